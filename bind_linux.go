@@ -0,0 +1,59 @@
+//go:build linux
+
+package ndp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// BindToVRF binds the socket of this Conn to the VRF master device of its
+// interface, so its traffic stays within that VRF
+func (c *Conn) BindToVRF() error {
+	master, err := vrfMaster(c.ifi.Name)
+	if err != nil {
+		return err
+	}
+
+	return c.BindToDevice(master)
+}
+
+func (t *rawTransport) BindToDevice(name string) error {
+	var serr error
+	err := t.rc.Control(func(fd uintptr) {
+		serr = unix.BindToDevice(int(fd), name)
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.NewSyscallError("setsockopt", serr)
+}
+
+// vrfMaster returns the name of the VRF device given interface is enslaved
+// to, as VRFs expose themselves as the master of their interfaces
+func vrfMaster(ifname string) (string, error) {
+	link, err := os.Readlink(filepath.Join("/sys/class/net", ifname, "master"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("interface %s is not enslaved to a VRF", ifname)
+		}
+
+		return "", err
+	}
+
+	master := filepath.Base(link)
+	// bridges and bonds are masters as well
+	if _, err := os.Stat(filepath.Join("/sys/class/net", master, "bridge")); err == nil {
+		return "", errors.New("master of interface " + ifname + " is a bridge, not a VRF")
+	}
+	if _, err := os.Stat(filepath.Join("/sys/class/net", master, "bonding")); err == nil {
+		return "", errors.New("master of interface " + ifname + " is a bond, not a VRF")
+	}
+
+	return master, nil
+}
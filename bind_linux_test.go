@@ -0,0 +1,49 @@
+//go:build linux
+
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBindToDevice(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range ifis {
+		c, err := Listen(&ifis[i], RoleHost)
+		if err != nil {
+			continue
+		}
+		defer c.Close()
+
+		if err = c.BindToDevice(ifis[i].Name); err != nil {
+			t.Error(err)
+		}
+
+		// unbinding
+		if err = c.BindToDevice(""); err != nil {
+			t.Error(err)
+		}
+
+		if err = c.BindToDevice("doesnotexist"); err == nil {
+			t.Errorf("expected error for non-existing device")
+		}
+		return
+	}
+
+	t.Skip("can't listen on any interface")
+}
+
+func TestVRFMaster(t *testing.T) {
+	if _, err := vrfMaster("doesnotexist"); err == nil {
+		t.Errorf("expected error for non-existing interface")
+	}
+
+	if _, err := vrfMaster("lo"); err == nil {
+		t.Errorf("expected error for interface without master")
+	}
+}
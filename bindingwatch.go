@@ -0,0 +1,270 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the defaults of BindingWatcher
+const (
+	defaultConflictWindow = 10 * time.Second
+	defaultMaxBindings    = 4096
+)
+
+// BindingEventType describes what BindingWatcher noticed about a binding
+type BindingEventType int
+
+// Binding event types
+const (
+	// BindingNew is an address seen for the first time
+	BindingNew BindingEventType = iota
+	// BindingChanged is an address that moved to another link-layer
+	// address, after the previous one went quiet for ConflictWindow
+	BindingChanged
+	// BindingConflict is an address claimed by another link-layer address
+	// while the previous one still claims it, which either is a duplicate
+	// address or somebody spoofing it
+	BindingConflict
+)
+
+func (t BindingEventType) String() string {
+	switch t {
+	case BindingNew:
+		return "new"
+	case BindingChanged:
+		return "changed"
+	case BindingConflict:
+		return "conflict"
+	}
+
+	return "unknown"
+}
+
+// Binding is an IPv6 address along with the link-layer address that claims
+// it
+type Binding struct {
+	Address          net.IP
+	LinkLayerAddress net.HardwareAddr
+	FirstSeen        time.Time
+	LastSeen         time.Time
+}
+
+// BindingEvent is reported by BindingWatcher
+type BindingEvent struct {
+	Type    BindingEventType
+	Binding Binding
+	// Previous is the link-layer address that claimed the address before
+	Previous net.HardwareAddr
+	// Metadata is that of the message that made the claim
+	Metadata *Metadata
+}
+
+func (e BindingEvent) String() string {
+	if e.Type == BindingNew {
+		return fmt.Sprintf("%s binding %s at %s", e.Type, e.Binding.Address, e.Binding.LinkLayerAddress)
+	}
+
+	return fmt.Sprintf("%s binding %s at %s, was %s", e.Type, e.Binding.Address, e.Binding.LinkLayerAddress, e.Previous)
+}
+
+// BindingWatcher implements a Handler that tracks which link-layer address
+// claims which IPv6 address in the neighbor solicitations, neighbor
+// advertisements and router advertisements it sees, like arpwatch does for
+// IPv4. It reports new bindings, and bindings that change or are claimed by
+// two link-layer addresses at once, which is how spoofing shows. All
+// messages are passed on to Next
+type BindingWatcher struct {
+	// Events is called for every binding event. It must not call back into
+	// the BindingWatcher
+	Events func(BindingEvent)
+	// Next receives all messages. Optional
+	Next Handler
+	// Logger, when set, logs new and changed bindings at info level and
+	// conflicts as warnings
+	Logger *slog.Logger
+	// ConflictWindow is how recently the previous link-layer address must
+	// have claimed an address for another claim to conflict. It defaults to
+	// 10 seconds
+	ConflictWindow time.Duration
+	// MaxEntries bounds the bindings kept, forgetting those seen longest
+	// ago. It defaults to 4096
+	MaxEntries int
+
+	mu       sync.Mutex
+	bindings map[string]*Binding
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewBindingWatcher returns an empty BindingWatcher
+func NewBindingWatcher() *BindingWatcher {
+	return &BindingWatcher{
+		ConflictWindow: defaultConflictWindow,
+		MaxEntries:     defaultMaxBindings,
+		bindings:       make(map[string]*Binding),
+		now:            time.Now,
+	}
+}
+
+// ServeNDP records the bindings that m, received with md, claims and passes m
+// on to Next
+func (w *BindingWatcher) ServeNDP(m ICMP, md *Metadata) {
+	if md != nil {
+		for _, b := range claims(m, md) {
+			w.Observe(b.Address, b.LinkLayerAddress, md)
+		}
+	}
+
+	if w.Next != nil {
+		w.Next.ServeNDP(m, md)
+	}
+}
+
+// Observe records that lla claims ip in a message received with md, which
+// may be nil
+func (w *BindingWatcher) Observe(ip net.IP, lla net.HardwareAddr, md *Metadata) {
+	if ip.IsUnspecified() || ip.IsMulticast() || len(lla) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	now := w.now()
+	key := ip.String()
+	var ev *BindingEvent
+	b, ok := w.bindings[key]
+	switch {
+	case !ok:
+		w.evict()
+		b = &Binding{
+			Address:          append(net.IP(nil), ip.To16()...),
+			LinkLayerAddress: append(net.HardwareAddr(nil), lla...),
+			FirstSeen:        now,
+		}
+		w.bindings[key] = b
+		ev = &BindingEvent{Type: BindingNew}
+	case !bytes.Equal(b.LinkLayerAddress, lla):
+		ev = &BindingEvent{Type: BindingChanged, Previous: b.LinkLayerAddress}
+		if now.Sub(b.LastSeen) < w.ConflictWindow {
+			ev.Type = BindingConflict
+		}
+		b.LinkLayerAddress = append(net.HardwareAddr(nil), lla...)
+		b.FirstSeen = now
+	}
+	b.LastSeen = now
+	if ev != nil {
+		ev.Binding, ev.Metadata = *b, md
+	}
+	w.mu.Unlock()
+
+	if ev == nil {
+		return
+	}
+	level := slog.LevelInfo
+	if ev.Type == BindingConflict {
+		level = slog.LevelWarn
+	}
+	loggerOr(w.Logger).Log(context.Background(), level, "binding "+ev.Type.String(), "addr", ev.Binding.Address.String(), "lladdr", ev.Binding.LinkLayerAddress.String(), "previous", ev.Previous.String())
+	if w.Events != nil {
+		w.Events(*ev)
+	}
+}
+
+// Lookup returns the binding of ip, if known
+func (w *BindingWatcher) Lookup(ip net.IP) (Binding, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b, ok := w.bindings[ip.String()]
+	if !ok {
+		return Binding{}, false
+	}
+
+	return *b, true
+}
+
+// Bindings returns all bindings known, sorted by address
+func (w *BindingWatcher) Bindings() []Binding {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bindings := make([]Binding, 0, len(w.bindings))
+	for _, b := range w.bindings {
+		bindings = append(bindings, *b)
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bytes.Compare(bindings[i].Address, bindings[j].Address) < 0
+	})
+
+	return bindings
+}
+
+// evict makes room for a new binding by forgetting the one seen longest ago.
+// It must be called with mu held
+func (w *BindingWatcher) evict() {
+	if w.MaxEntries <= 0 || len(w.bindings) < w.MaxEntries {
+		return
+	}
+
+	var oldest string
+	for k, b := range w.bindings {
+		if oldest == "" || b.LastSeen.Before(w.bindings[oldest].LastSeen) {
+			oldest = k
+		}
+	}
+	delete(w.bindings, oldest)
+}
+
+// claims returns the bindings m, received with md, claims. Solicitations and
+// router advertisements bind their source to their source link-layer
+// address, advertisements their target to their target link-layer address.
+// Without such option, the source of the frame is the claim when known
+func claims(m ICMP, md *Metadata) []Binding {
+	var (
+		ip  net.IP
+		lla net.HardwareAddr
+	)
+	switch m := m.(type) {
+	case *ICMPNeighborSolicitation:
+		ip, lla = md.Source, sourceLinkLayerAddress(m.Options)
+	case *ICMPRouterAdvertisement:
+		ip, lla = md.Source, sourceLinkLayerAddress(m.Options)
+	case *ICMPNeighborAdvertisement:
+		ip = m.TargetAddress
+		for _, o := range m.Options {
+			if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+				lla = o.LinkLayerAddress
+			}
+		}
+		// without option, only the target itself binds to the frame
+		if lla == nil && !ip.Equal(md.Source) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if lla == nil {
+		lla = md.SourceLinkLayerAddress
+	}
+
+	return []Binding{{Address: ip, LinkLayerAddress: lla}}
+}
+
+// sourceLinkLayerAddress returns the address of the source link-layer address
+// option among opts, if any
+func sourceLinkLayerAddress(opts ICMPOptions) net.HardwareAddr {
+	for _, o := range opts {
+		if o, ok := o.(*ICMPOptionSourceLinkLayerAddress); ok {
+			return o.LinkLayerAddress
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBindingWatcher(t *testing.T) {
+	w := NewBindingWatcher()
+	now := time.Unix(1000, 0)
+	w.now = func() time.Time { return now }
+	var events []BindingEvent
+	w.Events = func(e BindingEvent) { events = append(events, e) }
+	var next int
+	w.Next = HandlerFunc(func(ICMP, *Metadata) { next++ })
+
+	host := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	spoofed := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x66}
+	from := func(src net.IP, hw net.HardwareAddr) *Metadata {
+		return &Metadata{Source: src, Destination: net.IPv6linklocalallnodes, HopLimit: 255, SourceLinkLayerAddress: hw}
+	}
+	na := func(lla net.HardwareAddr) *ICMPNeighborAdvertisement {
+		m := &ICMPNeighborAdvertisement{TargetAddress: host, Override: true}
+		m.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+		return m
+	}
+
+	// solicitations bind their source
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	w.ServeNDP(ns, from(host, nil))
+	if len(events) != 1 || events[0].Type != BindingNew || !bytes.Equal(events[0].Binding.LinkLayerAddress, lla) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	// and so do advertisements their target, the same binding again is
+	// nothing new
+	now = now.Add(time.Second)
+	w.ServeNDP(na(lla), from(host, lla))
+	if len(events) != 1 {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	// another link-layer address claiming it right away conflicts
+	now = now.Add(time.Second)
+	w.ServeNDP(na(spoofed), from(host, spoofed))
+	if len(events) != 2 || events[1].Type != BindingConflict || !bytes.Equal(events[1].Previous, lla) || events[1].Metadata == nil {
+		t.Fatalf("unexpected events %v", events)
+	}
+	now = now.Add(time.Second)
+	w.ServeNDP(na(lla), from(host, lla))
+	if len(events) != 3 || events[2].Type != BindingConflict {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	// after a while of silence it merely changed
+	now = now.Add(time.Minute)
+	w.ServeNDP(na(spoofed), from(host, spoofed))
+	if len(events) != 4 || events[3].Type != BindingChanged {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if b, ok := w.Lookup(host); !ok || !bytes.Equal(b.LinkLayerAddress, spoofed) || !b.FirstSeen.Equal(now) {
+		t.Errorf("unexpected binding %+v", b)
+	}
+
+	// without option the frame tells, except for advertisements of
+	// another target
+	router := net.ParseIP("fe80::1")
+	w.ServeNDP(&ICMPRouterAdvertisement{}, from(router, lla))
+	w.ServeNDP(&ICMPNeighborAdvertisement{TargetAddress: net.ParseIP("fe80::3")}, from(router, lla))
+	// and duplicate address detection claims nothing yet
+	w.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: host}, from(net.IPv6unspecified, lla))
+	if b := w.Bindings(); len(b) != 2 || !b[0].Address.Equal(router) {
+		t.Errorf("unexpected bindings %v", b)
+	}
+	if next != 8 {
+		t.Errorf("expected all messages to be passed on, not %d", next)
+	}
+}
+
+func TestBindingWatcherLimit(t *testing.T) {
+	w := NewBindingWatcher()
+	w.MaxEntries = 2
+	now := time.Unix(1000, 0)
+	w.now = func() time.Time { return now }
+
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	for _, ip := range []string{"fe80::1", "fe80::2", "fe80::3"} {
+		now = now.Add(time.Second)
+		w.Observe(net.ParseIP(ip), lla, nil)
+	}
+	if _, ok := w.Lookup(net.ParseIP("fe80::1")); ok || len(w.Bindings()) != 2 {
+		t.Errorf("expected the oldest binding to be forgotten, not %v", w.Bindings())
+	}
+}
+
+func TestBindingEventString(t *testing.T) {
+	b := Binding{Address: net.ParseIP("fe80::2"), LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 2}}
+	if s := (BindingEvent{Type: BindingNew, Binding: b}).String(); s != "new binding fe80::2 at 02:00:00:00:00:02" {
+		t.Errorf("unexpected string %q", s)
+	}
+	e := BindingEvent{Type: BindingConflict, Binding: b, Previous: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}}
+	if s := e.String(); s != "conflict binding fe80::2 at 02:00:00:00:00:02, was 02:00:00:00:00:01" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if BindingEventType(42).String() != "unknown" {
+		t.Error("unexpected string of unknown type")
+	}
+}
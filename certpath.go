@@ -0,0 +1,75 @@
+package ndp
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+var (
+	errNoCertificate = errors.New("certification path advertisement without certificate")
+)
+
+// CertificationPath returns the certification path advertisements that
+// answer solicitation cps with path, which holds the certificate of the
+// router first and ends with the one issued by the trust anchor. Without
+// solicitation the whole path is advertised unsolicited. Advertisements
+// carry the Trust Anchor option the solicitation named, if any, as
+// described at https://tools.ietf.org/html/rfc3971#section-6.4.2
+func CertificationPath(cps *ICMPCertificationPathSolicitation, path []*x509.Certificate) []*ICMPCertificationPathAdvertisement {
+	var (
+		id     uint16
+		anchor ICMPOption
+		want   = CertificationPathAllComponents
+	)
+	if cps != nil {
+		id, want = cps.Identifier, int(cps.Component)
+		if o, err := cps.GetOption(ICMPOptionTypeTrustAnchor); err == nil {
+			anchor = *o
+		}
+	}
+
+	var cpas []*ICMPCertificationPathAdvertisement
+	// the certificate closest to the trust anchor goes first
+	for i := len(path) - 1; i >= 0; i-- {
+		if want != CertificationPathAllComponents && want != i {
+			continue
+		}
+
+		cpa := &ICMPCertificationPathAdvertisement{
+			Identifier:    id,
+			AllComponents: uint16(len(path)),
+			Component:     uint16(i),
+		}
+		cpa.AddOption(NewCertificateOption(path[i]))
+		if anchor != nil {
+			cpa.AddOption(anchor)
+		}
+		cpas = append(cpas, cpa)
+	}
+
+	return cpas
+}
+
+// Learn adds the certificate of certification path advertisement cpa to
+// Certificates, so the path it is part of can certify router keys
+func (v *Verifier) Learn(cpa *ICMPCertificationPathAdvertisement) error {
+	o, err := cpa.GetOption(ICMPOptionTypeCertificate)
+	if err != nil {
+		return errNoCertificate
+	}
+	cert, err := (*o).(*ICMPOptionCertificate).X509Certificate()
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, c := range v.Certificates {
+		if c.Equal(cert) {
+			return nil
+		}
+	}
+	v.Certificates = append(v.Certificates, cert)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package ndp
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCertificationPath(t *testing.T) {
+	s, _ := testSigner(t)
+	now := time.Now()
+	_, cert := testCertificate(t, s.Key.Public(), now)
+	_, other := testCertificate(t, s.Key.Public(), now)
+	path := []*x509.Certificate{cert, other}
+
+	// unsolicited, the whole path goes out from the trust anchor down
+	cpas := CertificationPath(nil, path)
+	if len(cpas) != 2 || cpas[0].Component != 1 || cpas[1].Component != 0 || cpas[0].AllComponents != 2 {
+		t.Fatalf("unexpected advertisements %v", cpas)
+	}
+	if o, err := cpas[1].GetOption(ICMPOptionTypeCertificate); err != nil || string((*o).(*ICMPOptionCertificate).Certificate) != string(cert.Raw) {
+		t.Errorf("expected the router certificate last, not %v", o)
+	}
+
+	// solicitations get the component asked for and their trust anchor
+	anchor, _ := NewTrustAnchorFQDN("example.com")
+	cps := &ICMPCertificationPathSolicitation{Identifier: 42, Component: 0}
+	cps.AddOption(anchor)
+	cpas = CertificationPath(cps, path)
+	if len(cpas) != 1 || cpas[0].Identifier != 42 || cpas[0].Component != 0 || !cpas[0].HasOption(ICMPOptionTypeTrustAnchor) {
+		t.Errorf("unexpected advertisements %v", cpas)
+	}
+	if cpas = CertificationPath(&ICMPCertificationPathSolicitation{Component: 5}, path); len(cpas) != 0 {
+		t.Errorf("unexpected advertisements %v", cpas)
+	}
+}
+
+func TestVerifierLearn(t *testing.T) {
+	s, addr := testSigner(t)
+	now := time.Unix(1000, 0)
+	anchors, cert := testCertificate(t, s.Key.Public(), now)
+	v := NewVerifier(anchors)
+	v.now = func() time.Time { return now }
+	v.Replay.now = v.now
+	s.now = v.now
+
+	if err := v.Learn(&ICMPCertificationPathAdvertisement{}); err != errNoCertificate {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, cpa := range CertificationPath(nil, []*x509.Certificate{cert}) {
+		// learning twice adds nothing
+		for i := 0; i < 2; i++ {
+			if err := v.Learn(cpa); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(v.Certificates) != 1 {
+		t.Errorf("unexpected certificates %v", v.Certificates)
+	}
+
+	// the learned path authorizes the router
+	m, md := testVerified(t, s, &ICMPRouterAdvertisement{RouterLifeTime: 1800}, addr, net.IPv6linklocalallnodes, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDSecured || !vd.Authorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+}
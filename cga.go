@@ -0,0 +1,277 @@
+package ndp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	errCGAParametersTooShort = errors.New("cga parameters too short")
+	errCGACollisionCount     = errors.New("cga collision count exceeds 2")
+	errCGAPrefix             = errors.New("cga subnet prefix doesn't match address")
+	errCGAHash1              = errors.New("cga hash1 doesn't match interface identifier")
+	errCGAHash2              = errors.New("cga hash2 doesn't meet sec")
+)
+
+// the highest collision count allowed for a CGA, see
+// https://tools.ietf.org/html/rfc3972#section-4
+const maxCGACollisionCount = 2
+
+// CGAExtension implements an Extension Field of the CGA Parameters as
+// described at https://tools.ietf.org/html/rfc4581#section-2
+type CGAExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// CGAParameters implements the CGA Parameters data structure as described at
+// https://tools.ietf.org/html/rfc3972#section-3
+type CGAParameters struct {
+	Modifier       [16]byte
+	SubnetPrefix   [8]byte
+	CollisionCount uint8
+	// PublicKey holds the DER encoded SubjectPublicKeyInfo
+	PublicKey  []byte
+	Extensions []CGAExtension
+}
+
+// NewCGAParameters returns CGAParameters for given subnet prefix and public
+// key, with a zero modifier and collision count
+func NewCGAParameters(prefix net.IP, pub crypto.PublicKey) (*CGAParameters, error) {
+	p := prefix.To16()
+	if p == nil {
+		return nil, fmt.Errorf("prefix %s is not an IPv6 address", prefix)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &CGAParameters{
+		PublicKey: der,
+	}
+	copy(params.SubnetPrefix[:], p[:8])
+
+	return params, nil
+}
+
+// ParseCGAParameters returns CGAParameters for given bytes or error if it
+// couldn't parse them
+func ParseCGAParameters(b []byte) (*CGAParameters, error) {
+	// modifier, subnet prefix and collision count take up 25 bytes
+	if len(b) < 25 {
+		return nil, errCGAParametersTooShort
+	}
+
+	params := &CGAParameters{
+		CollisionCount: b[24],
+	}
+	copy(params.Modifier[:], b[0:16])
+	copy(params.SubnetPrefix[:], b[16:24])
+
+	// the public key is DER encoded, so its own header tells us where
+	// it ends and the extension fields start
+	rest, err := asn1.Unmarshal(b[25:], &asn1.RawValue{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %s", err)
+	}
+
+	params.PublicKey = b[25:(len(b) - len(rest))]
+
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, errCGAParametersTooShort
+		}
+
+		l := int(binary.BigEndian.Uint16(rest[2:4]))
+		if len(rest) < 4+l {
+			return nil, fmt.Errorf("extension field of %d bytes exceeds cga parameters", l)
+		}
+
+		params.Extensions = append(params.Extensions, CGAExtension{
+			Type: binary.BigEndian.Uint16(rest[0:2]),
+			Data: rest[4:(4 + l)],
+		})
+		rest = rest[(4 + l):]
+	}
+
+	return params, nil
+}
+
+// Key returns the parsed public key of these CGAParameters
+func (p CGAParameters) Key() (crypto.PublicKey, error) {
+	return x509.ParsePKIXPublicKey(p.PublicKey)
+}
+
+// Prefix returns the subnet prefix of these CGAParameters as net.IP
+func (p CGAParameters) Prefix() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, p.SubnetPrefix[:])
+	return ip
+}
+
+// Marshal returns byte slice representing these CGAParameters
+func (p CGAParameters) Marshal() ([]byte, error) {
+	if len(p.PublicKey) == 0 {
+		return nil, errors.New("cga parameters require a public key")
+	}
+
+	b := make([]byte, 0, 25+len(p.PublicKey))
+	b = append(b, p.Modifier[:]...)
+	b = append(b, p.SubnetPrefix[:]...)
+	b = append(b, p.CollisionCount)
+	b = append(b, p.PublicKey...)
+	for _, e := range p.Extensions {
+		if len(e.Data) > 0xffff {
+			return nil, fmt.Errorf("extension field of %d bytes too large", len(e.Data))
+		}
+
+		h := make([]byte, 4)
+		binary.BigEndian.PutUint16(h[0:2], e.Type)
+		binary.BigEndian.PutUint16(h[2:4], uint16(len(e.Data)))
+		b = append(b, h...)
+		b = append(b, e.Data...)
+	}
+
+	return b, nil
+}
+
+// GenerateCGA generates a cryptographically generated address in prefix for
+// pub as described at https://tools.ietf.org/html/rfc3972#section-4, along
+// with the CGAParameters that prove it. Every step of sec, from 0 to 7,
+// makes the address 2^16 times harder to attack and to generate, so beyond
+// 1 or 2 generating takes very long. When duplicate address detection finds
+// the address in use, increment the collision count and use Address
+func GenerateCGA(prefix net.IP, pub crypto.PublicKey, sec uint8) (net.IP, *CGAParameters, error) {
+	if sec > 7 {
+		return nil, nil, fmt.Errorf("sec %d exceeds 7", sec)
+	}
+
+	params, err := NewCGAParameters(prefix, pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(params.Modifier[:]); err != nil {
+		return nil, nil, err
+	}
+
+	// hash2 is taken with a zero subnet prefix and collision count, so
+	// only the modifier changes between attempts
+	b, err := params.hash2Input()
+	if err != nil {
+		return nil, nil, err
+	}
+	for !hash2Valid(b, sec) {
+		incrementModifier(b[:16])
+	}
+	copy(params.Modifier[:], b[:16])
+
+	addr, err := params.Address(sec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, params, nil
+}
+
+// Address returns the CGA with given sec for these CGAParameters, which
+// must have been generated for that sec
+func (p CGAParameters) Address(sec uint8) (net.IP, error) {
+	if sec > 7 {
+		return nil, fmt.Errorf("sec %d exceeds 7", sec)
+	}
+	if p.CollisionCount > maxCGACollisionCount {
+		return nil, errCGACollisionCount
+	}
+
+	b, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	h := sha1.Sum(b)
+
+	addr := make(net.IP, net.IPv6len)
+	copy(addr, p.SubnetPrefix[:])
+	copy(addr[8:], h[:8])
+	// sec takes the leftmost 3 bits, the u and g bits are cleared
+	addr[8] = addr[8]&0x1c | sec<<5
+
+	return addr, nil
+}
+
+// VerifyCGA checks that addr is the cryptographically generated address of
+// params as described at https://tools.ietf.org/html/rfc3972#section-5,
+// which proves the owner of the public key in params chose it
+func VerifyCGA(addr net.IP, params *CGAParameters) error {
+	a := addr.To16()
+	if a == nil || addr.To4() != nil {
+		return fmt.Errorf("%s is not an IPv6 address", addr)
+	}
+	if params.CollisionCount > maxCGACollisionCount {
+		return errCGACollisionCount
+	}
+	if !bytes.Equal(a[:8], params.SubnetPrefix[:]) {
+		return errCGAPrefix
+	}
+
+	b, err := params.Marshal()
+	if err != nil {
+		return err
+	}
+	h := sha1.Sum(b)
+	// sec and the u and g bits aren't part of the hash
+	if h[0]&0x1c != a[8]&0x1c || !bytes.Equal(h[1:8], a[9:16]) {
+		return errCGAHash1
+	}
+
+	b, err = params.hash2Input()
+	if err != nil {
+		return err
+	}
+	if !hash2Valid(b, a[8]>>5) {
+		return errCGAHash2
+	}
+
+	return nil
+}
+
+// hash2Input returns the marshalled parameters hash2 is taken over, which
+// start with the modifier
+func (p CGAParameters) hash2Input() ([]byte, error) {
+	p.SubnetPrefix = [8]byte{}
+	p.CollisionCount = 0
+
+	return p.Marshal()
+}
+
+// hash2Valid reports whether the 16*sec leftmost bits of hash2 over b are
+// zero
+func hash2Valid(b []byte, sec uint8) bool {
+	h := sha1.Sum(b)
+	for _, c := range h[:2*int(sec)] {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// incrementModifier increments modifier as a 128 bit big endian integer
+func incrementModifier(modifier []byte) {
+	for i := len(modifier) - 1; i >= 0; i-- {
+		modifier[i]++
+		if modifier[i] != 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+// defaultControlSocket is where ra serves its control socket and ctl looks
+// for it by default
+const defaultControlSocket = "/run/ndp.sock"
+
+// ctl queries the control socket of a running ndp ra, like birdc does
+func ctl(ctx context.Context, args []string) error {
+	fs := newFlagSet("ctl")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ndp ctl [flags] %s|%s|%s\n\n", ndp.ControlNeighbors, ndp.ControlRAConfig, ndp.ControlEvents)
+		fs.PrintDefaults()
+	}
+	path := fs.String("s", defaultControlSocket, "control socket")
+	ifname := fs.String("i", "", "only show the neighbors or configuration of this interface")
+	limit := fs.Int("n", 0, "only show this many of the latest events")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single command")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := ndp.QueryControl(ctx, *path, ndp.ControlRequest{
+		Command:   fs.Arg(0),
+		Interface: *ifname,
+		Limit:     *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printResult(os.Stdout, result)
+}
+
+// printResult writes result indented
+func printResult(w io.Writer, result json.RawMessage) error {
+	var b bytes.Buffer
+	if err := json.Indent(&b, result, "", "  "); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+
+	_, err := b.WriteTo(w)
+	return err
+}
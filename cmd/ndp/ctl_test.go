@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintResult(t *testing.T) {
+	var b bytes.Buffer
+	if err := printResult(&b, []byte(`[{"address":"fe80::2","state":"stale"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[\n  {\n    \"address\": \"fe80::2\",\n    \"state\": \"stale\"\n  }\n]\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, not %q", expected, b.String())
+	}
+	if err := printResult(&b, []byte(`{`)); err == nil {
+		t.Error("expected invalid JSON to fail")
+	}
+}
@@ -0,0 +1,93 @@
+// Command ndp sends and receives ICMPv6 Neighbor Discovery messages with
+// package ndp. It watches the messages on a link, sends single messages
+// crafted from flags or JSON, resolves neighbors and runs a router
+// advertisement daemon configured like radvd or in YAML, which ctl inspects
+// through its control socket.
+//
+// Usage:
+//
+//	ndp monitor -i eth0 [-json|-radvdump]
+//	ndp send -i eth0 [flags] rs|ns|na|ra
+//	ndp send -i eth0 -json message.json
+//	ndp resolve -i eth0 [-c count] address
+//	ndp ra [-c /etc/radvd.conf|ndp.yaml] [-s /run/ndp.sock] [-v]
+//	ndp ctl [-s /run/ndp.sock] neighbors|ra-config|events
+//
+// Run a subcommand with -h to list its flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+var errNoInterface = errors.New("no interface given, use -i")
+
+// commands holds the subcommands by name
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"monitor": monitor,
+	"send":    send,
+	"resolve": resolve,
+	"ra":      ra,
+	"ctl":     ctl,
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := flag.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ndp: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd(ctx, flag.Args()[1:]); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "ndp %s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: ndp <command> [flags]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+}
+
+// newFlagSet returns the flags of subcommand name, which fail parsing with
+// an error rather than exiting
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("ndp "+name, flag.ContinueOnError)
+}
+
+// iface returns the interface called name
+func iface(name string) (*net.Interface, error) {
+	if name == "" {
+		return nil, errNoInterface
+	}
+
+	return net.InterfaceByName(name)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+// monitor prints the messages received on an interface as they arrive
+func monitor(ctx context.Context, args []string) error {
+	fs := newFlagSet("monitor")
+	ifname := fs.String("i", "", "interface to monitor")
+	asJSON := fs.Bool("json", false, "print messages as JSON, one per line")
+	radvdump := fs.Bool("radvdump", false, "print router advertisements in radvd.conf syntax, like radvdump")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ifi, err := iface(*ifname)
+	if err != nil {
+		return err
+	}
+	c, err := ndp.Listen(ifi, ndp.RoleMonitor)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	format := formatText
+	switch {
+	case *asJSON:
+		format = formatJSON
+	case *radvdump:
+		format = func(w io.Writer, t time.Time, m ndp.ICMP, md *ndp.Metadata) error {
+			return formatRadvdump(w, ifi.Name, t, m, md)
+		}
+	}
+	c.SetDropped(func(md *ndp.Metadata, err error) {
+		fmt.Fprintf(os.Stderr, "%s dropped message from %s: %s\n", time.Now().Format(timeFormat), md.Source, err)
+	})
+
+	return c.Serve(ctx, ndp.HandlerFunc(func(m ndp.ICMP, md *ndp.Metadata) {
+		if err := format(os.Stdout, time.Now(), m, md); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to format %s: %s\n", m.Type(), err)
+		}
+	}))
+}
+
+// timeFormat is how times of messages are printed, like tcpdump does
+const timeFormat = "15:04:05.000000"
+
+// formatText writes m, received at t with md, as a line of text
+func formatText(w io.Writer, t time.Time, m ndp.ICMP, md *ndp.Metadata) error {
+	_, err := fmt.Fprintf(w, "%s %s > %s: %s\n", t.Format(timeFormat), md.Source, md.Destination, m)
+	return err
+}
+
+// jsonMessage is a received message as formatJSON writes it
+type jsonMessage struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	HopLimit    int       `json:"hop_limit"`
+	Message     ndp.ICMP  `json:"message"`
+}
+
+// formatJSON writes m, received at t with md, as a line of JSON
+func formatJSON(w io.Writer, t time.Time, m ndp.ICMP, md *ndp.Metadata) error {
+	b, err := json.Marshal(jsonMessage{
+		Time:        t,
+		Source:      md.Source.String(),
+		Destination: md.Destination.String(),
+		HopLimit:    md.HopLimit,
+		Message:     m,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// formatRadvdump writes m, received at t on interface ifname with md, in
+// radvd.conf syntax when it is a router advertisement
+func formatRadvdump(w io.Writer, ifname string, t time.Time, m ndp.ICMP, md *ndp.Metadata) error {
+	ra, ok := m.(*ndp.ICMPRouterAdvertisement)
+	if !ok {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "# router advertisement from %s received at %s\n", md.Source, t.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if err := ndp.WriteRadvdConfig(w, ifname, ra); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
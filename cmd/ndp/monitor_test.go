@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+func testReceived() (time.Time, ndp.ICMP, *ndp.Metadata) {
+	return time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC),
+		&ndp.ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")},
+		&ndp.Metadata{Source: net.ParseIP("fe80::1"), Destination: net.ParseIP("ff02::1:ff00:2"), HopLimit: 255}
+}
+
+func TestFormatText(t *testing.T) {
+	tm, m, md := testReceived()
+	var b bytes.Buffer
+	if err := formatText(&b, tm, m, md); err != nil {
+		t.Fatal(err)
+	}
+	expected := "03:04:05.000006 fe80::1 > ff02::1:ff00:2: " + m.String() + "\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, not %q", expected, b.String())
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	tm, m, md := testReceived()
+	var b bytes.Buffer
+	if err := formatJSON(&b, tm, m, md); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"time":"2024-01-02T03:04:05.000006Z","source":"fe80::1","destination":"ff02::1:ff00:2","hop_limit":255,` +
+		`"message":{"type":135,"name":"neighbor solicitation","options":null,"target_address":"fe80::2"}}` + "\n"
+	if b.String() != expected {
+		t.Errorf("expected %s, not %s", expected, b.String())
+	}
+}
+
+func TestFormatRadvdump(t *testing.T) {
+	tm, m, md := testReceived()
+	var b bytes.Buffer
+	if err := formatRadvdump(&b, "eth0", tm, m, md); err != nil || b.Len() != 0 {
+		t.Fatalf("expected solicitations to be skipped, not %q: %v", b.String(), err)
+	}
+
+	ra := &ndp.ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+	if err := formatRadvdump(&b, "eth0", tm, ra, md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "# router advertisement from fe80::1 received at 2024-01-02T03:04:05Z\ninterface eth0\n{\n") ||
+		!strings.HasSuffix(b.String(), "}; # End of interface definition\n\n") {
+		t.Errorf("unexpected output %q", b.String())
+	}
+}
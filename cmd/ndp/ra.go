@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/skoef/ndp"
+	"github.com/skoef/ndp/ndpconfig"
+)
+
+// ra advertises the router on the interfaces of a radvd or YAML
+// configuration file, reloading it on SIGHUP
+func ra(ctx context.Context, args []string) error {
+	fs := newFlagSet("ra")
+	path := fs.String("c", "/etc/radvd.conf", "radvd configuration file, or ndpconfig YAML if it ends in .yaml or .yml")
+	socket := fs.String("s", defaultControlSocket, "control socket to serve, none if empty")
+	verbose := fs.Bool("v", false, "log every message sent and received")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	load := func() (map[string]ndp.RAConfig, error) {
+		return loadConfig(*path)
+	}
+	cfgs, err := load()
+	if err != nil {
+		return err
+	}
+	s, err := ndp.NewRAService(cfgs)
+	if err != nil {
+		return err
+	}
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	s.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	results := s.ReloadOnSignal(ctx, load, syscall.SIGHUP)
+	go func() {
+		for err := range results {
+			if err != nil {
+				s.Logger.Error("failed to reload configuration", "path", *path, "err", err)
+				continue
+			}
+			s.Logger.Info("reloaded configuration", "path", *path)
+		}
+	}()
+
+	if *socket != "" {
+		cs := ndp.NewControlServer()
+		cs.RAService = s
+		go func() {
+			if err := cs.ListenAndServe(ctx, *socket); ctx.Err() == nil {
+				s.Logger.Error("failed to serve control socket", "path", *socket, "err", err)
+			}
+		}()
+	}
+
+	return s.Serve(ctx)
+}
+
+// loadConfig returns the RAConfigs of the configuration file at path, which
+// its extension tells the syntax of
+func loadConfig(path string) (map[string]ndp.RAConfig, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		cfg, err := ndpconfig.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.RAConfigs(), nil
+	}
+
+	return ndp.LoadRadvdConfig(path)
+}
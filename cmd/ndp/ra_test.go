@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"radvd.conf": "interface eth0\n{\n\tAdvSendAdvert on;\n\tAdvManagedFlag on;\n};\n",
+		"ndp.yaml":   "interfaces:\n  eth0:\n    managed: true\n",
+		"ndp.yml":    "interfaces:\n  eth0:\n    managed: true\n",
+	}
+	for name, config := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		cfgs, err := loadConfig(path)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if cfg, ok := cfgs["eth0"]; !ok || !cfg.Managed || len(cfgs) != 1 {
+			t.Errorf("%s: unexpected configurations %v", name, cfgs)
+		}
+	}
+
+	// the extension tells the syntax
+	path := filepath.Join(dir, "radvd.yaml")
+	if err := os.WriteFile(path, []byte(files["radvd.conf"]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Errorf("expected radvd syntax to fail as YAML")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/skoef/ndp"
+)
+
+// resolve solicits a neighbor and prints its replies, like ndisc6 does
+func resolve(ctx context.Context, args []string) error {
+	fs := newFlagSet("resolve")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ndp resolve -i interface [flags] address\n\n")
+		fs.PrintDefaults()
+	}
+	ifname := fs.String("i", "", "interface to solicit on")
+	count := fs.Int("c", 3, "number of solicitations")
+	multicast := fs.Bool("m", true, "solicit the solicited-node group rather than the address itself")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single address")
+	}
+	ip := net.ParseIP(fs.Arg(0))
+	if ip == nil {
+		return fmt.Errorf("invalid address %q", fs.Arg(0))
+	}
+
+	ifi, err := iface(*ifname)
+	if err != nil {
+		return err
+	}
+	c, err := ndp.Listen(ifi, ndp.RoleHost)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	p := ndp.NewProber(c)
+	p.Count = *count
+	p.Multicast = *multicast
+	p.Reply = func(r ndp.ProbeReply) {
+		formatReply(os.Stdout, ip, r)
+	}
+
+	_, err = p.Probe(ctx, ip)
+	return err
+}
+
+// formatReply writes reply r of ip as a line of text
+func formatReply(w io.Writer, ip net.IP, r ndp.ProbeReply) {
+	router := ""
+	if r.Router {
+		router = " (router)"
+	}
+	fmt.Fprintf(w, "%s is at %s%s: seq=%d time=%s\n", ip, r.LinkLayerAddress, router, r.Seq, r.RTT)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+func TestFormatReply(t *testing.T) {
+	var b bytes.Buffer
+	formatReply(&b, net.ParseIP("fe80::2"), ndp.ProbeReply{
+		Seq:              1,
+		RTT:              1500 * time.Microsecond,
+		LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02},
+		Router:           true,
+	})
+	if expected := "fe80::2 is at 02:00:00:00:00:02 (router): seq=1 time=1.5ms\n"; b.String() != expected {
+		t.Errorf("expected %q, not %q", expected, b.String())
+	}
+}
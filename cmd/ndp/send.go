@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skoef/ndp"
+	"golang.org/x/net/ipv6"
+)
+
+var errNoTarget = errors.New("no target given, use -target")
+
+// addrList is a flag of addresses that may be given several times
+type addrList []net.IP
+
+func (l *addrList) String() string {
+	s := make([]string, len(*l))
+	for i, ip := range *l {
+		s[i] = ip.String()
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (l *addrList) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	*l = append(*l, ip)
+
+	return nil
+}
+
+// prefixList is a flag of prefixes that may be given several times
+type prefixList []*net.IPNet
+
+func (l *prefixList) String() string {
+	s := make([]string, len(*l))
+	for i, p := range *l {
+		s[i] = p.String()
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (l *prefixList) Set(s string) error {
+	_, p, err := net.ParseCIDR(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, p)
+
+	return nil
+}
+
+// sendOptions holds the flags of send that make up the message
+type sendOptions struct {
+	target    net.IP
+	dst       net.IP
+	router    bool
+	solicited bool
+	override  bool
+	lifetime  time.Duration
+	hopLimit  uint
+	managed   bool
+	other     bool
+	mtu       uint
+	prefixes  prefixList
+	rdnss     addrList
+	dnssl     string
+}
+
+// send sends a single message crafted from flags or read as JSON
+func send(ctx context.Context, args []string) error {
+	var o sendOptions
+	fs := newFlagSet("send")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ndp send -i interface [flags] rs|ns|na|ra\n       ndp send -i interface -json file\n\n")
+		fs.PrintDefaults()
+	}
+	ifname := fs.String("i", "", "interface to send on")
+	jsonFile := fs.String("json", "", "send the message of this JSON file, - for stdin")
+	dst := fs.String("dst", "", "destination address, which defaults to what suits the message")
+	target := fs.String("target", "", "target address of ns and na")
+	fs.BoolVar(&o.router, "router", false, "set the router flag of na")
+	fs.BoolVar(&o.solicited, "solicited", false, "set the solicited flag of na")
+	fs.BoolVar(&o.override, "override", true, "set the override flag of na")
+	fs.DurationVar(&o.lifetime, "lifetime", 30*time.Minute, "router lifetime of ra")
+	fs.UintVar(&o.hopLimit, "hoplimit", 64, "current hop limit of ra")
+	fs.BoolVar(&o.managed, "managed", false, "set the managed address configuration flag of ra")
+	fs.BoolVar(&o.other, "other", false, "set the other configuration flag of ra")
+	fs.UintVar(&o.mtu, "mtu", 0, "mtu option of ra, left out if 0")
+	fs.Var(&o.prefixes, "prefix", "prefix of ra, may be repeated")
+	fs.Var(&o.rdnss, "rdnss", "recursive dns server of ra, may be repeated")
+	fs.StringVar(&o.dnssl, "dnssl", "", "comma separated dns search list of ra")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for _, f := range []struct {
+		s  string
+		ip *net.IP
+	}{{*dst, &o.dst}, {*target, &o.target}} {
+		if f.s == "" {
+			continue
+		}
+		if *f.ip = net.ParseIP(f.s); *f.ip == nil {
+			return fmt.Errorf("invalid address %q", f.s)
+		}
+	}
+
+	ifi, err := iface(*ifname)
+	if err != nil {
+		return err
+	}
+
+	var m ndp.ICMP
+	switch {
+	case *jsonFile != "":
+		m, err = readMessage(*jsonFile)
+	case fs.NArg() != 1:
+		fs.Usage()
+		return errors.New("expected a single message type")
+	default:
+		m, err = buildMessage(fs.Arg(0), o, ifi.HardwareAddr)
+	}
+	if err != nil {
+		return err
+	}
+	if o.dst == nil {
+		if o.dst, err = defaultDestination(m); err != nil {
+			return err
+		}
+	}
+
+	c, err := ndp.Listen(ifi, ndp.RoleHost)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.WriteMessage(ctx, m, o.dst)
+}
+
+// readMessage returns the message of the JSON file called name, or of stdin
+// for -
+func readMessage(name string) (ndp.ICMP, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ndp.ParseMessageJSON(b)
+}
+
+// buildMessage returns the message of type typ that o describes, sent from
+// link-layer address lla
+func buildMessage(typ string, o sendOptions, lla net.HardwareAddr) (ndp.ICMP, error) {
+	switch typ {
+	case "rs":
+		rs := &ndp.ICMPRouterSolicitation{}
+		if lla != nil {
+			rs.AddOption(&ndp.ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return rs, nil
+	case "ns":
+		if o.target == nil {
+			return nil, errNoTarget
+		}
+		ns := &ndp.ICMPNeighborSolicitation{TargetAddress: o.target}
+		if lla != nil {
+			ns.AddOption(&ndp.ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return ns, nil
+	case "na":
+		if o.target == nil {
+			return nil, errNoTarget
+		}
+		na := &ndp.ICMPNeighborAdvertisement{
+			Router:        o.router,
+			Solicited:     o.solicited,
+			Override:      o.override,
+			TargetAddress: o.target,
+		}
+		if lla != nil {
+			na.AddOption(&ndp.ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return na, nil
+	case "ra":
+		cfg := ndp.DefaultRAConfig()
+		cfg.RouterLifetime = o.lifetime
+		cfg.HopLimit = uint8(o.hopLimit)
+		cfg.Managed = o.managed
+		cfg.Other = o.other
+		cfg.MTU = uint32(o.mtu)
+		for _, p := range o.prefixes {
+			cfg.Prefixes = append(cfg.Prefixes, ndp.NewRAPrefix(p))
+		}
+		cfg.RDNSS = o.rdnss
+		if o.dnssl != "" {
+			cfg.DNSSL = strings.Split(o.dnssl, ",")
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		ra := cfg.Advertisement()
+		if lla != nil {
+			ra.AddOption(&ndp.ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return ra, nil
+	}
+
+	return nil, fmt.Errorf("unsupported message type %q, not one of rs, ns, na or ra", typ)
+}
+
+// defaultDestination returns where m goes unless -dst tells otherwise
+func defaultDestination(m ndp.ICMP) (net.IP, error) {
+	switch m := m.(type) {
+	case *ndp.ICMPRouterSolicitation:
+		return net.IPv6linklocalallrouters, nil
+	case *ndp.ICMPNeighborSolicitation:
+		ip, _ := ndp.SolicitedNodeMulticast(m.TargetAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid target %s", m.TargetAddress)
+		}
+		return ip, nil
+	case *ndp.ICMPNeighborAdvertisement, *ndp.ICMPRouterAdvertisement:
+		return net.IPv6linklocalallnodes, nil
+	}
+
+	return nil, fmt.Errorf("no default destination for %s, use -dst", ipv6.ICMPType(m.Type()))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+func TestBuildMessage(t *testing.T) {
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	o := sendOptions{
+		target:    net.ParseIP("fe80::2"),
+		router:    true,
+		solicited: true,
+		lifetime:  time.Hour,
+		hopLimit:  64,
+		mtu:       1500,
+		prefixes:  prefixList{prefix},
+		rdnss:     addrList{net.ParseIP("2001:db8::53")},
+		dnssl:     "example.com,example.net",
+	}
+
+	tests := []struct {
+		typ string
+		dst string
+	}{
+		{"rs", "ff02::2"},
+		{"ns", "ff02::1:ff00:2"},
+		{"na", "ff02::1"},
+		{"ra", "ff02::1"},
+	}
+	for _, test := range tests {
+		m, err := buildMessage(test.typ, o, lla)
+		if err != nil {
+			t.Errorf("%s: %s", test.typ, err)
+			continue
+		}
+		dst, err := defaultDestination(m)
+		if err != nil {
+			t.Errorf("%s: %s", test.typ, err)
+			continue
+		}
+		if !dst.Equal(net.ParseIP(test.dst)) {
+			t.Errorf("%s: expected destination %s, not %s", test.typ, test.dst, dst)
+		}
+	}
+
+	m, err := buildMessage("na", o, lla)
+	if err != nil {
+		t.Fatal(err)
+	}
+	na := m.(*ndp.ICMPNeighborAdvertisement)
+	if !na.Router || !na.Solicited || !na.TargetAddress.Equal(o.target) || len(na.Options) != 1 {
+		t.Errorf("unexpected advertisement %s", na)
+	}
+
+	m, err = buildMessage("ra", o, lla)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra := m.(*ndp.ICMPRouterAdvertisement)
+	if ra.RouterLifeTime != 3600 || ra.HopLimit != 64 {
+		t.Errorf("unexpected advertisement %s", ra)
+	}
+	// mtu, prefix, rdnss, dnssl and source link-layer address
+	if len(ra.Options) != 5 {
+		t.Errorf("expected 5 options, not %d: %s", len(ra.Options), ra)
+	}
+}
+
+func TestBuildMessageErrors(t *testing.T) {
+	if _, err := buildMessage("ns", sendOptions{}, nil); err != errNoTarget {
+		t.Errorf("expected missing target, not %v", err)
+	}
+	if _, err := buildMessage("redirect", sendOptions{}, nil); err == nil {
+		t.Error("expected unsupported message type")
+	}
+	if _, err := defaultDestination(&ndp.ICMPRedirect{}); err == nil {
+		t.Error("expected no default destination of a redirect")
+	}
+}
+
+func TestAddrList(t *testing.T) {
+	var l addrList
+	for _, s := range []string{"2001:db8::1", "2001:db8::2"} {
+		if err := l.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.String() != "2001:db8::1,2001:db8::2" {
+		t.Errorf("unexpected list %s", l.String())
+	}
+	if err := l.Set("nope"); err == nil {
+		t.Error("expected invalid address")
+	}
+}
@@ -0,0 +1,891 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	errNoLinkLocalAddress = errors.New("interface has no IPv6 link-local address")
+	errNoMulticast        = errors.New("transport does not support multicast groups")
+	errNoBindToDevice     = errors.New("transport does not support binding to devices")
+	errNoSocketOptions    = errors.New("transport does not support socket options")
+	errNoPromiscuous      = errors.New("transport does not support promiscuous mode")
+)
+
+// Role describes the part a Conn plays on the link, which determines the
+// multicast groups it joins
+type Role int
+
+// roles currently defined
+const (
+	RoleHost Role = iota
+	RoleRouter
+	// RoleMonitor passively watches all NDP messages on the link
+	RoleMonitor
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleHost:
+		return "host"
+	case RoleRouter:
+		return "router"
+	case RoleMonitor:
+		return "monitor"
+	default:
+		return "<nil>"
+	}
+}
+
+// Types returns the ICMPv6 types a Conn with this role accepts by default.
+// Hosts ignore router solicitations and routers ignore router advertisements
+// of other routers, and likewise for the certification path solicitations
+// and advertisements of SEND. Routers accept the duplicate address requests
+// and confirmations 6LoWPAN routers exchange, while monitors accept every
+// NDP message
+func (r Role) Types() []ipv6.ICMPType {
+	switch r {
+	case RoleHost:
+		return []ipv6.ICMPType{
+			ipv6.ICMPTypeRouterAdvertisement,
+			ipv6.ICMPTypeNeighborSolicitation,
+			ipv6.ICMPTypeNeighborAdvertisement,
+			ipv6.ICMPTypeRedirect,
+			ipv6.ICMPTypeCertificationPathAdvertisement,
+		}
+	case RoleRouter:
+		return []ipv6.ICMPType{
+			ipv6.ICMPTypeRouterSolicitation,
+			ipv6.ICMPTypeNeighborSolicitation,
+			ipv6.ICMPTypeNeighborAdvertisement,
+			ipv6.ICMPTypeCertificationPathSolicitation,
+			ipv6.ICMPTypeDuplicateAddressRequest,
+			ipv6.ICMPTypeDuplicateAddressConfirmation,
+		}
+	default:
+		return []ipv6.ICMPType{
+			ipv6.ICMPTypeRouterSolicitation,
+			ipv6.ICMPTypeRouterAdvertisement,
+			ipv6.ICMPTypeNeighborSolicitation,
+			ipv6.ICMPTypeNeighborAdvertisement,
+			ipv6.ICMPTypeRedirect,
+			ipv6.ICMPTypeCertificationPathSolicitation,
+			ipv6.ICMPTypeCertificationPathAdvertisement,
+			ipv6.ICMPTypeDuplicateAddressRequest,
+			ipv6.ICMPTypeDuplicateAddressConfirmation,
+		}
+	}
+}
+
+// Metadata describes the IPv6 context a message was received with or should
+// be sent with. The link-layer addresses are only known to transports that
+// deal in complete frames
+type Metadata struct {
+	Source      net.IP
+	Destination net.IP
+	IfIndex     int
+	HopLimit    int
+	// HopLimitUnknown is set by transports that can't tell the hop limit of
+	// received messages, like raw sockets on Windows. Serve drops such
+	// messages unless SetAcceptUnknownHopLimit allows them
+	HopLimitUnknown bool
+	// Fragmented is set by transports that see the IPv6 header when the
+	// message arrived in fragments, for which Conn returns ErrFragmented
+	Fragmented                  bool
+	SourceLinkLayerAddress      net.HardwareAddr
+	DestinationLinkLayerAddress net.HardwareAddr
+	// Raw holds the ICMPv6 message as received, which Verifier checks
+	// signatures over since options don't necessarily marshal to the
+	// bytes they were parsed from. It is ignored when sending
+	Raw []byte
+}
+
+// transport implements an interface for sending and receiving raw ICMPv6
+// messages, which Conn uses to wrap different kinds of sockets
+type transport interface {
+	ReadFrom(b []byte) (int, *Metadata, error)
+	WriteTo(b []byte, md *Metadata, dst net.IP) (int, error)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// deviceTransport is implemented by transports whose socket can be bound to
+// a network device
+type deviceTransport interface {
+	BindToDevice(name string) error
+}
+
+// promiscuousTransport is implemented by transports that can capture traffic
+// not addressed to us
+type promiscuousTransport interface {
+	SetPromiscuous(enabled bool) error
+}
+
+// socketOptionTransport is implemented by transports that let the IPv6 stack
+// fill in the header of outgoing messages
+type socketOptionTransport interface {
+	SetHopLimit(hoplim int) error
+	SetMulticastHopLimit(hoplim int) error
+	SetTrafficClass(tclass int) error
+}
+
+// batchTransport is implemented by transports that can read or write several
+// messages at once. ReadBatch fills ns and mds for each buffer read into
+type batchTransport interface {
+	ReadBatch(bufs [][]byte, ns []int, mds []*Metadata) (int, error)
+	WriteBatch(bufs [][]byte, mds []*Metadata, dsts []net.IP) (int, error)
+}
+
+// multicastTransport is implemented by transports that need to explicitly
+// join multicast groups to receive traffic sent to them
+type multicastTransport interface {
+	JoinGroup(group net.IP) error
+	LeaveGroup(group net.IP) error
+}
+
+// Conn implements a connection for sending and receiving NDP messages on a
+// single interface
+type Conn struct {
+	t     transport
+	ifi   *net.Interface
+	addr  net.IP
+	role  Role
+	limit *rateLimiter
+	// accept holds the types ReadFrom passes on, or all if nil
+	accept map[ipv6.ICMPType]bool
+	// unknownHopLimit has Serve accept messages with HopLimitUnknown
+	unknownHopLimit bool
+	// dropped is told about the messages Serve drops while reading
+	dropped func(md *Metadata, err error)
+	// sent is told about the messages this Conn sends
+	sent func(m ICMP, dst net.IP)
+	// limits are those messages are parsed with
+	limits ParseLimits
+	// log is the logger of SetLogger
+	log *slog.Logger
+	// tracer is the Tracer of SetTracer
+	tracer Tracer
+}
+
+// Listen returns a Conn that sends and receives NDP messages on given
+// interface, using its link-local address as source address. Depending on
+// role, the Conn joins the multicast groups needed to see the messages
+// relevant to it: all-nodes and the solicited-node groups of all addresses
+// of the interface for hosts, and all-routers as well for routers and
+// monitors. Only messages of the Types of role are read by default
+func Listen(ifi *net.Interface, role Role) (*Conn, error) {
+	addr, err := linkLocalAddr(ifi)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := listenTransport(ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(t, ifi, addr, role)
+}
+
+// newConn returns a Conn for given transport, which has joined the multicast
+// groups for role
+func newConn(t transport, ifi *net.Interface, addr net.IP, role Role) (*Conn, error) {
+	c := &Conn{
+		t:      t,
+		ifi:    ifi,
+		addr:   addr,
+		role:   role,
+		limit:  newRateLimiter(),
+		limits: DefaultParseLimits,
+	}
+
+	groups, err := roleGroups(ifi, role)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	for _, g := range groups {
+		if err := c.JoinGroup(g); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to join %s: %s", g, err)
+		}
+	}
+
+	c.SetAllowedTypes(role.Types()...)
+
+	return c, nil
+}
+
+// SetAllowedTypes restricts the messages this Conn reads to given types,
+// which default to the Types of its Role. Where the transport supports it,
+// the kernel filters the other types out, otherwise ReadFrom and ReadBatch
+// drop them before parsing. The returned error only tells that the kernel
+// filter couldn't be installed, the types are restricted nonetheless. It
+// must not be called while other goroutines read from this Conn
+func (c *Conn) SetAllowedTypes(types ...ipv6.ICMPType) error {
+	c.accept = make(map[ipv6.ICMPType]bool, len(types))
+	for _, t := range types {
+		c.accept[t] = true
+	}
+
+	return c.SetICMPFilter(typeFilter(types))
+}
+
+// allowed reports whether the message in b is of an accepted type
+func (c *Conn) allowed(b []byte) bool {
+	if c.accept == nil || len(b) == 0 {
+		return true
+	}
+
+	return c.accept[ipv6.ICMPType(b[0])]
+}
+
+// SetParseLimits sets the limits ReadFrom and ReadBatch parse messages
+// with, which default to DefaultParseLimits. Messages exceeding them fail
+// with a ParseLimitError. It must not be called while other goroutines read
+// from this Conn
+func (c *Conn) SetParseLimits(l ParseLimits) {
+	c.limits = l
+}
+
+// SetDropped sets f to be called for every message Serve drops because it
+// failed to parse, exceeded the ParseLimits or, with ErrFragmented, arrived
+// in IPv6 fragments. It must not be called while Serve runs
+func (c *Conn) SetDropped(f func(md *Metadata, err error)) {
+	c.dropped = f
+}
+
+// SetSent sets f to be called for every message WriteTo, WriteMessage and
+// WriteBatch send. It must not be called while other goroutines write to
+// this Conn
+func (c *Conn) SetSent(f func(m ICMP, dst net.IP)) {
+	c.sent = f
+}
+
+// SetAcceptUnknownHopLimit sets whether Serve passes on messages whose hop
+// limit the transport couldn't tell. RFC 4861 has nodes drop NDP messages
+// with a hop limit other than 255 so off-link attackers can't spoof them, so
+// only enable this where that risk is acceptable, like on Windows where raw
+// sockets don't report the hop limit. It must not be called while Serve runs
+func (c *Conn) SetAcceptUnknownHopLimit(enabled bool) {
+	c.unknownHopLimit = enabled
+}
+
+// Role returns the role this Conn was created for
+func (c *Conn) Role() Role {
+	return c.role
+}
+
+// JoinGroup joins given multicast group on the interface of this Conn
+func (c *Conn) JoinGroup(group net.IP) error {
+	mt, ok := c.t.(multicastTransport)
+	if !ok {
+		return errNoMulticast
+	}
+
+	return mt.JoinGroup(group)
+}
+
+// LeaveGroup leaves given multicast group on the interface of this Conn
+func (c *Conn) LeaveGroup(group net.IP) error {
+	mt, ok := c.t.(multicastTransport)
+	if !ok {
+		return errNoMulticast
+	}
+
+	return mt.LeaveGroup(group)
+}
+
+// BindToDevice restricts the socket of this Conn to the network device named
+// name, using SO_BINDTODEVICE on Linux. Binding to a VRF master device
+// confines the Conn to the traffic of that VRF. An empty name removes the
+// binding
+func (c *Conn) BindToDevice(name string) error {
+	dt, ok := c.t.(deviceTransport)
+	if !ok {
+		return errNoBindToDevice
+	}
+
+	return dt.BindToDevice(name)
+}
+
+// SetPromiscuous puts the interface of this Conn in promiscuous mode, so it
+// also reads NDP messages addressed to others, such as the neighbor
+// solicitations other hosts send for duplicate address detection. Only Conns
+// returned by ListenFrames support it, and will typically use RoleMonitor.
+// Promiscuous mode ends when the Conn is closed
+func (c *Conn) SetPromiscuous(enabled bool) error {
+	pt, ok := c.t.(promiscuousTransport)
+	if !ok {
+		return errNoPromiscuous
+	}
+
+	return pt.SetPromiscuous(enabled)
+}
+
+// Addr returns the source address used by this Conn
+func (c *Conn) Addr() net.IP {
+	return c.addr
+}
+
+// SetSourceAddr sets the source address of messages that don't specify one
+// in their Metadata. By default the link-local address the Conn listens on
+// is used, but routers with several link-local addresses need to source
+// their advertisements from the one hosts know them by. It must not be
+// called while other goroutines write to this Conn
+func (c *Conn) SetSourceAddr(addr net.IP) error {
+	if addr.To16() == nil || addr.To4() != nil {
+		return fmt.Errorf("source %s is not an IPv6 address", addr)
+	}
+
+	c.addr = addr
+	return nil
+}
+
+// SetHopLimit sets the hop limit of unicast messages sent by this Conn,
+// which defaults to the 255 NDP requires
+func (c *Conn) SetHopLimit(hoplim int) error {
+	st, ok := c.t.(socketOptionTransport)
+	if !ok {
+		return errNoSocketOptions
+	}
+
+	return st.SetHopLimit(hoplim)
+}
+
+// SetMulticastHopLimit sets the hop limit of multicast messages sent by this
+// Conn, which defaults to the 255 NDP requires
+func (c *Conn) SetMulticastHopLimit(hoplim int) error {
+	st, ok := c.t.(socketOptionTransport)
+	if !ok {
+		return errNoSocketOptions
+	}
+
+	return st.SetMulticastHopLimit(hoplim)
+}
+
+// SetTrafficClass sets the traffic class of messages sent by this Conn
+func (c *Conn) SetTrafficClass(tclass int) error {
+	st, ok := c.t.(socketOptionTransport)
+	if !ok {
+		return errNoSocketOptions
+	}
+
+	return st.SetTrafficClass(tclass)
+}
+
+// Interface returns the interface this Conn is bound to
+func (c *Conn) Interface() *net.Interface {
+	return c.ifi
+}
+
+// ReadFrom reads and parses a single NDP message and returns it along with
+// the Metadata it was received with
+func (c *Conn) ReadFrom() (ICMP, *Metadata, error) {
+	b := make([]byte, c.bufferSize())
+	var (
+		n   int
+		md  *Metadata
+		err error
+	)
+	for {
+		n, md, err = c.t.ReadFrom(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if c.allowed(b[:n]) {
+			break
+		}
+	}
+
+	if md != nil && md.Fragmented {
+		c.logDropped(md, ErrFragmented)
+		return nil, md, ErrFragmented
+	}
+
+	m, err := c.limits.ParseMessage(b[:n])
+	if err != nil {
+		c.logDropped(md, err)
+		return nil, md, err
+	}
+	if md != nil {
+		md.Raw = b[:n]
+	}
+
+	return m, md, nil
+}
+
+// ReceivedMessage holds a single message read by ReadBatch. Err is set
+// instead of Message when the message failed to parse
+type ReceivedMessage struct {
+	Message  ICMP
+	Metadata *Metadata
+	Err      error
+}
+
+// ReadBatch reads up to len(rms) messages into rms and returns how many it
+// read. It waits for at least one message, and uses a single recvmmsg call
+// where the platform supports it. Messages that fail to parse don't fail the
+// batch, but carry their error in rms
+func (c *Conn) ReadBatch(rms []ReceivedMessage) (int, error) {
+	if len(rms) == 0 {
+		return 0, nil
+	}
+
+	bt, ok := c.t.(batchTransport)
+	if !ok {
+		m, md, err := c.ReadFrom()
+		if err != nil && md == nil {
+			return 0, err
+		}
+
+		rms[0] = ReceivedMessage{Message: m, Metadata: md, Err: err}
+		return 1, nil
+	}
+
+	bufs := make([][]byte, len(rms))
+	for i := range bufs {
+		bufs[i] = make([]byte, c.bufferSize())
+	}
+	ns := make([]int, len(rms))
+	mds := make([]*Metadata, len(rms))
+
+	for {
+		n, err := bt.ReadBatch(bufs, ns, mds)
+		if err != nil {
+			return 0, err
+		}
+
+		var read int
+		for i := 0; i < n; i++ {
+			if !c.allowed(bufs[i][:ns[i]]) {
+				continue
+			}
+
+			var (
+				m   ICMP
+				err = ErrFragmented
+			)
+			if mds[i] == nil || !mds[i].Fragmented {
+				m, err = c.limits.ParseMessage(bufs[i][:ns[i]])
+			}
+			if err == nil && mds[i] != nil {
+				mds[i].Raw = bufs[i][:ns[i]]
+			}
+			if err != nil {
+				c.logDropped(mds[i], err)
+			}
+			rms[read] = ReceivedMessage{Message: m, Metadata: mds[i], Err: err}
+			read++
+		}
+
+		// wait for more when the whole batch was dropped
+		if read > 0 {
+			return read, nil
+		}
+	}
+}
+
+// WriteTo marshals and sends given message to dst. Metadata is optional and
+// can be used to override the source address or hop limit of the message.
+// It returns ErrRateLimited rather than sending messages more often than
+// RFC 4861 allows, see SetRateLimiting
+func (c *Conn) WriteTo(m ICMP, md *Metadata, dst net.IP) error {
+	return c.writeTo(m, md, dst, true)
+}
+
+// writeTo implements WriteTo, skipping the rate limiter unless limit is set
+func (c *Conn) writeTo(m ICMP, md *Metadata, dst net.IP, limit bool) error {
+	b, md, err := c.prepare(m, md, dst, limit)
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.t.WriteTo(b, md, dst); err != nil {
+		return err
+	}
+	c.logger().Debug("sent message", sentAttrs(m, dst)...)
+	if c.sent != nil {
+		c.sent(m, dst)
+	}
+
+	return nil
+}
+
+// logDropped logs that the message received with md was dropped for err
+func (c *Conn) logDropped(md *Metadata, err error) {
+	c.logger().Debug("dropped message", append(receivedAttrs(nil, md), "err", err)...)
+}
+
+// OutgoingMessage holds a single message to be sent by WriteBatch, with
+// optional Metadata like WriteTo takes
+type OutgoingMessage struct {
+	Message     ICMP
+	Metadata    *Metadata
+	Destination net.IP
+}
+
+// WriteBatch sends given messages like WriteTo would, using a single sendmmsg
+// call where the platform supports it. It returns how many messages were
+// sent, which falls short of len(oms) when an error occurs. All messages go
+// out over the interface of this Conn, so sending on several interfaces
+// still takes a batch per Conn
+func (c *Conn) WriteBatch(oms []OutgoingMessage) (int, error) {
+	bufs := make([][]byte, 0, len(oms))
+	mds := make([]*Metadata, 0, len(oms))
+	dsts := make([]net.IP, 0, len(oms))
+
+	// send whatever was prepared before a message fails to marshal or
+	// would exceed its rate
+	var perr error
+	for _, om := range oms {
+		b, md, err := c.prepare(om.Message, om.Metadata, om.Destination, true)
+		if err != nil {
+			perr = err
+			break
+		}
+
+		bufs = append(bufs, b)
+		mds = append(mds, md)
+		dsts = append(dsts, om.Destination)
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if bt, ok := c.t.(batchTransport); ok && len(bufs) > 0 {
+		n, err = bt.WriteBatch(bufs, mds, dsts)
+	} else {
+		for ; n < len(bufs); n++ {
+			if _, err = c.t.WriteTo(bufs[n], mds[n], dsts[n]); err != nil {
+				break
+			}
+		}
+	}
+	for _, om := range oms[:n] {
+		c.logger().Debug("sent message", sentAttrs(om.Message, om.Destination)...)
+		if c.sent != nil {
+			c.sent(om.Message, om.Destination)
+		}
+	}
+
+	if err != nil {
+		return n, err
+	}
+
+	return n, perr
+}
+
+// prepare marshals m and returns it along with the Metadata to send it with
+// if it may be sent to dst now, or regardless of that unless limit is set
+func (c *Conn) prepare(m ICMP, md *Metadata, dst net.IP, limit bool) ([]byte, *Metadata, error) {
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if limit && c.limit != nil && !c.limit.allow(m, dst) {
+		return nil, nil, ErrRateLimited
+	}
+
+	// the source address is selected per message, so changing it doesn't
+	// require rebinding the socket
+	if c.addr != nil && (md == nil || md.Source == nil) {
+		cp := Metadata{}
+		if md != nil {
+			cp = *md
+		}
+		cp.Source = c.addr
+		md = &cp
+	}
+
+	return b, md, nil
+}
+
+// ReadMessage works like ReadFrom, but returns early with the error of ctx
+// when it is cancelled or its deadline expires. It takes over the read
+// deadline of this Conn while it runs
+func (c *Conn) ReadMessage(ctx context.Context) (ICMP, *Metadata, error) {
+	stop, err := watchContext(ctx, c.t.SetReadDeadline)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, md, err := c.ReadFrom()
+	if err = stop(err); err != nil {
+		return nil, md, err
+	}
+
+	return m, md, nil
+}
+
+// WriteMessage works like WriteTo without Metadata, but returns early with
+// the error of ctx when it is cancelled or its deadline expires. It takes
+// over the write deadline of this Conn while it runs
+func (c *Conn) WriteMessage(ctx context.Context, m ICMP, dst net.IP) error {
+	stop, err := watchContext(ctx, c.t.SetWriteDeadline)
+	if err != nil {
+		return err
+	}
+
+	return stop(c.WriteTo(m, nil, dst))
+}
+
+// watchContext applies the deadline of ctx using setDeadline and expires it
+// right away once ctx is done, to unblock pending I/O. The returned function
+// stops watching and clears the deadline. Given the error of the I/O, it
+// returns the error of ctx instead if that is what made the I/O fail
+func watchContext(ctx context.Context, setDeadline func(time.Time) error) (func(error) error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d, hasDeadline := ctx.Deadline()
+	if err := setDeadline(d); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			setDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func(err error) error {
+		close(done)
+		<-stopped
+		setDeadline(time.Time{})
+
+		if err == nil {
+			return nil
+		}
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+		// the socket deadline may expire just before ctx notices
+		if hasDeadline && !time.Now().Before(d) {
+			return context.DeadlineExceeded
+		}
+
+		return err
+	}, nil
+}
+
+// SetDeadline sets the read and write deadlines of this Conn
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.t.SetReadDeadline(t); err != nil {
+		return err
+	}
+
+	return c.t.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of this Conn
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.t.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of this Conn
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.t.SetWriteDeadline(t)
+}
+
+// Close closes this Conn
+func (c *Conn) Close() error {
+	return c.t.Close()
+}
+
+// NDP messages never exceed the link MTU
+func (c *Conn) bufferSize() int {
+	if c.ifi != nil && c.ifi.MTU > 0 {
+		return c.ifi.MTU
+	}
+
+	return 1500
+}
+
+// rawTransport implements transport on top of a raw ICMPv6 socket
+type rawTransport struct {
+	pc  *ipv6.PacketConn
+	rc  syscall.RawConn
+	ifi *net.Interface
+}
+
+func listenRaw(ifi *net.Interface, addr net.IP) (*rawTransport, error) {
+	c, err := net.ListenPacket("ip6:ipv6-icmp", fmt.Sprintf("%s%%%s", addr, ifi.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	pc := ipv6.NewPacketConn(c)
+	// hop limit of all NDP messages must be 255
+	if err := pc.SetHopLimit(255); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := pc.SetMulticastHopLimit(255); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := pc.SetMulticastInterface(ifi); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := enableControlMessages(pc); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	rc, err := c.(*net.IPConn).SyscallConn()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &rawTransport{
+		pc:  pc,
+		rc:  rc,
+		ifi: ifi,
+	}, nil
+}
+
+func (t *rawTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	n, cm, src, err := t.pc.ReadFrom(b)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	md := &Metadata{
+		HopLimitUnknown: !hopLimitKnown,
+	}
+	if a, ok := src.(*net.IPAddr); ok {
+		md.Source = a.IP
+	}
+	if cm != nil {
+		md.Destination = cm.Dst
+		md.IfIndex = cm.IfIndex
+		md.HopLimit = cm.HopLimit
+	}
+
+	return n, md, nil
+}
+
+func (t *rawTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	return t.pc.WriteTo(b, outgoingControlMessage(md), &net.IPAddr{IP: dst, Zone: t.ifi.Name})
+}
+
+// outgoingControlMessage returns the control message that applies md to an
+// outgoing message
+func outgoingControlMessage(md *Metadata) *ipv6.ControlMessage {
+	if md == nil {
+		return nil
+	}
+
+	return &ipv6.ControlMessage{
+		Src:      md.Source,
+		IfIndex:  md.IfIndex,
+		HopLimit: md.HopLimit,
+	}
+}
+
+func (t *rawTransport) SetHopLimit(hoplim int) error {
+	return t.pc.SetHopLimit(hoplim)
+}
+
+func (t *rawTransport) SetMulticastHopLimit(hoplim int) error {
+	return t.pc.SetMulticastHopLimit(hoplim)
+}
+
+func (t *rawTransport) SetTrafficClass(tclass int) error {
+	return t.pc.SetTrafficClass(tclass)
+}
+
+func (t *rawTransport) JoinGroup(group net.IP) error {
+	return t.pc.JoinGroup(t.ifi, &net.IPAddr{IP: group})
+}
+
+func (t *rawTransport) LeaveGroup(group net.IP) error {
+	return t.pc.LeaveGroup(t.ifi, &net.IPAddr{IP: group})
+}
+
+func (t *rawTransport) SetReadDeadline(d time.Time) error {
+	return t.pc.SetReadDeadline(d)
+}
+
+func (t *rawTransport) SetWriteDeadline(d time.Time) error {
+	return t.pc.SetWriteDeadline(d)
+}
+
+func (t *rawTransport) Close() error {
+	return t.pc.Close()
+}
+
+// roleGroups returns the multicast groups to join on given interface for role
+func roleGroups(ifi *net.Interface, role Role) ([]net.IP, error) {
+	groups := []net.IP{net.IPv6linklocalallnodes}
+	if role == RoleRouter || role == RoleMonitor {
+		groups = append(groups, net.IPv6linklocalallrouters)
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// address resolution and DAD for our own addresses go to their
+	// solicited-node groups, which several addresses may share
+	seen := map[string]bool{}
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.To4() != nil || ipn.IP.IsLoopback() {
+			continue
+		}
+
+		g, _ := SolicitedNodeMulticast(ipn.IP)
+		if seen[g.String()] {
+			continue
+		}
+
+		seen[g.String()] = true
+		groups = append(groups, g)
+	}
+
+	return groups, nil
+}
+
+// linkLocalAddr returns the first IPv6 link-local address of given interface
+func linkLocalAddr(ifi *net.Interface) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipn.IP.To4() == nil && ipn.IP.IsLinkLocalUnicast() {
+			return ipn.IP, nil
+		}
+	}
+
+	return nil, errNoLinkLocalAddress
+}
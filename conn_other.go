@@ -0,0 +1,88 @@
+//go:build !windows
+
+package ndp
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv6"
+)
+
+// hopLimitKnown tells that raw sockets report the hop limit of received
+// messages
+const hopLimitKnown = true
+
+// listenTransport returns a raw socket transport
+func listenTransport(ifi *net.Interface, addr net.IP) (transport, error) {
+	return listenRaw(ifi, addr)
+}
+
+// we want to know hop limit and addresses of incoming messages
+const controlFlags = ipv6.FlagHopLimit | ipv6.FlagSrc | ipv6.FlagDst | ipv6.FlagInterface
+
+// enableControlMessages has the kernel tell us hop limit and addresses of
+// incoming messages
+func enableControlMessages(pc *ipv6.PacketConn) error {
+	return pc.SetControlMessage(controlFlags, true)
+}
+
+// ReadBatch uses recvmmsg on Linux and reads a single message elsewhere
+func (t *rawTransport) ReadBatch(bufs [][]byte, ns []int, mds []*Metadata) (int, error) {
+	ms := make([]ipv6.Message, len(bufs))
+	for i := range ms {
+		ms[i] = ipv6.Message{
+			Buffers: [][]byte{bufs[i]},
+			OOB:     ipv6.NewControlMessage(controlFlags),
+		}
+	}
+
+	n, err := t.pc.ReadBatch(ms, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		ns[i] = ms[i].N
+
+		md := &Metadata{}
+		if a, ok := ms[i].Addr.(*net.IPAddr); ok {
+			md.Source = a.IP
+		}
+
+		cm := &ipv6.ControlMessage{}
+		if err := cm.Parse(ms[i].OOB[:ms[i].NN]); err == nil {
+			md.Destination = cm.Dst
+			md.IfIndex = cm.IfIndex
+			md.HopLimit = cm.HopLimit
+		}
+
+		mds[i] = md
+	}
+
+	return n, nil
+}
+
+// WriteBatch uses sendmmsg on Linux and writes one message per call elsewhere
+func (t *rawTransport) WriteBatch(bufs [][]byte, mds []*Metadata, dsts []net.IP) (int, error) {
+	ms := make([]ipv6.Message, len(bufs))
+	for i := range ms {
+		ms[i] = ipv6.Message{
+			Buffers: [][]byte{bufs[i]},
+			OOB:     outgoingControlMessage(mds[i]).Marshal(),
+			Addr:    &net.IPAddr{IP: dsts[i], Zone: t.ifi.Name},
+		}
+	}
+
+	// sendmmsg may send part of the batch only
+	var n int
+	for n < len(ms) {
+		w, err := t.pc.WriteBatch(ms[n:], 0)
+		if err != nil {
+			return n, err
+		}
+
+		n += w
+	}
+
+	return n, nil
+}
@@ -0,0 +1,683 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// testTransport implements transport by handing out prepared packets and
+// recording written ones
+type testTransport struct {
+	in     [][]byte
+	md     *Metadata
+	out    [][]byte
+	outMD  []*Metadata
+	dst    []net.IP
+	closed bool
+}
+
+func (t *testTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	if len(t.in) == 0 {
+		return 0, nil, errors.New("no more packets")
+	}
+
+	n := copy(b, t.in[0])
+	t.in = t.in[1:]
+	return n, t.md, nil
+}
+
+func (t *testTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	t.out = append(t.out, b)
+	t.outMD = append(t.outMD, md)
+	t.dst = append(t.dst, dst)
+	return len(b), nil
+}
+
+func (t *testTransport) SetReadDeadline(d time.Time) error  { return nil }
+func (t *testTransport) SetWriteDeadline(d time.Time) error { return nil }
+func (t *testTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+// blockingTransport implements transport that never receives anything and
+// only returns from ReadFrom when its read deadline expires
+type blockingTransport struct {
+	testTransport
+	mu       sync.Mutex
+	deadline time.Time
+	changed  chan struct{}
+}
+
+func (t *blockingTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	for {
+		t.mu.Lock()
+		d, changed := t.deadline, t.changed
+		t.mu.Unlock()
+
+		var expired <-chan time.Time
+		if !d.IsZero() {
+			expired = time.After(time.Until(d))
+		}
+
+		select {
+		case <-expired:
+			return 0, nil, errors.New("i/o timeout")
+		case <-changed:
+		}
+	}
+}
+
+func (t *blockingTransport) SetReadDeadline(d time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadline = d
+	close(t.changed)
+	t.changed = make(chan struct{})
+	return nil
+}
+
+func TestConnReadWriteMessage(t *testing.T) {
+	bt := &blockingTransport{changed: make(chan struct{})}
+	c := &Conn{t: bt}
+
+	// cancelling unblocks a pending read
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, _, err := c.ReadMessage(ctx); err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+
+	// deadline of the context is honored
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.ReadMessage(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, not %v", err)
+	}
+
+	// deadline is cleared afterwards
+	if !bt.deadline.IsZero() {
+		t.Errorf("deadline %s was not cleared", bt.deadline)
+	}
+
+	// done contexts don't even start
+	<-ctx.Done()
+	if err := c.WriteMessage(ctx, &ICMPRouterSolicitation{}, net.IPv6linklocalallrouters); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, not %v", err)
+	}
+	if len(bt.out) != 0 {
+		t.Errorf("unexpected written packets %v", bt.out)
+	}
+
+	err := c.WriteMessage(context.Background(), &ICMPRouterSolicitation{}, net.IPv6linklocalallrouters)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(bt.out) != 1 {
+		t.Errorf("unexpected written packets %v", bt.out)
+	}
+
+	// messages are still read without error
+	tt := &testTransport{in: [][]byte{{133, 0, 0, 0, 0, 0, 0, 0}}}
+	c = &Conn{t: tt}
+	m, _, err := c.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*ICMPRouterSolicitation); !ok {
+		t.Errorf("unexpected message %s", m)
+	}
+}
+
+func TestConnReadWrite(t *testing.T) {
+	tt := &testTransport{
+		in: [][]byte{
+			{135, 0, 0, 0, 0, 0, 0, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			{128, 0, 0, 0},
+		},
+		md: &Metadata{
+			Source:   net.ParseIP("fe80::2"),
+			IfIndex:  2,
+			HopLimit: 255,
+		},
+	}
+	c := &Conn{t: tt, addr: net.ParseIP("fe80::1")}
+
+	m, md, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+
+	if !ns.TargetAddress.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("unexpected target address %s", ns.TargetAddress)
+	}
+
+	if !md.Source.Equal(net.ParseIP("fe80::2")) || md.IfIndex != 2 || md.HopLimit != 255 {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	// unsupported messages still return metadata
+	if _, md, err = c.ReadFrom(); err == nil || md == nil {
+		t.Errorf("expected error with metadata for unsupported message")
+	}
+
+	if _, _, err = c.ReadFrom(); err == nil {
+		t.Errorf("expected transport error")
+	}
+
+	err = c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(tt.out) != 1 || bytes.Compare(tt.out[0], []byte{133, 0, 0, 0, 0, 0, 0, 0}) != 0 {
+		t.Errorf("unexpected written packets %v", tt.out)
+	}
+
+	if !tt.dst[0].Equal(net.IPv6linklocalallrouters) {
+		t.Errorf("unexpected destination %s", tt.dst[0])
+	}
+
+	if err = c.Close(); err != nil || !tt.closed {
+		t.Errorf("transport not closed")
+	}
+}
+
+func TestListen(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ifi *net.Interface
+	for i := range ifis {
+		if _, err := linkLocalAddr(&ifis[i]); err == nil {
+			ifi = &ifis[i]
+			break
+		}
+	}
+	if ifi == nil {
+		t.Skip("no interface with link-local address")
+	}
+
+	c, err := Listen(ifi, RoleRouter)
+	if err != nil {
+		t.Skipf("can't listen on %s: %s", ifi.Name, err)
+	}
+	defer c.Close()
+
+	if c.Role() != RoleRouter {
+		t.Errorf("unexpected role %s", c.Role())
+	}
+
+	if !c.Addr().IsLinkLocalUnicast() {
+		t.Errorf("unexpected address %s", c.Addr())
+	}
+
+	if c.Interface().Index != ifi.Index {
+		t.Errorf("unexpected interface %s", c.Interface().Name)
+	}
+
+	if err = c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Error(err)
+	}
+
+	n, err := c.WriteBatch([]OutgoingMessage{
+		{Message: &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}, Destination: net.ParseIP("ff02::1:ff00:2")},
+		{Message: &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::3")}, Destination: net.ParseIP("ff02::1:ff00:3")},
+	})
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 written messages, not %d (%v)", n, err)
+	}
+
+	if err = c.SetHopLimit(255); err != nil {
+		t.Error(err)
+	}
+	if err = c.SetMulticastHopLimit(255); err != nil {
+		t.Error(err)
+	}
+	if err = c.SetTrafficClass(0xc0); err != nil {
+		t.Error(err)
+	}
+
+	if err = c.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Error(err)
+	}
+
+	// nothing is expected to arrive, but reading should time out cleanly
+	if _, _, err = c.ReadFrom(); err != nil {
+		var nerr net.Error
+		if !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Logf("read returned %s", err)
+		}
+	}
+
+	if err = c.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Error(err)
+	}
+
+	rms := make([]ReceivedMessage, 8)
+	if _, err = c.ReadBatch(rms); err != nil {
+		var nerr net.Error
+		if !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Logf("batch read returned %s", err)
+		}
+	}
+}
+
+func TestRoleGroups(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ifi := range ifis {
+		host, err := roleGroups(&ifi, RoleHost)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router, err := roleGroups(&ifi, RoleRouter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !host[0].Equal(net.IPv6linklocalallnodes) {
+			t.Errorf("hosts should join all-nodes, not %s", host[0])
+		}
+
+		if len(router) != len(host)+1 || !router[1].Equal(net.IPv6linklocalallrouters) {
+			t.Errorf("routers should join all-routers as well: %v", router)
+		}
+
+		monitor, err := roleGroups(&ifi, RoleMonitor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(monitor) != len(router) {
+			t.Errorf("monitors should join the groups of routers: %v", monitor)
+		}
+
+		for _, g := range host[1:] {
+			if !g.IsLinkLocalMulticast() || g[11] != 1 || g[12] != 0xff {
+				t.Errorf("unexpected solicited-node group %s", g)
+			}
+		}
+	}
+}
+
+func TestRoleString(t *testing.T) {
+	if RoleHost.String() != "host" || RoleRouter.String() != "router" || RoleMonitor.String() != "monitor" || Role(100).String() != "<nil>" {
+		t.Errorf("unexpected role names")
+	}
+}
+
+func TestRoleTypes(t *testing.T) {
+	has := func(types []ipv6.ICMPType, typ ipv6.ICMPType) bool {
+		for _, t := range types {
+			if t == typ {
+				return true
+			}
+		}
+		return false
+	}
+
+	if has(RoleHost.Types(), ipv6.ICMPTypeRouterSolicitation) || !has(RoleHost.Types(), ipv6.ICMPTypeRouterAdvertisement) {
+		t.Errorf("hosts should accept RA but not RS: %v", RoleHost.Types())
+	}
+	if has(RoleRouter.Types(), ipv6.ICMPTypeRouterAdvertisement) || !has(RoleRouter.Types(), ipv6.ICMPTypeRouterSolicitation) {
+		t.Errorf("routers should accept RS but not RA: %v", RoleRouter.Types())
+	}
+	if has(RoleHost.Types(), ipv6.ICMPTypeCertificationPathSolicitation) || !has(RoleHost.Types(), ipv6.ICMPTypeCertificationPathAdvertisement) {
+		t.Errorf("hosts should accept CPA but not CPS: %v", RoleHost.Types())
+	}
+	if has(RoleRouter.Types(), ipv6.ICMPTypeCertificationPathAdvertisement) || !has(RoleRouter.Types(), ipv6.ICMPTypeCertificationPathSolicitation) {
+		t.Errorf("routers should accept CPS but not CPA: %v", RoleRouter.Types())
+	}
+	if has(RoleHost.Types(), ipv6.ICMPTypeDuplicateAddressRequest) || !has(RoleRouter.Types(), ipv6.ICMPTypeDuplicateAddressRequest) || !has(RoleRouter.Types(), ipv6.ICMPTypeDuplicateAddressConfirmation) {
+		t.Errorf("routers but not hosts should accept DAR and DAC: %v, %v", RoleHost.Types(), RoleRouter.Types())
+	}
+	if len(RoleMonitor.Types()) != 9 {
+		t.Errorf("monitors should accept all NDP messages: %v", RoleMonitor.Types())
+	}
+}
+
+func TestConnAllowedTypes(t *testing.T) {
+	rs := []byte{133, 0, 0, 0, 0, 0, 0, 0}
+	ra := []byte{134, 0, 0, 0, 64, 0, 7, 8, 0, 0, 0, 0, 0, 0, 0, 0}
+	md := &Metadata{HopLimit: 255}
+
+	c := &Conn{t: &testTransport{in: [][]byte{rs, rs, ra}, md: md}}
+	// the test transport has no kernel filter
+	if err := c.SetAllowedTypes(RoleHost.Types()...); err != errNoFilter {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	m, _, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*ICMPRouterAdvertisement); !ok {
+		t.Errorf("expected router solicitations to be dropped, got %s", m)
+	}
+
+	bt := &batchTestTransport{testTransport{in: [][]byte{ra, rs, ra, ra}, md: md}}
+	c = &Conn{t: bt}
+	c.SetAllowedTypes(RoleRouter.Types()...)
+
+	rms := make([]ReceivedMessage, 2)
+	n, err := c.ReadBatch(rms)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 message, not %d (%v)", n, err)
+	}
+	if _, ok := rms[0].Message.(*ICMPRouterSolicitation); !ok {
+		t.Errorf("expected router advertisements to be dropped, got %s", rms[0].Message)
+	}
+
+	// batches of only dropped messages are skipped
+	if _, err = c.ReadBatch(rms); err == nil {
+		t.Errorf("expected transport error")
+	}
+}
+
+// multicastTestTransport implements multicastTransport on top of
+// testTransport, so newConn can join the groups of a role
+type multicastTestTransport struct {
+	testTransport
+	groups []net.IP
+}
+
+func (t *multicastTestTransport) JoinGroup(group net.IP) error {
+	t.groups = append(t.groups, group)
+	return nil
+}
+
+func (t *multicastTestTransport) LeaveGroup(group net.IP) error {
+	return nil
+}
+
+func TestConnCertificationPath(t *testing.T) {
+	cpa, err := (&ICMPCertificationPathAdvertisement{Identifier: 42, AllComponents: 1}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cps, err := (&ICMPCertificationPathSolicitation{Identifier: 42, Component: 0xffff}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifi := &net.Interface{Index: 1000, Name: "test0", Flags: net.FlagUp | net.FlagMulticast}
+	md := &Metadata{Source: net.ParseIP("fe80::2"), HopLimit: 255}
+
+	// a Conn set up like Listen does reads the messages of its role
+	tt := &multicastTestTransport{testTransport: testTransport{in: [][]byte{cps, cpa}, md: md}}
+	c, err := newConn(tt, ifi, net.ParseIP("fe80::1"), RoleHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := m.(*ICMPCertificationPathAdvertisement); !ok || a.Identifier != 42 {
+		t.Errorf("expected hosts to read the certification path advertisement, got %s", m)
+	}
+
+	tt = &multicastTestTransport{testTransport: testTransport{in: [][]byte{cpa, cps}, md: md}}
+	if c, err = newConn(tt, ifi, net.ParseIP("fe80::1"), RoleRouter); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, err = c.ReadFrom(); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := m.(*ICMPCertificationPathSolicitation); !ok || s.Identifier != 42 {
+		t.Errorf("expected routers to read the certification path solicitation, got %s", m)
+	}
+
+	// and Serve hands them to the Mux
+	tt = &multicastTestTransport{testTransport: testTransport{in: [][]byte{cpa}, md: md}}
+	if c, err = newConn(tt, ifi, net.ParseIP("fe80::1"), RoleHost); err != nil {
+		t.Fatal(err)
+	}
+	var served int
+	mux := NewMux()
+	mux.HandleCertificationPathAdvertisement(func(m *ICMPCertificationPathAdvertisement, md *Metadata) { served++ })
+	c.Serve(context.Background(), mux)
+	if served != 1 {
+		t.Errorf("expected the certification path advertisement to be served, not %d", served)
+	}
+}
+
+func TestConnDuplicateAddress(t *testing.T) {
+	eui := net.HardwareAddr{0x02, 0, 0, 0, 0, 0, 0, 0x01}
+	dar, err := (&ICMPDuplicateAddressRequest{EUI64: eui, RegisteredAddress: net.ParseIP("2001:db8::1")}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dac, err := (&ICMPDuplicateAddressConfirmation{EUI64: eui, RegisteredAddress: net.ParseIP("2001:db8::1")}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a Conn set up like Listen does serves them to the Mux
+	ifi := &net.Interface{Index: 1000, Name: "test0", Flags: net.FlagUp | net.FlagMulticast}
+	md := &Metadata{Source: net.ParseIP("2001:db8::2"), HopLimit: 255}
+	tt := &multicastTestTransport{testTransport: testTransport{in: [][]byte{dar, dac}, md: md}}
+	c, err := newConn(tt, ifi, net.ParseIP("fe80::1"), RoleRouter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests, confirmations int
+	mux := NewMux()
+	mux.HandleDuplicateAddressRequest(func(m *ICMPDuplicateAddressRequest, md *Metadata) { requests++ })
+	mux.HandleDuplicateAddressConfirmation(func(m *ICMPDuplicateAddressConfirmation, md *Metadata) { confirmations++ })
+	c.Serve(context.Background(), mux)
+	if requests != 1 || confirmations != 1 {
+		t.Errorf("expected a request and a confirmation to be served, not %d and %d", requests, confirmations)
+	}
+}
+
+func TestJoinGroupUnsupported(t *testing.T) {
+	c := &Conn{t: &testTransport{}}
+	if err := c.JoinGroup(net.IPv6linklocalallnodes); err != errNoMulticast {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := c.LeaveGroup(net.IPv6linklocalallnodes); err != errNoMulticast {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// batchTestTransport implements batchTransport on top of testTransport
+type batchTestTransport struct {
+	testTransport
+}
+
+func (t *batchTestTransport) ReadBatch(bufs [][]byte, ns []int, mds []*Metadata) (int, error) {
+	if len(t.in) == 0 {
+		return 0, errors.New("no more packets")
+	}
+
+	var n int
+	for n < len(bufs) && len(t.in) > 0 {
+		ns[n] = copy(bufs[n], t.in[0])
+		mds[n] = t.md
+		t.in = t.in[1:]
+		n++
+	}
+
+	return n, nil
+}
+
+func (t *batchTestTransport) WriteBatch(bufs [][]byte, mds []*Metadata, dsts []net.IP) (int, error) {
+	t.out = append(t.out, bufs...)
+	t.outMD = append(t.outMD, mds...)
+	t.dst = append(t.dst, dsts...)
+	return len(bufs), nil
+}
+
+func TestConnWriteBatch(t *testing.T) {
+	oms := []OutgoingMessage{
+		{Message: &ICMPRouterAdvertisement{}, Destination: net.IPv6linklocalallnodes},
+		{Message: &ICMPRouterAdvertisement{}, Destination: net.ParseIP("fe80::2")},
+		{Message: &ICMPRouterAdvertisement{}, Destination: net.ParseIP("fe80::3"), Metadata: &Metadata{HopLimit: 64}},
+	}
+
+	bt, tt := &batchTestTransport{}, &testTransport{}
+	for _, out := range []*testTransport{&bt.testTransport, tt} {
+		c := &Conn{t: tt, addr: net.ParseIP("fe80::1")}
+		if out != tt {
+			c.t = bt
+		}
+
+		n, err := c.WriteBatch(oms)
+		if err != nil || n != 3 {
+			t.Fatalf("expected 3 written messages, not %d (%v)", n, err)
+		}
+
+		if len(out.out) != 3 || !out.dst[1].Equal(net.ParseIP("fe80::2")) {
+			t.Errorf("unexpected written packets %v to %v", out.out, out.dst)
+		}
+		if !out.outMD[2].Source.Equal(net.ParseIP("fe80::1")) || out.outMD[2].HopLimit != 64 {
+			t.Errorf("unexpected metadata %v", out.outMD[2])
+		}
+
+		// rate limited messages cut the batch short
+		c.SetRateLimiting(true)
+		n, err = c.WriteBatch(append(oms, oms[0]))
+		if err != ErrRateLimited || n != 3 {
+			t.Errorf("expected rate limiting after 3 messages, not %d (%v)", n, err)
+		}
+	}
+}
+
+func TestConnReadBatch(t *testing.T) {
+	in := [][]byte{
+		{133, 0, 0, 0, 0, 0, 0, 0},
+		{128, 0, 0, 0},
+		{133, 0, 0, 0, 0, 0, 0, 0},
+	}
+	md := &Metadata{Source: net.ParseIP("fe80::2"), HopLimit: 255}
+
+	bt := &batchTestTransport{testTransport{in: in, md: md}}
+	c := &Conn{t: bt}
+
+	rms := make([]ReceivedMessage, 2)
+	n, err := c.ReadBatch(rms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 messages, not %d", n)
+	}
+	if _, ok := rms[0].Message.(*ICMPRouterSolicitation); !ok || rms[0].Err != nil {
+		t.Errorf("unexpected message %v", rms[0])
+	}
+	// parse errors don't fail the batch
+	if rms[1].Err == nil || rms[1].Metadata != md {
+		t.Errorf("expected parse error with metadata, not %v", rms[1])
+	}
+
+	if n, err = c.ReadBatch(rms); err != nil || n != 1 {
+		t.Errorf("expected 1 message, not %d (%v)", n, err)
+	}
+
+	if _, err = c.ReadBatch(rms); err == nil {
+		t.Errorf("expected transport error")
+	}
+
+	// transports without batch support read a single message
+	c = &Conn{t: &testTransport{in: in, md: md}}
+	if n, err = c.ReadBatch(rms); err != nil || n != 1 {
+		t.Errorf("expected 1 message, not %d (%v)", n, err)
+	}
+	if n, err = c.ReadBatch(rms); err != nil || n != 1 || rms[0].Err == nil {
+		t.Errorf("expected parse error, not %d (%v)", n, err)
+	}
+	if n, err = c.ReadBatch(nil); err != nil || n != 0 {
+		t.Errorf("expected empty batch, not %d (%v)", n, err)
+	}
+}
+
+func TestConnSourceAddr(t *testing.T) {
+	tt := &testTransport{}
+	c := &Conn{t: tt, addr: net.ParseIP("fe80::1")}
+
+	if err := c.SetSourceAddr(net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSourceAddr(net.ParseIP("192.0.2.1")); err == nil {
+		t.Errorf("expected error for IPv4 source")
+	}
+
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Fatal(err)
+	}
+	md := &Metadata{Source: net.ParseIP("fe80::3"), HopLimit: 255}
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, md, net.IPv6linklocalallrouters); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, &Metadata{HopLimit: 64}, net.IPv6linklocalallrouters); err != nil {
+		t.Fatal(err)
+	}
+
+	if !tt.outMD[0].Source.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected source %s", tt.outMD[0].Source)
+	}
+	if !tt.outMD[1].Source.Equal(net.ParseIP("fe80::3")) {
+		t.Errorf("source in metadata was overridden by %s", tt.outMD[1].Source)
+	}
+	if !tt.outMD[2].Source.Equal(net.ParseIP("fe80::2")) || tt.outMD[2].HopLimit != 64 {
+		t.Errorf("unexpected metadata %v", tt.outMD[2])
+	}
+}
+
+func TestSocketOptionsUnsupported(t *testing.T) {
+	c := &Conn{t: &testTransport{}}
+	if err := c.SetHopLimit(255); err != errNoSocketOptions {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := c.SetMulticastHopLimit(255); err != errNoSocketOptions {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := c.SetTrafficClass(0); err != errNoSocketOptions {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestPromiscuousUnsupported(t *testing.T) {
+	c := &Conn{t: &testTransport{}}
+	if err := c.SetPromiscuous(true); err != errNoPromiscuous {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestBindToDeviceUnsupported(t *testing.T) {
+	c := &Conn{t: &testTransport{}}
+	if err := c.BindToDevice("eth0"); err != errNoBindToDevice {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestLinkLocalAddr(t *testing.T) {
+	lo := &net.Interface{Index: 999999, Name: "doesnotexist"}
+	if _, err := linkLocalAddr(lo); err == nil {
+		t.Errorf("expected error for non-existing interface")
+	}
+}
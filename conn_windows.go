@@ -0,0 +1,38 @@
+//go:build windows
+
+package ndp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv6"
+)
+
+// hopLimitKnown tells that raw sockets don't report the hop limit of
+// received messages on Windows
+const hopLimitKnown = false
+
+// listenTransport returns a raw socket transport or, when raw sockets aren't
+// available, falls back to WinDivert
+func listenTransport(ifi *net.Interface, addr net.IP) (transport, error) {
+	t, err := listenRaw(ifi, addr)
+	if err == nil {
+		return t, nil
+	}
+
+	d, derr := listenWinDivert(ifi, addr)
+	if derr != nil {
+		return nil, fmt.Errorf("%s, fallback failed: %s", err, derr)
+	}
+
+	return d, nil
+}
+
+// enableControlMessages is a no-op on Windows, where x/net can't receive
+// ancillary data on raw sockets. Messages read from winsock raw ICMPv6
+// sockets carry their source address only, so Metadata lacks hop limit and
+// destination, and Metadata on outgoing messages is ignored
+func enableControlMessages(pc *ipv6.PacketConn) error {
+	return nil
+}
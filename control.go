@@ -0,0 +1,449 @@
+package ndp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errNoControlRAService = errors.New("no ra service")
+)
+
+// defaultMaxControlEvents is the number of recent events ControlServer keeps
+// by default
+const defaultMaxControlEvents = 256
+
+// the commands ControlServer answers
+const (
+	ControlNeighbors = "neighbors"
+	ControlRAConfig  = "ra-config"
+	ControlEvents    = "events"
+)
+
+// ControlRequest is a request to ControlServer, sent as a line of JSON
+type ControlRequest struct {
+	// Command is one of ControlNeighbors, ControlRAConfig or ControlEvents
+	Command string `json:"command"`
+	// Interface limits the neighbors and configuration returned to those of
+	// one interface, when set
+	Interface string `json:"interface,omitempty"`
+	// Limit limits the events returned to the latest ones, when set
+	Limit int `json:"limit,omitempty"`
+}
+
+// ControlResponse is the answer of ControlServer to a ControlRequest, sent
+// as a line of JSON. Result holds what was asked for unless Error is set
+type ControlResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ControlNeighbor is a neighbor cache entry as ControlServer returns it
+type ControlNeighbor struct {
+	Interface        string `json:"interface"`
+	Address          string `json:"address"`
+	LinkLayerAddress string `json:"link_layer_address,omitempty"`
+	State            string `json:"state"`
+	IsRouter         bool   `json:"is_router"`
+}
+
+// ControlEvent is a neighbor cache or monitor event as ControlServer keeps
+// it
+type ControlEvent struct {
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface"`
+	// Source is neighbor for neighbor cache events and monitor for monitor
+	// events
+	Source           string `json:"source"`
+	Type             string `json:"type"`
+	Address          string `json:"address,omitempty"`
+	LinkLayerAddress string `json:"link_layer_address,omitempty"`
+	Description      string `json:"description"`
+}
+
+// ControlServer answers requests for the entries of NeighborCaches, the
+// configuration of an RAService and recent events of NeighborCaches and
+// Monitors on a local socket, like birdc does for bird. Requests and
+// responses are lines of JSON, see ControlRequest and ControlResponse
+type ControlServer struct {
+	// RAService, when set, is the service of which ControlRAConfig returns
+	// the configuration
+	RAService *RAService
+	// MaxEvents is the number of recent events kept. It defaults to 256
+	MaxEvents int
+
+	mu     sync.Mutex
+	caches map[string]*NeighborCache
+	events []ControlEvent
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewControlServer returns a ControlServer without any subsystems
+// instrumented
+func NewControlServer() *ControlServer {
+	return &ControlServer{
+		MaxEvents: defaultMaxControlEvents,
+		caches:    make(map[string]*NeighborCache),
+		now:       time.Now,
+	}
+}
+
+// InstrumentNeighborCache has ControlNeighbors include the entries of nc, the
+// neighbor cache of interface ifname, and keeps its events, keeping its
+// Events callback. It must be called before nc serves
+func (s *ControlServer) InstrumentNeighborCache(ifname string, nc *NeighborCache) {
+	events := nc.Events
+	nc.Events = func(ev NeighborEvent) {
+		s.record(ControlEvent{
+			Interface:        ifname,
+			Source:           "neighbor",
+			Type:             ev.Type.String(),
+			Address:          ev.Neighbor.Address.String(),
+			LinkLayerAddress: controlHardwareAddr(ev.Neighbor.LinkLayerAddress),
+			Description:      fmt.Sprintf("neighbor %s %s", ev.Neighbor.Address, ev.Type),
+		})
+		if events != nil {
+			events(ev)
+		}
+	}
+
+	s.mu.Lock()
+	s.caches[ifname] = nc
+	s.mu.Unlock()
+}
+
+// InstrumentMonitor keeps the events of mon, the monitor of interface
+// ifname, keeping its Events callback. It must be called before mon serves
+func (s *ControlServer) InstrumentMonitor(ifname string, mon *Monitor) {
+	events := mon.Events
+	mon.Events = func(ev MonitorEvent) {
+		ce := ControlEvent{
+			Interface:   ifname,
+			Source:      "monitor",
+			Type:        ev.Type.String(),
+			Description: ev.String(),
+		}
+		switch {
+		case ev.Router != nil:
+			ce.Address = ev.Router.Address.String()
+			ce.LinkLayerAddress = controlHardwareAddr(ev.Router.LinkLayerAddress)
+		case ev.DAD != nil:
+			ce.Address = ev.DAD.Address.String()
+			ce.LinkLayerAddress = controlHardwareAddr(ev.DAD.LinkLayerAddress)
+		}
+		s.record(ce)
+		if events != nil {
+			events(ev)
+		}
+	}
+}
+
+// record keeps ev, forgetting the oldest event if MaxEvents are kept
+func (s *ControlServer) record(ev ControlEvent) {
+	ev.Time = s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+	if max := s.MaxEvents; max > 0 && len(s.events) > max {
+		s.events = append(s.events[:0], s.events[len(s.events)-max:]...)
+	}
+}
+
+// ListenAndServe answers requests on the unix socket at path until ctx is
+// done, replacing a socket left behind at path. The socket is only
+// accessible to its owner and group
+func (s *ControlServer) ListenAndServe(ctx context.Context, path string) error {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0o660); err != nil {
+		l.Close()
+		return err
+	}
+
+	return s.Serve(ctx, l)
+}
+
+// Serve answers requests on the connections accepted from l until ctx is
+// done or accepting fails. It closes l
+func (s *ControlServer) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	// stop the connections still open before waiting for them
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers the requests read from conn until it is closed or ctx
+// is done
+func (s *ControlServer) serveConn(ctx context.Context, conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	sc := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		var req ControlRequest
+		resp := ControlResponse{}
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %s", err)
+		} else if result, err := s.answer(req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result = result
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// answer returns the result of req as JSON
+func (s *ControlServer) answer(req ControlRequest) (json.RawMessage, error) {
+	var result interface{}
+	switch req.Command {
+	case ControlNeighbors:
+		neighbors, err := s.neighbors(req.Interface)
+		if err != nil {
+			return nil, err
+		}
+		result = neighbors
+	case ControlRAConfig:
+		cfgs, err := s.raConfigs(req.Interface)
+		if err != nil {
+			return nil, err
+		}
+		result = cfgs
+	case ControlEvents:
+		result = s.recentEvents(req.Limit)
+	default:
+		return nil, fmt.Errorf("unknown command %q, not one of %s, %s or %s", req.Command, ControlNeighbors, ControlRAConfig, ControlEvents)
+	}
+
+	return json.Marshal(result)
+}
+
+// neighbors returns the entries of the neighbor cache of ifname, or of all
+// of them if ifname is empty
+func (s *ControlServer) neighbors(ifname string) ([]ControlNeighbor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.caches))
+	for name := range s.caches {
+		if ifname == "" || name == ifname {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && ifname != "" {
+		return nil, fmt.Errorf("no neighbor cache on %s", ifname)
+	}
+	sort.Strings(names)
+
+	neighbors := []ControlNeighbor{}
+	for _, name := range names {
+		for _, n := range s.caches[name].Neighbors() {
+			neighbors = append(neighbors, ControlNeighbor{
+				Interface:        name,
+				Address:          n.Address.String(),
+				LinkLayerAddress: controlHardwareAddr(n.LinkLayerAddress),
+				State:            n.State.String(),
+				IsRouter:         n.IsRouter,
+			})
+		}
+	}
+
+	return neighbors, nil
+}
+
+// raConfigs returns the configuration RAService advertises on ifname, or on
+// all interfaces if ifname is empty, keyed by interface name
+func (s *ControlServer) raConfigs(ifname string) (map[string]controlRAConfig, error) {
+	if s.RAService == nil {
+		return nil, errNoControlRAService
+	}
+
+	cfgs := make(map[string]controlRAConfig)
+	for name, cfg := range s.RAService.Configs() {
+		if ifname == "" || name == ifname {
+			cfgs[name] = newControlRAConfig(cfg)
+		}
+	}
+	if len(cfgs) == 0 && ifname != "" {
+		return nil, fmt.Errorf("not advertising on %s", ifname)
+	}
+
+	return cfgs, nil
+}
+
+// recentEvents returns the latest limit events kept, or all of them if
+// limit is 0
+func (s *ControlServer) recentEvents(limit int) []ControlEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.events
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	return append([]ControlEvent{}, events...)
+}
+
+// QueryControl sends req to the ControlServer listening on the unix socket
+// at path and returns its result
+func QueryControl(ctx context.Context, path string, req ControlRequest) (json.RawMessage, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp ControlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return resp.Result, nil
+}
+
+// controlHardwareAddr returns lla as a string, empty if there is none
+func controlHardwareAddr(lla net.HardwareAddr) string {
+	if lla == nil {
+		return ""
+	}
+
+	return lla.String()
+}
+
+// controlDuration is a time.Duration marshaled as a string like 10m0s
+type controlDuration time.Duration
+
+func (d controlDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// controlPrefix is an RAPrefix as ControlServer returns it
+type controlPrefix struct {
+	Prefix            string          `json:"prefix"`
+	OnLink            bool            `json:"on_link"`
+	Autonomous        bool            `json:"autonomous"`
+	ValidLifetime     controlDuration `json:"valid_lifetime"`
+	PreferredLifetime controlDuration `json:"preferred_lifetime"`
+}
+
+// controlRAConfig is an RAConfig as ControlServer returns it
+type controlRAConfig struct {
+	MinInterval      controlDuration `json:"min_interval"`
+	MaxInterval      controlDuration `json:"max_interval"`
+	Managed          bool            `json:"managed"`
+	Other            bool            `json:"other"`
+	HopLimit         uint8           `json:"hop_limit"`
+	RouterLifetime   controlDuration `json:"router_lifetime"`
+	Preference       string          `json:"preference"`
+	ReachableTime    controlDuration `json:"reachable_time"`
+	RetransTimer     controlDuration `json:"retrans_timer"`
+	MTU              uint32          `json:"mtu,omitempty"`
+	UnicastSolicited bool            `json:"unicast_solicited"`
+	Prefixes         []controlPrefix `json:"prefixes,omitempty"`
+	RDNSS            []string        `json:"rdnss,omitempty"`
+	RDNSSLifetime    controlDuration `json:"rdnss_lifetime"`
+	DNSSL            []string        `json:"dnssl,omitempty"`
+	DNSSLLifetime    controlDuration `json:"dnssl_lifetime"`
+	PREF64           string          `json:"pref64,omitempty"`
+	PREF64Lifetime   controlDuration `json:"pref64_lifetime"`
+}
+
+func newControlRAConfig(cfg RAConfig) controlRAConfig {
+	c := controlRAConfig{
+		MinInterval:      controlDuration(cfg.MinInterval),
+		MaxInterval:      controlDuration(cfg.MaxInterval),
+		Managed:          cfg.Managed,
+		Other:            cfg.Other,
+		HopLimit:         cfg.HopLimit,
+		RouterLifetime:   controlDuration(cfg.RouterLifetime),
+		Preference:       cfg.Preference.String(),
+		ReachableTime:    controlDuration(cfg.ReachableTime),
+		RetransTimer:     controlDuration(cfg.RetransTimer),
+		MTU:              cfg.MTU,
+		UnicastSolicited: cfg.UnicastSolicited,
+		RDNSSLifetime:    controlDuration(cfg.RDNSSLifetime),
+		DNSSL:            cfg.DNSSL,
+		DNSSLLifetime:    controlDuration(cfg.DNSSLLifetime),
+		PREF64Lifetime:   controlDuration(cfg.PREF64Lifetime),
+	}
+	for _, p := range cfg.Prefixes {
+		c.Prefixes = append(c.Prefixes, controlPrefix{
+			Prefix:            p.Prefix.String(),
+			OnLink:            p.OnLink,
+			Autonomous:        p.Autonomous,
+			ValidLifetime:     controlDuration(p.ValidLifetime),
+			PreferredLifetime: controlDuration(p.PreferredLifetime),
+		})
+	}
+	for _, ip := range cfg.RDNSS {
+		c.RDNSS = append(c.RDNSS, ip.String())
+	}
+	if cfg.PREF64 != nil {
+		c.PREF64 = cfg.PREF64.String()
+	}
+
+	return c
+}
@@ -0,0 +1,173 @@
+package ndp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveControl serves s on a unix socket, returning its path
+func serveControl(t *testing.T, s *ControlServer) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ndp.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServe(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return path
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the control socket")
+		}
+	}
+}
+
+func TestControlNeighbors(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+	nc := NewNeighborCache(a)
+	s := NewControlServer()
+	s.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	s.InstrumentNeighborCache("eth0", nc)
+	nc.Seed(Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: b.Interface().HardwareAddr, IsRouter: true})
+	path := serveControl(t, s)
+	ctx := context.Background()
+
+	result, err := QueryControl(ctx, path, ControlRequest{Command: ControlNeighbors})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lla := b.Interface().HardwareAddr.String()
+	if expected := `[{"interface":"eth0","address":"fe80::2","link_layer_address":"` + lla + `","state":"stale","is_router":true}]`; string(result) != expected {
+		t.Errorf("expected %s, not %s", expected, result)
+	}
+	if _, err := QueryControl(ctx, path, ControlRequest{Command: ControlNeighbors, Interface: "eth1"}); err == nil || err.Error() != "no neighbor cache on eth1" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	result, err = QueryControl(ctx, path, ControlRequest{Command: ControlEvents})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []ControlEvent
+	if err := json.Unmarshal(result, &events); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ControlEvent{{
+		Time:             s.now(),
+		Interface:        "eth0",
+		Source:           "neighbor",
+		Type:             "added",
+		Address:          "fe80::2",
+		LinkLayerAddress: lla,
+		Description:      "neighbor fe80::2 added",
+	}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %+v, not %+v", expected, events)
+	}
+}
+
+func TestControlEvents(t *testing.T) {
+	mon := &Monitor{}
+	s := NewControlServer()
+	s.MaxEvents = 2
+	s.InstrumentMonitor("eth0", mon)
+	for _, ip := range []string{"fe80::1", "fe80::2", "fe80::3"} {
+		mon.Events(MonitorEvent{Type: MonitorRouterNew, Router: &MonitorRouter{Address: net.ParseIP(ip)}})
+	}
+
+	// only the latest events are kept
+	events := s.recentEvents(0)
+	if len(events) != 2 || events[0].Address != "fe80::2" || events[1].Address != "fe80::3" || events[1].Source != "monitor" || events[1].Type != "new router" {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if events := s.recentEvents(1); len(events) != 1 || events[0].Address != "fe80::3" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestControlRAConfig(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	cfg := DefaultRAConfig()
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(prefix)}
+	cfg.RDNSS = []net.IP{net.ParseIP("2001:db8::53")}
+	rs, err := NewRAService(map[string]RAConfig{"eth0": cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewControlServer()
+	path := serveControl(t, s)
+	ctx := context.Background()
+
+	if _, err := QueryControl(ctx, path, ControlRequest{Command: ControlRAConfig}); err == nil || err.Error() != errNoControlRAService.Error() {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	s.RAService = rs
+	result, err := QueryControl(ctx, path, ControlRequest{Command: ControlRAConfig, Interface: "eth0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfgs map[string]map[string]interface{}
+	if err := json.Unmarshal(result, &cfgs); err != nil {
+		t.Fatal(err)
+	}
+	got := cfgs["eth0"]
+	if got["max_interval"] != "10m0s" || got["preference"] != "medium" || got["hop_limit"] != float64(64) {
+		t.Errorf("unexpected config %v", got)
+	}
+	if prefixes := got["prefixes"].([]interface{}); len(prefixes) != 1 || prefixes[0].(map[string]interface{})["prefix"] != "2001:db8::/64" {
+		t.Errorf("unexpected prefixes %v", got["prefixes"])
+	}
+	if _, err := QueryControl(ctx, path, ControlRequest{Command: ControlRAConfig, Interface: "eth1"}); err == nil {
+		t.Error("expected eth1 to be unknown")
+	}
+}
+
+func TestControlInvalid(t *testing.T) {
+	path := serveControl(t, NewControlServer())
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// a connection answers request after request, including invalid ones
+	r := bufio.NewReader(conn)
+	for _, test := range []struct{ req, err string }{
+		{"nope", "invalid request"},
+		{`{"command":"reboot"}`, `unknown command "reboot"`},
+		{`{"command":"events"}`, ""},
+	} {
+		if _, err := conn.Write([]byte(test.req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp ControlResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(resp.Error, test.err) || (test.err == "" && string(resp.Result) != "[]") {
+			t.Errorf("%s: unexpected response %s", test.req, line)
+		}
+	}
+}
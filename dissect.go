@@ -0,0 +1,321 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/ipv6"
+)
+
+// dissectRowBytes is the number of bytes a line of Dissect shows
+const dissectRowBytes = 16
+
+// Dissect returns a breakdown of ICMPv6 message b field by field, like
+// Wireshark shows it, for debugging messages other stacks send or reject.
+// Every line shows the offset and raw bytes of a field together with its
+// name and value. Options are broken down field by field for the common
+// ones and shown as package ndp describes them otherwise. Dissect shows as
+// much of malformed messages as it can and ends with why they fail to
+// parse
+func Dissect(b []byte) string {
+	d := &dissector{b: b}
+	d.message()
+
+	if _, err := ParseMessage(b); err != nil {
+		fmt.Fprintf(&d.sb, "[malformed: %s]\n", err)
+	}
+
+	return d.sb.String()
+}
+
+// dissector builds the output of Dissect
+type dissector struct {
+	b     []byte
+	sb    strings.Builder
+	depth int
+}
+
+// field writes the n bytes at off as a field called name with value, or
+// notes they're missing and returns false if b is too short
+func (d *dissector) field(off, n int, name, value string) bool {
+	if off+n > len(d.b) {
+		d.line(off, nil, fmt.Sprintf("[truncated: %s needs %d bytes, %d left]", name, n, len(d.b)-off))
+		return false
+	}
+
+	raw := d.b[off : off+n]
+	d.line(off, raw, fmt.Sprintf("%s: %s", name, value))
+
+	return true
+}
+
+// line writes text for the raw bytes at off, continuing on more lines when
+// raw doesn't fit on one
+func (d *dissector) line(off int, raw []byte, text string) {
+	for first := true; first || len(raw) > 0; first = false {
+		row := raw
+		if len(row) > dissectRowBytes {
+			row = row[:dissectRowBytes]
+		}
+		raw = raw[len(row):]
+
+		hex := make([]string, len(row))
+		for i, c := range row {
+			hex[i] = fmt.Sprintf("%02x", c)
+		}
+		if first {
+			fmt.Fprintf(&d.sb, "%04x  %-*s  %s%s\n", off, dissectRowBytes*3-1, strings.Join(hex, " "), strings.Repeat("  ", d.depth), text)
+		} else {
+			fmt.Fprintf(&d.sb, "%04x  %s\n", off, strings.Join(hex, " "))
+		}
+		off += len(row)
+	}
+}
+
+// uint8At, uint16At and uint32At return the integer at off, 0 if b is too
+// short, which field then reports
+func (d *dissector) uint8At(off int) uint8 {
+	if off+1 > len(d.b) {
+		return 0
+	}
+
+	return d.b[off]
+}
+
+func (d *dissector) uint16At(off int) uint16 {
+	if off+2 > len(d.b) {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(d.b[off:])
+}
+
+func (d *dissector) uint32At(off int) uint32 {
+	if off+4 > len(d.b) {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(d.b[off:])
+}
+
+// ipAt returns the address at off, nil if b is too short
+func (d *dissector) ipAt(off int) net.IP {
+	if off+net.IPv6len > len(d.b) {
+		return nil
+	}
+
+	return net.IP(d.b[off : off+net.IPv6len])
+}
+
+// message writes the header fields and options of the message
+func (d *dissector) message() {
+	typ := ipv6.ICMPType(d.uint8At(0))
+	if !d.field(0, 1, "Type", fmt.Sprintf("%s (%d)", typ, uint8(typ))) ||
+		!d.field(1, 1, "Code", fmt.Sprint(d.uint8At(1))) ||
+		!d.field(2, 2, "Checksum", fmt.Sprintf("0x%04x", d.uint16At(2))) {
+		return
+	}
+
+	var options int
+	switch typ {
+	case ipv6.ICMPTypeRouterSolicitation:
+		if !d.field(4, 4, "Reserved", fmt.Sprint(d.uint32At(4))) {
+			return
+		}
+		options = 8
+	case ipv6.ICMPTypeRouterAdvertisement:
+		flags := d.uint8At(5)
+		pref := RouterPreferenceField((flags >> 3) & 0x3)
+		ok := d.field(4, 1, "Cur hop limit", fmt.Sprint(d.uint8At(4))) &&
+			d.field(5, 1, "Flags", fmt.Sprintf("0x%02x (managed %s, other %s, home agent %s, preference %s)",
+				flags, dissectFlag(flags&0x80), dissectFlag(flags&0x40), dissectFlag(flags&0x20), pref)) &&
+			d.field(6, 2, "Router lifetime", fmt.Sprintf("%ds", d.uint16At(6))) &&
+			d.field(8, 4, "Reachable time", fmt.Sprintf("%dms", d.uint32At(8))) &&
+			d.field(12, 4, "Retrans timer", fmt.Sprintf("%dms", d.uint32At(12)))
+		if !ok {
+			return
+		}
+		options = 16
+	case ipv6.ICMPTypeNeighborSolicitation:
+		if !d.field(4, 4, "Reserved", fmt.Sprint(d.uint32At(4))) ||
+			!d.field(8, 16, "Target address", fmt.Sprint(d.ipAt(8))) {
+			return
+		}
+		options = 24
+	case ipv6.ICMPTypeNeighborAdvertisement:
+		flags := d.uint8At(4)
+		if !d.field(4, 4, "Flags", fmt.Sprintf("0x%08x (router %s, solicited %s, override %s)",
+			d.uint32At(4), dissectFlag(flags&0x80), dissectFlag(flags&0x40), dissectFlag(flags&0x20))) ||
+			!d.field(8, 16, "Target address", fmt.Sprint(d.ipAt(8))) {
+			return
+		}
+		options = 24
+	case ipv6.ICMPTypeRedirect:
+		if !d.field(4, 4, "Reserved", fmt.Sprint(d.uint32At(4))) ||
+			!d.field(8, 16, "Target address", fmt.Sprint(d.ipAt(8))) ||
+			!d.field(24, 16, "Destination address", fmt.Sprint(d.ipAt(24))) {
+			return
+		}
+		options = 40
+	case ipv6.ICMPTypeCertificationPathSolicitation:
+		if !d.field(4, 2, "Identifier", fmt.Sprint(d.uint16At(4))) ||
+			!d.field(6, 2, "Component", fmt.Sprint(d.uint16At(6))) {
+			return
+		}
+		options = 8
+	case ipv6.ICMPTypeCertificationPathAdvertisement:
+		if !d.field(4, 2, "Identifier", fmt.Sprint(d.uint16At(4))) ||
+			!d.field(6, 2, "All components", fmt.Sprint(d.uint16At(6))) ||
+			!d.field(8, 2, "Component", fmt.Sprint(d.uint16At(8))) ||
+			!d.field(10, 2, "Reserved", fmt.Sprint(d.uint16At(10))) {
+			return
+		}
+		options = 12
+	case ipv6.ICMPTypeDuplicateAddressRequest, ipv6.ICMPTypeDuplicateAddressConfirmation:
+		var eui64 net.HardwareAddr
+		if len(d.b) >= 16 {
+			eui64 = d.b[8:16]
+		}
+		if !d.field(4, 1, "Status", AddressRegistrationStatus(d.uint8At(4)).String()) ||
+			!d.field(5, 1, "Reserved", fmt.Sprint(d.uint8At(5))) ||
+			!d.field(6, 2, "Registration lifetime", fmt.Sprintf("%d minutes", d.uint16At(6))) ||
+			!d.field(8, 8, "EUI-64", eui64.String()) ||
+			!d.field(16, 16, "Registered address", fmt.Sprint(d.ipAt(16))) {
+			return
+		}
+		options = 32
+	default:
+		if len(d.b) > 4 {
+			d.field(4, len(d.b)-4, "Body", fmt.Sprintf("%d bytes", len(d.b)-4))
+		}
+		return
+	}
+
+	for off := options; off < len(d.b); {
+		// like ParseMessage, ignore what is too short to be an option
+		if len(d.b)-off < 8 {
+			d.field(off, len(d.b)-off, "Trailing bytes", fmt.Sprintf("%d, ignored", len(d.b)-off))
+			return
+		}
+		n, ok := d.option(off)
+		if !ok {
+			return
+		}
+		off += n
+	}
+}
+
+// option writes the option at off, returning its length in bytes and false
+// if it can't tell where the next one starts
+func (d *dissector) option(off int) (int, bool) {
+	typ := ICMPOptionType(d.uint8At(off))
+	d.line(off, nil, fmt.Sprintf("Option: %s (%d)", typ, uint8(typ)))
+	d.depth++
+	defer func() { d.depth-- }()
+
+	l := int(d.uint8At(off+1)) * 8
+	if !d.field(off, 1, "Type", fmt.Sprintf("%s (%d)", typ, uint8(typ))) ||
+		!d.field(off+1, 1, "Length", fmt.Sprintf("%d (%d bytes)", l/8, l)) {
+		return 0, false
+	}
+	if l == 0 {
+		d.line(off+1, nil, "[invalid length 0]")
+		return 0, false
+	}
+	if off+l > len(d.b) {
+		d.line(off+2, nil, fmt.Sprintf("[truncated: option needs %d bytes, %d left]", l, len(d.b)-off))
+		return 0, false
+	}
+
+	body := off + 2
+	switch typ {
+	case ICMPOptionTypeSourceLinkLayerAddress, ICMPOptionTypeTargetLinkLayerAddress:
+		d.field(body, l-2, "Link-layer address", net.HardwareAddr(d.b[body:off+l]).String())
+		return l, true
+	case ICMPOptionTypePrefixInformation:
+		if l != 32 {
+			break
+		}
+		flags := d.uint8At(body + 1)
+		d.field(body, 1, "Prefix length", fmt.Sprint(d.uint8At(body)))
+		d.field(body+1, 1, "Flags", fmt.Sprintf("0x%02x (on-link %s, autonomous %s, router address %s)",
+			flags, dissectFlag(flags&0x80), dissectFlag(flags&0x40), dissectFlag(flags&0x20)))
+		d.field(body+2, 4, "Valid lifetime", dissectLifetime(d.uint32At(body+2)))
+		d.field(body+6, 4, "Preferred lifetime", dissectLifetime(d.uint32At(body+6)))
+		d.field(body+10, 4, "Reserved", fmt.Sprint(d.uint32At(body+10)))
+		d.field(body+14, 16, "Prefix", d.ipAt(body+14).String())
+		return l, true
+	case ICMPOptionTypeMTU:
+		if l != 8 {
+			break
+		}
+		d.field(body, 2, "Reserved", fmt.Sprint(d.uint16At(body)))
+		d.field(body+2, 4, "MTU", fmt.Sprint(d.uint32At(body+2)))
+		return l, true
+	case ICMPOptionTypeRecursiveDNSServer:
+		if l < 24 || (l-8)%16 != 0 {
+			break
+		}
+		d.field(body, 2, "Reserved", fmt.Sprint(d.uint16At(body)))
+		d.field(body+2, 4, "Lifetime", dissectLifetime(d.uint32At(body+2)))
+		for i := body + 6; i < off+l; i += 16 {
+			d.field(i, 16, "Server", d.ipAt(i).String())
+		}
+		return l, true
+	case ICMPOptionTypeDNSSearchList:
+		if l < 16 {
+			break
+		}
+		d.field(body, 2, "Reserved", fmt.Sprint(d.uint16At(body)))
+		d.field(body+2, 4, "Lifetime", dissectLifetime(d.uint32At(body+2)))
+		value := "[malformed]"
+		if options, err := parseOptions(d.b[off : off+l]); err == nil {
+			value = strings.Join(options[0].(*ICMPOptionDNSSearchList).DomainNames, ", ")
+		}
+		d.field(body+6, l-8, "Domain names", value)
+		return l, true
+	case ICMPOptionTypeNonce:
+		d.field(body, l-2, "Nonce", fmt.Sprintf("0x%x", d.b[body:off+l]))
+		return l, true
+	}
+
+	// describe the rest through the option itself
+	d.field(body, l-2, "Body", dissectOptionValue(d.b[off:off+l]))
+
+	return l, true
+}
+
+// dissectOptionValue returns what option b tells as package ndp describes
+// it, after the type and length String starts with
+func dissectOptionValue(b []byte) string {
+	options, err := parseOptions(b)
+	if err != nil {
+		return fmt.Sprintf("[malformed: %s]", err)
+	}
+
+	s := options[0].String()
+	if i := strings.Index(s, "): "); i >= 0 {
+		return s[i+3:]
+	}
+
+	return s
+}
+
+func dissectFlag(b uint8) string {
+	if b != 0 {
+		return "set"
+	}
+
+	return "not set"
+}
+
+// dissectLifetime returns lifetime l in seconds
+func dissectLifetime(l uint32) string {
+	if l == 0xffffffff {
+		return "infinity"
+	}
+
+	return fmt.Sprintf("%ds", l)
+}
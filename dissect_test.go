@@ -0,0 +1,110 @@
+package ndp
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDissect(t *testing.T) {
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x01, 0x01}})
+	b, err := ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `0000  87                                               Type: neighbor solicitation (135)
+0001  00                                               Code: 0
+0002  00 00                                            Checksum: 0x0000
+0004  00 00 00 00                                      Reserved: 0
+0008  fe 80 00 00 00 00 00 00 00 00 00 00 00 00 00 01  Target address: fe80::1
+0018                                                   Option: source link-layer address (1)
+0018  01                                                 Type: source link-layer address (1)
+0019  01                                                 Length: 1 (8 bytes)
+001a  00 00 5e 00 01 01                                  Link-layer address: 00:00:5e:00:01:01
+`
+	if s := Dissect(b); s != expected {
+		t.Errorf("unexpected dissection:\n%s\nexpected:\n%s", s, expected)
+	}
+}
+
+func TestDissectOptions(t *testing.T) {
+	ra := &ICMPRouterAdvertisement{HopLimit: 64, ManagedAddress: true, RouterLifeTime: 1800}
+	ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	ra.AddOption(&ICMPOptionPrefixInformation{
+		PrefixLength:      64,
+		OnLink:            true,
+		Auto:              true,
+		ValidLifetime:     0xffffffff,
+		PreferredLifetime: 14400,
+		Prefix:            net.ParseIP("2001:db8:1::"),
+	})
+	ra.AddOption(&ICMPOptionRecursiveDNSServer{Lifetime: 1200, Servers: []net.IP{net.ParseIP("2001:db8::53")}})
+	ra.AddOption(&ICMPOptionDNSSearchList{Lifetime: 1200, DomainNames: []string{"example.com"}})
+	ra.AddOption(&ICMPOptionRouteInformation{PrefixLength: 48, RouteLifetime: 60, Prefix: net.ParseIP("2001:db8:100::")})
+	b, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Dissect(b)
+	for _, line := range []string{
+		"0005  80                                               Flags: 0x80 (managed set, other not set, home agent not set, preference medium)",
+		"0006  07 08                                            Router lifetime: 1800s",
+		"0014  00 00 05 dc                                        MTU: 1500",
+		"  Valid lifetime: infinity",
+		"  Preferred lifetime: 14400s",
+		"  Prefix: 2001:db8:1::",
+		"  Server: 2001:db8::53",
+		"  Domain names: example.com",
+		"Option: route info (24)",
+		"  Body: 2001:db8:100::/48, pref medium, lifetime 60s",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("expected %q in dissection:\n%s", line, s)
+		}
+	}
+	if strings.Contains(s, "[malformed") || strings.Contains(s, "[truncated") {
+		t.Errorf("unexpected problem in dissection:\n%s", s)
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasSuffix(line, " ") {
+			t.Errorf("unexpected trailing space in %q", line)
+		}
+	}
+}
+
+func TestDissectMalformed(t *testing.T) {
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x01, 0x01}})
+	b, err := ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zeroLength := append([]byte{}, b...)
+	zeroLength[25] = 0
+
+	tests := []struct {
+		name     string
+		b        []byte
+		expected []string
+	}{
+		{"empty", nil, []string{"[truncated: Type needs 1 bytes, 0 left]", "[malformed: "}},
+		{"short header", b[:20], []string{"0008                                                   [truncated: Target address needs 16 bytes, 12 left]", "[malformed: "}},
+		{"trailing bytes", b[:30], []string{"0018  01 01 00 00 5e 00                                Trailing bytes: 6, ignored"}},
+		{"zero length option", zeroLength, []string{"[invalid length 0]", "[malformed: "}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Dissect(test.b)
+			for _, line := range test.expected {
+				if !strings.Contains(s, line) {
+					t.Errorf("expected %q in dissection:\n%s", line, s)
+				}
+			}
+		})
+	}
+}
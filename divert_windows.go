@@ -0,0 +1,258 @@
+//go:build windows
+
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/ipv6"
+)
+
+// WinDivert is loaded on demand, so it's only needed when used, see
+// https://reqrypt.org/windivert-doc.html
+var (
+	winDivert             = syscall.NewLazyDLL("WinDivert.dll")
+	procWinDivertOpen     = winDivert.NewProc("WinDivertOpen")
+	procWinDivertRecv     = winDivert.NewProc("WinDivertRecv")
+	procWinDivertSend     = winDivert.NewProc("WinDivertSend")
+	procWinDivertShutdown = winDivert.NewProc("WinDivertShutdown")
+	procWinDivertClose    = winDivert.NewProc("WinDivertClose")
+)
+
+const (
+	winDivertLayerNetwork = 0
+	// copy packets rather than taking them away from the network stack
+	winDivertFlagSniff    = 0x0001
+	winDivertShutdownBoth = 0x3
+	// bits of the flags of winDivertAddress
+	winDivertAddressOutbound = 1 << 17
+	winDivertAddressIPv6     = 1 << 20
+)
+
+// winDivertAddress mirrors WINDIVERT_ADDRESS with the network layer data in
+// its union
+type winDivertAddress struct {
+	Timestamp int64
+	Flags     uint32
+	_         uint32
+	IfIdx     uint32
+	SubIfIdx  uint32
+	_         [56]byte
+}
+
+// ListenWinDivert works like Listen, but captures and injects complete IPv6
+// packets through the WinDivert driver rather than using a raw socket. Unlike
+// raw sockets on Windows it provides the hop limit and destination of
+// received messages. WinDivert.dll and its driver need to be installed
+func ListenWinDivert(ifi *net.Interface, role Role) (*Conn, error) {
+	addr, err := linkLocalAddr(ifi)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := listenWinDivert(ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(t, ifi, addr, role)
+}
+
+// winDivertPacket is a received message or the error that ended receiving
+type winDivertPacket struct {
+	b   []byte
+	md  *Metadata
+	err error
+}
+
+// winDivertTransport implements transport on top of a WinDivert handle
+type winDivertTransport struct {
+	h    syscall.Handle
+	ifi  *net.Interface
+	addr net.IP
+	// WinDivert doesn't join multicast groups, so we have an otherwise
+	// unused socket do it on our behalf
+	mc *ipv6.PacketConn
+
+	// WinDivertRecv blocks without deadline, so a goroutine receives and
+	// ReadFrom waits for it like pipeTransport does for its peer
+	rx        chan winDivertPacket
+	rd, wd    pipeDeadline
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func listenWinDivert(ifi *net.Interface, addr net.IP) (*winDivertTransport, error) {
+	if err := winDivert.Load(); err != nil {
+		return nil, fmt.Errorf("WinDivert unavailable: %s", err)
+	}
+
+	// our own messages are outbound, so we don't see them
+	types := make([]string, 0, len(RoleMonitor.Types()))
+	for _, t := range RoleMonitor.Types() {
+		types = append(types, fmt.Sprintf("icmpv6.Type == %d", t))
+	}
+	filter, err := syscall.BytePtrFromString(fmt.Sprintf("inbound and ifIdx == %d and (%s)", ifi.Index, strings.Join(types, " or ")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := []uintptr{uintptr(unsafe.Pointer(filter)), winDivertLayerNetwork, 0, winDivertFlagSniff}
+	if strconv.IntSize == 32 {
+		// the 64 bit flags take two arguments
+		args = append(args, 0)
+	}
+	r, _, err := procWinDivertOpen.Call(args...)
+	if syscall.Handle(r) == syscall.InvalidHandle {
+		return nil, fmt.Errorf("WinDivertOpen: %s", err)
+	}
+	h := syscall.Handle(r)
+
+	c, err := net.ListenPacket("udp6", "[::]:0")
+	if err != nil {
+		procWinDivertClose.Call(uintptr(h))
+		return nil, err
+	}
+
+	t := &winDivertTransport{
+		h:      h,
+		ifi:    ifi,
+		addr:   addr,
+		mc:     ipv6.NewPacketConn(c),
+		rx:     make(chan winDivertPacket),
+		rd:     newPipeDeadline(),
+		wd:     newPipeDeadline(),
+		closed: make(chan struct{}),
+	}
+	go t.receive()
+
+	return t, nil
+}
+
+// receive passes received messages on to ReadFrom until the handle is shut
+// down
+func (t *winDivertTransport) receive() {
+	buf := make([]byte, 0xffff)
+	for {
+		var n uint32
+		var addr winDivertAddress
+		r, _, err := procWinDivertRecv.Call(uintptr(t.h), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), uintptr(unsafe.Pointer(&n)), uintptr(unsafe.Pointer(&addr)))
+		if r == 0 {
+			select {
+			case t.rx <- winDivertPacket{err: fmt.Errorf("WinDivertRecv: %s", err)}:
+			case <-t.closed:
+			}
+			return
+		}
+
+		body, md, err := parsePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		md.IfIndex = int(addr.IfIdx)
+
+		select {
+		case t.rx <- winDivertPacket{b: append([]byte(nil), body...), md: md}:
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+func (t *winDivertTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	// a closed handle or expired deadline wins over pending packets
+	select {
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	case <-t.rd.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	default:
+	}
+
+	select {
+	case p := <-t.rx:
+		if p.err != nil {
+			return 0, nil, p.err
+		}
+		return copy(b, p.b), p.md, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	case <-t.rd.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (t *winDivertTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	select {
+	case <-t.closed:
+		return 0, net.ErrClosed
+	case <-t.wd.wait():
+		return 0, os.ErrDeadlineExceeded
+	default:
+	}
+
+	src, hopLimit := t.addr, 255
+	if md != nil {
+		if md.Source != nil {
+			src = md.Source
+		}
+		if md.HopLimit != 0 {
+			hopLimit = md.HopLimit
+		}
+	}
+
+	p, err := marshalPacket(b, src, dst, hopLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	addr := winDivertAddress{
+		Flags: winDivertAddressOutbound | winDivertAddressIPv6,
+		IfIdx: uint32(t.ifi.Index),
+	}
+	var n uint32
+	r, _, err := procWinDivertSend.Call(uintptr(t.h), uintptr(unsafe.Pointer(&p[0])), uintptr(len(p)), uintptr(unsafe.Pointer(&n)), uintptr(unsafe.Pointer(&addr)))
+	if r == 0 {
+		return 0, fmt.Errorf("WinDivertSend: %s", err)
+	}
+
+	return len(b), nil
+}
+
+func (t *winDivertTransport) JoinGroup(group net.IP) error {
+	return t.mc.JoinGroup(t.ifi, &net.UDPAddr{IP: group})
+}
+
+func (t *winDivertTransport) LeaveGroup(group net.IP) error {
+	return t.mc.LeaveGroup(t.ifi, &net.UDPAddr{IP: group})
+}
+
+func (t *winDivertTransport) SetReadDeadline(d time.Time) error {
+	t.rd.set(d)
+	return nil
+}
+
+func (t *winDivertTransport) SetWriteDeadline(d time.Time) error {
+	t.wd.set(d)
+	return nil
+}
+
+func (t *winDivertTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		// unblocks receive
+		procWinDivertShutdown.Call(uintptr(t.h), winDivertShutdownBoth)
+		procWinDivertClose.Call(uintptr(t.h))
+		t.mc.Close()
+	})
+
+	return nil
+}
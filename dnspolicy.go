@@ -0,0 +1,89 @@
+package ndp
+
+import (
+	"net"
+	"strings"
+)
+
+// DNSPolicy filters the recursive DNS servers and DNS search domains that
+// router advertisements offer, so a rogue router can't hijack name
+// resolution. Fields that are left empty don't filter anything
+type DNSPolicy struct {
+	// Routers, when set, are the only routers DNS options are accepted from
+	Routers []net.IP
+	// AllowServers, when set, only accepts servers within these prefixes,
+	// like fc00::/7 and 2000::/3 for unique local and global unicast ones
+	AllowServers []*net.IPNet
+	// DenyServers rejects servers within these prefixes
+	DenyServers []*net.IPNet
+	// AllowDomains, when set, only accepts these domain names and the
+	// domains below them
+	AllowDomains []string
+	// DenyDomains rejects these domain names and the domains below them
+	DenyDomains []string
+}
+
+// AllowServer reports whether server may be used when advertised by router.
+// A nil DNSPolicy allows any server
+func (p *DNSPolicy) AllowServer(router, server net.IP) bool {
+	if p == nil {
+		return true
+	}
+	if !p.allowRouter(router) || ipInAny(server, p.DenyServers) {
+		return false
+	}
+
+	return len(p.AllowServers) == 0 || ipInAny(server, p.AllowServers)
+}
+
+// AllowDomain reports whether name may be searched when advertised by
+// router. A nil DNSPolicy allows any domain name
+func (p *DNSPolicy) AllowDomain(router net.IP, name string) bool {
+	if p == nil {
+		return true
+	}
+	if !p.allowRouter(router) || domainInAny(name, p.DenyDomains) {
+		return false
+	}
+
+	return len(p.AllowDomains) == 0 || domainInAny(name, p.AllowDomains)
+}
+
+// allowRouter reports whether DNS options of router are accepted at all
+func (p *DNSPolicy) allowRouter(router net.IP) bool {
+	if len(p.Routers) == 0 {
+		return true
+	}
+	for _, r := range p.Routers {
+		if r.Equal(router) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipInAny reports whether ip is within any of prefixes
+func ipInAny(ip net.IP, prefixes []*net.IPNet) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// domainInAny reports whether name is any of domains or below one of them,
+// regardless of case and trailing dots
+func domainInAny(name string, domains []string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDNSPolicy(t *testing.T) {
+	_, ula, _ := net.ParseCIDR("fc00::/7")
+	_, gua, _ := net.ParseCIDR("2000::/3")
+	_, bad, _ := net.ParseCIDR("2001:db8:bad::/48")
+	router := net.ParseIP("fe80::1")
+	p := &DNSPolicy{
+		Routers:      []net.IP{router},
+		AllowServers: []*net.IPNet{ula, gua},
+		DenyServers:  []*net.IPNet{bad},
+		AllowDomains: []string{"example.com."},
+		DenyDomains:  []string{"evil.example.com"},
+	}
+
+	servers := []struct {
+		router, server string
+		allowed        bool
+	}{
+		{"fe80::1", "2001:db8::53", true},
+		{"fe80::1", "fd00::53", true},
+		{"fe80::1", "fe80::53", false},
+		{"fe80::1", "2001:db8:bad::53", false},
+		{"fe80::2", "2001:db8::53", false},
+	}
+	for _, s := range servers {
+		if allowed := p.AllowServer(net.ParseIP(s.router), net.ParseIP(s.server)); allowed != s.allowed {
+			t.Errorf("expected server %s from %s allowed %t", s.server, s.router, s.allowed)
+		}
+	}
+
+	domains := map[string]bool{
+		"example.com.":          true,
+		"EXAMPLE.com":           true,
+		"lab.example.com.":      true,
+		"badexample.com.":       false,
+		"example.net.":          false,
+		"evil.example.com.":     false,
+		"lab.evil.example.com.": false,
+	}
+	for name, allowed := range domains {
+		if p.AllowDomain(router, name) != allowed {
+			t.Errorf("expected domain %s allowed %t", name, allowed)
+		}
+	}
+	if p.AllowDomain(net.ParseIP("fe80::2"), "example.com.") {
+		t.Error("expected domain of unknown router to be rejected")
+	}
+
+	// no policy allows anything
+	var none *DNSPolicy
+	if !none.AllowServer(router, net.ParseIP("fe80::53")) || !none.AllowDomain(router, "example.net.") {
+		t.Error("expected nil policy to allow")
+	}
+}
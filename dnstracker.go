@@ -0,0 +1,266 @@
+package ndp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSConfig is the DNS configuration learnt from router advertisements
+type DNSConfig struct {
+	// Servers are the recursive DNS servers, in the order they were learnt
+	Servers []net.IP
+	// SearchList holds the domain names of the search list, in the order
+	// they were learnt
+	SearchList []string
+}
+
+// WriteResolvConf writes c in the format of resolv.conf(5) to w. Link-local
+// servers are qualified with zone, which names their interface
+func (c DNSConfig) WriteResolvConf(w io.Writer, zone string) error {
+	bw := bufio.NewWriter(w)
+	for _, ip := range c.Servers {
+		if ip.IsLinkLocalUnicast() && zone != "" {
+			fmt.Fprintf(bw, "nameserver %s%%%s\n", ip, zone)
+		} else {
+			fmt.Fprintf(bw, "nameserver %s\n", ip)
+		}
+	}
+	if len(c.SearchList) > 0 {
+		domains := make([]string, len(c.SearchList))
+		for i, d := range c.SearchList {
+			domains[i] = strings.TrimSuffix(d, ".")
+		}
+		fmt.Fprintf(bw, "search %s\n", strings.Join(domains, " "))
+	}
+
+	return bw.Flush()
+}
+
+// DNSTracker keeps track of the recursive DNS server and DNS search list
+// options, as described at https://tools.ietf.org/html/rfc8106, of the router
+// advertisements passed to Update. The options of all routers are merged,
+// and entries last until no router advertises them anymore or their
+// lifetimes end
+type DNSTracker struct {
+	// Changed, when set, is called with the new configuration whenever
+	// servers or domain names come or go
+	Changed func(DNSConfig)
+	// Policy, when set, filters the servers and domain names advertised
+	Policy *DNSPolicy
+
+	mu    sync.Mutex
+	list  dnsList
+	timer *time.Timer
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewDNSTracker returns a DNSTracker without any configuration
+func NewDNSTracker() *DNSTracker {
+	return &DNSTracker{
+		list: newDNSList(),
+		now:  time.Now,
+	}
+}
+
+// Update processes the options of a router advertisement read from
+// md.Source, so Update can be passed to Mux.HandleRouterAdvertisement
+func (t *DNSTracker) Update(ra *ICMPRouterAdvertisement, md *Metadata) {
+	t.mu.Lock()
+	changed := t.list.update(ra, md.Source, t.now(), t.Policy)
+	t.mu.Unlock()
+
+	t.changed(changed)
+}
+
+// Config returns the current configuration
+func (t *DNSTracker) Config() DNSConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.list.config()
+}
+
+// Stop stops expiring entries until the next Update
+func (t *DNSTracker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// expire removes the entries whose lifetime ended
+func (t *DNSTracker) expire() {
+	t.mu.Lock()
+	changed := t.list.expire(t.now())
+	t.mu.Unlock()
+
+	t.changed(changed)
+}
+
+// changed schedules the next expiry and calls Changed if changed is set
+func (t *DNSTracker) changed(changed bool) {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	if next := t.list.next(); !next.IsZero() {
+		t.timer = time.AfterFunc(next.Sub(t.now()), t.expire)
+	}
+	cfg := t.list.config()
+	t.mu.Unlock()
+
+	if changed && t.Changed != nil {
+		t.Changed(cfg)
+	}
+}
+
+// dnsList holds the DNS servers and search domains of all routers without
+// locking or timers, as used by DNSTracker and SLAACClient
+type dnsList struct {
+	servers map[string]*dnsEntry
+	domains map[string]*dnsEntry
+	// seq orders entries by when they were learnt
+	seq int
+}
+
+// dnsEntry is a DNS server or search domain with the routers advertising
+// it and when their lifetimes end, which is zero if they don't
+type dnsEntry struct {
+	value   string
+	order   int
+	routers map[string]time.Time
+}
+
+func newDNSList() dnsList {
+	return dnsList{
+		servers: make(map[string]*dnsEntry),
+		domains: make(map[string]*dnsEntry),
+	}
+}
+
+// update processes the DNS options of a router advertisement from src at
+// now, leaving out what policy doesn't allow, reporting whether the
+// configuration changed
+func (l *dnsList) update(ra *ICMPRouterAdvertisement, src net.IP, now time.Time, policy *DNSPolicy) bool {
+	changed := false
+	for _, o := range ra.Options {
+		switch o := o.(type) {
+		case *ICMPOptionRecursiveDNSServer:
+			for _, ip := range o.Servers {
+				if !policy.AllowServer(src, ip) {
+					continue
+				}
+				changed = l.set(l.servers, ip.String(), src, o.LifetimeDuration(), now) || changed
+			}
+		case *ICMPOptionDNSSearchList:
+			for _, name := range o.DomainNames {
+				if !policy.AllowDomain(src, name) {
+					continue
+				}
+				changed = l.set(l.domains, name, src, o.LifetimeDuration(), now) || changed
+			}
+		}
+	}
+
+	return changed
+}
+
+// set records that src advertises value with lifetime, which withdraws it
+// for a lifetime of 0, reporting whether value came or went
+func (l *dnsList) set(m map[string]*dnsEntry, value string, src net.IP, lifetime time.Duration, now time.Time) bool {
+	router := src.String()
+	e, ok := m[value]
+	if lifetime == 0 {
+		if !ok {
+			return false
+		}
+		delete(e.routers, router)
+		if len(e.routers) == 0 {
+			delete(m, value)
+			return true
+		}
+		return false
+	}
+
+	if !ok {
+		l.seq++
+		e = &dnsEntry{value: value, order: l.seq, routers: make(map[string]time.Time)}
+		m[value] = e
+	}
+	e.routers[router] = lifetimeEnd(now, lifetime)
+
+	return !ok
+}
+
+// expire removes what ran out at now, reporting whether anything went
+func (l *dnsList) expire(now time.Time) bool {
+	expired := false
+	for _, m := range []map[string]*dnsEntry{l.servers, l.domains} {
+		for value, e := range m {
+			for router, until := range e.routers {
+				if !until.IsZero() && !until.After(now) {
+					delete(e.routers, router)
+				}
+			}
+			if len(e.routers) == 0 {
+				delete(m, value)
+				expired = true
+			}
+		}
+	}
+
+	return expired
+}
+
+// next returns when the next lifetime ends, if any
+func (l *dnsList) next() time.Time {
+	var next time.Time
+	for _, m := range []map[string]*dnsEntry{l.servers, l.domains} {
+		for _, e := range m {
+			for _, until := range e.routers {
+				next = earliestEnd(next, until)
+			}
+		}
+	}
+
+	return next
+}
+
+// config returns the merged configuration
+func (l *dnsList) config() DNSConfig {
+	var cfg DNSConfig
+	for _, s := range ordered(l.servers) {
+		cfg.Servers = append(cfg.Servers, net.ParseIP(s))
+	}
+	cfg.SearchList = ordered(l.domains)
+
+	return cfg
+}
+
+// ordered returns the values of m in the order they were learnt
+func ordered(m map[string]*dnsEntry) []string {
+	entries := make([]*dnsEntry, 0, len(m))
+	for _, e := range m {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].order < entries[j].order
+	})
+
+	var values []string
+	for _, e := range entries {
+		values = append(values, e.value)
+	}
+
+	return values
+}
@@ -0,0 +1,94 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDNSTracker(t *testing.T) {
+	tr := NewDNSTracker()
+	defer tr.Stop()
+	now := time.Unix(0, 0)
+	tr.now = func() time.Time { return now }
+	var changes []DNSConfig
+	tr.Changed = func(cfg DNSConfig) { changes = append(changes, cfg) }
+
+	ra := func(lifetime time.Duration, servers []string, domains ...string) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{}
+		if servers != nil {
+			o := &ICMPOptionRecursiveDNSServer{}
+			o.SetLifetime(lifetime)
+			for _, s := range servers {
+				o.Servers = append(o.Servers, net.ParseIP(s))
+			}
+			ra.AddOption(o)
+		}
+		if domains != nil {
+			o := &ICMPOptionDNSSearchList{DomainNames: domains}
+			o.SetLifetime(lifetime)
+			ra.AddOption(o)
+		}
+		return ra
+	}
+	r1 := &Metadata{Source: net.ParseIP("fe80::1")}
+	r2 := &Metadata{Source: net.ParseIP("fe80::2")}
+
+	tr.Update(ra(time.Hour, []string{"2001:db8::53", "fe80::53"}, "example.com."), r1)
+	tr.Update(ra(10*time.Minute, []string{"2001:db8::53", "2001:db8::35"}, "example.net."), r2)
+	// only refreshed
+	tr.Update(ra(time.Hour, []string{"2001:db8::53"}), r1)
+	if len(changes) != 2 {
+		t.Errorf("expected 2 changes, not %d", len(changes))
+	}
+
+	cfg := tr.Config()
+	var b bytes.Buffer
+	if err := cfg.WriteResolvConf(&b, "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `nameserver 2001:db8::53
+nameserver fe80::53%eth0
+nameserver 2001:db8::35
+search example.com example.net
+`
+	if b.String() != expected {
+		t.Errorf("unexpected resolv.conf\n%s", b.String())
+	}
+
+	// a server stays as long as one router advertises it
+	tr.Update(ra(0, []string{"2001:db8::53"}), r2)
+	if len(changes) != 2 || len(tr.Config().Servers) != 3 {
+		t.Errorf("unexpected config %+v", tr.Config())
+	}
+	tr.Update(ra(0, []string{"2001:db8::53"}), r1)
+	if cfg := tr.Config(); len(changes) != 3 || len(cfg.Servers) != 2 || !cfg.Servers[0].Equal(net.ParseIP("fe80::53")) {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	// lifetimes end
+	now = now.Add(10 * time.Minute)
+	tr.expire()
+	cfg = tr.Config()
+	if len(changes) != 4 || len(cfg.Servers) != 1 || len(cfg.SearchList) != 1 || cfg.SearchList[0] != "example.com." {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	now = now.Add(time.Hour)
+	tr.expire()
+	if cfg := tr.Config(); len(changes) != 5 || len(cfg.Servers) != 0 || len(cfg.SearchList) != 0 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	// a policy leaves out what it doesn't allow
+	_, gua, _ := net.ParseCIDR("2000::/3")
+	tr = NewDNSTracker()
+	defer tr.Stop()
+	tr.now = func() time.Time { return now }
+	tr.Policy = &DNSPolicy{Routers: []net.IP{r1.Source}, AllowServers: []*net.IPNet{gua}}
+	tr.Update(ra(time.Hour, []string{"2001:db8::53", "fe80::53"}, "example.com."), r1)
+	tr.Update(ra(time.Hour, []string{"2001:db8::35"}, "example.net."), r2)
+	if cfg := tr.Config(); len(cfg.Servers) != 1 || !cfg.Servers[0].Equal(net.ParseIP("2001:db8::53")) || len(cfg.SearchList) != 1 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
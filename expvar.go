@@ -0,0 +1,77 @@
+package ndp
+
+import (
+	"errors"
+	"expvar"
+	"net"
+)
+
+// the reasons Counters counts dropped messages by
+const (
+	droppedFragmented = "fragmented"
+	droppedLimit      = "limit"
+	droppedMalformed  = "malformed"
+)
+
+// Counters counts the messages Conns receive, drop and send, and implements
+// expvar.Var to publish them, as a dependency free alternative to package
+// ndpprom. Received and sent messages are counted by type, dropped ones by
+// whether they were fragmented, exceeded the ParseLimits or were malformed.
+// Counters of several Conns add up, so publish a Counters per interface to
+// tell them apart:
+//
+//	c := ndp.NewCounters()
+//	expvar.Publish("ndp_eth0", c)
+//	conn.SetDropped(c.Dropped)
+//	conn.SetSent(c.Sent)
+//	conn.Serve(ctx, c.Handler(mux))
+type Counters struct {
+	vars     expvar.Map
+	received expvar.Map
+	sent     expvar.Map
+	dropped  expvar.Map
+}
+
+// NewCounters returns Counters that counted nothing yet
+func NewCounters() *Counters {
+	c := &Counters{}
+	c.vars.Set("received", &c.received)
+	c.vars.Set("sent", &c.sent)
+	c.vars.Set("dropped", &c.dropped)
+
+	return c
+}
+
+// String implements expvar.Var, returning the counters as JSON object with
+// received, sent and dropped objects
+func (c *Counters) String() string {
+	return c.vars.String()
+}
+
+// Handler returns a Handler that counts the messages Conn.Serve hands it
+// before passing them on to h
+func (c *Counters) Handler(h Handler) Handler {
+	return HandlerFunc(func(m ICMP, md *Metadata) {
+		c.received.Add(m.Type().String(), 1)
+		h.ServeNDP(m, md)
+	})
+}
+
+// Dropped counts the messages Conn.Serve drops by the reason err tells. It
+// suits Conn.SetDropped
+func (c *Counters) Dropped(md *Metadata, err error) {
+	var lerr *ParseLimitError
+	switch {
+	case err == ErrFragmented:
+		c.dropped.Add(droppedFragmented, 1)
+	case errors.As(err, &lerr):
+		c.dropped.Add(droppedLimit, 1)
+	default:
+		c.dropped.Add(droppedMalformed, 1)
+	}
+}
+
+// Sent counts message m sent to dst. It suits Conn.SetSent
+func (c *Counters) Sent(m ICMP, dst net.IP) {
+	c.sent.Add(m.Type().String(), 1)
+}
@@ -0,0 +1,68 @@
+package ndp
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	c := NewCounters()
+
+	var handled int
+	h := c.Handler(HandlerFunc(func(m ICMP, md *Metadata) {
+		handled++
+	}))
+	h.ServeNDP(&ICMPRouterSolicitation{}, &Metadata{})
+	h.ServeNDP(&ICMPNeighborSolicitation{}, &Metadata{})
+	h.ServeNDP(&ICMPNeighborSolicitation{}, &Metadata{})
+	if handled != 3 {
+		t.Errorf("expected 3 messages to be passed on, not %d", handled)
+	}
+
+	c.Dropped(&Metadata{}, ErrFragmented)
+	c.Dropped(&Metadata{}, &ParseLimitError{Limit: "MaxOptions", Max: 1})
+	c.Dropped(&Metadata{}, errors.New("message too short"))
+	c.Dropped(&Metadata{}, errors.New("message too short"))
+
+	// sent messages are counted once they are written
+	conn := &Conn{t: &testTransport{}}
+	conn.SetSent(c.Sent)
+	if err := conn.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteBatch([]OutgoingMessage{
+		{Message: &ICMPNeighborAdvertisement{TargetAddress: net.ParseIP("fe80::1")}, Destination: net.IPv6linklocalallnodes},
+		{Message: &ICMPNeighborAdvertisement{TargetAddress: net.ParseIP("fe80::2")}, Destination: net.IPv6linklocalallnodes},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var vars map[string]map[string]int
+	if err := json.Unmarshal([]byte(c.String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[string]int{
+		"received": {"router solicitation": 1, "neighbor solicitation": 2},
+		"sent":     {"router solicitation": 1, "neighbor advertisement": 2},
+		"dropped":  {droppedFragmented: 1, droppedLimit: 1, droppedMalformed: 2},
+	}
+	for name, counts := range expected {
+		if len(vars[name]) != len(counts) {
+			t.Errorf("unexpected %s counters %v", name, vars[name])
+		}
+		for k, n := range counts {
+			if vars[name][k] != n {
+				t.Errorf("expected %d %s %s, not %d", n, name, k, vars[name][k])
+			}
+		}
+	}
+}
+
+func TestCountersEmpty(t *testing.T) {
+	if s := NewCounters().String(); s != `{"dropped": {}, "received": {}, "sent": {}}` {
+		t.Errorf("unexpected empty counters %s", s)
+	}
+}
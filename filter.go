@@ -0,0 +1,159 @@
+package ndp
+
+import (
+	"errors"
+	"sort"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	errNoFilter = errors.New("transport does not support socket filters")
+)
+
+// filterTransport is implemented by transports that can have the kernel
+// filter incoming messages before they are read
+type filterTransport interface {
+	SetICMPFilter(f *ipv6.ICMPFilter) error
+	SetBPF(prog []bpf.RawInstruction) error
+}
+
+// NDPFilter returns an ICMPFilter that only passes the NDP messages of
+// RoleMonitor.Types: router solicitations, router advertisements, neighbor
+// solicitations, neighbor advertisements, redirects, the certification path
+// messages of SEND and the duplicate address messages of 6LoWPAN
+func NDPFilter() *ipv6.ICMPFilter {
+	return typeFilter(RoleMonitor.Types())
+}
+
+// typeFilter returns an ICMPFilter that only passes given types
+func typeFilter(types []ipv6.ICMPType) *ipv6.ICMPFilter {
+	f := &ipv6.ICMPFilter{}
+	f.SetAll(true)
+	for _, t := range types {
+		f.Accept(t)
+	}
+
+	return f
+}
+
+// typeBPF returns instructions that accept the message whose type was
+// loaded into the accumulator if it is one of types, and reject it
+// otherwise. The last two instructions return, rejecting and accepting
+// respectively, so preceding instructions can jump there
+func typeBPF(types []ipv6.ICMPType) []bpf.Instruction {
+	sorted := append([]ipv6.ICMPType(nil), types...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// the ranges of consecutive types
+	var ranges [][2]uint32
+	for _, t := range sorted {
+		if n := len(ranges); n > 0 && uint32(t) <= ranges[n-1][1]+1 {
+			ranges[n-1][1] = uint32(t)
+			continue
+		}
+		ranges = append(ranges, [2]uint32{uint32(t), uint32(t)})
+	}
+
+	// types below a range that weren't accepted by the previous one are
+	// rejected
+	reject := uint8(2 * len(ranges))
+	var insts []bpf.Instruction
+	for i, r := range ranges {
+		at := uint8(2 * i)
+		insts = append(insts,
+			bpf.JumpIf{Cond: bpf.JumpLessThan, Val: r[0], SkipTrue: reject - at - 1},
+			bpf.JumpIf{Cond: bpf.JumpLessOrEqual, Val: r[1], SkipTrue: reject - at - 1},
+		)
+	}
+
+	return append(insts, bpf.RetConstant{Val: 0}, bpf.RetConstant{Val: 0xffff})
+}
+
+// NDPBPF returns a classic BPF program that passes the same messages as
+// NDPFilter. Raw ICMPv6 sockets hand the program the ICMPv6 message without
+// its IPv6 header, so the type is found at the very first byte
+func NDPBPF() ([]bpf.RawInstruction, error) {
+	return bpf.Assemble(append([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 0, Size: 1},
+	}, typeBPF(RoleMonitor.Types())...))
+}
+
+// NDPFrameBPF returns a classic BPF program that passes Ethernet frames
+// carrying the same messages as NDPFilter, for devices that capture complete
+// frames. First fragments of these messages are passed as well, so Conn can
+// report them with ErrFragmented, while messages behind other IPv6 extension
+// headers are not passed
+func NDPFrameBPF() ([]bpf.RawInstruction, error) {
+	types := typeBPF(RoleMonitor.Types())
+	n := uint8(len(types))
+
+	insts := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: 12, Size: 2},
+		// to the rejecting return of the types
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: etherTypeIPv6, SkipTrue: n + 2},
+		bpf.LoadAbsolute{Off: ethernetHeaderLen + 6, Size: 1},
+		// past the types to the fragment header
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: protocolFragment, SkipTrue: n + 2},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: protocolICMPv6, SkipTrue: n - 1},
+		bpf.LoadAbsolute{Off: ethernetHeaderLen + ipv6HeaderLen, Size: 1},
+	}
+	insts = append(insts, types...)
+	insts = append(insts,
+		// the fragment header is followed by the ICMPv6 header
+		bpf.LoadAbsolute{Off: ethernetHeaderLen + ipv6HeaderLen, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: protocolICMPv6, SkipTrue: n - 1},
+		bpf.LoadAbsolute{Off: ethernetHeaderLen + ipv6HeaderLen + 8, Size: 1},
+	)
+	insts = append(insts, types...)
+
+	return bpf.Assemble(insts)
+}
+
+// SetICMPFilter installs given ICMPFilter on the socket of this Conn
+func (c *Conn) SetICMPFilter(f *ipv6.ICMPFilter) error {
+	ft, ok := c.t.(filterTransport)
+	if !ok {
+		return errNoFilter
+	}
+
+	return ft.SetICMPFilter(f)
+}
+
+// SetBPF attaches given classic BPF program to the socket of this Conn
+func (c *Conn) SetBPF(prog []bpf.RawInstruction) error {
+	ft, ok := c.t.(filterTransport)
+	if !ok {
+		return errNoFilter
+	}
+
+	return ft.SetBPF(prog)
+}
+
+// FilterNDP restricts the socket of this Conn to NDP messages, using
+// NDPFilter and, where the platform supports it, NDPBPF
+func (c *Conn) FilterNDP() error {
+	if err := c.SetICMPFilter(NDPFilter()); err != nil {
+		return err
+	}
+
+	prog, err := NDPBPF()
+	if err != nil {
+		return err
+	}
+
+	// the ICMP filter already does the job, BPF merely saves some work
+	// on platforms that support it
+	c.SetBPF(prog)
+
+	return nil
+}
+
+func (t *rawTransport) SetICMPFilter(f *ipv6.ICMPFilter) error {
+	return t.pc.SetICMPFilter(f)
+}
+
+func (t *rawTransport) SetBPF(prog []bpf.RawInstruction) error {
+	return t.pc.SetBPF(prog)
+}
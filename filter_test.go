@@ -0,0 +1,146 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/ipv6"
+)
+
+// ndpType returns whether ICMPv6 type i is one of the NDP messages the
+// filters pass
+func ndpType(i int) bool {
+	return i >= 133 && i <= 137 || i == 148 || i == 149 || i == 157 || i == 158
+}
+
+func TestNDPFilter(t *testing.T) {
+	f := NDPFilter()
+	for i := 0; i < 256; i++ {
+		typ := ipv6.ICMPType(i)
+		ndp := ndpType(i)
+		if f.WillBlock(typ) == ndp {
+			t.Errorf("unexpected filtering of type %d", i)
+		}
+	}
+}
+
+func TestNDPBPF(t *testing.T) {
+	prog, err := NDPBPF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insts, ok := bpf.Disassemble(prog)
+	if !ok {
+		t.Fatal("failed to disassemble program")
+	}
+
+	vm, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 256; i++ {
+		n, err := vm.Run([]byte{byte(i), 0, 0, 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ndp := ndpType(i)
+		if (n > 0) != ndp {
+			t.Errorf("unexpected filtering of type %d", i)
+		}
+	}
+}
+
+func TestNDPFrameBPF(t *testing.T) {
+	prog, err := NDPFrameBPF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insts, ok := bpf.Disassemble(prog)
+	if !ok {
+		t.Fatal("failed to disassemble program")
+	}
+
+	vm, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcHW := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	frame, err := MarshalFrame(&ICMPRouterSolicitation{}, srcHW, nil, net.ParseIP("fe80::1"), net.IPv6linklocalallrouters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 256; i++ {
+		frame[ethernetHeaderLen+ipv6HeaderLen] = byte(i)
+		n, err := vm.Run(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ndp := ndpType(i)
+		if (n > 0) != ndp {
+			t.Errorf("unexpected filtering of type %d", i)
+		}
+	}
+
+	// first fragments of NDP messages, to be reported
+	frame[ethernetHeaderLen+ipv6HeaderLen] = 134
+	frag := append(append([]byte(nil), frame[:ethernetHeaderLen]...), testFragment(frame[ethernetHeaderLen:], 0)...)
+	if n, _ := vm.Run(frag); n == 0 {
+		t.Errorf("unexpected filtering of fragment")
+	}
+	frag[ethernetHeaderLen+ipv6HeaderLen] = 17
+	if n, _ := vm.Run(frag); n != 0 {
+		t.Errorf("unexpected pass of UDP fragment")
+	}
+
+	// UDP
+	frame[ethernetHeaderLen+6] = 17
+	if n, _ := vm.Run(frame); n != 0 {
+		t.Errorf("unexpected pass of UDP")
+	}
+
+	// ARP
+	frame[12], frame[13] = 0x08, 0x06
+	if n, _ := vm.Run(frame); n != 0 {
+		t.Errorf("unexpected pass of ARP")
+	}
+}
+
+func TestFilterUnsupported(t *testing.T) {
+	c := &Conn{t: &testTransport{}}
+	if err := c.SetICMPFilter(NDPFilter()); err != errNoFilter {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := c.FilterNDP(); err != errNoFilter {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestFilterNDP(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range ifis {
+		c, err := Listen(&ifis[i], RoleHost)
+		if err != nil {
+			continue
+		}
+		defer c.Close()
+
+		if err = c.FilterNDP(); err != nil {
+			t.Error(err)
+		}
+		return
+	}
+
+	t.Skip("can't listen on any interface")
+}
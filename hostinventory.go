@@ -0,0 +1,243 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the defaults of HostInventory
+const (
+	defaultHostTimeout    = 24 * time.Hour
+	defaultMaxHostRecords = 4096
+)
+
+// HostRecord is an address of a host seen on the link, within a prefix an
+// RAServer advertises
+type HostRecord struct {
+	Address net.IP
+	// Prefix is the advertised prefix Address is in
+	Prefix           *net.IPNet
+	LinkLayerAddress net.HardwareAddr
+	// Tentative is set while the address has only been seen in duplicate
+	// address detection, before the host used it
+	Tentative bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// HostInventory keeps an inventory of the addresses hosts use in the
+// prefixes an RAServer advertises, as seen in their duplicate address
+// detection, neighbor solicitations and neighbor advertisements. This
+// accounts for the addresses hosts configured themselves, which a router
+// otherwise only learns about once they send traffic. The Conn must see
+// messages sent to solicited-node groups other than its own, like one
+// ListenFrames returns in promiscuous mode does
+type HostInventory struct {
+	// Timeout is how long addresses are kept after they were last seen. It
+	// defaults to 24h
+	Timeout time.Duration
+	// MaxEntries bounds the addresses kept, above which new ones are
+	// ignored until others time out. It defaults to 4096
+	MaxEntries int
+	// Seen, when set, is called for every address seen for the first time
+	Seen func(HostRecord)
+
+	c *Conn
+	s *RAServer
+
+	mu    sync.Mutex
+	hosts map[string]*HostRecord
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewHostInventory returns a HostInventory of the hosts using the prefixes s
+// advertises, as seen in the messages read from c
+func NewHostInventory(c *Conn, s *RAServer) *HostInventory {
+	return &HostInventory{
+		Timeout:    defaultHostTimeout,
+		MaxEntries: defaultMaxHostRecords,
+		c:          c,
+		s:          s,
+		hosts:      make(map[string]*HostRecord),
+		now:        time.Now,
+	}
+}
+
+// Serve keeps the inventory of the messages read from the Conn until ctx is
+// done or reading fails
+func (inv *HostInventory) Serve(ctx context.Context) error {
+	return inv.c.Serve(ctx, inv)
+}
+
+// ServeNDP records the address m, received with md, shows a host uses
+func (inv *HostInventory) ServeNDP(m ICMP, md *Metadata) {
+	if md == nil {
+		return
+	}
+
+	switch m := m.(type) {
+	case *ICMPNeighborSolicitation:
+		// duplicate address detection announces the address a host is
+		// about to use
+		if md.Source.IsUnspecified() {
+			inv.record(m.TargetAddress, md.SourceLinkLayerAddress, true)
+			return
+		}
+		lla := sourceLinkLayerAddr(m.Options)
+		if lla == nil {
+			lla = md.SourceLinkLayerAddress
+		}
+		inv.record(md.Source, lla, false)
+	case *ICMPNeighborAdvertisement:
+		var lla net.HardwareAddr
+		for _, o := range m.Options {
+			if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+				lla = o.LinkLayerAddress
+			}
+		}
+		if lla == nil {
+			lla = md.SourceLinkLayerAddress
+		}
+		inv.record(m.TargetAddress, lla, false)
+	}
+}
+
+// record notes that ip is used by the host at lla, if ip is in one of the
+// advertised prefixes
+func (inv *HostInventory) record(ip net.IP, lla net.HardwareAddr, tentative bool) {
+	if ip.To16() == nil || ip.To4() != nil || ip.IsMulticast() {
+		return
+	}
+	prefix := inv.prefix(ip)
+	if prefix == nil {
+		return
+	}
+
+	inv.mu.Lock()
+	now := inv.now()
+	inv.expire(now)
+	key := ip.String()
+	h, ok := inv.hosts[key]
+	if !ok {
+		if inv.MaxEntries > 0 && len(inv.hosts) >= inv.MaxEntries {
+			inv.mu.Unlock()
+			return
+		}
+		h = &HostRecord{
+			Address:   append(net.IP(nil), ip.To16()...),
+			Tentative: tentative,
+			FirstSeen: now,
+		}
+		inv.hosts[key] = h
+	}
+	h.Prefix = prefix
+	h.LastSeen = now
+	if !tentative {
+		h.Tentative = false
+	}
+	if lla != nil {
+		h.LinkLayerAddress = append(net.HardwareAddr(nil), lla...)
+	}
+	rec := *h
+	inv.mu.Unlock()
+
+	if ok {
+		return
+	}
+	inv.c.logger().Debug("new host address", "addr", rec.Address.String(), "prefix", rec.Prefix.String(), "lladdr", rec.LinkLayerAddress.String())
+	if inv.Seen != nil {
+		inv.Seen(rec)
+	}
+}
+
+// prefix returns the most specific prefix advertised by the RAServer that
+// contains ip, if any
+func (inv *HostInventory) prefix(ip net.IP) *net.IPNet {
+	inv.s.mu.Lock()
+	prefixes := inv.s.advertised().Prefixes
+	inv.s.mu.Unlock()
+
+	var (
+		match *net.IPNet
+		best  = -1
+	)
+	for _, p := range prefixes {
+		ones, _ := p.Prefix.Mask.Size()
+		if ones > best && p.Prefix.Contains(ip) {
+			match, best = p.Prefix, ones
+		}
+	}
+
+	return match
+}
+
+// expire removes the addresses that timed out at now. It must be called with
+// mu held
+func (inv *HostInventory) expire(now time.Time) {
+	for key, h := range inv.hosts {
+		if now.Sub(h.LastSeen) >= inv.Timeout {
+			delete(inv.hosts, key)
+		}
+	}
+}
+
+// Lookup returns the record of ip, if any
+func (inv *HostInventory) Lookup(ip net.IP) (HostRecord, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.expire(inv.now())
+	h, ok := inv.hosts[ip.String()]
+	if !ok {
+		return HostRecord{}, false
+	}
+
+	return *h, true
+}
+
+// Hosts returns the records of the addresses within prefix, or all records
+// if prefix is nil, sorted by address
+func (inv *HostInventory) Hosts(prefix *net.IPNet) []HostRecord {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.expire(inv.now())
+	var hosts []HostRecord
+	for _, h := range inv.hosts {
+		if prefix == nil || prefix.Contains(h.Address) {
+			hosts = append(hosts, *h)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].Address, hosts[j].Address) < 0
+	})
+
+	return hosts
+}
+
+// Inventory returns the records of every advertised prefix that hosts use,
+// keyed by prefix and sorted by address
+func (inv *HostInventory) Inventory() map[string][]HostRecord {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.expire(inv.now())
+	m := make(map[string][]HostRecord)
+	for _, h := range inv.hosts {
+		key := h.Prefix.String()
+		m[key] = append(m[key], *h)
+	}
+	for _, hosts := range m {
+		sort.Slice(hosts, func(i, j int) bool {
+			return bytes.Compare(hosts[i].Address, hosts[j].Address) < 0
+		})
+	}
+
+	return m
+}
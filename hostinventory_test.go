@@ -0,0 +1,93 @@
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHostInventory(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+	a.role = RoleRouter
+
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+	cfg := DefaultRAConfig()
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(p1), NewRAPrefix(p2)}
+	s, err := NewRAServer(a, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := NewHostInventory(a, s)
+	inv.MaxEntries = 3
+	now := time.Unix(0, 0)
+	inv.now = func() time.Time { return now }
+	var seen []HostRecord
+	inv.Seen = func(h HostRecord) { seen = append(seen, h) }
+
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x07}
+	addr := net.ParseIP("2001:db8:1::7")
+
+	// duplicate address detection has the address tentative
+	inv.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: addr}, &Metadata{Source: net.IPv6unspecified, SourceLinkLayerAddress: mac})
+	h, ok := inv.Lookup(addr)
+	if !ok || !h.Tentative || h.LinkLayerAddress.String() != mac.String() || h.Prefix.String() != p1.String() {
+		t.Errorf("unexpected record %+v", h)
+	}
+	if len(seen) != 1 {
+		t.Errorf("expected 1 address seen, not %d", len(seen))
+	}
+
+	// until the host uses it
+	now = now.Add(time.Second)
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8:1::1")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: mac})
+	inv.ServeNDP(ns, &Metadata{Source: addr})
+	if h, ok = inv.Lookup(addr); !ok || h.Tentative || !h.FirstSeen.Equal(time.Unix(0, 0)) || !h.LastSeen.Equal(now) {
+		t.Errorf("unexpected record %+v", h)
+	}
+	if len(seen) != 1 {
+		t.Errorf("expected 1 address seen, not %d", len(seen))
+	}
+
+	na := &ICMPNeighborAdvertisement{TargetAddress: net.ParseIP("2001:db8:2::8")}
+	na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x08}})
+	inv.ServeNDP(na, &Metadata{Source: net.ParseIP("fe80::8")})
+
+	// addresses outside the advertised prefixes are left out
+	inv.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8:3::9")}, &Metadata{Source: net.IPv6unspecified})
+	inv.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: addr}, &Metadata{Source: net.ParseIP("fe80::9")})
+
+	if hosts := inv.Hosts(nil); len(hosts) != 2 || !hosts[0].Address.Equal(addr) || hosts[1].Prefix.String() != p2.String() {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+	_, all, _ := net.ParseCIDR("2001:db8::/32")
+	if hosts := inv.Hosts(p2); len(hosts) != 1 || len(inv.Hosts(all)) != 2 {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+	if m := inv.Inventory(); len(m) != 2 || len(m[p1.String()]) != 1 || len(m[p2.String()]) != 1 {
+		t.Errorf("unexpected inventory %v", m)
+	}
+
+	// the inventory is bounded
+	for i := 10; i < 13; i++ {
+		ip := net.ParseIP(fmt.Sprintf("2001:db8:1::%x", i))
+		inv.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: ip}, &Metadata{Source: net.IPv6unspecified})
+	}
+	if hosts := inv.Hosts(nil); len(hosts) != 3 {
+		t.Errorf("expected 3 hosts, not %d", len(hosts))
+	}
+
+	// and forgets addresses no longer seen
+	now = now.Add(inv.Timeout)
+	if _, ok := inv.Lookup(addr); ok {
+		t.Error("expected address to time out")
+	}
+	if hosts := inv.Hosts(nil); len(hosts) != 0 {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+}
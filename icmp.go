@@ -92,9 +92,50 @@ func (oc optionContainer) GetOption(t ICMPOptionType) (*ICMPOption, error) {
 	return nil, fmt.Errorf("option %d not found", t)
 }
 
+// options returns the options of the message, see optionCarrier
+func (oc optionContainer) options() ICMPOptions {
+	return oc.Options
+}
+
+// optionCarrier is implemented by the messages that carry options
+type optionCarrier interface {
+	options() ICMPOptions
+}
+
+// optionAdder is implemented by the messages options can be added to
+type optionAdder interface {
+	AddOption(o ICMPOption)
+}
+
+// optionsOffset returns where the options of messages of type typ start, or
+// 0 for types without options
+func optionsOffset(typ ipv6.ICMPType) int {
+	switch typ {
+	case ipv6.ICMPTypeRouterSolicitation, ipv6.ICMPTypeCertificationPathSolicitation:
+		return 8
+	case ipv6.ICMPTypeCertificationPathAdvertisement:
+		return 12
+	case ipv6.ICMPTypeRouterAdvertisement:
+		return 16
+	case ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeNeighborAdvertisement:
+		return 24
+	case ipv6.ICMPTypeDuplicateAddressRequest, ipv6.ICMPTypeDuplicateAddressConfirmation:
+		return 32
+	case ipv6.ICMPTypeRedirect:
+		return 40
+	}
+
+	return 0
+}
+
 // ParseMessage returns ICMP and its ICMPOptions for given bytes or error
 // if it couldn't parse it
 func ParseMessage(b []byte) (ICMP, error) {
+	return parseMessage(b, nil)
+}
+
+// parseMessage works like ParseMessage, within the limits of pb if set
+func parseMessage(b []byte, pb *parseBudget) (ICMP, error) {
 	if len(b) < 4 {
 		return nil, errMessageTooShort
 	}
@@ -104,10 +145,14 @@ func ParseMessage(b []byte) (ICMP, error) {
 
 	switch icmpType {
 	case ipv6.ICMPTypeRouterSolicitation:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
 		message = &ICMPRouterSolicitation{}
 
 		if len(b) > 8 {
-			options, err := parseOptions(b[8:])
+			options, err := parseLimitedOptions(b[8:], pb)
 			if err != nil {
 				return nil, err
 			}
@@ -118,6 +163,10 @@ func ParseMessage(b []byte) (ICMP, error) {
 		return message, nil
 
 	case ipv6.ICMPTypeRouterAdvertisement:
+		if len(b) < 16 {
+			return nil, errMessageTooShort
+		}
+
 		message = &ICMPRouterAdvertisement{
 			HopLimit:       uint8(b[4]),
 			ManagedAddress: false,
@@ -145,7 +194,7 @@ func ParseMessage(b []byte) (ICMP, error) {
 		}
 
 		if len(b) > 16 {
-			options, err := parseOptions(b[16:])
+			options, err := parseLimitedOptions(b[16:], pb)
 			if err != nil {
 				return nil, err
 			}
@@ -156,12 +205,16 @@ func ParseMessage(b []byte) (ICMP, error) {
 		return message, nil
 
 	case ipv6.ICMPTypeNeighborSolicitation:
+		if len(b) < 24 {
+			return nil, errMessageTooShort
+		}
+
 		message = &ICMPNeighborSolicitation{
 			TargetAddress: b[8:24],
 		}
 
 		if len(b) > 24 {
-			options, err := parseOptions(b[24:])
+			options, err := parseLimitedOptions(b[24:], pb)
 			if err != nil {
 				return nil, err
 			}
@@ -172,6 +225,10 @@ func ParseMessage(b []byte) (ICMP, error) {
 		return message, nil
 
 	case ipv6.ICMPTypeNeighborAdvertisement:
+		if len(b) < 24 {
+			return nil, errMessageTooShort
+		}
+
 		message = &ICMPNeighborAdvertisement{
 			TargetAddress: b[8:24],
 		}
@@ -188,7 +245,7 @@ func ParseMessage(b []byte) (ICMP, error) {
 		}
 
 		if len(b) > 24 {
-			options, err := parseOptions(b[24:])
+			options, err := parseLimitedOptions(b[24:], pb)
 			if err != nil {
 				return nil, err
 			}
@@ -198,6 +255,85 @@ func ParseMessage(b []byte) (ICMP, error) {
 
 		return message, nil
 
+	case ipv6.ICMPTypeRedirect:
+		if len(b) < 40 {
+			return nil, errMessageTooShort
+		}
+
+		message = &ICMPRedirect{
+			TargetAddress:      b[8:24],
+			DestinationAddress: b[24:40],
+		}
+
+		if len(b) > 40 {
+			options, err := parseLimitedOptions(b[40:], pb)
+			if err != nil {
+				return nil, err
+			}
+
+			message.(*ICMPRedirect).Options = options
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeCertificationPathSolicitation:
+		if len(b) < 8 {
+			return nil, errMessageTooShort
+		}
+
+		message = &ICMPCertificationPathSolicitation{
+			Identifier: binary.BigEndian.Uint16(b[4:6]),
+			Component:  binary.BigEndian.Uint16(b[6:8]),
+		}
+
+		if len(b) > 8 {
+			options, err := parseLimitedOptions(b[8:], pb)
+			if err != nil {
+				return nil, err
+			}
+
+			message.(*ICMPCertificationPathSolicitation).Options = options
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeCertificationPathAdvertisement:
+		if len(b) < 12 {
+			return nil, errMessageTooShort
+		}
+
+		message = &ICMPCertificationPathAdvertisement{
+			Identifier:    binary.BigEndian.Uint16(b[4:6]),
+			AllComponents: binary.BigEndian.Uint16(b[6:8]),
+			Component:     binary.BigEndian.Uint16(b[8:10]),
+		}
+
+		if len(b) > 12 {
+			options, err := parseLimitedOptions(b[12:], pb)
+			if err != nil {
+				return nil, err
+			}
+
+			message.(*ICMPCertificationPathAdvertisement).Options = options
+		}
+
+		return message, nil
+
+	case ipv6.ICMPTypeDuplicateAddressRequest, ipv6.ICMPTypeDuplicateAddressConfirmation:
+		if len(b) < 32 {
+			return nil, errMessageTooShort
+		}
+
+		status := AddressRegistrationStatus(b[4])
+		lifetime := binary.BigEndian.Uint16(b[6:8])
+		eui64 := net.HardwareAddr(b[8:16])
+		addr := net.IP(b[16:32])
+		if icmpType == ipv6.ICMPTypeDuplicateAddressRequest {
+			return &ICMPDuplicateAddressRequest{Status: status, RegistrationLifetime: lifetime, EUI64: eui64, RegisteredAddress: addr}, nil
+		}
+
+		return &ICMPDuplicateAddressConfirmation{Status: status, RegistrationLifetime: lifetime, EUI64: eui64, RegisteredAddress: addr}, nil
+
 	default:
 		return nil, fmt.Errorf("message with type %d not supported", icmpType)
 	}
@@ -453,3 +589,253 @@ func (p ICMPNeighborAdvertisement) Marshal() ([]byte, error) {
 
 	return b, nil
 }
+
+// ICMPRedirect implements the Redirect message as described at
+// https://tools.ietf.org/html/rfc4861#section-4.5
+type ICMPRedirect struct {
+	optionContainer
+	// TargetAddress is the better first hop, which is DestinationAddress
+	// itself when the destination is a neighbor
+	TargetAddress      net.IP
+	DestinationAddress net.IP
+}
+
+func (p ICMPRedirect) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("%s to %s\n", p.DestinationAddress, p.TargetAddress)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeRedirect
+func (p ICMPRedirect) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeRedirect
+}
+
+// Marshal returns byte slice representing this ICMPRedirect
+func (p ICMPRedirect) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	b = append(b, p.TargetAddress.To16()...)
+	b = append(b, p.DestinationAddress.To16()...)
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+
+	return b, nil
+}
+
+// CertificationPathAllComponents is the Component of a certification path
+// solicitation that asks for the whole path
+const CertificationPathAllComponents = 0xffff
+
+// ICMPCertificationPathSolicitation implements the Certification Path
+// Solicitation message as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.1. Its Trust Anchor
+// options name the anchors the path should end in
+type ICMPCertificationPathSolicitation struct {
+	optionContainer
+	// Identifier is echoed by the advertisements answering this
+	// solicitation
+	Identifier uint16
+	// Component is the certificate asked for, where 0 is the certificate of
+	// the router, or CertificationPathAllComponents
+	Component uint16
+}
+
+func (p ICMPCertificationPathSolicitation) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, component %d\n", p.Identifier, p.Component)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeCertificationPathSolicitation
+func (p ICMPCertificationPathSolicitation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeCertificationPathSolicitation
+}
+
+// Marshal returns byte slice representing this
+// ICMPCertificationPathSolicitation
+func (p ICMPCertificationPathSolicitation) Marshal() ([]byte, error) {
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	binary.BigEndian.PutUint16(b[6:8], p.Component)
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+
+	return b, nil
+}
+
+// ICMPCertificationPathAdvertisement implements the Certification Path
+// Advertisement message as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.2. It carries one
+// certificate of the path in a Certificate option, along with the Trust
+// Anchor option it was asked for
+type ICMPCertificationPathAdvertisement struct {
+	optionContainer
+	// Identifier is that of the solicitation answered, or 0 when
+	// unsolicited
+	Identifier uint16
+	// AllComponents is the number of certificates in the path
+	AllComponents uint16
+	// Component is the certificate carried, counting down from the one
+	// closest to the trust anchor to 0 for the certificate of the router
+	Component uint16
+}
+
+func (p ICMPCertificationPathAdvertisement) String() string {
+	m, _ := p.Marshal()
+	s := fmt.Sprintf("%s, length %d, ", p.Type(), len(m))
+	s += fmt.Sprintf("id %d, component %d of %d\n", p.Identifier, p.Component, p.AllComponents)
+	for _, o := range p.Options {
+		s += fmt.Sprintf("    %s\n", o)
+	}
+
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Type returns ipv6.ICMPTypeCertificationPathAdvertisement
+func (p ICMPCertificationPathAdvertisement) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeCertificationPathAdvertisement
+}
+
+// Marshal returns byte slice representing this
+// ICMPCertificationPathAdvertisement
+func (p ICMPCertificationPathAdvertisement) Marshal() ([]byte, error) {
+	b := make([]byte, 12)
+	// message header
+	b[0] = uint8(p.Type())
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	binary.BigEndian.PutUint16(b[4:6], p.Identifier)
+	binary.BigEndian.PutUint16(b[6:8], p.AllComponents)
+	binary.BigEndian.PutUint16(b[8:10], p.Component)
+	// b[10:12] = reserved
+	// add options
+	om, err := p.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, om...)
+
+	return b, nil
+}
+
+// ICMPDuplicateAddressRequest implements the Duplicate Address Request
+// message as described at https://tools.ietf.org/html/rfc6775#section-4.4,
+// which a 6LoWPAN router sends a border router to check an address
+// registered with it across the network. Unlike other Neighbor Discovery
+// messages it travels several hops
+type ICMPDuplicateAddressRequest struct {
+	// Status is unused and should be AddressRegistrationStatusSuccess
+	Status AddressRegistrationStatus
+	// RegistrationLifetime is expressed in units of 60 seconds
+	RegistrationLifetime uint16
+	EUI64                net.HardwareAddr
+	RegisteredAddress    net.IP
+}
+
+func (p ICMPDuplicateAddressRequest) String() string {
+	m, _ := p.Marshal()
+	return duplicateAddressString(p.Type(), len(m), p.Status, p.RegistrationLifetime, p.EUI64, p.RegisteredAddress)
+}
+
+// Type returns ipv6.ICMPTypeDuplicateAddressRequest
+func (p ICMPDuplicateAddressRequest) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeDuplicateAddressRequest
+}
+
+// Marshal returns byte slice representing this ICMPDuplicateAddressRequest
+func (p ICMPDuplicateAddressRequest) Marshal() ([]byte, error) {
+	return marshalDuplicateAddress(p.Type(), p.Status, p.RegistrationLifetime, p.EUI64, p.RegisteredAddress)
+}
+
+// ICMPDuplicateAddressConfirmation implements the Duplicate Address
+// Confirmation message as described at
+// https://tools.ietf.org/html/rfc6775#section-4.4, with which a border
+// router answers a Duplicate Address Request
+type ICMPDuplicateAddressConfirmation struct {
+	Status AddressRegistrationStatus
+	// RegistrationLifetime is expressed in units of 60 seconds
+	RegistrationLifetime uint16
+	EUI64                net.HardwareAddr
+	RegisteredAddress    net.IP
+}
+
+func (p ICMPDuplicateAddressConfirmation) String() string {
+	m, _ := p.Marshal()
+	return duplicateAddressString(p.Type(), len(m), p.Status, p.RegistrationLifetime, p.EUI64, p.RegisteredAddress)
+}
+
+// Type returns ipv6.ICMPTypeDuplicateAddressConfirmation
+func (p ICMPDuplicateAddressConfirmation) Type() ipv6.ICMPType {
+	return ipv6.ICMPTypeDuplicateAddressConfirmation
+}
+
+// Marshal returns byte slice representing this
+// ICMPDuplicateAddressConfirmation
+func (p ICMPDuplicateAddressConfirmation) Marshal() ([]byte, error) {
+	return marshalDuplicateAddress(p.Type(), p.Status, p.RegistrationLifetime, p.EUI64, p.RegisteredAddress)
+}
+
+// duplicateAddressString describes a duplicate address request or
+// confirmation, which share their format
+func duplicateAddressString(typ ipv6.ICMPType, length int, status AddressRegistrationStatus, lifetime uint16, eui64 net.HardwareAddr, addr net.IP) string {
+	s := fmt.Sprintf("%s, length %d, ", typ, length)
+	s += fmt.Sprintf("status %s, ", status)
+	s += fmt.Sprintf("lifetime %dm, ", lifetime)
+	s += fmt.Sprintf("eui-64 %s, ", eui64)
+	s += fmt.Sprintf("address %s", addr)
+
+	return s
+}
+
+// marshalDuplicateAddress returns the bytes of a duplicate address request
+// or confirmation
+func marshalDuplicateAddress(typ ipv6.ICMPType, status AddressRegistrationStatus, lifetime uint16, eui64 net.HardwareAddr, addr net.IP) ([]byte, error) {
+	if len(eui64) != 8 {
+		return nil, fmt.Errorf("eui-64 %s should be 8 bytes", eui64)
+	}
+	if addr.To16() == nil || addr.To4() != nil {
+		return nil, fmt.Errorf("registered address %s is not an IPv6 address", addr)
+	}
+
+	b := make([]byte, 8)
+	// message header
+	b[0] = uint8(typ)
+	// b[1] = code, always 0
+	// b[2:3] = checksum, calculated separately
+	b[4] = byte(status)
+	// b[5] = reserved
+	binary.BigEndian.PutUint16(b[6:8], lifetime)
+	b = append(b, eui64...)
+	b = append(b, addr.To16()...)
+
+	return b, nil
+}
@@ -16,6 +16,14 @@ func TestParseMessage(t *testing.T) {
 		t.Errorf("unexpected error message: %s", err)
 	}
 
+	// truncated messages of supported types
+	for _, typ := range []byte{133, 134, 135, 136, 137, 148, 149} {
+		_, err = ParseMessage([]byte{typ, 0, 0, 0, 0, 0, 0})
+		if err != errMessageTooShort {
+			t.Errorf("unexpected error message for type %d: %s", typ, err)
+		}
+	}
+
 	_, err = ParseMessage([]byte{128, 0, 0, 0})
 	fixture := "message with type 128 not supported"
 	if strings.Compare(fmt.Sprintf("%s", err), fixture) != 0 {
@@ -484,6 +492,191 @@ func TestICMPRouterAdvertisement(t *testing.T) {
 	}
 }
 
+func TestICMPRedirect(t *testing.T) {
+	icmp := &ICMPRedirect{
+		TargetAddress:      net.ParseIP("fe80::1"),
+		DestinationAddress: net.ParseIP("2001:db8::1"),
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeRedirect {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeRedirect)
+	}
+
+	option := &ICMPOptionTargetLinkLayerAddress{}
+	option.LinkLayerAddress, _ = net.ParseMAC("a1:b2:c3:d4:e5:f6")
+	icmp.AddOption(option)
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{137, 0, 0, 0, 0, 0, 0, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 1, 161, 178, 195, 212, 229, 246}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "redirect message, length 48, 2001:db8::1 to fe80::1\n    target link-layer address option (2), length 8 (1): a1:b2:c3:d4:e5:f6"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPCertificationPathSolicitation(t *testing.T) {
+	icmp := &ICMPCertificationPathSolicitation{
+		Identifier: 42,
+		Component:  CertificationPathAllComponents,
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeCertificationPathSolicitation {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeCertificationPathSolicitation)
+	}
+
+	option, _ := NewTrustAnchorFQDN("example.com")
+	icmp.AddOption(option)
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{148, 0, 0, 0, 0, 42, 255, 255, 15, 3, 2, 7, 7, 101, 120, 97, 109, 112, 108, 101, 3, 99, 111, 109, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "certification path solicitation message, length 32, id 42, component 65535\n    trust anchor option (15), length 24 (3): name type fqdn, name example.com."
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPDuplicateAddress(t *testing.T) {
+	eui := net.HardwareAddr{0x02, 0, 0, 0xff, 0xfe, 0, 0, 0x02}
+	addr := net.ParseIP("2001:db8::2")
+	dar := &ICMPDuplicateAddressRequest{RegistrationLifetime: 10, EUI64: eui, RegisteredAddress: addr}
+	dac := &ICMPDuplicateAddressConfirmation{Status: AddressRegistrationStatusDuplicate, RegistrationLifetime: 10, EUI64: eui, RegisteredAddress: addr}
+
+	if dar.Type() != ipv6.ICMPTypeDuplicateAddressRequest {
+		t.Errorf("wrong type: %d instead of %d", dar.Type(), ipv6.ICMPTypeDuplicateAddressRequest)
+	}
+	if dac.Type() != ipv6.ICMPTypeDuplicateAddressConfirmation {
+		t.Errorf("wrong type: %d instead of %d", dac.Type(), ipv6.ICMPTypeDuplicateAddressConfirmation)
+	}
+
+	marshal, err := dac.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := []byte{158, 0, 0, 0, 1, 0, 0, 10, 2, 0, 0, 255, 254, 0, 0, 2, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "duplicate address confirmation, length 32, status duplicate address, lifetime 10m, eui-64 02:00:00:ff:fe:00:00:02, address 2001:db8::2"
+	if desc := dac.String(); desc != descfix {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	for _, m := range []ICMP{dar, dac} {
+		marshal, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseMessage(marshal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Type() != m.Type() || parsed.String() != m.String() {
+			t.Errorf("parsed %s did not match %s", parsed, m)
+		}
+	}
+
+	if _, err := ParseMessage(fixture[:31]); err != errMessageTooShort {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := (&ICMPDuplicateAddressRequest{EUI64: eui[:6], RegisteredAddress: addr}).Marshal(); err == nil {
+		t.Error("expected error for short eui-64")
+	}
+	if _, err := (&ICMPDuplicateAddressRequest{EUI64: eui, RegisteredAddress: net.IPv4(192, 0, 2, 1)}).Marshal(); err == nil {
+		t.Error("expected error for IPv4 address")
+	}
+}
+
+func TestICMPCertificationPathAdvertisement(t *testing.T) {
+	icmp := &ICMPCertificationPathAdvertisement{
+		Identifier:    42,
+		AllComponents: 2,
+		Component:     1,
+	}
+
+	if icmp.Type() != ipv6.ICMPTypeCertificationPathAdvertisement {
+		t.Errorf("wrong type: %d instead of %d", icmp.Type(), ipv6.ICMPTypeCertificationPathAdvertisement)
+	}
+
+	icmp.AddOption(&ICMPOptionCertificate{CertType: CertificateTypeX509, Certificate: []byte{1, 2, 3, 4}})
+
+	marshal, err := icmp.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{149, 0, 0, 0, 0, 42, 0, 2, 0, 1, 0, 0, 16, 1, 1, 0, 1, 2, 3, 4}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "certification path advertisement message, length 20, id 42, component 1 of 2\n    certificate option (16), length 8 (1): cert type x509v3"
+	desc := icmp.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	parsedICMP, err := ParseMessage(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsedMarshal, err := parsedICMP.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
 func TestChecksum(t *testing.T) {
 	// prepare icmp message
 	msg := &ICMPRouterAdvertisement{
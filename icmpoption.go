@@ -1,10 +1,19 @@
 package ndp
 
 import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 )
 
 // ICMPOptions is a type wrapper for a slice of ICMPOptions
@@ -29,20 +38,82 @@ func (opts ICMPOptions) Marshal() ([]byte, error) {
 // ICMPOptionType describes ICMPv6 types
 type ICMPOptionType int
 
-// ICMPv6 Neighbor discovery types as described in RFC4861, RFC3971, RFC6106
+// ICMPv6 Neighbor discovery types as registered by IANA at
+// https://www.iana.org/assignments/icmpv6-parameters/icmpv6-parameters.xhtml#icmpv6-parameters-5
 const (
 	ICMPOptionTypeUnknown ICMPOptionType = iota
 	// RFC4861
 	ICMPOptionTypeSourceLinkLayerAddress
 	ICMPOptionTypeTargetLinkLayerAddress
 	ICMPOptionTypePrefixInformation
-	_
+	ICMPOptionTypeRedirectedHeader
 	ICMPOptionTypeMTU
+	// RFC2491
+	ICMPOptionTypeNBMAShortcutLimit
+	// RFC6275
+	ICMPOptionTypeAdvertisementInterval
+	ICMPOptionTypeHomeAgentInformation
+	// RFC3122
+	ICMPOptionTypeSourceAddressList
+	ICMPOptionTypeTargetAddressList
 	// RFC3971
-	ICMPOptionTypeNonce ICMPOptionType = 14
+	ICMPOptionTypeCGA
+	ICMPOptionTypeRSASignature
+	ICMPOptionTypeTimestamp
+	ICMPOptionTypeNonce
+	ICMPOptionTypeTrustAnchor
+	ICMPOptionTypeCertificate
+	// RFC5568
+	ICMPOptionTypeIPAddressPrefix
+	// RFC4068
+	ICMPOptionTypeNewRouterPrefixInformation
+	// RFC5568
+	ICMPOptionTypeLinkLayerAddress
+	ICMPOptionTypeNeighborAdvertisementAcknowledgment
+	// RFC8801
+	ICMPOptionTypePvD
+	_
+	// RFC4140
+	ICMPOptionTypeMAP
+	// RFC4191
+	ICMPOptionTypeRouteInformation
 	// RFC6106
-	ICMPOptionTypeRecursiveDNSServer ICMPOptionType = 25
-	ICMPOptionTypeDNSSearchList      ICMPOptionType = 31
+	ICMPOptionTypeRecursiveDNSServer
+	// RFC5175
+	ICMPOptionTypeFlagsExpansion
+	// RFC5269
+	ICMPOptionTypeHandoverKeyRequest
+	ICMPOptionTypeHandoverKeyReply
+	// RFC5271
+	ICMPOptionTypeHandoverAssistInformation
+	ICMPOptionTypeMobileNodeIdentifier
+	// RFC6106
+	ICMPOptionTypeDNSSearchList
+	// RFC6496
+	ICMPOptionTypeProxySignature
+	// RFC6775
+	ICMPOptionTypeAddressRegistration
+	ICMPOptionTypeSixLoWPANContext
+	ICMPOptionTypeAuthoritativeBorderRouter
+	// RFC7400
+	ICMPOptionTypeSixLoWPANCapabilityIndication
+	// RFC8910
+	ICMPOptionTypeCaptivePortal
+	// RFC8781
+	ICMPOptionTypePREF64
+	// RFC8928
+	ICMPOptionTypeCryptoIDParameters
+	ICMPOptionTypeNDPSignature
+	// RFC9176
+	ICMPOptionTypeResourceDirectoryAddress
+	// RFC4065
+	ICMPOptionTypeCARDRequest ICMPOptionType = 138
+	ICMPOptionTypeCARDReply   ICMPOptionType = 139
+	// RFC9463
+	ICMPOptionTypeEncryptedDNS ICMPOptionType = 144
+	// RFC4727
+	ICMPOptionTypeExperiment1 ICMPOptionType = 253
+	ICMPOptionTypeExperiment2 ICMPOptionType = 254
 )
 
 func (t ICMPOptionType) String() string {
@@ -53,14 +124,90 @@ func (t ICMPOptionType) String() string {
 		return "target link-layer address"
 	case ICMPOptionTypePrefixInformation:
 		return "prefix info"
+	case ICMPOptionTypeRedirectedHeader:
+		return "redirected header"
 	case ICMPOptionTypeMTU:
 		return "mtu"
+	case ICMPOptionTypeNBMAShortcutLimit:
+		return "nbma shortcut limit"
+	case ICMPOptionTypeAdvertisementInterval:
+		return "advertisement interval"
+	case ICMPOptionTypeHomeAgentInformation:
+		return "home agent info"
+	case ICMPOptionTypeSourceAddressList:
+		return "source address list"
+	case ICMPOptionTypeTargetAddressList:
+		return "target address list"
+	case ICMPOptionTypeCGA:
+		return "cga"
+	case ICMPOptionTypeRSASignature:
+		return "rsa signature"
+	case ICMPOptionTypeTimestamp:
+		return "timestamp"
 	case ICMPOptionTypeNonce:
 		return "nonce"
+	case ICMPOptionTypeTrustAnchor:
+		return "trust anchor"
+	case ICMPOptionTypeCertificate:
+		return "certificate"
+	case ICMPOptionTypeIPAddressPrefix:
+		return "ip address/prefix"
+	case ICMPOptionTypeNewRouterPrefixInformation:
+		return "new router prefix info"
+	case ICMPOptionTypeLinkLayerAddress:
+		return "link-layer address"
+	case ICMPOptionTypeNeighborAdvertisementAcknowledgment:
+		return "neighbor advertisement ack"
+	case ICMPOptionTypePvD:
+		return "pvd"
+	case ICMPOptionTypeMAP:
+		return "map"
+	case ICMPOptionTypeRouteInformation:
+		return "route info"
 	case ICMPOptionTypeRecursiveDNSServer:
 		return "rdnss"
+	case ICMPOptionTypeFlagsExpansion:
+		return "flags expansion"
+	case ICMPOptionTypeHandoverKeyRequest:
+		return "handover key request"
+	case ICMPOptionTypeHandoverKeyReply:
+		return "handover key reply"
+	case ICMPOptionTypeHandoverAssistInformation:
+		return "handover assist info"
+	case ICMPOptionTypeMobileNodeIdentifier:
+		return "mobile node identifier"
 	case ICMPOptionTypeDNSSearchList:
 		return "dnssl"
+	case ICMPOptionTypeProxySignature:
+		return "proxy signature"
+	case ICMPOptionTypeAddressRegistration:
+		return "address registration"
+	case ICMPOptionTypeSixLoWPANContext:
+		return "6lowpan context"
+	case ICMPOptionTypeAuthoritativeBorderRouter:
+		return "authoritative border router"
+	case ICMPOptionTypeSixLoWPANCapabilityIndication:
+		return "6lowpan capability indication"
+	case ICMPOptionTypeCaptivePortal:
+		return "captive portal"
+	case ICMPOptionTypePREF64:
+		return "pref64"
+	case ICMPOptionTypeCryptoIDParameters:
+		return "crypto-id parameters"
+	case ICMPOptionTypeNDPSignature:
+		return "ndp signature"
+	case ICMPOptionTypeResourceDirectoryAddress:
+		return "resource directory address"
+	case ICMPOptionTypeCARDRequest:
+		return "card request"
+	case ICMPOptionTypeCARDReply:
+		return "card reply"
+	case ICMPOptionTypeEncryptedDNS:
+		return "encrypted dns"
+	case ICMPOptionTypeExperiment1:
+		return "experiment 1"
+	case ICMPOptionTypeExperiment2:
+		return "experiment 2"
 	default:
 		return "<nil>"
 	}
@@ -74,34 +221,63 @@ type ICMPOption interface {
 	Type() ICMPOptionType
 }
 
-// ICMPOptionUnknown implements generic type for handling unknown options
+// ICMPOptionUnknown implements generic type for handling unknown options. Its
+// fields are marshalled as is, so it can also be used to craft arbitrary or
+// deliberately malformed options
 type ICMPOptionUnknown struct {
-	optionLength uint8
-	optionType   ICMPOptionType
-	body         []byte
+	// OptionLength is the length in units of 8 bytes as sent on the wire
+	OptionLength uint8
+	OptionType   ICMPOptionType
+	// Body holds everything after the type and length fields
+	Body []byte
+}
+
+// NewRawOption returns an ICMPOptionUnknown of given type with given body,
+// padded with zeros up to the next multiple of 8 bytes
+func NewRawOption(t ICMPOptionType, body []byte) (*ICMPOptionUnknown, error) {
+	if 2+len(body) > 255*8 {
+		return nil, fmt.Errorf("body of %d bytes too large to fit in boundaries", len(body))
+	}
+
+	b := append([]byte{}, body...)
+	for (2+len(b))%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return &ICMPOptionUnknown{
+		OptionLength: uint8((2 + len(b)) / 8),
+		OptionType:   t,
+		Body:         b,
+	}, nil
 }
 
 func (o ICMPOptionUnknown) String() string {
-	return fmt.Sprintf("unknown option (%d), length %d (%d)", o.optionType, (o.optionLength * 8), o.optionLength)
+	// assigned types we don't parse are still named
+	name := "unknown"
+	if o.OptionType.String() != "<nil>" {
+		name = o.OptionType.String()
+	}
+
+	return fmt.Sprintf("%s option (%d), length %d (%d)", name, o.OptionType, (int(o.OptionLength) * 8), o.OptionLength)
 }
 
 // Type returns apparent type of this option
 func (o ICMPOptionUnknown) Type() ICMPOptionType {
-	return o.optionType
+	return o.OptionType
 }
 
 // Len returns known length for this option
 func (o ICMPOptionUnknown) Len() uint8 {
-	return o.optionLength
+	return o.OptionLength
 }
 
 // Marshal returns byte slice representing this ICMPOptionUnknown
 func (o ICMPOptionUnknown) Marshal() ([]byte, error) {
 	b := make([]byte, 2)
-	b[0] = uint8(o.optionType)
-	b[1] = o.optionLength
+	b[0] = uint8(o.OptionType)
+	b[1] = o.OptionLength
 
-	b = append(b, o.body...)
+	b = append(b, o.Body...)
 	return b, nil
 }
 
@@ -188,11 +364,18 @@ func (o ICMPOptionTargetLinkLayerAddress) Marshal() ([]byte, error) {
 // ICMPOptionPrefixInformation implements the Prefix Information option
 // as described at https://tools.ietf.org/html/rfc4861#section-4.6.2
 type ICMPOptionPrefixInformation struct {
-	PrefixLength      uint8
-	OnLink            bool
-	Auto              bool
+	PrefixLength uint8
+	OnLink       bool
+	Auto         bool
+	// RouterAddress is the R flag as described at
+	// https://tools.ietf.org/html/rfc6275#section-7.2
+	RouterAddress bool
+	// Reserved1 holds the remaining 5 flag bits, Reserved2 the 32 bit
+	// reserved field so they survive a round trip
+	Reserved1         uint8
 	ValidLifetime     uint32
 	PreferredLifetime uint32
+	Reserved2         uint32
 	Prefix            net.IP
 }
 
@@ -208,6 +391,9 @@ func (o ICMPOptionPrefixInformation) String() string {
 	if o.Auto {
 		f = append(f, "auto")
 	}
+	if o.RouterAddress {
+		f = append(f, "router")
+	}
 	s += fmt.Sprintf("Flags %s, ", f)
 	s += fmt.Sprintf("valid time %ds, ", o.ValidLifetime)
 	s += fmt.Sprintf("pref. time %ds", o.PreferredLifetime)
@@ -215,6 +401,26 @@ func (o ICMPOptionPrefixInformation) String() string {
 	return s
 }
 
+// ValidLifetimeDuration returns the valid lifetime as time.Duration
+func (o ICMPOptionPrefixInformation) ValidLifetimeDuration() time.Duration {
+	return lifetimeToDuration(o.ValidLifetime)
+}
+
+// SetValidLifetime sets the valid lifetime from given time.Duration
+func (o *ICMPOptionPrefixInformation) SetValidLifetime(d time.Duration) {
+	o.ValidLifetime = durationToLifetime(d)
+}
+
+// PreferredLifetimeDuration returns the preferred lifetime as time.Duration
+func (o ICMPOptionPrefixInformation) PreferredLifetimeDuration() time.Duration {
+	return lifetimeToDuration(o.PreferredLifetime)
+}
+
+// SetPreferredLifetime sets the preferred lifetime from given time.Duration
+func (o *ICMPOptionPrefixInformation) SetPreferredLifetime(d time.Duration) {
+	o.PreferredLifetime = durationToLifetime(d)
+}
+
 // Type returns ICMPOptionTypePrefixInformation
 func (o ICMPOptionPrefixInformation) Type() ICMPOptionType {
 	return ICMPOptionTypePrefixInformation
@@ -228,6 +434,10 @@ func (o ICMPOptionPrefixInformation) Len() uint8 {
 
 // Marshal returns byte slice representing this ICMPOptionPrefixInformation
 func (o ICMPOptionPrefixInformation) Marshal() ([]byte, error) {
+	if o.Reserved1 > 0x1f {
+		return nil, fmt.Errorf("reserved flags %d exceed 5 bits", o.Reserved1)
+	}
+
 	b := make([]byte, 16)
 	// option header
 	b[0] = byte(o.Type())
@@ -240,8 +450,13 @@ func (o ICMPOptionPrefixInformation) Marshal() ([]byte, error) {
 	if o.Auto {
 		b[3] ^= 0x40
 	}
+	if o.RouterAddress {
+		b[3] ^= 0x20
+	}
+	b[3] ^= o.Reserved1
 	binary.BigEndian.PutUint32(b[4:8], uint32(o.ValidLifetime))
 	binary.BigEndian.PutUint32(b[8:12], uint32(o.PreferredLifetime))
+	binary.BigEndian.PutUint32(b[12:16], o.Reserved2)
 	b = append(b, o.Prefix...)
 
 	return b, nil
@@ -285,6 +500,98 @@ func (o *ICMPOptionMTU) Marshal() ([]byte, error) {
 	return b, nil
 }
 
+// ICMPOptionRedirectedHeader implements the Redirected Header option as
+// described at https://tools.ietf.org/html/rfc4861#section-4.6.3
+type ICMPOptionRedirectedHeader struct {
+	// Packet is the start of the redirected packet, including its IPv6
+	// header. Parsed options keep the padding that follows it
+	Packet []byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionRedirectedHeader) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf(": %d bytes of packet", len(o.Packet))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Type() ICMPOptionType {
+	return ICMPOptionTypeRedirectedHeader
+}
+
+// Len returns the length in units of 8 bytes of ICMPOptionRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Len() uint8 {
+	// header and reserved bytes take up the first 8 bytes
+	return uint8((8 + len(o.Packet) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionRedirectedHeader
+func (o ICMPOptionRedirectedHeader) Marshal() ([]byte, error) {
+	if 8+len(o.Packet) > 255*8 {
+		return nil, fmt.Errorf("packet of %d bytes too large to fit in boundaries", len(o.Packet))
+	}
+
+	// option header
+	b := make([]byte, 8, int(o.Len())*8)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// b[2:8] = reserved
+	b = append(b, o.Packet...)
+	// pad up to the next multiple of 8 bytes
+	b = b[:cap(b)]
+
+	return b, nil
+}
+
+// ICMPOptionTimestamp implements the Timestamp option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.3.1
+type ICMPOptionTimestamp struct {
+	// Timestamp is sent with a precision of 1/64K seconds
+	Timestamp time.Time
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionTimestamp) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d)", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf(": %s", o.Timestamp.UTC().Format(time.RFC3339Nano))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeTimestamp
+func (o ICMPOptionTimestamp) Type() ICMPOptionType {
+	return ICMPOptionTypeTimestamp
+}
+
+// Len returns the length in units of 8 bytes of ICMPOptionTimestamp
+func (o ICMPOptionTimestamp) Len() uint8 {
+	// Timestamp options are always 2
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOptionTimestamp
+func (o ICMPOptionTimestamp) Marshal() ([]byte, error) {
+	sec := o.Timestamp.Unix()
+	if sec < 0 || sec >= 1<<48 {
+		return nil, fmt.Errorf("timestamp %s too large to fit in boundaries", o.Timestamp)
+	}
+
+	// option header
+	b := make([]byte, 16)
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// b[2:8] = reserved
+	// 48 bits of seconds and 16 bits of fractions since the epoch
+	frac := uint64(o.Timestamp.Nanosecond()) << 16 / uint64(time.Second)
+	binary.BigEndian.PutUint64(b[8:16], uint64(sec)<<16|frac)
+
+	return b, nil
+}
+
 // ICMPOptionNonce implements the Nonce option as described at
 // https://tools.ietf.org/html/rfc3971#section-5.3.2
 type ICMPOptionNonce struct {
@@ -334,217 +641,1685 @@ func (o ICMPOptionNonce) Marshal() ([]byte, error) {
 	return b, nil
 }
 
-// ICMPOptionRecursiveDNSServer implements the Recursive DNS Server option
-// as described at https://tools.ietf.org/html/rfc6106#section-5.1
-type ICMPOptionRecursiveDNSServer struct {
-	Lifetime uint32
-	Servers  []net.IP
-}
-
-// Len returns the length in bytes of ICMPOptionRecursiveDNSServer
-func (o ICMPOptionRecursiveDNSServer) Len() uint8 {
-	return 1 + uint8(len(o.Servers)*2)
+// ICMPOptionCGA implements the CGA option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.1
+type ICMPOptionCGA struct {
+	Parameters CGAParameters
 }
 
 // String implements the String method of ICMPOption interface.
-func (o ICMPOptionRecursiveDNSServer) String() string {
+func (o ICMPOptionCGA) String() string {
 	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
-	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
-	s += fmt.Sprintf("lifetime %ds, ", o.Lifetime)
-	for _, a := range o.Servers {
-		s += fmt.Sprintf("addr: %s ", a.String())
-	}
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("modifier %x, ", o.Parameters.Modifier)
+	s += fmt.Sprintf("prefix %s, ", o.Parameters.Prefix())
+	s += fmt.Sprintf("collision count %d, ", o.Parameters.CollisionCount)
+	s += fmt.Sprintf("public key length %d", len(o.Parameters.PublicKey))
 
-	return strings.TrimSuffix(s, " ")
+	return s
 }
 
-// Type returns ICMPOptionTypeRecursiveDNSServer
-func (o ICMPOptionRecursiveDNSServer) Type() ICMPOptionType {
-	return ICMPOptionTypeRecursiveDNSServer
+// Type returns ICMPOptionTypeCGA
+func (o ICMPOptionCGA) Type() ICMPOptionType {
+	return ICMPOptionTypeCGA
 }
 
-// Marshal returns byte slice representing this ICMPOptionRecursiveDNSServer
-func (o ICMPOptionRecursiveDNSServer) Marshal() ([]byte, error) {
-	b := make([]byte, 8)
+// Len returns the length in bytes of ICMPOptionCGA
+func (o ICMPOptionCGA) Len() uint8 {
+	// header, pad length and reserved field take up 4 bytes,
+	// the parameters and padding fill up to the next multiple of 8
+	params, err := o.Parameters.Marshal()
+	if err != nil {
+		return 0
+	}
+
+	return uint8((4 + len(params) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionCGA
+func (o ICMPOptionCGA) Marshal() ([]byte, error) {
+	params, err := o.Parameters.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if 4+len(params) > 255*8 {
+		return nil, fmt.Errorf("cga parameters of %d bytes too large to fit in boundaries", len(params))
+	}
+
+	b := make([]byte, 4)
 	// option header
 	b[0] = byte(o.Type())
 	b[1] = byte(o.Len())
 	// option fields
-	binary.BigEndian.PutUint32(b[4:8], uint32(o.Lifetime))
-	for _, s := range o.Servers {
-		b = append(b, s...)
+	b[2] = byte(int(o.Len())*8 - 4 - len(params))
+	// b[3] = reserved
+	b = append(b, params...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
 	}
 
 	return b, nil
 }
 
-// ICMPOptionDNSSearchList implements the DNS Search List option
-// as described at https://tools.ietf.org/html/rfc6106#section-5.2
-type ICMPOptionDNSSearchList struct {
-	Lifetime    uint32
-	DomainNames []string
+// ICMPOptionRSASignature implements the RSA Signature option as described at
+// https://tools.ietf.org/html/rfc3971#section-5.2
+type ICMPOptionRSASignature struct {
+	KeyHash [16]byte
+	// Signature holds the digital signature; when parsed from the wire it
+	// also contains any trailing padding, since the option itself does not
+	// encode where the signature ends
+	Signature []byte
 }
 
 // String implements the String method of ICMPOption interface.
-func (o ICMPOptionDNSSearchList) String() string {
+func (o ICMPOptionRSASignature) String() string {
 	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
-	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
-	s += fmt.Sprintf("lifetime %ds, ", o.Lifetime)
-	s += fmt.Sprintf("domain(s) %s", strings.Join(o.DomainNames, ", "))
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("key hash %x, ", o.KeyHash)
+	s += fmt.Sprintf("signature length %d", len(o.Signature))
 
 	return s
 }
 
-// Type returns ICMPOptionTypeDNSSearchList
-func (o ICMPOptionDNSSearchList) Type() ICMPOptionType {
-	return ICMPOptionTypeDNSSearchList
+// Type returns ICMPOptionTypeRSASignature
+func (o ICMPOptionRSASignature) Type() ICMPOptionType {
+	return ICMPOptionTypeRSASignature
 }
 
-// Len returns the length in bytes of ICMPOptionDNSSearchList
-func (o ICMPOptionDNSSearchList) Len() uint8 {
-	return 2 + uint8(len(o.DomainNames)*2)
+// Len returns the length in bytes of ICMPOptionRSASignature
+func (o ICMPOptionRSASignature) Len() uint8 {
+	// header, reserved field and key hash take up 20 bytes,
+	// the signature and padding fill up to the next multiple of 8
+	return uint8((20 + len(o.Signature) + 7) / 8)
 }
 
-// Marshal returns byte slice representing this ICMPOptionDNSSearchList
-func (o ICMPOptionDNSSearchList) Marshal() ([]byte, error) {
-	b := make([]byte, 8)
+// Marshal returns byte slice representing this ICMPOptionRSASignature
+func (o ICMPOptionRSASignature) Marshal() ([]byte, error) {
+	if 20+len(o.Signature) > 255*8 {
+		return nil, fmt.Errorf("signature of %d bytes too large to fit in boundaries", len(o.Signature))
+	}
+
+	b := make([]byte, 4)
 	// option header
 	b[0] = byte(o.Type())
 	b[1] = byte(o.Len())
 	// option fields
-	binary.BigEndian.PutUint32(b[4:8], uint32(o.Lifetime))
-	b = append(b, encDomainName(o.DomainNames)...)
+	// b[2:4] = reserved
+	b = append(b, o.KeyHash[:]...)
+	b = append(b, o.Signature...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
 
 	return b, nil
 }
 
-func parseOptions(b []byte) ([]ICMPOption, error) {
-	// empty container
-	var icmpOptions = []ICMPOption{}
+// TrustAnchorNameType describes the Name Type field of the Trust Anchor option
+// as described at https://tools.ietf.org/html/rfc3971#section-6.4.3 and
+// https://tools.ietf.org/html/rfc6495#section-3
+type TrustAnchorNameType uint8
 
-	for {
-		// left over bytes are less than minimum option length
-		if len(b) < 8 {
-			break
-		}
+// types currently defined
+const (
+	TrustAnchorNameTypeDER TrustAnchorNameType = iota + 1
+	TrustAnchorNameTypeFQDN
+	// RFC6495
+	TrustAnchorNameTypeSHA1SKI
+	TrustAnchorNameTypeSHA224SKI
+	TrustAnchorNameTypeSHA256SKI
+	TrustAnchorNameTypeSHA384SKI
+	TrustAnchorNameTypeSHA512SKI
+)
 
-		// beginning of header specifies type and length
-		optionType := ICMPOptionType(b[0])
-		optionLength := uint8(b[1])
-		// check if we got enought data for at least as long as optionLength specifies
-		if uint8(len(b)) < (optionLength * 8) {
-			return nil, fmt.Errorf("too few bytes received: %d while at least %d expected", len(b), (optionLength * 8))
-		}
+func (typ TrustAnchorNameType) String() string {
+	switch typ {
+	case TrustAnchorNameTypeDER:
+		return "der"
+	case TrustAnchorNameTypeFQDN:
+		return "fqdn"
+	case TrustAnchorNameTypeSHA1SKI:
+		return "sha-1 ski"
+	case TrustAnchorNameTypeSHA224SKI:
+		return "sha-224 ski"
+	case TrustAnchorNameTypeSHA256SKI:
+		return "sha-256 ski"
+	case TrustAnchorNameTypeSHA384SKI:
+		return "sha-384 ski"
+	case TrustAnchorNameTypeSHA512SKI:
+		return "sha-512 ski"
+	default:
+		return "<nil>"
+	}
+}
 
-		var currentOption ICMPOption
+// hash returns the hash of SKI name types, or 0 for others
+func (typ TrustAnchorNameType) hash() crypto.Hash {
+	switch typ {
+	case TrustAnchorNameTypeSHA1SKI:
+		return crypto.SHA1
+	case TrustAnchorNameTypeSHA224SKI:
+		return crypto.SHA224
+	case TrustAnchorNameTypeSHA256SKI:
+		return crypto.SHA256
+	case TrustAnchorNameTypeSHA384SKI:
+		return crypto.SHA384
+	case TrustAnchorNameTypeSHA512SKI:
+		return crypto.SHA512
+	default:
+		return 0
+	}
+}
 
-		switch optionType {
-		case ICMPOptionTypeSourceLinkLayerAddress:
-			if optionLength != 1 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
-			}
+// ICMPOptionTrustAnchor implements the Trust Anchor option as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.3
+type ICMPOptionTrustAnchor struct {
+	NameType TrustAnchorNameType
+	// Name holds either a DER encoded X.501 name or a FQDN encoded as
+	// described in RFC 1035, depending on NameType
+	Name []byte
+}
 
-			currentOption = &ICMPOptionSourceLinkLayerAddress{
-				LinkLayerAddress: b[2:8],
-			}
+// NewTrustAnchorDER returns an ICMPOptionTrustAnchor for given X.501 name
+func NewTrustAnchorDER(name pkix.Name) (*ICMPOptionTrustAnchor, error) {
+	der, err := asn1.Marshal(name.ToRDNSequence())
+	if err != nil {
+		return nil, err
+	}
 
-		case ICMPOptionTypeTargetLinkLayerAddress:
-			if optionLength != 1 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
-			}
+	return &ICMPOptionTrustAnchor{
+		NameType: TrustAnchorNameTypeDER,
+		Name:     der,
+	}, nil
+}
 
-			currentOption = &ICMPOptionTargetLinkLayerAddress{
+// NewTrustAnchorFQDN returns an ICMPOptionTrustAnchor for given FQDN
+func NewTrustAnchorFQDN(fqdn string) (*ICMPOptionTrustAnchor, error) {
+	name, err := encLabels(fqdn)
+	if err != nil {
+		return nil, err
+	}
 
-				LinkLayerAddress: b[2:8],
-			}
+	return &ICMPOptionTrustAnchor{
+		NameType: TrustAnchorNameTypeFQDN,
+		Name:     name,
+	}, nil
+}
 
-		case ICMPOptionTypePrefixInformation:
-			if optionLength != 4 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should be 4", optionType, optionType, optionLength)
-			}
+// NewTrustAnchorSKI returns an ICMPOptionTrustAnchor naming the trust anchor
+// of cert by the subject key identifier of name type typ, which is the hash
+// of its public key as described at https://tools.ietf.org/html/rfc6495
+func NewTrustAnchorSKI(typ TrustAnchorNameType, cert *x509.Certificate) (*ICMPOptionTrustAnchor, error) {
+	if typ.hash() == 0 {
+		return nil, fmt.Errorf("trust anchor name type %s is no subject key identifier", typ)
+	}
 
-			currentOption = &ICMPOptionPrefixInformation{
+	ski, err := subjectKeyIdentifier(typ.hash(), cert)
+	if err != nil {
+		return nil, err
+	}
 
-				PrefixLength:      uint8(b[2]),
-				OnLink:            (b[3]&0x80 > 0),
-				Auto:              (b[3]&0x40 > 0),
-				ValidLifetime:     binary.BigEndian.Uint32(b[4:8]),
-				PreferredLifetime: binary.BigEndian.Uint32(b[8:12]),
-				Prefix:            net.IP(b[16:32]),
-			}
+	return &ICMPOptionTrustAnchor{
+		NameType: typ,
+		Name:     ski,
+	}, nil
+}
 
-		case ICMPOptionTypeMTU:
-			if optionLength != 1 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
-			}
+// subjectKeyIdentifier returns the hash of the subject public key of cert
+func subjectKeyIdentifier(hash crypto.Hash, cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
 
-			currentOption = &ICMPOptionMTU{
+	h := hash.New()
+	h.Write(spki.PublicKey.RightAlign())
+	return h.Sum(nil), nil
+}
 
-				MTU: binary.BigEndian.Uint32(b[4:8]),
+// Matches reports whether this ICMPOptionTrustAnchor names trust anchor cert
+func (o ICMPOptionTrustAnchor) Matches(cert *x509.Certificate) bool {
+	switch o.NameType {
+	case TrustAnchorNameTypeDER:
+		return bytes.Equal(o.Name, cert.RawSubject)
+	case TrustAnchorNameTypeFQDN:
+		fqdn, err := o.FQDN()
+		if err != nil {
+			return false
+		}
+		for _, n := range cert.DNSNames {
+			if strings.EqualFold(strings.TrimSuffix(fqdn, "."), strings.TrimSuffix(n, ".")) {
+				return true
 			}
+		}
+		return false
+	}
 
-		case ICMPOptionTypeNonce:
-			if optionLength != 1 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
-			}
+	if o.NameType.hash() == 0 {
+		return false
+	}
+	ski, err := subjectKeyIdentifier(o.NameType.hash(), cert)
+	return err == nil && bytes.Equal(o.Name, ski)
+}
 
-			currentOption = &ICMPOptionNonce{}
+// DistinguishedName returns the X.501 name of this ICMPOptionTrustAnchor or
+// an error if it does not contain a DER encoded name
+func (o ICMPOptionTrustAnchor) DistinguishedName() (*pkix.Name, error) {
+	if o.NameType != TrustAnchorNameTypeDER {
+		return nil, fmt.Errorf("trust anchor name type is %s, not %s", o.NameType, TrustAnchorNameTypeDER)
+	}
 
-			n := make([]byte, 2)
-			n = append(n, b[2:8]...)
-			currentOption.(*ICMPOptionNonce).Nonce = binary.BigEndian.Uint64(n)
+	var rdns pkix.RDNSequence
+	rest, err := asn1.Unmarshal(o.Name, &rdns)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after trust anchor name")
+	}
 
-		case ICMPOptionTypeRecursiveDNSServer:
-			if optionLength < 3 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 3", optionType, optionType, optionLength)
-			}
+	name := &pkix.Name{}
+	name.FillFromRDNSequence(&rdns)
+	return name, nil
+}
 
-			currentOption = &ICMPOptionRecursiveDNSServer{
+// FQDN returns the domain name of this ICMPOptionTrustAnchor or an error if
+// it does not contain a valid FQDN
+func (o ICMPOptionTrustAnchor) FQDN() (string, error) {
+	if o.NameType != TrustAnchorNameTypeFQDN {
+		return "", fmt.Errorf("trust anchor name type is %s, not %s", o.NameType, TrustAnchorNameTypeFQDN)
+	}
 
-				Lifetime: binary.BigEndian.Uint32(b[4:8]),
-			}
+	name, n, err := decLabels(o.Name)
+	if err != nil || n != len(o.Name) {
+		return "", errors.New("trust anchor name is not a valid fqdn")
+	}
 
-			var servers []net.IP
-			for i := 8; i < (int(optionLength) * 8); i += 16 {
-				servers = append(servers, net.IP(b[i:(i+16)]))
-			}
+	return name, nil
+}
 
-			currentOption.(*ICMPOptionRecursiveDNSServer).Servers = servers
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionTrustAnchor) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("name type %s", o.NameType)
+	switch o.NameType {
+	case TrustAnchorNameTypeDER:
+		if n, err := o.DistinguishedName(); err == nil {
+			s += fmt.Sprintf(", name %s", n)
+		}
+	case TrustAnchorNameTypeFQDN:
+		if n, err := o.FQDN(); err == nil {
+			s += fmt.Sprintf(", name %s", n)
+		}
+	default:
+		if o.NameType.hash() != 0 {
+			s += fmt.Sprintf(", ski %x", o.Name)
+		}
+	}
 
-		case ICMPOptionTypeDNSSearchList:
-			if optionLength < 4 {
-				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 4", optionType, optionType, optionLength)
-			}
+	return s
+}
 
-			currentOption = &ICMPOptionDNSSearchList{
+// Type returns ICMPOptionTypeTrustAnchor
+func (o ICMPOptionTrustAnchor) Type() ICMPOptionType {
+	return ICMPOptionTypeTrustAnchor
+}
 
-				Lifetime: binary.BigEndian.Uint32(b[4:8]),
-			}
+// Len returns the length in bytes of ICMPOptionTrustAnchor
+func (o ICMPOptionTrustAnchor) Len() uint8 {
+	// header, name type and pad length take up 4 bytes,
+	// the name and padding fill up to the next multiple of 8
+	return uint8((4 + len(o.Name) + 7) / 8)
+}
 
-			currentOption.(*ICMPOptionDNSSearchList).DomainNames = decDomainName(b[8:(optionLength * 8)])
+// Marshal returns byte slice representing this ICMPOptionTrustAnchor
+func (o ICMPOptionTrustAnchor) Marshal() ([]byte, error) {
+	if 4+len(o.Name) > 255*8 {
+		return nil, fmt.Errorf("name of %d bytes too large to fit in boundaries", len(o.Name))
+	}
 
-		default:
-			currentOption = &ICMPOptionUnknown{
-				optionLength: optionLength,
-				optionType:   optionType,
-				body:         b[2:(optionLength * 8)],
-			}
-		}
+	b := make([]byte, 4)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.NameType)
+	b[3] = byte(int(o.Len())*8 - 4 - len(o.Name))
+	b = append(b, o.Name...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
 
-		if optionLength != currentOption.Len() {
-			return nil, fmt.Errorf("length mismatch while parsing %s: %d should be %d", optionType, currentOption.Len(), optionLength)
+// CertificateType describes the Cert Type field of the Certificate option
+// as described at https://tools.ietf.org/html/rfc3971#section-6.4.2
+type CertificateType uint8
+
+// types currently defined
+const (
+	CertificateTypeX509 CertificateType = iota + 1
+)
+
+func (typ CertificateType) String() string {
+	switch typ {
+	case CertificateTypeX509:
+		return "x509v3"
+	default:
+		return "<nil>"
+	}
+}
+
+// ICMPOptionCertificate implements the Certificate option as described at
+// https://tools.ietf.org/html/rfc3971#section-6.4.2
+type ICMPOptionCertificate struct {
+	CertType CertificateType
+	// Certificate holds the DER encoded certificate
+	Certificate []byte
+}
+
+// NewCertificateOption returns an ICMPOptionCertificate for given certificate
+func NewCertificateOption(cert *x509.Certificate) *ICMPOptionCertificate {
+	return &ICMPOptionCertificate{
+		CertType:    CertificateTypeX509,
+		Certificate: cert.Raw,
+	}
+}
+
+// X509Certificate returns the parsed certificate of this ICMPOptionCertificate
+func (o ICMPOptionCertificate) X509Certificate() (*x509.Certificate, error) {
+	if o.CertType != CertificateTypeX509 {
+		return nil, fmt.Errorf("certificate type %d not supported", o.CertType)
+	}
+
+	return x509.ParseCertificate(o.Certificate)
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionCertificate) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("cert type %s", o.CertType)
+	if c, err := o.X509Certificate(); err == nil {
+		s += fmt.Sprintf(", subject %s", c.Subject)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypeCertificate
+func (o ICMPOptionCertificate) Type() ICMPOptionType {
+	return ICMPOptionTypeCertificate
+}
+
+// Len returns the length in bytes of ICMPOptionCertificate
+func (o ICMPOptionCertificate) Len() uint8 {
+	// header, cert type and reserved field take up 4 bytes,
+	// the certificate and padding fill up to the next multiple of 8
+	return uint8((4 + len(o.Certificate) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionCertificate
+func (o ICMPOptionCertificate) Marshal() ([]byte, error) {
+	if 4+len(o.Certificate) > 255*8 {
+		return nil, fmt.Errorf("certificate of %d bytes too large to fit in boundaries", len(o.Certificate))
+	}
+
+	b := make([]byte, 4)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.CertType)
+	// b[3] = reserved
+	b = append(b, o.Certificate...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+// LinkLayerAddressOptionCode describes the Option-Code field of the
+// Link-Layer Address option as described at https://tools.ietf.org/html/rfc5568#section-6.4.3
+type LinkLayerAddressOptionCode uint8
+
+// types currently defined
+const (
+	LinkLayerAddressOptionCodeWildcard LinkLayerAddressOptionCode = iota
+	LinkLayerAddressOptionCodeNewAccessPoint
+	LinkLayerAddressOptionCodeMobileNode
+	LinkLayerAddressOptionCodeNewAccessRouter
+	LinkLayerAddressOptionCodeSource
+	LinkLayerAddressOptionCodeCurrentInterface
+	LinkLayerAddressOptionCodeNoPrefix
+	LinkLayerAddressOptionCodeNoFastHandover
+)
+
+func (typ LinkLayerAddressOptionCode) String() string {
+	switch typ {
+	case LinkLayerAddressOptionCodeWildcard:
+		return "wildcard"
+	case LinkLayerAddressOptionCodeNewAccessPoint:
+		return "new access point"
+	case LinkLayerAddressOptionCodeMobileNode:
+		return "mobile node"
+	case LinkLayerAddressOptionCodeNewAccessRouter:
+		return "new access router"
+	case LinkLayerAddressOptionCodeSource:
+		return "source"
+	case LinkLayerAddressOptionCodeCurrentInterface:
+		return "current interface"
+	case LinkLayerAddressOptionCodeNoPrefix:
+		return "no prefix"
+	case LinkLayerAddressOptionCodeNoFastHandover:
+		return "no fast handover"
+	default:
+		return fmt.Sprintf("code %d", uint8(typ))
+	}
+}
+
+// ICMPOptionLinkLayerAddress implements the FMIPv6 Link-Layer Address option
+// as described at https://tools.ietf.org/html/rfc5568#section-6.4.3
+type ICMPOptionLinkLayerAddress struct {
+	OptionCode LinkLayerAddressOptionCode
+	// LinkLayerAddress is of variable length; when parsed from an option
+	// of length 2 it is assumed to be a 6 byte IEEE 802 address, otherwise
+	// it contains all bytes up to the end of the option
+	LinkLayerAddress net.HardwareAddr
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionLinkLayerAddress) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("%s, ", o.OptionCode)
+	s += fmt.Sprintf("%s", o.LinkLayerAddress)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Type() ICMPOptionType {
+	return ICMPOptionTypeLinkLayerAddress
+}
+
+// Len returns the length in bytes of ICMPOptionLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Len() uint8 {
+	// header and option code take up 3 bytes,
+	// the address and padding fill up to the next multiple of 8
+	return uint8((3 + len(o.LinkLayerAddress) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionLinkLayerAddress
+func (o ICMPOptionLinkLayerAddress) Marshal() ([]byte, error) {
+	if 3+len(o.LinkLayerAddress) > 255*8 {
+		return nil, fmt.Errorf("link-layer address of %d bytes too large to fit in boundaries", len(o.LinkLayerAddress))
+	}
+
+	b := make([]byte, 3)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.OptionCode)
+	b = append(b, o.LinkLayerAddress...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+// ICMPOptionPvD implements the Provisioning Domain option as described at
+// https://tools.ietf.org/html/rfc8801#section-3.1
+type ICMPOptionPvD struct {
+	optionContainer
+	HTTP           bool
+	Legacy         bool
+	Delay          uint8
+	SequenceNumber uint16
+	FQDN           string
+	// RouterAdvertisement holds the embedded Router Advertisement header
+	// and is only present when the R flag is set
+	RouterAdvertisement *ICMPRouterAdvertisement
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionPvD) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("%s, ", o.FQDN)
+	s += fmt.Sprintf("seq %d, ", o.SequenceNumber)
+	f := []string{}
+	if o.HTTP {
+		f = append(f, "http")
+	}
+	if o.Legacy {
+		f = append(f, "legacy")
+	}
+	if o.RouterAdvertisement != nil {
+		f = append(f, "ra")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("delay %d", o.Delay)
+	for _, no := range o.Options {
+		s += fmt.Sprintf("\n      %s", no)
+	}
+
+	return s
+}
+
+// Type returns ICMPOptionTypePvD
+func (o ICMPOptionPvD) Type() ICMPOptionType {
+	return ICMPOptionTypePvD
+}
+
+// Len returns the length in bytes of ICMPOptionPvD
+func (o ICMPOptionPvD) Len() uint8 {
+	// length depends on the FQDN and all nested options
+	m, err := o.Marshal()
+	if err != nil {
+		return 0
+	}
+
+	return uint8(len(m) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionPvD
+func (o ICMPOptionPvD) Marshal() ([]byte, error) {
+	if o.Delay > 15 {
+		return nil, fmt.Errorf("delay %d exceeds 15", o.Delay)
+	}
+
+	b := make([]byte, 6)
+	// option header
+	b[0] = byte(o.Type())
+	// b[1] = length, set below
+	// option fields
+	if o.HTTP {
+		b[2] ^= 0x80
+	}
+	if o.Legacy {
+		b[2] ^= 0x40
+	}
+	if o.RouterAdvertisement != nil {
+		b[2] ^= 0x20
+	}
+	b[3] = o.Delay
+	binary.BigEndian.PutUint16(b[4:6], o.SequenceNumber)
+	fqdn, err := encLabels(o.FQDN)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, fqdn...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	// embedded router advertisement header, without its options
+	if o.RouterAdvertisement != nil {
+		ra := *o.RouterAdvertisement
+		ra.Options = nil
+		rm, err := ra.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, rm...)
+	}
+	// nested options
+	for _, no := range o.Options {
+		if no.Type() == ICMPOptionTypePvD {
+			return nil, errors.New("pvd option may not contain another pvd option")
+		}
+	}
+	om, err := o.Options.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, om...)
+
+	if len(b) > 255*8 {
+		return nil, fmt.Errorf("pvd option of %d bytes too large to fit in boundaries", len(b))
+	}
+	b[1] = byte(len(b) / 8)
+
+	return b, nil
+}
+
+// ICMPOptionRouteInformation implements the Route Information option
+// as described at https://tools.ietf.org/html/rfc4191#section-2.3
+type ICMPOptionRouteInformation struct {
+	PrefixLength  uint8
+	Preference    RouterPreferenceField
+	RouteLifetime uint32
+	Prefix        net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionRouteInformation) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("%s/%d, ", o.Prefix, o.PrefixLength)
+	s += fmt.Sprintf("pref %s, ", o.Preference)
+	s += fmt.Sprintf("lifetime %ds", o.RouteLifetime)
+
+	return s
+}
+
+// RouteLifetimeDuration returns the route lifetime as time.Duration
+func (o ICMPOptionRouteInformation) RouteLifetimeDuration() time.Duration {
+	return lifetimeToDuration(o.RouteLifetime)
+}
+
+// SetRouteLifetime sets the route lifetime from given time.Duration
+func (o *ICMPOptionRouteInformation) SetRouteLifetime(d time.Duration) {
+	o.RouteLifetime = durationToLifetime(d)
+}
+
+// Type returns ICMPOptionTypeRouteInformation
+func (o ICMPOptionRouteInformation) Type() ICMPOptionType {
+	return ICMPOptionTypeRouteInformation
+}
+
+// Len returns the length in bytes of ICMPOptionRouteInformation
+func (o ICMPOptionRouteInformation) Len() uint8 {
+	// only as much of the prefix as the prefix length requires is sent
+	switch {
+	case o.PrefixLength == 0:
+		return 1
+	case o.PrefixLength <= 64:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Marshal returns byte slice representing this ICMPOptionRouteInformation
+func (o ICMPOptionRouteInformation) Marshal() ([]byte, error) {
+	if o.PrefixLength > 128 {
+		return nil, fmt.Errorf("prefix length %d exceeds 128", o.PrefixLength)
+	}
+
+	prefix := o.Prefix.To16()
+	if prefix == nil {
+		prefix = make(net.IP, net.IPv6len)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = o.PrefixLength
+	// medium is 00, which is default
+	switch o.Preference {
+	case RouterPreferenceLow:
+		b[3] ^= 0x18
+	case RouterPreferenceHigh:
+		b[3] ^= 0x08
+	}
+	binary.BigEndian.PutUint32(b[4:8], o.RouteLifetime)
+	b = append(b, prefix[:(int(o.Len())-1)*8]...)
+
+	return b, nil
+}
+
+// ICMPOptionRecursiveDNSServer implements the Recursive DNS Server option
+// as described at https://tools.ietf.org/html/rfc6106#section-5.1
+type ICMPOptionRecursiveDNSServer struct {
+	Lifetime uint32
+	Servers  []net.IP
+}
+
+// Len returns the length in bytes of ICMPOptionRecursiveDNSServer
+func (o ICMPOptionRecursiveDNSServer) Len() uint8 {
+	// every server takes up 2, which leaves room for at most 127 servers
+	return uint8(1 + len(o.Servers)*2)
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionRecursiveDNSServer) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("lifetime %ds, ", o.Lifetime)
+	for _, a := range o.Servers {
+		s += fmt.Sprintf("addr: %s ", a.String())
+	}
+
+	return strings.TrimSuffix(s, " ")
+}
+
+// LifetimeDuration returns the lifetime as time.Duration
+func (o ICMPOptionRecursiveDNSServer) LifetimeDuration() time.Duration {
+	return lifetimeToDuration(o.Lifetime)
+}
+
+// SetLifetime sets the lifetime from given time.Duration
+func (o *ICMPOptionRecursiveDNSServer) SetLifetime(d time.Duration) {
+	o.Lifetime = durationToLifetime(d)
+}
+
+// Type returns ICMPOptionTypeRecursiveDNSServer
+func (o ICMPOptionRecursiveDNSServer) Type() ICMPOptionType {
+	return ICMPOptionTypeRecursiveDNSServer
+}
+
+// Marshal returns byte slice representing this ICMPOptionRecursiveDNSServer
+func (o ICMPOptionRecursiveDNSServer) Marshal() ([]byte, error) {
+	if len(o.Servers) == 0 {
+		return nil, errors.New("rdnss option requires at least one server")
+	}
+	if len(o.Servers) > 127 {
+		return nil, fmt.Errorf("%d servers too many to fit in boundaries", len(o.Servers))
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint32(b[4:8], uint32(o.Lifetime))
+	for _, s := range o.Servers {
+		if s.To16() == nil || s.To4() != nil {
+			return nil, fmt.Errorf("server %s is not an IPv6 address", s)
+		}
+
+		b = append(b, s.To16()...)
+	}
+
+	return b, nil
+}
+
+// ICMPOptionHandoverKeyRequest implements the Handover Key Request option
+// as described at https://tools.ietf.org/html/rfc5269#section-5.2.1
+type ICMPOptionHandoverKeyRequest struct {
+	AlgorithmType uint8
+	PublicKey     []byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionHandoverKeyRequest) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("algorithm type %d, ", o.AlgorithmType)
+	s += fmt.Sprintf("public key length %d", len(o.PublicKey))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeHandoverKeyRequest
+func (o ICMPOptionHandoverKeyRequest) Type() ICMPOptionType {
+	return ICMPOptionTypeHandoverKeyRequest
+}
+
+// Len returns the length in bytes of ICMPOptionHandoverKeyRequest
+func (o ICMPOptionHandoverKeyRequest) Len() uint8 {
+	// header, pad length and algorithm type take up 4 bytes,
+	// the public key and padding fill up to the next multiple of 8
+	return uint8((4 + len(o.PublicKey) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionHandoverKeyRequest
+func (o ICMPOptionHandoverKeyRequest) Marshal() ([]byte, error) {
+	if 4+len(o.PublicKey) > 255*8 {
+		return nil, fmt.Errorf("public key of %d bytes too large to fit in boundaries", len(o.PublicKey))
+	}
+	if o.AlgorithmType > 15 {
+		return nil, fmt.Errorf("algorithm type %d exceeds 15", o.AlgorithmType)
+	}
+
+	b := make([]byte, 4)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(int(o.Len())*8 - 4 - len(o.PublicKey))
+	b[3] = o.AlgorithmType << 4
+	b = append(b, o.PublicKey...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+// ICMPOptionHandoverKeyReply implements the Handover Key Reply option
+// as described at https://tools.ietf.org/html/rfc5269#section-5.2.2
+type ICMPOptionHandoverKeyReply struct {
+	AlgorithmType uint8
+	KeyLifetime   uint16
+	EncryptedKey  []byte
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionHandoverKeyReply) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (int(o.Len()) * 8), o.Len())
+	s += fmt.Sprintf("algorithm type %d, ", o.AlgorithmType)
+	s += fmt.Sprintf("lifetime %ds, ", o.KeyLifetime)
+	s += fmt.Sprintf("encrypted key length %d", len(o.EncryptedKey))
+
+	return s
+}
+
+// Type returns ICMPOptionTypeHandoverKeyReply
+func (o ICMPOptionHandoverKeyReply) Type() ICMPOptionType {
+	return ICMPOptionTypeHandoverKeyReply
+}
+
+// Len returns the length in bytes of ICMPOptionHandoverKeyReply
+func (o ICMPOptionHandoverKeyReply) Len() uint8 {
+	// header, pad length, algorithm type and key lifetime take up 6 bytes,
+	// the encrypted key and padding fill up to the next multiple of 8
+	return uint8((6 + len(o.EncryptedKey) + 7) / 8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionHandoverKeyReply
+func (o ICMPOptionHandoverKeyReply) Marshal() ([]byte, error) {
+	if 6+len(o.EncryptedKey) > 255*8 {
+		return nil, fmt.Errorf("encrypted key of %d bytes too large to fit in boundaries", len(o.EncryptedKey))
+	}
+	if o.AlgorithmType > 15 {
+		return nil, fmt.Errorf("algorithm type %d exceeds 15", o.AlgorithmType)
+	}
+
+	b := make([]byte, 6)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(int(o.Len())*8 - 6 - len(o.EncryptedKey))
+	b[3] = o.AlgorithmType << 4
+	binary.BigEndian.PutUint16(b[4:6], o.KeyLifetime)
+	b = append(b, o.EncryptedKey...)
+	// pad until multiple of 8
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+// ICMPOptionDNSSearchList implements the DNS Search List option
+// as described at https://tools.ietf.org/html/rfc6106#section-5.2
+type ICMPOptionDNSSearchList struct {
+	Lifetime    uint32
+	DomainNames []string
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionDNSSearchList) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("lifetime %ds, ", o.Lifetime)
+	s += fmt.Sprintf("domain(s) %s", strings.Join(o.DomainNames, ", "))
+
+	return s
+}
+
+// LifetimeDuration returns the lifetime as time.Duration
+func (o ICMPOptionDNSSearchList) LifetimeDuration() time.Duration {
+	return lifetimeToDuration(o.Lifetime)
+}
+
+// SetLifetime sets the lifetime from given time.Duration
+func (o *ICMPOptionDNSSearchList) SetLifetime(d time.Duration) {
+	o.Lifetime = durationToLifetime(d)
+}
+
+// Type returns ICMPOptionTypeDNSSearchList
+func (o ICMPOptionDNSSearchList) Type() ICMPOptionType {
+	return ICMPOptionTypeDNSSearchList
+}
+
+// Len returns the length in bytes of ICMPOptionDNSSearchList
+func (o ICMPOptionDNSSearchList) Len() uint8 {
+	// length depends on the encoded size of all domain names
+	enc, err := encDomainName(o.DomainNames)
+	if err != nil {
+		return 0
+	}
+
+	return uint8(1 + len(enc)/8)
+}
+
+// Marshal returns byte slice representing this ICMPOptionDNSSearchList
+func (o ICMPOptionDNSSearchList) Marshal() ([]byte, error) {
+	enc, err := encDomainName(o.DomainNames)
+	if err != nil {
+		return nil, err
+	}
+	if 8+len(enc) > 255*8 {
+		return nil, fmt.Errorf("domain names of %d bytes too large to fit in boundaries", len(enc))
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(1 + len(enc)/8)
+	// option fields
+	binary.BigEndian.PutUint32(b[4:8], uint32(o.Lifetime))
+	b = append(b, enc...)
+
+	return b, nil
+}
+
+// AddressRegistrationStatus describes the Status field of the Address
+// Registration option as described at https://tools.ietf.org/html/rfc6775#section-4.1
+type AddressRegistrationStatus uint8
+
+// types currently defined
+const (
+	AddressRegistrationStatusSuccess AddressRegistrationStatus = iota
+	AddressRegistrationStatusDuplicate
+	AddressRegistrationStatusCacheFull
+)
+
+func (typ AddressRegistrationStatus) String() string {
+	switch typ {
+	case AddressRegistrationStatusSuccess:
+		return "success"
+	case AddressRegistrationStatusDuplicate:
+		return "duplicate address"
+	case AddressRegistrationStatusCacheFull:
+		return "neighbor cache full"
+	default:
+		return fmt.Sprintf("status %d", uint8(typ))
+	}
+}
+
+// ICMPOptionAddressRegistration implements the Address Registration option
+// as described at https://tools.ietf.org/html/rfc6775#section-4.1
+type ICMPOptionAddressRegistration struct {
+	Status AddressRegistrationStatus
+	// RegistrationLifetime is expressed in units of 60 seconds
+	RegistrationLifetime uint16
+	EUI64                net.HardwareAddr
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionAddressRegistration) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("status %s, ", o.Status)
+	s += fmt.Sprintf("lifetime %dm, ", o.RegistrationLifetime)
+	s += fmt.Sprintf("eui-64 %s", o.EUI64)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeAddressRegistration
+func (o ICMPOptionAddressRegistration) Type() ICMPOptionType {
+	return ICMPOptionTypeAddressRegistration
+}
+
+// Len returns the length in bytes of ICMPOptionAddressRegistration
+func (o ICMPOptionAddressRegistration) Len() uint8 {
+	// Address Registration options are always 2
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOptionAddressRegistration
+func (o ICMPOptionAddressRegistration) Marshal() ([]byte, error) {
+	if len(o.EUI64) != 8 {
+		return nil, fmt.Errorf("eui-64 %s should be 8 bytes", o.EUI64)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = byte(o.Status)
+	// b[3:6] = reserved
+	binary.BigEndian.PutUint16(b[6:8], o.RegistrationLifetime)
+	b = append(b, o.EUI64...)
+
+	return b, nil
+}
+
+// ICMPOptionSixLoWPANContext implements the 6LoWPAN Context option
+// as described at https://tools.ietf.org/html/rfc6775#section-4.2
+type ICMPOptionSixLoWPANContext struct {
+	ContextLength uint8
+	Compression   bool
+	ContextID     uint8
+	// ValidLifetime is expressed in units of 60 seconds
+	ValidLifetime uint16
+	Prefix        net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionSixLoWPANContext) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("%s/%d, ", o.Prefix, o.ContextLength)
+	s += fmt.Sprintf("cid %d, ", o.ContextID)
+	f := []string{}
+	if o.Compression {
+		f = append(f, "compression")
+	}
+	s += fmt.Sprintf("Flags %s, ", f)
+	s += fmt.Sprintf("valid time %dm", o.ValidLifetime)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeSixLoWPANContext
+func (o ICMPOptionSixLoWPANContext) Type() ICMPOptionType {
+	return ICMPOptionTypeSixLoWPANContext
+}
+
+// Len returns the length in bytes of ICMPOptionSixLoWPANContext
+func (o ICMPOptionSixLoWPANContext) Len() uint8 {
+	// context prefixes up to 64 bits fit in 2, longer ones need 3
+	if o.ContextLength > 64 {
+		return 3
+	}
+
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOptionSixLoWPANContext
+func (o ICMPOptionSixLoWPANContext) Marshal() ([]byte, error) {
+	if o.ContextLength > 128 {
+		return nil, fmt.Errorf("context length %d exceeds 128", o.ContextLength)
+	}
+	if o.ContextID > 15 {
+		return nil, fmt.Errorf("context id %d exceeds 15", o.ContextID)
+	}
+
+	prefix := o.Prefix.To16()
+	if prefix == nil {
+		return nil, fmt.Errorf("prefix %s is not an IPv6 address", o.Prefix)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	b[2] = o.ContextLength
+	if o.Compression {
+		b[3] ^= 0x10
+	}
+	b[3] ^= o.ContextID
+	// b[4:6] = reserved
+	binary.BigEndian.PutUint16(b[6:8], o.ValidLifetime)
+	b = append(b, prefix[:(int(o.Len())-1)*8]...)
+
+	return b, nil
+}
+
+// ICMPOptionAuthoritativeBorderRouter implements the Authoritative Border
+// Router option as described at https://tools.ietf.org/html/rfc6775#section-4.3
+type ICMPOptionAuthoritativeBorderRouter struct {
+	// Version is sent as separate low and high 16 bit fields on the wire
+	Version uint32
+	// ValidLifetime is expressed in units of 60 seconds
+	ValidLifetime uint16
+	Address       net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionAuthoritativeBorderRouter) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("%s, ", o.Address)
+	s += fmt.Sprintf("version %d, ", o.Version)
+	s += fmt.Sprintf("valid time %dm", o.ValidLifetime)
+
+	return s
+}
+
+// Type returns ICMPOptionTypeAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Type() ICMPOptionType {
+	return ICMPOptionTypeAuthoritativeBorderRouter
+}
+
+// Len returns the length in bytes of ICMPOptionAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Len() uint8 {
+	// Authoritative Border Router options are always 3
+	return 3
+}
+
+// Marshal returns byte slice representing this ICMPOptionAuthoritativeBorderRouter
+func (o ICMPOptionAuthoritativeBorderRouter) Marshal() ([]byte, error) {
+	addr := o.Address.To16()
+	if addr == nil {
+		return nil, fmt.Errorf("address %s is not an IPv6 address", o.Address)
+	}
+
+	b := make([]byte, 8)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint16(b[2:4], uint16(o.Version))
+	binary.BigEndian.PutUint16(b[4:6], uint16(o.Version>>16))
+	binary.BigEndian.PutUint16(b[6:8], o.ValidLifetime)
+	b = append(b, addr...)
+
+	return b, nil
+}
+
+// pref64Lengths holds the prefix lengths of the PREF64 option, indexed by
+// the prefix length code that stands for them on the wire
+var pref64Lengths = []uint8{96, 64, 56, 48, 40, 32}
+
+// MaxPREF64Lifetime is the longest lifetime the PREF64 option can express
+const MaxPREF64Lifetime = 8191 * 8 * time.Second
+
+// ICMPOptionPREF64 implements the PREF64 option as described at
+// https://tools.ietf.org/html/rfc8781#section-4
+type ICMPOptionPREF64 struct {
+	// ScaledLifetime is expressed in units of 8 seconds and takes up 13 bits
+	ScaledLifetime uint16
+	// PrefixLength is one of 96, 64, 56, 48, 40 or 32
+	PrefixLength uint8
+	Prefix       net.IP
+}
+
+// String implements the String method of ICMPOption interface.
+func (o ICMPOptionPREF64) String() string {
+	s := fmt.Sprintf("%s option (%d), ", o.Type(), o.Type())
+	s += fmt.Sprintf("length %d (%d): ", (o.Len() * 8), o.Len())
+	s += fmt.Sprintf("%s/%d, ", o.Prefix, o.PrefixLength)
+	s += fmt.Sprintf("lifetime %ds", o.LifetimeDuration()/time.Second)
+
+	return s
+}
+
+// LifetimeDuration returns the lifetime as time.Duration
+func (o ICMPOptionPREF64) LifetimeDuration() time.Duration {
+	return time.Duration(o.ScaledLifetime) * 8 * time.Second
+}
+
+// SetLifetime sets the lifetime from given time.Duration, rounded up to
+// units of 8 seconds and capped at MaxPREF64Lifetime
+func (o *ICMPOptionPREF64) SetLifetime(d time.Duration) {
+	if d > MaxPREF64Lifetime {
+		d = MaxPREF64Lifetime
+	}
+	if d < 0 {
+		d = 0
+	}
+	o.ScaledLifetime = uint16((d + 8*time.Second - 1) / (8 * time.Second))
+}
+
+// Type returns ICMPOptionTypePREF64
+func (o ICMPOptionPREF64) Type() ICMPOptionType {
+	return ICMPOptionTypePREF64
+}
+
+// Len returns the length in bytes of ICMPOptionPREF64
+func (o ICMPOptionPREF64) Len() uint8 {
+	// PREF64 options are always 2
+	return 2
+}
+
+// Marshal returns byte slice representing this ICMPOptionPREF64
+func (o ICMPOptionPREF64) Marshal() ([]byte, error) {
+	plc := -1
+	for i, l := range pref64Lengths {
+		if l == o.PrefixLength {
+			plc = i
+		}
+	}
+	if plc < 0 {
+		return nil, fmt.Errorf("invalid pref64 prefix length %d", o.PrefixLength)
+	}
+	if o.ScaledLifetime > 0x1fff {
+		return nil, fmt.Errorf("scaled lifetime %d exceeds 13 bits", o.ScaledLifetime)
+	}
+	prefix := o.Prefix.To16()
+	if prefix == nil || o.Prefix.To4() != nil {
+		return nil, fmt.Errorf("prefix %s is not an IPv6 prefix", o.Prefix)
+	}
+
+	b := make([]byte, 4)
+	// option header
+	b[0] = byte(o.Type())
+	b[1] = byte(o.Len())
+	// option fields
+	binary.BigEndian.PutUint16(b[2:4], o.ScaledLifetime<<3|uint16(plc))
+	// the prefix is masked as fields beyond its length are reserved
+	b = append(b, prefix.Mask(net.CIDRMask(int(o.PrefixLength), 128))[:12]...)
+
+	return b, nil
+}
+
+func parseOptions(b []byte) ([]ICMPOption, error) {
+	return parseLimitedOptions(b, nil)
+}
+
+// parseLimitedOptions works like parseOptions, within the limits of pb if set
+func parseLimitedOptions(b []byte, pb *parseBudget) ([]ICMPOption, error) {
+	if err := pb.optionBytes(len(b)); err != nil {
+		return nil, err
+	}
+
+	// empty container
+	var icmpOptions = []ICMPOption{}
+
+	for {
+		// left over bytes are less than minimum option length
+		if len(b) < 8 {
+			break
+		}
+
+		// beginning of header specifies type and length
+		optionType := ICMPOptionType(b[0])
+		optionLength := uint8(b[1])
+		// options of length 0 are invalid and would never advance the parser
+		if optionLength == 0 {
+			return nil, fmt.Errorf("option %s (%d) has invalid length 0", optionType, optionType)
+		}
+		// optionLength is in units of 8 bytes
+		optionBytes := int(optionLength) * 8
+		// check if we got enought data for at least as long as optionLength specifies
+		if len(b) < optionBytes {
+			return nil, fmt.Errorf("too few bytes received: %d while at least %d expected", len(b), optionBytes)
+		}
+
+		if err := pb.option(); err != nil {
+			return nil, err
+		}
+
+		var currentOption ICMPOption
+
+		switch optionType {
+		case ICMPOptionTypeSourceLinkLayerAddress:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionSourceLinkLayerAddress{
+				LinkLayerAddress: b[2:8],
+			}
+
+		case ICMPOptionTypeTargetLinkLayerAddress:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionTargetLinkLayerAddress{
+
+				LinkLayerAddress: b[2:8],
+			}
+
+		case ICMPOptionTypePrefixInformation:
+			if optionLength != 4 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 4", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionPrefixInformation{
+
+				PrefixLength:      uint8(b[2]),
+				OnLink:            (b[3]&0x80 > 0),
+				Auto:              (b[3]&0x40 > 0),
+				RouterAddress:     (b[3]&0x20 > 0),
+				Reserved1:         uint8(b[3] & 0x1f),
+				ValidLifetime:     binary.BigEndian.Uint32(b[4:8]),
+				PreferredLifetime: binary.BigEndian.Uint32(b[8:12]),
+				Reserved2:         binary.BigEndian.Uint32(b[12:16]),
+				Prefix:            net.IP(b[16:32]),
+			}
+
+		case ICMPOptionTypeMTU:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionMTU{
+
+				MTU: binary.BigEndian.Uint32(b[4:8]),
+			}
+
+		case ICMPOptionTypeRedirectedHeader:
+			currentOption = &ICMPOptionRedirectedHeader{
+				Packet: b[8:optionBytes],
+			}
+
+		case ICMPOptionTypeTimestamp:
+			if optionLength != 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 2", optionType, optionType, optionLength)
+			}
+
+			// rounding up makes the fractions marshal as they were
+			ts := binary.BigEndian.Uint64(b[8:16])
+			currentOption = &ICMPOptionTimestamp{
+				Timestamp: time.Unix(int64(ts>>16), int64(((ts&0xffff)*uint64(time.Second)+0xffff)>>16)),
+			}
+
+		case ICMPOptionTypeNonce:
+			if optionLength != 1 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 1", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionNonce{}
+
+			n := make([]byte, 2)
+			n = append(n, b[2:8]...)
+			currentOption.(*ICMPOptionNonce).Nonce = binary.BigEndian.Uint64(n)
+
+		case ICMPOptionTypeCGA:
+			padLength := int(b[2])
+			if padLength > optionBytes-4 {
+				return nil, fmt.Errorf("option %s (%d) pad length %d exceeds option length", optionType, optionType, padLength)
+			}
+
+			params, err := ParseCGAParameters(b[4:(optionBytes - padLength)])
+			if err != nil {
+				return nil, fmt.Errorf("option %s (%d) has invalid parameters: %s", optionType, optionType, err)
+			}
+
+			currentOption = &ICMPOptionCGA{
+				Parameters: *params,
+			}
+
+		case ICMPOptionTypeRSASignature:
+			if optionLength < 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 3", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionRSASignature{
+				Signature: b[20:optionBytes],
+			}
+
+			copy(currentOption.(*ICMPOptionRSASignature).KeyHash[:], b[4:20])
+
+		case ICMPOptionTypeTrustAnchor:
+			padLength := int(b[3])
+			if padLength > optionBytes-4 {
+				return nil, fmt.Errorf("option %s (%d) pad length %d exceeds option length", optionType, optionType, padLength)
+			}
+
+			currentOption = &ICMPOptionTrustAnchor{
+				NameType: TrustAnchorNameType(b[2]),
+				Name:     b[4:(optionBytes - padLength)],
+			}
+
+		case ICMPOptionTypeCertificate:
+			cert := b[4:optionBytes]
+			// the certificate is DER encoded, so its own header tells us
+			// where it ends and the padding starts
+			if rest, err := asn1.Unmarshal(cert, &asn1.RawValue{}); err == nil && len(rest) < 8 {
+				cert = cert[:len(cert)-len(rest)]
+			}
+
+			currentOption = &ICMPOptionCertificate{
+				CertType:    CertificateType(b[2]),
+				Certificate: cert,
+			}
+
+		case ICMPOptionTypeLinkLayerAddress:
+			lla := b[3:optionBytes]
+			if optionLength == 2 {
+				lla = lla[:6]
+			}
+
+			currentOption = &ICMPOptionLinkLayerAddress{
+				OptionCode:       LinkLayerAddressOptionCode(b[2]),
+				LinkLayerAddress: net.HardwareAddr(lla),
+			}
+
+		case ICMPOptionTypePvD:
+			fqdn, n, err := decLabels(b[6:optionBytes])
+			if err != nil {
+				return nil, fmt.Errorf("option %s (%d) has invalid fqdn: %s", optionType, optionType, err)
+			}
+
+			pvd := &ICMPOptionPvD{
+				HTTP:           (b[2]&0x80 > 0),
+				Legacy:         (b[2]&0x40 > 0),
+				Delay:          uint8(b[3] & 0x0f),
+				SequenceNumber: binary.BigEndian.Uint16(b[4:6]),
+				FQDN:           fqdn,
+			}
+
+			// skip fqdn and its padding
+			off := 6 + n
+			off += (8 - off%8) % 8
+			if off > optionBytes {
+				return nil, fmt.Errorf("option %s (%d) fqdn padding exceeds option length", optionType, optionType)
+			}
+
+			if b[2]&0x20 > 0 {
+				if off+16 > optionBytes {
+					return nil, fmt.Errorf("option %s (%d) too short for router advertisement header", optionType, optionType)
+				}
+
+				m, err := parseMessage(b[off:(off+16)], pb)
+				if err != nil {
+					return nil, err
+				}
+
+				ra, ok := m.(*ICMPRouterAdvertisement)
+				if !ok {
+					return nil, fmt.Errorf("option %s (%d) contains %s instead of router advertisement header", optionType, optionType, m.Type())
+				}
+
+				pvd.RouterAdvertisement = ra
+				off += 16
+			}
+
+			// nested options
+			if off < optionBytes {
+				nested, err := parseLimitedOptions(b[off:optionBytes], pb)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, no := range nested {
+					if no.Type() == ICMPOptionTypePvD {
+						return nil, fmt.Errorf("option %s (%d) may not contain another %s option", optionType, optionType, optionType)
+					}
+				}
+
+				pvd.Options = nested
+			}
+
+			currentOption = pvd
+
+		case ICMPOptionTypeRouteInformation:
+			if optionLength < 1 || optionLength > 3 {
+				return nil, fmt.Errorf("option %s (%d) invalid length: %d should be 1, 2 or 3", optionType, optionType, optionLength)
+			}
+			if b[2] > 128 {
+				return nil, fmt.Errorf("option %s (%d) prefix length %d exceeds 128", optionType, optionType, b[2])
+			}
+
+			// bits beyond the prefix length are ignored
+			prefix := make(net.IP, net.IPv6len)
+			copy(prefix, b[8:optionBytes])
+			prefix = prefix.Mask(net.CIDRMask(int(b[2]), 128))
+
+			currentOption = &ICMPOptionRouteInformation{
+				PrefixLength:  uint8(b[2]),
+				RouteLifetime: binary.BigEndian.Uint32(b[4:8]),
+				Prefix:        prefix,
+			}
+
+			if b[3]&0x18 == 0x18 {
+				currentOption.(*ICMPOptionRouteInformation).Preference = RouterPreferenceLow
+			} else if b[3]&0x08 > 0 {
+				currentOption.(*ICMPOptionRouteInformation).Preference = RouterPreferenceHigh
+			}
+
+		case ICMPOptionTypeRecursiveDNSServer:
+			if optionLength < 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 3", optionType, optionType, optionLength)
+			}
+			// servers take up 16 bytes each, so anything else is truncated
+			if (optionLength-1)%2 != 0 {
+				return nil, fmt.Errorf("option %s (%d) has truncated server list: length %d", optionType, optionType, optionLength)
+			}
+
+			if err := pb.dnsServers((optionBytes - 8) / 16); err != nil {
+				return nil, err
+			}
+
+			currentOption = &ICMPOptionRecursiveDNSServer{
+				Lifetime: binary.BigEndian.Uint32(b[4:8]),
+			}
+
+			var servers []net.IP
+			for i := 8; i+16 <= optionBytes; i += 16 {
+				servers = append(servers, net.IP(b[i:(i+16)]))
+			}
+
+			currentOption.(*ICMPOptionRecursiveDNSServer).Servers = servers
+
+		case ICMPOptionTypeHandoverKeyRequest:
+			padLength := int(b[2])
+			if padLength > optionBytes-4 {
+				return nil, fmt.Errorf("option %s (%d) pad length %d exceeds option length", optionType, optionType, padLength)
+			}
+
+			currentOption = &ICMPOptionHandoverKeyRequest{
+				AlgorithmType: uint8(b[3] >> 4),
+				PublicKey:     b[4:(optionBytes - padLength)],
+			}
+
+		case ICMPOptionTypeHandoverKeyReply:
+			padLength := int(b[2])
+			if padLength > optionBytes-6 {
+				return nil, fmt.Errorf("option %s (%d) pad length %d exceeds option length", optionType, optionType, padLength)
+			}
+
+			currentOption = &ICMPOptionHandoverKeyReply{
+				AlgorithmType: uint8(b[3] >> 4),
+				KeyLifetime:   binary.BigEndian.Uint16(b[4:6]),
+				EncryptedKey:  b[6:(optionBytes - padLength)],
+			}
+
+		case ICMPOptionTypeDNSSearchList:
+			if optionLength < 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should at least be 2", optionType, optionType, optionLength)
+			}
+
+			if err := pb.dnsLabels(b[8:optionBytes]); err != nil {
+				return nil, err
+			}
+
+			names, err := decDomainName(b[8:optionBytes])
+			if err != nil {
+				return nil, fmt.Errorf("option %s (%d) has invalid domain names: %s", optionType, optionType, err)
+			}
+
+			currentOption = &ICMPOptionDNSSearchList{
+				Lifetime:    binary.BigEndian.Uint32(b[4:8]),
+				DomainNames: names,
+			}
+
+		case ICMPOptionTypeAddressRegistration:
+			if optionLength != 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 2", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionAddressRegistration{
+				Status:               AddressRegistrationStatus(b[2]),
+				RegistrationLifetime: binary.BigEndian.Uint16(b[6:8]),
+				EUI64:                net.HardwareAddr(b[8:16]),
+			}
+
+		case ICMPOptionTypeSixLoWPANContext:
+			if optionLength != 2 && optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) invalid length: %d should be 2 or 3", optionType, optionType, optionLength)
+			}
+			if b[2] > 128 {
+				return nil, fmt.Errorf("option %s (%d) context length %d exceeds 128", optionType, optionType, b[2])
+			}
+
+			prefix := make(net.IP, net.IPv6len)
+			copy(prefix, b[8:optionBytes])
+
+			currentOption = &ICMPOptionSixLoWPANContext{
+				ContextLength: uint8(b[2]),
+				Compression:   (b[3]&0x10 > 0),
+				ContextID:     uint8(b[3] & 0x0f),
+				ValidLifetime: binary.BigEndian.Uint16(b[6:8]),
+				Prefix:        prefix,
+			}
+
+		case ICMPOptionTypeAuthoritativeBorderRouter:
+			if optionLength != 3 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 3", optionType, optionType, optionLength)
+			}
+
+			currentOption = &ICMPOptionAuthoritativeBorderRouter{
+				Version:       uint32(binary.BigEndian.Uint16(b[4:6]))<<16 | uint32(binary.BigEndian.Uint16(b[2:4])),
+				ValidLifetime: binary.BigEndian.Uint16(b[6:8]),
+				Address:       net.IP(b[8:24]),
+			}
+
+		case ICMPOptionTypePREF64:
+			if optionLength != 2 {
+				return nil, fmt.Errorf("option %s (%d) too short: %d should be 2", optionType, optionType, optionLength)
+			}
+
+			// options with an unknown prefix length code are to be ignored
+			field := binary.BigEndian.Uint16(b[2:4])
+			if plc := int(field & 0x7); plc < len(pref64Lengths) {
+				prefix := make(net.IP, net.IPv6len)
+				copy(prefix, b[4:16])
+				currentOption = &ICMPOptionPREF64{
+					ScaledLifetime: field >> 3,
+					PrefixLength:   pref64Lengths[plc],
+					Prefix:         prefix,
+				}
+				break
+			}
+
+			currentOption = &ICMPOptionUnknown{
+				OptionLength: optionLength,
+				OptionType:   optionType,
+				Body:         b[2:optionBytes],
+			}
+
+		default:
+			currentOption = &ICMPOptionUnknown{
+				OptionLength: optionLength,
+				OptionType:   optionType,
+				Body:         b[2:optionBytes],
+			}
+		}
+
+		switch currentOption.(type) {
+		case *ICMPOptionRouteInformation, *ICMPOptionSixLoWPANContext:
+			// senders may use more space than the prefix requires, Len
+			// is only the minimum
+			if optionLength < currentOption.Len() {
+				return nil, fmt.Errorf("option %s (%d) too short for its prefix: %d should at least be %d", optionType, optionType, optionLength, currentOption.Len())
+			}
+		default:
+			if optionLength != currentOption.Len() {
+				return nil, fmt.Errorf("length mismatch while parsing %s: %d should be %d", optionType, currentOption.Len(), optionLength)
+			}
 		}
 
 		// add new option to array of options
 		icmpOptions = append(icmpOptions, currentOption)
 
 		// are we at the end of the byte slice
-		if len(b) <= int(optionLength*8) {
+		if len(b) <= optionBytes {
 			break
 		}
 
 		// chop off bytes for this option
-		b = b[(optionLength * 8):]
+		b = b[optionBytes:]
 	}
 
 	return icmpOptions, nil
@@ -2,9 +2,18 @@ package ndp
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
 	"net"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestICMPOptionTypeString(t *testing.T) {
@@ -20,6 +29,49 @@ func TestICMPOptionTypeString(t *testing.T) {
 		{ICMPOptionTypeNonce, "nonce"},
 		{ICMPOptionTypeRecursiveDNSServer, "rdnss"},
 		{ICMPOptionTypeDNSSearchList, "dnssl"},
+		// check values of all assigned types
+		{4, "redirected header"},
+		{6, "nbma shortcut limit"},
+		{7, "advertisement interval"},
+		{8, "home agent info"},
+		{9, "source address list"},
+		{10, "target address list"},
+		{11, "cga"},
+		{12, "rsa signature"},
+		{13, "timestamp"},
+		{15, "trust anchor"},
+		{16, "certificate"},
+		{17, "ip address/prefix"},
+		{18, "new router prefix info"},
+		{19, "link-layer address"},
+		{20, "neighbor advertisement ack"},
+		{21, "pvd"},
+		{22, "<nil>"},
+		{23, "map"},
+		{24, "route info"},
+		{25, "rdnss"},
+		{26, "flags expansion"},
+		{27, "handover key request"},
+		{28, "handover key reply"},
+		{29, "handover assist info"},
+		{30, "mobile node identifier"},
+		{31, "dnssl"},
+		{32, "proxy signature"},
+		{33, "address registration"},
+		{34, "6lowpan context"},
+		{35, "authoritative border router"},
+		{36, "6lowpan capability indication"},
+		{37, "captive portal"},
+		{38, "pref64"},
+		{39, "crypto-id parameters"},
+		{40, "ndp signature"},
+		{41, "resource directory address"},
+		{42, "<nil>"},
+		{138, "card request"},
+		{139, "card reply"},
+		{144, "encrypted dns"},
+		{253, "experiment 1"},
+		{254, "experiment 2"},
 	}
 
 	for _, test := range tests {
@@ -121,6 +173,45 @@ func TestICMPOptionDNSSearchList(t *testing.T) {
 	if bytes.Compare(parsedMarshal, marshal) != 0 {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
+	// check with a domain name longer than 14 bytes
+	option.DomainNames = []string{"subdomain.of.a.rather.long.example.com."}
+	if option.Len() != 6 {
+		t.Errorf("wrong length, %d != 6", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 48 || marshal[1] != 6 {
+		t.Errorf("unexpected encoding %v", marshal)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed = options[0].(*ICMPOptionDNSSearchList)
+	if !reflect.DeepEqual(parsed.DomainNames, option.DomainNames) {
+		t.Errorf("domain names %s did not match %s", parsed.DomainNames, option.DomainNames)
+	}
+
+	// invalid labels can't be marshalled
+	option.DomainNames = []string{strings.Repeat("a", 64) + ".example."}
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for label exceeding 63 bytes")
+	}
+
+	// malformed domain names are rejected
+	if _, err = parseOptions([]byte{31, 2, 0, 0, 0, 0, 0, 10, 3, 102, 111, 111, 9, 0, 0, 0}); err == nil {
+		t.Errorf("expected error for malformed domain name")
+	}
 }
 
 func TestICMPOptionMTU(t *testing.T) {
@@ -175,6 +266,116 @@ func TestICMPOptionMTU(t *testing.T) {
 	}
 }
 
+func TestICMPOptionRedirectedHeader(t *testing.T) {
+	option := &ICMPOptionRedirectedHeader{
+		Packet: []byte{0x60, 0, 0, 0, 0, 0, 0x3a, 0x40, 1, 2},
+	}
+
+	if option.Type() != ICMPOptionTypeRedirectedHeader {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeRedirectedHeader)
+	}
+
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// packet is padded up to a multiple of 8 bytes
+	fixture := []byte{4, 3, 0, 0, 0, 0, 0, 0, 0x60, 0, 0, 0, 0, 0, 0x3a, 0x40, 1, 2, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "redirected header option (4), length 24 (3): 10 bytes of packet"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != 1 {
+		t.Fatalf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionRedirectedHeader)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	if _, err := (&ICMPOptionRedirectedHeader{Packet: make([]byte, 255*8)}).Marshal(); err == nil {
+		t.Error("expected error for packet too large")
+	}
+}
+
+func TestICMPOptionTimestamp(t *testing.T) {
+	option := &ICMPOptionTimestamp{
+		Timestamp: time.Unix(1000, int64(time.Second/4)),
+	}
+
+	if option.Type() != ICMPOptionTypeTimestamp {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeTimestamp)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture := []byte{13, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 232, 64, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "timestamp option (13), length 16 (2): 1970-01-01T00:16:40.25Z"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	// fractions that aren't a multiple of 1/64K seconds survive parsing
+	option.Timestamp = time.Unix(1000, 123456789)
+	marshal, _ = option.Marshal()
+	options, err := parseOptions(marshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := options[0].(*ICMPOptionTimestamp)
+	if d := option.Timestamp.Sub(parsed.Timestamp); d < -time.Second/65536 || d > time.Second/65536 {
+		t.Errorf("timestamp %s too far from %s", parsed.Timestamp, option.Timestamp)
+	}
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	option.Timestamp = time.Unix(-1, 0)
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected out of boundaries error")
+	}
+}
+
 func TestICMPOptionNonce(t *testing.T) {
 	option := &ICMPOptionNonce{
 		Nonce: 65766764768057,
@@ -397,6 +598,48 @@ func TestICMPOptionPrefixInformation(t *testing.T) {
 	if bytes.Compare(parsedMarshal, marshal) != 0 {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
+
+	// router address flag and reserved bits
+	option.RouterAddress = true
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if marshal[3] != 224 {
+		t.Errorf("unexpected flags %d", marshal[3])
+	}
+
+	descfix = "prefix info option (3), length 32 (4): 2a00:1450:400e:802::/64, Flags [onlink auto router], valid time 2592000s, pref. time 604800s"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	fixture = []byte{3, 4, 64, 35, 0, 39, 141, 0, 0, 9, 58, 128, 1, 2, 3, 4, 42, 0, 20, 80, 64, 14, 8, 2, 0, 0, 0, 0, 0, 0, 0, 0}
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	parsed = options[0].(*ICMPOptionPrefixInformation)
+	if parsed.OnLink || parsed.Auto || !parsed.RouterAddress || parsed.Reserved1 != 3 || parsed.Reserved2 != 16909060 {
+		t.Errorf("unexpected flags in %s", parsed)
+	}
+
+	parsedMarshal, err = parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, fixture) != 0 {
+		t.Errorf("marshal of %v did not match %v", fixture, parsedMarshal)
+	}
+
+	option.Reserved1 = 32
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for out of bounds reserved flags")
+	}
 }
 
 func TestICMPOptionRecursiveDNSServer(t *testing.T) {
@@ -494,19 +737,83 @@ func TestICMPOptionRecursiveDNSServer(t *testing.T) {
 	if bytes.Compare(parsedMarshal, marshal) != 0 {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
+
+	// any number of servers up to 127
+	option.Servers = nil
+	for i := 0; i < 127; i++ {
+		option.Servers = append(option.Servers, net.ParseIP(fmt.Sprintf("2001:db8::%x", i+1)))
+	}
+
+	if option.Len() != 255 {
+		t.Errorf("wrong length, %d != 255", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 2040 {
+		t.Errorf("wrong marshal length, %d != 2040", len(marshal))
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed = options[0].(*ICMPOptionRecursiveDNSServer)
+	if len(parsed.Servers) != 127 || !parsed.Servers[126].Equal(option.Servers[126]) {
+		t.Errorf("parsed %d servers instead of 127", len(parsed.Servers))
+	}
+
+	option.Servers = append(option.Servers, net.ParseIP("2001:db8::ffff"))
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for too many servers")
+	}
+
+	// only IPv6 servers
+	option.Servers = []net.IP{net.ParseIP("192.0.2.53")}
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for IPv4 server")
+	}
+
+	option.Servers = nil
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for empty server list")
+	}
+
+	// truncated server list, followed by another option
+	fixture = []byte{25, 4, 0, 0, 0, 0, 1, 44, 32, 1, 72, 96, 72, 96, 0, 0, 0, 0, 0, 0, 0, 0, 136, 68, 32, 1, 72, 96, 72, 96, 0, 0, 5, 1, 0, 0, 0, 0, 5, 220}
+	if _, err = parseOptions(fixture); err == nil {
+		t.Errorf("expected error for truncated server list")
+	}
 }
 
 func TestICMPOptionUnknown(t *testing.T) {
 	option := &ICMPOptionUnknown{
-		optionType:   100,
-		optionLength: 1,
-		body:         []byte{1, 2, 3, 4, 5, 6},
+		OptionType:   100,
+		OptionLength: 1,
+		Body:         []byte{1, 2, 3, 4, 5, 6},
 	}
 
 	if option.Type() != 100 {
 		t.Errorf("wrong type: %d instead of %d", option.Type(), 100)
 	}
 
+	// assigned types print their name
+	assigned := &ICMPOptionUnknown{
+		OptionType:   ICMPOptionTypeRouteInformation,
+		OptionLength: 1,
+	}
+	if desc := assigned.String(); strings.Compare(desc, "route info option (24), length 8 (1)") != 0 {
+		t.Errorf("unexpected description for assigned type: %s", desc)
+	}
+
 	if option.Len() != 1 {
 		t.Errorf("wrong length, %d != 1", option.Len())
 	}
@@ -548,4 +855,1194 @@ func TestICMPOptionUnknown(t *testing.T) {
 	if bytes.Compare(parsedMarshal, marshal) != 0 {
 		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
 	}
+
+	// raw options are padded
+	option, err = NewRawOption(ICMPOptionTypeNBMAShortcutLimit, []byte{8})
+	if err != nil {
+		t.Error(err)
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{6, 1, 8, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix = "nbma shortcut limit option (6), length 8 (1)"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	option, err = NewRawOption(200, make([]byte, 14))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if option.Len() != 2 || len(option.Body) != 14 {
+		t.Errorf("unexpected length %d with body of %d", option.Len(), len(option.Body))
+	}
+
+	if _, err = NewRawOption(200, make([]byte, 2039)); err == nil {
+		t.Errorf("expected out of boundaries error")
+	}
+
+	// deliberately malformed option
+	option = &ICMPOptionUnknown{
+		OptionType:   ICMPOptionTypeMTU,
+		OptionLength: 2,
+		Body:         []byte{0, 0, 0, 0, 5, 220},
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, err = parseOptions(marshal); err == nil {
+		t.Errorf("expected error for malformed option")
+	}
+}
+
+func TestICMPOptionRSASignature(t *testing.T) {
+	option := &ICMPOptionRSASignature{
+		KeyHash:   [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Signature: []byte{170, 187, 204, 221, 238, 255},
+	}
+
+	if option.Type() != ICMPOptionTypeRSASignature {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeRSASignature)
+	}
+
+	if option.Len() != 4 {
+		t.Errorf("wrong length, %d != 4", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// rsa signature option (12), length 32 (4): key hash 0102030405060708090a0b0c0d0e0f10, signature length 6
+	fixture := []byte{12, 4, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 170, 187, 204, 221, 238, 255, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "rsa signature option (12), length 32 (4): key hash 0102030405060708090a0b0c0d0e0f10, signature length 6"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionRSASignature)
+	if parsed.KeyHash != option.KeyHash {
+		t.Errorf("key hash %x did not match %x", parsed.KeyHash, option.KeyHash)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// 2048 bit signature, option exceeds 255 bytes
+	option.Signature = make([]byte, 256)
+	if option.Len() != 35 {
+		t.Errorf("wrong length, %d != 35", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal) != 280 {
+		t.Errorf("wrong marshal length, %d != 280", len(marshal))
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	option.Signature = make([]byte, 2040)
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected out of boundaries error")
+	}
+}
+
+func TestParseOptionsZeroLength(t *testing.T) {
+	if _, err := parseOptions([]byte{100, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("expected error for zero length option")
+	}
+}
+
+func TestICMPOptionTrustAnchor(t *testing.T) {
+	option, err := NewTrustAnchorFQDN("ca.example.")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if option.Type() != ICMPOptionTypeTrustAnchor {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeTrustAnchor)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// trust anchor option (15), length 16 (2): name type fqdn, name ca.example.
+	fixture := []byte{15, 2, 2, 0, 2, 99, 97, 7, 101, 120, 97, 109, 112, 108, 101, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "trust anchor option (15), length 16 (2): name type fqdn, name ca.example."
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionTrustAnchor)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	if _, err = parsed.DistinguishedName(); err == nil {
+		t.Errorf("expected error for fqdn trust anchor")
+	}
+
+	// DER encoded name with padding
+	option, err = NewTrustAnchorDER(pkix.Name{CommonName: "SEND CA"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(marshal)%8 != 0 || int(marshal[3]) != len(marshal)-4-len(option.Name) {
+		t.Errorf("wrong padding in %v", marshal)
+	}
+
+	descfix = "trust anchor option (15), length 24 (3): name type der, name CN=SEND CA"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	name, err := options[0].(*ICMPOptionTrustAnchor).DistinguishedName()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if name.CommonName != "SEND CA" {
+		t.Errorf("unexpected common name %s", name.CommonName)
+	}
+
+	// pad length exceeding option
+	if _, err = parseOptions([]byte{15, 1, 2, 5, 0, 0, 0, 0}); err == nil {
+		t.Errorf("expected error for invalid pad length")
+	}
+}
+
+func TestICMPOptionTrustAnchorSKI(t *testing.T) {
+	cert := func() *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "SEND CA"},
+			DNSNames:     []string{"ca.example"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	ca, other := cert(), cert()
+
+	if _, err := NewTrustAnchorSKI(TrustAnchorNameTypeFQDN, ca); err == nil {
+		t.Error("expected error for name type without hash")
+	}
+
+	for typ, size := range map[TrustAnchorNameType]int{
+		TrustAnchorNameTypeSHA1SKI:   20,
+		TrustAnchorNameTypeSHA224SKI: 28,
+		TrustAnchorNameTypeSHA256SKI: 32,
+		TrustAnchorNameTypeSHA384SKI: 48,
+		TrustAnchorNameTypeSHA512SKI: 64,
+	} {
+		option, err := NewTrustAnchorSKI(typ, ca)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(option.Name) != size {
+			t.Errorf("unexpected %s of %d bytes", typ, len(option.Name))
+		}
+
+		marshal, err := option.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		options, err := parseOptions(marshal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed := options[0].(*ICMPOptionTrustAnchor)
+		if !parsed.Matches(ca) || parsed.Matches(other) {
+			t.Errorf("%s doesn't tell trust anchors apart", typ)
+		}
+	}
+
+	option, _ := NewTrustAnchorSKI(TrustAnchorNameTypeSHA1SKI, ca)
+	descfix := fmt.Sprintf("trust anchor option (15), length 24 (3): name type sha-1 ski, ski %x", option.Name)
+	if desc := option.String(); desc != descfix {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	// the other name types match as well
+	if o, _ := NewTrustAnchorDER(pkix.Name{CommonName: "SEND CA"}); !o.Matches(ca) {
+		t.Error("expected der name to match")
+	}
+	if o, _ := NewTrustAnchorFQDN("ca.example."); !o.Matches(ca) {
+		t.Error("expected fqdn to match")
+	}
+	if o, _ := NewTrustAnchorFQDN("other.example."); o.Matches(ca) {
+		t.Error("unexpected fqdn match")
+	}
+}
+
+func TestICMPOptionCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "router"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	option := NewCertificateOption(cert)
+
+	if option.Type() != ICMPOptionTypeCertificate {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeCertificate)
+	}
+
+	if int(option.Len()) != (4+len(der)+7)/8 {
+		t.Errorf("wrong length, %d != %d", option.Len(), (4+len(der)+7)/8)
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(marshal[:4], []byte{16, option.Len(), 1, 0}) != 0 {
+		t.Errorf("unexpected option header %v", marshal[:4])
+	}
+
+	if len(marshal)%8 != 0 {
+		t.Errorf("marshal length %d is not a multiple of 8", len(marshal))
+	}
+
+	descfix := fmt.Sprintf("certificate option (16), length %d (%d): cert type x509v3, subject CN=router", len(marshal), option.Len())
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionCertificate)
+	if bytes.Compare(parsed.Certificate, der) != 0 {
+		t.Errorf("parsed certificate did not match original")
+	}
+
+	parsedCert, err := parsed.X509Certificate()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !parsedCert.Equal(cert) {
+		t.Errorf("parsed certificate did not match original")
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionAddressRegistration(t *testing.T) {
+	var err error
+	option := &ICMPOptionAddressRegistration{
+		Status:               AddressRegistrationStatusDuplicate,
+		RegistrationLifetime: 1440,
+	}
+	option.EUI64, err = net.ParseMAC("02:00:00:ff:fe:00:00:01")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if option.Type() != ICMPOptionTypeAddressRegistration {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeAddressRegistration)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// address registration option (33), length 16 (2): status duplicate address, lifetime 1440m, eui-64 02:00:00:ff:fe:00:00:01
+	fixture := []byte{33, 2, 1, 0, 0, 0, 5, 160, 2, 0, 0, 255, 254, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "address registration option (33), length 16 (2): status duplicate address, lifetime 1440m, eui-64 02:00:00:ff:fe:00:00:01"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionAddressRegistration)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	option.EUI64 = option.EUI64[:6]
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for short eui-64")
+	}
+}
+
+func TestICMPOptionSixLoWPANContext(t *testing.T) {
+	option := &ICMPOptionSixLoWPANContext{
+		ContextLength: 64,
+		Compression:   true,
+		ContextID:     3,
+		ValidLifetime: 60,
+		Prefix:        net.ParseIP("2001:db8::"),
+	}
+
+	if option.Type() != ICMPOptionTypeSixLoWPANContext {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeSixLoWPANContext)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// 6lowpan context option (34), length 16 (2): 2001:db8::/64, cid 3, Flags [compression], valid time 60m
+	fixture := []byte{34, 2, 64, 19, 0, 0, 0, 60, 32, 1, 13, 184, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "6lowpan context option (34), length 16 (2): 2001:db8::/64, cid 3, Flags [compression], valid time 60m"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionSixLoWPANContext)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// context longer than 64 bits takes 3 units
+	option.ContextLength = 80
+	option.Compression = false
+	option.Prefix = net.ParseIP("2001:db8:0:0:abcd::")
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{34, 3, 80, 3, 0, 0, 0, 60, 32, 1, 13, 184, 0, 0, 0, 0, 171, 205, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed = options[0].(*ICMPOptionSixLoWPANContext)
+	if !parsed.Prefix.Equal(option.Prefix) {
+		t.Errorf("prefix %s did not match %s", parsed.Prefix, option.Prefix)
+	}
+
+	// a /64 context may be sent in 3 units
+	fixture = []byte{34, 3, 64, 19, 0, 0, 0, 60, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	} else if parsed = options[0].(*ICMPOptionSixLoWPANContext); parsed.ContextLength != 64 || !parsed.Prefix.Equal(net.ParseIP("2001:db8::")) {
+		t.Errorf("unexpected parse result %s", parsed)
+	}
+
+	// but an /80 doesn't fit in 2
+	fixture = []byte{34, 2, 80, 19, 0, 0, 0, 60, 32, 1, 13, 184, 0, 0, 0, 0}
+	if _, err = parseOptions(fixture); err == nil {
+		t.Errorf("expected error for /80 in 16 bytes")
+	}
+
+	option.ContextID = 16
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for out of bounds context id")
+	}
+}
+
+func TestICMPOptionAuthoritativeBorderRouter(t *testing.T) {
+	option := &ICMPOptionAuthoritativeBorderRouter{
+		Version:       65538,
+		ValidLifetime: 10000,
+		Address:       net.ParseIP("2001:db8::1"),
+	}
+
+	if option.Type() != ICMPOptionTypeAuthoritativeBorderRouter {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeAuthoritativeBorderRouter)
+	}
+
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// authoritative border router option (35), length 24 (3): 2001:db8::1, version 65538, valid time 10000m
+	fixture := []byte{35, 3, 0, 2, 0, 1, 39, 16, 32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "authoritative border router option (35), length 24 (3): 2001:db8::1, version 65538, valid time 10000m"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionAuthoritativeBorderRouter)
+	if parsed.Version != option.Version {
+		t.Errorf("version %d did not match %d", parsed.Version, option.Version)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionLinkLayerAddress(t *testing.T) {
+	var err error
+	option := &ICMPOptionLinkLayerAddress{
+		OptionCode: LinkLayerAddressOptionCodeNewAccessPoint,
+	}
+	option.LinkLayerAddress, err = net.ParseMAC("a1:b2:c3:d4:e6:f7")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if option.Type() != ICMPOptionTypeLinkLayerAddress {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeLinkLayerAddress)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// link-layer address option (19), length 16 (2): new access point, a1:b2:c3:d4:e6:f7
+	fixture := []byte{19, 2, 1, 161, 178, 195, 212, 230, 247, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "link-layer address option (19), length 16 (2): new access point, a1:b2:c3:d4:e6:f7"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionLinkLayerAddress)
+	if bytes.Compare(parsed.LinkLayerAddress, option.LinkLayerAddress) != 0 {
+		t.Errorf("address %s did not match %s", parsed.LinkLayerAddress, option.LinkLayerAddress)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// EUI-64 link-layer address still fits in 2
+	option.LinkLayerAddress, err = net.ParseMAC("02:00:00:ff:fe:00:00:01")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	// 20 byte InfiniBand address needs 3
+	option.LinkLayerAddress = make(net.HardwareAddr, 20)
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsedMarshal, err = options[0].Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+}
+
+func TestICMPOptionHandoverKeyRequest(t *testing.T) {
+	option := &ICMPOptionHandoverKeyRequest{
+		AlgorithmType: 1,
+		PublicKey:     []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	}
+
+	if option.Type() != ICMPOptionTypeHandoverKeyRequest {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeHandoverKeyRequest)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// handover key request option (27), length 16 (2): algorithm type 1, public key length 10
+	fixture := []byte{27, 2, 2, 16, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "handover key request option (27), length 16 (2): algorithm type 1, public key length 10"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionHandoverKeyRequest)
+	if bytes.Compare(parsed.PublicKey, option.PublicKey) != 0 {
+		t.Errorf("public key %v did not match %v", parsed.PublicKey, option.PublicKey)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	option.AlgorithmType = 16
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for out of bounds algorithm type")
+	}
+}
+
+func TestICMPOptionHandoverKeyReply(t *testing.T) {
+	option := &ICMPOptionHandoverKeyReply{
+		AlgorithmType: 1,
+		KeyLifetime:   600,
+		EncryptedKey:  []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	}
+
+	if option.Type() != ICMPOptionTypeHandoverKeyReply {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeHandoverKeyReply)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// handover key reply option (28), length 16 (2): algorithm type 1, lifetime 600s, encrypted key length 10
+	fixture := []byte{28, 2, 0, 16, 2, 88, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "handover key reply option (28), length 16 (2): algorithm type 1, lifetime 600s, encrypted key length 10"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionHandoverKeyReply)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// pad length exceeding option
+	if _, err = parseOptions([]byte{28, 1, 3, 16, 0, 0, 0, 0}); err == nil {
+		t.Errorf("expected error for invalid pad length")
+	}
+}
+
+func TestICMPOptionPvD(t *testing.T) {
+	option := &ICMPOptionPvD{
+		HTTP:           true,
+		SequenceNumber: 7,
+		FQDN:           "pvd.example.",
+	}
+
+	if option.Type() != ICMPOptionTypePvD {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypePvD)
+	}
+
+	if option.Len() != 3 {
+		t.Errorf("wrong length, %d != 3", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// pvd option (21), length 24 (3): pvd.example., seq 7, Flags [http], delay 0
+	fixture := []byte{21, 3, 128, 0, 0, 7, 3, 112, 118, 100, 7, 101, 120, 97, 109, 112, 108, 101, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "pvd option (21), length 24 (3): pvd.example., seq 7, Flags [http], delay 0"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionPvD)
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// with router advertisement header and nested options
+	option.RouterAdvertisement = &ICMPRouterAdvertisement{
+		HopLimit:       64,
+		RouterLifeTime: 1800,
+	}
+	option.AddOption(&ICMPOptionMTU{MTU: 1500})
+	option.AddOption(&ICMPOptionRecursiveDNSServer{
+		Lifetime: 300,
+		Servers:  []net.IP{net.ParseIP("2001:db8::53")},
+	})
+
+	if option.Len() != 9 {
+		t.Errorf("wrong length, %d != 9", option.Len())
+	}
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	descfix = "pvd option (21), length 72 (9): pvd.example., seq 7, Flags [http ra], delay 0\n      mtu option (5), length 8 (1): 1500\n      rdnss option (25), length 24 (3): lifetime 300s, addr: 2001:db8::53"
+	desc = option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err = parseOptions(marshal)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed = options[0].(*ICMPOptionPvD)
+	if parsed.RouterAdvertisement == nil || parsed.RouterAdvertisement.RouterLifeTime != 1800 {
+		t.Errorf("router advertisement header not parsed: %v", parsed.RouterAdvertisement)
+	}
+
+	if !parsed.HasOption(ICMPOptionTypeRecursiveDNSServer) {
+		t.Errorf("nested rdnss option not parsed")
+	}
+
+	parsedMarshal, err = parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// pvd options may not be nested
+	option.AddOption(&ICMPOptionPvD{FQDN: "nested.example."})
+	if _, err = option.Marshal(); err == nil {
+		t.Errorf("expected error for nested pvd option")
+	}
+}
+
+func TestICMPOptionRouteInformation(t *testing.T) {
+	option := &ICMPOptionRouteInformation{
+		PrefixLength:  48,
+		Preference:    RouterPreferenceHigh,
+		RouteLifetime: 1800,
+		Prefix:        net.ParseIP("2001:db8:1::"),
+	}
+
+	if option.Type() != ICMPOptionTypeRouteInformation {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypeRouteInformation)
+	}
+
+	if option.Len() != 2 {
+		t.Errorf("wrong length, %d != 2", option.Len())
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// route info option (24), length 16 (2): 2001:db8:1::/48, pref high, lifetime 1800s
+	fixture := []byte{24, 2, 48, 8, 0, 0, 7, 8, 32, 1, 13, 184, 0, 1, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "route info option (24), length 16 (2): 2001:db8:1::/48, pref high, lifetime 1800s"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	var options []ICMPOption
+	options, err = parseOptions(fixture)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(options) != 1 {
+		t.Errorf("parsed %d options instead of 1", len(options))
+	}
+
+	parsed := options[0].(*ICMPOptionRouteInformation)
+	if parsed.Preference != RouterPreferenceHigh || !parsed.Prefix.Equal(option.Prefix) {
+		t.Errorf("unexpected parse result %s", parsed)
+	}
+
+	parsedMarshal, err := parsed.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if bytes.Compare(parsedMarshal, marshal) != 0 {
+		t.Errorf("marshal of %v did not match %v", marshal, parsedMarshal)
+	}
+
+	// default route
+	option = &ICMPOptionRouteInformation{
+		Preference: RouterPreferenceLow,
+	}
+	option.SetRouteLifetime(Infinity)
+
+	marshal, err = option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	fixture = []byte{24, 1, 0, 24, 255, 255, 255, 255}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	if option.RouteLifetimeDuration() != Infinity {
+		t.Errorf("route lifetime %s is not infinity", option.RouteLifetimeDuration())
+	}
+	// /48 and /0 sent with more space than needed, as radvd does
+	for _, fixture := range [][]byte{
+		{24, 3, 48, 8, 0, 0, 7, 8, 32, 1, 13, 184, 0, 1, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0},
+		{24, 2, 0, 24, 255, 255, 255, 255, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		options, err = parseOptions(fixture)
+		if err != nil {
+			t.Errorf("failed to parse %v: %s", fixture, err)
+			continue
+		}
+		parsed := options[0].(*ICMPOptionRouteInformation)
+		expected := net.ParseIP("2001:db8:1::")
+		if parsed.PrefixLength == 0 {
+			expected = net.IPv6unspecified
+		}
+		if !parsed.Prefix.Equal(expected) {
+			t.Errorf("expected prefix %s, not %s", expected, parsed.Prefix)
+		}
+	}
+
+	// too short for the prefix length
+	if _, err = parseOptions([]byte{24, 2, 96, 8, 0, 0, 7, 8, 32, 1, 13, 184, 0, 1, 0, 0}); err == nil {
+		t.Error("expected error for /96 in 16 bytes")
+	}
+	if _, err = parseOptions([]byte{24, 1, 130, 8, 0, 0, 7, 8}); err == nil {
+		t.Error("expected error for prefix length exceeding 128")
+	}
+}
+
+func TestLifetimeDurations(t *testing.T) {
+	pio := &ICMPOptionPrefixInformation{}
+	pio.SetValidLifetime(30 * 24 * time.Hour)
+	pio.SetPreferredLifetime(Infinity)
+	if pio.ValidLifetime != 2592000 {
+		t.Errorf("valid lifetime %d != 2592000", pio.ValidLifetime)
+	}
+	if pio.PreferredLifetime != 0xffffffff {
+		t.Errorf("preferred lifetime %d is not infinity", pio.PreferredLifetime)
+	}
+	if pio.ValidLifetimeDuration() != 30*24*time.Hour {
+		t.Errorf("valid lifetime %s != 720h", pio.ValidLifetimeDuration())
+	}
+	if pio.PreferredLifetimeDuration() != Infinity {
+		t.Errorf("preferred lifetime %s is not infinity", pio.PreferredLifetimeDuration())
+	}
+
+	rdnss := &ICMPOptionRecursiveDNSServer{}
+	rdnss.SetLifetime(1500 * time.Millisecond)
+	if rdnss.Lifetime != 1 {
+		t.Errorf("lifetime %d != 1", rdnss.Lifetime)
+	}
+	if rdnss.LifetimeDuration() != time.Second {
+		t.Errorf("lifetime %s != 1s", rdnss.LifetimeDuration())
+	}
+
+	dnssl := &ICMPOptionDNSSearchList{}
+	dnssl.SetLifetime(-time.Second)
+	if dnssl.Lifetime != 0 {
+		t.Errorf("lifetime %d != 0", dnssl.Lifetime)
+	}
+	dnssl.SetLifetime(200 * 365 * 24 * time.Hour)
+	if dnssl.LifetimeDuration() != Infinity {
+		t.Errorf("lifetime %s is not infinity", dnssl.LifetimeDuration())
+	}
+}
+
+func TestICMPOptionPREF64(t *testing.T) {
+	option := &ICMPOptionPREF64{
+		PrefixLength: 96,
+		Prefix:       net.ParseIP("64:ff9b::"),
+	}
+	option.SetLifetime(1800 * time.Second)
+
+	if option.Type() != ICMPOptionTypePREF64 {
+		t.Errorf("wrong type: %d instead of %d", option.Type(), ICMPOptionTypePREF64)
+	}
+
+	if option.ScaledLifetime != 225 || option.LifetimeDuration() != 1800*time.Second {
+		t.Errorf("wrong lifetime %d", option.ScaledLifetime)
+	}
+
+	marshal, err := option.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// fixture describes
+	// pref64 option (38), length 16 (2): 64:ff9b::/96, lifetime 1800s
+	fixture := []byte{38, 2, 7, 8, 0, 100, 255, 155, 0, 0, 0, 0, 0, 0, 0, 0}
+	if bytes.Compare(marshal, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, marshal)
+	}
+
+	descfix := "pref64 option (38), length 16 (2): 64:ff9b::/96, lifetime 1800s"
+	desc := option.String()
+	if strings.Compare(desc, descfix) != 0 {
+		t.Errorf("fixture of '%s' did not match '%s'", descfix, desc)
+	}
+
+	options, err := parseOptions(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, ok := options[0].(*ICMPOptionPREF64)
+	if !ok {
+		t.Fatalf("unexpected option %s", options[0])
+	}
+	if parsed.ScaledLifetime != 225 || parsed.PrefixLength != 96 || !parsed.Prefix.Equal(option.Prefix) {
+		t.Errorf("unexpected parsed option %s", parsed)
+	}
+
+	// lifetimes are rounded up to units of 8 seconds, and capped
+	option.SetLifetime(time.Second)
+	if option.ScaledLifetime != 1 {
+		t.Errorf("wrong lifetime %d", option.ScaledLifetime)
+	}
+	option.SetLifetime(Infinity)
+	if option.ScaledLifetime != 8191 {
+		t.Errorf("wrong lifetime %d", option.ScaledLifetime)
+	}
+
+	// bits beyond the prefix length are left out
+	option.PrefixLength = 32
+	option.Prefix = net.ParseIP("2001:db8:ffff::")
+	if marshal, err = option.Marshal(); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(marshal[4:16], []byte{32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0}) || marshal[3]&0x7 != 5 {
+		t.Errorf("unexpected marshal %v", marshal)
+	}
+
+	option.PrefixLength = 80
+	if _, err := option.Marshal(); err == nil {
+		t.Error("expected error for invalid prefix length")
+	}
+
+	// options with unknown prefix length codes are ignored rather than
+	// failing the message
+	fixture[3] |= 0x7
+	if options, err = parseOptions(fixture); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := options[0].(*ICMPOptionUnknown); !ok {
+		t.Errorf("unexpected option %s", options[0])
+	}
 }
@@ -0,0 +1,834 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/ipv6"
+)
+
+// The JSON encoding of messages and options is an object holding their type
+// and its name, followed by their fields in the order they are declared.
+// Field names are in snake case, addresses are strings and byte fields are
+// hex encoded, for example:
+//
+//	{"type":135,"name":"neighbor solicitation","options":[{"type":1,
+//	"name":"source link-layer address","link_layer_address":"02:00:00:00:00:01"}],
+//	"target_address":"fe80::2"}
+//
+// Decoding accepts the same objects, where fields left out keep their zero
+// value and the name is ignored, so fixtures can hold just what matters
+
+var (
+	errJSONNoType    = errors.New("json object has no type")
+	errJSONNotObject = errors.New("json value is not an object")
+)
+
+var (
+	ipType          = reflect.TypeOf(net.IP(nil))
+	hwType          = reflect.TypeOf(net.HardwareAddr(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	optionType      = reflect.TypeOf((*ICMPOption)(nil)).Elem()
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPRouterSolicitation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPRouterAdvertisement) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPNeighborSolicitation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPNeighborAdvertisement) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPRedirect) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPCertificationPathSolicitation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPCertificationPathAdvertisement) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPDuplicateAddressRequest) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (p ICMPDuplicateAddressConfirmation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(p.Type()), p.Type().String(), p)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionUnknown) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionSourceLinkLayerAddress) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionTargetLinkLayerAddress) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionPrefixInformation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionMTU) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionRedirectedHeader) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionTimestamp) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionNonce) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionCGA) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionRSASignature) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionTrustAnchor) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionCertificate) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionLinkLayerAddress) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionPvD) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionRouteInformation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionRecursiveDNSServer) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionHandoverKeyRequest) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionHandoverKeyReply) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionDNSSearchList) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionAddressRegistration) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionSixLoWPANContext) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionAuthoritativeBorderRouter) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// MarshalJSON implements json.Marshaler
+func (o ICMPOptionPREF64) MarshalJSON() ([]byte, error) {
+	return marshalJSON(int(o.Type()), o.Type().String(), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPRouterSolicitation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPRouterAdvertisement) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPNeighborSolicitation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPNeighborAdvertisement) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPRedirect) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPCertificationPathSolicitation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPCertificationPathAdvertisement) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPDuplicateAddressRequest) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ICMPDuplicateAddressConfirmation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(p.Type()), p)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The option type defaults to
+// the type of the object
+func (o *ICMPOptionUnknown) UnmarshalJSON(b []byte) error {
+	typ, err := decodeJSON(b, reflect.ValueOf(o).Elem())
+	if err != nil {
+		return err
+	}
+	if o.OptionType == 0 && typ != nil {
+		o.OptionType = ICMPOptionType(*typ)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionSourceLinkLayerAddress) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionTargetLinkLayerAddress) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionPrefixInformation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionMTU) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionRedirectedHeader) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionTimestamp) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionNonce) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionCGA) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionRSASignature) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionTrustAnchor) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionCertificate) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionLinkLayerAddress) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionPvD) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionRouteInformation) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionRecursiveDNSServer) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionHandoverKeyRequest) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionHandoverKeyReply) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionDNSSearchList) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionAddressRegistration) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionSixLoWPANContext) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionAuthoritativeBorderRouter) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *ICMPOptionPREF64) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(b, int(o.Type()), o)
+}
+
+// ParseMessageJSON returns the message of the JSON object b, of the type
+// its type field holds, as MarshalJSON of that message encodes it
+func ParseMessageJSON(b []byte) (ICMP, error) {
+	typ, err := jsonType(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var m ICMP
+	switch ipv6.ICMPType(typ) {
+	case ipv6.ICMPTypeRouterSolicitation:
+		m = &ICMPRouterSolicitation{}
+	case ipv6.ICMPTypeRouterAdvertisement:
+		m = &ICMPRouterAdvertisement{}
+	case ipv6.ICMPTypeNeighborSolicitation:
+		m = &ICMPNeighborSolicitation{}
+	case ipv6.ICMPTypeNeighborAdvertisement:
+		m = &ICMPNeighborAdvertisement{}
+	case ipv6.ICMPTypeRedirect:
+		m = &ICMPRedirect{}
+	case ipv6.ICMPTypeCertificationPathSolicitation:
+		m = &ICMPCertificationPathSolicitation{}
+	case ipv6.ICMPTypeCertificationPathAdvertisement:
+		m = &ICMPCertificationPathAdvertisement{}
+	case ipv6.ICMPTypeDuplicateAddressRequest:
+		m = &ICMPDuplicateAddressRequest{}
+	case ipv6.ICMPTypeDuplicateAddressConfirmation:
+		m = &ICMPDuplicateAddressConfirmation{}
+	default:
+		return nil, fmt.Errorf("unsupported json message type %d", typ)
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ParseOptionJSON returns the option of the JSON object b, of the type its
+// type field holds, as MarshalJSON of that option encodes it. Types without
+// an implementation result in an ICMPOptionUnknown
+func ParseOptionJSON(b []byte) (ICMPOption, error) {
+	typ, err := jsonType(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var o ICMPOption
+	switch ICMPOptionType(typ) {
+	case ICMPOptionTypeSourceLinkLayerAddress:
+		o = &ICMPOptionSourceLinkLayerAddress{}
+	case ICMPOptionTypeTargetLinkLayerAddress:
+		o = &ICMPOptionTargetLinkLayerAddress{}
+	case ICMPOptionTypePrefixInformation:
+		o = &ICMPOptionPrefixInformation{}
+	case ICMPOptionTypeMTU:
+		o = &ICMPOptionMTU{}
+	case ICMPOptionTypeRedirectedHeader:
+		o = &ICMPOptionRedirectedHeader{}
+	case ICMPOptionTypeTimestamp:
+		o = &ICMPOptionTimestamp{}
+	case ICMPOptionTypeNonce:
+		o = &ICMPOptionNonce{}
+	case ICMPOptionTypeCGA:
+		o = &ICMPOptionCGA{}
+	case ICMPOptionTypeRSASignature:
+		o = &ICMPOptionRSASignature{}
+	case ICMPOptionTypeTrustAnchor:
+		o = &ICMPOptionTrustAnchor{}
+	case ICMPOptionTypeCertificate:
+		o = &ICMPOptionCertificate{}
+	case ICMPOptionTypeLinkLayerAddress:
+		o = &ICMPOptionLinkLayerAddress{}
+	case ICMPOptionTypePvD:
+		o = &ICMPOptionPvD{}
+	case ICMPOptionTypeRouteInformation:
+		o = &ICMPOptionRouteInformation{}
+	case ICMPOptionTypeRecursiveDNSServer:
+		o = &ICMPOptionRecursiveDNSServer{}
+	case ICMPOptionTypeHandoverKeyRequest:
+		o = &ICMPOptionHandoverKeyRequest{}
+	case ICMPOptionTypeHandoverKeyReply:
+		o = &ICMPOptionHandoverKeyReply{}
+	case ICMPOptionTypeDNSSearchList:
+		o = &ICMPOptionDNSSearchList{}
+	case ICMPOptionTypeAddressRegistration:
+		o = &ICMPOptionAddressRegistration{}
+	case ICMPOptionTypeSixLoWPANContext:
+		o = &ICMPOptionSixLoWPANContext{}
+	case ICMPOptionTypeAuthoritativeBorderRouter:
+		o = &ICMPOptionAuthoritativeBorderRouter{}
+	case ICMPOptionTypePREF64:
+		o = &ICMPOptionPREF64{}
+	default:
+		o = &ICMPOptionUnknown{}
+	}
+	if err := json.Unmarshal(b, o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// jsonType returns the type field of JSON object b
+func jsonType(b []byte) (int, error) {
+	var v struct {
+		Type *int `json:"type"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return 0, err
+	}
+	if v.Type == nil {
+		return 0, errJSONNoType
+	}
+
+	return *v.Type, nil
+}
+
+// unmarshalJSON decodes JSON object b into message or option v, failing if
+// its type isn't typ
+func unmarshalJSON(b []byte, typ int, v interface{}) error {
+	t, err := decodeJSON(b, reflect.ValueOf(v).Elem())
+	if err != nil {
+		return err
+	}
+	if t != nil && *t != typ {
+		return fmt.Errorf("json object of type %d, not %d", *t, typ)
+	}
+
+	return nil
+}
+
+// decodeJSON resets struct v to its zero value and decodes the fields of JSON
+// object b into it, returning its type field if it has one
+func decodeJSON(b []byte, v reflect.Value) (*int, error) {
+	if string(bytes.TrimSpace(b)) == "null" {
+		return nil, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+
+	var typ *int
+	if raw, ok := obj["type"]; ok {
+		if err := json.Unmarshal(raw, &typ); err != nil {
+			return nil, fmt.Errorf("type: %s", err)
+		}
+	}
+	delete(obj, "type")
+	delete(obj, "name")
+
+	v.Set(reflect.Zero(v.Type()))
+	if err := decodeJSONFields(obj, v); err != nil {
+		return nil, err
+	}
+
+	return typ, nil
+}
+
+// decodeJSONFields decodes the fields of obj into the exported fields of
+// struct v and the structs it embeds, failing on fields v doesn't have
+func decodeJSONFields(obj map[string]json.RawMessage, v reflect.Value) error {
+	if err := decodeJSONEmbedded(obj, v); err != nil {
+		return err
+	}
+	for name := range obj {
+		return fmt.Errorf("unknown field %q in %s", name, v.Type().Name())
+	}
+
+	return nil
+}
+
+// decodeJSONEmbedded decodes and removes the fields of obj that struct v and
+// the structs it embeds have
+func decodeJSONEmbedded(obj map[string]json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := decodeJSONEmbedded(obj, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := jsonName(f.Name)
+		raw, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if err := decodeJSONValue(raw, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		delete(obj, name)
+	}
+
+	return nil
+}
+
+// decodeJSONValue decodes raw into field value v, as jsonValue encodes it
+func decodeJSONValue(raw json.RawMessage, v reflect.Value) error {
+	null := string(bytes.TrimSpace(raw)) == "null"
+	if null && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Slice) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch {
+	case v.Type() == ipType:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("invalid address %q", s)
+		}
+		v.Set(reflect.ValueOf(ip))
+	case v.Type() == hwType:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		addr, err := parseHardwareAddr(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(addr))
+	case v.Type() == timeType:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Type() == optionType:
+		o, err := ParseOptionJSON(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(o))
+	case v.Kind() == reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := decodeJSONValue(raw, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.PtrTo(v.Type()).Implements(unmarshalerType):
+		return json.Unmarshal(raw, v.Addr().Interface())
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.Uint8:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Array {
+			if len(b) != v.Len() {
+				return fmt.Errorf("%d bytes instead of %d", len(b), v.Len())
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		v.SetBytes(b)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Array {
+			if len(elems) != v.Len() {
+				return fmt.Errorf("%d elements instead of %d", len(elems), v.Len())
+			}
+		} else {
+			v.Set(reflect.MakeSlice(v.Type(), len(elems), len(elems)))
+		}
+		for i, elem := range elems {
+			if err := decodeJSONValue(elem, v.Index(i)); err != nil {
+				return fmt.Errorf("%d: %s", i, err)
+			}
+		}
+	case v.Kind() == reflect.Struct:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return err
+		}
+		if obj == nil {
+			return errJSONNotObject
+		}
+		return decodeJSONFields(obj, v)
+	default:
+		return json.Unmarshal(raw, v.Addr().Interface())
+	}
+
+	return nil
+}
+
+// parseHardwareAddr returns the link-layer address of s, as colon separated
+// octets in hex like net.HardwareAddr.String returns. Unlike net.ParseMAC it
+// accepts addresses of any length
+func parseHardwareAddr(s string) (net.HardwareAddr, error) {
+	if s == "" {
+		return net.HardwareAddr{}, nil
+	}
+
+	var addr net.HardwareAddr
+	for _, octet := range strings.Split(s, ":") {
+		b, err := hex.DecodeString(octet)
+		if len(octet) != 2 || err != nil {
+			return nil, fmt.Errorf("invalid link-layer address %q", s)
+		}
+		addr = append(addr, b[0])
+	}
+
+	return addr, nil
+}
+
+// marshalJSON returns the JSON object of message or option v, of type typ
+// named name
+func marshalJSON(typ int, name string, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	b, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(`{"type":`)
+	buf.Write(jsonInt(typ))
+	buf.WriteString(`,"name":`)
+	buf.Write(b)
+	if err := writeJSONFields(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// jsonInt returns the JSON encoding of n
+func jsonInt(n int) []byte {
+	b, _ := json.Marshal(n)
+	return b
+}
+
+// writeJSONFields writes the exported fields of struct v to buf, each after
+// a comma, inlining the fields of embedded structs
+func writeJSONFields(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := writeJSONFields(buf, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		b, err := jsonValue(v.Field(i))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`,"`)
+		buf.WriteString(jsonName(f.Name))
+		buf.WriteString(`":`)
+		buf.Write(b)
+	}
+
+	return nil
+}
+
+// jsonValue returns the JSON encoding of field value v
+func jsonValue(v reflect.Value) ([]byte, error) {
+	switch {
+	case v.Type() == ipType:
+		if v.IsNil() {
+			return []byte("null"), nil
+		}
+		return json.Marshal(net.IP(v.Bytes()).String())
+	case v.Type() == hwType:
+		if v.IsNil() {
+			return []byte("null"), nil
+		}
+		return json.Marshal(net.HardwareAddr(v.Bytes()).String())
+	case v.Type() == timeType:
+		return json.Marshal(v.Interface().(time.Time).Format(time.RFC3339Nano))
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface:
+		if v.IsNil() {
+			return []byte("null"), nil
+		}
+		if v.Type().Implements(marshalerType) {
+			return json.Marshal(v.Interface())
+		}
+		return jsonValue(v.Elem())
+	case v.Type().Implements(marshalerType):
+		return json.Marshal(v.Interface())
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.Uint8:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return json.Marshal(hex.EncodeToString(b))
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []byte("null"), nil
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			b, err := jsonValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	case v.Kind() == reflect.Struct:
+		var buf bytes.Buffer
+		if err := writeJSONFields(&buf, v); err != nil {
+			return nil, err
+		}
+		// the fields are written after commas
+		b := buf.Bytes()
+		if len(b) > 0 {
+			b = b[1:]
+		}
+		return append(append([]byte{'{'}, b...), '}'), nil
+	}
+
+	return json.Marshal(v.Interface())
+}
+
+// jsonName returns the snake case JSON name of field name, keeping
+// abbreviations like EUI64 and FQDN together
+func jsonName(name string) string {
+	r := []rune(name)
+	var out []rune
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				out = append(out, '_')
+			}
+		}
+		out = append(out, unicode.ToLower(c))
+	}
+
+	return string(out)
+}
@@ -0,0 +1,326 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out string
+	}{
+		{
+			&ICMPNeighborSolicitation{
+				optionContainer: optionContainer{Options: ICMPOptions{
+					&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}},
+				}},
+				TargetAddress: net.ParseIP("fe80::2"),
+			},
+			`{"type":135,"name":"neighbor solicitation","options":[{"type":1,"name":"source link-layer address","link_layer_address":"02:00:00:00:00:01"}],"target_address":"fe80::2"}`,
+		},
+		{
+			ICMPNeighborAdvertisement{Router: true, Override: true},
+			`{"type":136,"name":"neighbor advertisement","options":null,"router":true,"solicited":false,"override":true,"target_address":null}`,
+		},
+		{
+			ICMPOptionPrefixInformation{
+				PrefixLength:  64,
+				OnLink:        true,
+				Auto:          true,
+				ValidLifetime: 86400,
+				Prefix:        net.ParseIP("2001:db8::"),
+			},
+			`{"type":3,"name":"prefix info","prefix_length":64,"on_link":true,"auto":true,"router_address":false,"reserved1":0,"valid_lifetime":86400,"preferred_lifetime":0,"reserved2":0,"prefix":"2001:db8::"}`,
+		},
+		{
+			ICMPOptionDNSSearchList{Lifetime: 600, DomainNames: []string{"example.com"}},
+			`{"type":31,"name":"dnssl","lifetime":600,"domain_names":["example.com"]}`,
+		},
+		{
+			ICMPOptionNonce{Nonce: 0xdeadbeef0001},
+			`{"type":14,"name":"nonce","nonce":244837814042625}`,
+		},
+		{
+			ICMPOptionTimestamp{Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)},
+			`{"type":13,"name":"timestamp","timestamp":"2020-01-02T03:04:05.5Z"}`,
+		},
+		{
+			ICMPDuplicateAddressConfirmation{
+				EUI64:             net.HardwareAddr{0x02, 0, 0, 0, 0, 0, 0, 0x01},
+				RegisteredAddress: net.ParseIP("2001:db8::1"),
+			},
+			`{"type":158,"name":"duplicate address confirmation","status":0,"registration_lifetime":0,"eui64":"02:00:00:00:00:00:00:01","registered_address":"2001:db8::1"}`,
+		},
+		{
+			ICMPOptionPvD{
+				FQDN:                "pvd.example.com",
+				RouterAdvertisement: &ICMPRouterAdvertisement{HopLimit: 64},
+			},
+			`{"type":21,"name":"pvd","options":null,"http":false,"legacy":false,"delay":0,"sequence_number":0,"fqdn":"pvd.example.com","router_advertisement":{"type":134,"name":"router advertisement","options":null,"hop_limit":64,"managed_address":false,"other_stateful":false,"home_agent":false,"router_preference":0,"router_life_time":0,"reachable_time":0,"retrans_timer":0}}`,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := json.Marshal(test.in)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %T: %s", test.in, err)
+			continue
+		}
+		if string(b) != test.out {
+			t.Errorf("unexpected JSON of %T\nexpected %s\ngot      %s", test.in, test.out, b)
+		}
+	}
+}
+
+func TestMarshalJSONCGA(t *testing.T) {
+	o := ICMPOptionCGA{Parameters: CGAParameters{
+		CollisionCount: 1,
+		PublicKey:      []byte{0x30, 0x01},
+		Extensions:     []CGAExtension{{Type: 1, Data: []byte{0xff}}},
+	}}
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("unexpected error decoding %s: %s", b, err)
+	}
+	params, ok := v["parameters"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected parameters object in %s", b)
+	}
+	if params["modifier"] != "00000000000000000000000000000000" {
+		t.Errorf("unexpected modifier %v", params["modifier"])
+	}
+	if params["public_key"] != "3001" {
+		t.Errorf("unexpected public key %v", params["public_key"])
+	}
+	exts, _ := params["extensions"].([]interface{})
+	if len(exts) != 1 {
+		t.Fatalf("unexpected extensions %v", params["extensions"])
+	}
+	if ext := exts[0].(map[string]interface{}); ext["data"] != "ff" || ext["type"] != float64(1) {
+		t.Errorf("unexpected extension %v", ext)
+	}
+}
+
+func TestJSONName(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"TargetAddress", "target_address"},
+		{"EUI64", "eui64"},
+		{"FQDN", "fqdn"},
+		{"HTTP", "http"},
+		{"RouterLifeTime", "router_life_time"},
+		{"PREF64Lifetime", "pref64_lifetime"},
+		{"ROVR", "rovr"},
+		{"KeyHash", "key_hash"},
+		{"Reserved1", "reserved1"},
+	}
+
+	for _, test := range tests {
+		if out := jsonName(test.in); out != test.out {
+			t.Errorf("expected %s for %s, not %s", test.out, test.in, out)
+		}
+	}
+}
+
+func TestMarshalJSONAll(t *testing.T) {
+	// every message and option encodes to an object of its type, even empty
+	for _, v := range []interface{}{
+		ICMPRouterSolicitation{}, ICMPRouterAdvertisement{},
+		ICMPNeighborSolicitation{}, ICMPNeighborAdvertisement{},
+		ICMPRedirect{}, ICMPCertificationPathSolicitation{},
+		ICMPCertificationPathAdvertisement{}, ICMPDuplicateAddressRequest{},
+		ICMPDuplicateAddressConfirmation{},
+		ICMPOptionUnknown{}, ICMPOptionSourceLinkLayerAddress{},
+		ICMPOptionTargetLinkLayerAddress{}, ICMPOptionPrefixInformation{},
+		ICMPOptionMTU{}, ICMPOptionRedirectedHeader{}, ICMPOptionTimestamp{},
+		ICMPOptionNonce{}, ICMPOptionCGA{}, ICMPOptionRSASignature{},
+		ICMPOptionTrustAnchor{}, ICMPOptionCertificate{},
+		ICMPOptionLinkLayerAddress{}, ICMPOptionPvD{},
+		ICMPOptionRouteInformation{}, ICMPOptionRecursiveDNSServer{},
+		ICMPOptionHandoverKeyRequest{}, ICMPOptionHandoverKeyReply{},
+		ICMPOptionDNSSearchList{}, ICMPOptionAddressRegistration{},
+		ICMPOptionSixLoWPANContext{}, ICMPOptionAuthoritativeBorderRouter{},
+		ICMPOptionPREF64{},
+	} {
+		if _, ok := v.(json.Marshaler); !ok {
+			t.Errorf("%T doesn't implement json.Marshaler", v)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %T: %s", v, err)
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			t.Errorf("unexpected error decoding %T: %s", v, err)
+			continue
+		}
+		if _, ok := obj["type"].(float64); !ok {
+			t.Errorf("expected type in JSON of %T: %s", v, b)
+		}
+	}
+}
+
+func TestParseMessageJSON(t *testing.T) {
+	raw, err := NewRawOption(253, []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	messages := []ICMP{
+		&ICMPRouterSolicitation{optionContainer: optionContainer{Options: ICMPOptions{
+			&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}},
+		}}},
+		&ICMPRouterAdvertisement{
+			optionContainer: optionContainer{Options: ICMPOptions{
+				&ICMPOptionMTU{MTU: 1500},
+				&ICMPOptionPrefixInformation{PrefixLength: 64, OnLink: true, Auto: true, ValidLifetime: 86400, PreferredLifetime: 14400, Prefix: net.ParseIP("2001:db8::")},
+				&ICMPOptionRouteInformation{PrefixLength: 48, Preference: RouterPreferenceHigh, RouteLifetime: 1800, Prefix: net.ParseIP("2001:db8:1::")},
+				&ICMPOptionRecursiveDNSServer{Lifetime: 600, Servers: []net.IP{net.ParseIP("2001:db8::53")}},
+				&ICMPOptionDNSSearchList{Lifetime: 600, DomainNames: []string{"example.com"}},
+				raw,
+			}},
+			HopLimit:         64,
+			ManagedAddress:   true,
+			RouterPreference: RouterPreferenceLow,
+			RouterLifeTime:   1800,
+			ReachableTime:    30000,
+		},
+		&ICMPNeighborSolicitation{
+			optionContainer: optionContainer{Options: ICMPOptions{&ICMPOptionNonce{Nonce: 0x010203040506}}},
+			TargetAddress:   net.ParseIP("fe80::2"),
+		},
+		&ICMPNeighborAdvertisement{Solicited: true, Override: true, TargetAddress: net.ParseIP("fe80::2")},
+		&ICMPRedirect{
+			optionContainer:    optionContainer{Options: ICMPOptions{&ICMPOptionRedirectedHeader{Packet: make([]byte, 40)}}},
+			TargetAddress:      net.ParseIP("fe80::3"),
+			DestinationAddress: net.ParseIP("2001:db8::4"),
+		},
+		&ICMPDuplicateAddressRequest{
+			RegistrationLifetime: 60,
+			EUI64:                net.HardwareAddr{0x02, 0, 0, 0, 0, 0, 0, 0x01},
+			RegisteredAddress:    net.ParseIP("2001:db8::1"),
+		},
+	}
+
+	for _, m := range messages {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %s: %s", m.Type(), err)
+			continue
+		}
+		parsed, err := ParseMessageJSON(b)
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %s", b, err)
+			continue
+		}
+		expected, _ := m.Marshal()
+		got, err := parsed.Marshal()
+		if err != nil {
+			t.Errorf("unexpected error marshaling parsed %s: %s", m.Type(), err)
+			continue
+		}
+		if !bytes.Equal(expected, got) {
+			t.Errorf("%s changed in a JSON round trip\nexpected %x\ngot      %x", m.Type(), expected, got)
+		}
+	}
+}
+
+func TestParseMessageJSONFixture(t *testing.T) {
+	// fixtures only hold the fields that matter
+	m, err := ParseMessageJSON([]byte(`{
+		"type": 136,
+		"router": true,
+		"target_address": "2001:db8::1",
+		"options": [
+			{"type": 2, "link_layer_address": "02:00:00:00:00:01"},
+			{"type": 250, "body": "000000000000"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	na, ok := m.(*ICMPNeighborAdvertisement)
+	if !ok {
+		t.Fatalf("unexpected message %T", m)
+	}
+	if !na.Router || na.Solicited || !na.TargetAddress.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("unexpected message %s", na)
+	}
+	if len(na.Options) != 2 {
+		t.Fatalf("unexpected options %v", na.Options)
+	}
+	if o, ok := na.Options[0].(*ICMPOptionTargetLinkLayerAddress); !ok || o.LinkLayerAddress.String() != "02:00:00:00:00:01" {
+		t.Errorf("unexpected option %v", na.Options[0])
+	}
+	if o, ok := na.Options[1].(*ICMPOptionUnknown); !ok || o.OptionType != 250 || len(o.Body) != 6 {
+		t.Errorf("unexpected option %v", na.Options[1])
+	}
+}
+
+func TestParseMessageJSONErrors(t *testing.T) {
+	for _, s := range []string{
+		`[]`,
+		`{"target_address":"fe80::1"}`,
+		`{"type":1}`,
+		`{"type":135,"target":"fe80::1"}`,
+		`{"type":135,"target_address":"fe80::g"}`,
+		`{"type":135,"options":[{"type":1,"link_layer_address":"02:00:0"}]}`,
+		`{"type":135,"options":[{"link_layer_address":"02:00:00:00:00:01"}]}`,
+		`{"type":137,"options":[{"type":4,"packet":"zz"}]}`,
+	} {
+		if _, err := ParseMessageJSON([]byte(s)); err == nil {
+			t.Errorf("expected error parsing %s", s)
+		}
+	}
+
+	// decoding into a message checks it is of its type
+	var ns ICMPNeighborSolicitation
+	if err := json.Unmarshal([]byte(`{"type":136}`), &ns); err == nil {
+		t.Error("expected error decoding neighbor advertisement into neighbor solicitation")
+	}
+}
+
+func TestParseOptionJSON(t *testing.T) {
+	opts := []ICMPOption{
+		&ICMPOptionTimestamp{Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		&ICMPOptionCGA{Parameters: CGAParameters{
+			Modifier:       [16]byte{1, 2, 3},
+			SubnetPrefix:   [8]byte{0x20, 0x01, 0x0d, 0xb8},
+			CollisionCount: 1,
+			PublicKey:      []byte{0x30, 0x01, 0x02},
+			Extensions:     []CGAExtension{{Type: 1, Data: []byte{0xff}}},
+		}},
+		&ICMPOptionAddressRegistration{RegistrationLifetime: 60, EUI64: net.HardwareAddr{0x02, 0, 0, 0, 0, 0, 0, 0x01}},
+		&ICMPOptionSixLoWPANContext{ContextLength: 64, Compression: true, ContextID: 1, ValidLifetime: 60, Prefix: net.ParseIP("2001:db8::")},
+	}
+
+	for _, o := range opts {
+		b, err := json.Marshal(o)
+		if err != nil {
+			t.Errorf("unexpected error marshaling %s: %s", o.Type(), err)
+			continue
+		}
+		parsed, err := ParseOptionJSON(b)
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %s", b, err)
+			continue
+		}
+		expected, _ := o.Marshal()
+		got, _ := parsed.Marshal()
+		if !bytes.Equal(expected, got) {
+			t.Errorf("%s changed in a JSON round trip\nexpected %x\ngot      %x", o.Type(), expected, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package ndp
+
+import (
+	"context"
+	"log/slog"
+	"net"
+)
+
+// discardHandler is an slog.Handler dropping all records, which Conns log to
+// until SetLogger is called
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// discardLogger drops all records
+var discardLogger = slog.New(discardHandler{})
+
+// SetLogger sets l as the logger this Conn and the subsystems serving on it
+// log to, such as a NeighborCache, RAServer or SLAACClient. Records carry the
+// ifname of the interface of the Conn and, for messages, their type and
+// source or destination address. Dropped and sent messages are logged at
+// debug level, changes of state at info level and attacks and conflicts as
+// warnings. Without a logger nothing is logged. It must not be called while
+// other goroutines use this Conn
+func (c *Conn) SetLogger(l *slog.Logger) {
+	if l != nil && c.ifi != nil {
+		l = l.With("ifname", c.ifi.Name)
+	}
+	c.log = l
+}
+
+// logger returns the logger of this Conn, which discards records when none
+// was set
+func (c *Conn) logger() *slog.Logger {
+	if c == nil || c.log == nil {
+		return discardLogger
+	}
+
+	return c.log
+}
+
+// loggerOr returns l, or a logger discarding records if it is nil
+func loggerOr(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return discardLogger
+	}
+
+	return l
+}
+
+// receivedAttrs returns the attributes of message m received with md, where
+// m is nil when it failed to parse
+func receivedAttrs(m ICMP, md *Metadata) []any {
+	var attrs []any
+	if m != nil {
+		attrs = append(attrs, "type", m.Type().String())
+	}
+	if md != nil && md.Source != nil {
+		attrs = append(attrs, "src", md.Source.String())
+	}
+
+	return attrs
+}
+
+// sentAttrs returns the attributes of message m sent to dst
+func sentAttrs(m ICMP, dst net.IP) []any {
+	return []any{"type", m.Type().String(), "dst", dst.String()}
+}
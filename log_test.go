@@ -0,0 +1,103 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+// newTestLogger returns a logger writing text records of all levels to buf
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestConnSetLogger(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	var abuf, bbuf bytes.Buffer
+	a.SetLogger(newTestLogger(&abuf))
+	b.SetLogger(newTestLogger(&bbuf))
+
+	if err := a.WriteTo(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}, nil, net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.ReadFrom(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`msg="sent message"`, "ifname=pipe0", `type="neighbor solicitation"`, "dst=fe80::2"} {
+		if !strings.Contains(abuf.String(), s) {
+			t.Errorf("expected %s in %q", s, abuf.String())
+		}
+	}
+
+	// messages that fail to parse are logged along with their source
+	if _, err := a.t.WriteTo([]byte{byte(135), 0, 0, 0}, &Metadata{Source: net.ParseIP("fe80::1")}, net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.ReadFrom(); err == nil {
+		t.Fatal("expected error reading malformed message")
+	}
+	for _, s := range []string{`msg="dropped message"`, "ifname=pipe1", "src=fe80::1", "err="} {
+		if !strings.Contains(bbuf.String(), s) {
+			t.Errorf("expected %s in %q", s, bbuf.String())
+		}
+	}
+}
+
+func TestConnWithoutLogger(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	// nothing is logged, nor does logging fail, without a logger
+	if a.logger().Enabled(context.Background(), slog.LevelError) {
+		t.Error("expected records to be discarded without logger")
+	}
+	a.SetLogger(newTestLogger(&bytes.Buffer{}))
+	a.SetLogger(nil)
+	if err := a.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRAGuardLogger(t *testing.T) {
+	var buf bytes.Buffer
+	g := NewRAGuard(RAGuardRule{Source: net.ParseIP("fe80::1")})
+	g.Logger = newTestLogger(&buf)
+
+	g.ServeNDP(&ICMPRouterAdvertisement{}, &Metadata{Source: net.ParseIP("fe80::1")})
+	if buf.Len() != 0 {
+		t.Errorf("unexpected record for legitimate advertisement: %s", buf.String())
+	}
+	g.ServeNDP(&ICMPRouterAdvertisement{}, &Metadata{Source: net.ParseIP("fe80::66")})
+	for _, s := range []string{"level=WARN", `msg="rejected rogue router advertisement"`, "src=fe80::66", "reason="} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %s in %q", s, buf.String())
+		}
+	}
+}
+
+func TestBindingWatcherLogger(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewBindingWatcher()
+	w.Logger = newTestLogger(&buf)
+
+	ip := net.ParseIP("2001:db8::1")
+	w.Observe(ip, net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}, &Metadata{})
+	w.Observe(ip, net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}, &Metadata{})
+	records := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(records) != 2 {
+		t.Fatalf("unexpected records %q", records)
+	}
+	if !strings.Contains(records[0], "level=INFO") || !strings.Contains(records[0], `msg="binding new"`) {
+		t.Errorf("unexpected record %s", records[0])
+	}
+	if !strings.Contains(records[1], "level=WARN") || !strings.Contains(records[1], `msg="binding conflict"`) || !strings.Contains(records[1], "previous=02:00:00:00:00:01") {
+		t.Errorf("unexpected record %s", records[1])
+	}
+}
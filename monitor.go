@@ -0,0 +1,415 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errNotMonitor = errors.New("monitors need a conn with RoleMonitor")
+)
+
+// the defaults of Monitor
+const (
+	defaultDADWindow      = 10 * time.Second
+	defaultMaxMonitorKeep = 4096
+)
+
+// MonitorEventType describes what Monitor noticed on the link
+type MonitorEventType int
+
+// Monitor event types
+const (
+	// MonitorRouterNew is a router advertising for the first time
+	MonitorRouterNew MonitorEventType = iota
+	// MonitorRouterChanged is a router advertising other content than
+	// before
+	MonitorRouterChanged
+	// MonitorRouterWithdrawn is a router advertising a router lifetime of 0
+	// after advertising itself as default router before
+	MonitorRouterWithdrawn
+	// MonitorRouterRogue is a router advertisement the Guard rejected
+	MonitorRouterRogue
+	// MonitorDADStarted is a host starting duplicate address detection for
+	// an address
+	MonitorDADStarted
+	// MonitorDADDuplicate is an address another node defended, or probed
+	// for too, during duplicate address detection
+	MonitorDADDuplicate
+)
+
+func (t MonitorEventType) String() string {
+	switch t {
+	case MonitorRouterNew:
+		return "new router"
+	case MonitorRouterChanged:
+		return "router changed"
+	case MonitorRouterWithdrawn:
+		return "router withdrawn"
+	case MonitorRouterRogue:
+		return "rogue router"
+	case MonitorDADStarted:
+		return "dad started"
+	case MonitorDADDuplicate:
+		return "dad duplicate"
+	}
+
+	return "unknown"
+}
+
+// MonitorRouter is the state Monitor keeps of a router on the link
+type MonitorRouter struct {
+	Address          net.IP
+	LinkLayerAddress net.HardwareAddr
+	// Advertisement is the last router advertisement it sent
+	Advertisement  *ICMPRouterAdvertisement
+	Advertisements int
+	// Rogue is set when the Guard rejected the last advertisement
+	Rogue     bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// DADActivity is the duplicate address detection Monitor saw for an address
+type DADActivity struct {
+	Address net.IP
+	// LinkLayerAddress is that of the node probing for Address last
+	LinkLayerAddress net.HardwareAddr
+	Probes           int
+	// Duplicate is set once another node defended Address, or probed for it
+	// as well, while it was being probed for
+	Duplicate bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// MonitorEvent is reported by Monitor
+type MonitorEvent struct {
+	Type MonitorEventType
+	// Router is set for router events
+	Router *MonitorRouter
+	// Rogue is set for MonitorRouterRogue
+	Rogue *RogueRA
+	// DAD is set for duplicate address detection events
+	DAD *DADActivity
+	// Metadata is that of the message that caused the event
+	Metadata *Metadata
+}
+
+func (e MonitorEvent) String() string {
+	if e.Router != nil {
+		return fmt.Sprintf("%s %s at %s", e.Type, e.Router.Address, e.Router.LinkLayerAddress)
+	}
+	if e.DAD != nil {
+		return fmt.Sprintf("%s for %s at %s", e.Type, e.DAD.Address, e.DAD.LinkLayerAddress)
+	}
+
+	return e.Type.String()
+}
+
+// Monitor passively watches the NDP messages of a link and keeps the state
+// of its routers, neighbor bindings and duplicate address detection, like
+// NDPMon does. It reports what changes to Events, so it can be embedded in
+// tools alerting on rogue routers, spoofing and address conflicts. The Conn
+// must have been created with RoleMonitor and see all messages on the link,
+// like one ListenFrames returns in promiscuous mode does
+type Monitor struct {
+	// Events is called for every event. It must not call back into the
+	// Monitor
+	Events func(MonitorEvent)
+	// Bindings keeps the neighbor bindings, and reports their events
+	Bindings *BindingWatcher
+	// Guard, when set, checks every router advertisement, reporting the
+	// ones it rejects as MonitorRouterRogue
+	Guard *RAGuard
+	// DADWindow is how long after a probe another node claiming the address
+	// makes it a duplicate. It defaults to 10 seconds
+	DADWindow time.Duration
+	// MaxEntries bounds the routers and addresses probed for kept each,
+	// forgetting those seen longest ago. It defaults to 4096
+	MaxEntries int
+
+	c *Conn
+
+	mu      sync.Mutex
+	routers map[string]*MonitorRouter
+	dad     map[string]*DADActivity
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewMonitor returns a Monitor of the messages read from c, which must have
+// been created with RoleMonitor
+func NewMonitor(c *Conn) (*Monitor, error) {
+	if c.Role() != RoleMonitor {
+		return nil, errNotMonitor
+	}
+
+	return &Monitor{
+		Bindings:   NewBindingWatcher(),
+		DADWindow:  defaultDADWindow,
+		MaxEntries: defaultMaxMonitorKeep,
+		c:          c,
+		routers:    make(map[string]*MonitorRouter),
+		dad:        make(map[string]*DADActivity),
+		now:        time.Now,
+	}, nil
+}
+
+// Serve watches the messages read from the Conn until ctx is done or reading
+// fails
+func (mon *Monitor) Serve(ctx context.Context) error {
+	return mon.c.Serve(ctx, mon)
+}
+
+// ServeNDP records what m, received with md, tells about the link
+func (mon *Monitor) ServeNDP(m ICMP, md *Metadata) {
+	if md == nil {
+		return
+	}
+
+	var events []MonitorEvent
+	switch m := m.(type) {
+	case *ICMPRouterAdvertisement:
+		var rogue *RogueRA
+		if mon.Guard != nil {
+			rogue = mon.Guard.Check(m, md)
+		}
+		events = mon.advertised(m, md, rogue)
+	case *ICMPNeighborSolicitation:
+		if md.Source.IsUnspecified() {
+			events = mon.probed(m, md)
+		}
+	case *ICMPNeighborAdvertisement:
+		events = mon.defended(m, md)
+	}
+
+	if mon.Bindings != nil {
+		mon.Bindings.ServeNDP(m, md)
+	}
+	log := mon.c.logger()
+	for _, ev := range events {
+		level := slog.LevelInfo
+		if ev.Type == MonitorRouterRogue || ev.Type == MonitorDADDuplicate {
+			level = slog.LevelWarn
+		}
+		log.Log(context.Background(), level, ev.String(), receivedAttrs(m, md)...)
+	}
+	if mon.Events != nil {
+		for _, ev := range events {
+			mon.Events(ev)
+		}
+	}
+}
+
+// advertised records router advertisement ra, received with md, which is
+// rogue if set
+func (mon *Monitor) advertised(ra *ICMPRouterAdvertisement, md *Metadata, rogue *RogueRA) []MonitorEvent {
+	lla := sourceLinkLayerAddr(ra.Options)
+	if lla == nil {
+		lla = md.SourceLinkLayerAddress
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	now := mon.now()
+	key := md.Source.String()
+	r, ok := mon.routers[key]
+	var ev *MonitorEvent
+	switch {
+	case !ok:
+		mon.evictRouter()
+		r = &MonitorRouter{
+			Address:   append(net.IP(nil), md.Source.To16()...),
+			FirstSeen: now,
+		}
+		mon.routers[key] = r
+		ev = &MonitorEvent{Type: MonitorRouterNew}
+	case ra.RouterLifeTime == 0 && r.Advertisement.RouterLifeTime != 0:
+		ev = &MonitorEvent{Type: MonitorRouterWithdrawn}
+	case !sameAdvertisement(ra, r.Advertisement):
+		ev = &MonitorEvent{Type: MonitorRouterChanged}
+	}
+	if lla != nil {
+		r.LinkLayerAddress = append(net.HardwareAddr(nil), lla...)
+	}
+	r.Advertisement = ra
+	r.Advertisements++
+	r.Rogue = rogue != nil
+	r.LastSeen = now
+
+	var events []MonitorEvent
+	rc := *r
+	if ev != nil {
+		ev.Router, ev.Metadata = &rc, md
+		events = append(events, *ev)
+	}
+	if rogue != nil {
+		events = append(events, MonitorEvent{Type: MonitorRouterRogue, Router: &rc, Rogue: rogue, Metadata: md})
+	}
+
+	return events
+}
+
+// sameAdvertisement reports whether a and b advertise the same
+func sameAdvertisement(a, b *ICMPRouterAdvertisement) bool {
+	ab, aerr := a.Marshal()
+	bb, berr := b.Marshal()
+
+	return aerr == nil && berr == nil && bytes.Equal(ab, bb)
+}
+
+// probed records duplicate address detection solicitation ns, received with
+// md
+func (mon *Monitor) probed(ns *ICMPNeighborSolicitation, md *Metadata) []MonitorEvent {
+	lla := md.SourceLinkLayerAddress
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	now := mon.now()
+	key := ns.TargetAddress.String()
+	d, ok := mon.dad[key]
+	var ev *MonitorEvent
+	switch {
+	case !ok || now.Sub(d.LastSeen) >= mon.DADWindow:
+		// probes after a while are another round of detection
+		if !ok {
+			mon.evictDAD()
+			d = &DADActivity{Address: append(net.IP(nil), ns.TargetAddress.To16()...)}
+			mon.dad[key] = d
+		}
+		d.FirstSeen, d.Probes, d.Duplicate = now, 0, false
+		ev = &MonitorEvent{Type: MonitorDADStarted}
+	case lla != nil && d.LinkLayerAddress != nil && !bytes.Equal(lla, d.LinkLayerAddress) && !d.Duplicate:
+		// two nodes probing for the same address at once
+		d.Duplicate = true
+		ev = &MonitorEvent{Type: MonitorDADDuplicate}
+	}
+	if lla != nil {
+		d.LinkLayerAddress = append(net.HardwareAddr(nil), lla...)
+	}
+	d.Probes++
+	d.LastSeen = now
+	if ev == nil {
+		return nil
+	}
+
+	dc := *d
+	ev.DAD, ev.Metadata = &dc, md
+	return []MonitorEvent{*ev}
+}
+
+// defended records neighbor advertisement na, received with md, which makes
+// the address probed for recently a duplicate when another node sent it
+func (mon *Monitor) defended(na *ICMPNeighborAdvertisement, md *Metadata) []MonitorEvent {
+	lla := md.SourceLinkLayerAddress
+	for _, o := range na.Options {
+		if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+			lla = o.LinkLayerAddress
+		}
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	d, ok := mon.dad[na.TargetAddress.String()]
+	if !ok || d.Duplicate || mon.now().Sub(d.LastSeen) >= mon.DADWindow {
+		return nil
+	}
+	// the node that probed may announce its address once done
+	if lla == nil || bytes.Equal(lla, d.LinkLayerAddress) {
+		return nil
+	}
+	d.Duplicate = true
+
+	dc := *d
+	return []MonitorEvent{{Type: MonitorDADDuplicate, DAD: &dc, Metadata: md}}
+}
+
+// evictRouter makes room for a new router by forgetting the one seen
+// longest ago. It must be called with mu held
+func (mon *Monitor) evictRouter() {
+	if mon.MaxEntries <= 0 || len(mon.routers) < mon.MaxEntries {
+		return
+	}
+
+	var oldest string
+	for k, r := range mon.routers {
+		if oldest == "" || r.LastSeen.Before(mon.routers[oldest].LastSeen) {
+			oldest = k
+		}
+	}
+	delete(mon.routers, oldest)
+}
+
+// evictDAD makes room for a new address probed for by forgetting the one
+// probed for longest ago. It must be called with mu held
+func (mon *Monitor) evictDAD() {
+	if mon.MaxEntries <= 0 || len(mon.dad) < mon.MaxEntries {
+		return
+	}
+
+	var oldest string
+	for k, d := range mon.dad {
+		if oldest == "" || d.LastSeen.Before(mon.dad[oldest].LastSeen) {
+			oldest = k
+		}
+	}
+	delete(mon.dad, oldest)
+}
+
+// Router returns the state of the router at ip, if seen
+func (mon *Monitor) Router(ip net.IP) (MonitorRouter, bool) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	r, ok := mon.routers[ip.String()]
+	if !ok {
+		return MonitorRouter{}, false
+	}
+
+	return *r, true
+}
+
+// Routers returns the state of all routers seen, sorted by address
+func (mon *Monitor) Routers() []MonitorRouter {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	routers := make([]MonitorRouter, 0, len(mon.routers))
+	for _, r := range mon.routers {
+		routers = append(routers, *r)
+	}
+	sort.Slice(routers, func(i, j int) bool {
+		return bytes.Compare(routers[i].Address, routers[j].Address) < 0
+	})
+
+	return routers
+}
+
+// DAD returns the duplicate address detection seen for every address,
+// sorted by address
+func (mon *Monitor) DAD() []DADActivity {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	dad := make([]DADActivity, 0, len(mon.dad))
+	for _, d := range mon.dad {
+		dad = append(dad, *d)
+	}
+	sort.Slice(dad, func(i, j int) bool {
+		return bytes.Compare(dad[i].Address, dad[j].Address) < 0
+	})
+
+	return dad
+}
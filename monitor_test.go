@@ -0,0 +1,114 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+	if _, err := NewMonitor(a); err != errNotMonitor {
+		t.Errorf("unexpected error %v", err)
+	}
+	a.role = RoleMonitor
+
+	mon, err := NewMonitor(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	mon.now = func() time.Time { return now }
+	var events []MonitorEvent
+	mon.Events = func(ev MonitorEvent) { events = append(events, ev) }
+	expect := func(types ...MonitorEventType) {
+		t.Helper()
+		if len(events) != len(types) {
+			t.Fatalf("expected events %v, not %v", types, events)
+		}
+		for i, typ := range types {
+			if events[i].Type != typ {
+				t.Errorf("expected event %s, not %s", typ, events[i])
+			}
+		}
+		events = nil
+	}
+
+	router := net.ParseIP("fe80::1")
+	rmac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	ra := func(lifetime uint16) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: lifetime}
+		ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: rmac})
+		return ra
+	}
+	md := &Metadata{Source: router, Destination: net.IPv6linklocalallnodes, HopLimit: 255}
+
+	mon.ServeNDP(ra(1800), md)
+	expect(MonitorRouterNew)
+	mon.ServeNDP(ra(1800), md)
+	expect()
+	mon.ServeNDP(ra(900), md)
+	expect(MonitorRouterChanged)
+	mon.ServeNDP(ra(0), md)
+	expect(MonitorRouterWithdrawn)
+	if r, ok := mon.Router(router); !ok || r.Advertisements != 4 || r.LinkLayerAddress.String() != rmac.String() || r.Advertisement.RouterLifeTime != 0 {
+		t.Errorf("unexpected router %+v", r)
+	}
+
+	// routers the guard rejects are rogue
+	mon.Guard = NewRAGuard(RAGuardRule{Source: net.ParseIP("fe80::2")})
+	mon.ServeNDP(ra(0), md)
+	expect(MonitorRouterRogue)
+	if routers := mon.Routers(); len(routers) != 1 || !routers[0].Rogue {
+		t.Errorf("unexpected routers %+v", routers)
+	}
+
+	// neighbor bindings are kept as well
+	if bd, ok := mon.Bindings.Lookup(router); !ok || bd.LinkLayerAddress.String() != rmac.String() {
+		t.Errorf("unexpected binding %+v", bd)
+	}
+
+	hmac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x07}
+	other := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x08}
+	addr := net.ParseIP("2001:db8::7")
+	probe := func(lla net.HardwareAddr) {
+		mon.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: addr}, &Metadata{Source: net.IPv6unspecified, SourceLinkLayerAddress: lla})
+	}
+	defend := func(lla net.HardwareAddr) {
+		na := &ICMPNeighborAdvertisement{Override: true, TargetAddress: addr}
+		na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+		mon.ServeNDP(na, &Metadata{Source: addr, Destination: net.IPv6linklocalallnodes, SourceLinkLayerAddress: lla})
+	}
+
+	probe(hmac)
+	expect(MonitorDADStarted)
+	probe(hmac)
+	// the prober announcing its address is no duplicate
+	defend(hmac)
+	expect()
+	if dad := mon.DAD(); len(dad) != 1 || dad[0].Probes != 2 || dad[0].Duplicate {
+		t.Errorf("unexpected dad %+v", dad)
+	}
+
+	// another node is
+	defend(other)
+	expect(MonitorDADDuplicate)
+	defend(other)
+	expect()
+
+	// as is another node probing at the same time
+	now = now.Add(mon.DADWindow)
+	probe(hmac)
+	probe(other)
+	expect(MonitorDADStarted, MonitorDADDuplicate)
+
+	// but not after the window
+	now = now.Add(mon.DADWindow)
+	defend(hmac)
+	expect()
+	if dad := mon.DAD(); len(dad) != 1 || dad[0].Probes != 2 || !dad[0].Duplicate || dad[0].LinkLayerAddress.String() != other.String() {
+		t.Errorf("unexpected dad %+v", dad)
+	}
+}
@@ -0,0 +1,209 @@
+package ndp
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/net/ipv6"
+)
+
+// Handler handles NDP messages received by Conn.Serve
+type Handler interface {
+	ServeNDP(m ICMP, md *Metadata)
+}
+
+// HandlerFunc lets ordinary functions be used as Handler
+type HandlerFunc func(m ICMP, md *Metadata)
+
+// ServeNDP calls f(m, md)
+func (f HandlerFunc) ServeNDP(m ICMP, md *Metadata) {
+	f(m, md)
+}
+
+// Mux implements a Handler that dispatches messages to the Handler that is
+// registered for their type. Messages without Handler are dropped
+type Mux struct {
+	mu       sync.RWMutex
+	handlers map[ipv6.ICMPType]Handler
+}
+
+// NewMux returns an empty Mux
+func NewMux() *Mux {
+	return &Mux{
+		handlers: make(map[ipv6.ICMPType]Handler),
+	}
+}
+
+// Handle registers h for messages of type t, replacing any Handler that was
+// registered before
+func (mux *Mux) Handle(t ipv6.ICMPType, h Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.handlers[t] = h
+}
+
+// HandleRouterSolicitation registers f for router solicitations
+func (mux *Mux) HandleRouterSolicitation(f func(*ICMPRouterSolicitation, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeRouterSolicitation, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPRouterSolicitation), md)
+	}))
+}
+
+// HandleRouterAdvertisement registers f for router advertisements
+func (mux *Mux) HandleRouterAdvertisement(f func(*ICMPRouterAdvertisement, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeRouterAdvertisement, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPRouterAdvertisement), md)
+	}))
+}
+
+// HandleNeighborSolicitation registers f for neighbor solicitations
+func (mux *Mux) HandleNeighborSolicitation(f func(*ICMPNeighborSolicitation, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeNeighborSolicitation, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPNeighborSolicitation), md)
+	}))
+}
+
+// HandleNeighborAdvertisement registers f for neighbor advertisements
+func (mux *Mux) HandleNeighborAdvertisement(f func(*ICMPNeighborAdvertisement, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeNeighborAdvertisement, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPNeighborAdvertisement), md)
+	}))
+}
+
+// HandleRedirect registers f for redirects
+func (mux *Mux) HandleRedirect(f func(*ICMPRedirect, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeRedirect, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPRedirect), md)
+	}))
+}
+
+// HandleCertificationPathSolicitation registers f for certification path
+// solicitations
+func (mux *Mux) HandleCertificationPathSolicitation(f func(*ICMPCertificationPathSolicitation, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeCertificationPathSolicitation, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPCertificationPathSolicitation), md)
+	}))
+}
+
+// HandleCertificationPathAdvertisement registers f for certification path
+// advertisements
+func (mux *Mux) HandleCertificationPathAdvertisement(f func(*ICMPCertificationPathAdvertisement, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeCertificationPathAdvertisement, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPCertificationPathAdvertisement), md)
+	}))
+}
+
+// HandleDuplicateAddressRequest registers f for duplicate address requests
+func (mux *Mux) HandleDuplicateAddressRequest(f func(*ICMPDuplicateAddressRequest, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeDuplicateAddressRequest, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPDuplicateAddressRequest), md)
+	}))
+}
+
+// HandleDuplicateAddressConfirmation registers f for duplicate address
+// confirmations
+func (mux *Mux) HandleDuplicateAddressConfirmation(f func(*ICMPDuplicateAddressConfirmation, *Metadata)) {
+	mux.Handle(ipv6.ICMPTypeDuplicateAddressConfirmation, HandlerFunc(func(m ICMP, md *Metadata) {
+		f(m.(*ICMPDuplicateAddressConfirmation), md)
+	}))
+}
+
+// ServeNDP dispatches m to the Handler registered for its type
+func (mux *Mux) ServeNDP(m ICMP, md *Metadata) {
+	mux.mu.RLock()
+	h, ok := mux.handlers[m.Type()]
+	mux.mu.RUnlock()
+
+	if ok {
+		h.ServeNDP(m, md)
+	}
+}
+
+// Serve reads messages from this Conn and hands the valid ones to h, until
+// ctx is done or reading fails. Messages that fail to parse, arrive in
+// fragments as https://tools.ietf.org/html/rfc6980 forbids, or don't pass
+// the validation of https://tools.ietf.org/html/rfc4861#section-6.1,
+// https://tools.ietf.org/html/rfc4861#section-7.1 and
+// https://tools.ietf.org/html/rfc4861#section-8.1 are dropped. Duplicate
+// address requests and confirmations travel several hops, so they're
+// exempt from the hop limit check
+func (c *Conn) Serve(ctx context.Context, h Handler) error {
+	for {
+		m, md, err := c.ReadMessage(ctx)
+		if err != nil {
+			// the transport failed when there is no metadata
+			if md == nil {
+				return err
+			}
+
+			if c.dropped != nil {
+				c.dropped(md, err)
+			}
+			continue
+		}
+
+		if !validMessage(m, md, c.unknownHopLimit) {
+			c.logger().Debug("dropped invalid message", receivedAttrs(m, md)...)
+			continue
+		}
+		h.ServeNDP(m, md)
+	}
+}
+
+// validMessage applies the checks that the kernel leaves to us. Messages of
+// which the hop limit is unknown only pass when acceptUnknown is set
+func validMessage(m ICMP, md *Metadata, acceptUnknown bool) bool {
+	if md == nil {
+		return false
+	}
+	// all NDP messages have code 0, only read messages tell theirs
+	if len(md.Raw) > 1 && md.Raw[1] != 0 {
+		return false
+	}
+	switch m.(type) {
+	case *ICMPDuplicateAddressRequest, *ICMPDuplicateAddressConfirmation:
+		// these travel between 6LoWPAN routers and border routers, see
+		// https://tools.ietf.org/html/rfc6775#section-8.2.1
+		return true
+	}
+	// off-link messages can't have a hop limit of 255
+	if md.HopLimit != 255 && !(md.HopLimitUnknown && acceptUnknown) {
+		return false
+	}
+
+	return validContent(m, md)
+}
+
+// validContent applies the checks of validMessage that don't concern the hop
+// limit or code
+func validContent(m ICMP, md *Metadata) bool {
+	switch m := m.(type) {
+	case *ICMPRouterSolicitation:
+		// there's no address to map the link-layer address to
+		return !md.Source.IsUnspecified() || !m.HasOption(ICMPOptionTypeSourceLinkLayerAddress)
+	case *ICMPRouterAdvertisement:
+		// routers are identified by their link-local address
+		return md.Source.IsLinkLocalUnicast()
+	case *ICMPNeighborSolicitation:
+		if m.TargetAddress.IsMulticast() {
+			return false
+		}
+		// duplicate address detection goes to the solicited-node group of
+		// the target, without a link-layer address to map
+		if md.Source.IsUnspecified() {
+			group, _ := SolicitedNodeMulticast(m.TargetAddress)
+			return md.Destination.Equal(group) && !m.HasOption(ICMPOptionTypeSourceLinkLayerAddress)
+		}
+
+		return true
+	case *ICMPNeighborAdvertisement:
+		// solicited advertisements can't go to a multicast group
+		return !m.TargetAddress.IsMulticast() && !(m.Solicited && md.Destination.IsMulticast())
+	case *ICMPRedirect:
+		// the target is a router or the destination itself, both on-link
+		return md.Source.IsLinkLocalUnicast() && !m.DestinationAddress.IsMulticast() &&
+			(m.TargetAddress.IsLinkLocalUnicast() || m.TargetAddress.Equal(m.DestinationAddress))
+	}
+
+	return true
+}
@@ -0,0 +1,169 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+func TestMux(t *testing.T) {
+	var (
+		rs []*ICMPRouterSolicitation
+		ra []*ICMPRouterAdvertisement
+		ns []*ICMPNeighborSolicitation
+		na []*ICMPNeighborAdvertisement
+		rd []*ICMPRedirect
+		cp []ICMP
+		da []ICMP
+	)
+
+	mux := NewMux()
+	mux.HandleRouterSolicitation(func(m *ICMPRouterSolicitation, md *Metadata) { rs = append(rs, m) })
+	mux.HandleRouterAdvertisement(func(m *ICMPRouterAdvertisement, md *Metadata) { ra = append(ra, m) })
+	mux.HandleNeighborSolicitation(func(m *ICMPNeighborSolicitation, md *Metadata) { ns = append(ns, m) })
+	mux.HandleNeighborAdvertisement(func(m *ICMPNeighborAdvertisement, md *Metadata) { na = append(na, m) })
+	mux.HandleRedirect(func(m *ICMPRedirect, md *Metadata) { rd = append(rd, m) })
+	mux.HandleCertificationPathSolicitation(func(m *ICMPCertificationPathSolicitation, md *Metadata) { cp = append(cp, m) })
+	mux.HandleCertificationPathAdvertisement(func(m *ICMPCertificationPathAdvertisement, md *Metadata) { cp = append(cp, m) })
+	mux.HandleDuplicateAddressRequest(func(m *ICMPDuplicateAddressRequest, md *Metadata) { da = append(da, m) })
+	mux.HandleDuplicateAddressConfirmation(func(m *ICMPDuplicateAddressConfirmation, md *Metadata) { da = append(da, m) })
+
+	md := &Metadata{Source: net.ParseIP("fe80::1"), Destination: net.IPv6linklocalallnodes, HopLimit: 255}
+	mux.ServeNDP(&ICMPRouterSolicitation{}, md)
+	mux.ServeNDP(&ICMPRouterAdvertisement{}, md)
+	mux.ServeNDP(&ICMPNeighborSolicitation{}, md)
+	mux.ServeNDP(&ICMPNeighborAdvertisement{}, md)
+	mux.ServeNDP(&ICMPNeighborAdvertisement{}, md)
+	mux.ServeNDP(&ICMPRedirect{}, md)
+	mux.ServeNDP(&ICMPCertificationPathSolicitation{}, md)
+	mux.ServeNDP(&ICMPCertificationPathAdvertisement{}, md)
+	mux.ServeNDP(&ICMPDuplicateAddressRequest{}, md)
+	mux.ServeNDP(&ICMPDuplicateAddressConfirmation{}, md)
+
+	if len(rs) != 1 || len(ra) != 1 || len(ns) != 1 || len(na) != 2 || len(rd) != 1 || len(cp) != 2 || len(da) != 2 {
+		t.Errorf("unexpected dispatch: %d RS, %d RA, %d NS, %d NA, %d redirects, %d CPS/CPA, %d DAR/DAC", len(rs), len(ra), len(ns), len(na), len(rd), len(cp), len(da))
+	}
+
+	// handlers can be replaced
+	var count int
+	mux.Handle(ipv6.ICMPTypeRouterSolicitation, HandlerFunc(func(m ICMP, md *Metadata) { count++ }))
+	mux.ServeNDP(&ICMPRouterSolicitation{}, md)
+	if count != 1 || len(rs) != 1 {
+		t.Errorf("handler was not replaced")
+	}
+}
+
+func TestServe(t *testing.T) {
+	tt := &testTransport{
+		in: [][]byte{
+			// router solicitation
+			{133, 0, 0, 0, 0, 0, 0, 0},
+			// unsupported message
+			{128, 0, 0, 0},
+			// neighbor solicitation for multicast target
+			{135, 0, 0, 0, 0, 0, 0, 0, 255, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			// router advertisement
+			{134, 0, 0, 0, 64, 0, 7, 8, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		md: &Metadata{
+			Source:      net.ParseIP("fe80::1"),
+			Destination: net.IPv6linklocalallnodes,
+			HopLimit:    255,
+		},
+	}
+	c := &Conn{t: tt}
+	var dropped []error
+	c.SetDropped(func(md *Metadata, err error) { dropped = append(dropped, err) })
+
+	var types []ipv6.ICMPType
+	err := c.Serve(context.Background(), HandlerFunc(func(m ICMP, md *Metadata) {
+		types = append(types, m.Type())
+	}))
+
+	// the test transport fails once it runs out of packets
+	if err == nil || err.Error() != "no more packets" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if len(types) != 2 || types[0] != ipv6.ICMPTypeRouterSolicitation || types[1] != ipv6.ICMPTypeRouterAdvertisement {
+		t.Errorf("unexpected messages served: %v", types)
+	}
+	// only what fails to parse is reported, not what fails validation
+	if len(dropped) != 1 {
+		t.Errorf("unexpected drops: %v", dropped)
+	}
+
+	// fragments are dropped
+	tt.in = [][]byte{{133, 0, 0, 0, 0, 0, 0, 0}}
+	tt.md.Fragmented = true
+	dropped, types = nil, nil
+	c.Serve(context.Background(), HandlerFunc(func(m ICMP, md *Metadata) {
+		types = append(types, m.Type())
+	}))
+	if len(types) != 0 || len(dropped) != 1 || dropped[0] != ErrFragmented {
+		t.Errorf("unexpected fragment handling: %v served, %v dropped", types, dropped)
+	}
+
+	// cancelled contexts stop serving
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = c.Serve(ctx, NewMux()); err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+}
+
+func TestValidMessage(t *testing.T) {
+	ll, global := net.ParseIP("fe80::1"), net.ParseIP("2001:db8::1")
+	slla := func(m ICMP) ICMP {
+		m.(interface{ AddOption(ICMPOption) }).AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{2, 0, 0, 0, 0, 1}})
+		return m
+	}
+	tests := []struct {
+		m             ICMP
+		md            *Metadata
+		acceptUnknown bool
+		valid         bool
+	}{
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimit: 254}, false, false},
+		{&ICMPRouterSolicitation{}, nil, true, false},
+		// a missing hop limit is not a valid one
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll}, false, false},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll}, true, false},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimitUnknown: true}, false, false},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimitUnknown: true}, true, true},
+		{&ICMPRouterAdvertisement{}, &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{&ICMPRouterAdvertisement{}, &Metadata{Source: global, HopLimit: 255}, false, false},
+		{&ICMPNeighborSolicitation{TargetAddress: global}, &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{&ICMPNeighborSolicitation{TargetAddress: net.IPv6linklocalallnodes}, &Metadata{Source: ll, HopLimit: 255}, false, false},
+		// duplicate address detection
+		{&ICMPNeighborSolicitation{TargetAddress: global}, &Metadata{Source: net.IPv6unspecified, Destination: net.ParseIP("ff02::1:ff00:1"), HopLimit: 255}, false, true},
+		{&ICMPNeighborSolicitation{TargetAddress: global}, &Metadata{Source: net.IPv6unspecified, Destination: global, HopLimit: 255}, false, false},
+		{&ICMPNeighborSolicitation{TargetAddress: global}, &Metadata{Source: net.IPv6unspecified, Destination: net.ParseIP("ff02::1:ff00:2"), HopLimit: 255}, false, false},
+		{slla(&ICMPNeighborSolicitation{TargetAddress: global}), &Metadata{Source: net.IPv6unspecified, Destination: net.ParseIP("ff02::1:ff00:1"), HopLimit: 255}, false, false},
+		{slla(&ICMPRouterSolicitation{}), &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{slla(&ICMPRouterSolicitation{}), &Metadata{Source: net.IPv6unspecified, HopLimit: 255}, false, false},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: net.IPv6unspecified, HopLimit: 255}, false, true},
+		// NDP messages only have code 0
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimit: 255, Raw: []byte{133, 0, 0, 0, 0, 0, 0, 0}}, false, true},
+		{&ICMPRouterSolicitation{}, &Metadata{Source: ll, HopLimit: 255, Raw: []byte{133, 1, 0, 0, 0, 0, 0, 0}}, false, false},
+		{&ICMPNeighborAdvertisement{TargetAddress: global, Solicited: true}, &Metadata{Source: ll, Destination: ll, HopLimit: 255}, false, true},
+		{&ICMPNeighborAdvertisement{TargetAddress: global, Solicited: true}, &Metadata{Source: ll, Destination: net.IPv6linklocalallnodes, HopLimit: 255}, false, false},
+		{&ICMPRedirect{TargetAddress: ll, DestinationAddress: global}, &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{&ICMPRedirect{TargetAddress: global, DestinationAddress: global}, &Metadata{Source: ll, HopLimit: 255}, false, true},
+		{&ICMPRedirect{TargetAddress: ll, DestinationAddress: global}, &Metadata{Source: global, HopLimit: 255}, false, false},
+		{&ICMPRedirect{TargetAddress: global, DestinationAddress: net.ParseIP("2001:db8::2")}, &Metadata{Source: ll, HopLimit: 255}, false, false},
+		{&ICMPRedirect{TargetAddress: ll, DestinationAddress: net.IPv6linklocalallnodes}, &Metadata{Source: ll, HopLimit: 255}, false, false},
+		// routed between 6LoWPAN routers
+		{&ICMPDuplicateAddressRequest{}, &Metadata{Source: global, HopLimit: 64}, false, true},
+		{&ICMPDuplicateAddressConfirmation{}, &Metadata{Source: global, HopLimit: 63}, false, true},
+	}
+
+	for i, test := range tests {
+		if validMessage(test.m, test.md, test.acceptUnknown) != test.valid {
+			t.Errorf("test %d: expected validity %t for %s", i, test.valid, test.m)
+		}
+	}
+}
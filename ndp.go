@@ -1,67 +1,198 @@
 package ndp
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
 
-// inspired by golang.org/net/dnsclient.go's absDomainName
-func decDomainName(b []byte) []string {
+// decode domain names as defined in RFC 1035 Section 3.1, skipping the
+// zero padding that may follow them
+func decDomainName(b []byte) ([]string, error) {
 	if len(b) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	names := []string{}
-	labels := []string{}
-	for {
-		// go over each label
-		length := int(b[0])
-		// extract new label
-		if length > 0 {
-			labels = append(labels, string(b[1:(length+1)]))
-			// on null byte, join current labels and add to array
-		} else if len(labels) > 0 {
-			names = append(names, strings.Join(labels, ".")+".")
-			labels = []string{}
+	for len(b) > 0 {
+		// padding
+		if b[0] == 0 {
+			b = b[1:]
+			continue
 		}
-		b = b[(length + 1):]
-		if len(b) == 0 {
-			break
+
+		name, n, err := decLabels(b)
+		if err != nil {
+			return nil, err
 		}
+
+		names = append(names, name)
+		b = b[n:]
 	}
 
-	return names
+	return names, nil
 }
 
-// encode domain names as defined in RFC 1035 Section 3.1
-func encDomainName(dn []string) []byte {
+// encode domain names as defined in RFC 1035 Section 3.1 and pad the
+// result with zeros to a multiple of 8 octets
+func encDomainName(dn []string) ([]byte, error) {
 	b := make([]byte, 0)
 	// loop over given domain names
 	for _, n := range dn {
-		// loop over each part of the domain name
-		for _, p := range strings.Split(n, ".") {
-			lab := make([]byte, 0)
-			// length for this part
-			lab = append(lab, uint8(len(p)))
-			// append bytes for this part
-			lab = append(lab, []byte(p)...)
-
-			// cap label on 63 octets
-			if len(lab) > 63 {
-				lab = lab[:63]
-			}
-
-			b = append(b, lab...)
+		lab, err := encLabels(n)
+		if err != nil {
+			return nil, err
+		}
+		if len(lab) > 255 {
+			return nil, fmt.Errorf("domain name %s exceeds 255 octets", n)
 		}
+
+		b = append(b, lab...)
 	}
 
 	// pad encoding until it's a multiple of octets
-	pad := (8 - (len(b) % 8))
-	for i := 0; i < pad; i++ {
+	for len(b)%8 != 0 {
 		b = append(b, 0)
 	}
 
-	// cap encoding on 255 octets
-	if len(b) > 255 {
-		return b[:255]
+	return b, nil
+}
+
+// encode a single domain name as a sequence of labels as defined in
+// RFC 1035 Section 3.1, including the terminating root label
+func encLabels(n string) ([]byte, error) {
+	b := make([]byte, 0)
+	for _, p := range strings.Split(strings.TrimSuffix(n, "."), ".") {
+		if len(p) == 0 {
+			continue
+		}
+		if len(p) > 63 {
+			return nil, fmt.Errorf("label %s exceeds 63 octets", p)
+		}
+
+		b = append(b, uint8(len(p)))
+		b = append(b, []byte(p)...)
+	}
+
+	return append(b, 0), nil
+}
+
+// decode a single domain name encoded as a sequence of labels as defined in
+// RFC 1035 Section 3.1 and return it along with the number of bytes consumed
+func decLabels(b []byte) (string, int, error) {
+	labels := []string{}
+	off := 0
+	for off < len(b) {
+		length := int(b[off])
+		if length == 0 {
+			return strings.Join(labels, ".") + ".", off + 1, nil
+		}
+		if length > 63 || off+length+1 > len(b) {
+			break
+		}
+
+		labels = append(labels, string(b[(off+1):(off+length+1)]))
+		off += length + 1
 	}
 
-	return b
+	return "", 0, errors.New("invalid domain name encoding")
+}
+
+// Infinity is the lifetime represented by all one bits (0xffffffff), which
+// means the lifetime never expires
+const Infinity = time.Duration(0xffffffff) * time.Second
+
+// protocol constants as described at
+// https://tools.ietf.org/html/rfc4861#section-10
+const (
+	// router constants
+	MaxInitialRtrAdvertInterval = 16 * time.Second
+	MaxInitialRtrAdvertisements = 3
+	MaxFinalRtrAdvertisements   = 3
+	MinDelayBetweenRAs          = 3 * time.Second
+	MaxRADelayTime              = 500 * time.Millisecond
+
+	// host constants
+	MaxRtrSolicitationDelay = 1 * time.Second
+	RtrSolicitationInterval = 4 * time.Second
+	MaxRtrSolicitations     = 3
+
+	// node constants
+	MaxMulticastSolicit      = 3
+	MaxUnicastSolicit        = 3
+	MaxAnycastDelayTime      = 1 * time.Second
+	MaxNeighborAdvertisement = 3
+	ReachableTime            = 30 * time.Second
+	RetransTimer             = 1 * time.Second
+	DelayFirstProbeTime      = 5 * time.Second
+	MinRandomFactor          = 0.5
+	MaxRandomFactor          = 1.5
+)
+
+// autoconfiguration constants as described at
+// https://tools.ietf.org/html/rfc4862#section-5.1 and
+// https://tools.ietf.org/html/rfc7217#section-7
+const (
+	DupAddrDetectTransmits = 1
+	IDGenRetries           = 3
+)
+
+// router solicitation backoff constant as described at
+// https://tools.ietf.org/html/rfc7559#section-8
+const MaxRtrSolicitationInterval = 3600 * time.Second
+
+// temporary address constants as described at
+// https://tools.ietf.org/html/rfc8981#section-3.8
+const (
+	TempValidLifetime     = 48 * time.Hour
+	TempPreferredLifetime = 24 * time.Hour
+	TempIDGenRetries      = 3
+	// MaxDesyncFactor is the fraction of TempPreferredLifetime that
+	// temporary addresses are deprecated early by at most
+	MaxDesyncFactor = 0.4
+)
+
+// SEND timestamp constants as described at
+// https://tools.ietf.org/html/rfc3971#section-12
+const (
+	TimestampDelta = 300 * time.Second
+	TimestampFuzz  = 1 * time.Second
+	TimestampDrift = 0.01
+)
+
+// convert a lifetime in seconds as sent on the wire to a time.Duration
+func lifetimeToDuration(l uint32) time.Duration {
+	return time.Duration(l) * time.Second
+}
+
+// convert a time.Duration to a lifetime in seconds as sent on the wire,
+// capping it at Infinity
+func durationToLifetime(d time.Duration) uint32 {
+	if d <= 0 {
+		return 0
+	}
+	if d >= Infinity {
+		return 0xffffffff
+	}
+
+	return uint32(d / time.Second)
+}
+
+// SolicitedNodeMulticast returns the solicited-node multicast address of ip
+// as described at https://tools.ietf.org/html/rfc4291#section-2.7.1, along
+// with the Ethernet address it maps to as described at
+// https://tools.ietf.org/html/rfc2464#section-7. It returns nil for anything
+// but IPv6 addresses
+func SolicitedNodeMulticast(ip net.IP) (net.IP, net.HardwareAddr) {
+	ip6 := ip.To16()
+	if ip6 == nil || ip.To4() != nil {
+		return nil, nil
+	}
+
+	group := net.ParseIP("ff02::1:ff00:0")
+	copy(group[13:], ip6[13:])
+
+	return group, multicastHardwareAddr(group)
 }
@@ -2,6 +2,7 @@ package ndp
 
 import (
 	"bytes"
+	"net"
 	"reflect"
 	"testing"
 )
@@ -21,48 +22,110 @@ func TestEncDecDomainName(t *testing.T) {
 
 	for _, test := range tests {
 		// encoding
-		encoded := encDomainName(test.name)
+		encoded, err := encDomainName(test.name)
+		if err != nil {
+			t.Error(err)
+		}
 		if bytes.Compare(encoded, test.encoded) != 0 {
 			t.Errorf("failed to encode %s to %v, result was %v", test.name, test.encoded, encoded)
 		}
 		// decoding
-		name := decDomainName(test.encoded)
+		name, err := decDomainName(test.encoded)
+		if err != nil {
+			t.Error(err)
+		}
 		if !reflect.DeepEqual(name, test.name) {
 			t.Errorf("failed to decode %v to %s, result was %s", test.encoded, test.name, name)
 		}
 	}
 
-	// total length may not exceed 255 bytes
-	encoded := encDomainName([]string{
+	// trailing dot is optional when encoding
+	encoded, err := encDomainName([]string{"foo.bar"})
+	if err != nil {
+		t.Error(err)
+	}
+	if bytes.Compare(encoded, tests[0].encoded) != 0 {
+		t.Errorf("failed to encode foo.bar to %v, result was %v", tests[0].encoded, encoded)
+	}
+
+	// total length is not limited to 255 bytes
+	names := []string{
 		// many labels
-		"aaaa.aaaa.aaaa",
-		"bbbb.bbbb.bbbb",
-		"cccc.cccc.cccc",
-		"dddd.dddd.dddd",
-		"eeee.eeee.eeee",
-		"ffff.ffff.ffff",
-		"gggg.gggg.gggg",
-		"hhhh.hhhh.hhhh",
-		"iiii.iiii.iiii",
-		"jjjj.jjjj.jjjj",
-		"kkkk.kkkk.kkkk",
-		"llll.llll.llll",
-		"mmmm.mmmm.mmmm",
-		"nnnn.nnnn.nnnn",
-		"oooo.oooo.oooo",
-		"pppp.pppp.pppp",
-		"qqqq.qqqq.qqqq",
-	})
-	if len(encoded) != 255 {
-		t.Errorf("expected truncated encoding of 255, not %d", len(encoded))
+		"aaaa.aaaa.aaaa.",
+		"bbbb.bbbb.bbbb.",
+		"cccc.cccc.cccc.",
+		"dddd.dddd.dddd.",
+		"eeee.eeee.eeee.",
+		"ffff.ffff.ffff.",
+		"gggg.gggg.gggg.",
+		"hhhh.hhhh.hhhh.",
+		"iiii.iiii.iiii.",
+		"jjjj.jjjj.jjjj.",
+		"kkkk.kkkk.kkkk.",
+		"llll.llll.llll.",
+		"mmmm.mmmm.mmmm.",
+		"nnnn.nnnn.nnnn.",
+		"oooo.oooo.oooo.",
+		"pppp.pppp.pppp.",
+		"qqqq.qqqq.qqqq.",
+	}
+	encoded, err = encDomainName(names)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(encoded) != 272 {
+		t.Errorf("expected encoding of 272, not %d", len(encoded))
+	}
+	decoded, err := decDomainName(encoded)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(decoded, names) {
+		t.Errorf("failed to decode %v to %s, result was %s", encoded, names, decoded)
 	}
 
 	// individual label length may not exceed 63 bytes
-	encoded = encDomainName([]string{
+	_, err = encDomainName([]string{
 		// very long label
 		"abcdefghijlmnopqrstuvwyxzabcdefghijlmnopqrstuvwyxzabcdefghijlmnopqrstuvwyxz.foo",
 	})
-	if len(encoded) != 72 {
-		t.Errorf("expected truncated encoding of 72, not %d", len(encoded))
+	if err == nil {
+		t.Errorf("expected error for label exceeding 63 bytes")
+	}
+
+	// label length exceeding input
+	if _, err = decDomainName([]byte{3, 102, 111, 111, 9, 98, 97, 114, 0}); err == nil {
+		t.Errorf("expected error for truncated label")
+	}
+
+	// missing root label
+	if _, err = decDomainName([]byte{3, 102, 111, 111}); err == nil {
+		t.Errorf("expected error for missing root label")
+	}
+}
+
+func TestSolicitedNodeMulticast(t *testing.T) {
+	tests := []struct {
+		ip    string
+		group string
+		mac   string
+	}{
+		{"2001:db8::abcd:1234", "ff02::1:ffcd:1234", "33:33:ff:cd:12:34"},
+		{"fe80::fc:ff:fe00:1", "ff02::1:ff00:1", "33:33:ff:00:00:01"},
+		{"::", "ff02::1:ff00:0", "33:33:ff:00:00:00"},
+	}
+
+	for _, test := range tests {
+		group, mac := SolicitedNodeMulticast(net.ParseIP(test.ip))
+		if !group.Equal(net.ParseIP(test.group)) {
+			t.Errorf("expected group %s for %s, not %s", test.group, test.ip, group)
+		}
+		if mac.String() != test.mac {
+			t.Errorf("expected mac %s for %s, not %s", test.mac, test.ip, mac)
+		}
+	}
+
+	if group, mac := SolicitedNodeMulticast(net.ParseIP("192.0.2.1")); group != nil || mac != nil {
+		t.Errorf("expected nil for IPv4 address, not %s and %s", group, mac)
 	}
 }
@@ -0,0 +1,422 @@
+// Package ndpconfig reads the configuration of daemons built on package ndp
+// from YAML, as an alternative to the radvd.conf(5) syntax of
+// ndp.ParseRadvdConfig. A configuration has a section per interface with
+// the router advertisements to send and the routers a Monitor accepts,
+// and a defaults section the interface sections override:
+//
+//	defaults:
+//	  max_interval: 5m
+//	  rdnss: [2001:db8::53]
+//	interfaces:
+//	  eth0:
+//	    managed: true
+//	    prefixes:
+//	      - prefix: 2001:db8:1::/64
+//	        preferred_lifetime: 24h
+//	  eth1:
+//	    advertise: false
+//	    monitor:
+//	      routers:
+//	        - link_layer_address: 00:00:5e:00:01:01
+//	          prefixes: [2001:db8:2::/48]
+//
+// Durations take the syntax of time.ParseDuration, or infinity for
+// lifetimes. Fields left out default to ndp.DefaultRAConfig and
+// ndp.NewRAPrefix, with the minimum interval and the router and DNS
+// lifetimes derived from the maximum interval when that is set. Lists in an
+// interface section replace those of the defaults rather than adding to
+// them. Unknown fields and invalid values are rejected, as are
+// configurations ndp.RAConfig.Validate rejects
+package ndpconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skoef/ndp"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a configuration read by Parse
+type Config struct {
+	// Interfaces holds the configuration of every interface, keyed by
+	// interface name
+	Interfaces map[string]Interface
+}
+
+// Interface is the configuration of a single interface
+type Interface struct {
+	// Advertise tells whether to send router advertisements on the
+	// interface. It defaults to true
+	Advertise bool
+	// RA describes the router advertisements to send
+	RA ndp.RAConfig
+	// Monitor configures a Monitor of the interface
+	Monitor Monitor
+}
+
+// Monitor configures an ndp.Monitor
+type Monitor struct {
+	// Routers lists the legitimate routers of the link. Without any, every
+	// router is accepted
+	Routers []ndp.RAGuardRule
+	// DADWindow and MaxEntries are those of ndp.Monitor, left to its
+	// defaults when 0
+	DADWindow  time.Duration
+	MaxEntries int
+}
+
+// Apply configures mon, giving it an RAGuard of the Routers if there are
+// any
+func (m Monitor) Apply(mon *ndp.Monitor) {
+	if len(m.Routers) > 0 {
+		mon.Guard = ndp.NewRAGuard(m.Routers...)
+	}
+	if m.DADWindow != 0 {
+		mon.DADWindow = m.DADWindow
+	}
+	if m.MaxEntries != 0 {
+		mon.MaxEntries = m.MaxEntries
+	}
+}
+
+// RAConfigs returns the RAConfig of every interface that advertises, keyed
+// by interface name, like ndp.ParseRadvdConfig does. It suits
+// ndp.NewRAService and RAService.Reload
+func (c *Config) RAConfigs() map[string]ndp.RAConfig {
+	cfgs := make(map[string]ndp.RAConfig)
+	for name, ifc := range c.Interfaces {
+		if ifc.Advertise {
+			cfgs[name] = ifc.RA
+		}
+	}
+
+	return cfgs
+}
+
+// Load reads the configuration file at path, see Parse
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads a configuration in YAML from r
+func Parse(r io.Reader) (*Config, error) {
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+
+	var f fileSchema
+	if err := d.Decode(&f); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	cfg := &Config{Interfaces: make(map[string]Interface, len(f.Interfaces))}
+	for name, s := range f.Interfaces {
+		if name == "" {
+			return nil, errNoName
+		}
+		ifc, err := s.resolve(f.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %s", name, err)
+		}
+		cfg.Interfaces[name] = ifc
+	}
+
+	return cfg, nil
+}
+
+var (
+	errNoName   = errors.New("interface without name")
+	errNoPrefix = errors.New("prefixes entry without prefix")
+)
+
+// fileSchema is the layout of a configuration file
+type fileSchema struct {
+	Defaults   interfaceSchema            `yaml:"defaults"`
+	Interfaces map[string]interfaceSchema `yaml:"interfaces"`
+}
+
+// interfaceSchema is the layout of the defaults and interface sections.
+// Fields that are left out are nil
+type interfaceSchema struct {
+	Advertise *bool `yaml:"advertise"`
+
+	MinInterval      *duration   `yaml:"min_interval"`
+	MaxInterval      *duration   `yaml:"max_interval"`
+	Managed          *bool       `yaml:"managed"`
+	Other            *bool       `yaml:"other"`
+	HopLimit         *uint8      `yaml:"hop_limit"`
+	RouterLifetime   *duration   `yaml:"router_lifetime"`
+	Preference       *preference `yaml:"preference"`
+	ReachableTime    *duration   `yaml:"reachable_time"`
+	RetransTimer     *duration   `yaml:"retrans_timer"`
+	MTU              *uint32     `yaml:"mtu"`
+	UnicastSolicited *bool       `yaml:"unicast_solicited"`
+
+	Prefixes       []prefixSchema `yaml:"prefixes"`
+	RDNSS          []address      `yaml:"rdnss"`
+	RDNSSLifetime  *duration      `yaml:"rdnss_lifetime"`
+	DNSSL          []string       `yaml:"dnssl"`
+	DNSSLLifetime  *duration      `yaml:"dnssl_lifetime"`
+	PREF64         *prefix        `yaml:"pref64"`
+	PREF64Lifetime *duration      `yaml:"pref64_lifetime"`
+
+	Monitor monitorSchema `yaml:"monitor"`
+}
+
+// prefixSchema is the layout of an entry of prefixes
+type prefixSchema struct {
+	Prefix            *prefix   `yaml:"prefix"`
+	OnLink            *bool     `yaml:"on_link"`
+	Autonomous        *bool     `yaml:"autonomous"`
+	ValidLifetime     *duration `yaml:"valid_lifetime"`
+	PreferredLifetime *duration `yaml:"preferred_lifetime"`
+}
+
+// monitorSchema is the layout of a monitor section
+type monitorSchema struct {
+	Routers    []routerSchema `yaml:"routers"`
+	DADWindow  *duration      `yaml:"dad_window"`
+	MaxEntries *int           `yaml:"max_entries"`
+}
+
+// routerSchema is the layout of an entry of routers
+type routerSchema struct {
+	LinkLayerAddress hardwareAddr `yaml:"link_layer_address"`
+	Source           address      `yaml:"source"`
+	Prefixes         []prefix     `yaml:"prefixes"`
+}
+
+// resolve returns the Interface s describes, taking what it leaves out from
+// def
+func (s interfaceSchema) resolve(def interfaceSchema) (Interface, error) {
+	ifc := Interface{Advertise: true, RA: ndp.DefaultRAConfig()}
+
+	// derive the defaults depending on the maximum interval like radvd
+	// does before either section may override them
+	if max := s.MaxInterval; max != nil || def.MaxInterval != nil {
+		if max == nil {
+			max = def.MaxInterval
+		}
+		cfg := &ifc.RA
+		cfg.MaxInterval = time.Duration(*max)
+		cfg.MinInterval = cfg.MaxInterval * 33 / 100
+		if cfg.MaxInterval < 9*time.Second {
+			cfg.MinInterval = cfg.MaxInterval * 3 / 4
+		}
+		cfg.RouterLifetime = 3 * cfg.MaxInterval
+		cfg.RDNSSLifetime = 3 * cfg.MaxInterval
+		cfg.DNSSLLifetime = 3 * cfg.MaxInterval
+		cfg.PREF64Lifetime = 3 * cfg.MaxInterval
+	}
+
+	for _, s := range []interfaceSchema{def, s} {
+		if err := s.apply(&ifc); err != nil {
+			return ifc, err
+		}
+	}
+	if err := ifc.RA.Validate(); err != nil {
+		return ifc, err
+	}
+	if err := ifc.Monitor.validate(); err != nil {
+		return ifc, fmt.Errorf("monitor: %s", err)
+	}
+
+	return ifc, nil
+}
+
+// apply sets the fields of ifc that s has
+func (s interfaceSchema) apply(ifc *Interface) error {
+	if s.Advertise != nil {
+		ifc.Advertise = *s.Advertise
+	}
+
+	cfg := &ifc.RA
+	setDuration(&cfg.MinInterval, s.MinInterval)
+	setDuration(&cfg.MaxInterval, s.MaxInterval)
+	setBool(&cfg.Managed, s.Managed)
+	setBool(&cfg.Other, s.Other)
+	if s.HopLimit != nil {
+		cfg.HopLimit = *s.HopLimit
+	}
+	setDuration(&cfg.RouterLifetime, s.RouterLifetime)
+	if s.Preference != nil {
+		cfg.Preference = ndp.RouterPreferenceField(*s.Preference)
+	}
+	setDuration(&cfg.ReachableTime, s.ReachableTime)
+	setDuration(&cfg.RetransTimer, s.RetransTimer)
+	if s.MTU != nil {
+		cfg.MTU = *s.MTU
+	}
+	setBool(&cfg.UnicastSolicited, s.UnicastSolicited)
+
+	if s.Prefixes != nil {
+		cfg.Prefixes = nil
+		for _, ps := range s.Prefixes {
+			if ps.Prefix == nil {
+				return errNoPrefix
+			}
+			p := ndp.NewRAPrefix(ps.Prefix.IPNet)
+			setBool(&p.OnLink, ps.OnLink)
+			setBool(&p.Autonomous, ps.Autonomous)
+			setDuration(&p.ValidLifetime, ps.ValidLifetime)
+			setDuration(&p.PreferredLifetime, ps.PreferredLifetime)
+			cfg.Prefixes = append(cfg.Prefixes, p)
+		}
+	}
+
+	if s.RDNSS != nil {
+		cfg.RDNSS = nil
+		for _, ip := range s.RDNSS {
+			cfg.RDNSS = append(cfg.RDNSS, ip.IP)
+		}
+	}
+	setDuration(&cfg.RDNSSLifetime, s.RDNSSLifetime)
+	if s.DNSSL != nil {
+		cfg.DNSSL = s.DNSSL
+	}
+	setDuration(&cfg.DNSSLLifetime, s.DNSSLLifetime)
+	if s.PREF64 != nil {
+		cfg.PREF64 = s.PREF64.IPNet
+	}
+	setDuration(&cfg.PREF64Lifetime, s.PREF64Lifetime)
+
+	mon := &ifc.Monitor
+	if s.Monitor.Routers != nil {
+		mon.Routers = nil
+		for _, r := range s.Monitor.Routers {
+			rule := ndp.RAGuardRule{
+				LinkLayerAddress: r.LinkLayerAddress.HardwareAddr,
+				Source:           r.Source.IP,
+			}
+			for _, p := range r.Prefixes {
+				rule.Prefixes = append(rule.Prefixes, p.IPNet)
+			}
+			mon.Routers = append(mon.Routers, rule)
+		}
+	}
+	setDuration(&mon.DADWindow, s.Monitor.DADWindow)
+	if s.Monitor.MaxEntries != nil {
+		mon.MaxEntries = *s.Monitor.MaxEntries
+	}
+
+	return nil
+}
+
+// validate returns an error if ndp.Monitor can't work with m
+func (m Monitor) validate() error {
+	if m.DADWindow >= ndp.Infinity {
+		return fmt.Errorf("dad window %s is not finite", m.DADWindow)
+	}
+	if m.MaxEntries < 0 {
+		return fmt.Errorf("invalid max entries %d", m.MaxEntries)
+	}
+
+	return nil
+}
+
+func setDuration(dst *time.Duration, d *duration) {
+	if d != nil {
+		*dst = time.Duration(*d)
+	}
+}
+
+func setBool(dst *bool, b *bool) {
+	if b != nil {
+		*dst = *b
+	}
+}
+
+// duration is a time.Duration in the syntax of time.ParseDuration, or
+// infinity for ndp.Infinity
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected duration", n.Line)
+	}
+	if n.Value == "infinity" {
+		*d = duration(ndp.Infinity)
+		return nil
+	}
+
+	v, err := time.ParseDuration(n.Value)
+	if err != nil || v < 0 {
+		return fmt.Errorf("line %d: invalid duration %q", n.Line, n.Value)
+	}
+	*d = duration(v)
+
+	return nil
+}
+
+// preference is a RouterPreferenceField as its String returns it
+type preference ndp.RouterPreferenceField
+
+func (p *preference) UnmarshalYAML(n *yaml.Node) error {
+	for _, v := range []ndp.RouterPreferenceField{ndp.RouterPreferenceLow, ndp.RouterPreferenceMedium, ndp.RouterPreferenceHigh} {
+		if n.Kind == yaml.ScalarNode && n.Value == v.String() {
+			*p = preference(v)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("line %d: invalid preference %q, not one of low, medium or high", n.Line, n.Value)
+}
+
+// address is an IPv6 address
+type address struct {
+	net.IP
+}
+
+func (a *address) UnmarshalYAML(n *yaml.Node) error {
+	ip := net.ParseIP(n.Value)
+	if n.Kind != yaml.ScalarNode || ip == nil || ip.To4() != nil {
+		return fmt.Errorf("line %d: invalid IPv6 address %q", n.Line, n.Value)
+	}
+	a.IP = ip
+
+	return nil
+}
+
+// prefix is an IPv6 prefix in CIDR notation
+type prefix struct {
+	*net.IPNet
+}
+
+func (p *prefix) UnmarshalYAML(n *yaml.Node) error {
+	ip, ipnet, err := net.ParseCIDR(n.Value)
+	if n.Kind != yaml.ScalarNode || err != nil || ip.To4() != nil {
+		return fmt.Errorf("line %d: invalid IPv6 prefix %q", n.Line, n.Value)
+	}
+	if !ip.Equal(ipnet.IP) {
+		return fmt.Errorf("line %d: prefix %q has host bits set", n.Line, n.Value)
+	}
+	p.IPNet = ipnet
+
+	return nil
+}
+
+// hardwareAddr is a link-layer address in any syntax of net.ParseMAC
+type hardwareAddr struct {
+	net.HardwareAddr
+}
+
+func (a *hardwareAddr) UnmarshalYAML(n *yaml.Node) error {
+	hw, err := net.ParseMAC(strings.TrimSpace(n.Value))
+	if n.Kind != yaml.ScalarNode || err != nil {
+		return fmt.Errorf("line %d: invalid link-layer address %q", n.Line, n.Value)
+	}
+	a.HardwareAddr = hw
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package ndpconfig
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+const testConfig = `
+defaults:
+  max_interval: 5m
+  rdnss: [2001:db8::53]
+  monitor:
+    dad_window: 5s
+interfaces:
+  eth0:
+    managed: true
+    preference: high
+    mtu: 1500
+    prefixes:
+      - prefix: 2001:db8:1::/64
+        preferred_lifetime: 24h
+      - prefix: 2001:db8:2::/64
+        autonomous: false
+        valid_lifetime: infinity
+        preferred_lifetime: infinity
+    dnssl: [example.com]
+    pref64: 64:ff9b::/96
+  eth1:
+    advertise: false
+    rdnss: []
+    monitor:
+      max_entries: 128
+      routers:
+        - link_layer_address: 00:00:5e:00:01:01
+          source: fe80::1
+          prefixes: [2001:db8::/32]
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Interfaces) != 2 {
+		t.Fatalf("unexpected interfaces %v", cfg.Interfaces)
+	}
+
+	eth0 := cfg.Interfaces["eth0"]
+	ra := eth0.RA
+	if !eth0.Advertise || !ra.Managed || ra.Other || ra.Preference != ndp.RouterPreferenceHigh || ra.MTU != 1500 || ra.HopLimit != 64 {
+		t.Errorf("unexpected eth0 configuration %+v", eth0)
+	}
+	// derived from the maximum interval of the defaults
+	if ra.MaxInterval != 5*time.Minute || ra.MinInterval != 99*time.Second || ra.RouterLifetime != 15*time.Minute || ra.RDNSSLifetime != 15*time.Minute {
+		t.Errorf("unexpected eth0 intervals and lifetimes %+v", ra)
+	}
+	if len(ra.Prefixes) != 2 {
+		t.Fatalf("unexpected eth0 prefixes %v", ra.Prefixes)
+	}
+	if p := ra.Prefixes[0]; p.Prefix.String() != "2001:db8:1::/64" || !p.OnLink || !p.Autonomous || p.ValidLifetime != 30*24*time.Hour || p.PreferredLifetime != 24*time.Hour {
+		t.Errorf("unexpected first prefix %+v", p)
+	}
+	if p := ra.Prefixes[1]; p.Autonomous || p.ValidLifetime != ndp.Infinity || p.PreferredLifetime != ndp.Infinity {
+		t.Errorf("unexpected second prefix %+v", p)
+	}
+	if len(ra.RDNSS) != 1 || !ra.RDNSS[0].Equal(net.ParseIP("2001:db8::53")) || len(ra.DNSSL) != 1 || ra.DNSSL[0] != "example.com" {
+		t.Errorf("unexpected eth0 DNS options %v %v", ra.RDNSS, ra.DNSSL)
+	}
+	if ra.PREF64 == nil || ra.PREF64.String() != "64:ff9b::/96" {
+		t.Errorf("unexpected eth0 pref64 %v", ra.PREF64)
+	}
+	if eth0.Monitor.DADWindow != 5*time.Second || len(eth0.Monitor.Routers) != 0 {
+		t.Errorf("unexpected eth0 monitor %+v", eth0.Monitor)
+	}
+
+	// lists replace those of the defaults
+	eth1 := cfg.Interfaces["eth1"]
+	if eth1.Advertise || len(eth1.RA.RDNSS) != 0 || len(eth1.RA.Prefixes) != 0 {
+		t.Errorf("unexpected eth1 configuration %+v", eth1)
+	}
+	mon := eth1.Monitor
+	if mon.DADWindow != 5*time.Second || mon.MaxEntries != 128 || len(mon.Routers) != 1 {
+		t.Fatalf("unexpected eth1 monitor %+v", mon)
+	}
+	r := mon.Routers[0]
+	if r.LinkLayerAddress.String() != "00:00:5e:00:01:01" || !r.Source.Equal(net.ParseIP("fe80::1")) || len(r.Prefixes) != 1 || r.Prefixes[0].String() != "2001:db8::/32" {
+		t.Errorf("unexpected eth1 router %+v", r)
+	}
+
+	cfgs := cfg.RAConfigs()
+	if _, ok := cfgs["eth0"]; !ok || len(cfgs) != 1 {
+		t.Errorf("expected only eth0 to advertise, not %v", cfgs)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	cfg, err := Parse(strings.NewReader("interfaces:\n  eth0: {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifc := cfg.Interfaces["eth0"]
+	if !ifc.Advertise {
+		t.Errorf("expected interfaces to advertise by default")
+	}
+	def := ndp.DefaultRAConfig()
+	if ra := ifc.RA; ra.MinInterval != def.MinInterval || ra.MaxInterval != def.MaxInterval || ra.RouterLifetime != def.RouterLifetime || ra.HopLimit != def.HopLimit {
+		t.Errorf("unexpected defaults %+v", ra)
+	}
+
+	// short intervals keep the minimum interval within limits like radvd
+	cfg, err = Parse(strings.NewReader("interfaces:\n  eth0:\n    max_interval: 4s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra := cfg.Interfaces["eth0"].RA; ra.MinInterval != 3*time.Second || ra.RouterLifetime != 12*time.Second {
+		t.Errorf("unexpected derived defaults %+v", ra)
+	}
+
+	// empty configurations have no interfaces
+	cfg, err = Parse(strings.NewReader(""))
+	if err != nil || len(cfg.Interfaces) != 0 {
+		t.Errorf("unexpected empty configuration %v, %v", cfg, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{"interfaces:\n  eth0:\n    managd: true\n", "field managd not found"},
+		{"interface:\n  eth0: {}\n", "field interface not found"},
+		{"interfaces:\n  eth0:\n    max_interval: 10\n", `line 3: invalid duration "10"`},
+		{"interfaces:\n  eth0:\n    max_interval: -1s\n", `line 3: invalid duration "-1s"`},
+		{"interfaces:\n  eth0:\n    preference: highest\n", `line 3: invalid preference "highest"`},
+		{"interfaces:\n  eth0:\n    rdnss: [192.0.2.1]\n", `line 3: invalid IPv6 address "192.0.2.1"`},
+		{"interfaces:\n  eth0:\n    prefixes:\n      - prefix: 2001:db8::1/64\n", `line 4: prefix "2001:db8::1/64" has host bits set`},
+		{"interfaces:\n  eth0:\n    prefixes:\n      - on_link: true\n", "interface eth0: prefixes entry without prefix"},
+		{"interfaces:\n  eth0:\n    monitor:\n      routers:\n        - link_layer_address: nope\n", `line 5: invalid link-layer address "nope"`},
+		{"interfaces:\n  eth0:\n    max_interval: 1h\n", "interface eth0: max interval 1h0m0s not within 4s and 1800s"},
+		{"interfaces:\n  eth0:\n    mtu: 1000\n", "interface eth0: mtu 1000 below IPv6 minimum of 1280"},
+		{"interfaces:\n  eth0:\n    monitor:\n      max_entries: -1\n", "interface eth0: monitor: invalid max entries -1"},
+		{"interfaces:\n  eth0: {}\n  eth0: {}\n", "already defined"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.config))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected error containing %q for %q, not %v", test.err, test.config, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ndp.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Interfaces) != 2 {
+		t.Errorf("unexpected interfaces %v", cfg.Interfaces)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestMonitorApply(t *testing.T) {
+	mon := &ndp.Monitor{DADWindow: 10 * time.Second, MaxEntries: 4096}
+	Monitor{}.Apply(mon)
+	if mon.Guard != nil || mon.DADWindow != 10*time.Second || mon.MaxEntries != 4096 {
+		t.Errorf("expected an empty Monitor to change nothing, not %+v", mon)
+	}
+
+	Monitor{
+		Routers:    []ndp.RAGuardRule{{Source: net.ParseIP("fe80::1")}},
+		DADWindow:  time.Second,
+		MaxEntries: 16,
+	}.Apply(mon)
+	if mon.Guard == nil || mon.DADWindow != time.Second || mon.MaxEntries != 16 {
+		t.Errorf("unexpected applied monitor %+v", mon)
+	}
+	if rogue := mon.Guard.Check(&ndp.ICMPRouterAdvertisement{}, &ndp.Metadata{Source: net.ParseIP("fe80::2")}); rogue == nil {
+		t.Errorf("expected routers other than fe80::1 to be rogue")
+	}
+}
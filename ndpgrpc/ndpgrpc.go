@@ -0,0 +1,366 @@
+// Package ndpgrpc serves the Control gRPC service of control.proto for the
+// subsystems of package ndp, so orchestration systems can inspect the
+// neighbor caches and monitors of a daemon, change what its RAService
+// advertises and stream its events remotely
+package ndpgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skoef/ndp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	errNoRAService = errors.New("no ra service")
+)
+
+// eventBuffer is the number of events buffered for every WatchEvents
+// stream. Events for streams that fall further behind are dropped
+const eventBuffer = 64
+
+// Server implements ControlServer for the NeighborCaches and Monitors it
+// instruments and its RAService
+type Server struct {
+	UnimplementedControlServer
+
+	// RAService, when set, is the service of which GetRAConfig returns and
+	// SetRAConfig reloads the configuration
+	RAService *ndp.RAService
+
+	mu       sync.Mutex
+	started  time.Time
+	caches   map[string]*ndp.NeighborCache
+	monitors map[string]*ndp.Monitor
+	watchers map[chan *Event]struct{}
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewServer returns a Server without any subsystems instrumented
+func NewServer() *Server {
+	return &Server{
+		started:  time.Now(),
+		caches:   make(map[string]*ndp.NeighborCache),
+		monitors: make(map[string]*ndp.Monitor),
+		watchers: make(map[chan *Event]struct{}),
+		now:      time.Now,
+	}
+}
+
+// Register registers s as the Control service of gs
+func (s *Server) Register(gs grpc.ServiceRegistrar) {
+	RegisterControlServer(gs, s)
+}
+
+// InstrumentNeighborCache lists the entries of nc, the neighbor cache of
+// interface ifname, and streams its events, keeping its Events callback. It
+// must be called before nc serves
+func (s *Server) InstrumentNeighborCache(ifname string, nc *ndp.NeighborCache) {
+	events := nc.Events
+	nc.Events = func(ev ndp.NeighborEvent) {
+		s.publish(&Event{
+			Interface:        ifname,
+			Source:           "neighbor",
+			Type:             ev.Type.String(),
+			Address:          ev.Neighbor.Address.String(),
+			LinkLayerAddress: hardwareAddr(ev.Neighbor.LinkLayerAddress),
+			Description:      fmt.Sprintf("neighbor %s %s", ev.Neighbor.Address, ev.Type),
+		})
+		if events != nil {
+			events(ev)
+		}
+	}
+
+	s.mu.Lock()
+	s.caches[ifname] = nc
+	s.mu.Unlock()
+}
+
+// InstrumentMonitor counts the routers mon, the monitor of interface
+// ifname, sees and streams its events, keeping its Events callback. It must
+// be called before mon serves
+func (s *Server) InstrumentMonitor(ifname string, mon *ndp.Monitor) {
+	events := mon.Events
+	mon.Events = func(ev ndp.MonitorEvent) {
+		out := &Event{
+			Interface:   ifname,
+			Source:      "monitor",
+			Type:        ev.Type.String(),
+			Description: ev.String(),
+		}
+		switch {
+		case ev.Router != nil:
+			out.Address = ev.Router.Address.String()
+			out.LinkLayerAddress = hardwareAddr(ev.Router.LinkLayerAddress)
+		case ev.DAD != nil:
+			out.Address = ev.DAD.Address.String()
+			out.LinkLayerAddress = hardwareAddr(ev.DAD.LinkLayerAddress)
+		}
+		s.publish(out)
+		if events != nil {
+			events(ev)
+		}
+	}
+
+	s.mu.Lock()
+	s.monitors[ifname] = mon
+	s.mu.Unlock()
+}
+
+// publish sends ev to all WatchEvents streams that have room for it
+func (s *Server) publish(ev *Event) {
+	ev.Time = timestamppb.New(s.now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for w := range s.watchers {
+		select {
+		case w <- ev:
+		default:
+		}
+	}
+}
+
+// GetStatus implements ControlServer
+func (s *Server) GetStatus(ctx context.Context, req *GetStatusRequest) (*Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := &Status{Started: timestamppb.New(s.started)}
+	if s.RAService != nil {
+		for name := range s.RAService.Configs() {
+			st.Advertising = append(st.Advertising, name)
+		}
+	}
+	for name, nc := range s.caches {
+		st.NeighborCaches = append(st.NeighborCaches, name)
+		st.Neighbors += int32(len(nc.Neighbors()))
+	}
+	for name, mon := range s.monitors {
+		st.Monitors = append(st.Monitors, name)
+		st.Routers += int32(len(mon.Routers()))
+	}
+	sort.Strings(st.Advertising)
+	sort.Strings(st.NeighborCaches)
+	sort.Strings(st.Monitors)
+
+	return st, nil
+}
+
+// ListNeighbors implements ControlServer
+func (s *Server) ListNeighbors(ctx context.Context, req *ListNeighborsRequest) (*ListNeighborsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.caches))
+	for name := range s.caches {
+		if req.GetInterface() == "" || name == req.GetInterface() {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && req.GetInterface() != "" {
+		return nil, status.Errorf(codes.NotFound, "no neighbor cache on %s", req.GetInterface())
+	}
+	sort.Strings(names)
+
+	resp := &ListNeighborsResponse{}
+	for _, name := range names {
+		for _, n := range s.caches[name].Neighbors() {
+			resp.Neighbors = append(resp.Neighbors, &Neighbor{
+				Interface:        name,
+				Address:          n.Address.String(),
+				LinkLayerAddress: hardwareAddr(n.LinkLayerAddress),
+				State:            n.State.String(),
+				IsRouter:         n.IsRouter,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// GetRAConfig implements ControlServer
+func (s *Server) GetRAConfig(ctx context.Context, req *GetRAConfigRequest) (*GetRAConfigResponse, error) {
+	if s.RAService == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNoRAService.Error())
+	}
+
+	resp := &GetRAConfigResponse{Configs: make(map[string]*RAConfig)}
+	for name, cfg := range s.RAService.Configs() {
+		resp.Configs[name] = fromRAConfig(cfg)
+	}
+
+	return resp, nil
+}
+
+// SetRAConfig implements ControlServer by reloading the RAService
+func (s *Server) SetRAConfig(ctx context.Context, req *SetRAConfigRequest) (*SetRAConfigResponse, error) {
+	if s.RAService == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNoRAService.Error())
+	}
+
+	cfgs := make(map[string]ndp.RAConfig, len(req.GetConfigs()))
+	for name, pc := range req.GetConfigs() {
+		cfg, err := toRAConfig(pc)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "interface %s: %s", name, err)
+		}
+		cfgs[name] = cfg
+	}
+	if err := s.RAService.Reload(cfgs); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &SetRAConfigResponse{}, nil
+}
+
+// WatchEvents implements ControlServer
+func (s *Server) WatchEvents(req *WatchEventsRequest, stream grpc.ServerStreamingServer[Event]) error {
+	w := make(chan *Event, eventBuffer)
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-w:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// hardwareAddr returns lla as a string, empty if there is none
+func hardwareAddr(lla net.HardwareAddr) string {
+	if lla == nil {
+		return ""
+	}
+
+	return lla.String()
+}
+
+// fromRAConfig returns cfg as sent over the Control service
+func fromRAConfig(cfg ndp.RAConfig) *RAConfig {
+	pc := &RAConfig{
+		MinInterval:      durationpb.New(cfg.MinInterval),
+		MaxInterval:      durationpb.New(cfg.MaxInterval),
+		Managed:          cfg.Managed,
+		Other:            cfg.Other,
+		HopLimit:         uint32(cfg.HopLimit),
+		RouterLifetime:   durationpb.New(cfg.RouterLifetime),
+		Preference:       cfg.Preference.String(),
+		ReachableTime:    durationpb.New(cfg.ReachableTime),
+		RetransTimer:     durationpb.New(cfg.RetransTimer),
+		Mtu:              cfg.MTU,
+		UnicastSolicited: cfg.UnicastSolicited,
+		RdnssLifetime:    durationpb.New(cfg.RDNSSLifetime),
+		Dnssl:            cfg.DNSSL,
+		DnsslLifetime:    durationpb.New(cfg.DNSSLLifetime),
+		Pref64Lifetime:   durationpb.New(cfg.PREF64Lifetime),
+	}
+	for _, p := range cfg.Prefixes {
+		pc.Prefixes = append(pc.Prefixes, &Prefix{
+			Prefix:            p.Prefix.String(),
+			OnLink:            p.OnLink,
+			Autonomous:        p.Autonomous,
+			ValidLifetime:     durationpb.New(p.ValidLifetime),
+			PreferredLifetime: durationpb.New(p.PreferredLifetime),
+		})
+	}
+	for _, ip := range cfg.RDNSS {
+		pc.Rdnss = append(pc.Rdnss, ip.String())
+	}
+	if cfg.PREF64 != nil {
+		pc.Pref64 = cfg.PREF64.String()
+	}
+
+	return pc
+}
+
+// toRAConfig returns the ndp.RAConfig pc describes. Durations that aren't set
+// are 0
+func toRAConfig(pc *RAConfig) (ndp.RAConfig, error) {
+	cfg := ndp.RAConfig{
+		MinInterval:      pc.GetMinInterval().AsDuration(),
+		MaxInterval:      pc.GetMaxInterval().AsDuration(),
+		Managed:          pc.GetManaged(),
+		Other:            pc.GetOther(),
+		RouterLifetime:   pc.GetRouterLifetime().AsDuration(),
+		ReachableTime:    pc.GetReachableTime().AsDuration(),
+		RetransTimer:     pc.GetRetransTimer().AsDuration(),
+		MTU:              pc.GetMtu(),
+		UnicastSolicited: pc.GetUnicastSolicited(),
+		RDNSSLifetime:    pc.GetRdnssLifetime().AsDuration(),
+		DNSSL:            pc.GetDnssl(),
+		DNSSLLifetime:    pc.GetDnsslLifetime().AsDuration(),
+		PREF64Lifetime:   pc.GetPref64Lifetime().AsDuration(),
+	}
+	if pc.GetHopLimit() > 0xff {
+		return cfg, fmt.Errorf("invalid hop limit %d", pc.GetHopLimit())
+	}
+	cfg.HopLimit = uint8(pc.GetHopLimit())
+
+	switch pc.GetPreference() {
+	case "", "medium":
+		cfg.Preference = ndp.RouterPreferenceMedium
+	case "low":
+		cfg.Preference = ndp.RouterPreferenceLow
+	case "high":
+		cfg.Preference = ndp.RouterPreferenceHigh
+	default:
+		return cfg, fmt.Errorf("invalid preference %q", pc.GetPreference())
+	}
+
+	for _, p := range pc.GetPrefixes() {
+		_, prefix, err := net.ParseCIDR(p.GetPrefix())
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Prefixes = append(cfg.Prefixes, ndp.RAPrefix{
+			Prefix:            prefix,
+			OnLink:            p.GetOnLink(),
+			Autonomous:        p.GetAutonomous(),
+			ValidLifetime:     p.GetValidLifetime().AsDuration(),
+			PreferredLifetime: p.GetPreferredLifetime().AsDuration(),
+		})
+	}
+	for _, s := range pc.GetRdnss() {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return cfg, fmt.Errorf("invalid RDNSS address %q", s)
+		}
+		cfg.RDNSS = append(cfg.RDNSS, ip)
+	}
+	if pc.GetPref64() != "" {
+		_, prefix, err := net.ParseCIDR(pc.GetPref64())
+		if err != nil {
+			return cfg, err
+		}
+		cfg.PREF64 = prefix
+	}
+
+	return cfg, nil
+}
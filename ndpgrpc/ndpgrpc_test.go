@@ -0,0 +1,216 @@
+package ndpgrpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testClient returns a client of s served over an in-memory connection
+func testClient(t *testing.T, s *Server) ControlClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer()
+	s.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return NewControlClient(cc)
+}
+
+// watch returns a WatchEvents stream of client once s sends it events
+func watch(t *testing.T, s *Server, client ControlClient) Control_WatchEventsClient {
+	t.Helper()
+	stream, err := client.WatchEvents(context.Background(), &WatchEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); ; {
+		s.mu.Lock()
+		n := len(s.watchers)
+		s.mu.Unlock()
+		if n == 1 {
+			return stream
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the stream")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	a, b := ndp.Pipe()
+	defer a.Close()
+	defer b.Close()
+	nc := ndp.NewNeighborCache(a)
+
+	var chained int
+	nc.Events = func(ndp.NeighborEvent) { chained++ }
+	s := NewServer()
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	s.InstrumentNeighborCache("eth0", nc)
+	client := testClient(t, s)
+	ctx := context.Background()
+
+	stream := watch(t, s, client)
+
+	nc.Seed(ndp.Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: b.Interface().HardwareAddr, IsRouter: true})
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Interface != "eth0" || ev.Source != "neighbor" || ev.Type != "added" || ev.Address != "fe80::2" ||
+		ev.LinkLayerAddress != b.Interface().HardwareAddr.String() || ev.Time.AsTime().Unix() != 1700000000 {
+		t.Errorf("unexpected event %v", ev)
+	}
+	if chained != 1 {
+		t.Errorf("expected the existing callback to be kept")
+	}
+
+	resp, err := client.ListNeighbors(ctx, &ListNeighborsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Neighbor{{Interface: "eth0", Address: "fe80::2", LinkLayerAddress: b.Interface().HardwareAddr.String(), State: "stale", IsRouter: true}}
+	if len(resp.Neighbors) != 1 || resp.Neighbors[0].String() != expected[0].String() {
+		t.Errorf("expected %v, not %v", expected, resp.Neighbors)
+	}
+	if _, err := client.ListNeighbors(ctx, &ListNeighborsRequest{Interface: "eth1"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected eth1 to be not found, not %v", err)
+	}
+
+	st, err := client.GetStatus(ctx, &GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Neighbors != 1 || !reflect.DeepEqual(st.NeighborCaches, []string{"eth0"}) || len(st.Advertising) != 0 {
+		t.Errorf("unexpected status %v", st)
+	}
+}
+
+func TestRAConfig(t *testing.T) {
+	s := NewServer()
+	client := testClient(t, s)
+	ctx := context.Background()
+
+	if _, err := client.GetRAConfig(ctx, &GetRAConfigRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected failed precondition without ra service, not %v", err)
+	}
+
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	_, pref64, _ := net.ParseCIDR("64:ff9b::/96")
+	cfg := ndp.DefaultRAConfig()
+	cfg.Preference = ndp.RouterPreferenceHigh
+	cfg.MTU = 1500
+	cfg.Prefixes = []ndp.RAPrefix{ndp.NewRAPrefix(prefix)}
+	cfg.RDNSS = []net.IP{net.ParseIP("2001:db8::53")}
+	cfg.DNSSL = []string{"example.com"}
+	cfg.PREF64 = pref64
+	rs, err := ndp.NewRAService(map[string]ndp.RAConfig{"eth0": cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RAService = rs
+
+	resp, err := client.GetRAConfig(ctx, &GetRAConfigRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := toRAConfig(resp.Configs["eth0"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Advertisement(), cfg.Advertisement()) || got.MaxInterval != cfg.MaxInterval {
+		t.Errorf("expected %+v, not %+v", cfg, got)
+	}
+
+	// set replaces the configuration of all interfaces
+	pc := resp.Configs["eth0"]
+	pc.HopLimit = 32
+	if _, err := client.SetRAConfig(ctx, &SetRAConfigRequest{Configs: map[string]*RAConfig{"eth1": pc}}); err != nil {
+		t.Fatal(err)
+	}
+	cfgs := rs.Configs()
+	if _, ok := cfgs["eth0"]; ok || cfgs["eth1"].HopLimit != 32 {
+		t.Errorf("unexpected configs %+v", cfgs)
+	}
+
+	st, err := client.GetStatus(ctx, &GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st.Advertising, []string{"eth1"}) {
+		t.Errorf("unexpected status %v", st)
+	}
+
+	// invalid configuration is rejected as a whole
+	for _, pc := range []*RAConfig{
+		{Preference: "highest"},
+		{HopLimit: 256},
+		{Prefixes: []*Prefix{{Prefix: "2001:db8::"}}},
+		{Rdnss: []string{"nope"}},
+		{MaxInterval: pc.MaxInterval},
+	} {
+		if _, err := client.SetRAConfig(ctx, &SetRAConfigRequest{Configs: map[string]*RAConfig{"eth2": pc}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected %v to be invalid, not %v", pc, err)
+		}
+	}
+	if _, ok := rs.Configs()["eth1"]; !ok {
+		t.Error("expected invalid configuration to leave eth1 alone")
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	mon := &ndp.Monitor{}
+	s := NewServer()
+	s.InstrumentMonitor("eth0", mon)
+	client := testClient(t, s)
+	stream := watch(t, s, client)
+
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	mon.Events(ndp.MonitorEvent{Type: ndp.MonitorRouterRogue, Router: &ndp.MonitorRouter{Address: net.ParseIP("fe80::1"), LinkLayerAddress: lla}})
+	mon.Events(ndp.MonitorEvent{Type: ndp.MonitorDADDuplicate, DAD: &ndp.DADActivity{Address: net.ParseIP("2001:db8::1"), LinkLayerAddress: lla}})
+
+	for _, expected := range []*Event{
+		{Interface: "eth0", Source: "monitor", Type: "rogue router", Address: "fe80::1", LinkLayerAddress: lla.String(), Description: "rogue router fe80::1 at 02:00:00:00:00:01"},
+		{Interface: "eth0", Source: "monitor", Type: "dad duplicate", Address: "2001:db8::1", LinkLayerAddress: lla.String(), Description: "dad duplicate for 2001:db8::1 at 02:00:00:00:00:01"},
+	} {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev.Time = nil
+		if ev.String() != expected.String() {
+			t.Errorf("expected %v, not %v", expected, ev)
+		}
+	}
+
+	st, err := client.GetStatus(context.Background(), &GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st.Monitors, []string{"eth0"}) || st.Routers != 0 {
+		t.Errorf("unexpected status %v", st)
+	}
+}
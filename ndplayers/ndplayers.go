@@ -0,0 +1,186 @@
+// Package ndplayers integrates the NDP messages of package ndp with
+// gopacket. The NDP layer decodes ICMPv6 messages into the types of package
+// ndp, which carry all options package ndp knows rather than the few the
+// layers of gopacket do, and serializes them back. It takes the place of
+// layers.ICMPv6 in a DecodingLayerParser:
+//
+//	var (
+//		eth layers.Ethernet
+//		ip6 layers.IPv6
+//		msg ndplayers.NDP
+//	)
+//	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &ip6, &msg)
+//	decoded := []gopacket.LayerType{}
+//	if err := parser.DecodeLayers(frame, &decoded); err == nil {
+//		fmt.Println(msg.Message)
+//	}
+//
+// Packets decoded by gopacket.NewPacket are converted with FromPacket
+package ndplayers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/skoef/ndp"
+)
+
+// LayerTypeNDP is the gopacket layer type of NDP messages
+var LayerTypeNDP = gopacket.RegisterLayerType(2861, gopacket.LayerTypeMetadata{
+	Name:    "NDP",
+	Decoder: gopacket.DecodeFunc(decodeNDP),
+})
+
+var (
+	errNoNDP              = errors.New("packet carries no ndp message")
+	errNoNetworkLayer     = errors.New("ndp checksum needs the ipv6 network layer")
+	errNoMessageToMarshal = errors.New("ndp layer without message")
+)
+
+// NDP is the gopacket layer of an ICMPv6 message of package ndp, from its
+// type field up to and including its options
+type NDP struct {
+	layers.BaseLayer
+	Message ndp.ICMP
+
+	// src and dst are those of SetNetworkLayerForChecksum
+	src, dst net.IP
+}
+
+// LayerType returns LayerTypeNDP
+func (n *NDP) LayerType() gopacket.LayerType {
+	return LayerTypeNDP
+}
+
+// CanDecode returns the layer types NDP decodes, which are LayerTypeNDP and
+// layers.LayerTypeICMPv6 so it can replace layers.ICMPv6. The ICMPv6
+// messages that aren't NDP messages fail to decode
+func (n *NDP) CanDecode() gopacket.LayerClass {
+	return gopacket.NewLayerClass([]gopacket.LayerType{LayerTypeNDP, layers.LayerTypeICMPv6})
+}
+
+// NextLayerType returns gopacket.LayerTypeZero, as NDP messages carry no
+// other layer
+func (n *NDP) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the NDP message in data
+func (n *NDP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	m, err := ndp.ParseMessage(data)
+	if err != nil {
+		if len(data) < 4 {
+			df.SetTruncated()
+		}
+		return err
+	}
+
+	n.Message = m
+	n.BaseLayer = layers.BaseLayer{Contents: data}
+
+	return nil
+}
+
+// SetNetworkLayerForChecksum sets the IPv6 layer whose addresses the checksum
+// covers when serializing with ComputeChecksums
+func (n *NDP) SetNetworkLayerForChecksum(l gopacket.NetworkLayer) error {
+	ip6, ok := l.(*layers.IPv6)
+	if !ok {
+		return fmt.Errorf("ndp messages are carried over ipv6, not %s", l.LayerType())
+	}
+	n.src, n.dst = ip6.SrcIP, ip6.DstIP
+
+	return nil
+}
+
+// SerializeTo writes the NDP message to b, with its checksum filled in when
+// opts has ComputeChecksums set
+func (n *NDP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if n.Message == nil {
+		return errNoMessageToMarshal
+	}
+	body, err := n.Message.Marshal()
+	if err != nil {
+		return err
+	}
+	if opts.ComputeChecksums {
+		if n.src == nil || n.dst == nil {
+			return errNoNetworkLayer
+		}
+		if err := ndp.Checksum(&body, n.src, n.dst); err != nil {
+			return err
+		}
+	}
+
+	bytes, err := b.PrependBytes(len(body))
+	if err != nil {
+		return err
+	}
+	copy(bytes, body)
+
+	return nil
+}
+
+func decodeNDP(data []byte, p gopacket.PacketBuilder) error {
+	n := &NDP{}
+	if err := n.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(n)
+
+	return nil
+}
+
+// FromPacket returns the NDP message p carries along with its Metadata, for
+// packets decoded by gopacket.NewPacket with the layers of gopacket. The
+// link-layer addresses are set when p has an Ethernet layer
+func FromPacket(p gopacket.Packet) (ndp.ICMP, *ndp.Metadata, error) {
+	var (
+		m          ndp.ICMP
+		ip6        *layers.IPv6
+		eth        *layers.Ethernet
+		raw        []byte
+		parsed     bool
+		fragmented bool
+	)
+	for _, l := range p.Layers() {
+		switch l := l.(type) {
+		case *layers.Ethernet:
+			eth = l
+		case *layers.IPv6:
+			ip6 = l
+		case *layers.IPv6Fragment:
+			fragmented = true
+		case *NDP:
+			m, raw, parsed = l.Message, l.Contents, true
+		case *layers.ICMPv6:
+			if parsed {
+				continue
+			}
+			body := append(append([]byte(nil), l.Contents...), l.Payload...)
+			msg, err := ndp.ParseMessage(body)
+			if err != nil {
+				return nil, nil, err
+			}
+			m, raw, parsed = msg, body, true
+		}
+	}
+	if !parsed {
+		return nil, nil, errNoNDP
+	}
+
+	md := &ndp.Metadata{Fragmented: fragmented, Raw: raw}
+	if ip6 != nil {
+		md.Source, md.Destination = ip6.SrcIP, ip6.DstIP
+		md.HopLimit = int(ip6.HopLimit)
+	}
+	if eth != nil {
+		md.SourceLinkLayerAddress = eth.SrcMAC
+		md.DestinationLinkLayerAddress = eth.DstMAC
+	}
+
+	return m, md, nil
+}
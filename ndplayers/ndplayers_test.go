@@ -0,0 +1,118 @@
+package ndplayers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/skoef/ndp"
+)
+
+// testFrame returns a neighbor solicitation in an Ethernet frame
+func testFrame(t *testing.T) (*ndp.ICMPNeighborSolicitation, []byte) {
+	t.Helper()
+	ns := &ndp.ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}
+	ns.AddOption(&ndp.ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}})
+	ns.AddOption(&ndp.ICMPOptionNonce{Nonce: 42})
+	frame, err := ndp.MarshalFrame(ns, net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}, nil, net.ParseIP("fe80::1"), net.ParseIP("ff02::1:ff00:2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ns, frame
+}
+
+func TestDecodingLayerParser(t *testing.T) {
+	ns, frame := testFrame(t)
+
+	var (
+		eth layers.Ethernet
+		ip6 layers.IPv6
+		msg NDP
+	)
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &ip6, &msg)
+	decoded := []gopacket.LayerType{}
+	if err := parser.DecodeLayers(frame, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 {
+		t.Fatalf("unexpected layers %v", decoded)
+	}
+
+	parsed, ok := msg.Message.(*ndp.ICMPNeighborSolicitation)
+	if !ok {
+		t.Fatalf("unexpected message %s", msg.Message)
+	}
+	if parsed.String() != ns.String() {
+		t.Errorf("expected %s, not %s", ns, parsed)
+	}
+	if len(parsed.Options) != 2 {
+		t.Errorf("unexpected options %v", parsed.Options)
+	}
+
+	// other ICMPv6 messages don't decode
+	echo := append([]byte(nil), frame...)
+	echo[14+40] = byte(layers.ICMPv6TypeEchoRequest)
+	if err := parser.DecodeLayers(echo, &decoded); err == nil {
+		t.Error("expected error for echo request")
+	}
+}
+
+func TestFromPacket(t *testing.T) {
+	ns, frame := testFrame(t)
+
+	for _, p := range []gopacket.Packet{
+		gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default),
+		gopacket.NewPacket(frame[14+40:], LayerTypeNDP, gopacket.Default),
+	} {
+		m, md, err := FromPacket(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.String() != ns.String() {
+			t.Errorf("expected %s, not %s", ns, m)
+		}
+		if p.Layer(layers.LayerTypeIPv6) != nil && (!md.Source.Equal(net.ParseIP("fe80::1")) || md.HopLimit != 255 || md.SourceLinkLayerAddress.String() != "02:00:00:00:00:01") {
+			t.Errorf("unexpected metadata %+v", md)
+		}
+	}
+
+	udp := gopacket.NewPacket(frame[:14+40], layers.LayerTypeEthernet, gopacket.Default)
+	if _, _, err := FromPacket(udp); err != errNoNDP {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSerializeTo(t *testing.T) {
+	ns, frame := testFrame(t)
+
+	ip6 := &layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolICMPv6,
+		HopLimit:   255,
+		SrcIP:      net.ParseIP("fe80::1"),
+		DstIP:      net.ParseIP("ff02::1:ff00:2"),
+	}
+	msg := &NDP{Message: ns}
+	if err := msg.SetNetworkLayerForChecksum(ip6); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}, DstMAC: net.HardwareAddr{0x33, 0x33, 0xff, 0, 0, 0x02}, EthernetType: layers.EthernetTypeIPv6},
+		ip6, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("expected %x, not %x", frame, buf.Bytes())
+	}
+
+	// the checksum covers the addresses
+	if err := (&NDP{Message: ns}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{ComputeChecksums: true}); err != errNoNetworkLayer {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package ndpotel traces the exchanges of package ndp with OpenTelemetry, so
+// the latency of resolving neighbors, soliciting routers and duplicate
+// address detection shows in distributed tracing systems
+package ndpotel
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the spans
+const instrumentationName = "github.com/skoef/ndp"
+
+// attributePrefix namespaces the attributes of the spans
+const attributePrefix = "ndp."
+
+// Tracer implements ndp.Tracer with an OpenTelemetry tracer
+type Tracer struct {
+	t trace.Tracer
+}
+
+// NewTracer returns a Tracer starting spans with a tracer of tp, or of the
+// global TracerProvider if tp is nil
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return &Tracer{t: tp.Tracer(instrumentationName)}
+}
+
+// Start implements ndp.Tracer. Spans that end with an error record it and
+// have their status set to codes.Error
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(error)) {
+	ctx, span := t.t.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes(attrs)...),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// attributes returns the OpenTelemetry attributes of attrs, prefixed with
+// ndp.
+func attributes(attrs []slog.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		key := attributePrefix + a.Key
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindBool:
+			kvs = append(kvs, attribute.Bool(key, v.Bool()))
+		case slog.KindInt64:
+			kvs = append(kvs, attribute.Int64(key, v.Int64()))
+		case slog.KindFloat64:
+			kvs = append(kvs, attribute.Float64(key, v.Float64()))
+		default:
+			kvs = append(kvs, attribute.String(key, v.String()))
+		}
+	}
+
+	return kvs
+}
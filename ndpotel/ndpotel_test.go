@@ -0,0 +1,68 @@
+package ndpotel
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/skoef/ndp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	a, b := ndp.Pipe()
+	defer a.Close()
+	defer b.Close()
+	a.SetTracer(NewTracer(tp))
+
+	nc := ndp.NewNeighborCache(a)
+	nc.Seed(ndp.Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: b.Interface().HardwareAddr})
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if _, err := nc.Resolve(ctx, net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := nc.Resolve(cctx, net.ParseIP("fe80::3")); err != context.Canceled {
+		t.Fatalf("expected cancellation, not %v", err)
+	}
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, not %d", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name() != ndp.SpanResolve {
+			t.Errorf("unexpected span %s", span.Name())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected span %s to be a child of the parent", span.Name())
+		}
+	}
+
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	for key, expected := range map[attribute.Key]string{"ndp.ifname": "pipe0", "ndp.addr": "fe80::2"} {
+		if v, ok := attrs.Value(key); !ok || v.AsString() != expected {
+			t.Errorf("expected attribute %s of %s, not %v", key, expected, v.AsString())
+		}
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("unexpected status %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("expected failed span to record its error, not %v", spans[1].Status())
+	}
+}
+
+func TestNewTracerGlobal(t *testing.T) {
+	// the global provider doesn't record anything by default
+	_, end := NewTracer(nil).Start(context.Background(), ndp.SpanDAD)
+	end(nil)
+}
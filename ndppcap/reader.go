@@ -0,0 +1,165 @@
+// Package ndppcap reads and writes the NDP messages of capture files in the
+// pcap and pcapng formats, so captures can be analyzed with the types of
+// package ndp without stripping link-layer and IPv6 headers by hand, and
+// generated messages inspected with tools like Wireshark
+package ndppcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/skoef/ndp"
+)
+
+// pcapngMagic starts the section header block every pcapng file starts with
+const pcapngMagic = 0x0a0d0d0a
+
+// ndpTypes holds the ICMPv6 types package ndp parses
+var ndpTypes = map[uint8]bool{
+	layers.ICMPv6TypeRouterSolicitation:    true,
+	layers.ICMPv6TypeRouterAdvertisement:   true,
+	layers.ICMPv6TypeNeighborSolicitation:  true,
+	layers.ICMPv6TypeNeighborAdvertisement: true,
+	layers.ICMPv6TypeRedirect:              true,
+	// RFC3971
+	148: true,
+	149: true,
+	// RFC6775
+	157: true,
+	158: true,
+}
+
+// Packet is an NDP message read from a capture file
+type Packet struct {
+	// Index is the position of the packet in the file, counting from 0
+	Index     int
+	Timestamp time.Time
+	// Interface is the name of the interface the packet was captured on,
+	// when the file tells
+	Interface string
+	Message   ndp.ICMP
+	// Metadata holds the addresses and hop limit of the packet, along with
+	// its link-layer addresses when the link has them
+	Metadata *ndp.Metadata
+}
+
+// PacketError is returned by Reader.Next for NDP messages that fail to
+// parse. Reading can go on with the next packet
+type PacketError struct {
+	Index     int
+	Timestamp time.Time
+	Err       error
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("packet %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the error the packet failed to parse with
+func (e *PacketError) Unwrap() error {
+	return e.Err
+}
+
+// packetSource is implemented by the readers of pcapgo
+type packetSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// Reader reads the NDP messages of a capture file, skipping all other
+// packets
+type Reader struct {
+	src packetSource
+	ng  *pcapgo.NgReader
+	n   int
+}
+
+// NewReader returns a Reader of the capture file r, which is in either the
+// pcap or pcapng format
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint32(magic) == pcapngMagic {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{src: ng, ng: ng}, nil
+	}
+
+	pr, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{src: pr}, nil
+}
+
+// Next returns the next NDP message in the file, or io.EOF once there are no
+// more. Messages that fail to parse are returned as *PacketError
+func (r *Reader) Next() (*Packet, error) {
+	for {
+		data, ci, err := r.src.ReadPacketData()
+		if err != nil {
+			return nil, err
+		}
+		i := r.n
+		r.n++
+
+		p := &Packet{Index: i, Timestamp: ci.Timestamp}
+		link := r.src.LinkType()
+		if r.ng != nil {
+			if ifi, err := r.ng.Interface(ci.InterfaceIndex); err == nil {
+				link, p.Interface = ifi.LinkType, ifi.Name
+			}
+		}
+
+		ok, err := decode(data, link, p)
+		if err != nil {
+			return nil, &PacketError{Index: i, Timestamp: ci.Timestamp, Err: err}
+		}
+		if ok {
+			return p, nil
+		}
+	}
+}
+
+// decode parses the NDP message in data, captured on a link of type link,
+// into p. It reports false for packets that carry no NDP message
+func decode(data []byte, link layers.LinkType, p *Packet) (bool, error) {
+	pkt := gopacket.NewPacket(data, link, gopacket.NoCopy)
+	ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	if !ok {
+		return false, nil
+	}
+	// later fragments don't tell what they carry
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+	if !ok || !ndpTypes[icmp.TypeCode.Type()] {
+		return false, nil
+	}
+
+	m, md, err := ndp.ParsePacket(append(append([]byte(nil), ip6.Contents...), ip6.Payload...))
+	if err != nil {
+		return true, err
+	}
+
+	switch l := pkt.LinkLayer().(type) {
+	case *layers.Ethernet:
+		md.SourceLinkLayerAddress, md.DestinationLinkLayerAddress = l.SrcMAC, l.DstMAC
+	case *layers.LinuxSLL:
+		md.SourceLinkLayerAddress = l.Addr
+	}
+	p.Message, p.Metadata = m, md
+
+	return true, nil
+}
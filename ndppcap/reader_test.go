@@ -0,0 +1,115 @@
+package ndppcap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/skoef/ndp"
+)
+
+// testFrames returns a neighbor solicitation, an echo request and a neighbor
+// solicitation with a bad checksum in Ethernet frames
+func testFrames(t *testing.T) [][]byte {
+	t.Helper()
+	src, dst := net.ParseIP("fe80::1"), net.ParseIP("ff02::1:ff00:2")
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+
+	ns := &ndp.ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}
+	ns.AddOption(&ndp.ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: mac})
+	frame, err := ndp.MarshalFrame(ns, mac, nil, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo := append([]byte(nil), frame...)
+	echo[14+40] = byte(layers.ICMPv6TypeEchoRequest)
+	bad := append([]byte(nil), frame...)
+	bad[14+40+2]++
+
+	return [][]byte{frame, echo, bad}
+}
+
+func testRead(t *testing.T, r *Reader, iface string) {
+	t.Helper()
+	p, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Index != 0 || !p.Timestamp.Equal(time.Unix(1000, 0)) || p.Interface != iface {
+		t.Errorf("unexpected packet %+v", p)
+	}
+	ns, ok := p.Message.(*ndp.ICMPNeighborSolicitation)
+	if !ok || !ns.TargetAddress.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected message %s", p.Message)
+	}
+	if !p.Metadata.Source.Equal(net.ParseIP("fe80::1")) || p.Metadata.HopLimit != 255 || p.Metadata.SourceLinkLayerAddress.String() != "02:00:00:00:00:01" || p.Metadata.DestinationLinkLayerAddress.String() != "33:33:ff:00:00:02" {
+		t.Errorf("unexpected metadata %+v", p.Metadata)
+	}
+
+	// the echo request is skipped, and the bad checksum reported
+	_, err = r.Next()
+	var perr *PacketError
+	if !errors.As(err, &perr) || perr.Index != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, not %v", err)
+	}
+}
+
+func TestReaderPcap(t *testing.T) {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	for i, frame := range testFrames(t) {
+		ci := gopacket.CaptureInfo{Timestamp: time.Unix(int64(1000+i), 0), CaptureLength: len(frame), Length: len(frame)}
+		if err := w.WritePacket(ci, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRead(t, r, "")
+}
+
+func TestReaderPcapng(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := pcapgo.NewNgWriterInterface(&buf, pcapgo.NgInterface{Name: "eth0", LinkType: layers.LinkTypeEthernet, SnapLength: 65536}, pcapgo.DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, frame := range testFrames(t) {
+		ci := gopacket.CaptureInfo{Timestamp: time.Unix(int64(1000+i), 0), CaptureLength: len(frame), Length: len(frame)}
+		if err := w.WritePacket(ci, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRead(t, r, "eth0")
+}
+
+func TestReaderInvalid(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("not a capture"))); err == nil {
+		t.Error("expected error for invalid file")
+	}
+}
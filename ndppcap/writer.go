@@ -0,0 +1,103 @@
+package ndppcap
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/skoef/ndp"
+)
+
+// snapLength is the snap length of the files Writer writes, which fits every
+// Ethernet frame
+const snapLength = 65536
+
+// hopLimitOffset is where the hop limit sits in an Ethernet frame carrying
+// an IPv6 packet
+const hopLimitOffset = 14 + 7
+
+var (
+	errNoMetadata = errors.New("ndp message without metadata")
+)
+
+// packetSink is implemented by the writers of pcapgo
+type packetSink interface {
+	WritePacket(ci gopacket.CaptureInfo, data []byte) error
+}
+
+// Writer writes NDP messages in Ethernet frames to a capture file, so
+// generated traffic can be inspected with tools like Wireshark
+type Writer struct {
+	dst packetSink
+	ng  *pcapgo.NgWriter
+}
+
+// NewWriter returns a Writer of a capture file in the pcap format to w, and
+// writes its file header
+func NewWriter(w io.Writer) (*Writer, error) {
+	pw := pcapgo.NewWriter(w)
+	if err := pw.WriteFileHeader(snapLength, layers.LinkTypeEthernet); err != nil {
+		return nil, err
+	}
+
+	return &Writer{dst: pw}, nil
+}
+
+// NewNgWriter returns a Writer of a capture file in the pcapng format to w,
+// with the packets captured on the interface named iface. Flush must be
+// called once done
+func NewNgWriter(w io.Writer, iface string) (*Writer, error) {
+	ng, err := pcapgo.NewNgWriterInterface(w, pcapgo.NgInterface{
+		Name:       iface,
+		LinkType:   layers.LinkTypeEthernet,
+		SnapLength: snapLength,
+	}, pcapgo.DefaultNgWriterOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{dst: ng, ng: ng}, nil
+}
+
+// Write writes m as sent at ts with md, which holds its addresses and
+// link-layer addresses. The destination link-layer address may be left out
+// for multicast destinations, see ndp.MarshalFrame. The hop limit is that
+// of md when set, or 255
+func (w *Writer) Write(ts time.Time, m ndp.ICMP, md *ndp.Metadata) error {
+	if md == nil {
+		return errNoMetadata
+	}
+	frame, err := ndp.MarshalFrame(m, md.SourceLinkLayerAddress, md.DestinationLinkLayerAddress, md.Source, md.Destination)
+	if err != nil {
+		return err
+	}
+	// the hop limit isn't covered by the checksum
+	if md.HopLimit > 0 && md.HopLimit < 256 {
+		frame[hopLimitOffset] = byte(md.HopLimit)
+	}
+
+	return w.WriteFrame(ts, frame)
+}
+
+// WriteFrame writes the Ethernet frame as sent at ts, for frames built with
+// ndp.MarshalFrame and then altered
+func (w *Writer) WriteFrame(ts time.Time, frame []byte) error {
+	return w.dst.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     ts,
+		CaptureLength: len(frame),
+		Length:        len(frame),
+	}, frame)
+}
+
+// Flush writes out what pcapng Writers buffer. It does nothing for pcap
+// Writers, which don't buffer
+func (w *Writer) Flush() error {
+	if w.ng == nil {
+		return nil
+	}
+
+	return w.ng.Flush()
+}
@@ -0,0 +1,74 @@
+package ndppcap
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skoef/ndp"
+)
+
+func TestWriter(t *testing.T) {
+	ra := &ndp.ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}
+	ra.AddOption(&ndp.ICMPOptionMTU{MTU: 1500})
+	md := &ndp.Metadata{
+		Source:                 net.ParseIP("fe80::1"),
+		Destination:            net.IPv6linklocalallnodes,
+		SourceLinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01},
+	}
+
+	for _, ng := range []bool{false, true} {
+		var (
+			buf bytes.Buffer
+			w   *Writer
+			err error
+		)
+		if ng {
+			w, err = NewNgWriter(&buf, "eth0")
+		} else {
+			w, err = NewWriter(&buf)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := time.Unix(1000, 0)
+		if err := w.Write(ts, ra, md); err != nil {
+			t.Fatal(err)
+		}
+		// spoofed hop limits are written as is
+		spoofed := *md
+		spoofed.HopLimit = 64
+		if err := w.Write(ts.Add(time.Second), ra, &spoofed); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(ts, ra, nil); err != errNoMetadata {
+			t.Errorf("unexpected error %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, hopLimit := range []int{255, 64} {
+			p, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Message.String() != ra.String() || !p.Timestamp.Equal(ts.Add(time.Duration(i)*time.Second)) {
+				t.Errorf("unexpected packet %+v", p)
+			}
+			if p.Metadata.HopLimit != hopLimit || p.Metadata.DestinationLinkLayerAddress.String() != "33:33:00:00:00:01" {
+				t.Errorf("unexpected metadata %+v", p.Metadata)
+			}
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Errorf("expected EOF, not %v", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package ndpprom exposes metrics of the subsystems of package ndp as a
+// prometheus.Collector, so daemons embedding them get counters of the
+// messages they handle, the advertisements they send and the neighbors they
+// keep without instrumenting each callback themselves
+package ndpprom
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/skoef/ndp"
+)
+
+// namespace prefixes the names of all metrics
+const namespace = "ndp"
+
+// the reasons of the parse errors metric
+const (
+	reasonFragmented = "fragmented"
+	reasonLimit      = "limit"
+	reasonMalformed  = "malformed"
+)
+
+// neighborStates holds the states the neighbor cache entries metric has a
+// series for, even without entries in them
+var neighborStates = []ndp.NeighborState{
+	ndp.NeighborIncomplete,
+	ndp.NeighborReachable,
+	ndp.NeighborStale,
+	ndp.NeighborDelay,
+	ndp.NeighborProbe,
+}
+
+// Collector implements prometheus.Collector of the metrics of the Conns,
+// RAServers, NeighborCaches and SLAACClients it instruments. Metrics of
+// several interfaces add up, so use a Collector per interface registered
+// with prometheus.WrapRegistererWith to tell them apart
+type Collector struct {
+	messages    *prometheus.CounterVec
+	parseErrors *prometheus.CounterVec
+	raSent      prometheus.Counter
+	neighbor    *prometheus.CounterVec
+	dadFailures prometheus.Counter
+	neighbors   *prometheus.Desc
+
+	mu     sync.Mutex
+	caches []*ndp.NeighborCache
+}
+
+// NewCollector returns a Collector without any subsystems instrumented
+func NewCollector() *Collector {
+	return &Collector{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_received_total",
+			Help:      "NDP messages received and handled, by type.",
+		}, []string{"type"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_errors_total",
+			Help:      "NDP messages dropped because they failed to parse, by reason.",
+		}, []string{"reason"}),
+		raSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "router_advertisements_sent_total",
+			Help:      "Router advertisements sent.",
+		}),
+		neighbor: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "neighbor_events_total",
+			Help:      "Neighbor cache entries added, changed and removed, by event.",
+		}, []string{"event"}),
+		dadFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dad_failures_total",
+			Help:      "Addresses duplicate address detection found in use by another node.",
+		}),
+		neighbors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "neighbor_cache_entries"),
+			"Neighbor cache entries, by state.",
+			[]string{"state"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.messages.Describe(ch)
+	c.parseErrors.Describe(ch)
+	c.raSent.Describe(ch)
+	c.neighbor.Describe(ch)
+	c.dadFailures.Describe(ch)
+	ch <- c.neighbors
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.messages.Collect(ch)
+	c.parseErrors.Collect(ch)
+	c.raSent.Collect(ch)
+	c.neighbor.Collect(ch)
+	c.dadFailures.Collect(ch)
+
+	c.mu.Lock()
+	caches := c.caches
+	c.mu.Unlock()
+
+	counts := make(map[ndp.NeighborState]int)
+	for _, nc := range caches {
+		for _, n := range nc.Neighbors() {
+			counts[n.State]++
+		}
+	}
+	for _, s := range neighborStates {
+		ch <- prometheus.MustNewConstMetric(c.neighbors, prometheus.GaugeValue, float64(counts[s]), s.String())
+	}
+}
+
+// Handler returns a Handler that counts the messages Conn.Serve hands it
+// before passing them on to h
+func (c *Collector) Handler(h ndp.Handler) ndp.Handler {
+	return ndp.HandlerFunc(func(m ndp.ICMP, md *ndp.Metadata) {
+		c.messages.WithLabelValues(m.Type().String()).Inc()
+		h.ServeNDP(m, md)
+	})
+}
+
+// Dropped counts the messages Conn.Serve drops by the reason err tells. It
+// suits Conn.SetDropped
+func (c *Collector) Dropped(md *ndp.Metadata, err error) {
+	var lerr *ndp.ParseLimitError
+	switch {
+	case err == ndp.ErrFragmented:
+		c.parseErrors.WithLabelValues(reasonFragmented).Inc()
+	case errors.As(err, &lerr):
+		c.parseErrors.WithLabelValues(reasonLimit).Inc()
+	default:
+		c.parseErrors.WithLabelValues(reasonMalformed).Inc()
+	}
+}
+
+// InstrumentRAServer counts the advertisements s sends, keeping its Sent
+// callback. It must be called before s serves
+func (c *Collector) InstrumentRAServer(s *ndp.RAServer) {
+	sent := s.Sent
+	s.Sent = func(ra *ndp.ICMPRouterAdvertisement, dst net.IP) {
+		c.raSent.Inc()
+		if sent != nil {
+			sent(ra, dst)
+		}
+	}
+}
+
+// InstrumentNeighborCache counts the events of nc and has the neighbor cache
+// entries metric include its entries, keeping its Events callback. It must
+// be called before nc serves
+func (c *Collector) InstrumentNeighborCache(nc *ndp.NeighborCache) {
+	events := nc.Events
+	nc.Events = func(ev ndp.NeighborEvent) {
+		c.neighbor.WithLabelValues(ev.Type.String()).Inc()
+		if events != nil {
+			events(ev)
+		}
+	}
+
+	c.mu.Lock()
+	c.caches = append(c.caches, nc)
+	c.mu.Unlock()
+}
+
+// InstrumentSLAACClient counts the addresses duplicate address detection of
+// cl finds in use, keeping its Duplicate callback. It must be called before
+// cl serves
+func (c *Collector) InstrumentSLAACClient(cl *ndp.SLAACClient) {
+	duplicate := cl.Duplicate
+	cl.Duplicate = func(ip net.IP) {
+		c.dadFailures.Inc()
+		if duplicate != nil {
+			duplicate(ip)
+		}
+	}
+}
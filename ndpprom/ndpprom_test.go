@@ -0,0 +1,119 @@
+package ndpprom
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/skoef/ndp"
+)
+
+func TestCollectorHandler(t *testing.T) {
+	c := NewCollector()
+	var handled int
+	h := c.Handler(ndp.HandlerFunc(func(m ndp.ICMP, md *ndp.Metadata) {
+		handled++
+	}))
+
+	h.ServeNDP(&ndp.ICMPRouterSolicitation{}, &ndp.Metadata{})
+	h.ServeNDP(&ndp.ICMPNeighborSolicitation{}, &ndp.Metadata{})
+	h.ServeNDP(&ndp.ICMPNeighborSolicitation{}, &ndp.Metadata{})
+	if handled != 3 {
+		t.Errorf("expected 3 messages to be passed on, not %d", handled)
+	}
+
+	expected := `
+# HELP ndp_messages_received_total NDP messages received and handled, by type.
+# TYPE ndp_messages_received_total counter
+ndp_messages_received_total{type="neighbor solicitation"} 2
+ndp_messages_received_total{type="router solicitation"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "ndp_messages_received_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorDropped(t *testing.T) {
+	c := NewCollector()
+	c.Dropped(&ndp.Metadata{}, ndp.ErrFragmented)
+	c.Dropped(&ndp.Metadata{}, &ndp.ParseLimitError{Limit: "MaxOptions", Max: 1})
+	c.Dropped(&ndp.Metadata{}, errors.New("message too short"))
+	c.Dropped(&ndp.Metadata{}, errors.New("message too short"))
+
+	for reason, n := range map[string]float64{reasonFragmented: 1, reasonLimit: 1, reasonMalformed: 2} {
+		if v := testutil.ToFloat64(c.parseErrors.WithLabelValues(reason)); v != n {
+			t.Errorf("expected %v parse errors of reason %s, not %v", n, reason, v)
+		}
+	}
+}
+
+func TestCollectorInstrument(t *testing.T) {
+	c := NewCollector()
+
+	var sent int
+	s := &ndp.RAServer{Sent: func(*ndp.ICMPRouterAdvertisement, net.IP) { sent++ }}
+	c.InstrumentRAServer(s)
+	s.Sent(&ndp.ICMPRouterAdvertisement{}, net.IPv6linklocalallnodes)
+	s.Sent(&ndp.ICMPRouterAdvertisement{}, net.IPv6linklocalallnodes)
+	if sent != 2 {
+		t.Errorf("expected the previous Sent to be called 2 times, not %d", sent)
+	}
+	if v := testutil.ToFloat64(c.raSent); v != 2 {
+		t.Errorf("expected 2 advertisements sent, not %v", v)
+	}
+
+	var duplicates int
+	cl := &ndp.SLAACClient{Duplicate: func(net.IP) { duplicates++ }}
+	c.InstrumentSLAACClient(cl)
+	cl.Duplicate(net.ParseIP("2001:db8::1"))
+	if duplicates != 1 {
+		t.Errorf("expected the previous Duplicate to be called once, not %d", duplicates)
+	}
+	if v := testutil.ToFloat64(c.dadFailures); v != 1 {
+		t.Errorf("expected 1 dad failure, not %v", v)
+	}
+}
+
+func TestCollectorNeighborCache(t *testing.T) {
+	a, b := ndp.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	c := NewCollector()
+	nc := ndp.NewNeighborCache(a)
+	c.InstrumentNeighborCache(nc)
+	nc.Seed(
+		ndp.Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}},
+		ndp.Neighbor{Address: net.ParseIP("fe80::3"), LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x03}},
+	)
+
+	if v := testutil.ToFloat64(c.neighbor.WithLabelValues(ndp.NeighborAdded.String())); v != 2 {
+		t.Errorf("expected 2 neighbors added, not %v", v)
+	}
+
+	expected := `
+# HELP ndp_neighbor_cache_entries Neighbor cache entries, by state.
+# TYPE ndp_neighbor_cache_entries gauge
+ndp_neighbor_cache_entries{state="delay"} 0
+ndp_neighbor_cache_entries{state="incomplete"} 0
+ndp_neighbor_cache_entries{state="probe"} 0
+ndp_neighbor_cache_entries{state="reachable"} 0
+ndp_neighbor_cache_entries{state="stale"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "ndp_neighbor_cache_entries"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorRegister(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewCollector()); err != nil {
+		t.Fatalf("unexpected error registering: %s", err)
+	}
+	if _, err := reg.Gather(); err != nil {
+		t.Errorf("unexpected error gathering: %s", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package ndptest holds a corpus of test vectors of NDP messages and loads
+// vectors from elsewhere, so captures of real-world stacks can be added as
+// regression tests of package ndp and reused by tests of its users.
+//
+// A vector is a file with extension .vec holding a message as a hex dump,
+// followed after a blank line by the JSON it decodes to as marshaled by
+// package ndp, or by a line starting with "error:" and the start of the
+// error parsing it returns instead. Lines before the hex dump starting with
+// # describe the vector. Hex bytes may be grouped, like tcpdump -x and xxd
+// print them, and tokens ending in a colon, like offsets, are ignored:
+//
+//	# router solicitation from a Linux host
+//	0x0000:  8500 0000 0000 0000 0101 0200 0000 0001
+//
+//	{"type":133,"name":"router solicitation","options":[...]}
+package ndptest
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/skoef/ndp"
+)
+
+// vectorExt is the extension of vector files
+const vectorExt = ".vec"
+
+//go:embed vectors/*.vec
+var corpus embed.FS
+
+// Vector is a message together with what package ndp decodes it to
+type Vector struct {
+	// Name is the name of the file without extension
+	Name        string
+	Description string
+	// Message is the ICMPv6 message, starting at its type
+	Message []byte
+	// JSON is what Message decodes to, unless Error is set
+	JSON json.RawMessage
+	// Error is the start of the error parsing Message returns, if it fails
+	Error string
+}
+
+// Corpus returns the vectors that come with this package, sorted by name
+func Corpus() ([]Vector, error) {
+	return Load(corpus, "vectors")
+}
+
+// Load returns the vectors of the .vec files in dir of fsys, sorted by name.
+// Use os.DirFS for vectors on disk
+func Load(fsys fs.FS, dir string) ([]Vector, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*"+vectorExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	vectors := make([]Vector, 0, len(names))
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		v.Name = strings.TrimSuffix(path.Base(name), vectorExt)
+		vectors = append(vectors, v)
+	}
+
+	return vectors, nil
+}
+
+// Parse returns the vector of a .vec file holding b, without a Name
+func Parse(b []byte) (Vector, error) {
+	var (
+		v           Vector
+		description []string
+		dump        bytes.Buffer
+		rest        []string
+		inDump      bool
+		done        bool
+	)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case done:
+			rest = append(rest, sc.Text())
+		case !inDump && strings.HasPrefix(line, "#"):
+			description = append(description, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+		case line == "":
+			done = inDump
+		default:
+			inDump = true
+			for _, tok := range strings.Fields(line) {
+				if !strings.HasSuffix(tok, ":") {
+					dump.WriteString(tok)
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return v, err
+	}
+
+	msg, err := hex.DecodeString(dump.String())
+	if err != nil {
+		return v, fmt.Errorf("invalid hex dump: %s", err)
+	}
+	if len(msg) == 0 {
+		return v, fmt.Errorf("no hex dump")
+	}
+	v.Description = strings.Join(description, " ")
+	v.Message = msg
+
+	expected := strings.TrimSpace(strings.Join(rest, "\n"))
+	switch {
+	case strings.HasPrefix(expected, "error:"):
+		v.Error = strings.TrimSpace(strings.TrimPrefix(expected, "error:"))
+	case expected == "":
+		return v, fmt.Errorf("no expected JSON or error")
+	case !json.Valid([]byte(expected)):
+		return v, fmt.Errorf("invalid expected JSON")
+	default:
+		v.JSON = json.RawMessage(expected)
+	}
+
+	return v, nil
+}
+
+// Check returns an error unless Message decodes to JSON, or fails to parse
+// with Error. JSON is compared by value, so its formatting doesn't matter
+func (v Vector) Check() error {
+	m, err := ndp.ParseMessage(v.Message)
+	if v.Error != "" {
+		if err == nil {
+			return fmt.Errorf("expected error %q, parsed %s", v.Error, m)
+		}
+		if !strings.HasPrefix(err.Error(), v.Error) {
+			return fmt.Errorf("expected error %q, not %q", v.Error, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	got, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var a, b interface{}
+	if err := json.Unmarshal(got, &a); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(v.JSON, &b); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(a, b) {
+		return fmt.Errorf("expected %s, not %s", v.JSON, got)
+	}
+
+	return nil
+}
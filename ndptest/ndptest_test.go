@@ -0,0 +1,89 @@
+package ndptest
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCorpus(t *testing.T) {
+	vectors, err := Corpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("expected vectors")
+	}
+	for _, v := range vectors {
+		if v.Description == "" {
+			t.Errorf("%s: expected a description", v.Name)
+		}
+		if err := v.Check(); err != nil {
+			t.Errorf("%s: %s", v.Name, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"captures/b.vec":  {Data: []byte("8500 0000 0000 0000\n\n{\"type\":133,\"name\":\"router solicitation\",\"options\":null}\n")},
+		"captures/a.vec":  {Data: []byte("# truncated\n# solicitation\n85 00\n\nerror: message too short\n")},
+		"captures/README": {Data: []byte("not a vector")},
+	}
+	vectors, err := Load(fsys, "captures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0].Name != "a" || vectors[1].Name != "b" {
+		t.Fatalf("unexpected vectors %+v", vectors)
+	}
+	if vectors[0].Description != "truncated solicitation" || vectors[0].Error != "message too short" {
+		t.Errorf("unexpected vector %+v", vectors[0])
+	}
+	for _, v := range vectors {
+		if err := v.Check(); err != nil {
+			t.Errorf("%s: %s", v.Name, err)
+		}
+	}
+
+	fsys["captures/c.vec"] = &fstest.MapFile{Data: []byte("zz\n\n{}\n")}
+	if _, err := Load(fsys, "captures"); err == nil || !strings.HasPrefix(err.Error(), "captures/c.vec: ") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	v, err := Parse([]byte("0x0000:  8500 0000\n0x0004:  0000 0000\n\n{\"type\": 133}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Message) != 8 || string(v.JSON) != `{"type": 133}` {
+		t.Errorf("unexpected vector %+v", v)
+	}
+
+	for _, in := range []string{
+		"",
+		"# only a description\n",
+		"8500 000\n\n{}\n",
+		"8500 0000 0000 0000\n",
+		"8500 0000 0000 0000\n\n{\n",
+	} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("expected %q to be invalid", in)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rs := []byte{0x85, 0, 0, 0, 0, 0, 0, 0}
+	for _, v := range []Vector{
+		{Message: rs, JSON: []byte(`{"type":134}`)},
+		{Message: rs, Error: "message too short"},
+		{Message: rs[:2], JSON: []byte(`{"type":133}`)},
+		{Message: rs[:2], Error: "invalid"},
+	} {
+		if err := v.Check(); err == nil {
+			t.Errorf("expected %+v to fail", v)
+		}
+	}
+}
@@ -0,0 +1,794 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnreachable is returned when a neighbor didn't answer solicitations
+var ErrUnreachable = errors.New("neighbor unreachable")
+
+var (
+	errNeighborCacheFull = errors.New("neighbor cache full")
+)
+
+// the defaults of NeighborCache, which follow those of Linux
+const (
+	defaultMaxNeighbors   = 1024
+	defaultStaleTimeout   = 60 * time.Second
+	defaultCreateInterval = time.Second
+	defaultCreateBurst    = 10
+	// forget about link-layer addresses creating entries beyond this many
+	maxCreateBuckets = 1024
+)
+
+// NeighborState is the reachability state of a neighbor cache entry as
+// described at https://tools.ietf.org/html/rfc4861#section-7.3.2
+type NeighborState int
+
+// Neighbor states
+const (
+	NeighborIncomplete NeighborState = iota
+	NeighborReachable
+	NeighborStale
+	NeighborDelay
+	NeighborProbe
+)
+
+func (s NeighborState) String() string {
+	switch s {
+	case NeighborIncomplete:
+		return "incomplete"
+	case NeighborReachable:
+		return "reachable"
+	case NeighborStale:
+		return "stale"
+	case NeighborDelay:
+		return "delay"
+	case NeighborProbe:
+		return "probe"
+	}
+
+	return "unknown"
+}
+
+// NeighborEventType tells what happened to a NeighborCache entry
+type NeighborEventType int
+
+// Neighbor event types
+const (
+	// NeighborAdded is a new entry, which is incomplete until resolved
+	// unless it was learned from a message
+	NeighborAdded NeighborEventType = iota
+	// NeighborResolved is an incomplete entry that learned its link-layer
+	// address
+	NeighborResolved
+	// NeighborUpdated is an entry that changed state or IsRouter flag
+	NeighborUpdated
+	// NeighborMoved is an entry of which the link-layer address changed
+	NeighborMoved
+	// NeighborUnreachable is an entry removed as neighbor unreachability
+	// detection or address resolution gave up on it
+	NeighborUnreachable
+	// NeighborExpired is an entry removed after being stale for
+	// StaleTimeout
+	NeighborExpired
+	// NeighborEvicted is an entry removed to make room for another
+	NeighborEvicted
+)
+
+func (t NeighborEventType) String() string {
+	switch t {
+	case NeighborAdded:
+		return "added"
+	case NeighborResolved:
+		return "resolved"
+	case NeighborUpdated:
+		return "updated"
+	case NeighborMoved:
+		return "moved"
+	case NeighborUnreachable:
+		return "unreachable"
+	case NeighborExpired:
+		return "expired"
+	case NeighborEvicted:
+		return "evicted"
+	}
+
+	return "unknown"
+}
+
+// removed reports whether the entry is gone after events of type t
+func (t NeighborEventType) removed() bool {
+	return t >= NeighborUnreachable
+}
+
+// NeighborEvent is a change to a NeighborCache entry
+type NeighborEvent struct {
+	Type NeighborEventType
+	// Neighbor is the entry after the change, or as it was when removed
+	Neighbor Neighbor
+	// Previous is the link-layer address a NeighborMoved entry had before
+	Previous net.HardwareAddr
+}
+
+// Neighbor is an entry of NeighborCache
+type Neighbor struct {
+	Address net.IP
+	// LinkLayerAddress is nil while the neighbor is incomplete
+	LinkLayerAddress net.HardwareAddr
+	State            NeighborState
+	IsRouter         bool
+}
+
+// NeighborCache implements the neighbor cache and neighbor unreachability
+// detection as described at https://tools.ietf.org/html/rfc4861#section-7.3.
+// It learns link-layer addresses from the messages it reads and solicits
+// neighbors to resolve their addresses or confirm they are still reachable
+type NeighborCache struct {
+	// Changed, when set, is called whenever an entry is added or changes
+	// state, link-layer address or IsRouter flag
+	Changed func(Neighbor)
+	// Removed, when set, is called for every entry that neighbor
+	// unreachability detection gave up on or that was evicted
+	Removed func(Neighbor)
+	// Events, when set, is called for every change like Changed and
+	// Removed are, telling what happened. It suits feeding inventory and
+	// alerting systems, which care about neighbors that moved or went
+	Events func(NeighborEvent)
+	// MaxEntries caps the number of entries, 0 for no limit. When the cache
+	// is full, the least recently used stale entry makes room, and without
+	// one no entry is created. It defaults to 1024
+	MaxEntries int
+	// StaleTimeout removes entries that stayed stale this long, 0 to keep
+	// them. It defaults to a minute
+	StaleTimeout time.Duration
+	// CreateInterval and CreateBurst limit how fast a single link-layer
+	// address creates entries by announcing itself for ever more
+	// addresses, which defaults to 10 at once and one a second after.
+	// Together with MaxEntries this resists neighbor cache exhaustion
+	CreateInterval time.Duration
+	CreateBurst    int
+
+	c *Conn
+
+	mu      sync.Mutex
+	entries map[netip.Addr]*neighborEntry
+	// reachable is the randomized ReachableTime derived from base
+	base      time.Duration
+	reachable time.Duration
+	retrans   time.Duration
+	// wake tells Serve that something is due earlier
+	wake chan struct{}
+	// waiters are closed once their incomplete entry resolves or goes
+	waiters map[netip.Addr][]chan struct{}
+	// creations limits the entries created per link-layer address
+	creations *bucketSet
+
+	// overridden by tests
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+	rand  func() float64
+}
+
+// neighborEntry is a Neighbor with the timer of its state
+type neighborEntry struct {
+	Neighbor
+	// next is when the state times out, if it does, and probes the number
+	// of solicitations sent in it
+	next   time.Time
+	probes int
+	// used is when the entry was last used, which decides what to evict
+	used time.Time
+}
+
+// neighborEvent is a change to report to Changed, Removed and Events
+type neighborEvent struct {
+	n    Neighbor
+	typ  NeighborEventType
+	prev net.HardwareAddr
+}
+
+// neighborProbe is a solicitation to send, to the solicited-node group of
+// target when multicast is set and to target itself otherwise
+type neighborProbe struct {
+	target    net.IP
+	multicast bool
+}
+
+// NewNeighborCache returns an empty NeighborCache for the link of c
+func NewNeighborCache(c *Conn) *NeighborCache {
+	nc := &NeighborCache{
+		MaxEntries:     defaultMaxNeighbors,
+		StaleTimeout:   defaultStaleTimeout,
+		CreateInterval: defaultCreateInterval,
+		CreateBurst:    defaultCreateBurst,
+		c:              c,
+		entries:        make(map[netip.Addr]*neighborEntry),
+		base:           ReachableTime,
+		retrans:        RetransTimer,
+		wake:           make(chan struct{}, 1),
+		waiters:        make(map[netip.Addr][]chan struct{}),
+		creations:      newBucketSet(maxCreateBuckets),
+		now:            time.Now,
+		after:          time.After,
+		rand:           rand.Float64,
+	}
+	nc.reachable = nc.randomReachable()
+
+	return nc
+}
+
+// randomReachable returns a ReachableTime derived from base as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.2
+func (nc *NeighborCache) randomReachable() time.Duration {
+	f := MinRandomFactor + nc.rand()*(MaxRandomFactor-MinRandomFactor)
+	return time.Duration(f * float64(nc.base))
+}
+
+// Serve hands the messages read from the Conn to ServeNDP and runs the timers
+// of the entries until ctx is done or reading or sending fails
+func (nc *NeighborCache) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- nc.c.Serve(ctx, nc)
+	}()
+
+	for {
+		nc.mu.Lock()
+		var timer <-chan time.Time
+		if due, ok := nc.due(); ok {
+			timer = nc.after(due.Sub(nc.now()))
+		}
+		nc.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case <-nc.wake:
+		case <-timer:
+			if err := nc.fire(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ServeNDP updates the cache from the link-layer addresses and flags of
+// neighbor and router discovery messages
+func (nc *NeighborCache) ServeNDP(m ICMP, md *Metadata) {
+	nc.mu.Lock()
+	now := nc.now()
+	var events []neighborEvent
+	switch m := m.(type) {
+	case *ICMPNeighborSolicitation:
+		// duplicate address detection doesn't tell where the sender is
+		if !md.Source.IsUnspecified() {
+			events = nc.unsolicited(events, md.Source, sourceLinkLayerAddr(m.Options), nil, now)
+		}
+	case *ICMPRouterSolicitation:
+		// solicitations come from hosts, see
+		// https://tools.ietf.org/html/rfc4861#section-6.2.6
+		if !md.Source.IsUnspecified() {
+			isRouter := false
+			events = nc.unsolicited(events, md.Source, sourceLinkLayerAddr(m.Options), &isRouter, now)
+		}
+	case *ICMPRouterAdvertisement:
+		isRouter := true
+		events = nc.unsolicited(events, md.Source, sourceLinkLayerAddr(m.Options), &isRouter, now)
+		nc.parameters(m)
+	case *ICMPNeighborAdvertisement:
+		events = nc.advertised(events, m, now)
+	}
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	nc.poke()
+}
+
+// Lookup returns the entry of ip, if any
+func (nc *NeighborCache) Lookup(ip net.IP) (Neighbor, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	e, ok := nc.entries[ipAddr(ip)]
+	if !ok {
+		return Neighbor{}, false
+	}
+
+	return e.Neighbor, true
+}
+
+// Neighbors returns all entries, sorted by address
+func (nc *NeighborCache) Neighbors() []Neighbor {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	neighbors := make([]Neighbor, 0, len(nc.entries))
+	for _, e := range nc.entries {
+		neighbors = append(neighbors, e.Neighbor)
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		return bytes.Compare(neighbors[i].Address, neighbors[j].Address) < 0
+	})
+
+	return neighbors
+}
+
+// Used tells that a packet is about to be sent to ip, returning its
+// link-layer address if it is known. Unknown neighbors are resolved and
+// stale ones are checked to still be reachable, as described at
+// https://tools.ietf.org/html/rfc4861#section-7.3.3
+func (nc *NeighborCache) Used(ip net.IP) (net.HardwareAddr, bool) {
+	nc.mu.Lock()
+	now := nc.now()
+	var events []neighborEvent
+	var lla net.HardwareAddr
+	key := ipAddr(ip)
+	e, ok := nc.entries[key]
+	switch {
+	case !ok:
+		if events, ok = nc.admit(events); !ok {
+			break
+		}
+		e = &neighborEntry{
+			Neighbor: Neighbor{Address: ip, State: NeighborIncomplete},
+			next:     now,
+			used:     now,
+		}
+		nc.entries[key] = e
+		events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborAdded})
+	case e.State == NeighborStale:
+		e.State = NeighborDelay
+		e.next = now.Add(DelayFirstProbeTime)
+		events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+		fallthrough
+	default:
+		e.used = now
+		lla = e.LinkLayerAddress
+	}
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	nc.poke()
+
+	return lla, lla != nil
+}
+
+// Resolve returns the link-layer address of ip, soliciting it if it isn't
+// known yet as described at https://tools.ietf.org/html/rfc4861#section-7.2.
+// It returns ErrUnreachable when ip doesn't answer and the error of ctx
+// when it is done first. Solicitations are only sent while Serve runs
+func (nc *NeighborCache) Resolve(ctx context.Context, ip net.IP) (net.HardwareAddr, error) {
+	ctx, end := nc.c.startSpan(ctx, SpanResolve, slog.String("addr", ip.String()))
+	lla, err := nc.resolve(ctx, ip)
+	end(err)
+
+	return lla, err
+}
+
+// resolve implements Resolve
+func (nc *NeighborCache) resolve(ctx context.Context, ip net.IP) (net.HardwareAddr, error) {
+	nc.mu.Lock()
+	key := ipAddr(ip)
+	wait := make(chan struct{})
+	nc.waiters[key] = append(nc.waiters[key], wait)
+	nc.mu.Unlock()
+	defer nc.unwait(key, wait)
+
+	if lla, ok := nc.Used(ip); ok {
+		return lla, nil
+	}
+	if _, ok := nc.Lookup(ip); !ok {
+		return nil, errNeighborCacheFull
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-wait:
+	}
+
+	if n, ok := nc.Lookup(ip); ok && n.LinkLayerAddress != nil {
+		return n.LinkLayerAddress, nil
+	}
+
+	return nil, ErrUnreachable
+}
+
+// unwait stops wait from waiting for key, if it still does
+func (nc *NeighborCache) unwait(key netip.Addr, wait chan struct{}) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	waiters := nc.waiters[key]
+	for i, w := range waiters {
+		if w == wait {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(nc.waiters, key)
+	} else {
+		nc.waiters[key] = waiters
+	}
+}
+
+// Confirm tells that an upper-layer protocol confirmed ip is reachable, like
+// TCP does when its data is acknowledged, see
+// https://tools.ietf.org/html/rfc4861#section-7.3.1
+func (nc *NeighborCache) Confirm(ip net.IP) {
+	nc.mu.Lock()
+	var events []neighborEvent
+	if e, ok := nc.entries[ipAddr(ip)]; ok && e.LinkLayerAddress != nil {
+		e.used = nc.now()
+		if nc.reach(e, e.used) {
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+		}
+	}
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	nc.poke()
+}
+
+// redirected processes the target of an accepted redirect as described at
+// https://tools.ietf.org/html/rfc4861#section-8.3, which is a router unless
+// it is the destination itself
+func (nc *NeighborCache) redirected(r *ICMPRedirect) {
+	var lla net.HardwareAddr
+	for _, o := range r.Options {
+		if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+			lla = o.LinkLayerAddress
+		}
+	}
+	isRouter := !r.TargetAddress.Equal(r.DestinationAddress)
+
+	nc.mu.Lock()
+	events := nc.unsolicited(nil, r.TargetAddress, lla, &isRouter, nc.now())
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	nc.poke()
+}
+
+// Seed adds the neighbors with a link-layer address that aren't cached yet,
+// like those another resolver such as the kernel knows. As nothing confirmed
+// them to us they start off stale, so they're checked once used
+func (nc *NeighborCache) Seed(neighbors ...Neighbor) {
+	nc.mu.Lock()
+	now := nc.now()
+	var events []neighborEvent
+	for _, n := range neighbors {
+		key := ipAddr(n.Address)
+		if _, ok := nc.entries[key]; ok || n.LinkLayerAddress == nil {
+			continue
+		}
+		var ok bool
+		if events, ok = nc.admit(events); !ok {
+			break
+		}
+		e := &neighborEntry{Neighbor: Neighbor{Address: n.Address, LinkLayerAddress: n.LinkLayerAddress, IsRouter: n.IsRouter}, used: now}
+		nc.stale(e, now)
+		nc.entries[key] = e
+		events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborAdded})
+	}
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	nc.poke()
+}
+
+// unsolicited processes a link-layer address ip announced for itself as
+// described at https://tools.ietf.org/html/rfc4861#section-7.2.3, setting
+// the IsRouter flag to isRouter if given. It must be called with mu held
+func (nc *NeighborCache) unsolicited(events []neighborEvent, ip net.IP, lla net.HardwareAddr, isRouter *bool, now time.Time) []neighborEvent {
+	key := ipAddr(ip)
+	e, ok := nc.entries[key]
+	if !ok {
+		if lla == nil || !nc.creations.bucket(lla.String(), nc.CreateInterval, nc.CreateBurst, now).allow(now) {
+			return events
+		}
+		if events, ok = nc.admit(events); !ok {
+			return events
+		}
+		e = &neighborEntry{Neighbor: Neighbor{Address: ip, LinkLayerAddress: lla}, used: now}
+		nc.stale(e, now)
+		if isRouter != nil {
+			e.IsRouter = *isRouter
+		}
+		nc.entries[key] = e
+		return append(events, neighborEvent{n: e.Neighbor, typ: NeighborAdded})
+	}
+
+	prev := e.LinkLayerAddress
+	moved := lla != nil && !bytes.Equal(lla, prev)
+	if moved {
+		e.LinkLayerAddress = lla
+		nc.stale(e, now)
+	}
+	routerChanged := isRouter != nil && e.IsRouter != *isRouter
+	if routerChanged {
+		e.IsRouter = *isRouter
+	}
+
+	return nc.changed(events, e, prev, moved || routerChanged)
+}
+
+// advertised processes a neighbor advertisement as described at
+// https://tools.ietf.org/html/rfc4861#section-7.2.5. It must be called with
+// mu held
+func (nc *NeighborCache) advertised(events []neighborEvent, na *ICMPNeighborAdvertisement, now time.Time) []neighborEvent {
+	e, ok := nc.entries[ipAddr(na.TargetAddress)]
+	if !ok {
+		return events
+	}
+
+	var lla net.HardwareAddr
+	for _, o := range na.Options {
+		if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+			lla = o.LinkLayerAddress
+		}
+	}
+
+	if e.State == NeighborIncomplete {
+		if lla == nil {
+			return events
+		}
+		e.LinkLayerAddress = lla
+		e.IsRouter = na.Router
+		if na.Solicited {
+			nc.reach(e, now)
+		} else {
+			nc.stale(e, now)
+		}
+		return append(events, neighborEvent{n: e.Neighbor, typ: NeighborResolved})
+	}
+
+	different := lla != nil && !bytes.Equal(lla, e.LinkLayerAddress)
+	if !na.Override && different {
+		// another address doesn't replace the one we know, but does make
+		// it doubtful
+		if e.State == NeighborReachable {
+			nc.stale(e, now)
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+		}
+		return events
+	}
+
+	prev, state := e.LinkLayerAddress, e.State
+	changed := e.IsRouter != na.Router
+	e.IsRouter = na.Router
+	if different {
+		e.LinkLayerAddress = lla
+	}
+	switch {
+	case na.Solicited:
+		nc.reach(e, now)
+	case different:
+		nc.stale(e, now)
+	}
+
+	return nc.changed(events, e, prev, changed || different || e.State != state)
+}
+
+// stale marks e stale, which times out after StaleTimeout. It must be called
+// with mu held
+func (nc *NeighborCache) stale(e *neighborEntry, now time.Time) {
+	e.State = NeighborStale
+	e.next, e.probes = time.Time{}, 0
+	if nc.StaleTimeout > 0 {
+		e.next = now.Add(nc.StaleTimeout)
+	}
+}
+
+// admit reports whether there is room for another entry, evicting the least
+// recently used stale entry if the cache is full. It must be called with mu
+// held
+func (nc *NeighborCache) admit(events []neighborEvent) ([]neighborEvent, bool) {
+	if nc.MaxEntries <= 0 || len(nc.entries) < nc.MaxEntries {
+		return events, true
+	}
+
+	var victim *neighborEntry
+	for _, e := range nc.entries {
+		if e.State == NeighborStale && (victim == nil || e.used.Before(victim.used)) {
+			victim = e
+		}
+	}
+	if victim == nil {
+		return events, false
+	}
+	delete(nc.entries, ipAddr(victim.Address))
+
+	return append(events, neighborEvent{n: victim.Neighbor, typ: NeighborEvicted}), true
+}
+
+// reach marks e reachable for ReachableTime from now, reporting whether it
+// wasn't before. It must be called with mu held
+func (nc *NeighborCache) reach(e *neighborEntry, now time.Time) bool {
+	changed := e.State != NeighborReachable
+	e.State = NeighborReachable
+	e.next, e.probes = now.Add(nc.reachable), 0
+
+	return changed
+}
+
+// changed reports a change to e if there was one, which resolved it when it
+// had no link-layer address and moved it when its address used to be prev.
+// It must be called with mu held
+func (nc *NeighborCache) changed(events []neighborEvent, e *neighborEntry, prev net.HardwareAddr, changed bool) []neighborEvent {
+	switch {
+	case !changed:
+		return events
+	case prev == nil && e.LinkLayerAddress != nil:
+		return append(events, neighborEvent{n: e.Neighbor, typ: NeighborResolved})
+	case !bytes.Equal(prev, e.LinkLayerAddress):
+		return append(events, neighborEvent{n: e.Neighbor, typ: NeighborMoved, prev: prev})
+	}
+
+	return append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+}
+
+// parameters takes over the ReachableTime and RetransTimer advertised by ra.
+// It must be called with mu held
+func (nc *NeighborCache) parameters(ra *ICMPRouterAdvertisement) {
+	if ra.ReachableTime != 0 {
+		if base := time.Duration(ra.ReachableTime) * time.Millisecond; base != nc.base {
+			nc.base = base
+			nc.reachable = nc.randomReachable()
+		}
+	}
+	if ra.RetransTimer != 0 {
+		nc.retrans = time.Duration(ra.RetransTimer) * time.Millisecond
+	}
+}
+
+// due returns when Serve needs to act next, if at all. It must be called with
+// mu held
+func (nc *NeighborCache) due() (time.Time, bool) {
+	var due time.Time
+	for _, e := range nc.entries {
+		if !e.next.IsZero() && (due.IsZero() || e.next.Before(due)) {
+			due = e.next
+		}
+	}
+
+	return due, !due.IsZero()
+}
+
+// fire advances the entries whose timers expired and sends the
+// solicitations that are due
+func (nc *NeighborCache) fire() error {
+	nc.mu.Lock()
+	now := nc.now()
+
+	var events []neighborEvent
+	var probes []neighborProbe
+	for key, e := range nc.entries {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+
+		switch e.State {
+		case NeighborReachable:
+			nc.stale(e, now)
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+			continue
+		case NeighborStale:
+			delete(nc.entries, key)
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborExpired})
+			continue
+		case NeighborDelay:
+			e.State = NeighborProbe
+			e.probes = 0
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUpdated})
+		}
+
+		limit := MaxUnicastSolicit
+		if e.State == NeighborIncomplete {
+			limit = MaxMulticastSolicit
+		}
+		if e.probes >= limit {
+			delete(nc.entries, key)
+			events = append(events, neighborEvent{n: e.Neighbor, typ: NeighborUnreachable})
+			continue
+		}
+		e.probes++
+		e.next = now.Add(nc.retrans)
+		probes = append(probes, neighborProbe{target: e.Address, multicast: e.State == NeighborIncomplete})
+	}
+	nc.mu.Unlock()
+
+	nc.notify(events)
+	for _, p := range probes {
+		if err := nc.probe(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// probe sends a neighbor solicitation
+func (nc *NeighborCache) probe(p neighborProbe) error {
+	dst := p.target
+	if p.multicast {
+		dst, _ = SolicitedNodeMulticast(p.target)
+	}
+	ns := &ICMPNeighborSolicitation{TargetAddress: p.target.To16()}
+	if lla := nc.c.linkLayerAddr(); lla != nil {
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	// probes are spaced by the RetransTimer of the link already, which may
+	// well be shorter than the one the rate limiter assumes
+	return nc.c.writeTo(ns, nil, dst, false)
+}
+
+// notify wakes up Resolve and calls Changed, Removed and Events for events
+func (nc *NeighborCache) notify(events []neighborEvent) {
+	nc.mu.Lock()
+	for _, ev := range events {
+		if !ev.typ.removed() && ev.n.State == NeighborIncomplete {
+			continue
+		}
+		key := ipAddr(ev.n.Address)
+		for _, w := range nc.waiters[key] {
+			close(w)
+		}
+		delete(nc.waiters, key)
+	}
+	nc.mu.Unlock()
+
+	log := nc.c.logger()
+	for _, ev := range events {
+		log.Debug("neighbor "+ev.typ.String(), "addr", ev.n.Address.String(), "lladdr", ev.n.LinkLayerAddress.String(), "state", ev.n.State.String())
+		removed := ev.typ.removed()
+		switch {
+		case removed && nc.Removed != nil:
+			nc.Removed(ev.n)
+		case !removed && nc.Changed != nil:
+			nc.Changed(ev.n)
+		}
+		if nc.Events != nil {
+			nc.Events(NeighborEvent{Type: ev.typ, Neighbor: ev.n, Previous: ev.prev})
+		}
+	}
+}
+
+// poke tells Serve to recompute its timer
+func (nc *NeighborCache) poke() {
+	select {
+	case nc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sourceLinkLayerAddr returns the address of the source link-layer address
+// option among options, if any
+func sourceLinkLayerAddr(options ICMPOptions) net.HardwareAddr {
+	for _, o := range options {
+		if o, ok := o.(*ICMPOptionSourceLinkLayerAddress); ok {
+			return o.LinkLayerAddress
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+//go:build linux
+
+package ndp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	errNoLinkLayerAddr = errors.New("neighbor has no link-layer address")
+	errNoNetlinkAck    = errors.New("netlink request not acknowledged")
+)
+
+// KernelNeighbors reads and maintains the IPv6 neighbor table of the kernel
+// for an interface through netlink, so neighbors resolved in userspace don't
+// have to be resolved by the kernel again and the other way around. Changing
+// the table takes CAP_NET_ADMIN
+type KernelNeighbors struct {
+	Interface *net.Interface
+}
+
+// Neighbors returns the entries of the kernel table, sorted by address.
+// Entries the kernel failed to resolve or doesn't resolve at all are left
+// out, and permanent ones are reachable. Pass them to NeighborCache.Seed to
+// start off with what the kernel knows
+func (k KernelNeighbors) Neighbors() ([]Neighbor, error) {
+	b, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, unix.AF_INET6)
+	if err != nil {
+		return nil, os.NewSyscallError("netlinkrib", err)
+	}
+
+	return kernelNeighbors(b, k.Interface.Index)
+}
+
+// Set adds n to the kernel table or replaces the entry it has for its
+// address. The kernel runs neighbor unreachability detection for it from
+// then on, starting in the state of n
+func (k KernelNeighbors) Set(n Neighbor) error {
+	if n.LinkLayerAddress == nil {
+		return errNoLinkLayerAddr
+	}
+
+	var flags uint8
+	if n.IsRouter {
+		flags |= unix.NTF_ROUTER
+	}
+	b := neighborMessage(unix.RTM_NEWNEIGH, unix.NLM_F_CREATE|unix.NLM_F_REPLACE, k.Interface.Index, nudState(n.State), flags, n.Address, n.LinkLayerAddress)
+
+	return netlinkRequest(b)
+}
+
+// Delete removes the kernel entry for ip, if any
+func (k KernelNeighbors) Delete(ip net.IP) error {
+	err := netlinkRequest(neighborMessage(unix.RTM_DELNEIGH, 0, k.Interface.Index, 0, 0, ip, nil))
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+
+	return err
+}
+
+// Mirror has nc install the entries it resolves into the kernel table and
+// delete the ones it removes, after calling the Changed and Removed it had
+// before. Errors are passed to errf when set. Call it before running nc
+func (k KernelNeighbors) Mirror(nc *NeighborCache, errf func(error)) {
+	report := func(err error) {
+		if err != nil && errf != nil {
+			errf(err)
+		}
+	}
+
+	changed, removed := nc.Changed, nc.Removed
+	nc.Changed = func(n Neighbor) {
+		if changed != nil {
+			changed(n)
+		}
+		// the kernel would start resolving incomplete entries itself
+		if n.State != NeighborIncomplete {
+			report(k.Set(n))
+		}
+	}
+	nc.Removed = func(n Neighbor) {
+		if removed != nil {
+			removed(n)
+		}
+		report(k.Delete(n.Address))
+	}
+}
+
+// kernelNeighbors returns the neighbors of interface index in the
+// RTM_NEWNEIGH messages in b
+func kernelNeighbors(b []byte, index int) ([]Neighbor, error) {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+
+	var neighbors []Neighbor
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH || len(m.Data) < unix.SizeofNdMsg {
+			continue
+		}
+		ndm := (*unix.NdMsg)(unsafe.Pointer(&m.Data[0]))
+		if int(ndm.Ifindex) != index || ndm.Family != unix.AF_INET6 {
+			continue
+		}
+		state, ok := neighborState(ndm.State)
+		if !ok {
+			continue
+		}
+
+		attrs, err := neighborAttrs(m)
+		if err != nil {
+			return nil, err
+		}
+		n := Neighbor{State: state, IsRouter: ndm.Flags&unix.NTF_ROUTER != 0}
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case unix.NDA_DST:
+				if len(a.Value) == net.IPv6len {
+					n.Address = append(net.IP(nil), a.Value...)
+				}
+			case unix.NDA_LLADDR:
+				n.LinkLayerAddress = append(net.HardwareAddr(nil), a.Value...)
+			}
+		}
+		if n.Address == nil || (n.State != NeighborIncomplete && n.LinkLayerAddress == nil) {
+			continue
+		}
+		neighbors = append(neighbors, n)
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		return bytes.Compare(neighbors[i].Address, neighbors[j].Address) < 0
+	})
+
+	return neighbors, nil
+}
+
+// neighborAttrs returns the attributes of neighbor message m, which
+// syscall.ParseNetlinkRouteAttr doesn't know about
+func neighborAttrs(m syscall.NetlinkMessage) ([]syscall.NetlinkRouteAttr, error) {
+	var attrs []syscall.NetlinkRouteAttr
+	b := m.Data[unix.SizeofNdMsg:]
+	for len(b) >= unix.SizeofRtAttr {
+		a := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+		if int(a.Len) < unix.SizeofRtAttr || int(a.Len) > len(b) {
+			return nil, os.NewSyscallError("parsenetlinkrouteattr", unix.EINVAL)
+		}
+		attrs = append(attrs, syscall.NetlinkRouteAttr{
+			Attr:  syscall.RtAttr{Len: a.Len, Type: a.Type},
+			Value: b[unix.SizeofRtAttr:a.Len],
+		})
+		n := (int(a.Len) + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+		if n > len(b) {
+			break
+		}
+		b = b[n:]
+	}
+
+	return attrs, nil
+}
+
+// neighborState returns the state for kernel state nud, if it has one
+func neighborState(nud uint16) (NeighborState, bool) {
+	switch {
+	case nud&unix.NUD_PERMANENT != 0, nud&unix.NUD_REACHABLE != 0:
+		return NeighborReachable, true
+	case nud&unix.NUD_STALE != 0:
+		return NeighborStale, true
+	case nud&unix.NUD_DELAY != 0:
+		return NeighborDelay, true
+	case nud&unix.NUD_PROBE != 0:
+		return NeighborProbe, true
+	case nud&unix.NUD_INCOMPLETE != 0:
+		return NeighborIncomplete, true
+	}
+
+	return 0, false
+}
+
+// nudState returns the kernel state for s
+func nudState(s NeighborState) uint16 {
+	switch s {
+	case NeighborReachable:
+		return unix.NUD_REACHABLE
+	case NeighborStale:
+		return unix.NUD_STALE
+	case NeighborDelay:
+		return unix.NUD_DELAY
+	case NeighborProbe:
+		return unix.NUD_PROBE
+	}
+
+	return unix.NUD_INCOMPLETE
+}
+
+// neighborMessage returns a netlink request of type typ for the neighbor ip
+// on interface index, leaving out its link-layer address when lla is nil
+func neighborMessage(typ, flags uint16, index int, state uint16, ntf uint8, ip net.IP, lla net.HardwareAddr) []byte {
+	ndm := unix.NdMsg{Family: unix.AF_INET6, Ifindex: int32(index), State: state, Flags: ntf}
+	b := make([]byte, unix.SizeofNlMsghdr, unix.SizeofNlMsghdr+unix.SizeofNdMsg+2*unix.SizeofRtAttr+net.IPv6len+len(lla)+4)
+	b = append(b, (*[unix.SizeofNdMsg]byte)(unsafe.Pointer(&ndm))[:]...)
+	b = appendRouteAttr(b, unix.NDA_DST, ip.To16())
+	if lla != nil {
+		b = appendRouteAttr(b, unix.NDA_LLADDR, lla)
+	}
+
+	h := (*unix.NlMsghdr)(unsafe.Pointer(&b[0]))
+	h.Len = uint32(len(b))
+	h.Type = typ
+	h.Flags = unix.NLM_F_REQUEST | unix.NLM_F_ACK | flags
+	h.Seq = 1
+
+	return b
+}
+
+// appendRouteAttr appends a route attribute of type typ holding v to b,
+// padded to the netlink alignment
+func appendRouteAttr(b []byte, typ uint16, v []byte) []byte {
+	a := unix.RtAttr{Len: uint16(unix.SizeofRtAttr + len(v)), Type: typ}
+	b = append(b, (*[unix.SizeofRtAttr]byte)(unsafe.Pointer(&a))[:]...)
+	b = append(b, v...)
+	for len(b)%unix.NLMSG_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+
+	return b
+}
+
+// netlinkRequest sends request b to the kernel and returns the error it
+// acknowledges it with
+func netlinkRequest(b []byte) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return os.NewSyscallError("recvfrom", err)
+	}
+
+	return netlinkAck(buf[:n])
+}
+
+// netlinkAck returns the error of the acknowledgement in the netlink
+// messages in b
+func netlinkAck(b []byte) error {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return os.NewSyscallError("parsenetlinkmessage", err)
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != unix.NLMSG_ERROR || len(m.Data) < 4 {
+			continue
+		}
+		if errno := -*(*int32)(unsafe.Pointer(&m.Data[0])); errno != 0 {
+			return os.NewSyscallError("netlink", unix.Errno(errno))
+		}
+		return nil
+	}
+
+	return errNoNetlinkAck
+}
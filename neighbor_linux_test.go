@@ -0,0 +1,188 @@
+//go:build linux
+
+package ndp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNeighborMessage(t *testing.T) {
+	ip := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+	b := neighborMessage(unix.RTM_NEWNEIGH, unix.NLM_F_CREATE, 3, unix.NUD_STALE, unix.NTF_ROUTER, ip, lla)
+
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, not %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.Header.Type != unix.RTM_NEWNEIGH {
+		t.Errorf("unexpected type %d", m.Header.Type)
+	}
+	if f := unix.NLM_F_REQUEST | unix.NLM_F_ACK | unix.NLM_F_CREATE; m.Header.Flags != uint16(f) {
+		t.Errorf("unexpected flags %#x", m.Header.Flags)
+	}
+
+	ndm := (*unix.NdMsg)(unsafe.Pointer(&m.Data[0]))
+	if ndm.Family != unix.AF_INET6 || ndm.Ifindex != 3 || ndm.State != unix.NUD_STALE || ndm.Flags != unix.NTF_ROUTER {
+		t.Errorf("unexpected header %+v", *ndm)
+	}
+
+	attrs, err := neighborAttrs(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 2 {
+		t.Fatalf("expected 2 attributes, not %d", len(attrs))
+	}
+	if attrs[0].Attr.Type != unix.NDA_DST || !net.IP(attrs[0].Value).Equal(ip) {
+		t.Errorf("unexpected destination %v", attrs[0])
+	}
+	if attrs[1].Attr.Type != unix.NDA_LLADDR || !bytes.Equal(attrs[1].Value, lla) {
+		t.Errorf("unexpected link-layer address %v", attrs[1])
+	}
+
+	// deletions only take the address
+	b = neighborMessage(unix.RTM_DELNEIGH, 0, 3, 0, 0, ip, nil)
+	msgs, _ = syscall.ParseNetlinkMessage(b)
+	if attrs, _ = neighborAttrs(msgs[0]); len(attrs) != 1 {
+		t.Errorf("expected 1 attribute, not %d", len(attrs))
+	}
+}
+
+func TestNudState(t *testing.T) {
+	tests := map[NeighborState]uint16{
+		NeighborIncomplete: unix.NUD_INCOMPLETE,
+		NeighborReachable:  unix.NUD_REACHABLE,
+		NeighborStale:      unix.NUD_STALE,
+		NeighborDelay:      unix.NUD_DELAY,
+		NeighborProbe:      unix.NUD_PROBE,
+	}
+	for s, nud := range tests {
+		if n := nudState(s); n != nud {
+			t.Errorf("expected %#x for %s, not %#x", nud, s, n)
+		}
+	}
+}
+
+func TestNetlinkAck(t *testing.T) {
+	ack := func(errno int32) []byte {
+		b := make([]byte, unix.SizeofNlMsghdr+4)
+		binary.NativeEndian.PutUint32(b, uint32(len(b)))
+		binary.NativeEndian.PutUint16(b[4:], unix.NLMSG_ERROR)
+		binary.NativeEndian.PutUint32(b[unix.SizeofNlMsghdr:], uint32(-errno))
+		return b
+	}
+
+	if err := netlinkAck(ack(0)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := netlinkAck(ack(int32(unix.EPERM))); !errors.Is(err, unix.EPERM) {
+		t.Errorf("expected EPERM, not %v", err)
+	}
+	if err := netlinkAck(nil); err != errNoNetlinkAck {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestKernelNeighbors(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	k := KernelNeighbors{Interface: lo}
+
+	if err := k.Set(Neighbor{Address: net.ParseIP("fe80::2")}); err != errNoLinkLayerAddr {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// missing entries are deleted already, without privileges we're told
+	// off by the kernel
+	if err := k.Delete(net.ParseIP("fe80::dead")); err != nil && !errors.Is(err, unix.EPERM) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Mirror calls the callbacks that were set before
+	nc := NewNeighborCache(nil)
+	var changed, removed []Neighbor
+	nc.Changed = func(n Neighbor) { changed = append(changed, n) }
+	nc.Removed = func(n Neighbor) { removed = append(removed, n) }
+	var errs []error
+	k.Mirror(nc, func(err error) { errs = append(errs, err) })
+
+	n := Neighbor{Address: net.ParseIP("fe80::dead"), State: NeighborIncomplete}
+	nc.Changed(n)
+	nc.Removed(n)
+	if len(changed) != 1 || len(removed) != 1 {
+		t.Errorf("expected callbacks to be called once, not %d and %d times", len(changed), len(removed))
+	}
+	// incomplete entries are left to the kernel
+	for _, err := range errs {
+		if !errors.Is(err, unix.EPERM) {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestKernelNeighborsParse(t *testing.T) {
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+	var b []byte
+	for _, m := range []struct {
+		index int
+		state uint16
+		ntf   uint8
+		ip    string
+		lla   net.HardwareAddr
+	}{
+		{3, unix.NUD_STALE, unix.NTF_ROUTER, "fe80::3", lla},
+		{3, unix.NUD_REACHABLE, 0, "fe80::2", lla},
+		{3, unix.NUD_PERMANENT, 0, "2001:db8::1", lla},
+		{3, unix.NUD_INCOMPLETE, 0, "fe80::4", nil},
+		{3, unix.NUD_FAILED, 0, "fe80::5", nil},
+		{3, unix.NUD_NOARP, 0, "fe80::6", nil},
+		{3, unix.NUD_STALE, 0, "fe80::7", nil},
+		{4, unix.NUD_REACHABLE, 0, "fe80::8", lla},
+	} {
+		b = append(b, neighborMessage(unix.RTM_NEWNEIGH, 0, m.index, m.state, m.ntf, net.ParseIP(m.ip), m.lla)...)
+	}
+
+	neighbors, err := kernelNeighbors(b, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Neighbor{
+		{Address: net.ParseIP("2001:db8::1"), LinkLayerAddress: lla, State: NeighborReachable},
+		{Address: net.ParseIP("fe80::2"), LinkLayerAddress: lla, State: NeighborReachable},
+		{Address: net.ParseIP("fe80::3"), LinkLayerAddress: lla, State: NeighborStale, IsRouter: true},
+		{Address: net.ParseIP("fe80::4"), State: NeighborIncomplete},
+	}
+	if len(neighbors) != len(expected) {
+		t.Fatalf("expected %d neighbors, not %+v", len(expected), neighbors)
+	}
+	for i, n := range neighbors {
+		e := expected[i]
+		if !n.Address.Equal(e.Address) || !bytes.Equal(n.LinkLayerAddress, e.LinkLayerAddress) || n.State != e.State || n.IsRouter != e.IsRouter {
+			t.Errorf("expected %+v, not %+v", e, n)
+		}
+	}
+
+	// the kernel table can be dumped without privileges
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	if _, err := (KernelNeighbors{Interface: lo}).Neighbors(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,517 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// testNeighborCache returns a NeighborCache on one end of a pipe with a
+// clock that only moves when told to, and the other end
+func testNeighborCache(t *testing.T) (*NeighborCache, *time.Time, *Conn, *[]Neighbor) {
+	t.Helper()
+	a, b := Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	nc := NewNeighborCache(a)
+	now := time.Unix(0, 0)
+	nc.now = func() time.Time { return now }
+	events := new([]Neighbor)
+	nc.Changed = func(n Neighbor) { *events = append(*events, n) }
+	nc.Removed = func(n Neighbor) {
+		n.State = -1
+		*events = append(*events, n)
+	}
+
+	return nc, &now, b, events
+}
+
+func readSolicitation(t *testing.T, c *Conn) (*ICMPNeighborSolicitation, *Metadata) {
+	t.Helper()
+	m, md, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+
+	return ns, md
+}
+
+func TestNeighborStateString(t *testing.T) {
+	if s := NeighborDelay.String(); s != "delay" {
+		t.Errorf("unexpected string %s", s)
+	}
+	if s := NeighborState(42).String(); s != "unknown" {
+		t.Errorf("unexpected string %s", s)
+	}
+}
+
+func TestNeighborCacheUnsolicited(t *testing.T) {
+	nc, _, _, events := testNeighborCache(t)
+	ip := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	md := &Metadata{Source: ip}
+
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	// no address to learn
+	nc.ServeNDP(ns, md)
+	if _, ok := nc.Lookup(ip); ok {
+		t.Error("unexpected entry without link-layer address")
+	}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	nc.ServeNDP(ns, &Metadata{Source: net.IPv6unspecified})
+	if len(nc.Neighbors()) != 0 {
+		t.Error("unexpected entry for duplicate address detection")
+	}
+
+	nc.ServeNDP(ns, md)
+	if n, ok := nc.Lookup(ip); !ok || n.State != NeighborStale || n.LinkLayerAddress.String() != lla.String() || n.IsRouter {
+		t.Errorf("unexpected entry %+v", n)
+	}
+
+	// advertisements make it a router, solicitations a host
+	ra := &ICMPRouterAdvertisement{}
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	nc.ServeNDP(ra, md)
+	if n, _ := nc.Lookup(ip); !n.IsRouter || n.State != NeighborStale {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	nc.ServeNDP(&ICMPRouterSolicitation{}, md)
+	if n, _ := nc.Lookup(ip); n.IsRouter {
+		t.Errorf("unexpected entry %+v", n)
+	}
+
+	// the same address changes nothing, another makes it stale
+	nc.ServeNDP(&ICMPNeighborAdvertisement{Solicited: true, TargetAddress: ip}, md)
+	nc.ServeNDP(ns, md)
+	if n, _ := nc.Lookup(ip); n.State != NeighborReachable {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	other := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	other.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 3}})
+	nc.ServeNDP(other, md)
+	if n, _ := nc.Lookup(ip); n.State != NeighborStale || n.LinkLayerAddress[5] != 3 {
+		t.Errorf("unexpected entry %+v", n)
+	}
+
+	if len(*events) != 5 {
+		t.Errorf("unexpected events %v", *events)
+	}
+}
+
+func TestNeighborCacheAdvertised(t *testing.T) {
+	ip := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	other := net.HardwareAddr{0x02, 0, 0, 0, 0, 3}
+	na := func(solicited, override bool, lla net.HardwareAddr) *ICMPNeighborAdvertisement {
+		na := &ICMPNeighborAdvertisement{Solicited: solicited, Override: override, TargetAddress: ip}
+		if lla != nil {
+			na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return na
+	}
+
+	tests := []struct {
+		name     string
+		state    NeighborState
+		na       *ICMPNeighborAdvertisement
+		expected NeighborState
+		lla      net.HardwareAddr
+	}{
+		{"incomplete without address", NeighborIncomplete, na(true, true, nil), NeighborIncomplete, nil},
+		{"incomplete solicited", NeighborIncomplete, na(true, false, lla), NeighborReachable, lla},
+		{"incomplete unsolicited", NeighborIncomplete, na(false, false, lla), NeighborStale, lla},
+		{"reachable other address", NeighborReachable, na(true, false, other), NeighborStale, lla},
+		{"stale other address", NeighborStale, na(true, false, other), NeighborStale, lla},
+		{"stale override", NeighborStale, na(false, true, other), NeighborStale, other},
+		{"stale solicited override", NeighborStale, na(true, true, other), NeighborReachable, other},
+		{"delay solicited", NeighborDelay, na(true, false, nil), NeighborReachable, lla},
+		{"probe same address", NeighborProbe, na(true, false, lla), NeighborReachable, lla},
+		{"probe unsolicited", NeighborProbe, na(false, false, nil), NeighborProbe, lla},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nc, _, _, _ := testNeighborCache(t)
+			e := &neighborEntry{Neighbor: Neighbor{Address: ip, State: test.state}}
+			if test.state != NeighborIncomplete {
+				e.LinkLayerAddress = lla
+			}
+			nc.entries[ipAddr(ip)] = e
+
+			nc.ServeNDP(test.na, &Metadata{Source: ip})
+			n, _ := nc.Lookup(ip)
+			if n.State != test.expected || n.LinkLayerAddress.String() != test.lla.String() {
+				t.Errorf("expected %s at %s, not %s at %s", test.expected, test.lla, n.State, n.LinkLayerAddress)
+			}
+		})
+	}
+
+	// advertisements don't create entries
+	nc, _, _, _ := testNeighborCache(t)
+	nc.ServeNDP(na(true, true, lla), &Metadata{Source: ip})
+	if _, ok := nc.Lookup(ip); ok {
+		t.Error("unexpected entry")
+	}
+}
+
+func TestNeighborCacheUnreachability(t *testing.T) {
+	nc, now, b, events := testNeighborCache(t)
+	nc.rand = func() float64 { return 0.5 }
+	ip := b.Addr()
+	// probes more frequent than the rate limiter allows aren't dropped
+	nc.c.SetRateLimiting(true)
+	b.SetReadDeadline(time.Now().Add(time.Second))
+
+	// reachable entries become stale after ReachableTime
+	ra := &ICMPRouterAdvertisement{ReachableTime: 10000, RetransTimer: 500}
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: b.Interface().HardwareAddr})
+	nc.ServeNDP(ra, &Metadata{Source: ip})
+	nc.Confirm(ip)
+	if n, _ := nc.Lookup(ip); n.State != NeighborReachable {
+		t.Fatalf("unexpected entry %+v", n)
+	}
+	if due, _ := nc.due(); !due.Equal(now.Add(10 * time.Second)) {
+		t.Errorf("unexpected reachable time %s", due.Sub(*now))
+	}
+	*now = now.Add(10 * time.Second)
+	nc.fire()
+	if n, _ := nc.Lookup(ip); n.State != NeighborStale {
+		t.Errorf("unexpected entry %+v", n)
+	}
+
+	// using it delays probing a while
+	if lla, ok := nc.Used(ip); !ok || lla.String() != b.Interface().HardwareAddr.String() {
+		t.Errorf("unexpected link-layer address %s", lla)
+	}
+	if n, _ := nc.Lookup(ip); n.State != NeighborDelay {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	*now = now.Add(DelayFirstProbeTime)
+	for i := 0; i < MaxUnicastSolicit; i++ {
+		nc.fire()
+		ns, md := readSolicitation(t, b)
+		if !ns.TargetAddress.Equal(ip) || !md.Destination.Equal(ip) {
+			t.Errorf("unexpected probe for %s to %s", ns.TargetAddress, md.Destination)
+		}
+		*now = now.Add(500 * time.Millisecond)
+	}
+	if n, _ := nc.Lookup(ip); n.State != NeighborProbe {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	nc.fire()
+	if _, ok := nc.Lookup(ip); ok {
+		t.Error("expected unreachable neighbor to be removed")
+	}
+
+	states := []NeighborState{NeighborStale, NeighborReachable, NeighborStale, NeighborDelay, NeighborProbe, -1}
+	if len(*events) != len(states) {
+		t.Fatalf("unexpected events %v", *events)
+	}
+	for i, s := range states {
+		if (*events)[i].State != s {
+			t.Errorf("expected %s at %d, not %s", s, i, (*events)[i].State)
+		}
+	}
+}
+
+func TestNeighborCacheServe(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	nc := NewNeighborCache(a)
+	clock := newFakeClockFor(&nc.now, &nc.after)
+	changed := make(chan Neighbor, 16)
+	nc.Changed = func(n Neighbor) { changed <- n }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- nc.Serve(ctx)
+	}()
+
+	ip := b.Addr()
+	if _, ok := nc.Used(ip); ok {
+		t.Error("unexpected link-layer address")
+	}
+	if n := <-changed; n.State != NeighborIncomplete {
+		t.Errorf("unexpected entry %+v", n)
+	}
+
+	// solicited right away
+	if d := clock.wait(t); d != 0 {
+		t.Errorf("unexpected delay %s", d)
+	}
+	clock.fire <- clock.now()
+	ns, md := readSolicitation(t, b)
+	group, _ := SolicitedNodeMulticast(ip)
+	if !ns.TargetAddress.Equal(ip) || !md.Destination.Equal(group) {
+		t.Errorf("unexpected solicitation for %s to %s", ns.TargetAddress, md.Destination)
+	}
+	clock.wait(t)
+
+	if err := b.SendNA(ip, a.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-changed:
+		if n.State != NeighborReachable || n.LinkLayerAddress.String() != b.Interface().HardwareAddr.String() {
+			t.Errorf("unexpected entry %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for advertisement")
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNeighborCacheResolve(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	nc := NewNeighborCache(a)
+	clock := newFakeClockFor(&nc.now, &nc.after)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- nc.Serve(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-errc
+	}()
+
+	type result struct {
+		lla net.HardwareAddr
+		err error
+	}
+	resolve := func(ip net.IP) <-chan result {
+		r := make(chan result, 1)
+		go func() {
+			lla, err := nc.Resolve(context.Background(), ip)
+			r <- result{lla, err}
+		}()
+		return r
+	}
+
+	// b answers
+	r := resolve(b.Addr())
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readSolicitation(t, b)
+	clock.wait(t)
+	if err := b.SendNA(b.Addr(), a.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	if res := <-r; res.err != nil || res.lla.String() != b.Interface().HardwareAddr.String() {
+		t.Errorf("unexpected result %v", res)
+	}
+	// the Serve loop catching up on the advertisement
+	clock.wait(t)
+
+	// known now
+	if lla, err := nc.Resolve(context.Background(), b.Addr()); err != nil || lla == nil {
+		t.Errorf("unexpected result %s, %v", lla, err)
+	}
+
+	// nobody answers for another address
+	ip := net.ParseIP("fe80::42")
+	r = resolve(ip)
+	for i := 0; i < MaxMulticastSolicit; i++ {
+		// the timer of the reachable entry of b may come first
+		for d := clock.wait(t); d != 0 && d != RetransTimer; d = clock.wait(t) {
+		}
+		clock.advance(RetransTimer)
+		clock.fire <- clock.now()
+		if ns, _ := readSolicitation(t, b); !ns.TargetAddress.Equal(ip) {
+			t.Errorf("unexpected target %s", ns.TargetAddress)
+		}
+	}
+	clock.wait(t)
+	clock.advance(RetransTimer)
+	clock.fire <- clock.now()
+	if res := <-r; res.err != ErrUnreachable {
+		t.Errorf("unexpected result %v", res)
+	}
+
+	// the context ends it early
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	if _, err := nc.Resolve(cctx, net.ParseIP("fe80::43")); err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNeighborCacheLimits(t *testing.T) {
+	nc, now, _, events := testNeighborCache(t)
+	nc.MaxEntries = 3
+	nc.CreateBurst = 2
+
+	announce := func(ip string, lla byte) {
+		ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, lla}})
+		nc.ServeNDP(ns, &Metadata{Source: net.ParseIP(ip)})
+	}
+
+	// a single link-layer address only creates so many entries
+	announce("fe80::10", 1)
+	announce("fe80::11", 1)
+	announce("fe80::12", 1)
+	if n := len(nc.Neighbors()); n != 2 {
+		t.Errorf("expected 2 entries, not %d", n)
+	}
+	*now = now.Add(nc.CreateInterval)
+	announce("fe80::12", 1)
+	if _, ok := nc.Lookup(net.ParseIP("fe80::12")); !ok {
+		t.Error("expected entry once the rate allows")
+	}
+
+	// the least recently used stale entry makes room
+	*now = now.Add(time.Second)
+	nc.Used(net.ParseIP("fe80::10"))
+	announce("fe80::20", 2)
+	if _, ok := nc.Lookup(net.ParseIP("fe80::11")); ok {
+		t.Error("expected fe80::11 to be evicted")
+	}
+	if _, ok := nc.Lookup(net.ParseIP("fe80::20")); !ok {
+		t.Error("expected entry for fe80::20")
+	}
+	if last := (*events)[len(*events)-2]; last.State != -1 || !last.Address.Equal(net.ParseIP("fe80::11")) {
+		t.Errorf("unexpected eviction %+v", last)
+	}
+
+	// without stale entries there's no room
+	nc.Confirm(net.ParseIP("fe80::12"))
+	nc.Confirm(net.ParseIP("fe80::20"))
+	if _, err := nc.Resolve(context.Background(), net.ParseIP("fe80::30")); err != errNeighborCacheFull {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// stale entries go after a while
+	*now = now.Add(DelayFirstProbeTime)
+	nc.fire()
+	nc.ServeNDP(&ICMPNeighborAdvertisement{Solicited: true, TargetAddress: net.ParseIP("fe80::10")}, &Metadata{})
+	*now = now.Add(nc.reachable)
+	nc.fire()
+	if n := nc.Neighbors()[0]; n.State != NeighborStale {
+		t.Fatalf("unexpected entry %+v", n)
+	}
+	*now = now.Add(nc.StaleTimeout)
+	nc.fire()
+	if n := len(nc.Neighbors()); n != 0 {
+		t.Errorf("expected stale entries to be removed, %d left", n)
+	}
+}
+
+func TestNeighborCacheSeed(t *testing.T) {
+	nc, _, _, events := testNeighborCache(t)
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	ip := net.ParseIP("fe80::2")
+	nc.Used(ip)
+
+	nc.Seed(
+		Neighbor{Address: ip, LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 9}, State: NeighborReachable},
+		Neighbor{Address: net.ParseIP("fe80::3"), LinkLayerAddress: lla, State: NeighborReachable, IsRouter: true},
+		Neighbor{Address: net.ParseIP("fe80::4"), State: NeighborIncomplete},
+	)
+
+	// what the cache knows wins
+	if n, _ := nc.Lookup(ip); n.State != NeighborIncomplete || n.LinkLayerAddress != nil {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	// seeded entries aren't confirmed
+	if n, _ := nc.Lookup(net.ParseIP("fe80::3")); n.State != NeighborStale || !bytes.Equal(n.LinkLayerAddress, lla) || !n.IsRouter {
+		t.Errorf("unexpected entry %+v", n)
+	}
+	if _, ok := nc.Lookup(net.ParseIP("fe80::4")); ok {
+		t.Error("unexpected entry without link-layer address")
+	}
+	if len(*events) != 2 {
+		t.Errorf("expected 2 events, not %d", len(*events))
+	}
+
+	// seeding respects MaxEntries
+	nc.MaxEntries = 2
+	nc.Seed(Neighbor{Address: net.ParseIP("fe80::5"), LinkLayerAddress: lla})
+	if len(nc.Neighbors()) != 2 {
+		t.Errorf("expected 2 entries, not %d", len(nc.Neighbors()))
+	}
+}
+
+func TestNeighborCacheEvents(t *testing.T) {
+	nc, now, b, _ := testNeighborCache(t)
+	var events []NeighborEvent
+	nc.Events = func(ev NeighborEvent) { events = append(events, ev) }
+	ip := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	other := net.HardwareAddr{0x02, 0, 0, 0, 0, 3}
+
+	nc.Used(ip)
+	na := &ICMPNeighborAdvertisement{Solicited: true, TargetAddress: ip}
+	na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+	nc.ServeNDP(na, &Metadata{Source: ip})
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: other})
+	nc.ServeNDP(ns, &Metadata{Source: ip})
+	nc.Confirm(ip)
+	*now = now.Add(nc.reachable)
+	nc.fire()
+	*now = now.Add(nc.StaleTimeout)
+	nc.fire()
+
+	// incomplete entries are unreachable once solicitations go unanswered
+	unresolved := net.ParseIP("fe80::3")
+	nc.Used(unresolved)
+	for i := 0; i < MaxMulticastSolicit; i++ {
+		nc.fire()
+		readSolicitation(t, b)
+		*now = now.Add(RetransTimer)
+	}
+	nc.fire()
+
+	// and stale ones make room for new ones
+	nc.MaxEntries = 1
+	nc.ServeNDP(ns, &Metadata{Source: ip})
+	nc.ServeNDP(ns, &Metadata{Source: net.ParseIP("fe80::4")})
+
+	expected := []NeighborEventType{
+		NeighborAdded, NeighborResolved, NeighborMoved, NeighborUpdated, NeighborUpdated, NeighborExpired,
+		NeighborAdded, NeighborUnreachable,
+		NeighborAdded, NeighborEvicted, NeighborAdded,
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	for i, typ := range expected {
+		if events[i].Type != typ {
+			t.Errorf("expected %s at %d, not %s", typ, i, events[i].Type)
+		}
+	}
+	if ev := events[2]; !bytes.Equal(ev.Previous, lla) || !bytes.Equal(ev.Neighbor.LinkLayerAddress, other) {
+		t.Errorf("unexpected move %+v", ev)
+	}
+	if ev := events[9]; !ev.Neighbor.Address.Equal(ip) {
+		t.Errorf("unexpected eviction %+v", ev)
+	}
+}
+
+func TestNeighborEventTypeString(t *testing.T) {
+	if s := NeighborMoved.String(); s != "moved" {
+		t.Errorf("unexpected string %s", s)
+	}
+	if s := NeighborEventType(42).String(); s != "unknown" {
+		t.Errorf("unexpected string %s", s)
+	}
+}
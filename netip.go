@@ -0,0 +1,196 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// ipAddr returns ip as a netip.Addr, IPv4-mapped addresses unmapped so they
+// compare equal to their 4 byte form. It returns the zero Addr for invalid
+// ip
+func ipAddr(ip net.IP) netip.Addr {
+	a, _ := netip.AddrFromSlice(ip)
+	return a.Unmap()
+}
+
+// ipPrefix returns prefix ip of length bits as a netip.Prefix, masked like
+// it is sent. It returns the zero Prefix for invalid ip
+func ipPrefix(ip net.IP, bits int) netip.Prefix {
+	p, err := ipAddr(ip).Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
+	}
+
+	return p
+}
+
+// addrIP returns a as a net.IP, nil for the zero Addr
+func addrIP(a netip.Addr) net.IP {
+	if !a.IsValid() {
+		return nil
+	}
+
+	return net.IP(a.AsSlice())
+}
+
+// TargetAddr returns TargetAddress as a netip.Addr
+func (p ICMPNeighborSolicitation) TargetAddr() netip.Addr {
+	return ipAddr(p.TargetAddress)
+}
+
+// SetTargetAddr sets TargetAddress from a netip.Addr
+func (p *ICMPNeighborSolicitation) SetTargetAddr(a netip.Addr) {
+	p.TargetAddress = addrIP(a)
+}
+
+// TargetAddr returns TargetAddress as a netip.Addr
+func (p ICMPNeighborAdvertisement) TargetAddr() netip.Addr {
+	return ipAddr(p.TargetAddress)
+}
+
+// SetTargetAddr sets TargetAddress from a netip.Addr
+func (p *ICMPNeighborAdvertisement) SetTargetAddr(a netip.Addr) {
+	p.TargetAddress = addrIP(a)
+}
+
+// TargetAddr returns TargetAddress as a netip.Addr
+func (p ICMPRedirect) TargetAddr() netip.Addr {
+	return ipAddr(p.TargetAddress)
+}
+
+// SetTargetAddr sets TargetAddress from a netip.Addr
+func (p *ICMPRedirect) SetTargetAddr(a netip.Addr) {
+	p.TargetAddress = addrIP(a)
+}
+
+// DestinationAddr returns DestinationAddress as a netip.Addr
+func (p ICMPRedirect) DestinationAddr() netip.Addr {
+	return ipAddr(p.DestinationAddress)
+}
+
+// SetDestinationAddr sets DestinationAddress from a netip.Addr
+func (p *ICMPRedirect) SetDestinationAddr(a netip.Addr) {
+	p.DestinationAddress = addrIP(a)
+}
+
+// RegisteredAddr returns RegisteredAddress as a netip.Addr
+func (p ICMPDuplicateAddressRequest) RegisteredAddr() netip.Addr {
+	return ipAddr(p.RegisteredAddress)
+}
+
+// SetRegisteredAddr sets RegisteredAddress from a netip.Addr
+func (p *ICMPDuplicateAddressRequest) SetRegisteredAddr(a netip.Addr) {
+	p.RegisteredAddress = addrIP(a)
+}
+
+// RegisteredAddr returns RegisteredAddress as a netip.Addr
+func (p ICMPDuplicateAddressConfirmation) RegisteredAddr() netip.Addr {
+	return ipAddr(p.RegisteredAddress)
+}
+
+// SetRegisteredAddr sets RegisteredAddress from a netip.Addr
+func (p *ICMPDuplicateAddressConfirmation) SetRegisteredAddr(a netip.Addr) {
+	p.RegisteredAddress = addrIP(a)
+}
+
+// IPPrefix returns Prefix and PrefixLength as a netip.Prefix
+func (o ICMPOptionPrefixInformation) IPPrefix() netip.Prefix {
+	return ipPrefix(o.Prefix, int(o.PrefixLength))
+}
+
+// SetIPPrefix sets Prefix and PrefixLength from a netip.Prefix
+func (o *ICMPOptionPrefixInformation) SetIPPrefix(p netip.Prefix) {
+	o.Prefix, o.PrefixLength = addrIP(p.Masked().Addr()), uint8(p.Bits())
+}
+
+// IPPrefix returns Prefix and PrefixLength as a netip.Prefix
+func (o ICMPOptionRouteInformation) IPPrefix() netip.Prefix {
+	return ipPrefix(o.Prefix, int(o.PrefixLength))
+}
+
+// SetIPPrefix sets Prefix and PrefixLength from a netip.Prefix
+func (o *ICMPOptionRouteInformation) SetIPPrefix(p netip.Prefix) {
+	o.Prefix, o.PrefixLength = addrIP(p.Masked().Addr()), uint8(p.Bits())
+}
+
+// IPPrefix returns Prefix and PrefixLength as a netip.Prefix
+func (o ICMPOptionPREF64) IPPrefix() netip.Prefix {
+	return ipPrefix(o.Prefix, int(o.PrefixLength))
+}
+
+// SetIPPrefix sets Prefix and PrefixLength from a netip.Prefix
+func (o *ICMPOptionPREF64) SetIPPrefix(p netip.Prefix) {
+	o.Prefix, o.PrefixLength = addrIP(p.Masked().Addr()), uint8(p.Bits())
+}
+
+// IPPrefix returns Prefix and ContextLength as a netip.Prefix
+func (o ICMPOptionSixLoWPANContext) IPPrefix() netip.Prefix {
+	return ipPrefix(o.Prefix, int(o.ContextLength))
+}
+
+// SetIPPrefix sets Prefix and ContextLength from a netip.Prefix
+func (o *ICMPOptionSixLoWPANContext) SetIPPrefix(p netip.Prefix) {
+	o.Prefix, o.ContextLength = addrIP(p.Masked().Addr()), uint8(p.Bits())
+}
+
+// ServerAddrs returns Servers as netip.Addrs
+func (o ICMPOptionRecursiveDNSServer) ServerAddrs() []netip.Addr {
+	addrs := make([]netip.Addr, len(o.Servers))
+	for i, ip := range o.Servers {
+		addrs[i] = ipAddr(ip)
+	}
+
+	return addrs
+}
+
+// SetServerAddrs sets Servers from netip.Addrs
+func (o *ICMPOptionRecursiveDNSServer) SetServerAddrs(addrs []netip.Addr) {
+	o.Servers = make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		o.Servers[i] = addrIP(a)
+	}
+}
+
+// Addr returns Address as a netip.Addr
+func (o ICMPOptionAuthoritativeBorderRouter) Addr() netip.Addr {
+	return ipAddr(o.Address)
+}
+
+// SetAddr sets Address from a netip.Addr
+func (o *ICMPOptionAuthoritativeBorderRouter) SetAddr(a netip.Addr) {
+	o.Address = addrIP(a)
+}
+
+// SourceAddr returns Source as a netip.Addr
+func (md Metadata) SourceAddr() netip.Addr {
+	return ipAddr(md.Source)
+}
+
+// DestinationAddr returns Destination as a netip.Addr
+func (md Metadata) DestinationAddr() netip.Addr {
+	return ipAddr(md.Destination)
+}
+
+// Addr returns Address as a netip.Addr
+func (n Neighbor) Addr() netip.Addr {
+	return ipAddr(n.Address)
+}
+
+// LookupAddr returns the entry of a like Lookup does. Entries are keyed by
+// netip.Addr, so this doesn't allocate
+func (nc *NeighborCache) LookupAddr(a netip.Addr) (Neighbor, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	e, ok := nc.entries[a.Unmap()]
+	if !ok {
+		return Neighbor{}, false
+	}
+
+	return e.Neighbor, true
+}
+
+// ResolveAddr returns the link-layer address of a like Resolve does
+func (nc *NeighborCache) ResolveAddr(ctx context.Context, a netip.Addr) (net.HardwareAddr, error) {
+	return nc.Resolve(ctx, addrIP(a))
+}
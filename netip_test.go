@@ -0,0 +1,132 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestIPAddr(t *testing.T) {
+	tests := []struct {
+		in  net.IP
+		out netip.Addr
+	}{
+		{net.ParseIP("fe80::1"), netip.MustParseAddr("fe80::1")},
+		{net.ParseIP("192.0.2.1"), netip.MustParseAddr("192.0.2.1")},
+		{net.IPv4(192, 0, 2, 1).To4(), netip.MustParseAddr("192.0.2.1")},
+		{nil, netip.Addr{}},
+		{net.IP{1, 2, 3}, netip.Addr{}},
+	}
+	for _, test := range tests {
+		if a := ipAddr(test.in); a != test.out {
+			t.Errorf("expected %v for %v, not %v", test.out, test.in, a)
+		}
+	}
+
+	if addrIP(netip.Addr{}) != nil {
+		t.Error("expected nil for the zero Addr")
+	}
+	if ip := addrIP(netip.MustParseAddr("fe80::1")); !ip.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("unexpected %s", ip)
+	}
+}
+
+func TestNetipAccessors(t *testing.T) {
+	a := netip.MustParseAddr("fe80::2")
+	b := netip.MustParseAddr("2001:db8::1")
+
+	ns := &ICMPNeighborSolicitation{}
+	ns.SetTargetAddr(a)
+	na := &ICMPNeighborAdvertisement{}
+	na.SetTargetAddr(a)
+	r := &ICMPRedirect{}
+	r.SetTargetAddr(a)
+	r.SetDestinationAddr(b)
+	dar := &ICMPDuplicateAddressRequest{}
+	dar.SetRegisteredAddr(b)
+	dac := &ICMPDuplicateAddressConfirmation{}
+	dac.SetRegisteredAddr(b)
+	abro := &ICMPOptionAuthoritativeBorderRouter{}
+	abro.SetAddr(b)
+
+	for i, got := range []netip.Addr{
+		ns.TargetAddr(), na.TargetAddr(), r.TargetAddr(), r.DestinationAddr(),
+		dar.RegisteredAddr(), dac.RegisteredAddr(), abro.Addr(),
+	} {
+		expected := b
+		if i < 3 {
+			expected = a
+		}
+		if got != expected {
+			t.Errorf("%d: expected %s, not %s", i, expected, got)
+		}
+	}
+	if !ns.TargetAddress.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected target %s", ns.TargetAddress)
+	}
+
+	// prefixes are set masked
+	p := netip.MustParsePrefix("2001:db8:1::1/64")
+	pi := &ICMPOptionPrefixInformation{}
+	pi.SetIPPrefix(p)
+	ri := &ICMPOptionRouteInformation{}
+	ri.SetIPPrefix(p)
+	pref64 := &ICMPOptionPREF64{}
+	pref64.SetIPPrefix(netip.MustParsePrefix("64:ff9b::/96"))
+	ctx := &ICMPOptionSixLoWPANContext{}
+	ctx.SetIPPrefix(p)
+	for _, got := range []netip.Prefix{pi.IPPrefix(), ri.IPPrefix(), ctx.IPPrefix()} {
+		if got != p.Masked() {
+			t.Errorf("expected %s, not %s", p.Masked(), got)
+		}
+	}
+	if pi.PrefixLength != 64 || !pi.Prefix.Equal(net.ParseIP("2001:db8:1::")) {
+		t.Errorf("unexpected prefix %s/%d", pi.Prefix, pi.PrefixLength)
+	}
+	if got := pref64.IPPrefix(); got.String() != "64:ff9b::/96" {
+		t.Errorf("unexpected prefix %s", got)
+	}
+	if got := (ICMPOptionPrefixInformation{PrefixLength: 64}).IPPrefix(); got.IsValid() {
+		t.Errorf("expected the zero Prefix without an address, not %s", got)
+	}
+
+	rdnss := &ICMPOptionRecursiveDNSServer{}
+	rdnss.SetServerAddrs([]netip.Addr{a, b})
+	if got := rdnss.ServerAddrs(); len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("unexpected servers %v", got)
+	}
+
+	md := Metadata{Source: net.ParseIP("fe80::2"), Destination: net.ParseIP("2001:db8::1")}
+	if md.SourceAddr() != a || md.DestinationAddr() != b {
+		t.Errorf("unexpected metadata %s > %s", md.SourceAddr(), md.DestinationAddr())
+	}
+}
+
+func TestNeighborCacheAddr(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	nc := NewNeighborCache(a)
+	nc.Seed(Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: b.Interface().HardwareAddr})
+
+	n, ok := nc.LookupAddr(netip.MustParseAddr("fe80::2"))
+	if !ok || n.Addr() != netip.MustParseAddr("fe80::2") {
+		t.Fatalf("unexpected entry %+v", n)
+	}
+	if _, ok := nc.LookupAddr(netip.MustParseAddr("fe80::3")); ok {
+		t.Error("unexpected entry of fe80::3")
+	}
+
+	lla, err := nc.ResolveAddr(context.Background(), netip.MustParseAddr("fe80::2"))
+	if err != nil || lla.String() != b.Interface().HardwareAddr.String() {
+		t.Errorf("unexpected %s: %v", lla, err)
+	}
+
+	// lookups don't allocate
+	ip := netip.MustParseAddr("fe80::2")
+	if allocs := testing.AllocsPerRun(100, func() { nc.LookupAddr(ip) }); allocs != 0 {
+		t.Errorf("expected no allocations, not %v", allocs)
+	}
+}
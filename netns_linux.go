@@ -0,0 +1,76 @@
+//go:build linux
+
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListenNetNS works like Listen, but opens the Conn on the interface named
+// ifname inside the network namespace at path, such as /run/netns/foo or
+// /proc/<pid>/ns/net. The Conn keeps operating in that namespace while the
+// rest of the process stays where it is
+func ListenNetNS(path, ifname string, role Role) (*Conn, error) {
+	ns, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	var c *Conn
+	err = InNetNS(ns, func() error {
+		ifi, err := net.InterfaceByName(ifname)
+		if err != nil {
+			return err
+		}
+
+		c, err = Listen(ifi, role)
+		return err
+	})
+
+	return c, err
+}
+
+// InNetNS runs fn with its OS thread switched to network namespace ns, so
+// sockets and interfaces fn opens or looks up belong to that namespace.
+// Goroutines started by fn run in the namespace of the process instead
+func InNetNS(ns *os.File, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errc <- err
+			return
+		}
+		defer orig.Close()
+
+		if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errc <- os.NewSyscallError("setns", err)
+			return
+		}
+
+		ferr := fn()
+
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err != nil {
+			// a thread stuck in the wrong namespace must not be reused,
+			// which the runtime ensures by terminating threads that
+			// remain locked when their goroutine exits
+			errc <- os.NewSyscallError("setns", err)
+			return
+		}
+
+		runtime.UnlockOSThread()
+		errc <- ferr
+	}()
+
+	return <-errc
+}
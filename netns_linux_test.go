@@ -0,0 +1,78 @@
+//go:build linux
+
+package ndp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestInNetNS(t *testing.T) {
+	ns, err := os.Open("/proc/self/ns/net")
+	if err != nil {
+		t.Skipf("can't open network namespace: %s", err)
+	}
+	defer ns.Close()
+
+	// entering our own namespace still takes privileges
+	err = InNetNS(ns, func() error { return nil })
+	if err != nil {
+		t.Skipf("can't enter network namespace: %s", err)
+	}
+
+	var ifis []net.Interface
+	err = InNetNS(ns, func() error {
+		var err error
+		ifis, err = net.Interfaces()
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	lo, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ifis) != len(lo) {
+		t.Errorf("expected %d interfaces, not %d", len(lo), len(ifis))
+	}
+
+	// errors of fn are returned
+	ferr := errors.New("fn failed")
+	if err = InNetNS(ns, func() error { return ferr }); err != ferr {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestListenNetNS(t *testing.T) {
+	if _, err := ListenNetNS("/doesnotexist", "lo", RoleHost); !os.IsNotExist(err) {
+		t.Errorf("expected error for non-existing namespace, not %v", err)
+	}
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ifi := range ifis {
+		if _, err := linkLocalAddr(&ifi); err != nil {
+			continue
+		}
+
+		c, err := ListenNetNS("/proc/self/ns/net", ifi.Name, RoleHost)
+		if err != nil {
+			t.Skipf("can't listen in network namespace: %s", err)
+		}
+		defer c.Close()
+
+		if c.Interface().Name != ifi.Name {
+			t.Errorf("unexpected interface %s", c.Interface().Name)
+		}
+		return
+	}
+
+	t.Skip("no interface with link-local address")
+}
@@ -0,0 +1,277 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	ipv6HeaderLen     = 40
+	ethernetHeaderLen = 14
+	etherTypeIPv6     = 0x86dd
+	etherTypeVLAN     = 0x8100
+	protocolICMPv6    = 58
+	protocolFragment  = 44
+)
+
+var (
+	errPacketTooShort = errors.New("packet too short")
+	errNotICMPv6      = errors.New("packet does not carry ICMPv6")
+	errBadChecksum    = errors.New("invalid ICMPv6 checksum")
+)
+
+// ErrFragmented is returned for NDP messages that arrived in IPv6 fragments,
+// which nodes must drop as described at https://tools.ietf.org/html/rfc6980
+var ErrFragmented = errors.New("ndp message arrived in ipv6 fragments")
+
+// MarshalPacket returns the IPv6 packet carrying m from src to dst, with the
+// hop limit of 255 NDP requires and the ICMPv6 checksum filled in
+func MarshalPacket(m ICMP, src, dst net.IP) ([]byte, error) {
+	body, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalPacket(body, src, dst, 255)
+}
+
+// MarshalFrame returns the Ethernet frame carrying m from src to dst. When
+// dstHW is nil and dst is a multicast address, the frame is sent to the
+// Ethernet address that group maps to
+func MarshalFrame(m ICMP, srcHW, dstHW net.HardwareAddr, src, dst net.IP) ([]byte, error) {
+	body, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalFrame(body, srcHW, dstHW, src, dst, 255)
+}
+
+// ParsePacket returns ICMP and the Metadata of given IPv6 packet or error if
+// it couldn't parse it
+func ParsePacket(b []byte) (ICMP, *Metadata, error) {
+	body, md, err := parsePacket(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := ParseMessage(body)
+	if err != nil {
+		return nil, md, err
+	}
+
+	return m, md, nil
+}
+
+// ParseFrame returns ICMP and the Metadata of given Ethernet frame or error
+// if it couldn't parse it
+func ParseFrame(b []byte) (ICMP, *Metadata, error) {
+	body, md, err := parseFrame(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := ParseMessage(body)
+	if err != nil {
+		return nil, md, err
+	}
+
+	return m, md, nil
+}
+
+// marshalPacket prepends an IPv6 header to given ICMPv6 body and fills in its
+// checksum
+func marshalPacket(body []byte, src, dst net.IP, hopLimit int) ([]byte, error) {
+	s, d := src.To16(), dst.To16()
+	if s == nil || src.To4() != nil || d == nil || dst.To4() != nil {
+		return nil, fmt.Errorf("can't send from %s to %s over IPv6", src, dst)
+	}
+	if len(body) > 0xffff {
+		return nil, fmt.Errorf("message of %d bytes too large", len(body))
+	}
+	if len(body) < 4 {
+		return nil, errMessageTooShort
+	}
+
+	// don't touch the checksum of the caller's bytes
+	body = append([]byte(nil), body...)
+	body[2], body[3] = 0, 0
+	if err := Checksum(&body, s, d); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, ipv6HeaderLen, ipv6HeaderLen+len(body))
+	b[0] = 6 << 4
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(body)))
+	b[6] = protocolICMPv6
+	b[7] = uint8(hopLimit)
+	copy(b[8:24], s)
+	copy(b[24:40], d)
+
+	return append(b, body...), nil
+}
+
+// marshalFrame prepends an Ethernet and IPv6 header to given ICMPv6 body
+func marshalFrame(body []byte, srcHW, dstHW net.HardwareAddr, src, dst net.IP, hopLimit int) ([]byte, error) {
+	if dstHW == nil && dst.IsMulticast() {
+		dstHW = multicastHardwareAddr(dst)
+	}
+	if len(srcHW) != 6 || len(dstHW) != 6 {
+		return nil, fmt.Errorf("can't send from %s to %s over Ethernet", srcHW, dstHW)
+	}
+
+	p, err := marshalPacket(body, src, dst, hopLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, ethernetHeaderLen, ethernetHeaderLen+len(p))
+	copy(b[0:6], dstHW)
+	copy(b[6:12], srcHW)
+	binary.BigEndian.PutUint16(b[12:14], etherTypeIPv6)
+
+	return append(b, p...), nil
+}
+
+// parsePacket returns the ICMPv6 body and Metadata of given IPv6 packet,
+// skipping any extension headers in between. The first fragment of an
+// ICMPv6 message is returned as far as it goes, with Fragmented set in its
+// Metadata, while later fragments don't tell what they carry
+func parsePacket(b []byte) ([]byte, *Metadata, error) {
+	if len(b) < ipv6HeaderLen {
+		return nil, nil, errPacketTooShort
+	}
+	if b[0]>>4 != 6 {
+		return nil, nil, fmt.Errorf("unexpected IP version %d", b[0]>>4)
+	}
+
+	l := int(binary.BigEndian.Uint16(b[4:6]))
+	if len(b) < ipv6HeaderLen+l {
+		return nil, nil, errPacketTooShort
+	}
+
+	md := &Metadata{
+		Source:      net.IP(append([]byte(nil), b[8:24]...)),
+		Destination: net.IP(append([]byte(nil), b[24:40]...)),
+		HopLimit:    int(b[7]),
+	}
+
+	next, body := b[6], b[ipv6HeaderLen:(ipv6HeaderLen+l)]
+	for next != protocolICMPv6 {
+		switch next {
+		// hop-by-hop options, routing and destination options share
+		// their layout
+		case 0, 43, 60:
+			if len(body) < 8 {
+				return nil, nil, errPacketTooShort
+			}
+
+			n := (int(body[1]) + 1) * 8
+			if len(body) < n {
+				return nil, nil, errPacketTooShort
+			}
+
+			next, body = body[0], body[n:]
+		case protocolFragment:
+			if len(body) < 8 {
+				return nil, nil, errPacketTooShort
+			}
+			if binary.BigEndian.Uint16(body[2:4])&^0x7 != 0 {
+				return nil, nil, errNotICMPv6
+			}
+
+			md.Fragmented = true
+			next, body = body[0], body[8:]
+		default:
+			return nil, nil, errNotICMPv6
+		}
+	}
+
+	// the checksum covers all fragments
+	if !md.Fragmented && !validChecksum(body, md.Source, md.Destination) {
+		return nil, nil, errBadChecksum
+	}
+
+	return body, md, nil
+}
+
+// parseFrame returns the ICMPv6 body and Metadata of given Ethernet frame
+func parseFrame(b []byte) ([]byte, *Metadata, error) {
+	if len(b) < ethernetHeaderLen {
+		return nil, nil, errPacketTooShort
+	}
+
+	dstHW := net.HardwareAddr(append([]byte(nil), b[0:6]...))
+	srcHW := net.HardwareAddr(append([]byte(nil), b[6:12]...))
+	etherType, p := binary.BigEndian.Uint16(b[12:14]), b[ethernetHeaderLen:]
+	// a single 802.1Q tag may sit in between
+	if etherType == etherTypeVLAN {
+		if len(p) < 4 {
+			return nil, nil, errPacketTooShort
+		}
+
+		etherType, p = binary.BigEndian.Uint16(p[2:4]), p[4:]
+	}
+
+	if etherType != etherTypeIPv6 {
+		return nil, nil, errNotICMPv6
+	}
+
+	body, md, err := parsePacket(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	md.SourceLinkLayerAddress = srcHW
+	md.DestinationLinkLayerAddress = dstHW
+
+	return body, md, nil
+}
+
+// validChecksum checks the ICMPv6 checksum of given body, which sums up to
+// zero when it's correct
+func validChecksum(body []byte, src, dst net.IP) bool {
+	if len(body) < 4 {
+		return false
+	}
+
+	b := append([]byte(nil), body...)
+	if err := Checksum(&b, src, dst); err != nil {
+		return false
+	}
+
+	return b[2] == body[2] && b[3] == body[3]
+}
+
+// multicastHardwareAddr returns the Ethernet address given IPv6 multicast
+// group maps to as described at https://tools.ietf.org/html/rfc2464#section-7
+func multicastHardwareAddr(group net.IP) net.HardwareAddr {
+	mac := net.HardwareAddr{0x33, 0x33, 0, 0, 0, 0}
+	copy(mac[2:], group.To16()[12:])
+	return mac
+}
+
+// ListenFrames works like Listen, but returns a Conn that sends and receives
+// complete Ethernet frames on given interface rather than using the ICMPv6
+// socket of the kernel. It sees every NDP message for the multicast groups
+// it joined, including those the kernel handles itself, and lets Metadata
+// control link-layer and IPv6 source addresses of outgoing messages, which
+// ND proxies need. Unicast messages require the DestinationLinkLayerAddress
+// to be set in Metadata. On Linux it uses an AF_PACKET socket, on BSDs and
+// macOS a /dev/bpf device, which also helps where the kernel consumes NDP
+// messages before they reach raw ICMPv6 sockets
+func ListenFrames(ifi *net.Interface, role Role) (*Conn, error) {
+	addr, err := linkLocalAddr(ifi)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := listenFrames(ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(t, ifi, addr, role)
+}
@@ -0,0 +1,274 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package ndp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// bpfTransport implements transport on top of a /dev/bpf device
+type bpfTransport struct {
+	f    *os.File
+	rc   syscall.RawConn
+	ifi  *net.Interface
+	addr net.IP
+	// BPF devices can't join multicast groups, so we have an otherwise
+	// unused socket do it on our behalf
+	mc *ipv6.PacketConn
+
+	// a single read may return several frames
+	mu      sync.Mutex
+	buf     []byte
+	pending []byte
+}
+
+func listenFrames(ifi *net.Interface, addr net.IP) (*bpfTransport, error) {
+	if len(ifi.HardwareAddr) != 6 {
+		return nil, errors.New("interface has no Ethernet address")
+	}
+
+	fd, err := openBPF()
+	if err != nil {
+		return nil, err
+	}
+
+	prog, err := NDPFrameBPF()
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	insns := make([]syscall.BpfInsn, len(prog))
+	for i, ri := range prog {
+		insns[i] = syscall.BpfInsn{Code: ri.Op, Jt: ri.Jt, Jf: ri.Jf, K: ri.K}
+	}
+
+	// we really want these settings, so bail out when we don't get them
+	buflen, err := syscall.SetBpfBuflen(fd, 2*(ifi.MTU+ethernetHeaderLen+64))
+	if err == nil {
+		err = syscall.SetBpfInterface(fd, ifi.Name)
+	}
+	if err == nil {
+		err = syscall.SetBpfImmediate(fd, 1)
+	}
+	if err == nil {
+		// we provide our own source link-layer address
+		err = syscall.SetBpfHeadercmpl(fd, 1)
+	}
+	if err == nil {
+		err = syscall.SetBpf(fd, insns)
+	}
+	if err == nil {
+		err = unix.SetNonblock(fd, true)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("ioctl", err)
+	}
+
+	c, err := net.ListenPacket("udp6", "[::]:0")
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), "bpf:"+ifi.Name)
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		c.Close()
+		return nil, err
+	}
+
+	return &bpfTransport{
+		f:    f,
+		rc:   rc,
+		ifi:  ifi,
+		addr: addr,
+		mc:   ipv6.NewPacketConn(c),
+		buf:  make([]byte, buflen),
+	}, nil
+}
+
+// openBPF opens the first available BPF device
+func openBPF() (int, error) {
+	// cloning device on FreeBSD and recent macOS
+	fd, err := unix.Open("/dev/bpf", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err == nil {
+		return fd, nil
+	}
+
+	for i := 0; i < 256; i++ {
+		fd, err = unix.Open(fmt.Sprintf("/dev/bpf%d", i), unix.O_RDWR|unix.O_CLOEXEC, 0)
+		if err == unix.EBUSY {
+			continue
+		}
+		if err != nil {
+			return -1, os.NewSyscallError("open", err)
+		}
+
+		return fd, nil
+	}
+
+	return -1, errors.New("no BPF device available")
+}
+
+func (t *bpfTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		if len(t.pending) == 0 {
+			var (
+				n    int
+				rerr error
+			)
+			err := t.rc.Read(func(fd uintptr) bool {
+				n, rerr = unix.Read(int(fd), t.buf)
+				return rerr != unix.EAGAIN
+			})
+			if err != nil {
+				return 0, nil, err
+			}
+			if rerr != nil {
+				return 0, nil, os.NewSyscallError("read", rerr)
+			}
+
+			t.pending = t.buf[:n]
+		}
+
+		frame := t.nextFrame()
+		if frame == nil {
+			continue
+		}
+
+		// we see our own frames too
+		if bytes.Equal(frame[6:12], t.ifi.HardwareAddr) {
+			continue
+		}
+
+		body, md, err := parseFrame(frame)
+		if err != nil {
+			continue
+		}
+
+		md.IfIndex = t.ifi.Index
+		return copy(b, body), md, nil
+	}
+}
+
+// nextFrame takes the next captured frame from the pending buffer, which
+// prefixes each frame with a header and aligns them to BPF_ALIGNMENT
+func (t *bpfTransport) nextFrame() []byte {
+	hdrSize := int(unsafe.Sizeof(syscall.BpfHdr{}))
+	if len(t.pending) < hdrSize {
+		t.pending = nil
+		return nil
+	}
+
+	hdr := (*syscall.BpfHdr)(unsafe.Pointer(&t.pending[0]))
+	start, end := int(hdr.Hdrlen), int(hdr.Hdrlen)+int(hdr.Caplen)
+	if end > len(t.pending) {
+		t.pending = nil
+		return nil
+	}
+
+	frame := t.pending[start:end]
+	next := (end + unix.BPF_ALIGNMENT - 1) &^ (unix.BPF_ALIGNMENT - 1)
+	if next > len(t.pending) {
+		next = len(t.pending)
+	}
+	t.pending = t.pending[next:]
+
+	if len(frame) < ethernetHeaderLen {
+		return nil
+	}
+
+	return frame
+}
+
+func (t *bpfTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	src, hopLimit := t.addr, 255
+	srcHW, dstHW := t.ifi.HardwareAddr, net.HardwareAddr(nil)
+	if md != nil {
+		if md.Source != nil {
+			src = md.Source
+		}
+		if md.HopLimit != 0 {
+			hopLimit = md.HopLimit
+		}
+		if md.SourceLinkLayerAddress != nil {
+			srcHW = md.SourceLinkLayerAddress
+		}
+		dstHW = md.DestinationLinkLayerAddress
+	}
+
+	frame, err := marshalFrame(b, srcHW, dstHW, src, dst, hopLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	var werr error
+	err = t.rc.Write(func(fd uintptr) bool {
+		_, werr = unix.Write(int(fd), frame)
+		return werr != unix.EAGAIN
+	})
+	if err != nil {
+		return 0, err
+	}
+	if werr != nil {
+		return 0, os.NewSyscallError("write", werr)
+	}
+
+	return len(b), nil
+}
+
+func (t *bpfTransport) JoinGroup(group net.IP) error {
+	return t.mc.JoinGroup(t.ifi, &net.UDPAddr{IP: group})
+}
+
+func (t *bpfTransport) LeaveGroup(group net.IP) error {
+	return t.mc.LeaveGroup(t.ifi, &net.UDPAddr{IP: group})
+}
+
+// SetPromiscuous can only enable promiscuous mode, which BPF devices keep
+// until they are closed
+func (t *bpfTransport) SetPromiscuous(enabled bool) error {
+	if !enabled {
+		return errors.New("promiscuous mode ends when the Conn is closed")
+	}
+
+	var serr error
+	err := t.rc.Control(func(fd uintptr) {
+		serr = syscall.SetBpfPromisc(int(fd), 1)
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.NewSyscallError("ioctl", serr)
+}
+
+func (t *bpfTransport) SetReadDeadline(d time.Time) error {
+	return t.f.SetReadDeadline(d)
+}
+
+func (t *bpfTransport) SetWriteDeadline(d time.Time) error {
+	return t.f.SetWriteDeadline(d)
+}
+
+func (t *bpfTransport) Close() error {
+	t.mc.Close()
+	return t.f.Close()
+}
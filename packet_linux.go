@@ -0,0 +1,207 @@
+//go:build linux
+
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// frameTransport implements transport on top of an AF_PACKET socket
+type frameTransport struct {
+	f    *os.File
+	rc   syscall.RawConn
+	ifi  *net.Interface
+	addr net.IP
+}
+
+func listenFrames(ifi *net.Interface, addr net.IP) (*frameTransport, error) {
+	if len(ifi.HardwareAddr) != 6 {
+		return nil, errors.New("interface has no Ethernet address")
+	}
+
+	proto := htons(unix.ETH_P_IPV6)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	sa := &unix.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  ifi.Index,
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	// have the kernel drop everything but NDP, which matters most in
+	// promiscuous mode
+	prog, err := NDPFrameBPF()
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	filter := make([]unix.SockFilter, len(prog))
+	for i, ri := range prog {
+		filter[i] = unix.SockFilter{Code: ri.Op, Jt: ri.Jt, Jf: ri.Jf, K: ri.K}
+	}
+	fprog := &unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, fprog); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+
+	// the os package takes care of deadlines for non-blocking descriptors
+	f := os.NewFile(uintptr(fd), "packet:"+ifi.Name)
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &frameTransport{
+		f:    f,
+		rc:   rc,
+		ifi:  ifi,
+		addr: addr,
+	}, nil
+}
+
+func (t *frameTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	frame := make([]byte, len(b)+ethernetHeaderLen+ipv6HeaderLen+4)
+	for {
+		var (
+			n    int
+			from unix.Sockaddr
+			rerr error
+		)
+		err := t.rc.Read(func(fd uintptr) bool {
+			n, from, rerr = unix.Recvfrom(int(fd), frame, 0)
+			return rerr != unix.EAGAIN
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		if rerr != nil {
+			return 0, nil, os.NewSyscallError("recvfrom", rerr)
+		}
+
+		// we see our own frames too
+		if sll, ok := from.(*unix.SockaddrLinklayer); ok && sll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		body, md, err := parseFrame(frame[:n])
+		if err != nil {
+			// just like the kernel would, drop whatever isn't
+			// valid ICMPv6
+			continue
+		}
+
+		md.IfIndex = t.ifi.Index
+		return copy(b, body), md, nil
+	}
+}
+
+func (t *frameTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	src, hopLimit := t.addr, 255
+	srcHW, dstHW := t.ifi.HardwareAddr, net.HardwareAddr(nil)
+	if md != nil {
+		if md.Source != nil {
+			src = md.Source
+		}
+		if md.HopLimit != 0 {
+			hopLimit = md.HopLimit
+		}
+		if md.SourceLinkLayerAddress != nil {
+			srcHW = md.SourceLinkLayerAddress
+		}
+		dstHW = md.DestinationLinkLayerAddress
+	}
+
+	frame, err := marshalFrame(b, srcHW, dstHW, src, dst, hopLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	var werr error
+	err = t.rc.Write(func(fd uintptr) bool {
+		_, werr = unix.Write(int(fd), frame)
+		return werr != unix.EAGAIN
+	})
+	if err != nil {
+		return 0, err
+	}
+	if werr != nil {
+		return 0, os.NewSyscallError("write", werr)
+	}
+
+	return len(b), nil
+}
+
+// JoinGroup has the interface accept frames for the Ethernet address given
+// group maps to
+func (t *frameTransport) JoinGroup(group net.IP) error {
+	return t.membership(unix.PACKET_ADD_MEMBERSHIP, unix.PACKET_MR_MULTICAST, multicastHardwareAddr(group))
+}
+
+func (t *frameTransport) LeaveGroup(group net.IP) error {
+	return t.membership(unix.PACKET_DROP_MEMBERSHIP, unix.PACKET_MR_MULTICAST, multicastHardwareAddr(group))
+}
+
+// SetPromiscuous uses a membership as well, which the kernel drops when the
+// socket is closed, so the interface doesn't stay promiscuous
+func (t *frameTransport) SetPromiscuous(enabled bool) error {
+	opt := unix.PACKET_DROP_MEMBERSHIP
+	if enabled {
+		opt = unix.PACKET_ADD_MEMBERSHIP
+	}
+
+	return t.membership(opt, unix.PACKET_MR_PROMISC, nil)
+}
+
+func (t *frameTransport) membership(opt, typ int, addr net.HardwareAddr) error {
+	mreq := &unix.PacketMreq{
+		Ifindex: int32(t.ifi.Index),
+		Type:    uint16(typ),
+		Alen:    uint16(len(addr)),
+	}
+	copy(mreq.Address[:], addr)
+
+	var serr error
+	err := t.rc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptPacketMreq(int(fd), unix.SOL_PACKET, opt, mreq)
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.NewSyscallError("setsockopt", serr)
+}
+
+func (t *frameTransport) SetReadDeadline(d time.Time) error {
+	return t.f.SetReadDeadline(d)
+}
+
+func (t *frameTransport) SetWriteDeadline(d time.Time) error {
+	return t.f.SetWriteDeadline(d)
+}
+
+func (t *frameTransport) Close() error {
+	return t.f.Close()
+}
+
+// htons converts given short to network byte order
+func htons(i uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, i)
+	return binary.NativeEndian.Uint16(b)
+}
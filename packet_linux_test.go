@@ -0,0 +1,50 @@
+//go:build linux
+
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenFrames(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range ifis {
+		c, err := ListenFrames(&ifis[i], RoleHost)
+		if err != nil {
+			continue
+		}
+		defer c.Close()
+
+		if err = c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+			t.Error(err)
+		}
+
+		// unicast destinations need a link-layer address
+		if err = c.WriteTo(&ICMPRouterSolicitation{}, nil, net.ParseIP("fe80::2")); err == nil {
+			t.Errorf("expected error for missing destination link-layer address")
+		}
+
+		if err = c.SetPromiscuous(true); err != nil {
+			t.Error(err)
+		}
+		if err = c.SetPromiscuous(false); err != nil {
+			t.Error(err)
+		}
+
+		if err = c.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+			t.Error(err)
+		}
+		if _, _, err = c.ReadFrom(); err == nil {
+			t.Logf("unexpectedly read a message")
+		}
+		return
+	}
+
+	t.Skip("can't listen for frames on any interface")
+}
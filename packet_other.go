@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package ndp
+
+import (
+	"errors"
+	"net"
+)
+
+func listenFrames(ifi *net.Interface, addr net.IP) (transport, error) {
+	return nil, errors.New("listening for frames is not supported on this platform")
+}
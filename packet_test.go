@@ -0,0 +1,141 @@
+package ndp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.org/x/net/ipv6"
+)
+
+// testFragment returns IPv6 packet p as fragment at offset, which carries
+// the same payload
+func testFragment(p []byte, offset uint16) []byte {
+	b := append([]byte(nil), p[:ipv6HeaderLen]...)
+	binary.BigEndian.PutUint16(b[4:6], binary.BigEndian.Uint16(p[4:6])+8)
+	b[6] = protocolFragment
+	b = append(b, p[6], 0, byte(offset>>8), byte(offset)|1, 0, 0, 0, 1)
+
+	return append(b, p[ipv6HeaderLen:]...)
+}
+
+func TestMarshalParsePacket(t *testing.T) {
+	src, dst := net.ParseIP("fe80::1"), net.ParseIP("ff02::2")
+	rs := &ICMPRouterSolicitation{}
+	rs.AddOption(&ICMPOptionSourceLinkLayerAddress{
+		LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01},
+	})
+
+	fixture := []byte{
+		// IPv6 header
+		0x60, 0, 0, 0, 0, 16, 58, 255,
+		0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+		// router solicitation
+		133, 0, 0x7a, 0x2c, 0, 0, 0, 0,
+		1, 1, 0x02, 0, 0, 0, 0, 0x01,
+	}
+
+	b, err := MarshalPacket(rs, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(b, fixture) != 0 {
+		t.Errorf("fixture of %v did not match %v", fixture, b)
+	}
+
+	m, md, err := ParsePacket(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*ICMPRouterSolicitation); !ok {
+		t.Errorf("unexpected message %s", m)
+	}
+	if !md.Source.Equal(src) || !md.Destination.Equal(dst) || md.HopLimit != 255 {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	// the checksum is verified
+	fixture[len(fixture)-1] = 0x02
+	if _, _, err = ParsePacket(fixture); err != errBadChecksum {
+		t.Errorf("expected checksum error, not %v", err)
+	}
+
+	// extension headers are skipped
+	ext := append([]byte(nil), b[:ipv6HeaderLen]...)
+	ext[5], ext[6] = 24, 0
+	ext = append(ext, 58, 0, 1, 4, 0, 0, 0, 0)
+	ext = append(ext, b[ipv6HeaderLen:]...)
+	if _, _, err = ParsePacket(ext); err != nil {
+		t.Errorf("failed to skip hop-by-hop options: %s", err)
+	}
+
+	// first fragments are passed on as far as they go, later ones aren't
+	frag := testFragment(b, 0)
+	if m, md, err := ParsePacket(frag); err != nil || !md.Fragmented || m.Type() != ipv6.ICMPTypeRouterSolicitation {
+		t.Errorf("unexpected fragment %v, %+v, %v", m, md, err)
+	}
+	if _, md, err := ParsePacket(testFragment(b, 8)); err != errNotICMPv6 || md != nil {
+		t.Errorf("expected error for later fragment, not %v", err)
+	}
+
+	// other protocols are rejected
+	b[6] = 17
+	if _, _, err = ParsePacket(b); err != errNotICMPv6 {
+		t.Errorf("expected error for UDP, not %v", err)
+	}
+
+	if _, _, err = ParsePacket(b[:39]); err != errPacketTooShort {
+		t.Errorf("expected error for truncated packet, not %v", err)
+	}
+
+	if _, err = MarshalPacket(rs, net.ParseIP("192.0.2.1"), dst); err == nil {
+		t.Errorf("expected error for IPv4 source")
+	}
+}
+
+func TestMarshalParseFrame(t *testing.T) {
+	srcHW := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	src, dst := net.ParseIP("fe80::1"), net.ParseIP("ff02::2")
+
+	b, err := MarshalFrame(&ICMPRouterSolicitation{}, srcHW, nil, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []byte{0x33, 0x33, 0, 0, 0, 2, 0x02, 0, 0, 0, 0, 0x01, 0x86, 0xdd}
+	if bytes.Compare(b[:ethernetHeaderLen], header) != 0 {
+		t.Errorf("fixture of %v did not match %v", header, b[:ethernetHeaderLen])
+	}
+
+	m, md, err := ParseFrame(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*ICMPRouterSolicitation); !ok {
+		t.Errorf("unexpected message %s", m)
+	}
+	if md.SourceLinkLayerAddress.String() != srcHW.String() || md.DestinationLinkLayerAddress.String() != "33:33:00:00:00:02" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	// tagged frames
+	tagged := append([]byte(nil), b[:12]...)
+	tagged = append(tagged, 0x81, 0, 0, 10)
+	tagged = append(tagged, b[12:]...)
+	if _, _, err = ParseFrame(tagged); err != nil {
+		t.Errorf("failed to parse tagged frame: %s", err)
+	}
+
+	// unicast destinations need a link-layer address
+	if _, err = MarshalFrame(&ICMPRouterSolicitation{}, srcHW, nil, src, net.ParseIP("fe80::2")); err == nil {
+		t.Errorf("expected error for missing destination link-layer address")
+	}
+
+	// ARP
+	b[12], b[13] = 0x08, 0x06
+	if _, _, err = ParseFrame(b); err != errNotICMPv6 {
+		t.Errorf("expected error for ARP, not %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package ndp
+
+import "fmt"
+
+// ParseLimits caps what parsing a single message may take, so untrusted
+// input can't make its reader allocate more than it is prepared to. Zero
+// values leave their limit off
+type ParseLimits struct {
+	// MaxOptions caps the options of a message, including nested ones
+	MaxOptions int
+	// MaxOptionBytes caps the bytes all options of a message take
+	MaxOptionBytes int
+	// MaxDNSServers caps the servers of all recursive DNS server options
+	// of a message
+	MaxDNSServers int
+	// MaxDNSSearchLabels caps the labels of all DNS search list options of
+	// a message
+	MaxDNSSearchLabels int
+}
+
+// DefaultParseLimits are the ParseLimits Conn reads messages with unless
+// SetParseLimits changes them. They leave plenty of room for legitimate
+// messages
+var DefaultParseLimits = ParseLimits{
+	MaxOptions:         256,
+	MaxOptionBytes:     16384,
+	MaxDNSServers:      64,
+	MaxDNSSearchLabels: 1024,
+}
+
+// ParseMessage works like the package level ParseMessage, but fails with a
+// ParseLimitError once the message exceeds one of these limits
+func (l ParseLimits) ParseMessage(b []byte) (ICMP, error) {
+	return parseMessage(b, &parseBudget{limits: l})
+}
+
+// ParseLimitError is returned when parsing a message exceeds ParseLimits
+type ParseLimitError struct {
+	// Limit names the field of ParseLimits that was exceeded
+	Limit string
+	Max   int
+}
+
+func (e *ParseLimitError) Error() string {
+	return fmt.Sprintf("message exceeds %s of %d", e.Limit, e.Max)
+}
+
+// parseBudget keeps track of what parsing a message took so far. Its methods
+// may be called on nil, which imposes no limits
+type parseBudget struct {
+	limits                   ParseLimits
+	options, servers, labels int
+}
+
+// exceeds returns a ParseLimitError for limit max when n exceeds it
+func exceeds(limit string, n, max int) error {
+	if max > 0 && n > max {
+		return &ParseLimitError{Limit: limit, Max: max}
+	}
+
+	return nil
+}
+
+// optionBytes checks the options of a message taking n bytes
+func (pb *parseBudget) optionBytes(n int) error {
+	if pb == nil {
+		return nil
+	}
+
+	return exceeds("MaxOptionBytes", n, pb.limits.MaxOptionBytes)
+}
+
+// option counts another option
+func (pb *parseBudget) option() error {
+	if pb == nil {
+		return nil
+	}
+
+	pb.options++
+	return exceeds("MaxOptions", pb.options, pb.limits.MaxOptions)
+}
+
+// dnsServers counts n more DNS servers
+func (pb *parseBudget) dnsServers(n int) error {
+	if pb == nil {
+		return nil
+	}
+
+	pb.servers += n
+	return exceeds("MaxDNSServers", pb.servers, pb.limits.MaxDNSServers)
+}
+
+// dnsLabels counts the labels of the encoded domain names in b, before they
+// are decoded
+func (pb *parseBudget) dnsLabels(b []byte) error {
+	if pb == nil {
+		return nil
+	}
+
+	for i := 0; i < len(b); {
+		// the end of a name, or padding
+		if b[i] == 0 {
+			i++
+			continue
+		}
+
+		pb.labels++
+		if err := exceeds("MaxDNSSearchLabels", pb.labels, pb.limits.MaxDNSSearchLabels); err != nil {
+			return err
+		}
+		i += int(b[i]) + 1
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	server := net.ParseIP("2001:db8::53")
+	ra := &ICMPRouterAdvertisement{}
+	ra.AddOption(&ICMPOptionRecursiveDNSServer{Lifetime: 600, Servers: []net.IP{server, server, server}})
+	ra.AddOption(&ICMPOptionDNSSearchList{Lifetime: 600, DomainNames: []string{"a.example.com", "b.example.com"}})
+	ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	pvd := &ICMPOptionPvD{FQDN: "pvd.example.com"}
+	pvd.AddOption(&ICMPOptionMTU{MTU: 1280})
+	ra.AddOption(pvd)
+	b, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no limits at all, or enough of each
+	for _, l := range []ParseLimits{{}, DefaultParseLimits, {MaxOptions: 5, MaxOptionBytes: len(b) - 16, MaxDNSServers: 3, MaxDNSSearchLabels: 6}} {
+		if _, err := l.ParseMessage(b); err != nil {
+			t.Errorf("unexpected error with %+v: %s", l, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		limits ParseLimits
+		limit  string
+	}{
+		// nested options count as well
+		{ParseLimits{MaxOptions: 4}, "MaxOptions"},
+		{ParseLimits{MaxOptionBytes: len(b) - 17}, "MaxOptionBytes"},
+		{ParseLimits{MaxDNSServers: 2}, "MaxDNSServers"},
+		{ParseLimits{MaxDNSSearchLabels: 5}, "MaxDNSSearchLabels"},
+	} {
+		_, err := tc.limits.ParseMessage(b)
+		var le *ParseLimitError
+		if !errors.As(err, &le) || le.Limit != tc.limit {
+			t.Errorf("expected %s to be exceeded, not %v", tc.limit, err)
+		}
+	}
+
+	if s := (&ParseLimitError{Limit: "MaxOptions", Max: 4}).Error(); s != "message exceeds MaxOptions of 4" {
+		t.Errorf("unexpected error string %q", s)
+	}
+}
+
+func TestConnParseLimits(t *testing.T) {
+	ra := &ICMPRouterAdvertisement{}
+	for i := 0; i < 3; i++ {
+		ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	}
+	b, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := &testTransport{in: [][]byte{b, b}, md: &Metadata{}}
+	c := &Conn{t: tt}
+	if _, _, err := c.ReadFrom(); err != nil {
+		t.Fatal(err)
+	}
+	c.SetParseLimits(ParseLimits{MaxOptions: 2})
+	if _, md, err := c.ReadFrom(); md == nil || err == nil {
+		t.Errorf("expected options to be limited, not %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pipe returns two Conns connected to each other in memory, as if they were
+// the only nodes on a link. Whatever one end writes, the other end reads,
+// with Metadata filled in like a real transport would. The ends use
+// link-local addresses fe80::1 and fe80::2 on interfaces pipe0 and pipe1 and
+// aren't rate limited, so NDP logic can be tested without privileges or
+// real interfaces
+func Pipe() (*Conn, *Conn) {
+	a2b := make(chan pipePacket, 64)
+	b2a := make(chan pipePacket, 64)
+
+	a := newPipeTransport(1, b2a, a2b)
+	b := newPipeTransport(2, a2b, b2a)
+	a.peer, b.peer = b, a
+
+	return &Conn{t: a, ifi: a.ifi, addr: a.addr}, &Conn{t: b, ifi: b.ifi, addr: b.addr}
+}
+
+// pipePacket is a message in transit between both ends of a pipe
+type pipePacket struct {
+	b  []byte
+	md *Metadata
+}
+
+// pipeTransport implements transport as one end of Pipe
+type pipeTransport struct {
+	rx   <-chan pipePacket
+	tx   chan<- pipePacket
+	ifi  *net.Interface
+	addr net.IP
+	peer *pipeTransport
+
+	rd, wd    pipeDeadline
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeTransport(n int, rx <-chan pipePacket, tx chan<- pipePacket) *pipeTransport {
+	return &pipeTransport{
+		rx: rx,
+		tx: tx,
+		ifi: &net.Interface{
+			Index:        n,
+			MTU:          1500,
+			Name:         fmt.Sprintf("pipe%d", n-1),
+			HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, byte(n)},
+			Flags:        net.FlagUp | net.FlagMulticast,
+		},
+		addr:   net.IP{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(n)},
+		rd:     newPipeDeadline(),
+		wd:     newPipeDeadline(),
+		closed: make(chan struct{}),
+	}
+}
+
+func (t *pipeTransport) ReadFrom(b []byte) (int, *Metadata, error) {
+	// a closed pipe or expired deadline wins over pending packets
+	select {
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	case <-t.rd.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	default:
+	}
+
+	select {
+	case p := <-t.rx:
+		return copy(b, p.b), p.md, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	case <-t.rd.wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (t *pipeTransport) WriteTo(b []byte, md *Metadata, dst net.IP) (int, error) {
+	select {
+	case <-t.closed:
+		return 0, net.ErrClosed
+	case <-t.wd.wait():
+		return 0, os.ErrDeadlineExceeded
+	default:
+	}
+
+	rmd := &Metadata{
+		Source:                      t.addr,
+		Destination:                 dst,
+		IfIndex:                     t.peer.ifi.Index,
+		HopLimit:                    255,
+		SourceLinkLayerAddress:      t.ifi.HardwareAddr,
+		DestinationLinkLayerAddress: t.peer.ifi.HardwareAddr,
+	}
+	if dst.IsMulticast() {
+		rmd.DestinationLinkLayerAddress = multicastHardwareAddr(dst)
+	}
+	if md != nil {
+		if md.Source != nil {
+			rmd.Source = md.Source
+		}
+		if md.HopLimit != 0 {
+			rmd.HopLimit = md.HopLimit
+		}
+	}
+
+	p := pipePacket{
+		b:  append([]byte(nil), b...),
+		md: rmd,
+	}
+
+	select {
+	case t.tx <- p:
+		return len(b), nil
+	case <-t.peer.closed:
+		// like on a real link, nobody notices
+		return len(b), nil
+	case <-t.closed:
+		return 0, net.ErrClosed
+	case <-t.wd.wait():
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (t *pipeTransport) SetReadDeadline(d time.Time) error {
+	t.rd.set(d)
+	return nil
+}
+
+func (t *pipeTransport) SetWriteDeadline(d time.Time) error {
+	t.wd.set(d)
+	return nil
+}
+
+func (t *pipeTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+
+	return nil
+}
+
+// pipeDeadline provides a channel that is closed once a deadline expires
+type pipeDeadline struct {
+	mu      *sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func newPipeDeadline() pipeDeadline {
+	return pipeDeadline{
+		mu:      &sync.Mutex{},
+		expired: make(chan struct{}),
+	}
+}
+
+// set replaces the deadline, with a zero time meaning no deadline at all
+func (d *pipeDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// the timer fired or is firing, so expired was closed
+		<-d.expired
+	}
+	d.timer = nil
+
+	select {
+	case <-d.expired:
+		d.expired = make(chan struct{})
+	default:
+	}
+
+	if t.IsZero() {
+		return
+	}
+
+	dur := time.Until(t)
+	if dur <= 0 {
+		close(d.expired)
+		return
+	}
+
+	expired := d.expired
+	d.timer = time.AfterFunc(dur, func() {
+		close(expired)
+	})
+}
+
+// wait returns a channel that is closed when the deadline expires
+func (d *pipeDeadline) wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
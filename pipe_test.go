@@ -0,0 +1,125 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	if !a.Addr().Equal(net.ParseIP("fe80::1")) || !b.Addr().Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected addresses %s and %s", a.Addr(), b.Addr())
+	}
+
+	if err := a.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, md, err := b.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, ok := m.(*ICMPRouterSolicitation)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+	if !rs.HasOption(ICMPOptionTypeSourceLinkLayerAddress) {
+		t.Errorf("expected source link-layer address option")
+	}
+
+	if !md.Source.Equal(a.Addr()) || !md.Destination.Equal(net.IPv6linklocalallrouters) || md.HopLimit != 255 || md.IfIndex != b.Interface().Index {
+		t.Errorf("unexpected metadata %v", md)
+	}
+	if md.DestinationLinkLayerAddress.String() != "33:33:00:00:00:02" || md.SourceLinkLayerAddress.String() != a.Interface().HardwareAddr.String() {
+		t.Errorf("unexpected link-layer addresses in %v", md)
+	}
+
+	// the other way around, with metadata
+	err = b.WriteTo(&ICMPNeighborAdvertisement{TargetAddress: b.Addr()}, &Metadata{HopLimit: 64}, a.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, md, err = a.ReadFrom(); err != nil {
+		t.Fatal(err)
+	}
+	if md.HopLimit != 64 || !md.Destination.Equal(a.Addr()) {
+		t.Errorf("unexpected metadata %v", md)
+	}
+
+	// works with the rest of the package
+	mux := NewMux()
+	got := make(chan *ICMPNeighborSolicitation, 1)
+	mux.HandleNeighborSolicitation(func(ns *ICMPNeighborSolicitation, md *Metadata) {
+		got <- ns
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Serve(ctx, mux)
+
+	if err = a.SendNS(b.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ns := <-got:
+		if !ns.TargetAddress.Equal(b.Addr()) {
+			t.Errorf("unexpected target %s", ns.TargetAddress)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("neighbor solicitation was not served")
+	}
+}
+
+func TestPipeDeadline(t *testing.T) {
+	a, b := Pipe()
+	defer b.Close()
+
+	if err := a.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := a.ReadFrom()
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, not %v", err)
+	}
+
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Errorf("expected timeout error, not %v", err)
+	}
+
+	// clearing the deadline makes reads block again
+	a.SetReadDeadline(time.Time{})
+	if err = b.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = a.ReadFrom(); err != nil {
+		t.Error(err)
+	}
+
+	// deadlines in the past expire right away
+	a.SetWriteDeadline(time.Now().Add(-time.Second))
+	if err = a.SendRS(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, not %v", err)
+	}
+
+	a.Close()
+	if _, _, err = a.ReadFrom(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected closed error, not %v", err)
+	}
+
+	// writing to a closed peer goes unnoticed
+	if err = b.SendRS(); err != nil {
+		t.Error(err)
+	}
+}
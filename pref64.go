@@ -0,0 +1,192 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	errNoNAT64Prefix = errors.New("no nat64 prefix discovered")
+)
+
+// the defaults of DNS64Discovery
+const (
+	defaultNAT64Interval = 10 * time.Minute
+)
+
+// ipv4onlyArpa is the name whose A records only exist as 192.0.0.170 and
+// 192.0.0.171, so DNS64 synthesized AAAA records for it reveal the NAT64
+// prefix as described at https://tools.ietf.org/html/rfc7050#section-3
+const ipv4onlyArpa = "ipv4only.arpa"
+
+// ipv4onlyAddrs holds the well-known addresses of ipv4only.arpa
+var ipv4onlyAddrs = []net.IP{
+	net.IPv4(192, 0, 0, 170).To4(),
+	net.IPv4(192, 0, 0, 171).To4(),
+}
+
+// PREF64Source provides the NAT64 prefix for RAServer to advertise that may
+// change while it runs, see WatchPREF64
+type PREF64Source interface {
+	// Watch calls update with the current prefix, which is nil if there is
+	// none, and again whenever it changes until ctx is done or update fails
+	Watch(ctx context.Context, update func(*net.IPNet) error) error
+}
+
+// StaticPREF64 is a PREF64Source of a prefix that never changes
+type StaticPREF64 struct {
+	Prefix *net.IPNet
+}
+
+// Watch calls update once and waits for ctx to be done
+func (p StaticPREF64) Watch(ctx context.Context, update func(*net.IPNet) error) error {
+	if err := update(p.Prefix); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// DNS64Discovery is a PREF64Source of the NAT64 prefix of the local DNS64
+// resolver, which it learns by resolving ipv4only.arpa as described at
+// https://tools.ietf.org/html/rfc7050. Routers of networks with a NAT64
+// gateway can so have hosts learn its prefix without configuring it twice
+type DNS64Discovery struct {
+	// Resolver is used for the lookups, net.DefaultResolver if nil
+	Resolver *net.Resolver
+	// Interval is how often the prefix is discovered again, 10m if 0
+	Interval time.Duration
+
+	// overridden by tests
+	lookup func(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// Watch discovers the prefix every Interval and calls update when it
+// changed. Lookups that fail for reasons other than there being no DNS64
+// keep the prefix found last, as the resolver may be unreachable for a while
+func (d DNS64Discovery) Watch(ctx context.Context, update func(*net.IPNet) error) error {
+	interval := d.Interval
+	if interval == 0 {
+		interval = defaultNAT64Interval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		current *net.IPNet
+		first   = true
+	)
+	for {
+		prefix, err := d.discover(ctx)
+		var derr *net.DNSError
+		switch {
+		case err == nil:
+		case err == errNoNAT64Prefix, errors.As(err, &derr) && derr.IsNotFound:
+			prefix = nil
+		default:
+			prefix = current
+		}
+		if first || prefix.String() != current.String() {
+			if err := update(prefix); err != nil {
+				return err
+			}
+			current, first = prefix, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DiscoverNAT64Prefix returns the NAT64 prefix of the DNS64 resolver r uses,
+// or that of net.DefaultResolver if r is nil, as described at
+// https://tools.ietf.org/html/rfc7050#section-3
+func DiscoverNAT64Prefix(ctx context.Context, r *net.Resolver) (*net.IPNet, error) {
+	return DNS64Discovery{Resolver: r}.discover(ctx)
+}
+
+// discover resolves ipv4only.arpa and returns the NAT64 prefix of the first
+// synthesized address
+func (d DNS64Discovery) discover(ctx context.Context) (*net.IPNet, error) {
+	lookup := d.lookup
+	if lookup == nil {
+		r := d.Resolver
+		if r == nil {
+			r = net.DefaultResolver
+		}
+		lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+			return r.LookupIP(ctx, "ip6", host)
+		}
+	}
+
+	ips, err := lookup(ctx, ipv4onlyArpa)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if prefix := nat64Prefix(ip); prefix != nil {
+			return prefix, nil
+		}
+	}
+
+	return nil, errNoNAT64Prefix
+}
+
+// nat64Prefix returns the prefix ip was synthesized with from one of the
+// well-known addresses of ipv4only.arpa, trying the prefix lengths of
+// https://tools.ietf.org/html/rfc6052#section-2.2 from longest to shortest
+func nat64Prefix(ip net.IP) *net.IPNet {
+	if ip.To4() != nil || ip.To16() == nil {
+		return nil
+	}
+	ip = ip.To16()
+
+	for _, length := range pref64Lengths {
+		v4 := embeddedIPv4(ip, int(length))
+		for _, wka := range ipv4onlyAddrs {
+			if v4.Equal(wka) {
+				mask := net.CIDRMask(int(length), 128)
+				return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+			}
+		}
+	}
+
+	return nil
+}
+
+// embeddedIPv4 returns the IPv4 address embedded in ip after a prefix of
+// length bits, skipping the reserved bits 64 to 71
+func embeddedIPv4(ip net.IP, length int) net.IP {
+	v4 := make(net.IP, 0, net.IPv4len)
+	for i := length / 8; len(v4) < net.IPv4len && i < net.IPv6len; i++ {
+		if i != 8 {
+			v4 = append(v4, ip[i])
+		}
+	}
+
+	return v4
+}
+
+// validPREF64 returns an error if prefix can't be advertised in a PREF64
+// option
+func validPREF64(prefix *net.IPNet) error {
+	if prefix.IP.To4() != nil || len(prefix.Mask) != net.IPv6len {
+		return fmt.Errorf("pref64 %s is not an IPv6 prefix", prefix)
+	}
+	ones, _ := prefix.Mask.Size()
+	for _, l := range pref64Lengths {
+		if int(l) == ones {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("pref64 %s has a length other than 96, 64, 56, 48, 40 or 32", prefix)
+}
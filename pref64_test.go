@@ -0,0 +1,129 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNAT64Prefix(t *testing.T) {
+	tests := []struct {
+		addr   string
+		prefix string
+	}{
+		{"64:ff9b::c000:aa", "64:ff9b::/96"},
+		{"64:ff9b::192.0.0.171", "64:ff9b::/96"},
+		{"2001:db8:c000:aa::", "2001:db8::/32"},
+		{"2001:db8:1c0:0:aa::", "2001:db8:100::/40"},
+		{"2001:db8:122:c000:0:aa00::", "2001:db8:122::/48"},
+		{"2001:db8:122:3c0:0:aa::", "2001:db8:122:300::/56"},
+		{"2001:db8:122:344:c0:0:aa00:0", "2001:db8:122:344::/64"},
+		{"64:ff9b::c000:2a", ""},
+		{"2001:db8::1", ""},
+		{"192.0.0.170", ""},
+	}
+
+	for _, test := range tests {
+		prefix := nat64Prefix(net.ParseIP(test.addr))
+		if test.prefix == "" {
+			if prefix != nil {
+				t.Errorf("expected no prefix for %s, not %s", test.addr, prefix)
+			}
+			continue
+		}
+		if prefix.String() != test.prefix {
+			t.Errorf("expected prefix %s for %s, not %s", test.prefix, test.addr, prefix)
+		}
+	}
+}
+
+func TestDNS64Discovery(t *testing.T) {
+	// every lookup takes the next answer, or error
+	type answer struct {
+		ips []net.IP
+		err error
+	}
+	answers := make(chan answer)
+	d := DNS64Discovery{
+		Interval: time.Millisecond,
+		lookup: func(ctx context.Context, host string) ([]net.IP, error) {
+			if host != "ipv4only.arpa" {
+				t.Errorf("unexpected lookup of %s", host)
+			}
+			select {
+			case a := <-answers:
+				return a.ips, a.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan *net.IPNet)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- d.Watch(ctx, func(prefix *net.IPNet) error {
+			updates <- prefix
+			return nil
+		})
+	}()
+
+	next := func() *net.IPNet {
+		t.Helper()
+		select {
+		case prefix := <-updates:
+			return prefix
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for update")
+			return nil
+		}
+	}
+
+	// the first discovery is always reported, even without a prefix
+	answers <- answer{ips: []net.IP{net.ParseIP("2001:db8::1")}}
+	if prefix := next(); prefix != nil {
+		t.Errorf("unexpected prefix %s", prefix)
+	}
+
+	answers <- answer{ips: []net.IP{net.ParseIP("64:ff9b::c000:aa"), net.ParseIP("64:ff9b::c000:ab")}}
+	if prefix := next(); prefix.String() != "64:ff9b::/96" {
+		t.Errorf("unexpected prefix %s", prefix)
+	}
+
+	// failing lookups keep the prefix, unlike the name not resolving
+	answers <- answer{err: errors.New("timeout")}
+	answers <- answer{ips: []net.IP{net.ParseIP("64:ff9b::c000:aa")}}
+	answers <- answer{err: &net.DNSError{Err: "no such host", Name: "ipv4only.arpa", IsNotFound: true}}
+	if prefix := next(); prefix != nil {
+		t.Errorf("unexpected prefix %s", prefix)
+	}
+
+	answers <- answer{ips: []net.IP{net.ParseIP("2001:db8:c000:aa::")}}
+	if prefix := next(); prefix.String() != "2001:db8::/32" {
+		t.Errorf("unexpected prefix %s", prefix)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+}
+
+func TestValidPREF64(t *testing.T) {
+	for _, s := range []string{"64:ff9b::/96", "2001:db8::/32", "2001:db8::/64"} {
+		_, prefix, _ := net.ParseCIDR(s)
+		if err := validPREF64(prefix); err != nil {
+			t.Errorf("unexpected error for %s: %s", s, err)
+		}
+	}
+	for _, s := range []string{"64:ff9b::/80", "2001:db8::/128", "192.0.2.0/24"} {
+		_, prefix, _ := net.ParseCIDR(s)
+		if err := validPREF64(prefix); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
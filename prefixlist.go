@@ -0,0 +1,202 @@
+package ndp
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OnLinkPrefix is a prefix on the prefix list
+type OnLinkPrefix struct {
+	Prefix *net.IPNet
+	// ValidUntil is when the valid lifetime ends, or zero if it doesn't
+	ValidUntil time.Time
+}
+
+// PrefixList maintains the prefix list as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.4 from the on-link
+// prefixes of the router advertisements passed to Update, removing them once
+// their valid lifetime ends. Like addresses, prefixes aren't shortened below
+// two hours by advertisements as described at
+// https://tools.ietf.org/html/rfc4862#section-5.5.3, as these aren't
+// authenticated either
+type PrefixList struct {
+	// Changed, when set, is called with the prefixes whenever prefixes come
+	// or go
+	Changed func([]OnLinkPrefix)
+
+	mu    sync.Mutex
+	list  prefixList
+	timer *time.Timer
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewPrefixList returns an empty PrefixList
+func NewPrefixList() *PrefixList {
+	return &PrefixList{
+		list: newPrefixList(),
+		now:  time.Now,
+	}
+}
+
+// Update processes the prefix information options of a router
+// advertisement, so Update can be passed to Mux.HandleRouterAdvertisement
+func (l *PrefixList) Update(ra *ICMPRouterAdvertisement, md *Metadata) {
+	l.mu.Lock()
+	changed := l.list.update(ra, l.now())
+	l.mu.Unlock()
+
+	l.changed(changed)
+}
+
+// Prefixes returns the prefixes, sorted by prefix
+func (l *PrefixList) Prefixes() []OnLinkPrefix {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list.sorted()
+}
+
+// OnLink reports whether ip is on-link, so it's reached directly rather than
+// through a router, as described at
+// https://tools.ietf.org/html/rfc4861#section-5.2. Link-local addresses
+// always are
+func (l *PrefixList) OnLink(ip net.IP) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list.onLink(ip, l.now())
+}
+
+// Stop stops expiring prefixes until the next Update
+func (l *PrefixList) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// expire removes the prefixes whose valid lifetime ended
+func (l *PrefixList) expire() {
+	l.mu.Lock()
+	changed := l.list.expire(l.now())
+	l.mu.Unlock()
+
+	l.changed(changed)
+}
+
+// changed schedules the next expiry and calls Changed if changed is set
+func (l *PrefixList) changed(changed bool) {
+	l.mu.Lock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if next := l.list.next(); !next.IsZero() {
+		l.timer = time.AfterFunc(next.Sub(l.now()), l.expire)
+	}
+	prefixes := l.list.sorted()
+	l.mu.Unlock()
+
+	if changed && l.Changed != nil {
+		l.Changed(prefixes)
+	}
+}
+
+// prefixList is the prefix list without locking or timers, as used by
+// PrefixList and SLAACClient
+type prefixList struct {
+	prefixes map[string]*OnLinkPrefix
+}
+
+func newPrefixList() prefixList {
+	return prefixList{prefixes: make(map[string]*OnLinkPrefix)}
+}
+
+// update processes the on-link prefixes of a router advertisement at now,
+// reporting whether prefixes came or went
+func (l prefixList) update(ra *ICMPRouterAdvertisement, now time.Time) bool {
+	changed := false
+	for _, o := range ra.Options {
+		pi, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok || !pi.OnLink || pi.PrefixLength > 128 {
+			continue
+		}
+		mask := net.CIDRMask(int(pi.PrefixLength), 128)
+		prefix := &net.IPNet{IP: pi.Prefix.Mask(mask), Mask: mask}
+		if prefix.IP == nil || prefix.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		key := prefix.String()
+		valid := pi.ValidLifetimeDuration()
+		p, ok := l.prefixes[key]
+		switch {
+		case ok:
+			p.ValidUntil = validEnd(p.ValidUntil, valid, now)
+			if !p.ValidUntil.IsZero() && !p.ValidUntil.After(now) {
+				delete(l.prefixes, key)
+				changed = true
+			}
+		case valid != 0:
+			l.prefixes[key] = &OnLinkPrefix{Prefix: prefix, ValidUntil: lifetimeEnd(now, valid)}
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// expire removes the prefixes whose valid lifetime ended at now, reporting
+// whether there were any
+func (l prefixList) expire(now time.Time) bool {
+	expired := false
+	for key, p := range l.prefixes {
+		if !p.ValidUntil.IsZero() && !p.ValidUntil.After(now) {
+			delete(l.prefixes, key)
+			expired = true
+		}
+	}
+
+	return expired
+}
+
+// next returns when the next prefix expires, if any
+func (l prefixList) next() time.Time {
+	var next time.Time
+	for _, p := range l.prefixes {
+		next = earliestEnd(next, p.ValidUntil)
+	}
+
+	return next
+}
+
+// onLink reports whether ip is on-link at now
+func (l prefixList) onLink(ip net.IP, now time.Time) bool {
+	if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return true
+	}
+	for _, p := range l.prefixes {
+		if p.Prefix.Contains(ip) && (p.ValidUntil.IsZero() || p.ValidUntil.After(now)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sorted returns the prefixes sorted by prefix
+func (l prefixList) sorted() []OnLinkPrefix {
+	var prefixes []OnLinkPrefix
+	for _, p := range l.prefixes {
+		prefixes = append(prefixes, *p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Prefix.String() < prefixes[j].Prefix.String()
+	})
+
+	return prefixes
+}
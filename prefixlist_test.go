@@ -0,0 +1,76 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPrefixList(t *testing.T) {
+	l := NewPrefixList()
+	defer l.Stop()
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+	changes := 0
+	l.Changed = func([]OnLinkPrefix) { changes++ }
+
+	ra := func(prefix string, onLink bool, valid time.Duration) *ICMPRouterAdvertisement {
+		ip, p, _ := net.ParseCIDR(prefix)
+		ones, _ := p.Mask.Size()
+		o := &ICMPOptionPrefixInformation{Prefix: ip, PrefixLength: uint8(ones), OnLink: onLink}
+		o.SetValidLifetime(valid)
+		ra := &ICMPRouterAdvertisement{}
+		ra.AddOption(o)
+		return ra
+	}
+
+	l.Update(ra("2001:db8:1::/64", true, 3*time.Hour), nil)
+	l.Update(ra("2001:db8:2::/48", true, Infinity), nil)
+	// ignored
+	l.Update(ra("2001:db8:3::/64", false, time.Hour), nil)
+	l.Update(ra("fe80::/64", true, time.Hour), nil)
+	l.Update(ra("2001:db8:4::/64", true, 0), nil)
+	if changes != 2 {
+		t.Errorf("expected 2 changes, not %d", changes)
+	}
+
+	prefixes := l.Prefixes()
+	if len(prefixes) != 2 || prefixes[0].Prefix.String() != "2001:db8:1::/64" || !prefixes[1].ValidUntil.IsZero() {
+		t.Fatalf("unexpected prefixes %v", prefixes)
+	}
+
+	tests := []struct {
+		ip     string
+		onLink bool
+	}{
+		{"2001:db8:1::1", true},
+		{"2001:db8:2:42::1", true},
+		{"2001:db8:3::1", false},
+		{"fe80::1234", true},
+		{"ff02::1", true},
+		{"2001:db8:5::1", false},
+	}
+	for _, test := range tests {
+		if l.OnLink(net.ParseIP(test.ip)) != test.onLink {
+			t.Errorf("expected %s on-link to be %t", test.ip, test.onLink)
+		}
+	}
+
+	// unauthenticated advertisements can't cut valid lifetimes below two
+	// hours, nor can they cut ones that are shorter already
+	l.Update(ra("2001:db8:1::/64", true, time.Minute), nil)
+	if p := l.Prefixes()[0]; !p.ValidUntil.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("unexpected valid lifetime end %s", p.ValidUntil)
+	}
+	now = now.Add(time.Hour)
+	l.Update(ra("2001:db8:1::/64", true, 0), nil)
+	if p := l.Prefixes()[0]; !p.ValidUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected valid lifetime end %s", p.ValidUntil)
+	}
+
+	now = now.Add(time.Hour)
+	l.expire()
+	if prefixes := l.Prefixes(); len(prefixes) != 1 || changes != 3 || l.OnLink(net.ParseIP("2001:db8:1::1")) {
+		t.Errorf("expected 2001:db8:1::/64 to expire, not %v", prefixes)
+	}
+}
@@ -0,0 +1,326 @@
+package ndp
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PrefixSource provides prefixes for RAServer to advertise that may change
+// while it runs, see WatchPrefixes
+type PrefixSource interface {
+	// Watch calls update with the current prefixes and again whenever they
+	// change until ctx is done or update fails
+	Watch(ctx context.Context, update func([]RAPrefix) error) error
+}
+
+// StaticPrefixes is a PrefixSource of prefixes that never change
+type StaticPrefixes []RAPrefix
+
+// Watch calls update once and waits for ctx to be done
+func (p StaticPrefixes) Watch(ctx context.Context, update func([]RAPrefix) error) error {
+	if err := update(p); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// mergePrefixes returns the prefixes of all lists, leaving out the ones
+// that are in an earlier list already
+func mergePrefixes(lists ...[]RAPrefix) []RAPrefix {
+	var merged []RAPrefix
+	seen := make(map[string]bool)
+	for _, l := range lists {
+		for _, p := range l {
+			if key := p.Prefix.String(); !seen[key] {
+				seen[key] = true
+				merged = append(merged, p)
+			}
+		}
+	}
+
+	return merged
+}
+
+// LeasePrefixes is a PrefixSource advertising a subnet of every prefix
+// delegated by DHCPv6 prefix delegation, as found in the lease file of ISC
+// dhclient. Prefixes are advertised with the lifetimes they have left when
+// the lease file changes and withdrawn once they expire
+type LeasePrefixes struct {
+	// Path is the lease file, like /var/lib/dhcp/dhclient6.leases
+	Path string
+	// SubnetID selects the subnet of each delegated prefix
+	SubnetID uint64
+	// Length is the length of the advertised subnets, 64 if 0
+	Length int
+	// Interval is how often Path is checked for changes, 10s if 0
+	Interval time.Duration
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// expiringPrefix is a prefix with the times its lifetimes end, which are
+// zero for infinite lifetimes
+type expiringPrefix struct {
+	prefix    *net.IPNet
+	preferred time.Time
+	valid     time.Time
+}
+
+// raPrefix returns the RAPrefix for p with the lifetimes it has left at now
+func (p expiringPrefix) raPrefix(now time.Time) RAPrefix {
+	r := NewRAPrefix(p.prefix)
+	r.ValidLifetime = remaining(p.valid, now)
+	r.PreferredLifetime = remaining(p.preferred, now)
+
+	return r
+}
+
+// sameExpiry reports whether a and b hold the same prefixes with lifetimes
+// ending within a second of each other, which is as precise as lifetimes
+// are reported
+func sameExpiry(a, b []expiringPrefix) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	near := func(x, y time.Time) bool {
+		if x.IsZero() || y.IsZero() {
+			return x.IsZero() == y.IsZero()
+		}
+		d := x.Sub(y)
+		return d > -time.Second && d < time.Second
+	}
+	for i := range a {
+		if a[i].prefix.String() != b[i].prefix.String() || !near(a[i].preferred, b[i].preferred) || !near(a[i].valid, b[i].valid) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Watch reads the lease file every Interval and calls update when it changed
+// or one of its prefixes expired. A missing lease file holds no prefixes
+func (l LeasePrefixes) Watch(ctx context.Context, update func([]RAPrefix) error) error {
+	interval := l.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	now := l.now
+	if now == nil {
+		now = time.Now
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		leases  []expiringPrefix
+		modTime time.Time
+		size    int64
+		alive   = -1
+	)
+	for {
+		changed := false
+		fi, err := os.Stat(l.Path)
+		switch {
+		case os.IsNotExist(err):
+			changed = leases != nil
+			leases, modTime, size = nil, time.Time{}, 0
+		case err != nil:
+			return err
+		case !fi.ModTime().Equal(modTime) || fi.Size() != size:
+			b, err := os.ReadFile(l.Path)
+			if err != nil {
+				return err
+			}
+			if leases, err = parseLeases(b); err != nil {
+				return fmt.Errorf("%s: %s", l.Path, err)
+			}
+			modTime, size = fi.ModTime(), fi.Size()
+			changed = true
+		}
+
+		t := now()
+		n := 0
+		for _, lease := range leases {
+			if remaining(lease.valid, t) > 0 {
+				n++
+			}
+		}
+		if changed || n != alive {
+			prefixes, err := l.prefixes(leases, t)
+			if err != nil {
+				return err
+			}
+			if err := update(prefixes); err != nil {
+				return err
+			}
+			alive = n
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// prefixes returns the subnets to advertise of the leases that are valid at
+// now, sorted by prefix
+func (l LeasePrefixes) prefixes(leases []expiringPrefix, now time.Time) ([]RAPrefix, error) {
+	length := l.Length
+	if length == 0 {
+		length = 64
+	}
+
+	var prefixes []RAPrefix
+	for _, lease := range leases {
+		if remaining(lease.valid, now) == 0 {
+			continue
+		}
+
+		subnet, err := subnetPrefix(lease.prefix, l.SubnetID, length)
+		if err != nil {
+			return nil, err
+		}
+		lease.prefix = subnet
+		prefixes = append(prefixes, lease.raPrefix(now))
+	}
+	sortPrefixes(prefixes)
+
+	return prefixes, nil
+}
+
+// sortPrefixes sorts prefixes by prefix, so sources report the same
+// prefixes the same way
+func sortPrefixes(prefixes []RAPrefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Prefix.String() < prefixes[j].Prefix.String()
+	})
+}
+
+// remaining returns the lifetime left until t, which is Infinity for the
+// zero time
+func remaining(t, now time.Time) time.Duration {
+	if t.IsZero() {
+		return Infinity
+	}
+	if d := t.Sub(now); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// subnetPrefix returns subnet id of given length within prefix
+func subnetPrefix(prefix *net.IPNet, id uint64, length int) (*net.IPNet, error) {
+	ones, _ := prefix.Mask.Size()
+	if length < ones || length > 128 {
+		return nil, fmt.Errorf("can't take a /%d from %s", length, prefix)
+	}
+	if bits := length - ones; bits < 64 && id >= 1<<uint(bits) {
+		return nil, fmt.Errorf("subnet id %d doesn't fit in %s for a /%d", id, prefix, length)
+	}
+
+	n := new(big.Int).SetBytes(prefix.IP.Mask(prefix.Mask).To16())
+	n.Or(n, new(big.Int).Lsh(new(big.Int).SetUint64(id), uint(128-length)))
+	ip := make(net.IP, net.IPv6len)
+	n.FillBytes(ip)
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(length, 128)}, nil
+}
+
+// parseLeases returns the delegated prefixes in the ISC dhclient lease file
+// b. Prefixes that are leased more than once take the lifetimes of their
+// last lease, as dhclient appends renewed leases
+func parseLeases(b []byte) ([]expiringPrefix, error) {
+	// lease files share their syntax with radvd.conf, but not every block
+	// ends with a semicolon
+	p := &radvdParser{toks: radvdTokenize(string(b))}
+
+	var leases []expiringPrefix
+	index := make(map[string]int)
+	for !p.done() {
+		s, _ := p.next()
+		if s != "iaprefix" {
+			continue
+		}
+
+		arg, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		_, prefix, err := net.ParseCIDR(arg)
+		if err != nil || prefix.IP.To4() != nil {
+			return nil, p.errorf("invalid prefix %q", arg)
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+
+		var starts, preferred, valid uint64
+		for p.peek() != "}" {
+			name, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			args := p.args()
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			var v *uint64
+			switch name {
+			case "starts":
+				v = &starts
+			case "preferred-life":
+				v = &preferred
+			case "max-life":
+				v = &valid
+			default:
+				continue
+			}
+			if len(args) != 1 {
+				return nil, p.errorf("%s takes a single value", name)
+			}
+			if *v, err = strconv.ParseUint(args[0], 10, 32); err != nil {
+				return nil, p.errorf("invalid %s %q", name, args[0])
+			}
+		}
+		p.pos++
+
+		lease := expiringPrefix{
+			prefix:    prefix,
+			preferred: leaseExpiry(starts, preferred),
+			valid:     leaseExpiry(starts, valid),
+		}
+		if i, ok := index[prefix.String()]; ok {
+			leases[i] = lease
+		} else {
+			index[prefix.String()] = len(leases)
+			leases = append(leases, lease)
+		}
+	}
+
+	return leases, nil
+}
+
+// leaseExpiry returns when a lifetime in seconds from starts ends, or the
+// zero time for infinite lifetimes
+func leaseExpiry(starts, lifetime uint64) time.Time {
+	if lifetime == 0xffffffff {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(starts+lifetime), 0)
+}
@@ -0,0 +1,209 @@
+//go:build linux
+
+package ndp
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// InterfacePrefixes is a PrefixSource advertising the prefixes of the global
+// addresses of an interface, typically an upstream one that got them through
+// SLAAC or DHCPv6, as reported by netlink. Prefixes are advertised with the
+// lifetimes their addresses have left whenever these change
+type InterfacePrefixes struct {
+	Interface *net.Interface
+	// Length, when set, only advertises prefixes of this length
+	Length int
+}
+
+// Watch dumps the addresses of the interface whenever netlink reports a
+// change to them
+func (p InterfacePrefixes) Watch(ctx context.Context, update func([]RAPrefix) error) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_IPV6_IFADDR}); err != nil {
+		return os.NewSyscallError("bind", err)
+	}
+	// recvfrom can't be interrupted, so wake up regularly to check ctx
+	tv := unix.NsecToTimeval(int64(time.Second))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+
+	var last []expiringPrefix
+	first := true
+	buf := make([]byte, os.Getpagesize())
+	for {
+		// we subscribed before dumping, so no change goes unnoticed
+		b, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_INET6)
+		if err != nil {
+			return os.NewSyscallError("netlinkrib", err)
+		}
+		now := time.Now()
+		prefixes, err := interfacePrefixes(b, p.Interface.Index, p.Length, now)
+		if err != nil {
+			return err
+		}
+		// lifetimes count down between dumps, only renewals count
+		if first || !sameExpiry(prefixes, last) {
+			rs := make([]RAPrefix, 0, len(prefixes))
+			for _, e := range prefixes {
+				rs = append(rs, e.raPrefix(now))
+			}
+			if err := update(rs); err != nil {
+				return err
+			}
+			last, first = prefixes, false
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			if err != nil {
+				return os.NewSyscallError("recvfrom", err)
+			}
+			if addrChanged(buf[:n], p.Interface.Index) {
+				break
+			}
+		}
+	}
+}
+
+// addrChanged reports whether the netlink messages in b, or ones that were
+// dropped, may concern the addresses of interface index
+func addrChanged(b []byte, index int) bool {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return true
+	}
+
+	for _, m := range msgs {
+		if len(m.Data) < unix.SizeofIfAddrmsg {
+			continue
+		}
+		ifam := (*unix.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+		if int(ifam.Index) == index {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interfacePrefixes returns the prefixes of the global addresses of interface
+// index in the RTM_NEWADDR messages in b, leaving out temporary addresses
+// and addresses that aren't usable yet. Only prefixes of given length are
+// returned, unless it is 0
+func interfacePrefixes(b []byte, index, length int, now time.Time) ([]expiringPrefix, error) {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+
+	var prefixes []expiringPrefix
+	seen := make(map[string]int)
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWADDR || len(m.Data) < unix.SizeofIfAddrmsg {
+			continue
+		}
+		ifam := (*unix.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+		if int(ifam.Index) != index || ifam.Family != unix.AF_INET6 || ifam.Scope != unix.RT_SCOPE_UNIVERSE {
+			continue
+		}
+		// a /128 has no prefix to share
+		if ifam.Prefixlen == 128 || (length != 0 && int(ifam.Prefixlen) != length) {
+			continue
+		}
+
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, os.NewSyscallError("parsenetlinkrouteattr", err)
+		}
+
+		var (
+			ip    net.IP
+			ci    *unix.IfaCacheinfo
+			flags = uint32(ifam.Flags)
+		)
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case unix.IFA_ADDRESS:
+				if len(a.Value) == net.IPv6len {
+					ip = net.IP(a.Value)
+				}
+			case unix.IFA_CACHEINFO:
+				if len(a.Value) >= unix.SizeofIfaCacheinfo {
+					ci = (*unix.IfaCacheinfo)(unsafe.Pointer(&a.Value[0]))
+				}
+			case unix.IFA_FLAGS:
+				if len(a.Value) >= 4 {
+					flags = binary.NativeEndian.Uint32(a.Value)
+				}
+			}
+		}
+		if ip == nil || flags&(unix.IFA_F_TEMPORARY|unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) != 0 {
+			continue
+		}
+
+		mask := net.CIDRMask(int(ifam.Prefixlen), 128)
+		e := expiringPrefix{prefix: &net.IPNet{IP: ip.Mask(mask), Mask: mask}}
+		if ci != nil {
+			e.preferred = kernelExpiry(ci.Prefered, now)
+			e.valid = kernelExpiry(ci.Valid, now)
+		}
+
+		// of several addresses in a prefix, the longest lived one counts
+		key := e.prefix.String()
+		if i, ok := seen[key]; ok {
+			if outlives(e.valid, prefixes[i].valid) {
+				prefixes[i] = e
+			}
+			continue
+		}
+		seen[key] = len(prefixes)
+		prefixes = append(prefixes, e)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].prefix.String() < prefixes[j].prefix.String()
+	})
+
+	return prefixes, nil
+}
+
+// kernelExpiry returns when a lifetime in seconds left at now ends, or the
+// zero time for infinite lifetimes
+func kernelExpiry(lifetime uint32, now time.Time) time.Time {
+	if lifetime == 0xffffffff {
+		return time.Time{}
+	}
+
+	return now.Add(lifetimeToDuration(lifetime))
+}
+
+// outlives reports whether expiry a is later than b, where zero is infinite
+func outlives(a, b time.Time) bool {
+	if b.IsZero() {
+		return false
+	}
+
+	return a.IsZero() || a.After(b)
+}
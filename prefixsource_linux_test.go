@@ -0,0 +1,91 @@
+//go:build linux
+
+package ndp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// newAddrMessage returns an RTM_NEWADDR message for addr on interface index
+func newAddrMessage(index int, addr string, flags uint32, scope uint8, preferred, valid uint32) []byte {
+	ip, prefix, _ := net.ParseCIDR(addr)
+	ones, _ := prefix.Mask.Size()
+
+	attr := func(typ uint16, v []byte) []byte {
+		b := make([]byte, 4, 4+len(v))
+		binary.NativeEndian.PutUint16(b, uint16(4+len(v)))
+		binary.NativeEndian.PutUint16(b[2:], typ)
+		return append(b, v...)
+	}
+
+	ci := unix.IfaCacheinfo{Prefered: preferred, Valid: valid}
+	f := make([]byte, 4)
+	binary.NativeEndian.PutUint32(f, flags)
+
+	ifam := unix.IfAddrmsg{Family: unix.AF_INET6, Prefixlen: uint8(ones), Scope: scope, Index: uint32(index)}
+	b := make([]byte, unix.SizeofNlMsghdr)
+	b = append(b, (*[unix.SizeofIfAddrmsg]byte)(unsafe.Pointer(&ifam))[:]...)
+	b = append(b, attr(unix.IFA_ADDRESS, ip.To16())...)
+	b = append(b, attr(unix.IFA_CACHEINFO, (*[unix.SizeofIfaCacheinfo]byte)(unsafe.Pointer(&ci))[:])...)
+	b = append(b, attr(unix.IFA_FLAGS, f)...)
+	binary.NativeEndian.PutUint32(b, uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:], unix.RTM_NEWADDR)
+
+	return b
+}
+
+func TestInterfacePrefixes(t *testing.T) {
+	var b []byte
+	b = append(b, newAddrMessage(2, "2001:db8:1::1/64", 0, unix.RT_SCOPE_UNIVERSE, 600, 1800)...)
+	// the longest lived address of a prefix counts
+	b = append(b, newAddrMessage(2, "2001:db8:1::2/64", 0, unix.RT_SCOPE_UNIVERSE, 3600, 7200)...)
+	b = append(b, newAddrMessage(2, "2001:db8:2::1/56", 0, unix.RT_SCOPE_UNIVERSE, 0xffffffff, 0xffffffff)...)
+	// none of these count
+	b = append(b, newAddrMessage(3, "2001:db8:3::1/64", 0, unix.RT_SCOPE_UNIVERSE, 600, 1800)...)
+	b = append(b, newAddrMessage(2, "fe80::1/64", 0, unix.RT_SCOPE_LINK, 600, 1800)...)
+	b = append(b, newAddrMessage(2, "2001:db8:4::1/64", unix.IFA_F_TEMPORARY, unix.RT_SCOPE_UNIVERSE, 600, 1800)...)
+	b = append(b, newAddrMessage(2, "2001:db8:5::1/64", unix.IFA_F_TENTATIVE, unix.RT_SCOPE_UNIVERSE, 600, 1800)...)
+	b = append(b, newAddrMessage(2, "2001:db8:6::1/128", 0, unix.RT_SCOPE_UNIVERSE, 600, 1800)...)
+
+	now := time.Unix(1000, 0)
+	prefixes, err := interfacePrefixes(b, 2, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 2 {
+		t.Fatalf("expected 2 prefixes, not %v", prefixes)
+	}
+
+	p := prefixes[0].raPrefix(now)
+	if p.Prefix.String() != "2001:db8:1::/64" || p.PreferredLifetime != time.Hour || p.ValidLifetime != 2*time.Hour {
+		t.Errorf("unexpected prefix %+v", p)
+	}
+	p = prefixes[1].raPrefix(now)
+	if p.Prefix.String() != "2001:db8:2::/56" || p.PreferredLifetime != Infinity || p.ValidLifetime != Infinity {
+		t.Errorf("unexpected prefix %+v", p)
+	}
+
+	// lifetimes counting down aren't changes
+	later, err := interfacePrefixes(b, 2, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later[0].valid = later[0].valid.Add(-500 * time.Millisecond)
+	if !sameExpiry(prefixes, later) {
+		t.Error("expected same expiry")
+	}
+
+	if prefixes, _ := interfacePrefixes(b, 2, 64, now); len(prefixes) != 1 {
+		t.Errorf("expected only the /64, not %v", prefixes)
+	}
+
+	if !addrChanged(b, 3) || addrChanged(b, 4) {
+		t.Error("unexpected changes")
+	}
+}
@@ -0,0 +1,179 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMergePrefixes(t *testing.T) {
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+	a := NewRAPrefix(p1)
+	b := NewRAPrefix(p1)
+	b.Autonomous = false
+
+	merged := mergePrefixes([]RAPrefix{a}, []RAPrefix{b, NewRAPrefix(p2)})
+	if len(merged) != 2 || !merged[0].Autonomous || merged[1].Prefix != p2 {
+		t.Errorf("unexpected merged prefixes %v", merged)
+	}
+	if mergePrefixes(nil, nil) != nil {
+		t.Error("expected no prefixes")
+	}
+}
+
+func TestSubnetPrefix(t *testing.T) {
+	_, pd, _ := net.ParseCIDR("2001:db8:1200::/56")
+
+	tests := []struct {
+		id     uint64
+		length int
+		subnet string
+	}{
+		{0, 64, "2001:db8:1200::/64"},
+		{1, 64, "2001:db8:1200:1::/64"},
+		{255, 64, "2001:db8:1200:ff::/64"},
+		{0, 56, "2001:db8:1200::/56"},
+		{3, 58, "2001:db8:1200:c0::/58"},
+	}
+
+	for _, test := range tests {
+		subnet, err := subnetPrefix(pd, test.id, test.length)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if subnet.String() != test.subnet {
+			t.Errorf("expected %s for subnet %d, not %s", test.subnet, test.id, subnet)
+		}
+	}
+
+	if _, err := subnetPrefix(pd, 256, 64); err == nil {
+		t.Error("expected error for subnet id out of range")
+	}
+	if _, err := subnetPrefix(pd, 0, 48); err == nil {
+		t.Error("expected error for subnet shorter than the prefix")
+	}
+}
+
+const testLeases = `
+default-duid "\000\001\000\001";
+lease6 {
+  interface "eth0";
+  ia-pd 1a:2b:3c:4d {
+    starts 1000;
+    renew 1800;
+    rebind 2880;
+    iaprefix 2001:db8:1200::/56 {
+      starts 1000;
+      preferred-life 3600;
+      max-life 7200;
+    }
+  }
+  option dhcp6.client-id 0:1:0:1;
+}
+lease6 {
+  interface "eth0";
+  ia-pd 1a:2b:3c:4d {
+    iaprefix 2001:db8:1200::/56 {
+      starts 2000;
+      preferred-life 3600;
+      max-life 7200;
+      option dhcp6.status-code success;
+    }
+    iaprefix 2001:db8:3400::/56 {
+      starts 2000;
+      preferred-life 4294967295;
+      max-life 4294967295;
+    }
+  }
+}
+`
+
+func TestParseLeases(t *testing.T) {
+	leases, err := parseLeases([]byte(testLeases))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("expected 2 leases, not %d", len(leases))
+	}
+
+	// the renewed lease counts
+	if leases[0].prefix.String() != "2001:db8:1200::/56" || !leases[0].preferred.Equal(time.Unix(5600, 0)) || !leases[0].valid.Equal(time.Unix(9200, 0)) {
+		t.Errorf("unexpected lease %+v", leases[0])
+	}
+	if !leases[1].preferred.IsZero() || !leases[1].valid.IsZero() {
+		t.Errorf("expected infinite lease, not %+v", leases[1])
+	}
+
+	for _, b := range []string{
+		`iaprefix 192.0.2.0/24 { }`,
+		`iaprefix 2001:db8::/56 { starts soon; }`,
+		`iaprefix 2001:db8::/56 { starts 1`,
+	} {
+		if _, err := parseLeases([]byte(b)); err == nil {
+			t.Errorf("expected error for %q", b)
+		}
+	}
+}
+
+func TestLeasePrefixes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhclient6.leases")
+	now := time.Unix(3000, 0)
+	l := LeasePrefixes{
+		Path:     path,
+		SubnetID: 1,
+		Interval: time.Millisecond,
+		now:      func() time.Time { return now },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []RAPrefix, 16)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.Watch(ctx, func(prefixes []RAPrefix) error {
+			updates <- prefixes
+			return nil
+		})
+	}()
+
+	next := func() []RAPrefix {
+		t.Helper()
+		select {
+		case prefixes := <-updates:
+			return prefixes
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for prefixes")
+			return nil
+		}
+	}
+
+	// no lease file yet
+	if prefixes := next(); len(prefixes) != 0 {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+
+	if err := os.WriteFile(path, []byte(testLeases), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prefixes := next()
+	if len(prefixes) != 2 {
+		t.Fatalf("expected 2 prefixes, not %v", prefixes)
+	}
+	if p := prefixes[0]; p.Prefix.String() != "2001:db8:1200:1::/64" || p.PreferredLifetime != 2600*time.Second || p.ValidLifetime != 6200*time.Second {
+		t.Errorf("unexpected prefix %+v", p)
+	}
+	if p := prefixes[1]; p.Prefix.String() != "2001:db8:3400:1::/64" || p.ValidLifetime != Infinity {
+		t.Errorf("unexpected prefix %+v", p)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+)
+
+// ProbeReply is a neighbor advertisement answering a probe of Prober
+type ProbeReply struct {
+	// Seq is the number of the probe, counting from 1
+	Seq              int
+	RTT              time.Duration
+	LinkLayerAddress net.HardwareAddr
+	Router           bool
+}
+
+// Prober checks whether a neighbor is reachable by soliciting it, like
+// ndisc6 and arping do
+type Prober struct {
+	// Count is the number of probes, 3 if 0
+	Count int
+	// Interval is the time between probes and how long replies are waited
+	// for, RetransTimer if 0
+	Interval time.Duration
+	// Multicast solicits through the solicited-node group of the target
+	// rather than the target itself, which also finds neighbors of which
+	// the link-layer address isn't known
+	Multicast bool
+	// Reply, when set, is called for every reply as it arrives
+	Reply func(ProbeReply)
+
+	c *Conn
+}
+
+// NewProber returns a Prober sending probes on c
+func NewProber(c *Conn) *Prober {
+	return &Prober{c: c}
+}
+
+// Probe probes ip through its own Conn on ifi, see Prober.Probe
+func Probe(ctx context.Context, ip net.IP, ifi *net.Interface) ([]ProbeReply, error) {
+	c, err := Listen(ifi, RoleHost)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	return NewProber(c).Probe(ctx, ip)
+}
+
+// Probe sends Count neighbor solicitations for ip and returns the replies.
+// It returns ErrUnreachable when none came and the error of ctx when it is
+// done first. It reads from the Conn itself, so it can't run along with
+// Conn.Serve
+func (p *Prober) Probe(ctx context.Context, ip net.IP) ([]ProbeReply, error) {
+	count := p.Count
+	if count == 0 {
+		count = 3
+	}
+	interval := p.Interval
+	if interval == 0 {
+		interval = RetransTimer
+	}
+
+	var replies []ProbeReply
+	for seq := 1; seq <= count; seq++ {
+		sent := time.Now()
+		if err := p.solicit(ip); err != nil {
+			return replies, err
+		}
+
+		reply, err := p.wait(ctx, ip, sent, sent.Add(interval))
+		switch {
+		case err == os.ErrDeadlineExceeded:
+			continue
+		case err != nil:
+			return replies, err
+		}
+		reply.Seq = seq
+		replies = append(replies, reply)
+		p.c.logger().Debug("probe reply", "addr", ip.String(), "seq", seq, "rtt", reply.RTT, "lladdr", reply.LinkLayerAddress.String())
+		if p.Reply != nil {
+			p.Reply(reply)
+		}
+
+		// keep the pace when the reply came early
+		if seq < count {
+			select {
+			case <-ctx.Done():
+				return replies, ctx.Err()
+			case <-time.After(time.Until(sent.Add(interval))):
+			}
+		}
+	}
+
+	if len(replies) == 0 {
+		return nil, ErrUnreachable
+	}
+
+	return replies, nil
+}
+
+// solicit sends a single probe for ip
+func (p *Prober) solicit(ip net.IP) error {
+	if p.Multicast {
+		return p.c.SendNS(ip)
+	}
+
+	ns := &ICMPNeighborSolicitation{TargetAddress: ip.To16()}
+	if lla := p.c.linkLayerAddr(); lla != nil {
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	return p.c.WriteTo(ns, nil, ip)
+}
+
+// wait reads until an advertisement for ip arrives, returning
+// os.ErrDeadlineExceeded when none did by deadline
+func (p *Prober) wait(ctx context.Context, ip net.IP, sent, deadline time.Time) (ProbeReply, error) {
+	rctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for {
+		m, md, err := p.c.ReadMessage(rctx)
+		if err != nil {
+			if cerr := ctx.Err(); cerr != nil {
+				return ProbeReply{}, cerr
+			}
+			// deadlines may expire just before contexts notice
+			if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+				return ProbeReply{}, context.DeadlineExceeded
+			}
+			if err == context.DeadlineExceeded || !time.Now().Before(deadline) {
+				return ProbeReply{}, os.ErrDeadlineExceeded
+			}
+			if md != nil {
+				// not a message, but the transport is fine
+				continue
+			}
+			return ProbeReply{}, err
+		}
+
+		na, ok := m.(*ICMPNeighborAdvertisement)
+		if !ok || !na.TargetAddress.Equal(ip) || !validMessage(m, md, p.c.unknownHopLimit) {
+			continue
+		}
+		reply := ProbeReply{RTT: time.Since(sent), Router: na.Router}
+		for _, o := range na.Options {
+			if o, ok := o.(*ICMPOptionTargetLinkLayerAddress); ok {
+				reply.LinkLayerAddress = o.LinkLayerAddress
+			}
+		}
+
+		return reply, nil
+	}
+}
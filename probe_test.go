@@ -0,0 +1,80 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProber(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	// b answers the first and last probe
+	go func() {
+		for i := 0; ; i++ {
+			m, md, err := b.ReadFrom()
+			if err != nil {
+				return
+			}
+			ns, ok := m.(*ICMPNeighborSolicitation)
+			if !ok || !md.Destination.Equal(b.Addr()) {
+				t.Errorf("unexpected probe %s to %s", m, md.Destination)
+				continue
+			}
+			if i == 1 {
+				continue
+			}
+			// not the one asked for
+			b.SendNA(net.ParseIP("fe80::42"), md.Source)
+			b.SendNA(ns.TargetAddress, md.Source)
+		}
+	}()
+
+	p := NewProber(a)
+	p.Interval = 20 * time.Millisecond
+	var streamed []ProbeReply
+	p.Reply = func(r ProbeReply) { streamed = append(streamed, r) }
+
+	replies, err := p.Probe(context.Background(), b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 2 || replies[0].Seq != 1 || replies[1].Seq != 3 || len(streamed) != 2 {
+		t.Fatalf("unexpected replies %v", replies)
+	}
+	for _, r := range replies {
+		if r.LinkLayerAddress.String() != b.Interface().HardwareAddr.String() || r.RTT <= 0 || r.RTT > p.Interval {
+			t.Errorf("unexpected reply %+v", r)
+		}
+	}
+}
+
+func TestProberUnreachable(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	p := NewProber(a)
+	p.Count = 2
+	p.Interval = 10 * time.Millisecond
+	p.Multicast = true
+	if _, err := p.Probe(context.Background(), net.ParseIP("fe80::42")); err != ErrUnreachable {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	group, _ := SolicitedNodeMulticast(net.ParseIP("fe80::42"))
+	for i := 0; i < p.Count; i++ {
+		if _, md := readSolicitation(t, b); !md.Destination.Equal(group) {
+			t.Errorf("unexpected destination %s", md.Destination)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Probe(ctx, net.ParseIP("fe80::42")); err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	errNoProxyPrefix = errors.New("proxy rule without prefix")
+)
+
+// ProxyRule is a prefix NDPProxy answers neighbor solicitations for, or
+// doesn't when Deny is set. Rules for single addresses take a /128 prefix
+type ProxyRule struct {
+	Prefix *net.IPNet
+	// Deny has solicitations for Prefix go unanswered, which lets more
+	// specific rules carve addresses out of the prefixes of others
+	Deny bool
+	// LinkLayerAddress, when set, is advertised instead of that of the
+	// interface, to point neighbors at another node
+	LinkLayerAddress net.HardwareAddr
+	// Anycast marks the addresses of Prefix as anycast addresses, which
+	// several nodes answer for. Advertisements for them are delayed by up to
+	// MaxAnycastDelayTime as described at
+	// https://tools.ietf.org/html/rfc4861#section-7.2.7, so answers spread
+	// out
+	Anycast bool
+	// Interface names the interface Downstream is of in a ProxyConfig
+	Interface string
+	// Downstream, when set, is the NeighborCache of the interface the
+	// addresses in Prefix live behind, which must be serving. Solicitations
+	// are then only answered for addresses it resolves, like the auto and
+	// iface rules of ndppd do. Without it, every address in Prefix is
+	// answered for right away
+	Downstream *NeighborCache
+}
+
+// NDPProxy answers the neighbor solicitations of an upstream link for the
+// addresses in its rules as described at
+// https://tools.ietf.org/html/rfc4861#section-7.2.8, advertising the
+// link-layer address of its own interface so traffic for them reaches this
+// node, which routes it on. This is what makes a prefix routed to a host,
+// like the /64 of many hosting providers, work when the upstream router
+// expects it on-link. The Conn must see solicitations for addresses other
+// than its own, like one ListenFrames returns in promiscuous mode does
+type NDPProxy struct {
+	// Router sets the router flag of the advertisements, for proxying on
+	// behalf of routers
+	Router bool
+	// Timeout bounds resolving a target downstream. It defaults to
+	// MaxMulticastSolicit times RetransTimer
+	Timeout time.Duration
+	// Proxied, when set, is called for every target advertised in answer
+	// to a solicitation received with md
+	Proxied func(target net.IP, md *Metadata)
+	// Looped, when set, is called for every solicitation received with md
+	// that this NDPProxy sent itself, and which it ignores
+	Looped func(target net.IP, md *Metadata)
+
+	c     *Conn
+	rules []ProxyRule
+
+	mu sync.Mutex
+	// pending holds the targets being resolved downstream
+	pending map[string]bool
+
+	// overridden by tests
+	after func(time.Duration) <-chan time.Time
+	rand  func() float64
+}
+
+// NewNDPProxy returns an NDPProxy answering solicitations read from c for the
+// addresses in rules. The most specific rule applies to every target
+func NewNDPProxy(c *Conn, rules ...ProxyRule) (*NDPProxy, error) {
+	for _, r := range rules {
+		if r.Prefix == nil {
+			return nil, errNoProxyPrefix
+		}
+		if r.Prefix.IP.To4() != nil || len(r.Prefix.Mask) != net.IPv6len {
+			return nil, fmt.Errorf("proxy prefix %s is not an IPv6 prefix", r.Prefix)
+		}
+		if r.Interface != "" && r.Downstream == nil {
+			return nil, fmt.Errorf("proxy prefix %s lacks the neighbor cache of %s", r.Prefix, r.Interface)
+		}
+	}
+
+	return &NDPProxy{
+		Timeout: MaxMulticastSolicit * RetransTimer,
+		c:       c,
+		rules:   append([]ProxyRule(nil), rules...),
+		pending: make(map[string]bool),
+		after:   time.After,
+		rand:    rand.Float64,
+	}, nil
+}
+
+// Serve answers the solicitations read from the Conn until ctx is done or
+// reading fails
+func (p *NDPProxy) Serve(ctx context.Context) error {
+	return p.c.Serve(ctx, p)
+}
+
+// ServeNDP answers m, received with md, when it is a neighbor solicitation
+// for an address in one of the rules. Solicitations this NDPProxy sent
+// downstream itself are ignored, so proxies facing each other don't keep
+// soliciting on behalf of one another
+func (p *NDPProxy) ServeNDP(m ICMP, md *Metadata) {
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok || md == nil {
+		return
+	}
+
+	r, ok := p.rule(ns.TargetAddress)
+	if !ok || r.Deny {
+		return
+	}
+	if p.looped(ns, md) {
+		p.c.logger().Warn("dropped looped solicitation", append(receivedAttrs(ns, md), "target", ns.TargetAddress.String())...)
+		if p.Looped != nil {
+			p.Looped(ns.TargetAddress, md)
+		}
+		return
+	}
+	if r.Downstream == nil {
+		p.advertise(r, ns, md)
+		return
+	}
+
+	// answer right away for neighbors known to be there
+	if n, ok := r.Downstream.Lookup(ns.TargetAddress); ok && n.State == NeighborReachable {
+		p.advertise(r, ns, md)
+		return
+	}
+
+	key := ns.TargetAddress.String()
+	p.mu.Lock()
+	if p.pending[key] {
+		p.mu.Unlock()
+		return
+	}
+	p.pending[key] = true
+	p.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+		_, err := r.Downstream.Resolve(ctx, ns.TargetAddress)
+		cancel()
+
+		p.mu.Lock()
+		delete(p.pending, key)
+		p.mu.Unlock()
+
+		// the soliciting node tries again when nothing is there
+		if err == nil {
+			p.advertise(r, ns, md)
+		}
+	}()
+}
+
+// looped reports whether ns, received with md, came from one of the Conns
+// of this NDPProxy, judging by its source address and source link-layer
+// address
+func (p *NDPProxy) looped(ns *ICMPNeighborSolicitation, md *Metadata) bool {
+	lla := sourceLinkLayerAddr(ns.Options)
+	if lla == nil {
+		lla = md.SourceLinkLayerAddress
+	}
+
+	// link-local addresses only tell our own messages apart on this link
+	if addr := p.c.Addr(); addr != nil && addr.Equal(md.Source) {
+		return true
+	}
+
+	conns := []*Conn{p.c}
+	for _, r := range p.rules {
+		if r.Downstream != nil {
+			conns = append(conns, r.Downstream.c)
+		}
+	}
+	for _, c := range conns {
+		if own := c.linkLayerAddr(); own != nil && bytes.Equal(own, lla) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rule returns the most specific rule containing target
+func (p *NDPProxy) rule(target net.IP) (ProxyRule, bool) {
+	var (
+		match ProxyRule
+		best  = -1
+	)
+	for _, r := range p.rules {
+		ones, _ := r.Prefix.Mask.Size()
+		if ones > best && r.Prefix.Contains(target) {
+			match, best = r, ones
+		}
+	}
+
+	return match, best >= 0
+}
+
+// advertise answers ns, received with md, by rule r. Solicitations for
+// duplicate address detection are answered to all nodes, others to their
+// source. Answers for anycast addresses are sent after a random delay
+func (p *NDPProxy) advertise(r ProxyRule, ns *ICMPNeighborSolicitation, md *Metadata) {
+	dad := md.Source.IsUnspecified()
+	na := &ICMPNeighborAdvertisement{
+		Router:    p.Router,
+		Solicited: !dad,
+		// proxies don't override the node itself, should it answer too,
+		// nor do nodes sharing an anycast address override each other
+		TargetAddress: ns.TargetAddress.To16(),
+	}
+	lla := r.LinkLayerAddress
+	if lla == nil {
+		lla = p.c.linkLayerAddr()
+	}
+	if lla != nil {
+		na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	dst := md.Source
+	out := &Metadata{}
+	if dad {
+		dst = net.IPv6linklocalallnodes
+	} else if lla := sourceLinkLayerAddr(ns.Options); lla != nil {
+		out.DestinationLinkLayerAddress = lla
+	} else {
+		out.DestinationLinkLayerAddress = md.SourceLinkLayerAddress
+	}
+
+	if !r.Anycast {
+		p.send(na, out, dst, md)
+		return
+	}
+
+	delay := time.Duration(p.rand() * float64(MaxAnycastDelayTime))
+	go func() {
+		<-p.after(delay)
+		p.send(na, out, dst, md)
+	}()
+}
+
+// send sends na with out to dst, answering a solicitation received with md
+func (p *NDPProxy) send(na *ICMPNeighborAdvertisement, out *Metadata, dst net.IP, md *Metadata) {
+	if err := p.c.WriteTo(na, out, dst); err != nil {
+		p.c.logger().Debug("failed to proxy advertisement", "target", na.TargetAddress.String(), "err", err)
+		return
+	}
+	if p.Proxied != nil {
+		p.Proxied(na.TargetAddress, md)
+	}
+}
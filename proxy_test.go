@@ -0,0 +1,202 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// readAdvertisement reads the next neighbor advertisement from c, failing
+// when none arrives within a second
+func readAdvertisement(t *testing.T, c *Conn) (*ICMPNeighborAdvertisement, *Metadata) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, md, err := c.ReadMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	na, ok := m.(*ICMPNeighborAdvertisement)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+
+	return na, md
+}
+
+func TestNDPProxy(t *testing.T) {
+	if _, err := NewNDPProxy(nil, ProxyRule{}); err != errNoProxyPrefix {
+		t.Errorf("unexpected error %v", err)
+	}
+	_, v4, _ := net.ParseCIDR("192.0.2.0/24")
+	if _, err := NewNDPProxy(nil, ProxyRule{Prefix: v4}); err == nil {
+		t.Error("expected error for IPv4 prefix")
+	}
+
+	router, c := Pipe()
+	defer router.Close()
+	defer c.Close()
+
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	p, err := NewNDPProxy(c, ProxyRule{Prefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Router = true
+	var proxied []net.IP
+	p.Proxied = func(target net.IP, md *Metadata) { proxied = append(proxied, target) }
+
+	target := net.ParseIP("2001:db8::42")
+	md := &Metadata{Source: router.Addr(), HopLimit: 255}
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: target}, md)
+	na, nmd := readAdvertisement(t, router)
+	if !na.TargetAddress.Equal(target) || !na.Solicited || na.Override || !na.Router || !nmd.Destination.Equal(router.Addr()) {
+		t.Errorf("unexpected advertisement %s to %s", na, nmd.Destination)
+	}
+	if len(na.Options) != 1 || na.Options[0].(*ICMPOptionTargetLinkLayerAddress).LinkLayerAddress.String() != c.Interface().HardwareAddr.String() {
+		t.Errorf("unexpected options %v", na.Options)
+	}
+	if len(proxied) != 1 || !proxied[0].Equal(target) {
+		t.Errorf("unexpected proxied %v", proxied)
+	}
+
+	// outside of the rules nothing happens
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8:1::42")}, md)
+	if len(proxied) != 1 {
+		t.Errorf("unexpected proxied %v", proxied)
+	}
+
+	// duplicate address detection is answered to all nodes
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: target}, &Metadata{Source: net.IPv6unspecified, HopLimit: 255})
+	na, nmd = readAdvertisement(t, router)
+	if na.Solicited || !nmd.Destination.Equal(net.IPv6linklocalallnodes) {
+		t.Errorf("unexpected advertisement %s to %s", na, nmd.Destination)
+	}
+}
+
+func TestNDPProxyDownstream(t *testing.T) {
+	router, up := Pipe()
+	defer router.Close()
+	defer up.Close()
+	down, host := Pipe()
+	defer down.Close()
+	defer host.Close()
+
+	nc := NewNeighborCache(down)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- nc.Serve(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-errc
+	}()
+
+	// the host only answers for its own address
+	target := net.ParseIP("2001:db8::42")
+	go func() {
+		for {
+			m, md, err := host.ReadFrom()
+			if err != nil {
+				return
+			}
+			if ns, ok := m.(*ICMPNeighborSolicitation); ok && ns.TargetAddress.Equal(target) {
+				na, _ := host.neighborAdvertisement(target, true)
+				host.WriteTo(na, nil, md.Source)
+			}
+		}
+	}()
+
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	_, wide, _ := net.ParseCIDR("2001:db8::/48")
+	p, err := NewNDPProxy(up, ProxyRule{Prefix: wide}, ProxyRule{Prefix: prefix, Downstream: nc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Timeout = 50 * time.Millisecond
+	proxied := make(chan net.IP, 4)
+	p.Proxied = func(target net.IP, md *Metadata) { proxied <- target }
+
+	md := &Metadata{Source: router.Addr(), HopLimit: 255}
+	// the most specific rule has the target resolved first
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8::43")}, md)
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: target}, md)
+	if na, _ := readAdvertisement(t, router); !na.TargetAddress.Equal(target) {
+		t.Errorf("unexpected advertisement %s", na)
+	}
+	// once reachable, it's answered for right away
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: target}, md)
+	if na, _ := readAdvertisement(t, router); !na.TargetAddress.Equal(target) {
+		t.Errorf("unexpected advertisement %s", na)
+	}
+
+	time.Sleep(2 * p.Timeout)
+	if len(proxied) != 2 {
+		t.Errorf("expected only the host to be proxied, not %d", len(proxied))
+	}
+}
+
+func TestNDPProxyAnycast(t *testing.T) {
+	router, c := Pipe()
+	defer router.Close()
+	defer c.Close()
+
+	_, prefix, _ := net.ParseCIDR("2001:db8::53/128")
+	p, err := NewNDPProxy(c, ProxyRule{Prefix: prefix, Anycast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.rand = func() float64 { return 0.5 }
+	delays := make(chan time.Duration, 1)
+	release := make(chan time.Time)
+	p.after = func(d time.Duration) <-chan time.Time {
+		delays <- d
+		return release
+	}
+
+	md := &Metadata{Source: router.Addr(), HopLimit: 255}
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8::53")}, md)
+	if d := <-delays; d != MaxAnycastDelayTime/2 {
+		t.Errorf("unexpected delay %s", d)
+	}
+	close(release)
+	if na, _ := readAdvertisement(t, router); na.Override || !na.Solicited {
+		t.Errorf("unexpected advertisement %s", na)
+	}
+}
+
+func TestNDPProxyLooped(t *testing.T) {
+	router, up := Pipe()
+	defer router.Close()
+	defer up.Close()
+	down, host := Pipe()
+	defer down.Close()
+	defer host.Close()
+
+	_, prefix, _ := net.ParseCIDR("2001:db8::/64")
+	p, err := NewNDPProxy(up, ProxyRule{Prefix: prefix, Downstream: NewNeighborCache(down)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var looped []net.IP
+	p.Looped = func(target net.IP, md *Metadata) { looped = append(looped, target) }
+
+	// our own downstream solicitation coming back in upstream, either by its
+	// link-layer or IPv6 source
+	target := net.ParseIP("2001:db8::42")
+	ns := &ICMPNeighborSolicitation{TargetAddress: target}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: down.Interface().HardwareAddr})
+	p.ServeNDP(ns, &Metadata{Source: router.Addr(), HopLimit: 255})
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: target}, &Metadata{Source: up.Addr(), HopLimit: 255})
+	if len(looped) != 2 {
+		t.Errorf("expected 2 looped solicitations, not %d", len(looped))
+	}
+	p.mu.Lock()
+	pending := len(p.pending)
+	p.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("unexpected resolving of looped solicitations")
+	}
+}
@@ -0,0 +1,203 @@
+package ndp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProxyConfig is the configuration of the NDPProxy of an upstream interface
+type ProxyConfig struct {
+	Router bool
+	// Timeout is that of NDPProxy, or its default if 0
+	Timeout time.Duration
+	Rules   []ProxyRule
+}
+
+// Downstreams returns the names of the interfaces the rules resolve targets
+// on, which need a serving NeighborCache each
+func (cfg ProxyConfig) Downstreams() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range cfg.Rules {
+		if r.Interface != "" && !seen[r.Interface] {
+			seen[r.Interface] = true
+			names = append(names, r.Interface)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewNDPProxyConfig returns an NDPProxy for c configured by cfg, resolving
+// the targets of rules naming an interface with its NeighborCache in
+// downstream
+func NewNDPProxyConfig(c *Conn, cfg ProxyConfig, downstream map[string]*NeighborCache) (*NDPProxy, error) {
+	rules := make([]ProxyRule, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		if r.Interface != "" && r.Downstream == nil {
+			r.Downstream = downstream[r.Interface]
+		}
+		rules[i] = r
+	}
+
+	p, err := NewNDPProxy(c, rules...)
+	if err != nil {
+		return nil, err
+	}
+	p.Router = cfg.Router
+	if cfg.Timeout > 0 {
+		p.Timeout = cfg.Timeout
+	}
+
+	return p, nil
+}
+
+// LoadProxyConfig reads the proxy configuration file at path, see
+// ParseProxyConfig
+func LoadProxyConfig(path string) (map[string]ProxyConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseProxyConfig(f)
+}
+
+// ParseProxyConfig reads proxy configuration from r and returns the
+// ProxyConfig of every upstream interface, keyed by name. The syntax is
+// that of radvd.conf(5), with rules in the spirit of ndppd.conf(5):
+//
+//	proxy eth0 {
+//		router on;
+//		timeout 500;
+//		allow 2001:db8::/64 {
+//			iface eth1;
+//		};
+//		allow 2001:db8::7 {
+//			mac 02:00:00:00:00:07;
+//		};
+//		allow 2001:db8::53 {
+//			anycast on;
+//		};
+//		deny 2001:db8::/120;
+//	};
+//
+// Allow answers for a prefix or address, resolving targets on iface first
+// when set and advertising mac instead of the address of the interface when
+// set. Anycast addresses are answered after a random delay. Deny leaves a prefix or address unanswered. The most specific rule
+// applies to every target, and timeout is in milliseconds
+func ParseProxyConfig(r io.Reader) (map[string]ProxyConfig, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &radvdParser{toks: radvdTokenize(string(b))}
+	cfgs := make(map[string]ProxyConfig)
+	for !p.done() {
+		name, cfg, err := parseProxy(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := cfgs[name]; ok {
+			return nil, fmt.Errorf("interface %s configured more than once", name)
+		}
+		cfgs[name] = cfg
+	}
+
+	return cfgs, nil
+}
+
+func parseProxy(p *radvdParser) (string, ProxyConfig, error) {
+	var cfg ProxyConfig
+	if err := p.expect("proxy"); err != nil {
+		return "", cfg, err
+	}
+	name, err := p.next()
+	if err != nil {
+		return "", cfg, err
+	}
+
+	seen := make(map[string]bool)
+	err = p.block(func(opt string) error {
+		switch opt {
+		case "router":
+			return p.flag(opt, &cfg.Router)
+		case "timeout":
+			return p.milliseconds(opt, &cfg.Timeout)
+		case "allow", "deny":
+			r, err := parseProxyRule(p, opt == "deny")
+			if err != nil {
+				return err
+			}
+			key := r.Prefix.String()
+			if seen[key] {
+				return p.errorf("more than one rule for %s", key)
+			}
+			seen[key] = true
+			cfg.Rules = append(cfg.Rules, r)
+			return nil
+		}
+
+		return p.errorf("unknown proxy option %s", opt)
+	})
+	if err != nil {
+		return "", cfg, err
+	}
+
+	return name, cfg, nil
+}
+
+func parseProxyRule(p *radvdParser, deny bool) (ProxyRule, error) {
+	r := ProxyRule{Deny: deny}
+	s, err := p.next()
+	if err != nil {
+		return r, err
+	}
+	if !strings.Contains(s, "/") {
+		s += "/128"
+	}
+	ip, prefix, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() != nil {
+		return r, p.errorf("invalid prefix %q", s)
+	}
+	r.Prefix = prefix
+
+	if deny {
+		if p.peek() != ";" {
+			return r, p.errorf("deny takes no options")
+		}
+		return r, p.expect(";")
+	}
+
+	err = p.block(func(opt string) error {
+		switch opt {
+		case "iface":
+			v, err := p.value(opt)
+			r.Interface = v
+			return err
+		case "anycast":
+			return p.flag(opt, &r.Anycast)
+		case "mac":
+			v, err := p.value(opt)
+			if err != nil {
+				return err
+			}
+			if r.LinkLayerAddress, err = net.ParseMAC(v); err != nil {
+				return p.errorf("invalid mac %q", v)
+			}
+			return nil
+		}
+
+		return p.errorf("unknown rule option %s", opt)
+	})
+
+	return r, err
+}
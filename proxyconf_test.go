@@ -0,0 +1,119 @@
+package ndp
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProxyConfig(t *testing.T) {
+	cfgs, err := ParseProxyConfig(strings.NewReader(`
+# routed /64 of the hosting provider
+proxy eth0 {
+	router on;
+	timeout 500;
+	allow 2001:db8::/64 {
+		iface eth1;
+	};
+	allow 2001:db8::7 {
+		mac 02:00:00:00:00:07;
+	};
+	allow 2001:db8::53 {
+		anycast on;
+	};
+	deny 2001:db8::/120;
+};
+
+proxy eth2 {
+	allow 2001:db8:1::/64;
+};
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cidr := func(s string) *net.IPNet {
+		_, n, _ := net.ParseCIDR(s)
+		return n
+	}
+	mac, _ := net.ParseMAC("02:00:00:00:00:07")
+	expected := map[string]ProxyConfig{
+		"eth0": {
+			Router:  true,
+			Timeout: 500 * time.Millisecond,
+			Rules: []ProxyRule{
+				{Prefix: cidr("2001:db8::/64"), Interface: "eth1"},
+				{Prefix: cidr("2001:db8::7/128"), LinkLayerAddress: mac},
+				{Prefix: cidr("2001:db8::53/128"), Anycast: true},
+				{Prefix: cidr("2001:db8::/120"), Deny: true},
+			},
+		},
+		"eth2": {
+			Rules: []ProxyRule{{Prefix: cidr("2001:db8:1::/64")}},
+		},
+	}
+	if !reflect.DeepEqual(cfgs, expected) {
+		t.Errorf("unexpected configs %+v", cfgs)
+	}
+	if d := cfgs["eth0"].Downstreams(); len(d) != 1 || d[0] != "eth1" {
+		t.Errorf("unexpected downstreams %v", d)
+	}
+
+	for _, bad := range []string{
+		"proxy eth0 { allow 192.0.2.0/24; };",
+		"proxy eth0 { deny 2001:db8::1 { mac 02:00:00:00:00:07; }; };",
+		"proxy eth0 { allow 2001:db8::1 { mac nope; }; };",
+		"proxy eth0 { allow 2001:db8::1 { bogus 1; }; };",
+		"proxy eth0 { allow 2001:db8::/64; deny 2001:db8::/64; };",
+		"proxy eth0 { bogus; };",
+		"proxy eth0 { }; proxy eth0 { };",
+	} {
+		if _, err := ParseProxyConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestNewNDPProxyConfig(t *testing.T) {
+	router, c := Pipe()
+	defer router.Close()
+	defer c.Close()
+
+	cfg, err := ParseProxyConfig(strings.NewReader(`proxy pipe1 {
+	router on;
+	allow 2001:db8::/64 { iface eth1; };
+	allow 2001:db8::7 { mac 02:00:00:00:00:07; };
+	deny 2001:db8::/120;
+};`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNDPProxyConfig(c, cfg["pipe1"], nil); err == nil {
+		t.Error("expected error for missing neighbor cache")
+	}
+
+	p, err := NewNDPProxyConfig(c, cfg["pipe1"], map[string]*NeighborCache{"eth1": NewNeighborCache(c)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Router || p.Timeout != MaxMulticastSolicit*RetransTimer {
+		t.Errorf("unexpected proxy %+v", p)
+	}
+	var proxied []net.IP
+	p.Proxied = func(target net.IP, md *Metadata) { proxied = append(proxied, target) }
+
+	// the address carved out of the denied prefix is answered with its
+	// own link-layer address
+	md := &Metadata{Source: router.Addr(), HopLimit: 255}
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8::1")}, md)
+	p.ServeNDP(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("2001:db8::7")}, md)
+	na, _ := readAdvertisement(t, router)
+	if !na.TargetAddress.Equal(net.ParseIP("2001:db8::7")) || na.Options[0].(*ICMPOptionTargetLinkLayerAddress).LinkLayerAddress.String() != "02:00:00:00:00:07" {
+		t.Errorf("unexpected advertisement %s", na)
+	}
+	if len(proxied) != 1 {
+		t.Errorf("unexpected proxied %v", proxied)
+	}
+}
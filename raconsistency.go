@@ -0,0 +1,88 @@
+package ndp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// RAInconsistency describes a field of a router advertisement from another
+// router that differs from what we advertise, as described at
+// https://tools.ietf.org/html/rfc4861#section-6.2.7
+type RAInconsistency struct {
+	// Source is the address of the other router
+	Source net.IP
+	Field  string
+	Ours   string
+	Theirs string
+}
+
+func (i RAInconsistency) String() string {
+	return fmt.Sprintf("router %s advertises %s %s, we advertise %s", i.Source, i.Field, i.Theirs, i.Ours)
+}
+
+// Inconsistencies returns the fields in which ra, received from another
+// router on the link, is inconsistent with this RAConfig. Unspecified values
+// on either side are not compared
+func (cfg RAConfig) Inconsistencies(ra *ICMPRouterAdvertisement) []RAInconsistency {
+	var issues []RAInconsistency
+	add := func(field string, ours, theirs interface{}) {
+		issues = append(issues, RAInconsistency{
+			Field:  field,
+			Ours:   fmt.Sprint(ours),
+			Theirs: fmt.Sprint(theirs),
+		})
+	}
+
+	if cfg.HopLimit != 0 && ra.HopLimit != 0 && cfg.HopLimit != ra.HopLimit {
+		add("hop limit", cfg.HopLimit, ra.HopLimit)
+	}
+	if cfg.Managed != ra.ManagedAddress {
+		add("managed flag", cfg.Managed, ra.ManagedAddress)
+	}
+	if cfg.Other != ra.OtherStateful {
+		add("other config flag", cfg.Other, ra.OtherStateful)
+	}
+	theirs := time.Duration(ra.ReachableTime) * time.Millisecond
+	if cfg.ReachableTime != 0 && theirs != 0 && cfg.ReachableTime != theirs {
+		add("reachable time", cfg.ReachableTime, theirs)
+	}
+	theirs = time.Duration(ra.RetransTimer) * time.Millisecond
+	if cfg.RetransTimer != 0 && theirs != 0 && cfg.RetransTimer != theirs {
+		add("retrans timer", cfg.RetransTimer, theirs)
+	}
+
+	prefixes := make(map[string]RAPrefix, len(cfg.Prefixes))
+	for _, p := range cfg.Prefixes {
+		prefixes[p.Prefix.String()] = p
+	}
+
+	for _, o := range ra.Options {
+		switch o := o.(type) {
+		case *ICMPOptionMTU:
+			if cfg.MTU != 0 && cfg.MTU != o.MTU {
+				add("mtu", cfg.MTU, o.MTU)
+			}
+		case *ICMPOptionPrefixInformation:
+			prefix := &net.IPNet{
+				IP:   o.Prefix,
+				Mask: net.CIDRMask(int(o.PrefixLength), 128),
+			}
+			p, ok := prefixes[prefix.String()]
+			if !ok {
+				continue
+			}
+			// compare our lifetimes the way they're sent
+			ours := lifetimeToDuration(durationToLifetime(p.ValidLifetime))
+			if d := o.ValidLifetimeDuration(); ours != d {
+				add(fmt.Sprintf("valid lifetime of %s", prefix), ours, d)
+			}
+			ours = lifetimeToDuration(durationToLifetime(p.PreferredLifetime))
+			if d := o.PreferredLifetimeDuration(); ours != d {
+				add(fmt.Sprintf("preferred lifetime of %s", prefix), ours, d)
+			}
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,60 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRAConfigInconsistencies(t *testing.T) {
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+	cfg := DefaultRAConfig()
+	cfg.MTU = 1500
+	cfg.ReachableTime = 30 * time.Second
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(p1)}
+
+	// consistent with ourselves
+	if issues := cfg.Inconsistencies(cfg.Advertisement()); len(issues) != 0 {
+		t.Errorf("unexpected inconsistencies %v", issues)
+	}
+
+	// unspecified values and other prefixes are fine
+	other := cfg
+	other.HopLimit = 0
+	other.MTU = 0
+	other.ReachableTime = 0
+	other.RetransTimer = time.Second
+	other.RouterLifetime = 0
+	other.Prefixes = []RAPrefix{NewRAPrefix(p2)}
+	if issues := cfg.Inconsistencies(other.Advertisement()); len(issues) != 0 {
+		t.Errorf("unexpected inconsistencies %v", issues)
+	}
+
+	other = cfg
+	other.HopLimit = 255
+	other.Managed = true
+	other.MTU = 9000
+	other.ReachableTime = time.Minute
+	p := NewRAPrefix(p1)
+	p.PreferredLifetime = time.Hour
+	other.Prefixes = []RAPrefix{p}
+
+	expected := []string{
+		"hop limit 255, we advertise 64",
+		"managed flag true, we advertise false",
+		"reachable time 1m0s, we advertise 30s",
+		"mtu 9000, we advertise 1500",
+		"preferred lifetime of 2001:db8:1::/64 1h0m0s, we advertise 168h0m0s",
+	}
+	issues := cfg.Inconsistencies(other.Advertisement())
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d inconsistencies, not %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		issue.Source = net.ParseIP("fe80::1")
+		if e := "router fe80::1 advertises " + expected[i]; issue.String() != e {
+			t.Errorf("expected %q, not %q", e, issue)
+		}
+	}
+}
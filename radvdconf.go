@@ -0,0 +1,566 @@
+package ndp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// radvdMatching lists the radvd options that RAServer can only behave like
+// with one value, which is then ignored. Others are rejected. An empty value
+// ignores options that have no effect on RAServer at all
+var radvdMatching = map[string]string{
+	"IgnoreIfMissing":      "off",
+	"UnicastOnly":          "off",
+	"AdvSourceLLAddress":   "on",
+	"AdvIntervalOpt":       "off",
+	"AdvHomeAgentFlag":     "off",
+	"AdvHomeAgentInfo":     "off",
+	"AdvMobRtrSupportFlag": "off",
+	"AdvRouterAddr":        "off",
+	"DeprecatePrefix":      "off",
+	"DecrementLifetimes":   "off",
+	// final advertisements withdraw the router and its DNS options
+	"RemoveAdvOnExit": "on",
+	"FlushRDNSS":      "on",
+	"FlushDNSSL":      "on",
+	// only used with AdvHomeAgentInfo
+	"HomeAgentLifetime":   "",
+	"HomeAgentPreference": "",
+	// only used with prefixes derived from the interface
+	"autoignoreprefixes": "",
+}
+
+// radvdUnsupported lists the radvd options that change advertisements in
+// ways RAServer doesn't support
+var radvdUnsupported = map[string]bool{
+	"AdvRASrcAddress":     true,
+	"AdvCaptivePortalAPI": true,
+	"route":               true,
+	"clients":             true,
+	"abro":                true,
+	"lowpanco":            true,
+}
+
+// LoadRadvdConfig reads the radvd configuration file at path, see
+// ParseRadvdConfig
+func LoadRadvdConfig(path string) (map[string]RAConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRadvdConfig(f)
+}
+
+// ParseRadvdConfig reads configuration in radvd.conf(5) syntax from r and
+// returns the RAConfig for every interface that has AdvSendAdvert enabled,
+// keyed by interface name. Defaults that differ between radvd and
+// DefaultRAConfig, like the minimum interval and prefix lifetimes, follow
+// radvd. Options that RAServer doesn't support, like routes and Mobile IPv6
+// settings, are rejected unless they're set to how RAServer behaves anyway
+func ParseRadvdConfig(r io.Reader) (map[string]RAConfig, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &radvdParser{toks: radvdTokenize(string(b))}
+	cfgs := make(map[string]RAConfig)
+	seen := make(map[string]bool)
+	for !p.done() {
+		name, cfg, advertise, err := p.parseInterface()
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("interface %s configured more than once", name)
+		}
+		seen[name] = true
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("interface %s: %s", name, err)
+		}
+		if advertise {
+			cfgs[name] = cfg
+		}
+	}
+
+	return cfgs, nil
+}
+
+// radvdToken is a word or one of the characters {, } and ; together with
+// the line it was found on
+type radvdToken struct {
+	s    string
+	line int
+}
+
+func radvdTokenize(s string) []radvdToken {
+	var toks []radvdToken
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';':
+			toks = append(toks, radvdToken{s: string(c), line: line})
+			i++
+		case c == '"':
+			j := strings.IndexAny(s[i+1:], "\"\n")
+			if j < 0 {
+				j = len(s) - i - 1
+			}
+			toks = append(toks, radvdToken{s: s[i+1 : i+1+j], line: line})
+			i += j + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n#{};\"", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, radvdToken{s: s[i:j], line: line})
+			i = j
+		}
+	}
+
+	return toks
+}
+
+type radvdParser struct {
+	toks []radvdToken
+	pos  int
+}
+
+func (p *radvdParser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *radvdParser) errorf(format string, a ...interface{}) error {
+	line := 0
+	if len(p.toks) > 0 {
+		line = p.toks[len(p.toks)-1].line
+		if !p.done() {
+			line = p.toks[p.pos].line
+		}
+	}
+
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, a...))
+}
+
+func (p *radvdParser) next() (string, error) {
+	if p.done() {
+		return "", p.errorf("unexpected end of configuration")
+	}
+	p.pos++
+
+	return p.toks[p.pos-1].s, nil
+}
+
+func (p *radvdParser) peek() string {
+	if p.done() {
+		return ""
+	}
+
+	return p.toks[p.pos].s
+}
+
+func (p *radvdParser) expect(s string) error {
+	if p.done() {
+		return p.errorf("unexpected end of configuration")
+	}
+	if p.peek() != s {
+		return p.errorf("expected %q, not %q", s, p.peek())
+	}
+	p.pos++
+
+	return nil
+}
+
+// args returns the words up to the next { or ;
+func (p *radvdParser) args() []string {
+	var args []string
+	for !p.done() && p.peek() != "{" && p.peek() != ";" && p.peek() != "}" {
+		args = append(args, p.toks[p.pos].s)
+		p.pos++
+	}
+
+	return args
+}
+
+// value returns the single argument of an option terminated by ;
+func (p *radvdParser) value(name string) (string, error) {
+	args := p.args()
+	if len(args) != 1 {
+		return "", p.errorf("%s takes a single value", name)
+	}
+
+	return args[0], p.expect(";")
+}
+
+// skip discards the rest of a statement including its block
+func (p *radvdParser) skip() error {
+	p.args()
+	if p.peek() == "{" {
+		depth := 0
+		for {
+			s, err := p.next()
+			if err != nil {
+				return err
+			}
+			if s == "{" {
+				depth++
+			} else if s == "}" {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	return p.expect(";")
+}
+
+// matching discards an option that must have value v, or any value if v is
+// empty
+func (p *radvdParser) matching(name, v string) error {
+	if v == "" {
+		return p.skip()
+	}
+
+	s, err := p.value(name)
+	if err != nil {
+		return err
+	}
+	if s != v {
+		return p.errorf("unsupported %s %s", name, s)
+	}
+
+	return nil
+}
+
+// block calls fn for every option in a block until its closing }
+func (p *radvdParser) block(fn func(name string) error) error {
+	if p.peek() == ";" {
+		// blocks are optional for prefixes and DNS options
+		p.pos++
+		return nil
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for p.peek() != "}" {
+		name, err := p.next()
+		if err != nil {
+			return err
+		}
+		if v, ok := radvdMatching[name]; ok {
+			if err := p.matching(name, v); err != nil {
+				return err
+			}
+			continue
+		}
+		if radvdUnsupported[name] {
+			return p.errorf("unsupported option %s", name)
+		}
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	p.pos++
+
+	return p.expect(";")
+}
+
+func (p *radvdParser) parseInterface() (string, RAConfig, bool, error) {
+	cfg := DefaultRAConfig()
+	// radvd answers solicitations with unicast advertisements by default
+	cfg.UnicastSolicited = true
+	if err := p.expect("interface"); err != nil {
+		return "", cfg, false, err
+	}
+	name, err := p.next()
+	if err != nil {
+		return "", cfg, false, err
+	}
+
+	var (
+		advertise           bool
+		minSet, lifetimeSet bool
+		rdnssSet, dnsslSet  bool
+		pref64Set           bool
+	)
+	err = p.block(func(opt string) error {
+		switch opt {
+		case "prefix":
+			return p.parsePrefix(&cfg)
+		case "RDNSS":
+			servers := p.args()
+			for _, s := range servers {
+				ip := net.ParseIP(s)
+				if ip == nil || ip.To4() != nil {
+					return p.errorf("invalid RDNSS address %q", s)
+				}
+				cfg.RDNSS = append(cfg.RDNSS, ip)
+			}
+			return p.block(func(opt string) error {
+				if opt != "AdvRDNSSLifetime" {
+					return p.errorf("unknown RDNSS option %s", opt)
+				}
+				d, err := p.lifetime(opt)
+				if err != nil {
+					return err
+				}
+				if rdnssSet && d != cfg.RDNSSLifetime {
+					return p.errorf("RDNSS lifetimes differ")
+				}
+				cfg.RDNSSLifetime, rdnssSet = d, true
+				return nil
+			})
+		case "DNSSL":
+			cfg.DNSSL = append(cfg.DNSSL, p.args()...)
+			return p.block(func(opt string) error {
+				if opt != "AdvDNSSLLifetime" {
+					return p.errorf("unknown DNSSL option %s", opt)
+				}
+				d, err := p.lifetime(opt)
+				if err != nil {
+					return err
+				}
+				if dnsslSet && d != cfg.DNSSLLifetime {
+					return p.errorf("DNSSL lifetimes differ")
+				}
+				cfg.DNSSLLifetime, dnsslSet = d, true
+				return nil
+			})
+		case "nat64prefix":
+			if cfg.PREF64 != nil {
+				return p.errorf("more than one nat64prefix")
+			}
+			return p.parseNAT64Prefix(&cfg, &pref64Set)
+		case "AdvSendAdvert":
+			return p.flag(opt, &advertise)
+		case "AdvManagedFlag":
+			return p.flag(opt, &cfg.Managed)
+		case "AdvRASolicitedUnicast":
+			return p.flag(opt, &cfg.UnicastSolicited)
+		case "AdvOtherConfigFlag":
+			return p.flag(opt, &cfg.Other)
+		case "MaxRtrAdvInterval":
+			return p.seconds(opt, &cfg.MaxInterval)
+		case "MinRtrAdvInterval":
+			minSet = true
+			return p.seconds(opt, &cfg.MinInterval)
+		case "AdvDefaultLifetime":
+			lifetimeSet = true
+			return p.seconds(opt, &cfg.RouterLifetime)
+		case "AdvReachableTime":
+			return p.milliseconds(opt, &cfg.ReachableTime)
+		case "AdvRetransTimer":
+			return p.milliseconds(opt, &cfg.RetransTimer)
+		case "AdvCurHopLimit":
+			n, err := p.number(opt, 8)
+			cfg.HopLimit = uint8(n)
+			return err
+		case "AdvLinkMTU":
+			n, err := p.number(opt, 32)
+			cfg.MTU = uint32(n)
+			return err
+		case "AdvDefaultPreference":
+			v, err := p.value(opt)
+			if err != nil {
+				return err
+			}
+			switch v {
+			case "low":
+				cfg.Preference = RouterPreferenceLow
+			case "medium":
+				cfg.Preference = RouterPreferenceMedium
+			case "high":
+				cfg.Preference = RouterPreferenceHigh
+			default:
+				return p.errorf("invalid %s %q", opt, v)
+			}
+			return nil
+		}
+
+		return p.errorf("unknown interface option %s", opt)
+	})
+	if err != nil {
+		return "", cfg, false, err
+	}
+
+	// derived defaults as documented in radvd.conf(5)
+	if !minSet {
+		cfg.MinInterval = cfg.MaxInterval * 33 / 100
+		if cfg.MaxInterval < 9*time.Second {
+			cfg.MinInterval = cfg.MaxInterval * 3 / 4
+		}
+	}
+	if !lifetimeSet {
+		cfg.RouterLifetime = 3 * cfg.MaxInterval
+		if cfg.RouterLifetime < time.Second {
+			cfg.RouterLifetime = time.Second
+		}
+	}
+	if !rdnssSet {
+		cfg.RDNSSLifetime = 2 * cfg.MaxInterval
+	}
+	if !dnsslSet {
+		cfg.DNSSLLifetime = 2 * cfg.MaxInterval
+	}
+	if cfg.PREF64 != nil && !pref64Set {
+		cfg.PREF64Lifetime = 3 * cfg.MaxInterval
+		if cfg.PREF64Lifetime > MaxPREF64Lifetime {
+			cfg.PREF64Lifetime = MaxPREF64Lifetime
+		}
+	}
+
+	return name, cfg, advertise, nil
+}
+
+func (p *radvdParser) parsePrefix(cfg *RAConfig) error {
+	s, err := p.next()
+	if err != nil {
+		return err
+	}
+	ip, prefix, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() != nil {
+		return p.errorf("invalid prefix %q", s)
+	}
+	if ip.IsUnspecified() {
+		return p.errorf("prefixes derived from the interface are not supported")
+	}
+
+	pfx := NewRAPrefix(prefix)
+	pfx.ValidLifetime = 86400 * time.Second
+	pfx.PreferredLifetime = 14400 * time.Second
+	err = p.block(func(opt string) error {
+		switch opt {
+		case "AdvOnLink":
+			return p.flag(opt, &pfx.OnLink)
+		case "AdvAutonomous":
+			return p.flag(opt, &pfx.Autonomous)
+		case "AdvValidLifetime":
+			d, err := p.lifetime(opt)
+			pfx.ValidLifetime = d
+			return err
+		case "AdvPreferredLifetime":
+			d, err := p.lifetime(opt)
+			pfx.PreferredLifetime = d
+			return err
+		}
+
+		return p.errorf("unknown prefix option %s", opt)
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Prefixes = append(cfg.Prefixes, pfx)
+
+	return nil
+}
+
+// parseNAT64Prefix parses a nat64prefix into cfg, setting lifetimeSet if it
+// has its lifetime configured
+func (p *radvdParser) parseNAT64Prefix(cfg *RAConfig, lifetimeSet *bool) error {
+	s, err := p.next()
+	if err != nil {
+		return err
+	}
+	ip, prefix, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() != nil {
+		return p.errorf("invalid nat64prefix %q", s)
+	}
+	if err := validPREF64(prefix); err != nil {
+		return p.errorf("%s", err)
+	}
+	cfg.PREF64 = prefix
+
+	return p.block(func(opt string) error {
+		if opt != "AdvValidLifetime" {
+			return p.errorf("unknown nat64prefix option %s", opt)
+		}
+		*lifetimeSet = true
+		return p.seconds(opt, &cfg.PREF64Lifetime)
+	})
+}
+
+func (p *radvdParser) flag(name string, b *bool) error {
+	v, err := p.value(name)
+	if err != nil {
+		return err
+	}
+
+	switch v {
+	case "on":
+		*b = true
+	case "off":
+		*b = false
+	default:
+		return p.errorf("expected on or off for %s, not %q", name, v)
+	}
+
+	return nil
+}
+
+func (p *radvdParser) number(name string, bits int) (uint64, error) {
+	v, err := p.value(name)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseUint(v, 10, bits)
+	if err != nil {
+		return 0, p.errorf("invalid %s %q", name, v)
+	}
+
+	return n, nil
+}
+
+// seconds parses a possibly fractional number of seconds
+func (p *radvdParser) seconds(name string, d *time.Duration) error {
+	v, err := p.value(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return p.errorf("invalid %s %q", name, v)
+	}
+	*d = time.Duration(f * float64(time.Second))
+
+	return nil
+}
+
+func (p *radvdParser) milliseconds(name string, d *time.Duration) error {
+	n, err := p.number(name, 32)
+	*d = time.Duration(n) * time.Millisecond
+	return err
+}
+
+// lifetime parses a number of seconds or infinity
+func (p *radvdParser) lifetime(name string) (time.Duration, error) {
+	if p.peek() == "infinity" {
+		_, err := p.value(name)
+		return Infinity, err
+	}
+
+	n, err := p.number(name, 32)
+	return time.Duration(n) * time.Second, err
+}
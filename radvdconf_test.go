@@ -0,0 +1,128 @@
+package ndp
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRadvdConfig(t *testing.T) {
+	cfgs, err := ParseRadvdConfig(strings.NewReader(`
+# lan with everything
+interface eth0 {
+	AdvSendAdvert on;
+	IgnoreIfMissing off;
+	AdvSourceLLAddress on;
+	HomeAgentLifetime 60;
+	MaxRtrAdvInterval 30;
+	AdvManagedFlag on;
+	AdvOtherConfigFlag off;
+	AdvLinkMTU 1500;
+	AdvReachableTime 30000;
+	AdvRetransTimer 1000;
+	AdvCurHopLimit 255;
+	AdvDefaultPreference high;
+
+	prefix 2001:db8:1::/64 {
+		AdvOnLink on;
+		AdvAutonomous off;
+		AdvValidLifetime infinity;
+		AdvPreferredLifetime 3600;
+	};
+
+	prefix 2001:db8:2::/64 { };
+
+	RDNSS 2001:db8::53 2001:db8::54 {
+		AdvRDNSSLifetime 60;
+	};
+
+	DNSSL example.com example.org { };
+
+	nat64prefix 64:ff9b::/96 { };
+};
+
+interface "eth1" {
+	AdvSendAdvert on;
+	AdvRASolicitedUnicast off;
+	MinRtrAdvInterval 3;
+	MaxRtrAdvInterval 4;
+	AdvDefaultLifetime 0;
+};
+
+# not advertising
+interface eth2 {
+	AdvSendAdvert off;
+};
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfgs) != 2 {
+		t.Fatalf("expected 2 interfaces, not %d", len(cfgs))
+	}
+
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+	_, pref64, _ := net.ParseCIDR("64:ff9b::/96")
+	expected := RAConfig{
+		MinInterval:    9900 * time.Millisecond,
+		MaxInterval:    30 * time.Second,
+		Managed:        true,
+		HopLimit:       255,
+		RouterLifetime: 90 * time.Second,
+		Preference:     RouterPreferenceHigh,
+		ReachableTime:  30 * time.Second,
+		RetransTimer:   time.Second,
+		MTU:            1500,
+		Prefixes: []RAPrefix{
+			{Prefix: p1, OnLink: true, ValidLifetime: Infinity, PreferredLifetime: time.Hour},
+			{Prefix: p2, OnLink: true, Autonomous: true, ValidLifetime: 24 * time.Hour, PreferredLifetime: 4 * time.Hour},
+		},
+		RDNSS:            []net.IP{net.ParseIP("2001:db8::53"), net.ParseIP("2001:db8::54")},
+		RDNSSLifetime:    time.Minute,
+		DNSSL:            []string{"example.com", "example.org"},
+		DNSSLLifetime:    time.Minute,
+		UnicastSolicited: true,
+		PREF64:           pref64,
+		PREF64Lifetime:   90 * time.Second,
+	}
+	if !reflect.DeepEqual(cfgs["eth0"], expected) {
+		t.Errorf("expected config %+v, not %+v", expected, cfgs["eth0"])
+	}
+
+	cfg := cfgs["eth1"]
+	if cfg.MinInterval != 3*time.Second || cfg.MaxInterval != 4*time.Second || cfg.RouterLifetime != 0 || cfg.HopLimit != 64 || cfg.UnicastSolicited {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`interface eth0 { AdvSendAdvert on; }`, "line 1: unexpected end of configuration"},
+		{`interface eth0 { AdvSendAdvert yes; };`, `line 1: expected on or off for AdvSendAdvert, not "yes"`},
+		{"interface eth0 {\n\tAdvFoo on;\n};", "line 2: unknown interface option AdvFoo"},
+		{`interface eth0 { prefix 2001:db8::/64 { AdvFoo on; }; };`, "line 1: unknown prefix option AdvFoo"},
+		{"interface eth0 {\n\troute 2001:db8::/48 { };\n};", "line 2: unsupported option route"},
+		{`interface eth0 { UnicastOnly on; };`, "line 1: unsupported UnicastOnly on"},
+		{`interface eth0 { prefix 2001:db8::/64 { DeprecatePrefix on; }; };`, "line 1: unsupported DeprecatePrefix on"},
+		{`interface eth0 { prefix ::/64 { }; };`, "line 1: prefixes derived from the interface are not supported"},
+		{`interface eth0 { prefix 192.0.2.0/24 { }; };`, `line 1: invalid prefix "192.0.2.0/24"`},
+		{`interface eth0 { MaxRtrAdvInterval 1; };`, "interface eth0: max interval 1s not within 4s and 1800s"},
+		{`interface eth0 { }; interface eth0 { };`, "interface eth0 configured more than once"},
+		{`interface eth0 { nat64prefix 64:ff9b::/80 { }; };`, "line 1: pref64 64:ff9b::/80 has a length other than 96, 64, 56, 48, 40 or 32"},
+		{`interface eth0 { nat64prefix 64:ff9b::/96 { AdvValidLifetime 65536; }; };`, "interface eth0: pref64 lifetime 18h12m16s not within 0 and 18h12m8s"},
+		{`interface eth0 { nat64prefix 64:ff9b::/96 { }; nat64prefix 64:ff9b:1::/96 { }; };`, "line 1: more than one nat64prefix"},
+		{`interface eth0 { RDNSS 2001:db8::1 { AdvRDNSSLifetime 10; }; RDNSS 2001:db8::2 { AdvRDNSSLifetime 20; }; };`, "line 1: RDNSS lifetimes differ"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRadvdConfig(strings.NewReader(test.config))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error %q for %q, not %v", test.err, test.config, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ndp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteRadvdConfig writes ra, as received on interface ifname, to w in
+// radvd.conf(5) syntax like radvdump does, so a live advertisement can be
+// turned into configuration. Intervals aren't part of advertisements, so
+// MaxRtrAdvInterval is only written when the router lifetime requires a
+// shorter interval than the default. Routes and the home agent flag are
+// written as radvd has them, even though ParseRadvdConfig doesn't support
+// them
+func WriteRadvdConfig(w io.Writer, ifname string, ra *ICMPRouterAdvertisement) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "interface %s\n{\n", ifname)
+	fmt.Fprintf(bw, "\tAdvSendAdvert on;\n")
+	fmt.Fprintf(bw, "\t# Note: {Min,Max}RtrAdvInterval cannot be obtained from advertisements\n")
+	lifetime := time.Duration(ra.RouterLifeTime) * time.Second
+	if lifetime >= 4*time.Second && lifetime < DefaultRAConfig().MaxInterval {
+		fmt.Fprintf(bw, "\tMaxRtrAdvInterval %d;\n", ra.RouterLifeTime)
+	}
+	fmt.Fprintf(bw, "\tAdvManagedFlag %s;\n", radvdFlag(ra.ManagedAddress))
+	fmt.Fprintf(bw, "\tAdvOtherConfigFlag %s;\n", radvdFlag(ra.OtherStateful))
+	fmt.Fprintf(bw, "\tAdvReachableTime %d;\n", ra.ReachableTime)
+	fmt.Fprintf(bw, "\tAdvRetransTimer %d;\n", ra.RetransTimer)
+	fmt.Fprintf(bw, "\tAdvCurHopLimit %d;\n", ra.HopLimit)
+	fmt.Fprintf(bw, "\tAdvDefaultLifetime %d;\n", ra.RouterLifeTime)
+	fmt.Fprintf(bw, "\tAdvHomeAgentFlag %s;\n", radvdFlag(ra.HomeAgent))
+	fmt.Fprintf(bw, "\tAdvDefaultPreference %s;\n", ra.RouterPreference)
+	if o, _ := ra.GetOption(ICMPOptionTypeMTU); o != nil {
+		fmt.Fprintf(bw, "\tAdvLinkMTU %d;\n", (*o).(*ICMPOptionMTU).MTU)
+	}
+	fmt.Fprintf(bw, "\tAdvSourceLLAddress %s;\n", radvdFlag(ra.HasOption(ICMPOptionTypeSourceLinkLayerAddress)))
+
+	for _, o := range ra.Options {
+		switch o := o.(type) {
+		case *ICMPOptionPrefixInformation:
+			fmt.Fprintf(bw, "\n\tprefix %s/%d\n\t{\n", o.Prefix, o.PrefixLength)
+			fmt.Fprintf(bw, "\t\tAdvValidLifetime %s;\n", radvdLifetime(o.ValidLifetime))
+			fmt.Fprintf(bw, "\t\tAdvPreferredLifetime %s;\n", radvdLifetime(o.PreferredLifetime))
+			fmt.Fprintf(bw, "\t\tAdvOnLink %s;\n", radvdFlag(o.OnLink))
+			fmt.Fprintf(bw, "\t\tAdvAutonomous %s;\n", radvdFlag(o.Auto))
+			fmt.Fprintf(bw, "\t\tAdvRouterAddr %s;\n", radvdFlag(o.RouterAddress))
+			fmt.Fprintf(bw, "\t}; # End of prefix definition\n")
+		case *ICMPOptionRouteInformation:
+			fmt.Fprintf(bw, "\n\troute %s/%d\n\t{\n", o.Prefix, o.PrefixLength)
+			fmt.Fprintf(bw, "\t\tAdvRoutePreference %s;\n", o.Preference)
+			fmt.Fprintf(bw, "\t\tAdvRouteLifetime %s;\n", radvdLifetime(o.RouteLifetime))
+			fmt.Fprintf(bw, "\t}; # End of route definition\n")
+		case *ICMPOptionRecursiveDNSServer:
+			fmt.Fprintf(bw, "\n\tRDNSS")
+			for _, ip := range o.Servers {
+				fmt.Fprintf(bw, " %s", ip)
+			}
+			fmt.Fprintf(bw, "\n\t{\n\t\tAdvRDNSSLifetime %s;\n", radvdLifetime(o.Lifetime))
+			fmt.Fprintf(bw, "\t}; # End of RDNSS definition\n")
+		case *ICMPOptionDNSSearchList:
+			fmt.Fprintf(bw, "\n\tDNSSL")
+			for _, name := range o.DomainNames {
+				fmt.Fprintf(bw, " %s", name)
+			}
+			fmt.Fprintf(bw, "\n\t{\n\t\tAdvDNSSLLifetime %s;\n", radvdLifetime(o.Lifetime))
+			fmt.Fprintf(bw, "\t}; # End of DNSSL definition\n")
+		case *ICMPOptionPREF64:
+			fmt.Fprintf(bw, "\n\tnat64prefix %s/%d\n\t{\n", o.Prefix, o.PrefixLength)
+			fmt.Fprintf(bw, "\t\tAdvValidLifetime %d;\n", o.LifetimeDuration()/time.Second)
+			fmt.Fprintf(bw, "\t}; # End of nat64prefix definition\n")
+		}
+	}
+
+	fmt.Fprintf(bw, "}; # End of interface definition\n")
+
+	return bw.Flush()
+}
+
+func radvdFlag(b bool) string {
+	if b {
+		return "on"
+	}
+
+	return "off"
+}
+
+// radvdLifetime returns lifetime l in seconds as radvd.conf has it
+func radvdLifetime(l uint32) string {
+	if l == 0xffffffff {
+		return "infinity"
+	}
+
+	return fmt.Sprint(l)
+}
@@ -0,0 +1,123 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteRadvdConfig(t *testing.T) {
+	ra := &ICMPRouterAdvertisement{
+		HopLimit:         64,
+		ManagedAddress:   true,
+		RouterPreference: RouterPreferenceHigh,
+		RouterLifeTime:   1800,
+		ReachableTime:    30000,
+	}
+	ra.AddOption(&ICMPOptionMTU{MTU: 1500})
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}})
+	ra.AddOption(&ICMPOptionPrefixInformation{
+		PrefixLength:      64,
+		OnLink:            true,
+		Auto:              true,
+		ValidLifetime:     0xffffffff,
+		PreferredLifetime: 3600,
+		Prefix:            net.ParseIP("2001:db8:1::"),
+	})
+	ra.AddOption(&ICMPOptionRouteInformation{
+		PrefixLength:  48,
+		Preference:    RouterPreferenceLow,
+		RouteLifetime: 1800,
+		Prefix:        net.ParseIP("2001:db8::"),
+	})
+	ra.AddOption(&ICMPOptionRecursiveDNSServer{Lifetime: 60, Servers: []net.IP{net.ParseIP("2001:db8::53"), net.ParseIP("2001:db8::54")}})
+	ra.AddOption(&ICMPOptionDNSSearchList{Lifetime: 60, DomainNames: []string{"example.com"}})
+
+	var b bytes.Buffer
+	if err := WriteRadvdConfig(&b, "eth0", ra); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `interface eth0
+{
+	AdvSendAdvert on;
+	# Note: {Min,Max}RtrAdvInterval cannot be obtained from advertisements
+	AdvManagedFlag on;
+	AdvOtherConfigFlag off;
+	AdvReachableTime 30000;
+	AdvRetransTimer 0;
+	AdvCurHopLimit 64;
+	AdvDefaultLifetime 1800;
+	AdvHomeAgentFlag off;
+	AdvDefaultPreference high;
+	AdvLinkMTU 1500;
+	AdvSourceLLAddress on;
+
+	prefix 2001:db8:1::/64
+	{
+		AdvValidLifetime infinity;
+		AdvPreferredLifetime 3600;
+		AdvOnLink on;
+		AdvAutonomous on;
+		AdvRouterAddr off;
+	}; # End of prefix definition
+
+	route 2001:db8::/48
+	{
+		AdvRoutePreference low;
+		AdvRouteLifetime 1800;
+	}; # End of route definition
+
+	RDNSS 2001:db8::53 2001:db8::54
+	{
+		AdvRDNSSLifetime 60;
+	}; # End of RDNSS definition
+
+	DNSSL example.com
+	{
+		AdvDNSSLLifetime 60;
+	}; # End of DNSSL definition
+}; # End of interface definition
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\nnot:\n%s", expected, b.String())
+	}
+}
+
+func TestWriteRadvdConfigParse(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, pref64, _ := net.ParseCIDR("64:ff9b::/96")
+	cfg := DefaultRAConfig()
+	cfg.Other = true
+	cfg.RouterLifetime = 300 * time.Second
+	cfg.RetransTimer = time.Second
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(prefix)}
+	cfg.RDNSS = []net.IP{net.ParseIP("2001:db8::53")}
+	cfg.DNSSL = []string{"example.com", "example.org"}
+	cfg.PREF64 = pref64
+	cfg.PREF64Lifetime = 1800 * time.Second
+	ra := cfg.Advertisement()
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}})
+
+	// what is written parses back into the configuration advertising it
+	var b bytes.Buffer
+	if err := WriteRadvdConfig(&b, "eth0", ra); err != nil {
+		t.Fatal(err)
+	}
+	cfgs, err := ParseRadvdConfig(&b)
+	if err != nil {
+		t.Fatalf("%s in:\n%s", err, b.String())
+	}
+	parsed, ok := cfgs["eth0"]
+	if !ok {
+		t.Fatal("expected eth0 to be advertised")
+	}
+	if parsed.MaxInterval != 300*time.Second {
+		t.Errorf("expected max interval to fit the router lifetime, not %s", parsed.MaxInterval)
+	}
+	if !reflect.DeepEqual(parsed.Advertisement(), cfg.Advertisement()) {
+		t.Errorf("expected %s, not %s", cfg.Advertisement(), parsed.Advertisement())
+	}
+}
@@ -0,0 +1,210 @@
+package ndp
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// RAGuardRule describes a legitimate router for RAGuard. Fields that are
+// left empty match any router
+type RAGuardRule struct {
+	// LinkLayerAddress is matched against the source of the frame when
+	// known and the source link-layer address option otherwise
+	LinkLayerAddress net.HardwareAddr
+	Source           net.IP
+	// Prefixes lists the prefixes this router may advertise in prefix
+	// information options, including their more specific prefixes
+	Prefixes []*net.IPNet
+}
+
+// matches returns whether the router that sent ra matches this rule
+func (r RAGuardRule) matches(ra *ICMPRouterAdvertisement, md *Metadata) bool {
+	if r.Source != nil && (md == nil || !r.Source.Equal(md.Source)) {
+		return false
+	}
+
+	if len(r.LinkLayerAddress) > 0 {
+		var lla net.HardwareAddr
+		if md != nil {
+			lla = md.SourceLinkLayerAddress
+		}
+		for _, o := range ra.Options {
+			if slla, ok := o.(*ICMPOptionSourceLinkLayerAddress); ok && lla == nil {
+				lla = slla.LinkLayerAddress
+			}
+		}
+		if !bytes.Equal(r.LinkLayerAddress, lla) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// disallowed returns the first prefix information option of ra this rule
+// doesn't allow, or nil if it allows all of them
+func (r RAGuardRule) disallowed(ra *ICMPRouterAdvertisement) *net.IPNet {
+	if len(r.Prefixes) == 0 {
+		return nil
+	}
+
+	for _, o := range ra.Options {
+		pi, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok {
+			continue
+		}
+
+		prefix := &net.IPNet{
+			IP:   pi.Prefix,
+			Mask: net.CIDRMask(int(pi.PrefixLength), 128),
+		}
+		allowed := false
+		for _, p := range r.Prefixes {
+			ones, _ := p.Mask.Size()
+			if p.Contains(pi.Prefix) && int(pi.PrefixLength) >= ones {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return prefix
+		}
+	}
+
+	return nil
+}
+
+// RAGuardReason describes why RAGuard rejected a router advertisement
+type RAGuardReason int
+
+// reasons for rejecting router advertisements
+const (
+	// RAGuardUnknownRouter means the advertisement matched no rule
+	RAGuardUnknownRouter RAGuardReason = iota
+	// RAGuardUnknownPrefix means the advertisement came from a known router
+	// but contained a prefix it may not advertise
+	RAGuardUnknownPrefix
+)
+
+func (r RAGuardReason) String() string {
+	switch r {
+	case RAGuardUnknownRouter:
+		return "unknown router"
+	case RAGuardUnknownPrefix:
+		return "unknown prefix"
+	default:
+		return "<nil>"
+	}
+}
+
+// RogueRA describes a router advertisement rejected by RAGuard
+type RogueRA struct {
+	Advertisement *ICMPRouterAdvertisement
+	Metadata      *Metadata
+	Reason        RAGuardReason
+	// Prefix holds the offending prefix for RAGuardUnknownPrefix
+	Prefix *net.IPNet
+}
+
+func (r RogueRA) String() string {
+	var src net.IP
+	if r.Metadata != nil {
+		src = r.Metadata.Source
+	}
+
+	if r.Reason == RAGuardUnknownPrefix {
+		return fmt.Sprintf("rogue router advertisement from %s: %s %s", src, r.Reason, r.Prefix)
+	}
+
+	return fmt.Sprintf("rogue router advertisement from %s: %s", src, r.Reason)
+}
+
+// RAGuard implements a Handler that checks router advertisements against a
+// list of legitimate routers, like the RA-Guard feature of switches
+// described at https://tools.ietf.org/html/rfc6105. Rogue advertisements are
+// reported to Rogue, all other messages are passed on to Next
+type RAGuard struct {
+	// Rogue is called for every rejected router advertisement
+	Rogue func(RogueRA)
+	// Next receives all messages except rogue router advertisements.
+	// Optional
+	Next Handler
+	// Logger, when set, logs rejected router advertisements as warnings
+	Logger *slog.Logger
+
+	mu    sync.RWMutex
+	rules []RAGuardRule
+}
+
+// NewRAGuard returns an RAGuard allowing router advertisements matching
+// any of rules
+func NewRAGuard(rules ...RAGuardRule) *RAGuard {
+	return &RAGuard{
+		rules: rules,
+	}
+}
+
+// SetRules replaces the rules of this RAGuard
+func (g *RAGuard) SetRules(rules ...RAGuardRule) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rules = rules
+}
+
+// Check returns a RogueRA if ra, received with md, doesn't match any rule or
+// nil if it's legitimate
+func (g *RAGuard) Check(ra *ICMPRouterAdvertisement, md *Metadata) *RogueRA {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var rogue *RogueRA
+	for _, r := range g.rules {
+		if !r.matches(ra, md) {
+			continue
+		}
+
+		prefix := r.disallowed(ra)
+		if prefix == nil {
+			return nil
+		}
+		if rogue == nil {
+			rogue = &RogueRA{
+				Advertisement: ra,
+				Metadata:      md,
+				Reason:        RAGuardUnknownPrefix,
+				Prefix:        prefix,
+			}
+		}
+	}
+
+	if rogue == nil {
+		rogue = &RogueRA{
+			Advertisement: ra,
+			Metadata:      md,
+			Reason:        RAGuardUnknownRouter,
+		}
+	}
+
+	return rogue
+}
+
+// ServeNDP checks router advertisements and passes legitimate ones and all
+// other messages on to Next
+func (g *RAGuard) ServeNDP(m ICMP, md *Metadata) {
+	if ra, ok := m.(*ICMPRouterAdvertisement); ok {
+		if rogue := g.Check(ra, md); rogue != nil {
+			loggerOr(g.Logger).Warn("rejected rogue router advertisement", append(receivedAttrs(ra, md), "reason", rogue.Reason.String())...)
+			if g.Rogue != nil {
+				g.Rogue(*rogue)
+			}
+			return
+		}
+	}
+
+	if g.Next != nil {
+		g.Next.ServeNDP(m, md)
+	}
+}
@@ -0,0 +1,111 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRAGuard(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	rogueMAC, _ := net.ParseMAC("02:00:00:00:00:66")
+	_, allowed, _ := net.ParseCIDR("2001:db8::/48")
+
+	g := NewRAGuard(
+		RAGuardRule{
+			LinkLayerAddress: mac,
+			Source:           net.ParseIP("fe80::1"),
+			Prefixes:         []*net.IPNet{allowed},
+		},
+		RAGuardRule{
+			Source: net.ParseIP("fe80::2"),
+		},
+	)
+
+	ra := func(lla net.HardwareAddr, prefixes ...string) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{RouterLifeTime: 1800}
+		if lla != nil {
+			ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		for _, p := range prefixes {
+			_, prefix, _ := net.ParseCIDR(p)
+			ra.AddOption(NewRAPrefix(prefix).Option())
+		}
+		return ra
+	}
+
+	tests := []struct {
+		ra     *ICMPRouterAdvertisement
+		md     *Metadata
+		rogue  bool
+		reason RAGuardReason
+		prefix string
+	}{
+		// known router, allowed prefixes
+		{ra(mac, "2001:db8:0:1::/64", "2001:db8::/48"), &Metadata{Source: net.ParseIP("fe80::1")}, false, 0, ""},
+		// link-layer address from the frame takes precedence
+		{ra(rogueMAC), &Metadata{Source: net.ParseIP("fe80::1"), SourceLinkLayerAddress: mac}, false, 0, ""},
+		{ra(mac), &Metadata{Source: net.ParseIP("fe80::1"), SourceLinkLayerAddress: rogueMAC}, true, RAGuardUnknownRouter, ""},
+		// spoofed source from another link-layer address
+		{ra(rogueMAC), &Metadata{Source: net.ParseIP("fe80::1")}, true, RAGuardUnknownRouter, ""},
+		// no link-layer address at all
+		{ra(nil), &Metadata{Source: net.ParseIP("fe80::1")}, true, RAGuardUnknownRouter, ""},
+		// prefix outside of or less specific than the allowed one
+		{ra(mac, "2001:db8:0:1::/64", "2001:db8:1::/64"), &Metadata{Source: net.ParseIP("fe80::1")}, true, RAGuardUnknownPrefix, "2001:db8:1::/64"},
+		{ra(mac, "2001:db8::/32"), &Metadata{Source: net.ParseIP("fe80::1")}, true, RAGuardUnknownPrefix, "2001:db8::/32"},
+		// any prefix from any link-layer address is fine for the second
+		{ra(rogueMAC, "2001:db8:1::/64"), &Metadata{Source: net.ParseIP("fe80::2")}, false, 0, ""},
+		// unknown source
+		{ra(mac), &Metadata{Source: net.ParseIP("fe80::3")}, true, RAGuardUnknownRouter, ""},
+		{ra(mac), nil, true, RAGuardUnknownRouter, ""},
+	}
+
+	for i, test := range tests {
+		rogue := g.Check(test.ra, test.md)
+		if !test.rogue {
+			if rogue != nil {
+				t.Errorf("test %d: unexpected %s", i, rogue)
+			}
+			continue
+		}
+
+		if rogue == nil {
+			t.Errorf("test %d: expected rogue advertisement", i)
+			continue
+		}
+		if rogue.Reason != test.reason || rogue.Advertisement != test.ra {
+			t.Errorf("test %d: unexpected %s", i, rogue)
+		}
+		if test.prefix != "" && (rogue.Prefix == nil || rogue.Prefix.String() != test.prefix) {
+			t.Errorf("test %d: expected prefix %s, not %s", i, test.prefix, rogue.Prefix)
+		}
+	}
+
+	// without rules everything is rogue
+	g.SetRules()
+	if rogue := g.Check(tests[0].ra, tests[0].md); rogue == nil || rogue.String() != "rogue router advertisement from fe80::1: unknown router" {
+		t.Errorf("unexpected %v", rogue)
+	}
+}
+
+func TestRAGuardServeNDP(t *testing.T) {
+	var rogues []RogueRA
+	var passed []ICMP
+	g := NewRAGuard(RAGuardRule{Source: net.ParseIP("fe80::1")})
+	g.Rogue = func(r RogueRA) {
+		rogues = append(rogues, r)
+	}
+	g.Next = HandlerFunc(func(m ICMP, md *Metadata) {
+		passed = append(passed, m)
+	})
+
+	g.ServeNDP(&ICMPRouterAdvertisement{}, &Metadata{Source: net.ParseIP("fe80::1")})
+	g.ServeNDP(&ICMPRouterAdvertisement{}, &Metadata{Source: net.ParseIP("fe80::2")})
+	g.ServeNDP(&ICMPNeighborSolicitation{}, &Metadata{Source: net.ParseIP("fe80::2")})
+
+	if len(passed) != 2 {
+		t.Errorf("expected 2 messages passed on, not %d", len(passed))
+	}
+	if len(rogues) != 1 || !rogues[0].Metadata.Source.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected rogue advertisements %v", rogues)
+	}
+}
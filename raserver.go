@@ -0,0 +1,744 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// RAPrefix describes a prefix advertised by RAServer in a Prefix Information
+// option
+type RAPrefix struct {
+	Prefix            *net.IPNet
+	OnLink            bool
+	Autonomous        bool
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+}
+
+// NewRAPrefix returns an RAPrefix for prefix with the defaults of
+// https://tools.ietf.org/html/rfc4861#section-6.2.1: on-link and usable for
+// autonomous address configuration, valid for 30 days and preferred for 7
+func NewRAPrefix(prefix *net.IPNet) RAPrefix {
+	return RAPrefix{
+		Prefix:            prefix,
+		OnLink:            true,
+		Autonomous:        true,
+		ValidLifetime:     30 * 24 * time.Hour,
+		PreferredLifetime: 7 * 24 * time.Hour,
+	}
+}
+
+// Option returns the Prefix Information option advertising this RAPrefix
+func (p RAPrefix) Option() *ICMPOptionPrefixInformation {
+	ones, _ := p.Prefix.Mask.Size()
+	o := &ICMPOptionPrefixInformation{
+		PrefixLength: uint8(ones),
+		OnLink:       p.OnLink,
+		Auto:         p.Autonomous,
+		Prefix:       p.Prefix.IP.Mask(p.Prefix.Mask).To16(),
+	}
+	o.SetValidLifetime(p.ValidLifetime)
+	o.SetPreferredLifetime(p.PreferredLifetime)
+
+	return o
+}
+
+// RAConfig describes the router advertisements RAServer sends as described
+// at https://tools.ietf.org/html/rfc4861#section-6.2.1. Zero values are sent
+// as is, so start from DefaultRAConfig for sensible defaults
+type RAConfig struct {
+	// MinInterval and MaxInterval bound the random interval between
+	// unsolicited advertisements
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	Managed        bool
+	Other          bool
+	HopLimit       uint8
+	RouterLifetime time.Duration
+	Preference     RouterPreferenceField
+	ReachableTime  time.Duration
+	RetransTimer   time.Duration
+	// MTU is only advertised when set
+	MTU uint32
+	// UnicastSolicited answers solicitations from hosts that have an
+	// address with a unicast rather than a multicast advertisement, as
+	// recommended by https://tools.ietf.org/html/rfc7772#section-5.1
+	UnicastSolicited bool
+
+	Prefixes []RAPrefix
+
+	RDNSS         []net.IP
+	RDNSSLifetime time.Duration
+	DNSSL         []string
+	DNSSLLifetime time.Duration
+
+	// PREF64 is the NAT64 prefix advertised as described at
+	// https://tools.ietf.org/html/rfc8781, when set. Its length must be one
+	// of 96, 64, 56, 48, 40 or 32
+	PREF64         *net.IPNet
+	PREF64Lifetime time.Duration
+}
+
+// DefaultRAConfig returns an RAConfig with the default intervals and router
+// lifetime of https://tools.ietf.org/html/rfc4861#section-6.2.1, the hop
+// limit recommended by IANA, DNS lifetimes recommended by
+// https://tools.ietf.org/html/rfc8106#section-5.1 and the PREF64 lifetime
+// recommended by https://tools.ietf.org/html/rfc8781#section-4.1
+func DefaultRAConfig() RAConfig {
+	max := 600 * time.Second
+	return RAConfig{
+		MinInterval:    max * 33 / 100,
+		MaxInterval:    max,
+		HopLimit:       64,
+		RouterLifetime: 3 * max,
+		RDNSSLifetime:  3 * max,
+		DNSSLLifetime:  3 * max,
+		PREF64Lifetime: 3 * max,
+	}
+}
+
+// Validate returns an error if this RAConfig violates the limits of
+// https://tools.ietf.org/html/rfc4861#section-6.2.1
+func (cfg RAConfig) Validate() error {
+	if cfg.MaxInterval < 4*time.Second || cfg.MaxInterval > 1800*time.Second {
+		return fmt.Errorf("max interval %s not within 4s and 1800s", cfg.MaxInterval)
+	}
+	if cfg.MinInterval < 3*time.Second || cfg.MinInterval > cfg.MaxInterval*3/4 {
+		return fmt.Errorf("min interval %s not within 3s and %s", cfg.MinInterval, cfg.MaxInterval*3/4)
+	}
+	if cfg.RouterLifetime != 0 && (cfg.RouterLifetime < cfg.MaxInterval || cfg.RouterLifetime > 9000*time.Second) {
+		return fmt.Errorf("router lifetime %s not within %s and 9000s", cfg.RouterLifetime, cfg.MaxInterval)
+	}
+	if cfg.ReachableTime < 0 || cfg.ReachableTime > time.Hour {
+		return fmt.Errorf("reachable time %s not within 0 and 1h", cfg.ReachableTime)
+	}
+	if cfg.RetransTimer < 0 || cfg.RetransTimer/time.Millisecond > 0xffffffff {
+		return fmt.Errorf("invalid retrans timer %s", cfg.RetransTimer)
+	}
+	if cfg.MTU != 0 && cfg.MTU < 1280 {
+		return fmt.Errorf("mtu %d below IPv6 minimum of 1280", cfg.MTU)
+	}
+
+	for _, p := range cfg.Prefixes {
+		if p.Prefix == nil || p.Prefix.IP.To16() == nil || p.Prefix.IP.To4() != nil {
+			return fmt.Errorf("prefix %s is not an IPv6 prefix", p.Prefix)
+		}
+		if p.PreferredLifetime > p.ValidLifetime {
+			return fmt.Errorf("preferred lifetime of %s exceeds its valid lifetime", p.Prefix)
+		}
+	}
+
+	if cfg.PREF64 != nil {
+		if err := validPREF64(cfg.PREF64); err != nil {
+			return err
+		}
+		if cfg.PREF64Lifetime < 0 || cfg.PREF64Lifetime > MaxPREF64Lifetime {
+			return fmt.Errorf("pref64 lifetime %s not within 0 and %s", cfg.PREF64Lifetime, MaxPREF64Lifetime)
+		}
+	}
+
+	// leave checking the DNS options to their Marshal
+	if _, err := cfg.Advertisement().Marshal(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Advertisement returns the router advertisement described by this RAConfig
+func (cfg RAConfig) Advertisement() *ICMPRouterAdvertisement {
+	ra := &ICMPRouterAdvertisement{
+		HopLimit:         cfg.HopLimit,
+		ManagedAddress:   cfg.Managed,
+		OtherStateful:    cfg.Other,
+		RouterPreference: cfg.Preference,
+		RouterLifeTime:   uint16(durationToLifetime(cfg.RouterLifetime)),
+		ReachableTime:    uint32(cfg.ReachableTime / time.Millisecond),
+		RetransTimer:     uint32(cfg.RetransTimer / time.Millisecond),
+	}
+	if cfg.RouterLifetime > 0xffff*time.Second {
+		ra.RouterLifeTime = 0xffff
+	}
+
+	if cfg.MTU != 0 {
+		ra.AddOption(&ICMPOptionMTU{MTU: cfg.MTU})
+	}
+
+	for _, p := range cfg.Prefixes {
+		ra.AddOption(p.Option())
+	}
+
+	if len(cfg.RDNSS) > 0 {
+		o := &ICMPOptionRecursiveDNSServer{Servers: cfg.RDNSS}
+		o.SetLifetime(cfg.RDNSSLifetime)
+		ra.AddOption(o)
+	}
+
+	if len(cfg.DNSSL) > 0 {
+		o := &ICMPOptionDNSSearchList{DomainNames: cfg.DNSSL}
+		o.SetLifetime(cfg.DNSSLLifetime)
+		ra.AddOption(o)
+	}
+
+	if cfg.PREF64 != nil {
+		ones, _ := cfg.PREF64.Mask.Size()
+		o := &ICMPOptionPREF64{
+			PrefixLength: uint8(ones),
+			Prefix:       cfg.PREF64.IP.Mask(cfg.PREF64.Mask).To16(),
+		}
+		o.SetLifetime(cfg.PREF64Lifetime)
+		ra.AddOption(o)
+	}
+
+	return ra
+}
+
+// RAServer periodically multicasts router advertisements on the interface
+// of a Conn and answers router solicitations, like radvd does
+type RAServer struct {
+	// Inconsistent, when set, is called for every way in which a router
+	// advertisement from another router differs from ours. Serve then has
+	// the Conn accept router advertisements. It must be set before calling
+	// Serve
+	Inconsistent func(RAInconsistency)
+	// Sent, when set, is called for every router advertisement sent to dst,
+	// including the final one withdrawing this router. It must be set
+	// before calling Serve
+	Sent func(ra *ICMPRouterAdvertisement, dst net.IP)
+
+	c *Conn
+
+	mu   sync.Mutex
+	cfg  RAConfig
+	sent int
+	// sourced holds the prefixes of the PrefixSources of WatchPrefixes,
+	// advertised alongside those of cfg
+	sourced []RAPrefix
+	// sourcedPREF64 is the NAT64 prefix of the PREF64Source of WatchPREF64,
+	// advertised unless cfg has one
+	sourcedPREF64 *net.IPNet
+	// deprecated holds prefixes removed from cfg that are still advertised
+	// with a preferred lifetime of 0
+	deprecated []deprecatedPrefix
+	// next is when the next multicast advertisement is due, last when the
+	// previous one was sent
+	next time.Time
+	last time.Time
+	// solicitors holds the hosts that recently sent a solicitation, keyed
+	// by address
+	solicitors map[string]*raSolicitor
+	// wake tells Serve that next or a solicitor's response moved
+	wake chan struct{}
+
+	// overridden by tests
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+	rand  func() float64
+}
+
+// deprecatedPrefix is an RAPrefix that is advertised as deprecated in the
+// next left multicast advertisements
+type deprecatedPrefix struct {
+	p    RAPrefix
+	left int
+}
+
+// maxSolicitors caps the number of tracked solicitors so spoofed
+// solicitations can't exhaust memory
+const maxSolicitors = 4096
+
+// raSolicitor is a host that sent a router solicitation
+type raSolicitor struct {
+	addr net.IP
+	seen time.Time
+	// due is when a unicast advertisement is to be sent to this host, if at
+	// all
+	due time.Time
+}
+
+// NewRAServer returns an RAServer advertising cfg on c, which must have been
+// created with RoleRouter
+func NewRAServer(c *Conn, cfg RAConfig) (*RAServer, error) {
+	if c.Role() != RoleRouter {
+		return nil, errNotRouter
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &RAServer{
+		c:          c,
+		cfg:        cfg,
+		solicitors: make(map[string]*raSolicitor),
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+		after:      time.After,
+		rand:       rand.Float64,
+	}, nil
+}
+
+// Config returns the RAConfig this RAServer advertises
+func (s *RAServer) Config() RAConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// Serve sends unsolicited router advertisements and answers router
+// solicitations read from the Conn until ctx is done or reading or sending
+// fails, following https://tools.ietf.org/html/rfc4861#section-6.2.4 and
+// https://tools.ietf.org/html/rfc4861#section-6.2.6. When ctx is done, a
+// final advertisement with a router lifetime of 0 is sent so hosts stop
+// using this router and its prefixes right away, as described at
+// https://tools.ietf.org/html/rfc4861#section-6.2.5
+func (s *RAServer) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mux := NewMux()
+	mux.HandleRouterSolicitation(s.solicited)
+	if s.Inconsistent != nil {
+		if !s.c.allowed([]byte{byte(ipv6.ICMPTypeRouterAdvertisement)}) {
+			types := []ipv6.ICMPType{ipv6.ICMPTypeRouterAdvertisement}
+			for t := range s.c.accept {
+				types = append(types, t)
+			}
+			// falls back to filtering in ReadFrom
+			s.c.SetAllowedTypes(types...)
+		}
+		mux.HandleRouterAdvertisement(s.compare)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.c.Serve(ctx, mux)
+	}()
+
+	s.mu.Lock()
+	s.next = s.now()
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		d := s.due().Sub(s.now())
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return s.shutdown(ctx)
+		case err := <-errc:
+			// Serve of the Conn may return before we notice ctx is done
+			if ctx.Err() != nil {
+				return s.shutdown(ctx)
+			}
+			return err
+		case <-s.wake:
+		case <-s.after(d):
+			if err := s.fire(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// shutdown sends the final advertisement when ctx is done and returns its
+// error
+func (s *RAServer) shutdown(ctx context.Context) error {
+	// like radvd, don't hold up shutting down for the rate limit
+	s.mu.Lock()
+	ra := s.finalAdvertisement()
+	s.mu.Unlock()
+	s.c.logger().Info("withdrawing router")
+	s.send(ra, net.IPv6linklocalallnodes)
+
+	return ctx.Err()
+}
+
+// due returns when Serve needs to send its next advertisement. It must be
+// called with mu held
+func (s *RAServer) due() time.Time {
+	due := s.next
+	for _, sol := range s.solicitors {
+		if !sol.due.IsZero() && sol.due.Before(due) {
+			due = sol.due
+		}
+	}
+
+	return due
+}
+
+// fire sends the advertisements that are due and forgets about solicitors
+// that no longer depend on them
+func (s *RAServer) fire() error {
+	s.mu.Lock()
+	now := s.now()
+	// hosts that solicited keep relying on us for a router lifetime
+	lifetime := s.cfg.RouterLifetime
+	if lifetime < s.cfg.MaxInterval {
+		lifetime = s.cfg.MaxInterval
+	}
+
+	var unicast []net.IP
+	for key, sol := range s.solicitors {
+		if !sol.due.IsZero() && !sol.due.After(now) {
+			unicast = append(unicast, sol.addr)
+			sol.due = time.Time{}
+		} else if sol.due.IsZero() && now.Sub(sol.seen) > lifetime {
+			delete(s.solicitors, key)
+		}
+	}
+	multicast := !s.next.After(now)
+	s.mu.Unlock()
+
+	for _, dst := range unicast {
+		if err := s.advertise(dst); err != nil {
+			return err
+		}
+	}
+
+	if multicast {
+		if err := s.advertise(net.IPv6linklocalallnodes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Solicitors returns the addresses of the hosts that sent a router
+// solicitation within the last router lifetime, or MaxInterval if that is
+// longer
+func (s *RAServer) Solicitors() []net.IP {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addrs := make([]net.IP, 0, len(s.solicitors))
+	for _, sol := range s.solicitors {
+		addrs = append(addrs, sol.addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i], addrs[j]) < 0
+	})
+
+	return addrs
+}
+
+// SetConfig changes the advertised RAConfig without interrupting Serve.
+// Like a router that just started, the next few advertisements go out
+// quickly so hosts learn about the change, as allowed by
+// https://tools.ietf.org/html/rfc4861#section-6.2.4. Prefixes that are no
+// longer in cfg are advertised with a preferred lifetime of 0 in the next
+// MaxFinalRtrAdvertisements advertisements, so hosts stop using them for
+// new connections
+func (s *RAServer) SetConfig(cfg RAConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.update(cfg, s.sourced)
+
+	return nil
+}
+
+// update starts advertising cfg and sourced, deprecating the prefixes that
+// are no longer advertised. It must be called with mu held
+func (s *RAServer) update(cfg RAConfig, sourced []RAPrefix) {
+	current := make(map[string]bool)
+	for _, p := range mergePrefixes(cfg.Prefixes, sourced) {
+		current[p.Prefix.String()] = true
+	}
+	deprecated := s.deprecated[:0]
+	for _, d := range s.deprecated {
+		if !current[d.p.Prefix.String()] {
+			deprecated = append(deprecated, d)
+		}
+	}
+	for _, p := range s.advertised().Prefixes {
+		if !current[p.Prefix.String()] {
+			deprecated = append(deprecated, deprecatedPrefix{p: p, left: MaxFinalRtrAdvertisements})
+		}
+	}
+	s.deprecated = deprecated
+
+	s.cfg = cfg
+	s.sourced = sourced
+	s.sent = 0
+	s.schedule(0)
+}
+
+// advertised returns cfg with the prefixes of sourced added, and the
+// sourced PREF64 unless cfg has one. It must be called with mu held
+func (s *RAServer) advertised() RAConfig {
+	cfg := s.cfg
+	cfg.Prefixes = mergePrefixes(s.cfg.Prefixes, s.sourced)
+	if cfg.PREF64 == nil {
+		cfg.PREF64 = s.sourcedPREF64
+	}
+
+	return cfg
+}
+
+// WatchPrefixes advertises the prefixes of sources alongside those of the
+// RAConfig until ctx is done or one of them fails, and then deprecates them.
+// Whenever the prefixes of a source change, hosts are told about it like
+// after SetConfig. Prefixes that are also in the RAConfig, or in an earlier
+// source, are advertised as configured there
+func (s *RAServer) WatchPrefixes(ctx context.Context, sources ...PrefixSource) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	latest := make([][]RAPrefix, len(sources))
+	errc := make(chan error, len(sources))
+	for i, src := range sources {
+		go func(i int, src PrefixSource) {
+			errc <- src.Watch(ctx, func(prefixes []RAPrefix) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				latest[i] = prefixes
+				return s.setSourced(mergePrefixes(latest...))
+			})
+		}(i, src)
+	}
+
+	var err error
+	for range sources {
+		if serr := <-errc; serr != nil && err == nil {
+			err = serr
+			cancel()
+		}
+	}
+	if len(sources) == 0 {
+		<-ctx.Done()
+		err = ctx.Err()
+	}
+
+	s.setSourced(nil)
+
+	return err
+}
+
+// setSourced starts advertising prefixes from PrefixSources unless these are
+// advertised already
+func (s *RAServer) setSourced(prefixes []RAPrefix) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reflect.DeepEqual(prefixes, s.sourced) {
+		return nil
+	}
+
+	cfg := s.cfg
+	cfg.Prefixes = mergePrefixes(s.cfg.Prefixes, prefixes)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	s.update(s.cfg, prefixes)
+
+	return nil
+}
+
+// WatchPREF64 advertises the NAT64 prefix of src until ctx is done or src
+// fails, and then stops advertising it. Whenever the prefix changes, hosts
+// are told about it like after SetConfig. A PREF64 in the RAConfig takes
+// precedence over that of src
+func (s *RAServer) WatchPREF64(ctx context.Context, src PREF64Source) error {
+	err := src.Watch(ctx, s.setSourcedPREF64)
+	s.setSourcedPREF64(nil)
+
+	return err
+}
+
+// setSourcedPREF64 starts advertising prefix from a PREF64Source unless it
+// is advertised already
+func (s *RAServer) setSourcedPREF64(prefix *net.IPNet) error {
+	if prefix != nil {
+		if err := validPREF64(prefix); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prefix.String() == s.sourcedPREF64.String() {
+		return nil
+	}
+	s.sourcedPREF64 = prefix
+	// only hosts not told about the PREF64 of cfg need to hear about this
+	if s.cfg.PREF64 == nil {
+		s.update(s.cfg, s.sourced)
+	}
+
+	return nil
+}
+
+// solicited schedules the advertisement that answers a router solicitation
+// after a random delay, unless a multicast one is due before that anyway
+func (s *RAServer) solicited(rs *ICMPRouterSolicitation, md *Metadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := time.Duration(s.rand() * float64(MaxRADelayTime))
+	now := s.now()
+	var sol *raSolicitor
+	if !md.Source.IsUnspecified() {
+		key := md.Source.String()
+		sol = s.solicitors[key]
+		if sol == nil && len(s.solicitors) < maxSolicitors {
+			sol = &raSolicitor{addr: md.Source}
+			s.solicitors[key] = sol
+		}
+		if sol != nil {
+			sol.seen = now
+		}
+	}
+
+	// hosts without an address can only be reached by multicast
+	if !s.cfg.UnicastSolicited || sol == nil {
+		s.schedule(delay)
+		return
+	}
+
+	at := now.Add(delay)
+	if !sol.due.IsZero() || !at.Before(s.next) {
+		return
+	}
+
+	sol.due = at
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule moves the next multicast advertisement forward to delay from now
+// if it isn't due before that anyway. It must be called with mu held
+func (s *RAServer) schedule(delay time.Duration) {
+	now := s.now()
+	at := now.Add(delay)
+	// multicast advertisements are at least MinDelayBetweenRAs apart
+	if !s.last.IsZero() && now.Sub(s.last) < MinDelayBetweenRAs {
+		at = s.last.Add(MinDelayBetweenRAs + delay)
+	}
+
+	if !at.Before(s.next) {
+		return
+	}
+
+	s.next = at
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// compare reports how ra, received from another router, is inconsistent
+// with our configuration
+func (s *RAServer) compare(ra *ICMPRouterAdvertisement, md *Metadata) {
+	// our own advertisements may be looped back
+	if md.Source.Equal(s.c.Addr()) {
+		return
+	}
+
+	s.mu.Lock()
+	cfg := s.advertised()
+	s.mu.Unlock()
+
+	for _, i := range cfg.Inconsistencies(ra) {
+		i.Source = md.Source
+		s.c.logger().Warn("inconsistent router advertisement", "src", i.Source.String(), "field", i.Field, "ours", i.Ours, "theirs", i.Theirs)
+		s.Inconsistent(i)
+	}
+}
+
+// advertise sends the current advertisement to dst and, for multicast ones,
+// schedules the next one
+func (s *RAServer) advertise(dst net.IP) error {
+	s.mu.Lock()
+	ra := s.advertised().Advertisement()
+	deprecated := s.deprecated[:0]
+	for _, d := range s.deprecated {
+		d.p.PreferredLifetime = 0
+		ra.AddOption(d.p.Option())
+		if !dst.IsMulticast() {
+			deprecated = append(deprecated, d)
+		} else if d.left--; d.left > 0 {
+			deprecated = append(deprecated, d)
+		}
+	}
+	s.deprecated = deprecated
+
+	if dst.IsMulticast() {
+		s.sent++
+		s.last = s.now()
+		s.next = s.last.Add(s.nextInterval())
+	}
+	s.mu.Unlock()
+
+	return s.send(ra, dst)
+}
+
+// send sends ra to dst and tells Sent about it
+func (s *RAServer) send(ra *ICMPRouterAdvertisement, dst net.IP) error {
+	// the intervals of Serve already pace our advertisements, and the rate
+	// limit of the Conn would drop ones we've scheduled
+	if err := s.c.sendRA(ra, dst, false); err != nil {
+		return err
+	}
+	if s.Sent != nil {
+		s.Sent(ra, dst)
+	}
+
+	return nil
+}
+
+// finalAdvertisement returns the advertisement that withdraws this router
+// and deprecates its prefixes and DNS options. It must be called with mu
+// held
+func (s *RAServer) finalAdvertisement() *ICMPRouterAdvertisement {
+	cfg := s.advertised()
+	cfg.RouterLifetime = 0
+	cfg.RDNSSLifetime = 0
+	cfg.DNSSLLifetime = 0
+	cfg.PREF64Lifetime = 0
+	prefixes := cfg.Prefixes
+	cfg.Prefixes = nil
+	for _, p := range prefixes {
+		p.PreferredLifetime = 0
+		cfg.Prefixes = append(cfg.Prefixes, p)
+	}
+	for _, d := range s.deprecated {
+		d.p.PreferredLifetime = 0
+		cfg.Prefixes = append(cfg.Prefixes, d.p)
+	}
+
+	return cfg.Advertisement()
+}
+
+// nextInterval returns a random interval between MinInterval and
+// MaxInterval, which is capped for the first few advertisements so hosts
+// learn about a new router quickly. It must be called with mu held
+func (s *RAServer) nextInterval() time.Duration {
+	min, max := s.cfg.MinInterval, s.cfg.MaxInterval
+	d := min + time.Duration(s.rand()*float64(max-min))
+	if s.sent < MaxInitialRtrAdvertisements && d > MaxInitialRtrAdvertInterval {
+		d = MaxInitialRtrAdvertInterval
+	}
+
+	return d
+}
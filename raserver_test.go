@@ -0,0 +1,634 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRAConfigAdvertisement(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	cfg := DefaultRAConfig()
+	cfg.MTU = 1500
+	cfg.Managed = true
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(prefix)}
+	cfg.RDNSS = []net.IP{net.ParseIP("2001:db8::53")}
+	cfg.DNSSL = []string{"example.com"}
+	_, cfg.PREF64, _ = net.ParseCIDR("64:ff9b::/96")
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ra := cfg.Advertisement()
+	if ra.HopLimit != 64 || !ra.ManagedAddress || ra.RouterLifeTime != 1800 {
+		t.Errorf("unexpected advertisement %s", ra)
+	}
+
+	if len(ra.Options) != 5 {
+		t.Fatalf("expected 5 options, not %d", len(ra.Options))
+	}
+
+	pi, ok := ra.Options[1].(*ICMPOptionPrefixInformation)
+	if !ok {
+		t.Fatalf("unexpected option %s", ra.Options[1])
+	}
+	if pi.PrefixLength != 64 || !pi.OnLink || !pi.Auto || pi.ValidLifetime != 2592000 || pi.PreferredLifetime != 604800 || !pi.Prefix.Equal(prefix.IP) {
+		t.Errorf("unexpected prefix information %s", pi)
+	}
+
+	if rdnss := ra.Options[2].(*ICMPOptionRecursiveDNSServer); rdnss.Lifetime != 1800 {
+		t.Errorf("unexpected rdnss lifetime %d", rdnss.Lifetime)
+	}
+
+	if pref64 := ra.Options[4].(*ICMPOptionPREF64); pref64.PrefixLength != 96 || pref64.ScaledLifetime != 225 || !pref64.Prefix.Equal(cfg.PREF64.IP) {
+		t.Errorf("unexpected pref64 %s", pref64)
+	}
+}
+
+func TestRAConfigValidate(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("192.0.2.0/24")
+	_, v6, _ := net.ParseCIDR("2001:db8::/64")
+
+	tests := []func(*RAConfig){
+		func(c *RAConfig) { c.MaxInterval = 3 * time.Second },
+		func(c *RAConfig) { c.MaxInterval = 1801 * time.Second },
+		func(c *RAConfig) { c.MinInterval = 2 * time.Second },
+		func(c *RAConfig) { c.MinInterval = c.MaxInterval },
+		func(c *RAConfig) { c.RouterLifetime = c.MaxInterval - time.Second },
+		func(c *RAConfig) { c.RouterLifetime = 9001 * time.Second },
+		func(c *RAConfig) { c.ReachableTime = 2 * time.Hour },
+		func(c *RAConfig) { c.MTU = 1000 },
+		func(c *RAConfig) { c.Prefixes = []RAPrefix{NewRAPrefix(v4)} },
+		func(c *RAConfig) {
+			p := NewRAPrefix(v6)
+			p.PreferredLifetime = p.ValidLifetime + time.Second
+			c.Prefixes = []RAPrefix{p}
+		},
+		func(c *RAConfig) { c.RDNSS = []net.IP{net.ParseIP("192.0.2.53")} },
+		func(c *RAConfig) { _, c.PREF64, _ = net.ParseCIDR("64:ff9b::/80") },
+		func(c *RAConfig) { c.PREF64 = v4 },
+		func(c *RAConfig) {
+			_, c.PREF64, _ = net.ParseCIDR("64:ff9b::/96")
+			c.PREF64Lifetime = MaxPREF64Lifetime + time.Second
+		},
+	}
+
+	for i, test := range tests {
+		cfg := DefaultRAConfig()
+		test(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("test %d: expected invalid config", i)
+		}
+	}
+
+	// not being a default router is fine
+	cfg := DefaultRAConfig()
+	cfg.RouterLifetime = 0
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// fakeClock drives an RAServer's timers from a test
+type fakeClock struct {
+	mu   sync.Mutex
+	t    time.Time
+	d    chan time.Duration
+	fire chan time.Time
+}
+
+func newFakeClock(s *RAServer) *fakeClock {
+	return newFakeClockFor(&s.now, &s.after)
+}
+
+// newFakeClockFor returns a fakeClock that replaces now and after
+func newFakeClockFor(now *func() time.Time, after *func(time.Duration) <-chan time.Time) *fakeClock {
+	c := &fakeClock{
+		t:    time.Unix(0, 0),
+		d:    make(chan time.Duration, 16),
+		fire: make(chan time.Time),
+	}
+	*now = c.now
+	*after = func(d time.Duration) <-chan time.Time {
+		c.d <- d
+		return c.fire
+	}
+
+	return c
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// wait returns the duration Serve waits for next
+func (c *fakeClock) wait(t *testing.T) time.Duration {
+	select {
+	case d := <-c.d:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for timer")
+		return 0
+	}
+}
+
+func serveRAServer(t *testing.T, setup func(*RAServer)) (*RAServer, *fakeClock, *Conn, func()) {
+	t.Helper()
+
+	a, b := Pipe()
+
+	if _, err := NewRAServer(a, DefaultRAConfig()); err != errNotRouter {
+		t.Errorf("expected error for host, not %v", err)
+	}
+
+	a.role = RoleRouter
+	a.SetAllowedTypes(RoleRouter.Types()...)
+	s, err := NewRAServer(a, DefaultRAConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock(s)
+	s.rand = func() float64 { return 1 }
+	if setup != nil {
+		setup(s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(ctx)
+	}()
+
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	return s, clock, b, func() {
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("expected cancellation, not %v", err)
+		}
+	}
+}
+
+func readRA(t *testing.T, c *Conn) *ICMPRouterAdvertisement {
+	t.Helper()
+	return readRAFrom(t, c, net.IPv6linklocalallnodes)
+}
+
+// readRAFrom reads an advertisement sent to dst from c
+func readRAFrom(t *testing.T, c *Conn, dst net.IP) *ICMPRouterAdvertisement {
+	t.Helper()
+	m, md, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra, ok := m.(*ICMPRouterAdvertisement)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+	if !ra.HasOption(ICMPOptionTypeSourceLinkLayerAddress) {
+		t.Errorf("unexpected advertisement %s", ra)
+	}
+	if !md.Destination.Equal(dst) {
+		t.Errorf("unexpected destination %s", md.Destination)
+	}
+
+	return ra
+}
+
+func TestRAServer(t *testing.T) {
+	_, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	// the first advertisement goes out right away and the next intervals
+	// are capped
+	expected := []time.Duration{0, 16 * time.Second, 16 * time.Second, 600 * time.Second, 600 * time.Second, 600 * time.Second}
+	for i, e := range expected {
+		if d := clock.wait(t); d != e {
+			t.Errorf("expected interval %d of %s, not %s", i, e, d)
+		}
+		if i == len(expected)-1 {
+			break
+		}
+
+		clock.advance(e)
+		clock.fire <- clock.now()
+		if ra := readRA(t, b); ra.RouterLifeTime != 1800 {
+			t.Errorf("unexpected router lifetime %d", ra.RouterLifeTime)
+		}
+	}
+}
+
+func TestRAServerRateLimit(t *testing.T) {
+	_, clock, b, stop := serveRAServer(t, func(s *RAServer) {
+		s.c.SetRateLimiting(true)
+	})
+	defer stop()
+
+	// scheduled advertisements aren't dropped by the rate limit of the Conn
+	for i := 0; i < 3; i++ {
+		d := clock.wait(t)
+		clock.advance(d)
+		clock.fire <- clock.now()
+		readRA(t, b)
+	}
+	clock.wait(t)
+}
+
+func TestRAServerSent(t *testing.T) {
+	type sent struct {
+		ra  *ICMPRouterAdvertisement
+		dst net.IP
+	}
+	sents := make(chan sent, 2)
+	_, clock, b, stop := serveRAServer(t, func(s *RAServer) {
+		s.Sent = func(ra *ICMPRouterAdvertisement, dst net.IP) {
+			sents <- sent{ra, dst}
+		}
+	})
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	if s := <-sents; !s.dst.Equal(net.IPv6linklocalallnodes) || s.ra.RouterLifeTime != 1800 {
+		t.Errorf("unexpected advertisement sent to %s: %s", s.dst, s.ra)
+	}
+
+	// the final advertisement withdraws the router
+	clock.wait(t)
+	stop()
+	if s := <-sents; s.ra.RouterLifeTime != 0 {
+		t.Errorf("unexpected final advertisement %s", s.ra)
+	}
+}
+
+func TestRAServerSolicited(t *testing.T) {
+	_, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	if d := clock.wait(t); d != 16*time.Second {
+		t.Fatalf("unexpected interval %s", d)
+	}
+
+	// a solicitation right after an advertisement is answered no sooner
+	// than MinDelayBetweenRAs plus the random delay
+	clock.advance(time.Second)
+	if err := b.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.wait(t); d != 2500*time.Millisecond {
+		t.Errorf("expected 2.5s until the solicited advertisement, not %s", d)
+	}
+	clock.advance(2500 * time.Millisecond)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	if d := clock.wait(t); d != 16*time.Second {
+		t.Fatalf("unexpected interval %s", d)
+	}
+
+	// a solicitation right before the next advertisement waits for it
+	clock.advance(15800 * time.Millisecond)
+	if err := b.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-clock.d:
+		t.Errorf("unexpected reschedule in %s", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// otherwise it is answered after the random delay
+	clock.advance(200 * time.Millisecond)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	if d := clock.wait(t); d != 600*time.Second {
+		t.Fatalf("unexpected interval %s", d)
+	}
+	clock.advance(10 * time.Second)
+	if err := b.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.wait(t); d != MaxRADelayTime {
+		t.Errorf("expected %s until the solicited advertisement, not %s", MaxRADelayTime, d)
+	}
+}
+
+func TestRAServerSetConfig(t *testing.T) {
+	s, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	for i := 0; i < 3; i++ {
+		clock.advance(clock.wait(t))
+		clock.fire <- clock.now()
+		readRA(t, b)
+	}
+	if d := clock.wait(t); d != 600*time.Second {
+		t.Fatalf("unexpected interval %s", d)
+	}
+
+	cfg := DefaultRAConfig()
+	cfg.RouterLifetime = 0
+	cfg.MaxInterval = 1
+	if err := s.SetConfig(cfg); err == nil {
+		t.Error("expected error for invalid config")
+	}
+	cfg.MaxInterval = 600 * time.Second
+
+	// the change is advertised right away and quickly repeated
+	clock.advance(time.Minute)
+	if err := s.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s.Config().RouterLifetime != 0 {
+		t.Errorf("unexpected config %+v", s.Config())
+	}
+	if d := clock.wait(t); d != 0 {
+		t.Errorf("expected changed config to be advertised right away, not in %s", d)
+	}
+	clock.fire <- clock.now()
+	if ra := readRA(t, b); ra.RouterLifeTime != 0 {
+		t.Errorf("unexpected router lifetime %d", ra.RouterLifeTime)
+	}
+	if d := clock.wait(t); d != 16*time.Second {
+		t.Errorf("unexpected interval %s", d)
+	}
+}
+
+func TestRAServerDeprecate(t *testing.T) {
+	s, clock, b, stop := serveRAServer(t, nil)
+
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+	cfg := DefaultRAConfig()
+	cfg.Prefixes = []RAPrefix{NewRAPrefix(p1), NewRAPrefix(p2)}
+	if err := s.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(clock.wait(t))
+	clock.fire <- clock.now()
+	readRA(t, b)
+	clock.wait(t)
+
+	// a removed prefix is deprecated in the next few advertisements
+	cfg.Prefixes = cfg.Prefixes[1:]
+	if err := s.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= MaxFinalRtrAdvertisements; i++ {
+		clock.advance(clock.wait(t))
+		clock.fire <- clock.now()
+
+		var prefixes []string
+		for _, o := range readRA(t, b).Options {
+			if pi, ok := o.(*ICMPOptionPrefixInformation); ok {
+				prefixes = append(prefixes, pi.Prefix.String())
+				if pi.Prefix.Equal(p1.IP) && pi.PreferredLifetime != 0 {
+					t.Errorf("expected removed prefix to be deprecated, not %s", pi)
+				}
+			}
+		}
+
+		expected := 2
+		if i == MaxFinalRtrAdvertisements {
+			expected = 1
+		}
+		if len(prefixes) != expected {
+			t.Errorf("expected %d prefixes in advertisement %d, not %v", expected, i, prefixes)
+		}
+	}
+
+	// a final advertisement is sent regardless of the rate limit
+	s.c.SetRateLimiting(true)
+	if err := s.c.SendRA(cfg.Advertisement(), nil); err != nil {
+		t.Fatal(err)
+	}
+	readRA(t, b)
+	if err := s.c.SendRA(cfg.Advertisement(), nil); err != ErrRateLimited {
+		t.Fatalf("expected rate limit, not %v", err)
+	}
+	stop()
+	ra := readRA(t, b)
+	if ra.RouterLifeTime != 0 {
+		t.Errorf("expected final advertisement, not %s", ra)
+	}
+	var n int
+	for _, o := range ra.Options {
+		if pi, ok := o.(*ICMPOptionPrefixInformation); ok {
+			n++
+			if pi.PreferredLifetime != 0 || pi.ValidLifetime == 0 {
+				t.Errorf("expected deprecated prefix, not %s", pi)
+			}
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected 1 prefix in final advertisement, not %d", n)
+	}
+}
+
+func TestRAServerInconsistent(t *testing.T) {
+	issues := make(chan RAInconsistency, 16)
+	_, clock, b, stop := serveRAServer(t, func(s *RAServer) {
+		s.Inconsistent = func(i RAInconsistency) {
+			issues <- i
+		}
+	})
+	defer stop()
+
+	clock.wait(t)
+	ra := DefaultRAConfig().Advertisement()
+	ra.HopLimit = 32
+	if err := b.WriteTo(ra, nil, net.IPv6linklocalallnodes); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case i := <-issues:
+		if i.String() != "router fe80::2 advertises hop limit 32, we advertise 64" {
+			t.Errorf("unexpected inconsistency %s", i)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for inconsistency")
+	}
+}
+
+func TestRAServerUnicastSolicited(t *testing.T) {
+	s, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	clock.wait(t)
+
+	cfg := s.Config()
+	cfg.UnicastSolicited = true
+	if err := s.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	// the changed config is advertised after MinDelayBetweenRAs
+	if d := clock.wait(t); d != MinDelayBetweenRAs {
+		t.Fatalf("unexpected interval %s", d)
+	}
+	clock.advance(MinDelayBetweenRAs)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	if d := clock.wait(t); d != 16*time.Second {
+		t.Fatalf("unexpected interval %s", d)
+	}
+
+	// solicitations are answered after the random delay to the host only
+	if err := b.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.wait(t); d != MaxRADelayTime {
+		t.Errorf("expected %s until the solicited advertisement, not %s", MaxRADelayTime, d)
+	}
+	clock.advance(MaxRADelayTime)
+	clock.fire <- clock.now()
+	readRAFrom(t, b, b.Addr())
+	if d := clock.wait(t); d != 16*time.Second-MaxRADelayTime {
+		t.Errorf("unexpected interval %s", d)
+	}
+
+	if solicitors := s.Solicitors(); len(solicitors) != 1 || !solicitors[0].Equal(b.Addr()) {
+		t.Errorf("unexpected solicitors %v", solicitors)
+	}
+
+	// hosts are forgotten after a router lifetime
+	clock.advance(cfg.RouterLifetime)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	clock.wait(t)
+	if solicitors := s.Solicitors(); len(solicitors) != 0 {
+		t.Errorf("unexpected solicitors %v", solicitors)
+	}
+}
+
+func TestRAServerWatchPrefixes(t *testing.T) {
+	s, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	clock.wait(t)
+
+	prefixes := func(ra *ICMPRouterAdvertisement) map[string]*ICMPOptionPrefixInformation {
+		pis := make(map[string]*ICMPOptionPrefixInformation)
+		for _, o := range ra.Options {
+			if pi, ok := o.(*ICMPOptionPrefixInformation); ok {
+				pis[pi.Prefix.String()] = pi
+			}
+		}
+		return pis
+	}
+
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.WatchPrefixes(ctx, StaticPrefixes{NewRAPrefix(p1)})
+	}()
+
+	// sourced prefixes are advertised right away
+	clock.advance(clock.wait(t))
+	clock.fire <- clock.now()
+	if pis := prefixes(readRA(t, b)); len(pis) != 1 || pis[p1.IP.String()] == nil {
+		t.Errorf("unexpected prefixes %v", pis)
+	}
+	clock.wait(t)
+	if len(s.Config().Prefixes) != 0 {
+		t.Errorf("unexpected configured prefixes %v", s.Config().Prefixes)
+	}
+
+	// and deprecated once no longer watched
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+	clock.advance(clock.wait(t))
+	clock.fire <- clock.now()
+	if pi := prefixes(readRA(t, b))[p1.IP.String()]; pi == nil || pi.PreferredLifetime != 0 {
+		t.Errorf("expected deprecated prefix, not %v", pi)
+	}
+	clock.wait(t)
+
+	// invalid prefixes stop watching
+	_, v4, _ := net.ParseCIDR("192.0.2.0/24")
+	if err := s.WatchPrefixes(context.Background(), StaticPrefixes{NewRAPrefix(v4)}); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
+
+func TestRAServerWatchPREF64(t *testing.T) {
+	s, clock, b, stop := serveRAServer(t, nil)
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	readRA(t, b)
+	clock.wait(t)
+
+	pref64 := func(ra *ICMPRouterAdvertisement) *ICMPOptionPREF64 {
+		for _, o := range ra.Options {
+			if o, ok := o.(*ICMPOptionPREF64); ok {
+				return o
+			}
+		}
+		return nil
+	}
+
+	_, prefix, _ := net.ParseCIDR("64:ff9b::/96")
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.WatchPREF64(ctx, StaticPREF64{Prefix: prefix})
+	}()
+
+	// the sourced prefix is advertised right away
+	clock.advance(clock.wait(t))
+	clock.fire <- clock.now()
+	if o := pref64(readRA(t, b)); o == nil || !o.Prefix.Equal(prefix.IP) || o.PrefixLength != 96 {
+		t.Errorf("unexpected pref64 %v", o)
+	}
+	clock.wait(t)
+	if s.Config().PREF64 != nil {
+		t.Errorf("unexpected configured pref64 %s", s.Config().PREF64)
+	}
+
+	// and no longer once not watched
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+	clock.advance(clock.wait(t))
+	clock.fire <- clock.now()
+	if o := pref64(readRA(t, b)); o != nil {
+		t.Errorf("unexpected pref64 %s", o)
+	}
+	clock.wait(t)
+
+	// invalid prefixes stop watching
+	_, long, _ := net.ParseCIDR("64:ff9b::/80")
+	if err := s.WatchPREF64(context.Background(), StaticPREF64{Prefix: long}); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
@@ -0,0 +1,276 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+)
+
+var (
+	errServing = errors.New("ra service already serving")
+)
+
+// RAService runs an RAServer on each of a set of interfaces, each with its
+// own RAConfig, and applies configuration changes while running
+type RAService struct {
+	// Logger, when set, is the logger of the Conns of all interfaces, see
+	// Conn.SetLogger. It must be set before calling Serve
+	Logger *slog.Logger
+
+	// listen returns the Conn to advertise on for an interface, overridden
+	// by tests
+	listen func(name string) (*Conn, error)
+
+	mu      sync.Mutex
+	cfgs    map[string]RAConfig
+	ctx     context.Context
+	servers map[string]*raInstance
+	errc    chan error
+}
+
+// raInstance is the RAServer of a single interface of an RAService
+type raInstance struct {
+	s      *RAServer
+	c      *Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRAService returns an RAService advertising cfgs, keyed by interface
+// name, as returned by ParseRadvdConfig
+func NewRAService(cfgs map[string]RAConfig) (*RAService, error) {
+	if err := validateRAConfigs(cfgs); err != nil {
+		return nil, err
+	}
+
+	return &RAService{
+		listen: listenRouter,
+		cfgs:   copyRAConfigs(cfgs),
+	}, nil
+}
+
+// Configs returns the RAConfig of every interface
+func (s *RAService) Configs() map[string]RAConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyRAConfigs(s.cfgs)
+}
+
+// Serve advertises on all configured interfaces until ctx is done or
+// advertising on one of them fails, in which case all stop
+func (s *RAService) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return errServing
+	}
+	s.ctx = ctx
+	s.servers = make(map[string]*raInstance)
+	s.errc = make(chan error, 1)
+	for name, cfg := range s.cfgs {
+		if err := s.start(name, cfg); err != nil {
+			s.stopAll()
+			s.mu.Unlock()
+			return err
+		}
+	}
+	errc := s.errc
+	s.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-errc:
+	}
+
+	s.mu.Lock()
+	s.stopAll()
+	s.mu.Unlock()
+
+	return err
+}
+
+// Reload replaces the configuration of all interfaces with cfgs. While
+// serving, advertising starts on added interfaces and stops on removed ones,
+// and changed configuration is passed on to the running RAServer through
+// SetConfig, leaving interfaces with unchanged configuration alone. Either
+// all of cfgs is applied or, on error, none of it
+func (s *RAService) Reload(cfgs map[string]RAConfig) error {
+	if err := validateRAConfigs(cfgs); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		var added []string
+		for name, cfg := range cfgs {
+			if _, ok := s.cfgs[name]; ok {
+				continue
+			}
+			if err := s.start(name, cfg); err != nil {
+				for _, name := range added {
+					s.stop(name)
+				}
+				return err
+			}
+			added = append(added, name)
+		}
+
+		for name, i := range s.servers {
+			if _, ok := s.cfgs[name]; !ok {
+				// just started
+				continue
+			}
+			cfg, ok := cfgs[name]
+			if !ok {
+				s.stop(name)
+				continue
+			}
+			// SetConfig starts over with the initial advertisements, which
+			// unchanged interfaces don't need
+			if reflect.DeepEqual(cfg, s.cfgs[name]) {
+				continue
+			}
+			// configs were validated above
+			i.s.SetConfig(cfg)
+		}
+	}
+
+	s.cfgs = copyRAConfigs(cfgs)
+
+	return nil
+}
+
+// ReloadOnSignal calls load and passes its result to Reload whenever one of
+// sigs, typically syscall.SIGHUP, is received until ctx is done. The result
+// of the latest reload is available on the returned channel, which is closed
+// when ctx is done. Results that aren't read before the next reload are
+// dropped
+func (s *RAService) ReloadOnSignal(ctx context.Context, load func() (map[string]RAConfig, error), sigs ...os.Signal) <-chan error {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, sigs...)
+
+	results := make(chan error, 1)
+	go func() {
+		defer close(results)
+		defer signal.Stop(sigc)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigc:
+			}
+
+			cfgs, err := load()
+			if err == nil {
+				err = s.Reload(cfgs)
+			}
+
+			select {
+			case results <- err:
+			default:
+				// replace the unread result, we're its only sender
+				select {
+				case <-results:
+				default:
+				}
+				results <- err
+			}
+		}
+	}()
+
+	return results
+}
+
+// start starts advertising cfg on interface name. It must be called with mu
+// held while serving
+func (s *RAService) start(name string, cfg RAConfig) error {
+	c, err := s.listen(name)
+	if err != nil {
+		return fmt.Errorf("interface %s: %s", name, err)
+	}
+
+	c.SetLogger(s.Logger)
+	srv, err := NewRAServer(c, cfg)
+	if err != nil {
+		c.Close()
+		return fmt.Errorf("interface %s: %s", name, err)
+	}
+	c.logger().Info("advertising")
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	i := &raInstance{
+		s:      srv,
+		c:      c,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	s.servers[name] = i
+
+	go func() {
+		defer close(i.done)
+		if err := srv.Serve(ctx); ctx.Err() == nil {
+			select {
+			case s.errc <- fmt.Errorf("interface %s: %s", name, err):
+			default:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop stops advertising on interface name. It must be called with mu held
+func (s *RAService) stop(name string) {
+	i := s.servers[name]
+	i.cancel()
+	<-i.done
+	i.c.Close()
+	delete(s.servers, name)
+}
+
+func (s *RAService) stopAll() {
+	for name := range s.servers {
+		s.stop(name)
+	}
+	s.ctx = nil
+}
+
+// listenRouter returns a Conn with RoleRouter on the interface called name
+func listenRouter(name string) (*Conn, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Listen(ifi, RoleRouter)
+}
+
+func validateRAConfigs(cfgs map[string]RAConfig) error {
+	for name, cfg := range cfgs {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("interface %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func copyRAConfigs(cfgs map[string]RAConfig) map[string]RAConfig {
+	c := make(map[string]RAConfig, len(cfgs))
+	for name, cfg := range cfgs {
+		c[name] = cfg
+	}
+
+	return c
+}
@@ -0,0 +1,190 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// pipeListener hands out the router end of a Pipe per interface
+type pipeListener struct {
+	mu    sync.Mutex
+	hosts map[string]*Conn
+}
+
+func (l *pipeListener) listen(name string) (*Conn, error) {
+	if name == "missing" {
+		return nil, errors.New("no such interface")
+	}
+
+	a, b := Pipe()
+	a.role = RoleRouter
+	a.SetAllowedTypes(RoleRouter.Types()...)
+
+	l.mu.Lock()
+	l.hosts[name] = b
+	l.mu.Unlock()
+
+	return a, nil
+}
+
+func (l *pipeListener) host(name string) *Conn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hosts[name]
+}
+
+func TestRAService(t *testing.T) {
+	cfg := DefaultRAConfig()
+	bad := cfg
+	bad.MaxInterval = 0
+	if _, err := NewRAService(map[string]RAConfig{"eth0": bad}); err == nil {
+		t.Error("expected error for invalid config")
+	}
+
+	s, err := NewRAService(map[string]RAConfig{"eth0": cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &pipeListener{hosts: make(map[string]*Conn)}
+	s.listen = l.listen
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(ctx)
+	}()
+
+	// wait for the first advertisement
+	for l.host("eth0") == nil {
+		time.Sleep(time.Millisecond)
+	}
+	readRA(t, l.host("eth0"))
+
+	// add an interface and change the other
+	changed := cfg
+	changed.HopLimit = 255
+	if err := s.Reload(map[string]RAConfig{"eth0": changed, "eth1": cfg}); err != nil {
+		t.Fatal(err)
+	}
+	for l.host("eth1") == nil {
+		time.Sleep(time.Millisecond)
+	}
+	readRA(t, l.host("eth1"))
+
+	s.mu.Lock()
+	if len(s.servers) != 2 || s.servers["eth0"].s.Config().HopLimit != 255 {
+		t.Errorf("unexpected servers %v", s.servers)
+	}
+	s.mu.Unlock()
+
+	// unchanged interfaces don't start over
+	if err := s.Reload(map[string]RAConfig{"eth0": changed, "eth1": cfg}); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	srv := s.servers["eth1"].s
+	s.mu.Unlock()
+	srv.mu.Lock()
+	if srv.sent == 0 {
+		t.Error("expected unchanged interface to keep advertising")
+	}
+	srv.mu.Unlock()
+
+	// failing reloads change nothing
+	if err := s.Reload(map[string]RAConfig{"eth0": bad}); err == nil {
+		t.Error("expected error for invalid config")
+	}
+	if err := s.Reload(map[string]RAConfig{"eth0": cfg, "eth2": cfg, "missing": cfg}); err == nil {
+		t.Error("expected error for missing interface")
+	}
+	if len(s.Configs()) != 2 {
+		t.Errorf("unexpected configs %v", s.Configs())
+	}
+
+	// remove an interface
+	if err := s.Reload(map[string]RAConfig{"eth1": cfg}); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	if _, ok := s.servers["eth0"]; len(s.servers) != 1 || ok {
+		t.Errorf("unexpected servers %v", s.servers)
+	}
+	s.mu.Unlock()
+
+	if err := s.Serve(ctx); err != errServing {
+		t.Errorf("expected error for serving twice, not %v", err)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected cancellation, not %v", err)
+	}
+	if len(s.servers) != 0 {
+		t.Errorf("expected all servers stopped, not %v", s.servers)
+	}
+}
+
+func TestRAServiceReloadOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on windows")
+	}
+
+	s, err := NewRAService(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loaded := map[string]RAConfig{"eth0": DefaultRAConfig()}
+	results := s.ReloadOnSignal(ctx, func() (map[string]RAConfig, error) {
+		return loaded, nil
+	}, syscall.SIGHUP)
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reload")
+	}
+	if _, ok := s.Configs()["eth0"]; !ok {
+		t.Errorf("unexpected configs %v", s.Configs())
+	}
+
+	// reloading doesn't wait for results to be read
+	for i := 0; i < 2; i++ {
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reload")
+	}
+
+	cancel()
+	if _, ok := <-results; ok {
+		t.Error("expected results to be closed")
+	}
+}
@@ -0,0 +1,210 @@
+package ndp
+
+import (
+	"container/list"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when sending a message would exceed the rate
+// at which messages of its kind may be sent
+var ErrRateLimited = errors.New("message rate limited")
+
+// no RFC limits the total amount of NDP messages on a link, but nothing
+// legitimate comes near a burst of 100 or a sustained 100 per second
+const (
+	linkRateInterval = 10 * time.Millisecond
+	linkRateBurst    = 100
+	// forget about destinations beyond this many
+	maxRateBuckets = 4096
+)
+
+// tokenBucket allows a burst of messages, replenished at one per interval
+type tokenBucket struct {
+	interval time.Duration
+	burst    int
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(interval time.Duration, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		interval: interval,
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     now,
+	}
+}
+
+// refill adds the tokens replenished since last and reports whether the
+// bucket is full
+func (b *tokenBucket) refill(now time.Time) bool {
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.last = now
+
+	return b.tokens == float64(b.burst)
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// keyedBucket is the tokenBucket of a key in the lru list of a bucketSet
+type keyedBucket struct {
+	key string
+	*tokenBucket
+}
+
+// bucketSet holds a tokenBucket per key, forgetting about the least recently
+// used ones beyond max
+type bucketSet struct {
+	max     int
+	buckets map[string]*list.Element
+	// lru holds the keyedBuckets, most recently used first
+	lru *list.List
+}
+
+func newBucketSet(max int) *bucketSet {
+	return &bucketSet{
+		max:     max,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// bucket returns the bucket of key, creating it with given rate if needed
+func (s *bucketSet) bucket(key string, interval time.Duration, burst int, now time.Time) *tokenBucket {
+	if e, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(keyedBucket).tokenBucket
+	}
+
+	if len(s.buckets) >= s.max {
+		s.prune(now)
+	}
+	if len(s.buckets) >= s.max {
+		// this only lets the key of the evicted bucket through a little
+		// early
+		s.remove(s.lru.Back())
+	}
+
+	b := newTokenBucket(interval, burst, now)
+	s.buckets[key] = s.lru.PushFront(keyedBucket{key: key, tokenBucket: b})
+
+	return b
+}
+
+// prune drops the least recently used buckets that are full, since they
+// behave like new ones, up to the first one that isn't
+func (s *bucketSet) prune(now time.Time) {
+	for e := s.lru.Back(); e != nil; e = s.lru.Back() {
+		if !e.Value.(keyedBucket).refill(now) {
+			return
+		}
+		s.remove(e)
+	}
+}
+
+func (s *bucketSet) remove(e *list.Element) {
+	delete(s.buckets, e.Value.(keyedBucket).key)
+	s.lru.Remove(e)
+}
+
+// rateLimiter limits outgoing messages per interface and per destination,
+// following the intervals RFC 4861 prescribes for each kind of message
+type rateLimiter struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	link *tokenBucket
+	*bucketSet
+}
+
+func newRateLimiter() *rateLimiter {
+	l := &rateLimiter{
+		now:       time.Now,
+		bucketSet: newBucketSet(maxRateBuckets),
+	}
+	l.link = newTokenBucket(linkRateInterval, linkRateBurst, l.now())
+
+	return l
+}
+
+// allow reports whether m may be sent to dst now and takes a token if so
+func (l *rateLimiter) allow(m ICMP, dst net.IP) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key, interval, burst := rateLimit(m, dst)
+	if key == "" {
+		return l.link.allow(now)
+	}
+
+	// evicted buckets still leave the link limited as a whole
+	b := l.bucket(key, interval, burst, now)
+
+	// don't take a token from the link when the message is dropped anyway
+	b.refill(now)
+	if b.tokens < 1 || !l.link.allow(now) {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// rateLimit returns the bucket key and rate for given message to dst, or an
+// empty key for messages only subject to the limit of the link
+func rateLimit(m ICMP, dst net.IP) (string, time.Duration, int) {
+	key := m.Type().String() + " to " + dst.String()
+	switch m := m.(type) {
+	case *ICMPRouterSolicitation:
+		// https://tools.ietf.org/html/rfc4861#section-6.3.7
+		return key, RtrSolicitationInterval, 1
+	case *ICMPRouterAdvertisement:
+		// https://tools.ietf.org/html/rfc4861#section-6.2.6
+		return key, MinDelayBetweenRAs, 1
+	case *ICMPNeighborSolicitation:
+		// https://tools.ietf.org/html/rfc4861#section-7.2.2
+		return key + " for " + m.TargetAddress.String(), RetransTimer, 1
+	case *ICMPNeighborAdvertisement:
+		// https://tools.ietf.org/html/rfc4861#section-7.2.6
+		key += " for " + m.TargetAddress.String()
+		if dst.IsMulticast() {
+			return key, RetransTimer, MaxNeighborAdvertisement
+		}
+
+		return key, RetransTimer, 1
+	case *ICMPRedirect:
+		// https://tools.ietf.org/html/rfc4861#section-8.2
+		return key + " for " + m.DestinationAddress.String(), RetransTimer, 1
+	}
+
+	return "", 0, 0
+}
+
+// SetRateLimiting enables or disables the rate limiting of WriteTo and the
+// functions using it. Conns are rate limited unless disabled, which is only
+// sensible for tools that deliberately generate traffic, like testers. It
+// must not be called while other goroutines write to this Conn
+func (c *Conn) SetRateLimiting(enabled bool) {
+	if !enabled {
+		c.limit = nil
+		return
+	}
+
+	if c.limit == nil {
+		c.limit = newRateLimiter()
+	}
+}
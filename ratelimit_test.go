@@ -0,0 +1,117 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newRateLimiter()
+	l.now = func() time.Time { return now }
+	l.link = newTokenBucket(linkRateInterval, linkRateBurst, now)
+
+	ra := &ICMPRouterAdvertisement{}
+	if !l.allow(ra, net.IPv6linklocalallnodes) {
+		t.Errorf("first RA should be allowed")
+	}
+	if l.allow(ra, net.IPv6linklocalallnodes) {
+		t.Errorf("second RA within MinDelayBetweenRAs should be limited")
+	}
+
+	// other destinations have their own budget
+	if !l.allow(ra, net.ParseIP("fe80::2")) {
+		t.Errorf("unicast RA should be allowed")
+	}
+
+	now = now.Add(MinDelayBetweenRAs)
+	if !l.allow(ra, net.IPv6linklocalallnodes) {
+		t.Errorf("RA after MinDelayBetweenRAs should be allowed")
+	}
+
+	// unsolicited NAs may be sent in a burst
+	na := &ICMPNeighborAdvertisement{TargetAddress: net.ParseIP("fe80::1")}
+	for i := 0; i < MaxNeighborAdvertisement; i++ {
+		if !l.allow(na, net.IPv6linklocalallnodes) {
+			t.Errorf("unsolicited NA %d should be allowed", i)
+		}
+	}
+	if l.allow(na, net.IPv6linklocalallnodes) {
+		t.Errorf("unsolicited NA exceeding MaxNeighborAdvertisement should be limited")
+	}
+
+	// solicitations are limited per target
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::1")}
+	group, _ := SolicitedNodeMulticast(ns.TargetAddress)
+	if !l.allow(ns, group) || l.allow(ns, group) {
+		t.Errorf("NS should be allowed once per RetransTimer")
+	}
+	if !l.allow(&ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}, group) {
+		t.Errorf("NS for other target should be allowed")
+	}
+
+	now = now.Add(RetransTimer / 2)
+	if l.allow(ns, group) {
+		t.Errorf("NS within RetransTimer should be limited")
+	}
+	now = now.Add(RetransTimer / 2)
+	if !l.allow(ns, group) {
+		t.Errorf("NS after RetransTimer should be allowed")
+	}
+
+	// the link as a whole is limited too
+	for i := 0; i < linkRateBurst; i++ {
+		target := net.ParseIP("fd00::")
+		target[15] = byte(i)
+		l.allow(&ICMPNeighborSolicitation{TargetAddress: target}, group)
+	}
+	if l.allow(&ICMPRouterSolicitation{}, net.IPv6linklocalallrouters) {
+		t.Errorf("link rate should be exceeded")
+	}
+
+	// full buckets are pruned
+	now = now.Add(time.Hour)
+	l.prune(now)
+	if len(l.buckets) != 0 {
+		t.Errorf("expected all buckets to be pruned, %d left", len(l.buckets))
+	}
+
+	// beyond the maximum the least recently used bucket makes way
+	l.link = newTokenBucket(linkRateInterval, 2*maxRateBuckets, now)
+	for i := 0; i < maxRateBuckets+1; i++ {
+		dst := net.ParseIP("fe80::")
+		dst[14], dst[15] = byte(i>>8), byte(i)
+		if !l.allow(ra, dst) {
+			t.Errorf("first RA to %s should be allowed", dst)
+		}
+	}
+	if len(l.buckets) != maxRateBuckets {
+		t.Errorf("expected %d buckets, not %d", maxRateBuckets, len(l.buckets))
+	}
+	if !l.allow(ra, net.ParseIP("fe80::")) {
+		t.Errorf("RA to evicted destination should be allowed")
+	}
+}
+
+func TestConnRateLimiting(t *testing.T) {
+	tt := &testTransport{}
+	c := &Conn{t: tt}
+	c.SetRateLimiting(true)
+
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Error(err)
+	}
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != ErrRateLimited {
+		t.Errorf("expected rate limiting, not %v", err)
+	}
+
+	c.SetRateLimiting(false)
+	if err := c.WriteTo(&ICMPRouterSolicitation{}, nil, net.IPv6linklocalallrouters); err != nil {
+		t.Error(err)
+	}
+
+	if len(tt.out) != 2 {
+		t.Errorf("expected 2 written packets, not %d", len(tt.out))
+	}
+}
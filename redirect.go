@@ -0,0 +1,159 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+)
+
+// the maximum number of redirected destinations DestinationCache keeps
+const defaultMaxDestinations = 1024
+
+// Destination is a destination that a router redirected to a better first
+// hop
+type Destination struct {
+	Address net.IP
+	// NextHop is the better first hop, which is Address itself when the
+	// destination turned out to be a neighbor
+	NextHop net.IP
+	// Router is the router that sent the redirect
+	Router net.IP
+}
+
+// DestinationCache processes redirects as described at
+// https://tools.ietf.org/html/rfc4861#section-8, keeping the first hops that
+// routers redirected destinations to. A redirect is only accepted from the
+// router a destination is sent through: the first hop of an earlier redirect
+// or else the best of the default routers
+type DestinationCache struct {
+	// Neighbors, when set, learns the link-layer addresses of the targets
+	// of accepted redirects
+	Neighbors *NeighborCache
+	// Changed, when set, is called for every accepted redirect
+	Changed func(Destination)
+	// MaxEntries caps the number of destinations, 0 for no limit. The
+	// destination redirected longest ago makes room for a new one. It
+	// defaults to 1024
+	MaxEntries int
+
+	routers *RouterList
+
+	mu           sync.Mutex
+	destinations map[string]*destinationEntry
+	// seq orders the destinations by when they were redirected
+	seq uint64
+}
+
+// destinationEntry is a Destination with the order it was redirected in
+type destinationEntry struct {
+	Destination
+	seq uint64
+}
+
+// NewDestinationCache returns an empty DestinationCache of which the
+// destinations go through the best router of routers until redirected
+func NewDestinationCache(routers *RouterList) *DestinationCache {
+	return &DestinationCache{
+		MaxEntries:   defaultMaxDestinations,
+		routers:      routers,
+		destinations: make(map[string]*destinationEntry),
+	}
+}
+
+// Update processes a redirect read from md.Source, so Update can be passed to
+// Mux.HandleRedirect. Redirects that aren't from the current first hop of
+// their destination or that fail the checks at
+// https://tools.ietf.org/html/rfc4861#section-8.1 are ignored, except for
+// the hop limit, which Conn.Serve checks as SetAcceptUnknownHopLimit tells
+func (dc *DestinationCache) Update(r *ICMPRedirect, md *Metadata) {
+	if md == nil || !validContent(r, md) {
+		return
+	}
+
+	first, ok := dc.NextHop(r.DestinationAddress)
+	if !ok || !first.Equal(md.Source) {
+		return
+	}
+
+	d := Destination{
+		Address: append(net.IP(nil), r.DestinationAddress.To16()...),
+		NextHop: append(net.IP(nil), r.TargetAddress.To16()...),
+		Router:  append(net.IP(nil), md.Source.To16()...),
+	}
+	dc.mu.Lock()
+	key := d.Address.String()
+	if _, ok := dc.destinations[key]; !ok && dc.MaxEntries > 0 && len(dc.destinations) >= dc.MaxEntries {
+		dc.evict()
+	}
+	dc.seq++
+	dc.destinations[key] = &destinationEntry{Destination: d, seq: dc.seq}
+	dc.mu.Unlock()
+
+	if dc.Neighbors != nil {
+		dc.Neighbors.redirected(r)
+	}
+	if dc.Changed != nil {
+		dc.Changed(d)
+	}
+}
+
+// NextHop returns the first hop to send packets for dst through: the one it
+// was redirected to or else the best default router. Whether dst is on-link
+// in the first place, see PrefixList.OnLink, is up to the caller
+func (dc *DestinationCache) NextHop(dst net.IP) (net.IP, bool) {
+	dc.mu.Lock()
+	e, ok := dc.destinations[dst.String()]
+	dc.mu.Unlock()
+	if ok {
+		return e.NextHop, true
+	}
+
+	r, ok := dc.routers.Best()
+	return r.Address, ok
+}
+
+// Destinations returns the redirected destinations, sorted by address
+func (dc *DestinationCache) Destinations() []Destination {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	destinations := make([]Destination, 0, len(dc.destinations))
+	for _, e := range dc.destinations {
+		destinations = append(destinations, e.Destination)
+	}
+	sort.Slice(destinations, func(i, j int) bool {
+		return bytes.Compare(destinations[i].Address, destinations[j].Address) < 0
+	})
+
+	return destinations
+}
+
+// Forget removes the destinations redirected to or by nextHop, so they go
+// through the default routers again, like when nextHop is unreachable or no
+// longer a router as described at
+// https://tools.ietf.org/html/rfc4861#section-7.3.3
+func (dc *DestinationCache) Forget(nextHop net.IP) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	for key, e := range dc.destinations {
+		if e.NextHop.Equal(nextHop) || e.Router.Equal(nextHop) {
+			delete(dc.destinations, key)
+		}
+	}
+}
+
+// evict removes the destination redirected longest ago. It must be called
+// with mu held
+func (dc *DestinationCache) evict() {
+	var oldest *destinationEntry
+	for _, e := range dc.destinations {
+		if oldest == nil || e.seq < oldest.seq {
+			oldest = e
+		}
+	}
+	if oldest != nil {
+		delete(dc.destinations, oldest.Address.String())
+	}
+}
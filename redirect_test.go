@@ -0,0 +1,109 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestDestinationCache(t *testing.T) {
+	routers := NewRouterList()
+	defer routers.Stop()
+	nc, _, _, _ := testNeighborCache(t)
+	dc := NewDestinationCache(routers)
+	dc.Neighbors = nc
+	var changes []Destination
+	dc.Changed = func(d Destination) { changes = append(changes, d) }
+
+	router, other := net.ParseIP("fe80::1"), net.ParseIP("fe80::2")
+	dst := net.ParseIP("2001:db8::1")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	from := func(ip net.IP) *Metadata { return &Metadata{Source: ip, HopLimit: 255} }
+	redirect := func(target, dst net.IP, lla net.HardwareAddr) *ICMPRedirect {
+		r := &ICMPRedirect{TargetAddress: target, DestinationAddress: dst}
+		if lla != nil {
+			r.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+		}
+		return r
+	}
+
+	// without default router nothing is accepted
+	if _, ok := dc.NextHop(dst); ok {
+		t.Error("unexpected next hop")
+	}
+	dc.Update(redirect(other, dst, lla), from(router))
+	if len(changes) != 0 {
+		t.Error("unexpected redirect without router")
+	}
+
+	routers.Update(&ICMPRouterAdvertisement{RouterLifeTime: 1800}, from(router))
+	if hop, _ := dc.NextHop(dst); !hop.Equal(router) {
+		t.Errorf("expected %s, not %s", router, hop)
+	}
+
+	// only the current first hop redirects
+	dc.Update(redirect(other, dst, lla), from(other))
+	// the target is a router or the destination itself
+	dc.Update(redirect(net.ParseIP("2001:db8::2"), dst, lla), from(router))
+	// destinations are unicast
+	dc.Update(redirect(other, net.ParseIP("ff02::1"), lla), from(router))
+	if len(changes) != 0 {
+		t.Fatalf("unexpected redirects %v", changes)
+	}
+
+	// the hop limit is left to Conn.Serve, which may accept unknown ones
+	dc.Update(redirect(other, dst, lla), &Metadata{Source: router, HopLimitUnknown: true})
+	if len(changes) != 1 || !changes[0].NextHop.Equal(other) || !changes[0].Router.Equal(router) {
+		t.Fatalf("unexpected redirects %v", changes)
+	}
+	if hop, _ := dc.NextHop(dst); !hop.Equal(other) {
+		t.Errorf("expected %s, not %s", other, hop)
+	}
+	// the target is another router
+	if n, ok := nc.Lookup(other); !ok || n.State != NeighborStale || !n.IsRouter || !bytes.Equal(n.LinkLayerAddress, lla) {
+		t.Errorf("unexpected neighbor %+v", n)
+	}
+
+	// from now on the new first hop redirects, here to the destination
+	// itself, which turns out to be a neighbor
+	dc.Update(redirect(dst, dst, lla), from(router))
+	if len(changes) != 1 {
+		t.Error("unexpected redirect by the former first hop")
+	}
+	dc.Update(redirect(dst, dst, nil), from(other))
+	if hop, _ := dc.NextHop(dst); !hop.Equal(dst) {
+		t.Errorf("expected %s, not %s", dst, hop)
+	}
+	// without link-layer address the target isn't learned
+	if _, ok := nc.Lookup(dst); ok {
+		t.Error("unexpected neighbor")
+	}
+	if d := dc.Destinations(); len(d) != 1 || !d[0].Router.Equal(other) {
+		t.Errorf("unexpected destinations %v", d)
+	}
+
+	// forgotten destinations go through the default router again
+	dc.Forget(dst)
+	if hop, _ := dc.NextHop(dst); !hop.Equal(router) {
+		t.Errorf("expected %s, not %s", router, hop)
+	}
+}
+
+func TestDestinationCacheLimit(t *testing.T) {
+	routers := NewRouterList()
+	defer routers.Stop()
+	router := net.ParseIP("fe80::1")
+	md := &Metadata{Source: router, HopLimit: 255}
+	routers.Update(&ICMPRouterAdvertisement{RouterLifeTime: 1800}, md)
+
+	dc := NewDestinationCache(routers)
+	dc.MaxEntries = 2
+	for _, dst := range []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"} {
+		dc.Update(&ICMPRedirect{TargetAddress: net.ParseIP("fe80::2"), DestinationAddress: net.ParseIP(dst)}, md)
+	}
+
+	d := dc.Destinations()
+	if len(d) != 2 || !d[0].Address.Equal(net.ParseIP("2001:db8::2")) {
+		t.Errorf("expected the oldest destination to be evicted, not %v", d)
+	}
+}
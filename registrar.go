@@ -0,0 +1,241 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the defaults of Registrar
+const (
+	defaultMaxRegistrations = 1024
+)
+
+// Registration is an address registered with Registrar by the node owning
+// EUI64
+type Registration struct {
+	Address          net.IP
+	EUI64            net.HardwareAddr
+	LinkLayerAddress net.HardwareAddr
+	Expires          time.Time
+}
+
+// Registrar implements the address registration of 6LoWPAN routers and
+// border routers as described at
+// https://tools.ietf.org/html/rfc6775#section-6.5. Hosts register their
+// addresses with neighbor solicitations carrying an Address Registration
+// option, which Registrar answers with a neighbor advertisement telling
+// whether the address was registered, is in use by another node or didn't
+// fit the registration table
+type Registrar struct {
+	// MaxEntries bounds the registrations kept, above which registering is
+	// answered with AddressRegistrationStatusCacheFull. It defaults to 1024
+	MaxEntries int
+	// Registered, when set, is called for every address registered anew
+	// or by another link-layer address
+	Registered func(Registration)
+	// Removed, when set, is called for every registration that ended,
+	// because its node removed it or its lifetime ran out
+	Removed func(Registration)
+
+	c *Conn
+
+	mu      sync.Mutex
+	entries map[string]*Registration
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewRegistrar returns a Registrar answering the registrations read from c,
+// which must have been created with RoleRouter
+func NewRegistrar(c *Conn) (*Registrar, error) {
+	if c.Role() != RoleRouter {
+		return nil, errNotRouter
+	}
+
+	return &Registrar{
+		MaxEntries: defaultMaxRegistrations,
+		c:          c,
+		entries:    make(map[string]*Registration),
+		now:        time.Now,
+	}, nil
+}
+
+// Serve answers the registrations read from the Conn until ctx is done or
+// reading fails
+func (r *Registrar) Serve(ctx context.Context) error {
+	return r.c.Serve(ctx, r)
+}
+
+// ServeNDP answers m, received with md, when it is a neighbor solicitation
+// registering an address
+func (r *Registrar) ServeNDP(m ICMP, md *Metadata) {
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok || md == nil {
+		return
+	}
+	var aro *ICMPOptionAddressRegistration
+	for _, o := range ns.Options {
+		if o, ok := o.(*ICMPOptionAddressRegistration); ok {
+			aro = o
+		}
+	}
+	// registrations come from the address registered, along with the
+	// link-layer address to reach it at
+	lla := sourceLinkLayerAddr(ns.Options)
+	if aro == nil || lla == nil || md.Source.IsUnspecified() || md.Source.IsMulticast() {
+		return
+	}
+
+	status, events := r.register(md.Source, aro, lla)
+	r.notify(events)
+
+	r.answer(ns, md, aro, lla, status)
+}
+
+// Lookup returns the registration of ip, if any
+func (r *Registrar) Lookup(ip net.IP) (Registration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[ip.String()]
+	if !ok || !e.Expires.After(r.now()) {
+		return Registration{}, false
+	}
+
+	return *e, true
+}
+
+// Registrations returns the registrations of the node owning eui64, or all
+// registrations if eui64 is nil, sorted by address
+func (r *Registrar) Registrations(eui64 net.HardwareAddr) []Registration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var regs []Registration
+	for _, e := range r.entries {
+		if e.Expires.After(now) && (eui64 == nil || bytes.Equal(e.EUI64, eui64)) {
+			regs = append(regs, *e)
+		}
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return bytes.Compare(regs[i].Address, regs[j].Address) < 0
+	})
+
+	return regs
+}
+
+// registerEvent is a registration to report to Registered, or Removed if
+// removed is set
+type registerEvent struct {
+	reg     Registration
+	removed bool
+}
+
+// register processes the registration aro of ip by lla, returning its status
+// along with the events to report
+func (r *Registrar) register(ip net.IP, aro *ICMPOptionAddressRegistration, lla net.HardwareAddr) (AddressRegistrationStatus, []registerEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	events := r.expire(now)
+	key := ip.String()
+	e, ok := r.entries[key]
+	if ok && !bytes.Equal(e.EUI64, aro.EUI64) {
+		return AddressRegistrationStatusDuplicate, events
+	}
+
+	// a lifetime of 0 removes the registration
+	if aro.RegistrationLifetime == 0 {
+		if ok {
+			delete(r.entries, key)
+			events = append(events, registerEvent{reg: *e, removed: true})
+		}
+		return AddressRegistrationStatusSuccess, events
+	}
+
+	if !ok {
+		if r.MaxEntries > 0 && len(r.entries) >= r.MaxEntries {
+			return AddressRegistrationStatusCacheFull, events
+		}
+		e = &Registration{
+			Address: append(net.IP(nil), ip.To16()...),
+			EUI64:   append(net.HardwareAddr(nil), aro.EUI64...),
+		}
+		r.entries[key] = e
+	}
+	moved := !bytes.Equal(e.LinkLayerAddress, lla)
+	e.LinkLayerAddress = append(net.HardwareAddr(nil), lla...)
+	e.Expires = now.Add(time.Duration(aro.RegistrationLifetime) * time.Minute)
+	if moved {
+		events = append(events, registerEvent{reg: *e})
+	}
+
+	return AddressRegistrationStatusSuccess, events
+}
+
+// expire removes the registrations whose lifetime ended at now, returning
+// them as events. It must be called with mu held
+func (r *Registrar) expire(now time.Time) []registerEvent {
+	var events []registerEvent
+	for key, e := range r.entries {
+		if !e.Expires.After(now) {
+			delete(r.entries, key)
+			events = append(events, registerEvent{reg: *e, removed: true})
+		}
+	}
+
+	return events
+}
+
+// notify reports events to Registered and Removed
+func (r *Registrar) notify(events []registerEvent) {
+	log := r.c.logger()
+	for _, ev := range events {
+		msg := "registered address"
+		if ev.removed {
+			msg = "registration removed"
+		}
+		log.Info(msg, "addr", ev.reg.Address.String(), "eui64", ev.reg.EUI64.String())
+		if ev.removed && r.Removed != nil {
+			r.Removed(ev.reg)
+		} else if !ev.removed && r.Registered != nil {
+			r.Registered(ev.reg)
+		}
+	}
+}
+
+// answer sends the advertisement answering registration ns, received with
+// md, with status. Addresses that can't be registered aren't the node's to
+// use, so they're answered to the link-local address of its EUI-64
+func (r *Registrar) answer(ns *ICMPNeighborSolicitation, md *Metadata, aro *ICMPOptionAddressRegistration, lla net.HardwareAddr, status AddressRegistrationStatus) {
+	na := &ICMPNeighborAdvertisement{
+		Router:        true,
+		Solicited:     true,
+		TargetAddress: ns.TargetAddress.To16(),
+	}
+	na.AddOption(&ICMPOptionAddressRegistration{
+		Status:               status,
+		RegistrationLifetime: aro.RegistrationLifetime,
+		EUI64:                aro.EUI64,
+	})
+
+	dst := md.Source
+	if status != AddressRegistrationStatusSuccess {
+		id, err := ModifiedEUI64(aro.EUI64)
+		if err != nil {
+			return
+		}
+		dst = make(net.IP, net.IPv6len)
+		dst[0], dst[1] = 0xfe, 0x80
+		copy(dst[8:], id)
+	}
+
+	r.c.WriteTo(na, &Metadata{DestinationLinkLayerAddress: lla}, dst)
+}
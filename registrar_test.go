@@ -0,0 +1,84 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRegistrar(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+	if _, err := NewRegistrar(a); err != errNotRouter {
+		t.Errorf("unexpected error %v", err)
+	}
+	a.role = RoleRouter
+
+	r, err := NewRegistrar(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxEntries = 1
+	now := time.Unix(0, 0)
+	r.now = func() time.Time { return now }
+	var registered, removed []Registration
+	r.Registered = func(reg Registration) { registered = append(registered, reg) }
+	r.Removed = func(reg Registration) { removed = append(removed, reg) }
+
+	eui := net.HardwareAddr{0x02, 0, 0, 0xff, 0xfe, 0, 0, 0x02}
+	register := func(ip string, eui64 net.HardwareAddr, lifetime uint16) (*ICMPOptionAddressRegistration, *Metadata) {
+		t.Helper()
+		ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP(ip)}
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: b.Interface().HardwareAddr})
+		ns.AddOption(&ICMPOptionAddressRegistration{RegistrationLifetime: lifetime, EUI64: eui64})
+		r.ServeNDP(ns, &Metadata{Source: net.ParseIP(ip), HopLimit: 255})
+
+		na, md := readAdvertisement(t, b)
+		if !na.TargetAddress.Equal(net.ParseIP(ip)) || !na.Router || !na.Solicited || len(na.Options) != 1 {
+			t.Fatalf("unexpected advertisement %s", na)
+		}
+		return na.Options[0].(*ICMPOptionAddressRegistration), md
+	}
+
+	aro, md := register("2001:db8::2", eui, 10)
+	if aro.Status != AddressRegistrationStatusSuccess || aro.RegistrationLifetime != 10 || !md.Destination.Equal(net.ParseIP("2001:db8::2")) {
+		t.Errorf("unexpected registration %s to %s", aro, md.Destination)
+	}
+	if reg, ok := r.Lookup(net.ParseIP("2001:db8::2")); !ok || reg.LinkLayerAddress.String() != b.Interface().HardwareAddr.String() || !reg.Expires.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("unexpected registration %+v", reg)
+	}
+	if regs := r.Registrations(eui); len(regs) != 1 || len(registered) != 1 {
+		t.Errorf("unexpected registrations %v", regs)
+	}
+
+	// other nodes can't register the address, and are told so at their own
+	// link-local address
+	other := net.HardwareAddr{0x02, 0, 0, 0xff, 0xfe, 0, 0, 0x03}
+	aro, md = register("2001:db8::2", other, 10)
+	if aro.Status != AddressRegistrationStatusDuplicate || !md.Destination.Equal(net.ParseIP("fe80::ff:fe00:3")) {
+		t.Errorf("unexpected registration %s to %s", aro, md.Destination)
+	}
+	if aro, _ = register("2001:db8::3", other, 10); aro.Status != AddressRegistrationStatusCacheFull {
+		t.Errorf("unexpected registration %s", aro)
+	}
+	if len(r.Registrations(other)) != 0 {
+		t.Error("unexpected registrations")
+	}
+
+	// refreshing isn't reported, removing is
+	register("2001:db8::2", eui, 10)
+	if aro, _ = register("2001:db8::2", eui, 0); aro.Status != AddressRegistrationStatusSuccess || len(registered) != 1 || len(removed) != 1 {
+		t.Errorf("unexpected registration %s", aro)
+	}
+
+	// as is running out
+	register("2001:db8::3", other, 1)
+	now = now.Add(time.Minute)
+	if _, ok := r.Lookup(net.ParseIP("2001:db8::3")); ok {
+		t.Error("unexpected registration")
+	}
+	if aro, _ = register("2001:db8::2", eui, 10); aro.Status != AddressRegistrationStatusSuccess || len(removed) != 2 {
+		t.Errorf("unexpected registration %s", aro)
+	}
+}
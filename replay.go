@@ -0,0 +1,157 @@
+package ndp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var errTimestamp = errors.New("timestamp not fresh")
+
+// the defaults of ReplayCache
+const (
+	defaultReplayPeers  = 1024
+	defaultReplayNonces = 64
+)
+
+// ReplayCache tells fresh SEND messages from replayed ones as described at
+// https://tools.ietf.org/html/rfc3971#section-5.3. It keeps the timestamp of
+// the last message of every source, along with the nonces of the
+// solicitations sent, which answers echo
+type ReplayCache struct {
+	// Delta is how far off the clock of a new source may be. It defaults
+	// to TimestampDelta
+	Delta time.Duration
+	// Fuzz is how far off the timestamps of a source may be from one
+	// message to the next. It defaults to TimestampFuzz
+	Fuzz time.Duration
+	// Drift is how much the clock of a source may drift from ours. It
+	// defaults to TimestampDrift
+	Drift float64
+	// MaxPeers is how many sources to keep track of before forgetting about
+	// the one heard from longest ago. It defaults to 1024
+	MaxPeers int
+	// MaxNonces is how many solicitations to await answers for before
+	// forgetting about the oldest. It defaults to 64
+	MaxNonces int
+
+	mu     sync.Mutex
+	peers  map[string]*replayPeer
+	nonces map[uint64]time.Time
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// replayPeer is the timestamp of the last message of a source, along with
+// when it was received
+type replayPeer struct {
+	ts, rd time.Time
+}
+
+// NewReplayCache returns a ReplayCache with the defaults of RFC 3971
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{
+		Delta:     TimestampDelta,
+		Fuzz:      TimestampFuzz,
+		Drift:     TimestampDrift,
+		MaxPeers:  defaultReplayPeers,
+		MaxNonces: defaultReplayNonces,
+		peers:     make(map[string]*replayPeer),
+		nonces:    make(map[uint64]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Expect remembers nonce of a solicitation that is sent, so that answers
+// echoing it are known to be fresh for Delta
+func (rc *ReplayCache) Expect(nonce uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := rc.now()
+	for n, sent := range rc.nonces {
+		if now.Sub(sent) > rc.Delta {
+			delete(rc.nonces, n)
+		}
+	}
+	if _, ok := rc.nonces[nonce]; !ok && rc.MaxNonces > 0 && len(rc.nonces) >= rc.MaxNonces {
+		var oldest uint64
+		for n, sent := range rc.nonces {
+			if o, ok := rc.nonces[oldest]; !ok || sent.Before(o) {
+				oldest = n
+			}
+		}
+		delete(rc.nonces, oldest)
+	}
+
+	rc.nonces[nonce] = now
+}
+
+// Expected reports whether nonce is of a solicitation sent within Delta
+func (rc *ReplayCache) Expected(nonce uint64) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	sent, ok := rc.nonces[nonce]
+	return ok && rc.now().Sub(sent) <= rc.Delta
+}
+
+// Check returns an error unless a message from src with timestamp ts is
+// fresh. Answers echoing an expected nonce only need to be within Delta,
+// like messages of new sources and the unspecified address, which is
+// shared by every node doing duplicate address detection. Other messages
+// must not be older than the last one of their source, taking Fuzz and
+// Drift into account
+func (rc *ReplayCache) Check(src net.IP, ts time.Time, nonce uint64) error {
+	expected := nonce != 0 && rc.Expected(nonce)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rd := rc.now()
+	within := rd.Sub(ts) > -rc.Delta && rd.Sub(ts) < rc.Delta
+	if expected || src.IsUnspecified() {
+		if !within {
+			return errTimestamp
+		}
+		return nil
+	}
+
+	key := src.String()
+	p, ok := rc.peers[key]
+	if !ok {
+		if !within {
+			return errTimestamp
+		}
+		rc.remember(key, ts, rd)
+		return nil
+	}
+
+	drift := time.Duration(float64(rd.Sub(p.rd)) * (1 - rc.Drift))
+	if !ts.Add(rc.Fuzz).After(p.ts.Add(drift).Add(-rc.Fuzz)) {
+		return errTimestamp
+	}
+	if ts.After(p.ts) {
+		p.ts, p.rd = ts, rd
+	}
+
+	return nil
+}
+
+// remember adds the timestamp of source key, making room by dropping the
+// source heard from longest ago. It must be called with mu held
+func (rc *ReplayCache) remember(key string, ts, rd time.Time) {
+	if rc.MaxPeers > 0 && len(rc.peers) >= rc.MaxPeers {
+		var oldest string
+		for k, p := range rc.peers {
+			if oldest == "" || p.rd.Before(rc.peers[oldest].rd) {
+				oldest = k
+			}
+		}
+		delete(rc.peers, oldest)
+	}
+
+	rc.peers[key] = &replayPeer{ts: ts, rd: rd}
+}
@@ -0,0 +1,108 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReplayCacheCheck(t *testing.T) {
+	rc := NewReplayCache()
+	now := time.Unix(1000, 0)
+	rc.now = func() time.Time { return now }
+	src := net.ParseIP("fe80::1")
+
+	// new sources are checked against the clock
+	if err := rc.Check(src, now.Add(-TimestampDelta), 0); err != errTimestamp {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := rc.Check(src, now.Add(-time.Minute), 0); err != nil {
+		t.Error(err)
+	}
+	// then against their last message, even when far behind our clock
+	now = now.Add(time.Hour)
+	if err := rc.Check(src, now.Add(-time.Minute), 0); err != nil {
+		t.Error(err)
+	}
+	if err := rc.Check(src, now.Add(-time.Minute-TimestampFuzz), 0); err != nil {
+		t.Errorf("expected fuzz to be allowed, not %v", err)
+	}
+	if err := rc.Check(src, now.Add(-time.Minute-3*TimestampFuzz), 0); err != errTimestamp {
+		t.Errorf("unexpected error %v", err)
+	}
+	// clocks may drift a little
+	now = now.Add(1000 * time.Second)
+	if err := rc.Check(src, now.Add(-time.Minute-5*time.Second), 0); err != nil {
+		t.Errorf("expected drift to be allowed, not %v", err)
+	}
+	if err := rc.Check(src, now.Add(-time.Minute-20*time.Second), 0); err != errTimestamp {
+		t.Errorf("unexpected error %v", err)
+	}
+	now = now.Add(1000 * time.Second)
+	if err := rc.Check(src, now.Add(-100*time.Second), 0); err != errTimestamp {
+		t.Errorf("unexpected error %v", err)
+	}
+	rc.Drift = 0.05
+	if err := rc.Check(src, now.Add(-100*time.Second), 0); err != nil {
+		t.Errorf("expected configured drift to be allowed, not %v", err)
+	}
+
+	// the unspecified address is never remembered
+	if err := rc.Check(net.IPv6unspecified, now, 0); err != nil {
+		t.Error(err)
+	}
+	if _, ok := rc.peers[net.IPv6unspecified.String()]; ok {
+		t.Error("unexpected unspecified peer")
+	}
+}
+
+func TestReplayCacheNonces(t *testing.T) {
+	rc := NewReplayCache()
+	rc.MaxNonces = 2
+	now := time.Unix(1000, 0)
+	rc.now = func() time.Time { return now }
+	src := net.ParseIP("fe80::1")
+
+	if err := rc.Check(src, now, 0); err != nil {
+		t.Fatal(err)
+	}
+	rc.Expect(1)
+	// answers echoing the nonce may be older than the last message
+	if err := rc.Check(src, now.Add(-time.Minute), 1); err != nil {
+		t.Error(err)
+	}
+	if err := rc.Check(src, now.Add(-time.Minute), 2); err != errTimestamp {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// beyond MaxNonces the oldest is forgotten
+	now = now.Add(time.Second)
+	rc.Expect(2)
+	now = now.Add(time.Second)
+	rc.Expect(3)
+	if rc.Expected(1) || !rc.Expected(2) || !rc.Expected(3) {
+		t.Errorf("unexpected nonces %v", rc.nonces)
+	}
+	// as is any after Delta
+	now = now.Add(TimestampDelta)
+	if rc.Expected(2) || !rc.Expected(3) {
+		t.Errorf("unexpected nonces %v", rc.nonces)
+	}
+}
+
+func TestReplayCacheLimit(t *testing.T) {
+	rc := NewReplayCache()
+	rc.MaxPeers = 2
+	now := time.Unix(1000, 0)
+	rc.now = func() time.Time { return now }
+
+	for _, ip := range []string{"fe80::1", "fe80::2", "fe80::3"} {
+		now = now.Add(time.Second)
+		if err := rc.Check(net.ParseIP(ip), now, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := rc.peers["fe80::1"]; ok || len(rc.peers) != 2 {
+		t.Errorf("expected the oldest peer to be forgotten, not %v", rc.peers)
+	}
+}
@@ -0,0 +1,273 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRouter is a router on the default router list
+type DefaultRouter struct {
+	Address    net.IP
+	Preference RouterPreferenceField
+	// ValidUntil is when the router lifetime ends
+	ValidUntil time.Time
+}
+
+// RouterList maintains the default router list as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.4 from the router
+// advertisements passed to Update. Routers are removed once their lifetime
+// ends and ordered by their preference as described at
+// https://tools.ietf.org/html/rfc4191#section-3.2
+type RouterList struct {
+	// Changed, when set, is called with the routers, best first, whenever
+	// routers come or go or change preference
+	Changed func([]DefaultRouter)
+
+	mu    sync.Mutex
+	list  routerList
+	timer *time.Timer
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewRouterList returns an empty RouterList
+func NewRouterList() *RouterList {
+	return &RouterList{
+		list: newRouterList(),
+		now:  time.Now,
+	}
+}
+
+// Update processes a router advertisement read from md.Source, so Update
+// can be passed to Mux.HandleRouterAdvertisement
+func (l *RouterList) Update(ra *ICMPRouterAdvertisement, md *Metadata) {
+	l.mu.Lock()
+	changed := l.list.update(ra, md.Source, l.now())
+	l.mu.Unlock()
+
+	l.changed(changed)
+}
+
+// Routers returns the routers, best first
+func (l *RouterList) Routers() []DefaultRouter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list.sorted()
+}
+
+// Best returns the best router, if there is any
+func (l *RouterList) Best() (DefaultRouter, bool) {
+	routers := l.Routers()
+	if len(routers) == 0 {
+		return DefaultRouter{}, false
+	}
+
+	return routers[0], true
+}
+
+// ForSource returns the router to send packets from src through, as
+// described at https://tools.ietf.org/html/rfc8028#section-3. Routers that
+// advertised a prefix containing src are preferred, so multihomed hosts
+// don't send packets from the prefix of one provider through the router of
+// another, which might drop them
+func (l *RouterList) ForSource(src net.IP) (DefaultRouter, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.list.forSource(src, l.now())
+}
+
+// Stop stops expiring routers until the next Update
+func (l *RouterList) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// expire removes the routers whose lifetime ended
+func (l *RouterList) expire() {
+	l.mu.Lock()
+	changed := l.list.expire(l.now())
+	l.mu.Unlock()
+
+	l.changed(changed)
+}
+
+// changed schedules the next expiry and calls Changed if changed is set
+func (l *RouterList) changed(changed bool) {
+	l.mu.Lock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if next := l.list.next(); !next.IsZero() {
+		l.timer = time.AfterFunc(next.Sub(l.now()), l.expire)
+	}
+	routers := l.list.sorted()
+	l.mu.Unlock()
+
+	if changed && l.Changed != nil {
+		l.Changed(routers)
+	}
+}
+
+// routerList is the default router list without locking or timers, as used
+// by RouterList and SLAACClient
+type routerList struct {
+	routers map[string]*DefaultRouter
+	// prefixes holds the prefixes each router advertised, along with when
+	// their valid lifetime ends, which is zero if it doesn't
+	prefixes map[string]map[string]routerPrefix
+}
+
+// routerPrefix is a prefix advertised by a router
+type routerPrefix struct {
+	prefix     *net.IPNet
+	validUntil time.Time
+}
+
+func newRouterList() routerList {
+	return routerList{
+		routers:  make(map[string]*DefaultRouter),
+		prefixes: make(map[string]map[string]routerPrefix),
+	}
+}
+
+// update processes a router advertisement from src at now, reporting whether
+// routers came, went or changed preference
+func (l routerList) update(ra *ICMPRouterAdvertisement, src net.IP, now time.Time) bool {
+	key := src.String()
+	if ra.RouterLifeTime == 0 {
+		delete(l.prefixes, key)
+		if _, ok := l.routers[key]; ok {
+			delete(l.routers, key)
+			return true
+		}
+		return false
+	}
+	l.advertised(key, ra, now)
+
+	r := DefaultRouter{
+		Address:    src,
+		Preference: ra.RouterPreference,
+		ValidUntil: now.Add(time.Duration(ra.RouterLifeTime) * time.Second),
+	}
+	old, ok := l.routers[key]
+	l.routers[key] = &r
+
+	return !ok || old.Preference != r.Preference
+}
+
+// advertised records the prefixes router key advertises in ra at now
+func (l routerList) advertised(key string, ra *ICMPRouterAdvertisement, now time.Time) {
+	prefixes := l.prefixes[key]
+	for _, o := range ra.Options {
+		pi, ok := o.(*ICMPOptionPrefixInformation)
+		if !ok || pi.PrefixLength > 128 {
+			continue
+		}
+		mask := net.CIDRMask(int(pi.PrefixLength), 128)
+		prefix := &net.IPNet{IP: pi.Prefix.Mask(mask), Mask: mask}
+		if prefix.IP == nil {
+			continue
+		}
+
+		valid := pi.ValidLifetimeDuration()
+		if valid == 0 {
+			delete(prefixes, prefix.String())
+			continue
+		}
+		if prefixes == nil {
+			prefixes = make(map[string]routerPrefix)
+			l.prefixes[key] = prefixes
+		}
+		prefixes[prefix.String()] = routerPrefix{prefix: prefix, validUntil: lifetimeEnd(now, valid)}
+	}
+}
+
+// forSource returns the best router among those that advertised a prefix
+// containing src and are valid at now, or the best router if none did
+func (l routerList) forSource(src net.IP, now time.Time) (DefaultRouter, bool) {
+	routers := l.sorted()
+	for _, r := range routers {
+		for _, p := range l.prefixes[r.Address.String()] {
+			if p.prefix.Contains(src) && (p.validUntil.IsZero() || p.validUntil.After(now)) {
+				return r, true
+			}
+		}
+	}
+	if len(routers) == 0 {
+		return DefaultRouter{}, false
+	}
+
+	return routers[0], true
+}
+
+// expire removes the routers whose lifetime ended at now, reporting whether
+// there were any, along with the prefixes that ran out
+func (l routerList) expire(now time.Time) bool {
+	expired := false
+	for key, r := range l.routers {
+		if !r.ValidUntil.After(now) {
+			delete(l.routers, key)
+			delete(l.prefixes, key)
+			expired = true
+		}
+	}
+	for _, prefixes := range l.prefixes {
+		for pk, p := range prefixes {
+			if !p.validUntil.IsZero() && !p.validUntil.After(now) {
+				delete(prefixes, pk)
+			}
+		}
+	}
+
+	return expired
+}
+
+// next returns when the next router expires, if any
+func (l routerList) next() time.Time {
+	var next time.Time
+	for _, r := range l.routers {
+		if next.IsZero() || r.ValidUntil.Before(next) {
+			next = r.ValidUntil
+		}
+	}
+
+	return next
+}
+
+// sorted returns the routers by preference, and by address among routers of
+// equal preference
+func (l routerList) sorted() []DefaultRouter {
+	var routers []DefaultRouter
+	for _, r := range l.routers {
+		routers = append(routers, *r)
+	}
+	sort.Slice(routers, func(i, j int) bool {
+		if a, b := preferenceRank(routers[i].Preference), preferenceRank(routers[j].Preference); a != b {
+			return a < b
+		}
+		return bytes.Compare(routers[i].Address, routers[j].Address) < 0
+	})
+
+	return routers
+}
+
+// preferenceRank returns the rank of preference p, lowest first
+func preferenceRank(p RouterPreferenceField) int {
+	switch p {
+	case RouterPreferenceHigh:
+		return 0
+	case RouterPreferenceLow:
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,123 @@
+package ndp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRouterList(t *testing.T) {
+	l := NewRouterList()
+	defer l.Stop()
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+	var changes [][]DefaultRouter
+	l.Changed = func(routers []DefaultRouter) { changes = append(changes, routers) }
+
+	ra := func(lifetime uint16, pref RouterPreferenceField) *ICMPRouterAdvertisement {
+		return &ICMPRouterAdvertisement{RouterLifeTime: lifetime, RouterPreference: pref}
+	}
+	from := func(ip string) *Metadata { return &Metadata{Source: net.ParseIP(ip)} }
+
+	if _, ok := l.Best(); ok {
+		t.Error("expected no router")
+	}
+
+	l.Update(ra(1800, RouterPreferenceMedium), from("fe80::2"))
+	l.Update(ra(600, RouterPreferenceLow), from("fe80::1"))
+	l.Update(ra(900, RouterPreferenceHigh), from("fe80::3"))
+	// only refreshed
+	l.Update(ra(1800, RouterPreferenceMedium), from("fe80::2"))
+	// not a router
+	l.Update(ra(0, RouterPreferenceMedium), from("fe80::4"))
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, not %d", len(changes))
+	}
+
+	expected := []string{"fe80::3", "fe80::2", "fe80::1"}
+	routers := l.Routers()
+	if len(routers) != len(expected) {
+		t.Fatalf("unexpected routers %v", routers)
+	}
+	for i, ip := range expected {
+		if !routers[i].Address.Equal(net.ParseIP(ip)) {
+			t.Errorf("expected %s at %d, not %s", ip, i, routers[i].Address)
+		}
+	}
+	if best, ok := l.Best(); !ok || !best.Address.Equal(net.ParseIP("fe80::3")) || !best.ValidUntil.Equal(now.Add(900*time.Second)) {
+		t.Errorf("unexpected best router %+v", best)
+	}
+
+	// lifetimes end
+	now = now.Add(600 * time.Second)
+	l.expire()
+	if routers := l.Routers(); len(routers) != 2 || len(changes) != 4 {
+		t.Errorf("expected fe80::1 to expire, not %v", routers)
+	}
+	now = now.Add(time.Second)
+	l.expire()
+	if len(changes) != 4 {
+		t.Errorf("unexpected change %v", changes[len(changes)-1])
+	}
+
+	// preference changes and withdrawal
+	l.Update(ra(1800, RouterPreferenceHigh), from("fe80::2"))
+	l.Update(ra(0, RouterPreferenceMedium), from("fe80::3"))
+	if len(changes) != 6 || len(changes[5]) != 1 || !changes[5][0].Address.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestRouterListForSource(t *testing.T) {
+	l := NewRouterList()
+	defer l.Stop()
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	ra := func(pref RouterPreferenceField, prefix string, valid time.Duration) *ICMPRouterAdvertisement {
+		ra := &ICMPRouterAdvertisement{RouterLifeTime: 1800, RouterPreference: pref}
+		if prefix != "" {
+			ip, n, _ := net.ParseCIDR(prefix)
+			ones, _ := n.Mask.Size()
+			pi := &ICMPOptionPrefixInformation{Prefix: ip, PrefixLength: uint8(ones), Auto: true}
+			pi.SetValidLifetime(valid)
+			ra.AddOption(pi)
+		}
+		return ra
+	}
+	from := func(ip string) *Metadata { return &Metadata{Source: net.ParseIP(ip)} }
+
+	if _, ok := l.ForSource(net.ParseIP("2001:db8:1::1")); ok {
+		t.Error("expected no router")
+	}
+
+	// two providers, of which the first is preferred
+	l.Update(ra(RouterPreferenceHigh, "2001:db8:1::/64", time.Hour), from("fe80::1"))
+	l.Update(ra(RouterPreferenceMedium, "2001:db8:2::/64", time.Minute), from("fe80::2"))
+	tests := map[string]string{
+		"2001:db8:1::42": "fe80::1",
+		"2001:db8:2::42": "fe80::2",
+		// others fall back to the best router
+		"2001:db8:3::42": "fe80::1",
+	}
+	for src, router := range tests {
+		if r, ok := l.ForSource(net.ParseIP(src)); !ok || !r.Address.Equal(net.ParseIP(router)) {
+			t.Errorf("expected %s for %s, not %+v", router, src, r)
+		}
+	}
+
+	// prefixes count until their valid lifetime ends
+	now = now.Add(time.Minute)
+	if r, _ := l.ForSource(net.ParseIP("2001:db8:2::42")); !r.Address.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("unexpected router %+v", r)
+	}
+	l.Update(ra(RouterPreferenceMedium, "2001:db8:2::/64", time.Hour), from("fe80::2"))
+	if r, _ := l.ForSource(net.ParseIP("2001:db8:2::42")); !r.Address.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("unexpected router %+v", r)
+	}
+	// or the router is withdrawn
+	l.Update(&ICMPRouterAdvertisement{}, from("fe80::2"))
+	if r, _ := l.ForSource(net.ParseIP("2001:db8:2::42")); !r.Address.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("unexpected router %+v", r)
+	}
+}
@@ -0,0 +1,152 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// ErrNoAdvertisement is returned when no router answered router
+// solicitations
+var ErrNoAdvertisement = errors.New("no router advertisement received")
+
+// RSClient solicits router advertisements as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.7, optionally backing off
+// as described at https://tools.ietf.org/html/rfc7559 rather than giving up
+type RSClient struct {
+	// Interval is the time between solicitations, RtrSolicitationInterval
+	// if 0
+	Interval time.Duration
+	// MaxSolicitations is the number of solicitations sent before giving
+	// up, MaxRtrSolicitations if 0. Negative values never give up
+	MaxSolicitations int
+	// Backoff doubles Interval after every solicitation, up to MaxInterval.
+	// Unless MaxSolicitations is set, it never gives up
+	Backoff bool
+	// MaxInterval caps the interval of Backoff, MaxRtrSolicitationInterval
+	// if 0
+	MaxInterval time.Duration
+
+	c *Conn
+
+	// overridden by tests
+	rand func() float64
+}
+
+// NewRSClient returns an RSClient soliciting router advertisements on c
+func NewRSClient(c *Conn) *RSClient {
+	return &RSClient{
+		c:    c,
+		rand: rand.Float64,
+	}
+}
+
+// Solicit sends router solicitations until a router advertisement from a
+// link-local address is read, which it returns. It returns
+// ErrNoAdvertisement when MaxSolicitations went unanswered, or the error of
+// ctx when it is done first. It reads from the Conn itself, so it can't run
+// along with Conn.Serve
+func (r *RSClient) Solicit(ctx context.Context) (*ICMPRouterAdvertisement, *Metadata, error) {
+	ctx, end := r.c.startSpan(ctx, SpanSolicitRouter)
+	ra, md, err := r.solicit(ctx)
+	end(err)
+
+	return ra, md, err
+}
+
+// solicit implements Solicit
+func (r *RSClient) solicit(ctx context.Context) (*ICMPRouterAdvertisement, *Metadata, error) {
+	interval := r.Interval
+	if interval == 0 {
+		interval = RtrSolicitationInterval
+	}
+	maxInterval := r.MaxInterval
+	if maxInterval == 0 {
+		maxInterval = MaxRtrSolicitationInterval
+	}
+	left := r.MaxSolicitations
+	if left == 0 && !r.Backoff {
+		left = MaxRtrSolicitations
+	}
+
+	// don't have every host on a link solicit at once
+	delay := time.Duration(r.rand() * float64(MaxRtrSolicitationDelay))
+	if r.Interval != 0 && delay > r.Interval {
+		delay = r.Interval
+	}
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-time.After(delay):
+	}
+
+	// the first interval is randomized too when backing off
+	if r.Backoff {
+		interval = r.jitter(interval)
+	}
+	for sent := 0; left <= 0 || sent < left; sent++ {
+		if err := r.c.SendRS(); err != nil && err != ErrRateLimited {
+			return nil, nil, err
+		}
+
+		ra, md, err := r.wait(ctx, time.Now().Add(interval))
+		if err != os.ErrDeadlineExceeded {
+			if err == nil {
+				r.c.logger().Debug("solicited router advertisement", receivedAttrs(ra, md)...)
+			}
+			return ra, md, err
+		}
+
+		if r.Backoff {
+			interval = r.jitter(2 * interval)
+			if interval > maxInterval {
+				interval = r.jitter(maxInterval)
+			}
+		}
+	}
+
+	r.c.logger().Info("no router answered solicitations")
+	return nil, nil, ErrNoAdvertisement
+}
+
+// jitter returns d changed by up to a tenth either way, see
+// https://tools.ietf.org/html/rfc7559#section-2
+func (r *RSClient) jitter(d time.Duration) time.Duration {
+	return d + time.Duration((r.rand()*0.2-0.1)*float64(d))
+}
+
+// wait reads until a router advertisement arrives, returning
+// os.ErrDeadlineExceeded when none did by deadline
+func (r *RSClient) wait(ctx context.Context, deadline time.Time) (*ICMPRouterAdvertisement, *Metadata, error) {
+	rctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for {
+		m, md, err := r.c.ReadMessage(rctx)
+		if err != nil {
+			if cerr := ctx.Err(); cerr != nil {
+				return nil, nil, cerr
+			}
+			// deadlines may expire just before contexts notice
+			if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+				return nil, nil, context.DeadlineExceeded
+			}
+			if err == context.DeadlineExceeded || !time.Now().Before(deadline) {
+				return nil, nil, os.ErrDeadlineExceeded
+			}
+			if md != nil {
+				// not a message, but the transport is fine
+				continue
+			}
+			return nil, nil, err
+		}
+
+		// only routers on the link advertise, as described at
+		// https://tools.ietf.org/html/rfc4861#section-6.1.2
+		if ra, ok := m.(*ICMPRouterAdvertisement); ok && validMessage(m, md, r.c.unknownHopLimit) {
+			return ra, md, nil
+		}
+	}
+}
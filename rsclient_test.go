@@ -0,0 +1,115 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// testRSClient returns an RSClient on one end of a pipe, which solicits
+// without delay and with fixed intervals
+func testRSClient(interval time.Duration) (*RSClient, *Conn) {
+	a, b := Pipe()
+	b.role = RoleRouter
+
+	r := NewRSClient(a)
+	r.Interval = interval
+	// no jitter
+	r.rand = func() float64 { return 0.5 }
+
+	return r, b
+}
+
+// countRS counts the router solicitations c reads until it is closed
+func countRS(c *Conn) <-chan int {
+	n := make(chan int, 1)
+	go func() {
+		count := 0
+		for {
+			m, _, err := c.ReadFrom()
+			if err != nil {
+				n <- count
+				return
+			}
+			if _, ok := m.(*ICMPRouterSolicitation); ok {
+				count++
+			}
+		}
+	}()
+
+	return n
+}
+
+func TestRSClient(t *testing.T) {
+	r, b := testRSClient(time.Second)
+	defer r.c.Close()
+
+	go func() {
+		if _, _, err := b.ReadFrom(); err != nil {
+			t.Error(err)
+			return
+		}
+		// a truncated advertisement is skipped
+		b.t.WriteTo([]byte{134, 0, 0, 0}, nil, net.IPv6linklocalallnodes)
+		b.SendRA(DefaultRAConfig().Advertisement(), nil)
+	}()
+
+	ra, md, err := r.Solicit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.RouterLifeTime != 1800 || !md.Source.Equal(b.Addr()) {
+		t.Errorf("unexpected advertisement %+v from %s", ra, md.Source)
+	}
+}
+
+func TestRSClientTimeout(t *testing.T) {
+	r, b := testRSClient(10 * time.Millisecond)
+	n := countRS(b)
+
+	if _, _, err := r.Solicit(context.Background()); err != ErrNoAdvertisement {
+		t.Errorf("unexpected error %v", err)
+	}
+	b.Close()
+	if count := <-n; count != MaxRtrSolicitations {
+		t.Errorf("expected %d solicitations, not %d", MaxRtrSolicitations, count)
+	}
+}
+
+func TestRSClientBackoff(t *testing.T) {
+	r, b := testRSClient(10 * time.Millisecond)
+	r.Backoff = true
+	r.MaxInterval = 40 * time.Millisecond
+	n := countRS(b)
+
+	// 10, 20, 40 and 40ms
+	ctx, cancel := context.WithTimeout(context.Background(), 115*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := r.Solicit(ctx); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %s to time out", d)
+	}
+	b.Close()
+	if count := <-n; count != 4 {
+		t.Errorf("expected 4 solicitations, not %d", count)
+	}
+
+	r.MaxSolicitations = 2
+	if _, _, err := r.Solicit(context.Background()); err != ErrNoAdvertisement {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRSClientJitter(t *testing.T) {
+	r := NewRSClient(nil)
+	for _, f := range []float64{0, 0.5, 0.999} {
+		r.rand = func() float64 { return f }
+		if d := r.jitter(time.Second); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Errorf("jitter %s beyond a tenth", d)
+		}
+	}
+}
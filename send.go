@@ -0,0 +1,197 @@
+package ndp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	errNotRouter         = errors.New("only routers send router advertisements")
+	errRedirectNotRouter = errors.New("only routers send redirects")
+	errNotIPv6Packet     = errors.New("not an IPv6 packet")
+)
+
+// redirects, like all ICMPv6 errors, fit in the IPv6 minimum MTU of 1280
+// bytes less the IPv6 header, see
+// https://tools.ietf.org/html/rfc4861#section-4.6.3
+const maxRedirectLen = 1280 - 40
+
+// SendRS sends a router solicitation to all routers on the link
+func (c *Conn) SendRS() error {
+	rs := &ICMPRouterSolicitation{}
+	if lla := c.linkLayerAddr(); lla != nil {
+		rs.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	return c.WriteTo(rs, nil, net.IPv6linklocalallrouters)
+}
+
+// SendNS sends a neighbor solicitation to the solicited-node multicast group
+// of target to resolve its link-layer address
+func (c *Conn) SendNS(target net.IP) error {
+	group, _ := SolicitedNodeMulticast(target)
+	if group == nil {
+		return fmt.Errorf("target %s is not an IPv6 address", target)
+	}
+
+	ns := &ICMPNeighborSolicitation{
+		TargetAddress: target.To16(),
+	}
+	if lla := c.linkLayerAddr(); lla != nil {
+		ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	return c.WriteTo(ns, nil, group)
+}
+
+// SendNA sends a neighbor advertisement for target. With dst set it answers
+// a solicitation from dst, without it the advertisement is sent unsolicited
+// to all nodes to announce a changed link-layer address
+func (c *Conn) SendNA(target, dst net.IP) error {
+	na, err := c.neighborAdvertisement(target, dst != nil)
+	if err != nil {
+		return err
+	}
+
+	if dst == nil {
+		dst = net.IPv6linklocalallnodes
+	}
+
+	return c.WriteTo(na, nil, dst)
+}
+
+// neighborAdvertisement returns the advertisement SendNA sends for target
+func (c *Conn) neighborAdvertisement(target net.IP, solicited bool) (*ICMPNeighborAdvertisement, error) {
+	if target.To16() == nil || target.To4() != nil {
+		return nil, fmt.Errorf("target %s is not an IPv6 address", target)
+	}
+
+	na := &ICMPNeighborAdvertisement{
+		Router:        c.role == RoleRouter,
+		Solicited:     solicited,
+		Override:      true,
+		TargetAddress: target.To16(),
+	}
+	if lla := c.linkLayerAddr(); lla != nil {
+		na.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: lla})
+	}
+
+	return na, nil
+}
+
+// Announce sends MaxNeighborAdvertisement unsolicited neighbor advertisements
+// for every target, RetransTimer apart, as described at
+// https://tools.ietf.org/html/rfc4861#section-7.2.6. These override the
+// link-layer address neighbors have for the targets, so they reach this
+// node right after the addresses moved to it, like on failover of a virtual
+// IP. It returns early with the error of ctx when it is done
+func (c *Conn) Announce(ctx context.Context, targets ...net.IP) error {
+	nas := make([]*ICMPNeighborAdvertisement, 0, len(targets))
+	for _, target := range targets {
+		na, err := c.neighborAdvertisement(target, false)
+		if err != nil {
+			return err
+		}
+		nas = append(nas, na)
+	}
+
+	for i := 0; i < MaxNeighborAdvertisement; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(RetransTimer):
+			}
+		}
+
+		// the repetitions are limited already, and a burst of targets
+		// shouldn't be cut short
+		for _, na := range nas {
+			if err := c.writeTo(na, nil, net.IPv6linklocalallnodes, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SendRA sends given router advertisement to dst, or to all nodes if dst is
+// nil. A source link-layer address option is added when ra doesn't have one
+func (c *Conn) SendRA(ra *ICMPRouterAdvertisement, dst net.IP) error {
+	return c.sendRA(ra, dst, true)
+}
+
+// sendRA implements SendRA, skipping the rate limiter unless limit is set
+func (c *Conn) sendRA(ra *ICMPRouterAdvertisement, dst net.IP, limit bool) error {
+	if c.role != RoleRouter {
+		return errNotRouter
+	}
+
+	if lla := c.linkLayerAddr(); lla != nil && !ra.HasOption(ICMPOptionTypeSourceLinkLayerAddress) {
+		// leave the caller's message alone
+		cp := *ra
+		cp.Options = append(ICMPOptions{&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: lla}}, ra.Options...)
+		ra = &cp
+	}
+
+	if dst == nil {
+		dst = net.IPv6linklocalallnodes
+	}
+
+	return c.writeTo(ra, nil, dst, limit)
+}
+
+// SendRedirect tells the source of packet, an IPv6 packet this router is
+// forwarding, that target is a better first hop for its destination as
+// described at https://tools.ietf.org/html/rfc4861#section-8.2. The target
+// is either another router, by its link-local address, or the destination
+// itself when that is a neighbor. Its link-layer address is included when
+// targetLLA is set, and as much of packet as fits in the minimum MTU is
+// returned in the redirected header option. Redirects are rate limited per
+// source and destination
+func (c *Conn) SendRedirect(packet []byte, target net.IP, targetLLA net.HardwareAddr) error {
+	if c.role != RoleRouter {
+		return errRedirectNotRouter
+	}
+	if len(packet) < 40 || packet[0]>>4 != 6 {
+		return errNotIPv6Packet
+	}
+
+	src, dst := net.IP(packet[8:24]), net.IP(packet[24:40])
+	if src.IsUnspecified() || src.IsMulticast() || dst.IsMulticast() {
+		return fmt.Errorf("can't redirect packet from %s to %s", src, dst)
+	}
+	if !target.IsLinkLocalUnicast() && !target.Equal(dst) {
+		return fmt.Errorf("target %s is neither link-local nor destination %s", target, dst)
+	}
+
+	r := &ICMPRedirect{
+		TargetAddress:      target.To16(),
+		DestinationAddress: append(net.IP(nil), dst...),
+	}
+	room := maxRedirectLen - 40 - 8
+	if targetLLA != nil {
+		r.AddOption(&ICMPOptionTargetLinkLayerAddress{LinkLayerAddress: targetLLA})
+		room -= 8
+	}
+	if len(packet) > room {
+		packet = packet[:room]
+	}
+	r.AddOption(&ICMPOptionRedirectedHeader{Packet: packet})
+
+	return c.WriteTo(r, nil, src)
+}
+
+// linkLayerAddr returns the link-layer address of the interface of this
+// Conn, if it has one
+func (c *Conn) linkLayerAddr() net.HardwareAddr {
+	if c.ifi == nil || len(c.ifi.HardwareAddr) == 0 {
+		return nil
+	}
+
+	return c.ifi.HardwareAddr
+}
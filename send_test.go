@@ -0,0 +1,191 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	ifi := &net.Interface{
+		Index:        2,
+		Name:         "eth0",
+		HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01},
+	}
+	tt := &testTransport{}
+	c := &Conn{t: tt, ifi: ifi, role: RoleRouter}
+
+	tests := []struct {
+		send    func() error
+		dst     net.IP
+		encoded []byte
+	}{
+		{
+			func() error { return c.SendRS() },
+			net.IPv6linklocalallrouters,
+			[]byte{133, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 0, 0, 0, 0, 1},
+		},
+		{
+			func() error { return c.SendNS(net.ParseIP("fe80::1234:5678")) },
+			net.ParseIP("ff02::1:ff34:5678"),
+			[]byte{135, 0, 0, 0, 0, 0, 0, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 18, 52, 86, 120, 1, 1, 2, 0, 0, 0, 0, 1},
+		},
+		{
+			func() error { return c.SendNA(net.ParseIP("fe80::1"), net.ParseIP("fe80::2")) },
+			net.ParseIP("fe80::2"),
+			[]byte{136, 0, 0, 0, 224, 0, 0, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 1, 2, 0, 0, 0, 0, 1},
+		},
+		{
+			func() error { return c.SendNA(net.ParseIP("fe80::1"), nil) },
+			net.IPv6linklocalallnodes,
+			[]byte{136, 0, 0, 0, 160, 0, 0, 0, 254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 1, 2, 0, 0, 0, 0, 1},
+		},
+		{
+			func() error { return c.SendRA(&ICMPRouterAdvertisement{HopLimit: 64, RouterLifeTime: 1800}, nil) },
+			net.IPv6linklocalallnodes,
+			[]byte{134, 0, 0, 0, 64, 0, 7, 8, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 0, 0, 0, 0, 1},
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.send(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tt.out) != i+1 {
+			t.Fatalf("expected %d written packets, not %d", i+1, len(tt.out))
+		}
+
+		if bytes.Compare(tt.out[i], test.encoded) != 0 {
+			t.Errorf("fixture of %v did not match %v", test.encoded, tt.out[i])
+		}
+
+		if !tt.dst[i].Equal(test.dst) {
+			t.Errorf("expected destination %s, not %s", test.dst, tt.dst[i])
+		}
+	}
+
+	// existing source link-layer address options are kept
+	ra := &ICMPRouterAdvertisement{}
+	ra.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}})
+	if err := c.SendRA(ra, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(ra.Options) != 1 || len(tt.out[len(tt.out)-1]) != 24 {
+		t.Errorf("unexpected options in %v", tt.out[len(tt.out)-1])
+	}
+
+	if err := c.SendNS(net.ParseIP("192.0.2.1")); err == nil {
+		t.Errorf("expected error for IPv4 target")
+	}
+
+	// hosts don't advertise themselves as router
+	c.role = RoleHost
+	if err := c.SendRA(&ICMPRouterAdvertisement{}, nil); err != errNotRouter {
+		t.Errorf("expected error for host sending RA, not %v", err)
+	}
+
+	// links without link-layer addresses get no options
+	c.ifi = &net.Interface{Name: "tun0"}
+	if err := c.SendRS(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tt.out[len(tt.out)-1]) != 8 {
+		t.Errorf("unexpected options in %v", tt.out[len(tt.out)-1])
+	}
+}
+
+func TestAnnounce(t *testing.T) {
+	tt := &testTransport{}
+	c := &Conn{t: tt, ifi: &net.Interface{HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}}, role: RoleRouter}
+
+	if err := c.Announce(context.Background(), net.ParseIP("192.0.2.1")); err == nil {
+		t.Error("expected error for IPv4 target")
+	}
+
+	// the first round goes out right away
+	ctx, cancel := context.WithTimeout(context.Background(), RetransTimer/2)
+	defer cancel()
+	targets := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}
+	if err := c.Announce(ctx, targets...); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(tt.out) != len(targets) {
+		t.Fatalf("expected %d advertisements, not %d", len(targets), len(tt.out))
+	}
+	for i, b := range tt.out {
+		m, err := ParseMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		na, ok := m.(*ICMPNeighborAdvertisement)
+		if !ok || !na.Override || na.Solicited || !na.Router || !na.TargetAddress.Equal(targets[i]) {
+			t.Errorf("unexpected advertisement %s", m)
+		}
+		if !tt.dst[i].Equal(net.IPv6linklocalallnodes) {
+			t.Errorf("unexpected destination %s", tt.dst[i])
+		}
+	}
+}
+
+func TestSendRedirect(t *testing.T) {
+	tt := &testTransport{}
+	c := &Conn{t: tt, ifi: &net.Interface{HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}}, role: RoleHost, limit: newRateLimiter()}
+	src, dst := net.ParseIP("2001:db8::10"), net.ParseIP("2001:db8:1::1")
+	target := net.ParseIP("fe80::2")
+	lla := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+
+	packet := make([]byte, 1500)
+	packet[0] = 0x60
+	copy(packet[8:24], src)
+	copy(packet[24:40], dst)
+
+	if err := c.SendRedirect(packet, target, lla); err != errRedirectNotRouter {
+		t.Errorf("unexpected error %v", err)
+	}
+	c.role = RoleRouter
+	if err := c.SendRedirect(packet[:20], target, lla); err != errNotIPv6Packet {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := c.SendRedirect(packet, net.ParseIP("2001:db8::2"), lla); err == nil {
+		t.Error("expected error for global target")
+	}
+
+	if err := c.SendRedirect(packet, target, lla); err != nil {
+		t.Fatal(err)
+	}
+	if len(tt.out) != 1 || !tt.dst[0].Equal(src) {
+		t.Fatalf("expected a redirect to %s", src)
+	}
+	if len(tt.out[0]) != maxRedirectLen {
+		t.Errorf("expected redirect of %d bytes, not %d", maxRedirectLen, len(tt.out[0]))
+	}
+	m, err := ParseMessage(tt.out[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := m.(*ICMPRedirect)
+	if !ok || !r.TargetAddress.Equal(target) || !r.DestinationAddress.Equal(dst) {
+		t.Fatalf("unexpected redirect %s", m)
+	}
+	o, err := r.GetOption(ICMPOptionTypeRedirectedHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rh := (*o).(*ICMPOptionRedirectedHeader); !bytes.Equal(rh.Packet, packet[:len(rh.Packet)]) {
+		t.Error("redirected header doesn't hold the packet")
+	}
+
+	// redirects for the same destination are rate limited, others aren't
+	if err := c.SendRedirect(packet, target, lla); err != ErrRateLimited {
+		t.Errorf("expected rate limit, not %v", err)
+	}
+	copy(packet[24:40], net.ParseIP("2001:db8:2::1"))
+	if err := c.SendRedirect(packet[:64], target, nil); err != nil {
+		t.Error(err)
+	}
+	if len(tt.out) != 2 || len(tt.out[1]) != 40+8+64 {
+		t.Errorf("unexpected redirects %v", tt.out)
+	}
+}
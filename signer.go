@@ -0,0 +1,136 @@
+package ndp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	errSignerKeyType     = errors.New("send signatures take an rsa or ecdsa key")
+	errSignerKeyMismatch = errors.New("key doesn't match the public key of the cga parameters")
+	errNoOptions         = errors.New("message takes no options")
+)
+
+// cgaMessageTypeTag is the CGA Message Type tag of SEND as described at
+// https://tools.ietf.org/html/rfc3971#section-5.2
+var cgaMessageTypeTag = []byte{0x08, 0x6f, 0xca, 0x5e, 0x10, 0xb2, 0x00, 0xc9, 0x9c, 0x8c, 0xe0, 0x01, 0x64, 0x27, 0x7c, 0x08}
+
+// Signer secures outgoing messages with SEND as described at
+// https://tools.ietf.org/html/rfc3971, proving they come from the owner of
+// a cryptographically generated address. Messages are to be sent from the
+// address of Parameters, see GenerateCGA. RSA keys sign with RSASSA-PKCS1-v1_5
+// and SHA-1 as RFC 3971 has it, ECDSA keys with SHA-256 and ASN.1 encoded
+// signatures, carried in the same option, for the deployments that moved on
+// from RSA
+type Signer struct {
+	Key        crypto.Signer
+	Parameters *CGAParameters
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewSigner returns a Signer for the RSA or ECDSA key of which params holds
+// the public key
+func NewSigner(key crypto.Signer, params *CGAParameters) (*Signer, error) {
+	if signatureHash(key.Public()) == 0 {
+		return nil, errSignerKeyType
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if string(der) != string(params.PublicKey) {
+		return nil, errSignerKeyMismatch
+	}
+
+	return &Signer{Key: key, Parameters: params, now: time.Now}, nil
+}
+
+// Sign appends the CGA, Timestamp, Nonce and RSA Signature options to m,
+// which is to be sent from src to dst. Solicitations get a fresh nonce
+// unless given one, while advertisements only carry nonce when answering a
+// solicitation that had it, so leave nonce 0 for unsolicited ones. As the
+// signature covers the whole message, nothing may be added to m after
+func (s *Signer) Sign(m ICMP, src, dst net.IP, nonce uint64) error {
+	oa, ok := m.(optionAdder)
+	if !ok {
+		return errNoOptions
+	}
+
+	if nonce == 0 && (m.Type() == ipv6.ICMPTypeRouterSolicitation || m.Type() == ipv6.ICMPTypeNeighborSolicitation) {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b[2:]); err != nil {
+			return err
+		}
+		nonce = binary.BigEndian.Uint64(b)
+	}
+
+	oa.AddOption(&ICMPOptionCGA{Parameters: *s.Parameters})
+	oa.AddOption(&ICMPOptionTimestamp{Timestamp: s.now()})
+	if nonce != 0 {
+		oa.AddOption(&ICMPOptionNonce{Nonce: nonce})
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	hash := signatureHash(s.Key.Public())
+	sig, err := s.Key.Sign(rand.Reader, signedDigest(b, src, dst, hash), hash)
+	if err != nil {
+		return fmt.Errorf("can't sign message: %s", err)
+	}
+	oa.AddOption(&ICMPOptionRSASignature{KeyHash: keyHash(s.Parameters.PublicKey), Signature: sig})
+
+	return nil
+}
+
+// signatureHash returns the hash that signatures of key are taken with, or 0
+// for keys SEND doesn't sign with
+func signatureHash(key crypto.PublicKey) crypto.Hash {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return crypto.SHA1
+	case *ecdsa.PublicKey:
+		return crypto.SHA256
+	}
+
+	return 0
+}
+
+// signedDigest returns the digest using hash that the signature of message b
+// from src to dst is taken over, where b holds all options that precede it.
+// The checksum covers the signature itself, so it is left zero
+func signedDigest(b []byte, src, dst net.IP, hash crypto.Hash) []byte {
+	h := hash.New()
+	h.Write(cgaMessageTypeTag)
+	h.Write(src.To16())
+	h.Write(dst.To16())
+	h.Write(b[:2])
+	h.Write([]byte{0, 0})
+	h.Write(b[4:])
+
+	return h.Sum(nil)
+}
+
+// keyHash returns the key hash of the DER encoded public key der, which
+// is the leftmost 128 bits of its SHA-1 hash
+func keyHash(der []byte) [16]byte {
+	var kh [16]byte
+	h := sha1.Sum(der)
+	copy(kh[:], h[:16])
+
+	return kh
+}
@@ -0,0 +1,161 @@
+package ndp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+)
+
+// testSigner returns a Signer of a fresh RSA key and the CGA it signs for
+func testSigner(t *testing.T) (*Signer, net.IP) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeySigner(t, key)
+}
+
+// testKeySigner returns a Signer of key and the CGA it signs for
+func testKeySigner(t *testing.T, key crypto.Signer) (*Signer, net.IP) {
+	t.Helper()
+	addr, params, err := GenerateCGA(net.ParseIP("fe80::"), key.Public(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSigner(key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Unix(1000, 0) }
+
+	return s, addr
+}
+
+func TestNewSigner(t *testing.T) {
+	s, _ := testSigner(t)
+
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(other, s.Parameters); err != errSignerKeyMismatch {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(ed, s.Parameters); err != errSignerKeyType {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(ec, s.Parameters); err != errSignerKeyMismatch {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSignerSign(t *testing.T) {
+	s, addr := testSigner(t)
+	target := net.ParseIP("fe80::2")
+	group, _ := SolicitedNodeMulticast(target)
+
+	ns := &ICMPNeighborSolicitation{TargetAddress: target}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}})
+	if err := s.Sign(ns, addr, group, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	types := []ICMPOptionType{ICMPOptionTypeSourceLinkLayerAddress, ICMPOptionTypeCGA, ICMPOptionTypeTimestamp, ICMPOptionTypeNonce, ICMPOptionTypeRSASignature}
+	if len(ns.Options) != len(types) {
+		t.Fatalf("unexpected options %v", ns.Options)
+	}
+	for i, typ := range types {
+		if ns.Options[i].Type() != typ {
+			t.Errorf("expected %s at %d, not %s", typ, i, ns.Options[i].Type())
+		}
+	}
+	if n := ns.Options[3].(*ICMPOptionNonce); n.Nonce == 0 {
+		t.Error("expected a nonce for solicitations")
+	}
+
+	// the signature covers everything before it
+	b, err := ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := ns.Options[4].(*ICMPOptionRSASignature)
+	signed := b[:len(b)-int(sig.Len())*8]
+	pub := s.Key.Public().(*rsa.PublicKey)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA1, signedDigest(signed, addr, group, crypto.SHA1), sig.Signature); err != nil {
+		t.Error(err)
+	}
+	if sig.KeyHash != keyHash(s.Parameters.PublicKey) {
+		t.Errorf("unexpected key hash %x", sig.KeyHash)
+	}
+	// and the addresses it is sent between
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA1, signedDigest(signed, addr, target, crypto.SHA1), sig.Signature); err == nil {
+		t.Error("expected signature not to cover another destination")
+	}
+
+	// the parsed message carries the same options
+	m, err := ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, err := m.(*ICMPNeighborSolicitation).GetOption(ICMPOptionTypeTimestamp); err != nil || !(*o).(*ICMPOptionTimestamp).Timestamp.Equal(time.Unix(1000, 0)) {
+		t.Errorf("unexpected timestamp %v", o)
+	}
+
+	// unsolicited advertisements carry no nonce, answers echo it
+	na := &ICMPNeighborAdvertisement{TargetAddress: addr}
+	if err := s.Sign(na, addr, net.IPv6linklocalallnodes, 0); err != nil {
+		t.Fatal(err)
+	}
+	if na.HasOption(ICMPOptionTypeNonce) {
+		t.Error("unexpected nonce in unsolicited advertisement")
+	}
+	na = &ICMPNeighborAdvertisement{Solicited: true, TargetAddress: addr}
+	if err := s.Sign(na, addr, target, 42); err != nil {
+		t.Fatal(err)
+	}
+	if o, err := na.GetOption(ICMPOptionTypeNonce); err != nil || (*o).(*ICMPOptionNonce).Nonce != 42 {
+		t.Errorf("expected nonce to be echoed, not %v", o)
+	}
+}
+
+func TestSignerSignECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, addr := testKeySigner(t, key)
+
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}
+	if err := s.Sign(ns, addr, net.IPv6linklocalallnodes, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ns.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ECDSA signs with SHA-256
+	sig := ns.Options[len(ns.Options)-1].(*ICMPOptionRSASignature)
+	signed := b[:len(b)-int(sig.Len())*8]
+	if !ecdsa.VerifyASN1(&key.PublicKey, signedDigest(signed, addr, net.IPv6linklocalallnodes, crypto.SHA256), sig.Signature) {
+		t.Error("signature doesn't verify")
+	}
+}
@@ -0,0 +1,910 @@
+package ndp
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errNotHost          = errors.New("only hosts configure addresses")
+	errDuplicateAddress = errors.New("duplicate address")
+)
+
+// ModifiedEUI64 returns the interface identifier derived from a 48 or 64 bit
+// link-layer address as described at
+// https://tools.ietf.org/html/rfc4291#appendix-A
+func ModifiedEUI64(mac net.HardwareAddr) ([]byte, error) {
+	var id []byte
+	switch len(mac) {
+	case 6:
+		id = []byte{mac[0], mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}
+	case 8:
+		id = append([]byte(nil), mac...)
+	default:
+		return nil, fmt.Errorf("can't derive an interface identifier from %s", mac)
+	}
+	// flip the universal/local bit
+	id[0] ^= 0x02
+
+	return id, nil
+}
+
+// SLAACAddress is an address SLAACClient configured from an advertised
+// prefix
+type SLAACAddress struct {
+	// Address holds the address and the length of its prefix
+	Address *net.IPNet
+	// PreferredUntil and ValidUntil are when the preferred and valid
+	// lifetimes of the address end, or zero if they don't
+	PreferredUntil time.Time
+	ValidUntil     time.Time
+	// Temporary tells whether this is a temporary address, which
+	// applications should prefer for outgoing connections
+	Temporary bool
+	// Router is the first hop for packets from this address, see
+	// SLAACClient.Router, or nil without routers
+	Router net.IP
+}
+
+// Deprecated reports whether this address shouldn't be used for new
+// connections at now, because its preferred lifetime ended
+func (a SLAACAddress) Deprecated(now time.Time) bool {
+	return !a.PreferredUntil.IsZero() && !now.Before(a.PreferredUntil)
+}
+
+// SLAACState is the configuration SLAACClient learnt from router
+// advertisements
+type SLAACState struct {
+	Addresses []SLAACAddress
+	// Routers are the default routers, best first
+	Routers []DefaultRouter
+	// Prefixes are the on-link prefixes
+	Prefixes []OnLinkPrefix
+	// RDNSS and DNSSL are the DNS servers and search domains of all
+	// routers, see DNSTracker
+	RDNSS []net.IP
+	DNSSL []string
+	// Managed and OtherConfig are the M and O flags of the last router
+	// advertisement, which tell to get addresses or other configuration
+	// from DHCPv6
+	Managed     bool
+	OtherConfig bool
+	// the following are zero until a router advertises them
+	MTU           uint32
+	HopLimit      uint8
+	ReachableTime time.Duration
+	RetransTimer  time.Duration
+}
+
+// SLAACClient implements a host's side of stateless address
+// autoconfiguration as described at https://tools.ietf.org/html/rfc4862: it
+// solicits router advertisements, forms addresses in the prefixes they
+// advertise for autonomous configuration and tracks their lifetimes, along
+// with the default routers and DNS options learnt on the way. It doesn't
+// configure the interface itself, which is up to Changed
+type SLAACClient struct {
+	// InterfaceID returns the interface identifier of the address in
+	// prefix. When that address turns out to be in use, it's called again
+	// with attempt incremented. Without InterfaceID, the modified EUI-64 of
+	// the interface is used, see StableID for identifiers that don't reveal
+	// it. It must be set before calling Serve
+	InterfaceID func(prefix *net.IPNet, attempt int) ([]byte, error)
+	// Changed, when set, is called with the new state whenever addresses,
+	// routers or DNS options come or go, addresses become deprecated or
+	// advertised parameters change. Lifetimes that are merely refreshed
+	// don't count
+	Changed func(SLAACState)
+	// Duplicate, when set, is called for every address that duplicate
+	// address detection found in use by another node
+	Duplicate func(net.IP)
+	// Managed and OtherConfig, when set, are called when the M or O flag of
+	// router advertisements changes, so a DHCPv6 client can be started or
+	// stopped as described at https://tools.ietf.org/html/rfc4861#section-4.2
+	Managed     func(bool)
+	OtherConfig func(bool)
+	// DADTransmits is how many neighbor solicitations duplicate address
+	// detection sends before using an address, 0 to use addresses right
+	// away. These are sent from the unspecified address, which takes a
+	// Conn that can send from it, like the ones ListenFrames returns
+	DADTransmits int
+	// Temporary also forms a temporary address with a random interface
+	// identifier in every prefix as described at
+	// https://tools.ietf.org/html/rfc8981 and forms a new one before it
+	// becomes deprecated. Temporary addresses only last
+	// TempValidLifetime and are preferred for TempPreferredLifetime less a
+	// random desync factor, so hosts don't regenerate them in lockstep.
+	// These default to the constants of the same name
+	Temporary             bool
+	TempValidLifetime     time.Duration
+	TempPreferredLifetime time.Duration
+	// DNSPolicy, when set, filters the DNS servers and search domains
+	// routers advertise
+	DNSPolicy *DNSPolicy
+
+	c *Conn
+
+	mu      sync.Mutex
+	addrs   map[string]*slaacAddr
+	routers routerList
+	onLink  prefixList
+	dns     dnsList
+	params  slaacParams
+	// solicits is the number of router solicitations left to send, next
+	// when the next one is due
+	solicits int
+	next     time.Time
+	// wake tells Serve that something is due earlier
+	wake chan struct{}
+	// ctx is that of Serve, which the spans of duplicate address detection
+	// are children of
+	ctx context.Context
+	// changed tells whether the state changed since Changed was called
+	changed bool
+	// desync is how much earlier temporary addresses are deprecated, seq
+	// numbers them
+	desync time.Duration
+	seq    int
+
+	// overridden by tests
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+	rand  func() float64
+	nonce func() uint64
+	read  func([]byte) error
+}
+
+// slaacAddr is an address in an advertised prefix
+type slaacAddr struct {
+	// key is the prefix, or the prefix and a number for temporary
+	// addresses, of which a prefix can have several
+	key    string
+	prefix *net.IPNet
+	ip     net.IP
+	// temporary addresses stop being renewed at created plus the temporary
+	// lifetimes, and were regenerated once a successor was formed
+	temporary   bool
+	created     time.Time
+	regenerated bool
+	// attempt is the number of duplicates found in prefix
+	attempt    int
+	preferred  time.Time
+	valid      time.Time
+	deprecated bool
+	// tentative addresses await duplicate address detection, which sends
+	// left more solicitations, the next one at next, and then succeeds
+	tentative bool
+	left      int
+	next      time.Time
+	nonce     uint64
+	// dadEnd ends the span of duplicate address detection of a tentative
+	// address
+	dadEnd func(error)
+}
+
+// slaacParams are the parameters routers advertise
+type slaacParams struct {
+	managed       bool
+	otherConfig   bool
+	mtu           uint32
+	hopLimit      uint8
+	reachableTime time.Duration
+	retransTimer  time.Duration
+}
+
+// NewSLAACClient returns an SLAACClient that configures the interface of c,
+// which must have been created with RoleHost
+func NewSLAACClient(c *Conn) (*SLAACClient, error) {
+	if c.Role() != RoleHost {
+		return nil, errNotHost
+	}
+
+	return &SLAACClient{
+		DADTransmits: DupAddrDetectTransmits,
+		c:            c,
+		addrs:        make(map[string]*slaacAddr),
+		routers:      newRouterList(),
+		onLink:       newPrefixList(),
+		dns:          newDNSList(),
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+		after:        time.After,
+		rand:         rand.Float64,
+		nonce:        func() uint64 { return uint64(rand.Int63()) & 0xffffffffffff },
+		read: func(b []byte) error {
+			_, err := crand.Read(b)
+			return err
+		},
+	}, nil
+}
+
+// OnLink reports whether ip is on-link according to the advertised prefixes,
+// see PrefixList.OnLink
+func (s *SLAACClient) OnLink(ip net.IP) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.onLink.onLink(ip, s.now())
+}
+
+// Router returns the default router to send packets from src through. Like
+// RouterList.ForSource, it prefers routers that advertised the prefix of src
+func (s *SLAACClient) Router(src net.IP) (DefaultRouter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.routers.forSource(src, s.now())
+}
+
+// State returns the current state of the configuration
+func (s *SLAACClient) State() SLAACState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state()
+}
+
+// Serve solicits router advertisements as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.7 and processes the ones
+// read from the Conn until ctx is done or reading or sending fails
+func (s *SLAACClient) Serve(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mux := NewMux()
+	mux.HandleRouterAdvertisement(s.advertised)
+	mux.HandleNeighborSolicitation(s.solicited)
+	mux.HandleNeighborAdvertisement(s.neighborAdvertised)
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	defer func() {
+		s.endDAD(err)
+	}()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.c.Serve(ctx, mux)
+	}()
+
+	s.mu.Lock()
+	s.solicits = MaxRtrSolicitations
+	s.next = s.now().Add(time.Duration(s.rand() * float64(MaxRtrSolicitationDelay)))
+	_, preferred := s.tempLifetimes()
+	s.desync = time.Duration(s.rand() * MaxDesyncFactor * float64(preferred))
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		var d time.Duration
+		due, ok := s.due()
+		if ok {
+			d = due.Sub(s.now())
+		}
+		s.mu.Unlock()
+
+		var timer <-chan time.Time
+		if ok {
+			timer = s.after(d)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case <-s.wake:
+		case <-timer:
+			if err := s.fire(); err != nil {
+				return err
+			}
+		}
+		s.notify()
+	}
+}
+
+// due returns when Serve needs to act next, if at all. It must be called with
+// mu held
+func (s *SLAACClient) due() (time.Time, bool) {
+	var due time.Time
+	earliest := func(t time.Time) {
+		if !t.IsZero() && (due.IsZero() || t.Before(due)) {
+			due = t
+		}
+	}
+
+	if s.solicits > 0 {
+		earliest(s.next)
+	}
+	for _, a := range s.addrs {
+		if a.tentative {
+			earliest(a.next)
+			continue
+		}
+		earliest(a.valid)
+		if !a.deprecated {
+			earliest(a.preferred)
+		}
+		if a.temporary && !a.regenerated {
+			earliest(a.preferred.Add(-s.regenAdvance()))
+		}
+	}
+	earliest(s.routers.next())
+	earliest(s.onLink.next())
+	earliest(s.dns.next())
+
+	return due, !due.IsZero()
+}
+
+// fire sends what is due and expires what ran out
+func (s *SLAACClient) fire() error {
+	s.mu.Lock()
+	now := s.now()
+
+	solicit := false
+	if s.solicits > 0 && !s.next.After(now) {
+		solicit = true
+		s.solicits--
+		s.next = now.Add(RtrSolicitationInterval)
+	}
+
+	var probes, formed []*slaacAddr
+	for key, a := range s.addrs {
+		if a.temporary && !a.tentative && !a.regenerated && !a.preferred.After(now.Add(s.regenAdvance())) {
+			// a successor takes over before a is deprecated, see
+			// https://tools.ietf.org/html/rfc8981#section-3.5
+			a.regenerated = true
+			if p, ok := s.addrs[a.prefix.String()]; ok {
+				if t := s.temporary(a.prefix, remaining(p.valid, now), remaining(p.preferred, now), now); t != nil {
+					formed = append(formed, t)
+				}
+			}
+		}
+
+		switch {
+		case a.tentative && !a.next.After(now):
+			if a.left == 0 {
+				// nobody objected
+				a.tentative = false
+				a.endDAD(nil)
+				s.changed = true
+				break
+			}
+			a.left--
+			a.next = now.Add(s.retransTimer())
+			probes = append(probes, a)
+		case !a.tentative && !a.valid.IsZero() && !a.valid.After(now):
+			delete(s.addrs, key)
+			s.changed = true
+		case !a.tentative && !a.deprecated && !a.preferred.IsZero() && !a.preferred.After(now):
+			a.deprecated = true
+			s.changed = true
+		}
+	}
+	s.changed = s.routers.expire(now) || s.changed
+	s.changed = s.onLink.expire(now) || s.changed
+	s.changed = s.dns.expire(now) || s.changed
+	s.mu.Unlock()
+
+	for _, a := range formed {
+		if group, _ := SolicitedNodeMulticast(a.ip); group != nil {
+			s.c.JoinGroup(group)
+		}
+	}
+	if solicit {
+		if err := s.c.SendRS(); err != nil && err != ErrRateLimited {
+			return err
+		}
+	}
+	for _, a := range probes {
+		if err := s.probe(a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// probe sends a neighbor solicitation for duplicate address detection of
+// tentative address a, as described at
+// https://tools.ietf.org/html/rfc4862#section-5.4.2
+func (s *SLAACClient) probe(a *slaacAddr) error {
+	group, _ := SolicitedNodeMulticast(a.ip)
+	ns := &ICMPNeighborSolicitation{TargetAddress: a.ip}
+	// tells our own looped back solicitations apart, see
+	// https://tools.ietf.org/html/rfc7527
+	ns.AddOption(&ICMPOptionNonce{Nonce: a.nonce})
+
+	// the solicitations are spaced by the RetransTimer of the link
+	// already, which may be shorter than the one the rate limiter assumes
+	return s.c.writeTo(ns, &Metadata{Source: net.IPv6unspecified}, group, false)
+}
+
+// notify calls Changed if the state changed
+func (s *SLAACClient) notify() {
+	s.mu.Lock()
+	if !s.changed {
+		s.mu.Unlock()
+		return
+	}
+	s.changed = false
+	state := s.state()
+	s.mu.Unlock()
+
+	s.c.logger().Info("slaac state changed", "addresses", len(state.Addresses), "routers", len(state.Routers))
+
+	if s.Changed != nil {
+		s.Changed(state)
+	}
+}
+
+// state returns the current state. It must be called with mu held
+func (s *SLAACClient) state() SLAACState {
+	state := SLAACState{
+		Managed:       s.params.managed,
+		OtherConfig:   s.params.otherConfig,
+		MTU:           s.params.mtu,
+		HopLimit:      s.params.hopLimit,
+		ReachableTime: s.params.reachableTime,
+		RetransTimer:  s.params.retransTimer,
+	}
+
+	for _, a := range s.addrs {
+		if a.tentative {
+			continue
+		}
+		state.Addresses = append(state.Addresses, SLAACAddress{
+			Address:        &net.IPNet{IP: a.ip, Mask: a.prefix.Mask},
+			PreferredUntil: a.preferred,
+			ValidUntil:     a.valid,
+			Temporary:      a.temporary,
+		})
+	}
+	sort.Slice(state.Addresses, func(i, j int) bool {
+		return bytes.Compare(state.Addresses[i].Address.IP, state.Addresses[j].Address.IP) < 0
+	})
+
+	state.Routers = s.routers.sorted()
+	state.Prefixes = s.onLink.sorted()
+	now := s.now()
+	for i, a := range state.Addresses {
+		if r, ok := s.routers.forSource(a.Address.IP, now); ok {
+			state.Addresses[i].Router = r.Address
+		}
+	}
+
+	dns := s.dns.config()
+	state.RDNSS, state.DNSSL = dns.Servers, dns.SearchList
+
+	return state
+}
+
+// retransTimer returns the advertised RetransTimer or its default. It must
+// be called with mu held
+func (s *SLAACClient) retransTimer() time.Duration {
+	if s.params.retransTimer != 0 {
+		return s.params.retransTimer
+	}
+
+	return RetransTimer
+}
+
+// lifetimeEnd returns when lifetime d starting at now ends, or zero if it
+// is infinite
+func lifetimeEnd(now time.Time, d time.Duration) time.Time {
+	if d >= Infinity {
+		return time.Time{}
+	}
+
+	return now.Add(d)
+}
+
+// advertised processes a router advertisement as described at
+// https://tools.ietf.org/html/rfc4861#section-6.3.4 and its prefixes as
+// described at https://tools.ietf.org/html/rfc4862#section-5.5.3
+func (s *SLAACClient) advertised(ra *ICMPRouterAdvertisement, md *Metadata) {
+	s.mu.Lock()
+
+	now := s.now()
+	// one answer is enough
+	s.solicits = 0
+
+	s.changed = s.routers.update(ra, md.Source, now) || s.changed
+	s.changed = s.onLink.update(ra, now) || s.changed
+	s.changed = s.dns.update(ra, md.Source, now, s.DNSPolicy) || s.changed
+
+	params := s.params
+	params.managed, params.otherConfig = ra.ManagedAddress, ra.OtherStateful
+	if ra.HopLimit != 0 {
+		params.hopLimit = ra.HopLimit
+	}
+	if ra.ReachableTime != 0 {
+		params.reachableTime = time.Duration(ra.ReachableTime) * time.Millisecond
+	}
+	if ra.RetransTimer != 0 {
+		params.retransTimer = time.Duration(ra.RetransTimer) * time.Millisecond
+	}
+
+	var tentative []*slaacAddr
+	for _, o := range ra.Options {
+		switch o := o.(type) {
+		case *ICMPOptionMTU:
+			if o.MTU >= 1280 && (s.c.ifi == nil || s.c.ifi.MTU == 0 || int(o.MTU) <= s.c.ifi.MTU) {
+				params.mtu = o.MTU
+			}
+		case *ICMPOptionPrefixInformation:
+			tentative = append(tentative, s.prefix(o, now)...)
+		}
+	}
+	managed := params.managed != s.params.managed
+	otherConfig := params.otherConfig != s.params.otherConfig
+	if params != s.params {
+		s.params = params
+		s.changed = true
+	}
+	s.mu.Unlock()
+
+	if managed && s.Managed != nil {
+		s.Managed(params.managed)
+	}
+	if otherConfig && s.OtherConfig != nil {
+		s.OtherConfig(params.otherConfig)
+	}
+
+	// duplicate address detection listens to the solicited-node group of
+	// tentative addresses
+	for _, a := range tentative {
+		if group, _ := SolicitedNodeMulticast(a.ip); group != nil {
+			s.c.JoinGroup(group)
+		}
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// twoHours is the valid lifetime unauthenticated advertisements can't
+// shorten addresses below, see
+// https://tools.ietf.org/html/rfc4862#section-5.5.3
+const twoHours = 2 * time.Hour
+
+// prefix updates or adds the addresses in the prefix of pi, returning the
+// ones that are new and need duplicate address detection. It must be called
+// with mu held
+func (s *SLAACClient) prefix(pi *ICMPOptionPrefixInformation, now time.Time) []*slaacAddr {
+	prefix := &net.IPNet{
+		IP:   pi.Prefix.Mask(net.CIDRMask(int(pi.PrefixLength), 128)),
+		Mask: net.CIDRMask(int(pi.PrefixLength), 128),
+	}
+	if !pi.Auto || prefix.IP.IsLinkLocalUnicast() || pi.PreferredLifetime > pi.ValidLifetime {
+		return nil
+	}
+
+	valid := pi.ValidLifetimeDuration()
+	preferred := pi.PreferredLifetimeDuration()
+
+	var formed []*slaacAddr
+	key := prefix.String()
+	if a, ok := s.addrs[key]; ok {
+		a.valid = validEnd(a.valid, valid, now)
+		s.renew(a, lifetimeEnd(now, preferred), now)
+	} else if valid != 0 {
+		a := &slaacAddr{
+			key:       key,
+			prefix:    prefix,
+			preferred: lifetimeEnd(now, preferred),
+			valid:     lifetimeEnd(now, valid),
+		}
+		if s.form(a) {
+			s.addrs[key] = a
+			formed = append(formed, a)
+		}
+	}
+
+	if !s.Temporary {
+		return formed
+	}
+	// temporary addresses can't outlive the prefix either, see
+	// https://tools.ietf.org/html/rfc8981#section-3.4
+	current := false
+	tempValid, tempPreferred := s.tempLifetimes()
+	for _, a := range s.addrs {
+		if !a.temporary || a.prefix.String() != key {
+			continue
+		}
+		a.valid = earliestEnd(validEnd(a.valid, valid, now), a.created.Add(tempValid))
+		s.renew(a, earliestEnd(lifetimeEnd(now, preferred), a.created.Add(tempPreferred-s.desync)), now)
+		current = current || !a.regenerated
+	}
+	if !current {
+		if a := s.temporary(prefix, valid, preferred, now); a != nil {
+			formed = append(formed, a)
+		}
+	}
+
+	return formed
+}
+
+// validEnd returns when a valid lifetime ending at end ends after a prefix
+// is advertised with lifetime valid at now, which unauthenticated
+// advertisements can't shorten below two hours. It's zero for infinite
+// lifetimes
+func validEnd(end time.Time, valid time.Duration, now time.Time) time.Time {
+	left := remaining(end, now)
+	switch {
+	case valid > twoHours || valid > left:
+		return lifetimeEnd(now, valid)
+	case left > twoHours:
+		return now.Add(twoHours)
+	}
+
+	return end
+}
+
+// earliestEnd returns the earlier of lifetime ends a and b, where zero is
+// infinite
+func earliestEnd(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
+
+// renew sets the preferred lifetime of a to end at preferred, which undoes
+// its deprecation if that's later than now. It must be called with mu held
+func (s *SLAACClient) renew(a *slaacAddr, preferred, now time.Time) {
+	a.preferred = preferred
+	if a.deprecated && (preferred.IsZero() || preferred.After(now)) {
+		a.deprecated = false
+		s.changed = s.changed || !a.tentative
+	}
+}
+
+// temporary forms a temporary address in prefix, which has lifetimes valid
+// and preferred left at now, as described at
+// https://tools.ietf.org/html/rfc8981#section-3.4. It returns nil when the
+// address wouldn't be preferred long enough to be worth it. It must be
+// called with mu held
+func (s *SLAACClient) temporary(prefix *net.IPNet, valid, preferred time.Duration, now time.Time) *slaacAddr {
+	tempValid, tempPreferred := s.tempLifetimes()
+	if valid < tempValid {
+		tempValid = valid
+	}
+	if preferred < tempPreferred-s.desync {
+		tempPreferred = preferred
+	} else {
+		tempPreferred -= s.desync
+	}
+	if tempPreferred <= s.regenAdvance() {
+		return nil
+	}
+
+	s.seq++
+	a := &slaacAddr{
+		key:       fmt.Sprintf("%s temporary %d", prefix, s.seq),
+		prefix:    prefix,
+		temporary: true,
+		created:   now,
+		preferred: now.Add(tempPreferred),
+		valid:     now.Add(tempValid),
+	}
+	if !s.form(a) {
+		return nil
+	}
+	s.addrs[a.key] = a
+
+	return a
+}
+
+// tempLifetimes returns the lifetimes of temporary addresses. It must be
+// called with mu held
+func (s *SLAACClient) tempLifetimes() (valid, preferred time.Duration) {
+	valid, preferred = s.TempValidLifetime, s.TempPreferredLifetime
+	if valid == 0 {
+		valid = TempValidLifetime
+	}
+	if preferred == 0 {
+		preferred = TempPreferredLifetime
+	}
+
+	return valid, preferred
+}
+
+// regenAdvance returns how long before temporary addresses are deprecated
+// their successors are formed, which leaves time for duplicate address
+// detection. It must be called with mu held
+func (s *SLAACClient) regenAdvance() time.Duration {
+	return 2*time.Second + time.Duration(TempIDGenRetries*s.DADTransmits)*s.retransTimer()
+}
+
+// form picks the address a uses in its prefix and starts duplicate address
+// detection, reporting whether an address could be formed. It must be called
+// with mu held
+func (s *SLAACClient) form(a *slaacAddr) bool {
+	var id []byte
+	var err error
+	if a.temporary {
+		id, err = s.randomID(a.prefix)
+	} else if s.InterfaceID != nil {
+		id, err = s.InterfaceID(a.prefix, a.attempt)
+	} else {
+		id, err = ModifiedEUI64(s.c.linkLayerAddr())
+	}
+	ones, _ := a.prefix.Mask.Size()
+	// the prefix and interface identifier must add up to an address
+	if err != nil || ones+len(id)*8 != 128 {
+		return false
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, a.prefix.IP.To16())
+	copy(ip[ones/8:], id)
+	if a.ip.Equal(ip) {
+		// trying again is pointless
+		return false
+	}
+	a.ip = ip
+
+	a.tentative = s.DADTransmits > 0
+	a.left = s.DADTransmits
+	a.next = s.now()
+	a.nonce = s.nonce()
+	if !a.tentative {
+		s.changed = true
+	} else {
+		_, a.dadEnd = s.c.startSpan(s.ctx, SpanDAD, slog.String("addr", ip.String()), slog.Int("attempt", a.attempt))
+	}
+
+	return true
+}
+
+// randomID returns a random interface identifier for a temporary address in
+// prefix, avoiding the reserved ones listed at
+// https://tools.ietf.org/html/rfc5453#section-3
+func (s *SLAACClient) randomID(prefix *net.IPNet) ([]byte, error) {
+	ones, _ := prefix.Mask.Size()
+	if ones != 64 {
+		return nil, fmt.Errorf("can't form a temporary address in %s", prefix)
+	}
+
+	id := make([]byte, 8)
+	for {
+		if err := s.read(id); err != nil {
+			return nil, err
+		}
+		if !reservedID(id) {
+			return id, nil
+		}
+	}
+}
+
+// reservedID reports whether the 64 bit interface identifier id is reserved,
+// see https://tools.ietf.org/html/rfc5453#section-3
+func reservedID(id []byte) bool {
+	switch {
+	case bytes.Equal(id, make([]byte, 8)):
+		// subnet-router anycast
+		return true
+	case bytes.Equal(id[:7], []byte{0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) && id[7] >= 0x80:
+		// reserved subnet anycast
+		return true
+	case bytes.Equal(id[:5], []byte{0x02, 0x00, 0x5e, 0xff, 0xfe}):
+		// proxy mobile IPv6 and reserved for the IANA
+		return true
+	}
+
+	return false
+}
+
+// solicited checks neighbor solicitations for duplicate address detection of
+// other nodes for one of our tentative addresses
+func (s *SLAACClient) solicited(ns *ICMPNeighborSolicitation, md *Metadata) {
+	if !md.Source.IsUnspecified() {
+		return
+	}
+
+	for _, o := range ns.Options {
+		if nonce, ok := o.(*ICMPOptionNonce); ok {
+			s.mu.Lock()
+			a := s.tentative(ns.TargetAddress)
+			own := a != nil && a.nonce == nonce.Nonce
+			s.mu.Unlock()
+			if own {
+				return
+			}
+		}
+	}
+
+	s.duplicate(ns.TargetAddress)
+}
+
+// neighborAdvertised checks neighbor advertisements for one of our tentative
+// addresses
+func (s *SLAACClient) neighborAdvertised(na *ICMPNeighborAdvertisement, md *Metadata) {
+	s.duplicate(na.TargetAddress)
+}
+
+// tentative returns the tentative address ip, if any. It must be called with
+// mu held
+func (s *SLAACClient) tentative(ip net.IP) *slaacAddr {
+	for _, a := range s.addrs {
+		if a.tentative && a.ip.Equal(ip) {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// endDAD ends the spans of the duplicate address detection still going on
+// when Serve returns err
+func (s *SLAACClient) endDAD(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.addrs {
+		a.endDAD(err)
+	}
+	s.ctx = nil
+}
+
+// endDAD ends the span of duplicate address detection of a, if it has one
+func (a *slaacAddr) endDAD(err error) {
+	if a.dadEnd != nil {
+		a.dadEnd(err)
+		a.dadEnd = nil
+	}
+}
+
+// duplicate gives up tentative address ip, if it is one, and tries another
+// address in its prefix as described at
+// https://tools.ietf.org/html/rfc4862#section-5.4.5
+func (s *SLAACClient) duplicate(ip net.IP) {
+	s.mu.Lock()
+	a := s.tentative(ip)
+	if a == nil {
+		s.mu.Unlock()
+		return
+	}
+
+	retries := IDGenRetries
+	if a.temporary {
+		retries = TempIDGenRetries
+	}
+	a.endDAD(errDuplicateAddress)
+	a.attempt++
+	retry := a.attempt <= retries && s.form(a)
+	if !retry {
+		delete(s.addrs, a.key)
+	}
+	s.mu.Unlock()
+
+	if group, _ := SolicitedNodeMulticast(ip); group != nil {
+		s.c.LeaveGroup(group)
+	}
+	if retry {
+		if group, _ := SolicitedNodeMulticast(a.ip); group != nil {
+			s.c.JoinGroup(group)
+		}
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	s.c.logger().Warn("duplicate address detected", "addr", ip.String())
+	if s.Duplicate != nil {
+		s.Duplicate(ip)
+	}
+}
@@ -0,0 +1,468 @@
+package ndp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestModifiedEUI64(t *testing.T) {
+	id, err := ModifiedEUI64(net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.HardwareAddr(id).String() != "02:11:22:ff:fe:33:44:55" {
+		t.Errorf("unexpected interface identifier %x", id)
+	}
+
+	if _, err := ModifiedEUI64(net.HardwareAddr{1, 2, 3}); err == nil {
+		t.Error("expected error for short link-layer address")
+	}
+}
+
+// serveSLAACClient runs an SLAACClient on one end of a pipe, returning the
+// other end as a router
+func serveSLAACClient(t *testing.T, setup func(*SLAACClient)) (*SLAACClient, *fakeClock, *Conn, <-chan SLAACState, func()) {
+	t.Helper()
+
+	a, b := Pipe()
+	b.role = RoleRouter
+	if _, err := NewSLAACClient(b); err != errNotHost {
+		t.Errorf("expected error for router, not %v", err)
+	}
+
+	s, err := NewSLAACClient(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClockFor(&s.now, &s.after)
+	s.rand = func() float64 { return 0 }
+	s.nonce = func() uint64 { return 42 }
+	states := make(chan SLAACState, 16)
+	s.Changed = func(state SLAACState) { states <- state }
+	if setup != nil {
+		setup(s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(ctx)
+	}()
+
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	return s, clock, b, states, func() {
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("expected cancellation, not %v", err)
+		}
+	}
+}
+
+// readNS reads a neighbor solicitation for duplicate address detection
+func readNS(t *testing.T, c *Conn) *ICMPNeighborSolicitation {
+	t.Helper()
+	m, md, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, ok := m.(*ICMPNeighborSolicitation)
+	if !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+	if !md.Source.IsUnspecified() {
+		t.Errorf("unexpected source %s", md.Source)
+	}
+
+	return ns
+}
+
+func nextState(t *testing.T, states <-chan SLAACState) SLAACState {
+	t.Helper()
+	select {
+	case state := <-states:
+		return state
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for state")
+		return SLAACState{}
+	}
+}
+
+// nextStateOrNone returns the next state if one is reported right away
+func nextStateOrNone(states <-chan SLAACState) *SLAACState {
+	select {
+	case state := <-states:
+		return &state
+	case <-time.After(10 * time.Millisecond):
+		return nil
+	}
+}
+
+func testAdvertisement(prefix string, valid, preferred time.Duration) *ICMPRouterAdvertisement {
+	_, p, _ := net.ParseCIDR(prefix)
+	cfg := DefaultRAConfig()
+	cfg.MTU = 1400
+	cfg.RetransTimer = 2 * time.Second
+	rp := NewRAPrefix(p)
+	rp.ValidLifetime = valid
+	rp.PreferredLifetime = preferred
+	cfg.Prefixes = []RAPrefix{rp}
+	cfg.RDNSS = []net.IP{net.ParseIP("2001:db8::53")}
+	cfg.DNSSL = []string{"example.com"}
+
+	return cfg.Advertisement()
+}
+
+func TestSLAACClient(t *testing.T) {
+	s, clock, b, states, stop := serveSLAACClient(t, nil)
+	defer stop()
+
+	// solicit right away
+	if d := clock.wait(t); d != 0 {
+		t.Errorf("unexpected delay %s", d)
+	}
+	clock.fire <- clock.now()
+	if m, _, err := b.ReadFrom(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := m.(*ICMPRouterSolicitation); !ok {
+		t.Fatalf("unexpected message %s", m)
+	}
+	if d := clock.wait(t); d != RtrSolicitationInterval {
+		t.Errorf("unexpected interval %s", d)
+	}
+
+	// the address is only used after duplicate address detection
+	if err := b.SendRA(testAdvertisement("2001:db8:1::/64", time.Hour, 30*time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+	state := nextState(t, states)
+	if len(state.Addresses) != 0 || len(state.Routers) != 1 || state.MTU != 1400 || state.RetransTimer != 2*time.Second {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if len(state.RDNSS) != 1 || len(state.DNSSL) != 1 || state.DNSSL[0] != "example.com." {
+		t.Errorf("unexpected DNS options %v %v", state.RDNSS, state.DNSSL)
+	}
+	if len(state.Prefixes) != 1 || state.Prefixes[0].Prefix.String() != "2001:db8:1::/64" {
+		t.Errorf("unexpected on-link prefixes %v", state.Prefixes)
+	}
+	if !s.OnLink(net.ParseIP("2001:db8:1::42")) || s.OnLink(net.ParseIP("2001:db8:2::42")) {
+		t.Error("unexpected on-link determination")
+	}
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	addr := net.ParseIP("2001:db8:1::ff:fe00:1")
+	if ns := readNS(t, b); !ns.TargetAddress.Equal(addr) {
+		t.Errorf("unexpected target %s", ns.TargetAddress)
+	}
+	if d := clock.wait(t); d != 2*time.Second {
+		t.Errorf("expected to wait for the advertised retrans timer, not %s", d)
+	}
+	clock.advance(2 * time.Second)
+	clock.fire <- clock.now()
+	state = nextState(t, states)
+	if len(state.Addresses) != 1 || !state.Addresses[0].Address.IP.Equal(addr) || state.Addresses[0].Address.String() != "2001:db8:1::ff:fe00:1/64" {
+		t.Fatalf("unexpected addresses %v", state.Addresses)
+	}
+	if a := state.Addresses[0]; !a.ValidUntil.Equal(clock.now().Add(time.Hour-2*time.Second)) || a.Deprecated(clock.now()) {
+		t.Errorf("unexpected address %+v", a)
+	}
+	if a := state.Addresses[0]; !a.Router.Equal(b.Addr()) {
+		t.Errorf("unexpected first hop %s", a.Router)
+	}
+	if r, ok := s.Router(addr); !ok || !r.Address.Equal(b.Addr()) {
+		t.Errorf("unexpected first hop %+v", r)
+	}
+
+	// the address is deprecated and then removed
+	clock.wait(t)
+	clock.advance(30 * time.Minute)
+	clock.fire <- clock.now()
+	state = nextState(t, states)
+	if len(state.Addresses) != 1 || !state.Addresses[0].Deprecated(clock.now()) {
+		t.Errorf("expected deprecated address, not %v", state.Addresses)
+	}
+	clock.wait(t)
+	clock.advance(time.Hour)
+	clock.fire <- clock.now()
+	state = nextState(t, states)
+	if len(state.Addresses) != 0 || len(state.Routers) != 0 || len(state.RDNSS) != 0 {
+		t.Errorf("expected everything to expire, not %+v", state)
+	}
+}
+
+func TestSLAACClientRetransmissions(t *testing.T) {
+	_, clock, b, states, stop := serveSLAACClient(t, func(s *SLAACClient) {
+		s.DADTransmits = 2
+		s.c.SetRateLimiting(true)
+	})
+	defer stop()
+	b.SetReadDeadline(time.Now().Add(time.Second))
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	b.ReadFrom()
+	clock.wait(t)
+
+	// solicitations more frequent than the rate limiter allows aren't
+	// dropped
+	ra := testAdvertisement("2001:db8:1::/64", time.Hour, time.Hour)
+	ra.RetransTimer = 500
+	if err := b.SendRA(ra, nil); err != nil {
+		t.Fatal(err)
+	}
+	nextState(t, states)
+	for i := 0; i < 2; i++ {
+		clock.wait(t)
+		clock.advance(500 * time.Millisecond)
+		clock.fire <- clock.now()
+		readNS(t, b)
+	}
+	clock.wait(t)
+	clock.advance(500 * time.Millisecond)
+	clock.fire <- clock.now()
+	if state := nextState(t, states); len(state.Addresses) != 1 {
+		t.Errorf("unexpected addresses %v", state.Addresses)
+	}
+}
+
+func TestSLAACClientDuplicate(t *testing.T) {
+	var attempts []int
+	dups := make(chan net.IP, 1)
+	s, clock, b, states, stop := serveSLAACClient(t, func(s *SLAACClient) {
+		s.InterfaceID = func(prefix *net.IPNet, attempt int) ([]byte, error) {
+			attempts = append(attempts, attempt)
+			return []byte{0, 0, 0, 0, 0, 0, 0, byte(attempt + 1)}, nil
+		}
+		s.Duplicate = func(ip net.IP) { dups <- ip }
+	})
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	b.ReadFrom()
+	clock.wait(t)
+
+	if err := b.SendRA(testAdvertisement("2001:db8:1::/64", time.Hour, time.Hour), nil); err != nil {
+		t.Fatal(err)
+	}
+	nextState(t, states)
+	clock.wait(t)
+	clock.fire <- clock.now()
+	first := readNS(t, b).TargetAddress
+	clock.wait(t)
+
+	// our own solicitation looped back isn't a duplicate, a neighbor
+	// advertisement is
+	s.solicited(&ICMPNeighborSolicitation{
+		TargetAddress: first,
+		optionContainer: optionContainer{
+			Options: ICMPOptions{&ICMPOptionNonce{Nonce: 42}},
+		},
+	}, &Metadata{Source: net.IPv6unspecified})
+	if err := b.SendNA(first, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ip := <-dups:
+		if !ip.Equal(first) {
+			t.Errorf("unexpected duplicate %s", ip)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for duplicate")
+	}
+
+	// another address is tried
+	clock.wait(t)
+	clock.fire <- clock.now()
+	if second := readNS(t, b).TargetAddress; second.Equal(first) || !second.Equal(net.ParseIP("2001:db8:1::2")) {
+		t.Errorf("unexpected second address %s", second)
+	}
+	if len(attempts) != 2 || attempts[1] != 1 {
+		t.Errorf("unexpected attempts %v", attempts)
+	}
+}
+
+func TestSLAACClientPrefix(t *testing.T) {
+	a, _ := Pipe()
+	s, err := NewSLAACClient(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DADTransmits = 0
+	now := time.Unix(0, 0)
+
+	pi := func(prefix string, length uint8, valid, preferred time.Duration) *ICMPOptionPrefixInformation {
+		o := &ICMPOptionPrefixInformation{Prefix: net.ParseIP(prefix), PrefixLength: length, Auto: true}
+		o.SetValidLifetime(valid)
+		o.SetPreferredLifetime(preferred)
+		return o
+	}
+
+	// prefixes that can't be used
+	for _, o := range []*ICMPOptionPrefixInformation{
+		pi("fe80::", 64, time.Hour, time.Hour),
+		pi("2001:db8::", 48, time.Hour, time.Hour),
+		pi("2001:db8::", 64, time.Hour, 2*time.Hour),
+		pi("2001:db8::", 64, 0, 0),
+	} {
+		s.prefix(o, now)
+		if len(s.addrs) != 0 {
+			t.Errorf("unexpected address for %s", o)
+		}
+		s.addrs = make(map[string]*slaacAddr)
+	}
+
+	// valid lifetimes can't be cut below two hours
+	s.prefix(pi("2001:db8::", 64, 10*time.Hour, time.Hour), now)
+	tests := []struct {
+		valid    time.Duration
+		expected time.Duration
+	}{
+		{20 * time.Hour, 20 * time.Hour},
+		{time.Minute, 2 * time.Hour},
+		{time.Minute, 2 * time.Hour},
+		{3 * time.Hour, 3 * time.Hour},
+		{Infinity, Infinity},
+	}
+	for _, test := range tests {
+		s.prefix(pi("2001:db8::", 64, test.valid, 0), now)
+		a := s.addrs["2001:db8::/64"]
+		if left := remaining(a.valid, now); left != test.expected {
+			t.Errorf("expected %s left after advertising %s, not %s", test.expected, test.valid, left)
+		}
+	}
+}
+
+func TestSLAACClientTemporary(t *testing.T) {
+	var n byte
+	_, clock, b, states, stop := serveSLAACClient(t, func(s *SLAACClient) {
+		s.DADTransmits = 0
+		s.Temporary = true
+		s.TempValidLifetime = 3 * time.Hour
+		s.TempPreferredLifetime = time.Hour
+		s.read = func(b []byte) error {
+			// the first one is reserved
+			for i := range b {
+				b[i] = 0
+			}
+			b[7] = n
+			n++
+			return nil
+		}
+	})
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	b.ReadFrom()
+	clock.wait(t)
+
+	ra := testAdvertisement("2001:db8:1::/64", 24*time.Hour, 24*time.Hour)
+	// nothing else expires in the meantime
+	ra.RouterLifeTime = 9000
+	var options ICMPOptions
+	for _, o := range ra.Options {
+		if _, ok := o.(*ICMPOptionPrefixInformation); ok {
+			options = append(options, o)
+		}
+	}
+	ra.Options = options
+	if err := b.SendRA(ra, nil); err != nil {
+		t.Fatal(err)
+	}
+	state := nextState(t, states)
+	if len(state.Addresses) != 2 {
+		t.Fatalf("expected a stable and temporary address, not %v", state.Addresses)
+	}
+	temp := state.Addresses[0]
+	if !temp.Temporary || !temp.Address.IP.Equal(net.ParseIP("2001:db8:1::1")) || state.Addresses[1].Temporary {
+		t.Errorf("unexpected addresses %v", state.Addresses)
+	}
+	if !temp.PreferredUntil.Equal(clock.now().Add(time.Hour)) || !temp.ValidUntil.Equal(clock.now().Add(3*time.Hour)) {
+		t.Errorf("unexpected lifetimes %+v", temp)
+	}
+
+	// a successor is formed ahead of deprecation
+	if d := clock.wait(t); d != time.Hour-2*time.Second {
+		t.Errorf("unexpected regeneration after %s", d)
+	}
+	clock.advance(time.Hour - 2*time.Second)
+	clock.fire <- clock.now()
+	state = nextState(t, states)
+	if len(state.Addresses) != 3 || !state.Addresses[1].Address.IP.Equal(net.ParseIP("2001:db8:1::2")) {
+		t.Fatalf("unexpected addresses %v", state.Addresses)
+	}
+
+	clock.wait(t)
+	clock.advance(2 * time.Second)
+	clock.fire <- clock.now()
+	state = nextState(t, states)
+	if !state.Addresses[0].Deprecated(clock.now()) || state.Addresses[1].Deprecated(clock.now()) {
+		t.Errorf("expected the first temporary address to be deprecated, not %v", state.Addresses)
+	}
+
+	// advertisements don't extend temporary addresses beyond their lifetimes
+	if err := b.SendRA(ra, nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.wait(t)
+	if state := nextStateOrNone(states); state != nil {
+		t.Errorf("unexpected change %v", state.Addresses)
+	}
+}
+
+func TestReservedID(t *testing.T) {
+	tests := []struct {
+		id       []byte
+		reserved bool
+	}{
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0}, true},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 1}, false},
+		{[]byte{0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x80}, true},
+		{[]byte{0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, false},
+		{[]byte{0x02, 0x00, 0x5e, 0xff, 0xfe, 0x00, 0x52, 0x13}, true},
+	}
+	for _, test := range tests {
+		if reservedID(test.id) != test.reserved {
+			t.Errorf("expected %x reserved to be %t", test.id, test.reserved)
+		}
+	}
+}
+
+func TestSLAACClientFlags(t *testing.T) {
+	a, _ := Pipe()
+	s, err := NewSLAACClient(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var managed, other []bool
+	s.Managed = func(on bool) { managed = append(managed, on) }
+	s.OtherConfig = func(on bool) { other = append(other, on) }
+
+	md := &Metadata{Source: net.ParseIP("fe80::2")}
+	for _, ra := range []*ICMPRouterAdvertisement{
+		{},
+		{OtherStateful: true},
+		{OtherStateful: true},
+		{ManagedAddress: true, OtherStateful: true},
+		{},
+	} {
+		s.advertised(ra, md)
+	}
+
+	if len(managed) != 2 || !managed[0] || managed[1] {
+		t.Errorf("unexpected M flag changes %v", managed)
+	}
+	if len(other) != 2 || !other[0] || other[1] {
+		t.Errorf("unexpected O flag changes %v", other)
+	}
+	if state := s.State(); state.Managed || state.OtherConfig {
+		t.Errorf("unexpected flags in %+v", state)
+	}
+}
@@ -0,0 +1,105 @@
+package ndp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errSecretTooShort = errors.New("secret key too short")
+)
+
+// minSecretLen is the minimum length of secret keys, which must be at least
+// 128 bits as described at https://tools.ietf.org/html/rfc7217#section-5
+const minSecretLen = 16
+
+// StableID generates semantically opaque interface identifiers as described
+// at https://tools.ietf.org/html/rfc7217. These are stable within a prefix,
+// but differ between prefixes, so hosts can't be tracked across networks.
+// Its InterfaceID can be used as the one of SLAACClient
+type StableID struct {
+	// Secret is the secret key, of at least 16 bytes, that has to stay the
+	// same for addresses to be stable, see LoadStableSecret
+	Secret []byte
+	// Interface identifies the interface, like its name
+	Interface string
+	// NetworkID optionally identifies the network, like its SSID
+	NetworkID []byte
+}
+
+// InterfaceID returns the interface identifier for prefix, using attempt as
+// the DAD_Counter that changes it after duplicate address detection failed
+func (s StableID) InterfaceID(prefix *net.IPNet, attempt int) ([]byte, error) {
+	if len(s.Secret) < minSecretLen {
+		return nil, errSecretTooShort
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 || ones%8 != 0 || ones == 128 {
+		return nil, fmt.Errorf("can't form an interface identifier in %s", prefix)
+	}
+
+	counter := uint32(attempt)
+	for {
+		// F(Prefix, Net_Iface, Network_ID, DAD_Counter, secret_key)
+		mac := hmac.New(sha256.New, s.Secret)
+		mac.Write(prefix.IP.To16()[:ones/8])
+		mac.Write([]byte(s.Interface))
+		mac.Write(s.NetworkID)
+		binary.Write(mac, binary.BigEndian, counter)
+		// the leftmost bits make up the identifier
+		id := mac.Sum(nil)[:(128-ones)/8]
+
+		// reserved identifiers count as collisions
+		if len(id) != 8 || !reservedID(id) {
+			return id, nil
+		}
+		counter++
+	}
+}
+
+// LoadStableSecret returns the secret key stored at path for use by
+// StableID, generating a random one and storing it first if there is none,
+// so addresses stay the same across restarts
+func LoadStableSecret(path string) ([]byte, error) {
+	secret, err := os.ReadFile(path)
+	if err == nil {
+		if len(secret) < minSecretLen {
+			return nil, fmt.Errorf("%s: %s", path, errSecretTooShort)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	secret = make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	// write it in full or not at all, so it never changes once read
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(secret); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
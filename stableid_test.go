@@ -0,0 +1,107 @@
+package ndp
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStableID(t *testing.T) {
+	s := StableID{Secret: bytes.Repeat([]byte{1}, 16), Interface: "eth0"}
+	_, p1, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, p2, _ := net.ParseCIDR("2001:db8:2::/64")
+
+	a, err := s.InterfaceID(p1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 8 {
+		t.Fatalf("unexpected length %d", len(a))
+	}
+	if again, _ := s.InterfaceID(p1, 0); !bytes.Equal(a, again) {
+		t.Error("expected the same identifier for the same prefix")
+	}
+
+	// anything else changes the identifier
+	other := []func() ([]byte, error){
+		func() ([]byte, error) { return s.InterfaceID(p2, 0) },
+		func() ([]byte, error) { return s.InterfaceID(p1, 1) },
+		func() ([]byte, error) {
+			return StableID{Secret: s.Secret, Interface: "eth1"}.InterfaceID(p1, 0)
+		},
+		func() ([]byte, error) {
+			return StableID{Secret: s.Secret, Interface: "eth0", NetworkID: []byte("ssid")}.InterfaceID(p1, 0)
+		},
+		func() ([]byte, error) {
+			return StableID{Secret: bytes.Repeat([]byte{2}, 16), Interface: "eth0"}.InterfaceID(p1, 0)
+		},
+	}
+	for i, f := range other {
+		if b, err := f(); err != nil || bytes.Equal(a, b) {
+			t.Errorf("%d: expected another identifier, not %x (%v)", i, b, err)
+		}
+	}
+
+	_, p56, _ := net.ParseCIDR("2001:db8::/56")
+	if id, err := s.InterfaceID(p56, 0); err != nil || len(id) != 9 {
+		t.Errorf("unexpected identifier %x for /56 (%v)", id, err)
+	}
+	_, p60, _ := net.ParseCIDR("2001:db8::/60")
+	if _, err := s.InterfaceID(p60, 0); err == nil {
+		t.Error("expected error for /60")
+	}
+	if _, err := (StableID{Secret: []byte("short")}).InterfaceID(p1, 0); err != errSecretTooShort {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadStableSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+
+	secret, err := LoadStableSecret(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) < minSecretLen {
+		t.Errorf("secret of %d bytes too short", len(secret))
+	}
+	again, err := LoadStableSecret(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, again) {
+		t.Error("expected the stored secret")
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm()&0o077 != 0 {
+		t.Errorf("expected secret only readable by us, not %v (%v)", fi.Mode(), err)
+	}
+
+	if err := os.WriteFile(path, []byte("short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStableSecret(path); err == nil {
+		t.Error("expected error for short secret")
+	}
+}
+
+func TestSLAACClientStableID(t *testing.T) {
+	a, _ := Pipe()
+	s, err := NewSLAACClient(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DADTransmits = 0
+	s.InterfaceID = StableID{Secret: bytes.Repeat([]byte{1}, 16), Interface: "eth0"}.InterfaceID
+
+	o := &ICMPOptionPrefixInformation{Prefix: net.ParseIP("2001:db8::"), PrefixLength: 64, Auto: true}
+	o.SetValidLifetime(Infinity)
+	o.SetPreferredLifetime(Infinity)
+	s.prefix(o, s.now())
+
+	addrs := s.State().Addresses
+	if len(addrs) != 1 || bytes.Equal(addrs[0].Address.IP[8:], []byte{0, 0, 0, 0xff, 0xfe, 0, 0, 1}) {
+		t.Errorf("unexpected addresses %v", addrs)
+	}
+}
@@ -0,0 +1,54 @@
+package ndp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Span names of the exchanges a Tracer traces
+const (
+	// SpanResolve covers NeighborCache.Resolve, from looking up the cache
+	// to the neighbor answering its solicitations or giving up
+	SpanResolve = "ndp.resolve"
+	// SpanSolicitRouter covers RSClient.Solicit, from the first router
+	// solicitation to the router advertisement answering it
+	SpanSolicitRouter = "ndp.solicit_router"
+	// SpanDAD covers a round of duplicate address detection of
+	// SLAACClient, from forming a tentative address to using it or finding
+	// it is a duplicate
+	SpanDAD = "ndp.dad"
+)
+
+// Tracer starts spans around the request and response exchanges of neighbor
+// discovery, so their latency can be observed in distributed tracing
+// systems. See Conn.SetTracer and package ndpotel, which implements it with
+// OpenTelemetry
+type Tracer interface {
+	// Start starts a span called name as a child of the span of ctx, if
+	// any, and returns ctx holding the new span along with end, which ends
+	// it with the error the exchange failed with or nil
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (_ context.Context, end func(error))
+}
+
+// SetTracer sets t as the Tracer of the exchanges of this Conn and the
+// subsystems serving on it, which aren't traced without one. The attributes
+// of spans include the ifname of the interface of the Conn. It must not be
+// called while other goroutines use this Conn
+func (c *Conn) SetTracer(t Tracer) {
+	c.tracer = t
+}
+
+// startSpan starts span name of this Conn with attrs if it has a Tracer
+func (c *Conn) startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(error)) {
+	if c == nil || c.tracer == nil {
+		return ctx, func(error) {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.ifi != nil {
+		attrs = append([]slog.Attr{slog.String("ifname", c.ifi.Name)}, attrs...)
+	}
+
+	return c.tracer.Start(ctx, name, attrs...)
+}
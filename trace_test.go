@@ -0,0 +1,148 @@
+package ndp
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// testSpan is a span ended by testTracer
+type testSpan struct {
+	name  string
+	attrs map[string]string
+	ctx   context.Context
+	err   error
+}
+
+// testTracer sends the spans it started to spans once they end
+type testTracer struct {
+	spans chan testSpan
+}
+
+func newTestTracer() *testTracer {
+	return &testTracer{spans: make(chan testSpan, 16)}
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(error)) {
+	span := testSpan{name: name, attrs: make(map[string]string), ctx: ctx}
+	for _, a := range attrs {
+		span.attrs[a.Key] = a.Value.String()
+	}
+
+	return ctx, func(err error) {
+		span.err = err
+		t.spans <- span
+	}
+}
+
+// next returns the next span that ended
+func (t *testTracer) next(tb testing.TB) testSpan {
+	tb.Helper()
+	select {
+	case span := <-t.spans:
+		return span
+	case <-time.After(time.Second):
+		tb.Fatal("timeout waiting for span")
+		return testSpan{}
+	}
+}
+
+type testContextKey struct{}
+
+func TestTraceResolve(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+	tracer := newTestTracer()
+	a.SetTracer(tracer)
+
+	nc := NewNeighborCache(a)
+	nc.Seed(Neighbor{Address: net.ParseIP("fe80::2"), LinkLayerAddress: b.Interface().HardwareAddr})
+
+	// spans are children of the span of the context passed in
+	ctx := context.WithValue(context.Background(), testContextKey{}, "parent")
+	if _, err := nc.Resolve(ctx, net.ParseIP("fe80::2")); err != nil {
+		t.Fatal(err)
+	}
+	span := tracer.next(t)
+	if span.name != SpanResolve || span.err != nil || span.ctx.Value(testContextKey{}) != "parent" {
+		t.Errorf("unexpected span %+v", span)
+	}
+	if span.attrs["ifname"] != "pipe0" || span.attrs["addr"] != "fe80::2" {
+		t.Errorf("unexpected attributes %v", span.attrs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := nc.Resolve(ctx, net.ParseIP("fe80::3")); err != context.Canceled {
+		t.Fatalf("expected cancellation, not %v", err)
+	}
+	if span := tracer.next(t); span.err != context.Canceled {
+		t.Errorf("expected span to end cancelled, not %v", span.err)
+	}
+}
+
+func TestTraceSolicitRouter(t *testing.T) {
+	r, b := testRSClient(10 * time.Millisecond)
+	defer r.c.Close()
+	countRS(b)
+	tracer := newTestTracer()
+	r.c.SetTracer(tracer)
+
+	if _, _, err := r.Solicit(context.Background()); err != ErrNoAdvertisement {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b.Close()
+	if span := tracer.next(t); span.name != SpanSolicitRouter || span.err != ErrNoAdvertisement {
+		t.Errorf("unexpected span %+v", span)
+	}
+}
+
+func TestTraceDAD(t *testing.T) {
+	tracer := newTestTracer()
+	s, clock, b, states, stop := serveSLAACClient(t, func(s *SLAACClient) {
+		s.InterfaceID = func(prefix *net.IPNet, attempt int) ([]byte, error) {
+			return []byte{0, 0, 0, 0, 0, 0, 0, byte(attempt + 1)}, nil
+		}
+		s.c.SetTracer(tracer)
+	})
+	defer stop()
+
+	clock.wait(t)
+	clock.fire <- clock.now()
+	b.ReadFrom()
+	clock.wait(t)
+
+	if err := b.SendRA(testAdvertisement("2001:db8:1::/64", time.Hour, time.Hour), nil); err != nil {
+		t.Fatal(err)
+	}
+	nextState(t, states)
+	clock.wait(t)
+	clock.fire <- clock.now()
+	first := readNS(t, b).TargetAddress
+	clock.wait(t)
+
+	// a round of duplicate address detection ends with the address found
+	// in use
+	if err := b.SendNA(first, nil); err != nil {
+		t.Fatal(err)
+	}
+	span := tracer.next(t)
+	if span.name != SpanDAD || span.err != errDuplicateAddress || span.attrs["addr"] != first.String() || span.attrs["attempt"] != "0" {
+		t.Errorf("unexpected span %+v", span)
+	}
+
+	// and another with the address used
+	clock.wait(t)
+	clock.fire <- clock.now()
+	second := readNS(t, b).TargetAddress
+	clock.wait(t)
+	clock.advance(s.retransTimer())
+	clock.fire <- clock.now()
+	span = tracer.next(t)
+	if span.err != nil || span.attrs["addr"] != second.String() || span.attrs["attempt"] != "1" {
+		t.Errorf("unexpected span %+v", span)
+	}
+}
@@ -0,0 +1,374 @@
+package ndp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	errNoCGAOption         = errors.New("signed message without cga option")
+	errNoTimestamp         = errors.New("signed message without timestamp")
+	errKeyHash             = errors.New("key hash doesn't match a known key")
+	errKeyTooSmall         = errors.New("rsa key too small")
+	errSecTooLow           = errors.New("cga sec too low")
+	errBadSignature        = errors.New("rsa signature doesn't verify")
+	errRouterNotAuthorized = errors.New("router key not certified by a trust anchor")
+)
+
+// defaultMinKeyBits is the default MinKeyBits of Verifier
+const defaultMinKeyBits = 1024
+
+// minECDSABits rejects ECDSA keys on curves smaller than P-256
+const minECDSABits = 256
+
+// Extended key usages of SEND certificates as described at
+// https://tools.ietf.org/html/rfc6494#section-7
+var (
+	ExtKeyUsageSENDRouter        = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 23}
+	ExtKeyUsageSENDProxiedRouter = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 24}
+	ExtKeyUsageSENDOwner         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 25}
+	ExtKeyUsageSENDProxiedOwner  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 26}
+)
+
+// SENDStatus is what verifying the SEND options of a message found
+type SENDStatus int
+
+// SEND statuses
+const (
+	// SENDUnsecured is a message without RSA signature
+	SENDUnsecured SENDStatus = iota
+	// SENDSecured is a message that passed every check
+	SENDSecured
+	// SENDInvalid is a signed message that failed a check
+	SENDInvalid
+)
+
+func (s SENDStatus) String() string {
+	switch s {
+	case SENDUnsecured:
+		return "unsecured"
+	case SENDSecured:
+		return "secured"
+	case SENDInvalid:
+		return "invalid"
+	}
+
+	return "unknown"
+}
+
+// SENDVerdict is the outcome of Verifier.Verify
+type SENDVerdict struct {
+	Status SENDStatus
+	// Reason tells why an invalid message failed
+	Reason error
+	// Authorized is set when the key is certified by a trust anchor, which
+	// router advertisements and redirects take to be secured
+	Authorized bool
+}
+
+// Verifier checks the SEND options of received messages as described at
+// https://tools.ietf.org/html/rfc3971#section-5: the source has to be the
+// CGA of the key that signed the message, and the message has to be fresh
+// by its timestamp and, for answers to solicitations, its nonce
+type Verifier struct {
+	// TrustAnchors, when set, certify router keys through Certificates
+	TrustAnchors *x509.CertPool
+	// Certificates of routers and intermediate authorities, to which Learn
+	// adds those of certification path advertisements
+	Certificates []*x509.Certificate
+	// MinKeyBits rejects smaller RSA keys. It defaults to 1024, while
+	// ECDSA keys need a curve of at least P-256
+	MinKeyBits int
+	// MinSec rejects CGAs of a lower sec
+	MinSec uint8
+	// RequireRouterEKU has only certificates with the router extended key
+	// usages of RFC 6494 authorize routers
+	RequireRouterEKU bool
+	// Replay tells fresh messages from replayed ones
+	Replay *ReplayCache
+
+	// mu guards Certificates once the Verifier is in use
+	mu sync.Mutex
+
+	// overridden by tests
+	now func() time.Time
+}
+
+// NewVerifier returns a Verifier of which trustAnchors certify router keys
+func NewVerifier(trustAnchors *x509.CertPool) *Verifier {
+	return &Verifier{
+		TrustAnchors: trustAnchors,
+		MinKeyBits:   defaultMinKeyBits,
+		Replay:       NewReplayCache(),
+		now:          time.Now,
+	}
+}
+
+// Expect remembers the nonce of solicitation m that is sent, so answers echoing
+// it are known to be fresh
+func (v *Verifier) Expect(m ICMP) {
+	oc, ok := m.(optionCarrier)
+	if !ok {
+		return
+	}
+
+	for _, o := range oc.options() {
+		if o, ok := o.(*ICMPOptionNonce); ok {
+			v.Replay.Expect(o.Nonce)
+		}
+	}
+}
+
+// Verify checks the SEND options of m, read with md
+func (v *Verifier) Verify(m ICMP, md *Metadata) SENDVerdict {
+	oc, ok := m.(optionCarrier)
+	if !ok {
+		return SENDVerdict{Status: SENDUnsecured}
+	}
+
+	var (
+		cga   *ICMPOptionCGA
+		ts    *ICMPOptionTimestamp
+		nonce *ICMPOptionNonce
+		sig   *ICMPOptionRSASignature
+		// signed counts the options covered by the signature, which are
+		// those before it
+		signed int
+	)
+	for i, o := range oc.options() {
+		if sig != nil {
+			break
+		}
+		switch o := o.(type) {
+		case *ICMPOptionCGA:
+			cga = o
+		case *ICMPOptionTimestamp:
+			ts = o
+		case *ICMPOptionNonce:
+			nonce = o
+		case *ICMPOptionRSASignature:
+			sig = o
+			signed = i
+		}
+	}
+	if sig == nil {
+		return SENDVerdict{Status: SENDUnsecured}
+	}
+
+	key, authorized, err := v.key(cga, sig, md)
+	if err == nil {
+		err = v.verifySignature(m, md, key, sig, signed)
+	}
+	if err == nil && ts == nil {
+		err = errNoTimestamp
+	}
+	if err == nil {
+		err = v.fresh(md, ts.Timestamp, nonce, m.Type())
+	}
+	if err == nil && !authorized && (m.Type() == ipv6.ICMPTypeRouterAdvertisement || m.Type() == ipv6.ICMPTypeRedirect) {
+		err = errRouterNotAuthorized
+	}
+	if err != nil {
+		return SENDVerdict{Status: SENDInvalid, Reason: err, Authorized: authorized}
+	}
+
+	return SENDVerdict{Status: SENDSecured, Authorized: authorized}
+}
+
+// SecuredOnly returns a Handler passing on the messages that verify as
+// secured to h, dropping the others
+func (v *Verifier) SecuredOnly(h Handler) Handler {
+	return HandlerFunc(func(m ICMP, md *Metadata) {
+		if v.Verify(m, md).Status == SENDSecured {
+			h.ServeNDP(m, md)
+		}
+	})
+}
+
+// key returns the key sig is made with, which is the key of the CGA options
+// or of a certificate, and whether trust anchors certify it
+func (v *Verifier) key(cga *ICMPOptionCGA, sig *ICMPOptionRSASignature, md *Metadata) (crypto.PublicKey, bool, error) {
+	if cga == nil {
+		return nil, false, errNoCGAOption
+	}
+
+	// duplicate address detection has no address to prove yet
+	if !md.Source.IsUnspecified() {
+		if err := VerifyCGA(md.Source, &cga.Parameters); err != nil {
+			return nil, false, err
+		}
+		if md.Source.To16()[8]>>5 < v.MinSec {
+			return nil, false, errSecTooLow
+		}
+	}
+
+	var pub crypto.PublicKey
+	if keyHash(cga.Parameters.PublicKey) == sig.KeyHash {
+		var err error
+		if pub, err = cga.Parameters.Key(); err != nil {
+			return nil, false, err
+		}
+	} else {
+		for _, c := range v.certificates() {
+			if keyHash(c.RawSubjectPublicKeyInfo) == sig.KeyHash {
+				pub = c.PublicKey
+				break
+			}
+		}
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		minBits := v.MinKeyBits
+		if minBits == 0 {
+			minBits = defaultMinKeyBits
+		}
+		if key.N.BitLen() < minBits {
+			return nil, false, errKeyTooSmall
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize < minECDSABits {
+			return nil, false, errKeyTooSmall
+		}
+	default:
+		return nil, false, errKeyHash
+	}
+
+	return pub, v.authorized(pub), nil
+}
+
+// authorized reports whether one of Certificates certifies key and chains up
+// to TrustAnchors
+func (v *Verifier) authorized(key crypto.PublicKey) bool {
+	if v.TrustAnchors == nil {
+		return false
+	}
+
+	certs := v.certificates()
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+	for _, c := range certs {
+		if ck, ok := c.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !ck.Equal(key) {
+			continue
+		}
+		if v.RequireRouterEKU && !routerCertificate(c) {
+			continue
+		}
+		_, err := c.Verify(x509.VerifyOptions{
+			Roots:         v.TrustAnchors,
+			Intermediates: intermediates,
+			CurrentTime:   v.now(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routerCertificate reports whether c certifies a router as for
+// https://tools.ietf.org/html/rfc6494#section-7
+func routerCertificate(c *x509.Certificate) bool {
+	for _, eku := range c.UnknownExtKeyUsage {
+		if eku.Equal(ExtKeyUsageSENDRouter) || eku.Equal(ExtKeyUsageSENDProxiedRouter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// certificates returns a copy of Certificates
+func (v *Verifier) certificates() []*x509.Certificate {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return append([]*x509.Certificate(nil), v.Certificates...)
+}
+
+// verifySignature checks sig over m as received with md, of which the first
+// signed options precede the signature
+func (v *Verifier) verifySignature(m ICMP, md *Metadata, key crypto.PublicKey, sig *ICMPOptionRSASignature, signed int) error {
+	// options may marshal to other bytes than they were parsed from, so
+	// only messages that weren't received are marshaled
+	b := md.Raw
+	if b == nil {
+		var err error
+		if b, err = m.Marshal(); err != nil {
+			return err
+		}
+	}
+	end, ok := optionEnd(b, optionsOffset(m.Type()), signed)
+	if !ok || end+2 > len(b) || ICMPOptionType(b[end]) != ICMPOptionTypeRSASignature {
+		return errBadSignature
+	}
+	b = b[:end]
+
+	// the signature is followed by padding to a multiple of 8 bytes
+	s := sig.Signature
+	digest := signedDigest(b, md.Source, md.Destination, signatureHash(key))
+	var err error
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if len(s) > key.Size() {
+			s = s[:key.Size()]
+		}
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA1, digest, s)
+	case *ecdsa.PublicKey:
+		// which the ASN.1 encoding tells apart
+		if rest, aerr := asn1.Unmarshal(s, &asn1.RawValue{}); aerr == nil {
+			s = s[:len(s)-len(rest)]
+		}
+		if !ecdsa.VerifyASN1(key, digest, s) {
+			err = errBadSignature
+		}
+	}
+	if err != nil {
+		return errBadSignature
+	}
+
+	return nil
+}
+
+// optionEnd returns where the first n options of message b end, of which the
+// options start at off, walking the options as they are on the wire
+func optionEnd(b []byte, off, n int) (int, bool) {
+	if off < 4 || off > len(b) {
+		return 0, false
+	}
+
+	for ; n > 0; n-- {
+		if off+2 > len(b) || b[off+1] == 0 {
+			return 0, false
+		}
+		off += int(b[off+1]) * 8
+		if off > len(b) {
+			return 0, false
+		}
+	}
+
+	return off, true
+}
+
+// fresh checks the timestamp of a message of type typ with Replay, where
+// only advertisements answer solicitations by their nonce
+func (v *Verifier) fresh(md *Metadata, ts time.Time, nonce *ICMPOptionNonce, typ ipv6.ICMPType) error {
+	var n uint64
+	if nonce != nil && (typ == ipv6.ICMPTypeRouterAdvertisement || typ == ipv6.ICMPTypeNeighborAdvertisement) {
+		n = nonce.Nonce
+	}
+
+	return v.Replay.Check(md.Source, ts, n)
+}
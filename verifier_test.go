@@ -0,0 +1,330 @@
+package ndp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testVerified signs m from src to dst with s and returns it as parsed from
+// the wire
+func testVerified(t *testing.T, s *Signer, m ICMP, src, dst net.IP, nonce uint64) (ICMP, *Metadata) {
+	t.Helper()
+	if err := s.Sign(m, src, dst, nonce); err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, &Metadata{Source: src, Destination: dst, HopLimit: 255}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	s, addr := testSigner(t)
+	v := NewVerifier(nil)
+	now := time.Unix(1000, 0)
+	v.now = func() time.Time { return now }
+	v.Replay.now = v.now
+	target := net.ParseIP("fe80::2")
+	group, _ := SolicitedNodeMulticast(target)
+
+	// unsigned messages are merely unsecured
+	md := &Metadata{Source: addr, Destination: group, HopLimit: 255}
+	if vd := v.Verify(&ICMPNeighborSolicitation{TargetAddress: target}, md); vd.Status != SENDUnsecured {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	m, md := testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, group, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDSecured || vd.Authorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// the signature covers the message and its addresses
+	m.(*ICMPNeighborSolicitation).TargetAddress = net.ParseIP("fe80::3")
+	if vd := v.Verify(m, md); vd.Status != SENDInvalid || vd.Reason != errBadSignature {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	m.(*ICMPNeighborSolicitation).TargetAddress = target
+	if vd := v.Verify(m, &Metadata{Source: addr, Destination: target}); vd.Reason != errBadSignature {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	// and the source is the CGA of the key
+	if vd := v.Verify(m, &Metadata{Source: net.ParseIP("fe80::1"), Destination: group}); vd.Status != SENDInvalid {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// a later message of the source must not be older than the last one
+	now = now.Add(10 * time.Second)
+	if vd := v.Verify(m, md); vd.Status != SENDInvalid || vd.Reason != errTimestamp {
+		t.Errorf("expected replay to fail, not %+v", vd)
+	}
+	s.now = func() time.Time { return now }
+	m, md = testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, group, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDSecured {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// sources that are new are checked against the clock
+	other, oaddr := testSigner(t)
+	other.now = func() time.Time { return now.Add(-2 * TimestampDelta) }
+	m, md = testVerified(t, other, &ICMPNeighborSolicitation{TargetAddress: target}, oaddr, group, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDInvalid || vd.Reason != errTimestamp {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	v.MinSec = 1
+	m, md = testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, group, 0)
+	if vd := v.Verify(m, md); vd.Reason != errSecTooLow {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	v.MinSec = 0
+	v.MinKeyBits = 2048
+	if vd := v.Verify(m, md); vd.Reason != errKeyTooSmall {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+}
+
+func TestVerifierExpect(t *testing.T) {
+	s, addr := testSigner(t)
+	v := NewVerifier(nil)
+	now := time.Unix(1000, 0)
+	v.now = func() time.Time { return now }
+	v.Replay.now = v.now
+	target := net.ParseIP("fe80::2")
+
+	ns := &ICMPNeighborSolicitation{TargetAddress: addr}
+	ns.AddOption(&ICMPOptionNonce{Nonce: 42})
+	v.Expect(ns)
+
+	// answers to our solicitations are fresh by their nonce, even though
+	// their source has sent a later message before
+	na := func(ts time.Time) (ICMP, *Metadata) {
+		s.now = func() time.Time { return ts }
+		return testVerified(t, s, &ICMPNeighborAdvertisement{Solicited: true, TargetAddress: addr}, addr, target, 42)
+	}
+	m, md := testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, target, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDSecured {
+		t.Fatalf("unexpected verdict %+v", vd)
+	}
+	m, md = na(time.Unix(900, 0))
+	if vd := v.Verify(m, md); vd.Status != SENDSecured {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	// as long as their timestamp is within delta
+	m, md = na(now.Add(-TimestampDelta))
+	if vd := v.Verify(m, md); vd.Reason != errTimestamp {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// nonces are forgotten after a while
+	now = now.Add(TimestampDelta + time.Second)
+	if v.Replay.Expected(42) {
+		t.Error("unexpected nonce")
+	}
+}
+
+// testCertificate returns a trust anchor and the certificate it issues for pub,
+// valid around now and with extended key usages ekus
+func testCertificate(t *testing.T, pub crypto.PublicKey, now time.Time, ekus ...asn1.ObjectIdentifier) (*x509.CertPool, *x509.Certificate) {
+	t.Helper()
+	caKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "anchor"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		Subject:            pkix.Name{CommonName: "router"},
+		NotBefore:          now.Add(-time.Hour),
+		NotAfter:           now.Add(time.Hour),
+		UnknownExtKeyUsage: ekus,
+	}, ca, pub, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchors := x509.NewCertPool()
+	anchors.AddCert(ca)
+
+	return anchors, cert
+}
+
+func TestVerifierAuthorized(t *testing.T) {
+	s, addr := testSigner(t)
+	now := time.Unix(1000, 0)
+
+	anchors, cert := testCertificate(t, s.Key.Public(), now)
+
+	v := NewVerifier(anchors)
+	v.now = func() time.Time { return now }
+	v.Replay.now = v.now
+	s.now = v.now
+
+	// routers need a certified key
+	m, md := testVerified(t, s, &ICMPRouterAdvertisement{RouterLifeTime: 1800}, addr, net.IPv6linklocalallnodes, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDInvalid || vd.Reason != errRouterNotAuthorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	v.Certificates = []*x509.Certificate{cert}
+	if vd := v.Verify(m, md); vd.Status != SENDSecured || !vd.Authorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// the certificate profile of RFC 6494 has router certificates say so
+	v.RequireRouterEKU = true
+	if vd := v.Verify(m, md); vd.Authorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	_, cert = testCertificate(t, s.Key.Public(), now, ExtKeyUsageSENDRouter)
+	v.Certificates = []*x509.Certificate{cert}
+	if vd := v.Verify(m, md); vd.Authorized {
+		t.Errorf("expected certificate of another anchor not to authorize, not %+v", vd)
+	}
+	anchors, cert = testCertificate(t, s.Key.Public(), now, ExtKeyUsageSENDRouter)
+	v.TrustAnchors, v.Certificates = anchors, []*x509.Certificate{cert}
+	if vd := v.Verify(m, md); vd.Status != SENDSecured || !vd.Authorized {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	var served int
+	h := v.SecuredOnly(HandlerFunc(func(ICMP, *Metadata) { served++ }))
+	h.ServeNDP(m, md)
+	h.ServeNDP(&ICMPRouterAdvertisement{RouterLifeTime: 1800}, md)
+	if served != 1 {
+		t.Errorf("expected only the secured message to be served, not %d", served)
+	}
+}
+
+func TestVerifierReceivedBytes(t *testing.T) {
+	s, addr := testSigner(t)
+	now := time.Unix(1000, 0)
+	anchors, cert := testCertificate(t, s.Key.Public(), now)
+	v := NewVerifier(anchors)
+	v.Certificates = []*x509.Certificate{cert}
+	v.now = func() time.Time { return now }
+	v.Replay.now = v.now
+	s.now = v.now
+
+	// route information options of /64 prefixes may take 3 units rather
+	// than the 2 they marshal to, as allowed by
+	// https://tools.ietf.org/html/rfc4191#section-2.3
+	body := append([]byte{64, 0, 0, 0, 0x07, 0x08}, net.ParseIP("2001:db8::")...)
+	rio, err := NewRawOption(ICMPOptionTypeRouteInformation, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra := &ICMPRouterAdvertisement{RouterLifeTime: 1800}
+	ra.AddOption(rio)
+	if err := s.Sign(ra, addr, net.IPv6linklocalallnodes, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ra.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Conn{t: &testTransport{
+		in: [][]byte{b},
+		md: &Metadata{Source: addr, Destination: net.IPv6linklocalallnodes, HopLimit: 255},
+	}}
+	m, md, err := c.ReadFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*ICMPRouterAdvertisement).Options[0].(*ICMPOptionRouteInformation); !ok {
+		t.Fatalf("unexpected option %s", m.(*ICMPRouterAdvertisement).Options[0])
+	}
+	if remarshaled, _ := m.Marshal(); len(remarshaled) == len(b) {
+		t.Fatalf("expected the route information option to marshal differently")
+	}
+
+	if vd := v.Verify(m, md); vd.Status != SENDSecured {
+		t.Errorf("expected the signature over the received bytes to verify, not %+v", vd)
+	}
+	// which the marshaled message doesn't
+	if vd := v.Verify(m, &Metadata{Source: addr, Destination: net.IPv6linklocalallnodes}); vd.Reason != errBadSignature {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// options that don't lead up to the signature fail
+	md.Raw = append([]byte(nil), b...)
+	md.Raw[optionsOffset(m.Type())+1] = 4
+	if vd := v.Verify(m, md); vd.Reason != errBadSignature {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+}
+
+func TestVerifierECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, addr := testKeySigner(t, key)
+	v := NewVerifier(nil)
+	v.now = s.now
+	v.Replay.now = v.now
+	target := net.ParseIP("fe80::2")
+
+	m, md := testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, target, 0)
+	if vd := v.Verify(m, md); vd.Status != SENDSecured {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+	m.(*ICMPNeighborSolicitation).TargetAddress = net.ParseIP("fe80::3")
+	if vd := v.Verify(m, md); vd.Reason != errBadSignature {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+
+	// curves smaller than P-256 are too weak
+	small, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, addr = testKeySigner(t, small)
+	m, md = testVerified(t, s, &ICMPNeighborSolicitation{TargetAddress: target}, addr, target, 0)
+	if vd := v.Verify(m, md); vd.Reason != errKeyTooSmall {
+		t.Errorf("unexpected verdict %+v", vd)
+	}
+}
+
+func TestSENDStatusString(t *testing.T) {
+	for s, str := range map[SENDStatus]string{SENDUnsecured: "unsecured", SENDSecured: "secured", SENDInvalid: "invalid", 42: "unknown"} {
+		if s.String() != str {
+			t.Errorf("expected %q, not %q", str, s.String())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ndp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+var errNotICMPv6Message = errors.New("not an ICMPv6 message")
+
+// ToICMPMessage returns m as an icmp.Message of golang.org/x/net/icmp, with
+// the marshaled body as its icmp.RawBody. Like m, it has no checksum, which
+// icmp.Message.Marshal computes when given a pseudo header and the kernel
+// does otherwise
+func ToICMPMessage(m ICMP) (*icmp.Message, error) {
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, errMessageTooShort
+	}
+
+	return &icmp.Message{
+		Type:     m.Type(),
+		Code:     int(b[1]),
+		Checksum: int(binary.BigEndian.Uint16(b[2:4])),
+		Body:     &icmp.RawBody{Data: b[4:]},
+	}, nil
+}
+
+// FromICMPMessage returns the message m of golang.org/x/net/icmp holds, like
+// those icmp.ParseMessage returns, see ParseMessage
+func FromICMPMessage(m *icmp.Message) (ICMP, error) {
+	if _, ok := m.Type.(ipv6.ICMPType); !ok {
+		return nil, fmt.Errorf("%s: %v", errNotICMPv6Message, m.Type)
+	}
+
+	b, err := m.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMessage(b)
+}
@@ -0,0 +1,56 @@
+package ndp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestToICMPMessage(t *testing.T) {
+	ns := &ICMPNeighborSolicitation{TargetAddress: net.ParseIP("fe80::2")}
+	ns.AddOption(&ICMPOptionSourceLinkLayerAddress{LinkLayerAddress: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}})
+
+	m, err := ToICMPMessage(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != ipv6.ICMPTypeNeighborSolicitation || m.Code != 0 {
+		t.Errorf("unexpected message %+v", m)
+	}
+
+	// what x/net marshals parses back into the original
+	b, err := m.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := ns.Marshal()
+	if !reflect.DeepEqual(b, expected) {
+		t.Errorf("expected %x, not %x", expected, b)
+	}
+
+	// as does what x/net parses
+	parsed, err := icmp.ParseMessage(58, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromICMPMessage(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, ns) {
+		t.Errorf("expected %s, not %s", ns, back)
+	}
+}
+
+func TestFromICMPMessageErrors(t *testing.T) {
+	if _, err := FromICMPMessage(&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{}}); err == nil {
+		t.Error("expected ICMPv4 to be rejected")
+	}
+	if _, err := FromICMPMessage(&icmp.Message{Type: ipv6.ICMPTypeNeighborSolicitation}); err == nil {
+		t.Error("expected a message without body to be rejected")
+	}
+}